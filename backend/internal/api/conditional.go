@@ -0,0 +1,61 @@
+package api
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// libraryETag derives an ETag for a library listing from the library version and
+// the request query, so each distinct listing is validated independently. The
+// epoch keeps tags from a previous process from matching after a restart.
+func libraryETag(epoch int64, version uint64, r *http.Request) string {
+	query := sha256.Sum256([]byte(r.URL.Query().Encode()))
+	return fmt.Sprintf(`"%x-%d-%x"`, epoch, version, query[:6])
+}
+
+// conditional reports whether r may be answered with 304 Not Modified, which
+// only GET and HEAD requests can be.
+func conditional(r *http.Request) bool {
+	return r.Method == http.MethodGet || r.Method == http.MethodHead
+}
+
+// checkNotModified sets the ETag header and, when the client's If-None-Match
+// already matches it on a GET or HEAD, writes a 304 and reports true.
+func checkNotModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+
+	ifNoneMatch := r.Header.Get("If-None-Match")
+	if ifNoneMatch == "" || !conditional(r) {
+		return false
+	}
+
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkNotModifiedSince sets Last-Modified and, when the client's
+// If-Modified-Since on a GET or HEAD is not older than modTime, writes a 304
+// and reports true. HTTP dates have whole-second precision, so modTime is
+// compared truncated.
+func checkNotModifiedSince(w http.ResponseWriter, r *http.Request, modTime time.Time) bool {
+	modTime = modTime.UTC().Truncate(time.Second)
+	w.Header().Set("Last-Modified", modTime.Format(http.TimeFormat))
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil || modTime.After(since) || !conditional(r) {
+		return false
+	}
+
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
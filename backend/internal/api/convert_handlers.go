@@ -0,0 +1,73 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/Steven-harris/sortify/backend/internal/media"
+	"github.com/Steven-harris/sortify/backend/pkg/response"
+)
+
+// convertingFileServer wraps the static media file server so that
+// ?format=jpeg returns a browser-friendly rendition of formats such as HEIC
+// and ?format=thumbnail a cached preview. Whenever conversion is unnecessary
+// or impossible the original is served.
+func (h *MediaHandlers) convertingFileServer(files http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		format := strings.ToLower(r.URL.Query().Get("format"))
+		if format == "" {
+			files.ServeHTTP(w, r)
+			return
+		}
+		if format == "thumbnail" {
+			h.serveThumbnail(w, r, files)
+			return
+		}
+		if format != "jpeg" && format != "jpg" {
+			response.BadRequest(w, "Unsupported format, expected jpeg or thumbnail")
+			return
+		}
+
+		fullPath, err := h.organizer.ResolvePath(r.URL.Path)
+		if err != nil || !media.NeedsConversion(fullPath) || h.converter == nil {
+			files.ServeHTTP(w, r)
+			return
+		}
+
+		convertedPath, err := h.converter.ToJPEG(r.Context(), fullPath)
+		if err != nil {
+			if !errors.Is(err, media.ErrConversionUnavailable) {
+				slog.Warn("Image conversion failed, serving original", "error", err, "path", r.URL.Path)
+			}
+			files.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "image/jpeg")
+		http.ServeFile(w, r, convertedPath)
+	})
+}
+
+func (h *MediaHandlers) serveThumbnail(w http.ResponseWriter, r *http.Request, files http.Handler) {
+	fullPath, err := h.organizer.ResolvePath(r.URL.Path)
+	if err != nil || h.thumbnailer == nil || !h.thumbnailer.Supports(fullPath) {
+		files.ServeHTTP(w, r)
+		return
+	}
+
+	thumbnailPath, release, err := h.thumbnailer.AcquireThumbnail(r.Context(), fullPath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			slog.Warn("Thumbnail generation failed, serving original", "error", err, "path", r.URL.Path)
+		}
+		files.ServeHTTP(w, r)
+		return
+	}
+	defer release()
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	http.ServeFile(w, r, thumbnailPath)
+}
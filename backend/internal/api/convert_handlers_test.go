@@ -0,0 +1,92 @@
+package api
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image/jpeg"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/Steven-harris/sortify/backend/internal/media"
+)
+
+// tinyBMP returns a 1x1 24-bit bitmap, a format browsers can't be relied on to
+// render and that every converter understands.
+func tinyBMP() string {
+	var buf bytes.Buffer
+	buf.WriteString("BM")
+	binary.Write(&buf, binary.LittleEndian, uint32(58)) // file size
+	binary.Write(&buf, binary.LittleEndian, uint32(0))  // reserved
+	binary.Write(&buf, binary.LittleEndian, uint32(54)) // pixel data offset
+	binary.Write(&buf, binary.LittleEndian, uint32(40)) // header size
+	binary.Write(&buf, binary.LittleEndian, int32(1))   // width
+	binary.Write(&buf, binary.LittleEndian, int32(1))   // height
+	binary.Write(&buf, binary.LittleEndian, uint16(1))  // planes
+	binary.Write(&buf, binary.LittleEndian, uint16(24)) // bits per pixel
+	binary.Write(&buf, binary.LittleEndian, [6]uint32{0, 4, 2835, 2835, 0, 0})
+	buf.Write([]byte{0, 0, 255, 0}) // one red pixel plus row padding
+	return buf.String()
+}
+
+func newConvertingServer(handler *MediaHandlers, mediaDir string) http.Handler {
+	return http.StripPrefix("/media/", handler.convertingFileServer(http.FileServer(http.Dir(mediaDir))))
+}
+
+func TestConvertingFileServerFallsBackToOriginal(t *testing.T) {
+	mediaDir := t.TempDir()
+	handler := NewMediaHandlers(mediaDir)
+	original := tinyBMP()
+	writeMediaFile(t, mediaDir, "2024/March/scan.bmp", original)
+	server := newConvertingServer(handler, mediaDir)
+
+	// No converter configured: the original is served untouched
+	rr := httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest("GET", "/media/2024/March/scan.bmp?format=jpeg", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if rr.Body.String() != original {
+		t.Error("Expected original file content")
+	}
+
+	rr = httptest.NewRecorder()
+	server.ServeHTTP(rr, httptest.NewRequest("GET", "/media/2024/March/scan.bmp?format=avif", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for unsupported format, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestConvertingFileServerConvertsToJPEG(t *testing.T) {
+	found := false
+	for _, tool := range []string{"magick", "convert", "ffmpeg"} {
+		if _, err := exec.LookPath(tool); err == nil {
+			found = true
+		}
+	}
+	if !found {
+		t.Skip("No image converter available")
+	}
+
+	mediaDir := t.TempDir()
+	handler := NewMediaHandlers(mediaDir)
+	handler.converter = media.NewConverter(filepath.Join(t.TempDir(), "converted"))
+	writeMediaFile(t, mediaDir, "2024/March/scan.bmp", tinyBMP())
+	server := newConvertingServer(handler, mediaDir)
+
+	for i := 0; i < 2; i++ { // second request is served from the cache
+		rr := httptest.NewRecorder()
+		server.ServeHTTP(rr, httptest.NewRequest("GET", "/media/2024/March/scan.bmp?format=jpeg", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+		}
+		if contentType := rr.Header().Get("Content-Type"); contentType != "image/jpeg" {
+			t.Errorf("Expected Content-Type image/jpeg, got %s", contentType)
+		}
+		if _, err := jpeg.Decode(rr.Body); err != nil {
+			t.Errorf("Expected a valid JPEG: %v", err)
+		}
+	}
+}
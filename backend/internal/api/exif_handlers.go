@@ -0,0 +1,84 @@
+package api
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+
+	"github.com/Steven-harris/sortify/backend/internal/media"
+	"github.com/Steven-harris/sortify/backend/pkg/response"
+)
+
+// EXIFHandler returns every EXIF tag of a library file, to help explain why a
+// photo was dated the way it was.
+func (h *MediaHandlers) EXIFHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	relPath := r.URL.Query().Get("path")
+	fullPath, err := h.organizer.ResolvePath(relPath)
+	if err != nil {
+		response.BadRequest(w, err.Error())
+		return
+	}
+
+	tags, err := h.organizer.Extractor().EXIFTags(fullPath)
+	if os.IsNotExist(err) {
+		response.NotFound(w, "File not found")
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to read EXIF tags", "error", err, "path", relPath)
+		response.InternalError(w, "Failed to read EXIF tags")
+		return
+	}
+
+	response.Success(w, tags)
+}
+
+// CaptureInfoHandler returns the camera and exposure settings of the photo
+// with ?id=, both structured and as a one-line summary for captions.
+func (h *MediaHandlers) CaptureInfoHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		response.BadRequest(w, "File ID is required")
+		return
+	}
+
+	file, err := h.organizer.FindFileByID(id)
+	if os.IsNotExist(err) {
+		response.NotFound(w, "File not found")
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to find file", "error", err, "id", id)
+		response.InternalError(w, "Failed to find file")
+		return
+	}
+	relPath := file.RelativePath
+
+	info, err := h.organizer.CaptureInfo(relPath)
+	if errors.Is(err, media.ErrInvalidPath) {
+		response.BadRequest(w, err.Error())
+		return
+	}
+	if os.IsNotExist(err) {
+		response.NotFound(w, "File not found")
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to read capture info", "error", err, "path", relPath)
+		response.InternalError(w, "Failed to read capture info")
+		return
+	}
+
+	response.Success(w, info)
+}
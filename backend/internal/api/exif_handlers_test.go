@@ -0,0 +1,89 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEXIFHandler(t *testing.T) {
+	mediaDir := t.TempDir()
+	handler := NewMediaHandlers(mediaDir)
+
+	if err := os.MkdirAll(filepath.Join(mediaDir, "2024", "March"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(mediaDir, "2024", "March", "plain.jpg"), []byte("no exif"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"File without EXIF", "?path=2024/March/plain.jpg", http.StatusOK, "{}"},
+		{"Missing path", "", http.StatusBadRequest, ""},
+		{"Library root", "?path=../..", http.StatusBadRequest, ""},
+		{"Missing file", "?path=2024/March/missing.jpg", http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.EXIFHandler(rr, httptest.NewRequest("GET", "/api/media/exif"+test.query, nil))
+
+			if rr.Code != test.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", test.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if test.expectedBody != "" && rr.Body.String() != test.expectedBody+"\n" {
+				t.Errorf("Expected body %s, got %s", test.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestCaptureInfoHandler(t *testing.T) {
+	mediaDir := t.TempDir()
+	handler := NewMediaHandlers(mediaDir)
+
+	if err := os.MkdirAll(filepath.Join(mediaDir, "2024", "March"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(mediaDir, "2024", "March", "plain.jpg"), []byte("no exif"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	file, err := handler.organizer.FileInfo("2024/March/plain.jpg")
+	if err != nil {
+		t.Fatalf("FileInfo failed: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"File without EXIF", "?id=" + file.ID, http.StatusOK, `{"relativePath":"2024/March/plain.jpg","summary":""}`},
+		{"Missing id", "", http.StatusBadRequest, ""},
+		{"Path instead of id", "?id=2024/March/plain.jpg", http.StatusNotFound, ""},
+		{"Unknown id", "?id=0000000000000000", http.StatusNotFound, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.CaptureInfoHandler(rr, httptest.NewRequest("GET", "/api/media/capture-info"+test.query, nil))
+
+			if rr.Code != test.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", test.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if test.expectedBody != "" && rr.Body.String() != test.expectedBody+"\n" {
+				t.Errorf("Expected body %s, got %s", test.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
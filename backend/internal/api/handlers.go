@@ -1,9 +1,15 @@
 package api
 
 import (
+	"errors"
+	"io/fs"
+	"log/slog"
 	"net/http"
+	"path/filepath"
+	"runtime"
 	"time"
 
+	"github.com/Steven-harris/sortify/backend/internal/media"
 	"github.com/Steven-harris/sortify/backend/pkg/response"
 )
 
@@ -23,6 +29,104 @@ func (s *Server) HealthHandler(w http.ResponseWriter, r *http.Request) {
 	response.Success(w, healthData)
 }
 
+// MetricsHandler reports hit and miss counters and occupancy for the
+// in-memory metadata cache and the on-disk thumbnail cache.
+func (s *Server) MetricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	response.Success(w, map[string]any{
+		"caches": s.cacheStats(),
+	})
+}
+
+func (s *Server) cacheStats() map[string]media.CacheStats {
+	caches := map[string]media.CacheStats{
+		"metadata": s.mediaHandler.organizer.MetadataCacheStats(),
+	}
+	if s.mediaHandler.thumbnailer != nil {
+		caches["thumbnails"] = s.mediaHandler.thumbnailer.CacheStats()
+	}
+	return caches
+}
+
+// DebugStatsHandler reports runtime, upload and cache figures for an operator
+// checking on a running server by hand. Reading memory statistics briefly
+// stops the world, so it is only served when debug endpoints are enabled.
+func (s *Server) DebugStatsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	manager := s.uploadHandler.manager
+	active, err := manager.ActiveSessions()
+	if err != nil {
+		slog.Error("Failed to count upload sessions", "error", err)
+		response.InternalError(w, "Failed to count upload sessions")
+		return
+	}
+
+	tempBytes, tempFiles, err := dirUsage(manager.TempDir())
+	if err != nil {
+		slog.Error("Failed to measure temp directory", "error", err)
+		response.InternalError(w, "Failed to measure temp directory")
+		return
+	}
+
+	response.Success(w, map[string]any{
+		"goroutines": runtime.NumGoroutine(),
+		"memory": map[string]any{
+			"allocBytes":      mem.Alloc,
+			"totalAllocBytes": mem.TotalAlloc,
+			"sysBytes":        mem.Sys,
+			"heapObjects":     mem.HeapObjects,
+			"numGC":           mem.NumGC,
+			"gcPauseTotal":    time.Duration(mem.PauseTotalNs).String(),
+		},
+		"uploads": map[string]any{
+			"activeSessions": active,
+			"maxSessions":    manager.MaxSessions(),
+			"reservedBytes":  manager.ReservedBytes(),
+		},
+		"caches": s.cacheStats(),
+		"tempDir": map[string]any{
+			"path":  manager.TempDir(),
+			"bytes": tempBytes,
+			"files": tempFiles,
+		},
+	})
+}
+
+// dirUsage totals the size and number of files under dir. Files removed
+// while it walks are skipped.
+func dirUsage(dir string) (int64, int, error) {
+	var bytes int64
+	var files int
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) && path != dir {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			bytes += info.Size()
+			files++
+		}
+		return nil
+	})
+	return bytes, files, err
+}
+
 func (s *Server) NotFoundHandler(w http.ResponseWriter, r *http.Request) {
 	response.NotFound(w, "Endpoint not found")
 }
@@ -38,9 +142,10 @@ func (s *Server) RootHandler(w http.ResponseWriter, r *http.Request) {
 		"description": "Photo and video management API",
 		"version":     "1.0.0",
 		"endpoints": map[string]string{
-			"health": "/api/health",
-			"upload": "/api/upload/*",
-			"media":  "/api/media/*",
+			"health":  "/api/health",
+			"metrics": "/api/metrics",
+			"upload":  "/api/upload/*",
+			"media":   "/api/media/*",
 		},
 	}
 
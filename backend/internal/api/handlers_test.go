@@ -0,0 +1,93 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Steven-harris/sortify/backend/internal/config"
+	"github.com/Steven-harris/sortify/backend/internal/models"
+)
+
+func TestDebugStatsHandler(t *testing.T) {
+	newServer := func(debug bool) *Server {
+		return NewServer(&config.Config{
+			MediaPath:         t.TempDir(),
+			CachePath:         t.TempDir(),
+			DataPath:          t.TempDir(),
+			CORSOrigins:       "*",
+			AdminToken:        "secret",
+			DebugEndpoints:    debug,
+			MaxUploadSessions: 2,
+		})
+	}
+	get := func(server *Server, authorization string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/debug/stats", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		rr := httptest.NewRecorder()
+		server.setupRoutes().ServeHTTP(rr, req)
+		return rr
+	}
+
+	if rr := get(newServer(false), "Bearer secret"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d without the debug flag, got %d", http.StatusNotFound, rr.Code)
+	}
+
+	server := newServer(true)
+	if rr := get(server, ""); rr.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d without a token, got %d", http.StatusUnauthorized, rr.Code)
+	}
+
+	if _, err := server.uploadHandler.manager.CreateSession(&models.StartUploadRequest{
+		FileName:  "test.jpg",
+		FileSize:  1024,
+		ChunkSize: 256,
+	}); err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	rr := get(server, "Bearer secret")
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var stats struct {
+		Goroutines int `json:"goroutines"`
+		Memory     struct {
+			AllocBytes uint64 `json:"allocBytes"`
+			SysBytes   uint64 `json:"sysBytes"`
+		} `json:"memory"`
+		Uploads struct {
+			ActiveSessions int   `json:"activeSessions"`
+			MaxSessions    int   `json:"maxSessions"`
+			ReservedBytes  int64 `json:"reservedBytes"`
+		} `json:"uploads"`
+		Caches  map[string]json.RawMessage `json:"caches"`
+		TempDir struct {
+			Bytes int64 `json:"bytes"`
+			Files int   `json:"files"`
+		} `json:"tempDir"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &stats); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	if stats.Goroutines < 1 || stats.Goroutines > 10000 {
+		t.Errorf("Expected a plausible goroutine count, got %d", stats.Goroutines)
+	}
+	if stats.Memory.AllocBytes == 0 || stats.Memory.SysBytes < stats.Memory.AllocBytes {
+		t.Errorf("Expected plausible memory figures, got %+v", stats.Memory)
+	}
+	if stats.Uploads.ActiveSessions != 1 || stats.Uploads.MaxSessions != 2 || stats.Uploads.ReservedBytes != 1024 {
+		t.Errorf("Expected 1 of 2 sessions reserving 1024 bytes, got %+v", stats.Uploads)
+	}
+	if _, ok := stats.Caches["metadata"]; !ok {
+		t.Errorf("Expected metadata cache stats, got %v", stats.Caches)
+	}
+	if stats.TempDir.Files != 1 || stats.TempDir.Bytes != 1024 {
+		t.Errorf("Expected the preallocated upload in the temp dir, got %+v", stats.TempDir)
+	}
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Steven-harris/sortify/backend/internal/media"
+	"github.com/Steven-harris/sortify/backend/pkg/response"
+)
+
+// ImportHandler imports a folder under the configured import path into the
+// library. Event-based organization lives here rather than in the upload flow
+// because clustering needs the whole batch up front.
+func (h *MediaHandlers) ImportHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	sourceDir, opts, ok := h.decodeImportRequest(w, r)
+	if !ok {
+		return
+	}
+
+	result, err := h.organizer.ImportDirectory(r.Context(), sourceDir, opts)
+	if err != nil {
+		slog.Error("Failed to import folder", "error", err, "folder", sourceDir)
+		response.InternalError(w, "Failed to import folder")
+		return
+	}
+
+	if len(result.Imported) > 0 {
+		h.startThumbnailPregeneration()
+	}
+
+	response.Success(w, result)
+}
+
+// ImportPlanHandler reports what importing a folder would do, without copying
+// anything. It takes the same request as ImportHandler; the plan it returns
+// can be reviewed and then sent to ApplyImportPlanHandler.
+func (h *MediaHandlers) ImportPlanHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	sourceDir, opts, ok := h.decodeImportRequest(w, r)
+	if !ok {
+		return
+	}
+
+	plan, err := media.NewPlanner(h.organizer).Plan(r.Context(), sourceDir, opts)
+	if err != nil {
+		slog.Error("Failed to plan import", "error", err, "folder", sourceDir)
+		response.InternalError(w, "Failed to plan import")
+		return
+	}
+
+	response.Success(w, plan)
+}
+
+// ApplyImportPlanHandler imports the files in a plan from ImportPlanHandler.
+// Files that changed since planning are reported as failures.
+func (h *MediaHandlers) ApplyImportPlanHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var plan media.ImportPlan
+	if err := json.NewDecoder(r.Body).Decode(&plan); err != nil {
+		slog.Error("Failed to decode import plan", "error", err)
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if h.importPath == "" {
+		response.Error(w, http.StatusServiceUnavailable, "Imports are not configured")
+		return
+	}
+
+	// The plan comes back from the client, so its source must still be one
+	// this server would have planned
+	if rel, err := filepath.Rel(h.importPath, plan.SourceDir); err != nil || !filepath.IsAbs(plan.SourceDir) ||
+		rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		errs := response.ValidationErrors{}
+		errs.Add("sourceDir", "must be inside the import directory")
+		response.ValidationFailed(w, errs)
+		return
+	}
+
+	result, err := media.NewPlanner(h.organizer).Apply(r.Context(), &plan)
+	if err != nil {
+		slog.Error("Failed to apply import plan", "error", err, "folder", plan.SourceDir)
+		response.InternalError(w, "Failed to apply import plan")
+		return
+	}
+
+	if len(result.Imported) > 0 {
+		h.startThumbnailPregeneration()
+	}
+
+	response.Success(w, result)
+}
+
+// decodeImportRequest reads and validates an import request, writing the
+// error response itself when it reports false.
+func (h *MediaHandlers) decodeImportRequest(w http.ResponseWriter, r *http.Request) (string, media.ImportOptions, bool) {
+	var req struct {
+		Folder   string `json:"folder"`   // Relative to the import path; empty imports all of it
+		Strategy string `json:"strategy"` // "month" (default) or "events"
+		EventGap string `json:"eventGap"` // Optional override, e.g. "6h"
+		BurstGap string `json:"burstGap"` // Optional override, e.g. "2s"; "0s" disables
+
+		PreserveDirs *int `json:"preserveDirs"` // Optional override; 0 flattens
+	}
+
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			slog.Error("Failed to decode import request", "error", err)
+			response.BadRequest(w, "Invalid request body")
+			return "", media.ImportOptions{}, false
+		}
+	}
+
+	if h.importPath == "" {
+		response.Error(w, http.StatusServiceUnavailable, "Imports are not configured")
+		return "", media.ImportOptions{}, false
+	}
+
+	errs := response.ValidationErrors{}
+
+	sourceDir, err := media.ResolveWithin(h.importPath, req.Folder)
+	if err != nil {
+		errs.Add("folder", "must be inside the import directory")
+	}
+
+	strategy := media.ImportStrategy(req.Strategy)
+	if strategy != "" && strategy != media.ImportByMonth && strategy != media.ImportByEvent {
+		errs.Add("strategy", "must be one of month, events")
+	}
+
+	eventGap := h.eventGap
+	if req.EventGap != "" {
+		if eventGap, err = time.ParseDuration(req.EventGap); err != nil || eventGap <= 0 {
+			errs.Add("eventGap", "must be a positive duration such as 6h")
+		}
+	}
+
+	burstGap := h.burstGap
+	if req.BurstGap != "" {
+		if burstGap, err = time.ParseDuration(req.BurstGap); err != nil || burstGap < 0 {
+			errs.Add("burstGap", "must be a duration such as 2s, or 0s to disable")
+		}
+	}
+
+	preserveDirs := h.preserveDirs
+	if req.PreserveDirs != nil {
+		if preserveDirs = *req.PreserveDirs; preserveDirs < 0 {
+			errs.Add("preserveDirs", "must be 0 or more")
+		}
+	}
+
+	if errs.HasErrors() {
+		response.ValidationFailed(w, errs)
+		return "", media.ImportOptions{}, false
+	}
+
+	return sourceDir, media.ImportOptions{
+		Strategy:     strategy,
+		EventGap:     eventGap,
+		BurstGap:     burstGap,
+		PreserveDirs: preserveDirs,
+	}, true
+}
@@ -2,10 +2,12 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"log/slog"
 	"net/http"
+	"os"
 	"strconv"
-	"strings"
+	"time"
 
 	"github.com/Steven-harris/sortify/backend/internal/media"
 	"github.com/Steven-harris/sortify/backend/pkg/response"
@@ -13,11 +15,37 @@ import (
 
 type MediaHandlers struct {
 	organizer *media.Organizer
+	etagEpoch int64
+	scanLimit int              // Maximum files ListFilesHandler materializes per request
+	maxLimit  int              // Largest page a listing returns; bigger limits are clamped
+	converter *media.Converter // Nil disables ?format=jpeg conversion
+
+	integrityBytesPerSecond int64
+
+	importPath string
+	eventGap   time.Duration
+	burstGap   time.Duration // Zero disables burst detection on import
+
+	preserveDirs int // Source folders kept under the date folder on import
+
+	thumbnailer      *media.Thumbnailer // Nil disables thumbnails
+	thumbnailWorkers int
+	thumbnailJob     thumbnailJob
 }
 
 func NewMediaHandlers(mediaPath string) *MediaHandlers {
+	return newMediaHandlers(media.NewOrganizer(mediaPath))
+}
+
+func newMediaHandlers(organizer *media.Organizer) *MediaHandlers {
 	return &MediaHandlers{
-		organizer: media.NewOrganizer(mediaPath),
+		organizer: organizer,
+		etagEpoch: time.Now().UnixNano(),
+		scanLimit: 10000,
+		maxLimit:  defaultMaxLimit,
+		eventGap:  media.DefaultEventGap,
+
+		thumbnailWorkers: 4,
 	}
 }
 
@@ -27,20 +55,18 @@ func (h *MediaHandlers) BrowseHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if checkNotModified(w, r, libraryETag(h.etagEpoch, h.organizer.Version(), r)) {
+		return
+	}
+
 	year := r.URL.Query().Get("year")
 	month := r.URL.Query().Get("month")
 	limit := r.URL.Query().Get("limit")
 	offset := r.URL.Query().Get("offset")
 
-	limitInt := 50
+	limitInt, limitClamped := h.pageLimit(w, limit)
 	offsetInt := 0
 
-	if limit != "" {
-		if l, err := strconv.Atoi(limit); err == nil && l > 0 {
-			limitInt = l
-		}
-	}
-
 	if offset != "" {
 		if o, err := strconv.Atoi(offset); err == nil && o >= 0 {
 			offsetInt = o
@@ -70,28 +96,35 @@ func (h *MediaHandlers) BrowseHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	response.Success(w, map[string]any{
-		"type":   "files",
-		"year":   year,
-		"month":  month,
-		"files":  files,
-		"limit":  limitInt,
-		"offset": offsetInt,
+		"type":         "files",
+		"year":         year,
+		"month":        month,
+		"files":        files,
+		"limit":        limitInt,
+		"limitClamped": limitClamped,
+		"offset":       offsetInt,
 	})
 }
 
+// MetadataHandler extracts a file's metadata. The path comes as the filePath
+// query parameter of a GET, which honours If-Modified-Since, or in the JSON
+// body of a POST, which is always answered in full.
 func (h *MediaHandlers) MetadataHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
 	var req struct {
 		FilePath string `json:"filePath"`
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		slog.Error("Failed to decode metadata request", "error", err)
-		response.BadRequest(w, "Invalid request body")
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		req.FilePath = r.URL.Query().Get("filePath")
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			slog.Error("Failed to decode metadata request", "error", err)
+			response.BadRequest(w, "Invalid request body")
+			return
+		}
+	default:
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
@@ -100,64 +133,48 @@ func (h *MediaHandlers) MetadataHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	extractor := media.NewExtractor()
-	info, err := extractor.ExtractMetadata(req.FilePath)
-	if err != nil {
-		slog.Error("Failed to extract metadata", "error", err, "filePath", req.FilePath)
-		response.InternalError(w, "Failed to extract metadata")
+	stat, err := os.Stat(req.FilePath)
+	if os.IsNotExist(err) {
+		response.NotFound(w, "File not found")
 		return
 	}
-
-	response.Success(w, info)
-}
-
-func (h *MediaHandlers) UserDateHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+	if err == nil && checkNotModifiedSince(w, r, stat.ModTime()) {
 		return
 	}
 
-	var req media.DateExtractionResponse
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		slog.Error("Failed to decode user date request", "error", err)
-		response.BadRequest(w, "Invalid request body")
-		return
-	}
-
-	if req.SessionID == "" {
-		response.BadRequest(w, "Session ID is required")
+	info, err := h.organizer.Extractor().ExtractMetadata(req.FilePath)
+	if err != nil {
+		slog.Error("Failed to extract metadata", "error", err, "filePath", req.FilePath)
+		response.InternalError(w, "Failed to extract metadata")
 		return
 	}
 
-	// TODO: Store the user-provided date and complete the upload process
-	slog.Info("User provided date for upload",
-		"sessionId", req.SessionID,
-		"dateTaken", req.DateTaken,
-	)
-
-	response.NoContent(w)
+	response.Success(w, info)
 }
 
+// ListFilesHandler lists library files a page at a time, filtered by type and
+// searched by q. A plain q is one phrase matched against the name, camera,
+// location and caption. A q with field:value terms, such as
+// "camera:canon,nikon type:photo 2024", must match every term; see
+// media.Query for the fields and grammar.
 func (h *MediaHandlers) ListFilesHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
-	query := r.URL.Query().Get("q")
+	if checkNotModified(w, r, libraryETag(h.etagEpoch, h.organizer.Version(), r)) {
+		return
+	}
+
+	query := media.ParseQuery(r.URL.Query().Get("q"))
 	mediaType := r.URL.Query().Get("type")
 	limit := r.URL.Query().Get("limit")
 	offset := r.URL.Query().Get("offset")
 
-	limitInt := 50
+	limitInt, limitClamped := h.pageLimit(w, limit)
 	offsetInt := 0
 
-	if limit != "" {
-		if l, err := strconv.Atoi(limit); err == nil && l > 0 {
-			limitInt = l
-		}
-	}
-
 	if offset != "" {
 		if o, err := strconv.Atoi(offset); err == nil && o >= 0 {
 			offsetInt = o
@@ -165,26 +182,32 @@ func (h *MediaHandlers) ListFilesHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	// Get all files without pagination first
-	allFiles, err := h.organizer.ScanFiles("", "", 10000, 0)
+	allFiles, err := h.organizer.ScanFiles("", "", h.scanLimit, 0)
 	if err != nil {
 		slog.Error("Failed to scan files", "error", err)
 		response.InternalError(w, "Failed to retrieve files")
 		return
 	}
 
+	total, err := h.organizer.CountFiles("", "")
+	if err != nil {
+		slog.Error("Failed to count files", "error", err)
+		response.InternalError(w, "Failed to retrieve files")
+		return
+	}
+
+	truncated := total > len(allFiles)
+	if truncated {
+		slog.Warn("File listing truncated by scan limit",
+			"scan_limit", h.scanLimit,
+			"total", total,
+		)
+	}
+
 	var filteredFiles []media.MediaFileInfo
 	for _, file := range allFiles {
-		if query != "" {
-			queryMatch := false
-			queryLower := strings.ToLower(query)
-			if strings.Contains(strings.ToLower(file.FileName), queryLower) ||
-				strings.Contains(strings.ToLower(file.Camera), queryLower) ||
-				strings.Contains(strings.ToLower(file.Location), queryLower) {
-				queryMatch = true
-			}
-			if !queryMatch {
-				continue
-			}
+		if !query.Matches(&file) {
+			continue
 		}
 
 		if mediaType != "" && mediaType != "all" && file.MediaType != mediaType {
@@ -207,13 +230,447 @@ func (h *MediaHandlers) ListFilesHandler(w http.ResponseWriter, r *http.Request)
 	}
 
 	response.Success(w, map[string]any{
-		"files":  filteredFiles,
-		"total":  len(allFiles),
-		"limit":  limitInt,
-		"offset": offsetInt,
+		"files":        filteredFiles,
+		"total":        total,
+		"truncated":    truncated,
+		"limit":        limitInt,
+		"limitClamped": limitClamped,
+		"offset":       offsetInt,
 	})
 }
 
+const (
+	defaultPageLimit = 50
+	defaultMaxLimit  = 1000
+)
+
+// pageLimit parses a listing's limit parameter, defaulting to
+// defaultPageLimit. Limits above maxLimit are clamped so one request can't
+// ask for the whole library; the clamp is reported in the X-Limit-Clamped
+// header (carrying the applied limit) as well as the response body.
+func (h *MediaHandlers) pageLimit(w http.ResponseWriter, value string) (int, bool) {
+	limit := defaultPageLimit
+	if l, err := strconv.Atoi(value); err == nil && l > 0 {
+		limit = l
+	}
+
+	if h.maxLimit > 0 && limit > h.maxLimit {
+		w.Header().Set("X-Limit-Clamped", strconv.Itoa(h.maxLimit))
+		return h.maxLimit, true
+	}
+	return limit, false
+}
+
 func (h *MediaHandlers) getFilesInDirectory(year, month string, limit, offset int) ([]media.MediaFileInfo, error) {
 	return h.organizer.ScanFiles(year, month, limit, offset)
 }
+
+func (h *MediaHandlers) VerifyIntegrityHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req struct {
+		Folder string `json:"folder"`
+		Cursor string `json:"cursor"`
+		Limit  int    `json:"limit"`
+	}
+
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			slog.Error("Failed to decode verify integrity request", "error", err)
+			response.BadRequest(w, "Invalid request body")
+			return
+		}
+	}
+
+	if req.Limit < 0 {
+		response.BadRequest(w, "Limit must not be negative")
+		return
+	}
+
+	report, err := h.organizer.VerifyIntegrity(r.Context(), media.VerifyOptions{
+		Folder:         req.Folder,
+		Cursor:         req.Cursor,
+		Limit:          req.Limit,
+		BytesPerSecond: h.integrityBytesPerSecond,
+	})
+	if errors.Is(err, media.ErrInvalidPath) {
+		response.BadRequest(w, err.Error())
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to verify integrity", "error", err, "folder", req.Folder)
+		response.InternalError(w, "Failed to verify integrity")
+		return
+	}
+
+	if len(report.Mismatches) > 0 || len(report.Missing) > 0 {
+		slog.Warn("Integrity verification found problems",
+			"mismatches", len(report.Mismatches),
+			"missing", len(report.Missing),
+		)
+	}
+
+	response.Success(w, report)
+}
+
+// QuarantineHandler checks the library for corrupt JPEGs, moving those that
+// have failed enough checks to the quarantine folder.
+func (h *MediaHandlers) QuarantineHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	report, err := h.organizer.QuarantineCorrupt(r.Context())
+	if errors.Is(err, media.ErrQuarantineDisabled) {
+		response.Error(w, http.StatusConflict, "Quarantine is disabled; set QUARANTINE_AFTER to enable it")
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to check for corrupt files", "error", err)
+		response.InternalError(w, "Failed to check for corrupt files")
+		return
+	}
+
+	if len(report.Quarantined) > 0 {
+		slog.Warn("Corrupt files quarantined", "files", len(report.Quarantined))
+	}
+	response.Success(w, report)
+}
+
+func (h *MediaHandlers) CamerasHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if checkNotModified(w, r, libraryETag(h.etagEpoch, h.organizer.Version(), r)) {
+		return
+	}
+
+	stats, err := h.organizer.CameraStats(r.Context())
+	if err != nil {
+		slog.Error("Failed to collect camera statistics", "error", err)
+		response.InternalError(w, "Failed to collect camera statistics")
+		return
+	}
+
+	response.Success(w, stats)
+}
+
+// DeleteFileHandler moves a file to the trash, or removes it outright when
+// the trash is disabled or the request sets permanent=true.
+func (h *MediaHandlers) DeleteFileHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	relPath := r.URL.Query().Get("path")
+	permanent, _ := strconv.ParseBool(r.URL.Query().Get("permanent"))
+
+	if h.organizer.TrashEnabled() && !permanent {
+		trashed, err := h.organizer.TrashFile(relPath)
+		if errors.Is(err, media.ErrInvalidPath) {
+			response.BadRequest(w, err.Error())
+			return
+		}
+		if os.IsNotExist(err) {
+			response.NotFound(w, "File not found")
+			return
+		}
+		if err != nil {
+			slog.Error("Failed to move file to trash", "error", err, "path", relPath)
+			response.InternalError(w, "Failed to delete file")
+			return
+		}
+
+		slog.Info("File moved to trash", "path", relPath, "id", trashed.ID)
+		response.Success(w, trashed)
+		return
+	}
+
+	err := h.organizer.DeleteFile(relPath)
+	if errors.Is(err, media.ErrInvalidPath) {
+		response.BadRequest(w, err.Error())
+		return
+	}
+	if os.IsNotExist(err) {
+		response.NotFound(w, "File not found")
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to delete file", "error", err, "path", relPath)
+		response.InternalError(w, "Failed to delete file")
+		return
+	}
+
+	slog.Info("File deleted", "path", relPath)
+	response.NoContent(w)
+}
+
+// DeleteRangeHandler deletes every file dated between from and to, both
+// YYYY-MM-DD and inclusive, going through the trash like DeleteFileHandler.
+// Since one request can empty years of the library, it must set confirm.
+func (h *MediaHandlers) DeleteRangeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req struct {
+		From    string `json:"from"`
+		To      string `json:"to"`
+		Confirm bool   `json:"confirm"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	// Days start at midnight in the zone the library is filed in
+	from, err := time.ParseInLocation(time.DateOnly, req.From, h.organizer.Location())
+	if err != nil {
+		response.BadRequest(w, "from must be a YYYY-MM-DD date")
+		return
+	}
+	to, err := time.ParseInLocation(time.DateOnly, req.To, h.organizer.Location())
+	if err != nil {
+		response.BadRequest(w, "to must be a YYYY-MM-DD date")
+		return
+	}
+	if to.Before(from) {
+		response.BadRequest(w, "to must not be before from")
+		return
+	}
+	if !req.Confirm {
+		response.BadRequest(w, "Deleting a date range requires confirm=true")
+		return
+	}
+
+	deleted, err := h.organizer.DeleteRange(from, to.AddDate(0, 0, 1))
+	if err != nil {
+		slog.Error("Failed to delete date range", "error", err, "from", req.From, "to", req.To, "deleted", deleted)
+		response.InternalError(w, "Failed to delete files")
+		return
+	}
+
+	slog.Info("Date range deleted", "from", req.From, "to", req.To, "deleted", deleted, "trashed", h.organizer.TrashEnabled())
+	response.Success(w, map[string]any{
+		"deleted": deleted,
+		"trashed": h.organizer.TrashEnabled(),
+	})
+}
+
+// TrashHandler lists soft-deleted files that can still be restored.
+func (h *MediaHandlers) TrashHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	files, err := h.organizer.ListTrash()
+	if err != nil {
+		slog.Error("Failed to list trash", "error", err)
+		response.InternalError(w, "Failed to list trash")
+		return
+	}
+
+	response.Success(w, map[string]any{
+		"files": files,
+		"total": len(files),
+	})
+}
+
+// RestoreHandler moves a file from the trash back into the library.
+func (h *MediaHandlers) RestoreHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req struct {
+		ID string `json:"id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	relPath, err := h.organizer.RestoreFile(req.ID)
+	if errors.Is(err, media.ErrInvalidPath) {
+		response.BadRequest(w, err.Error())
+		return
+	}
+	if os.IsNotExist(err) {
+		response.NotFound(w, "File not found in trash")
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to restore file", "error", err, "id", req.ID)
+		response.InternalError(w, "Failed to restore file")
+		return
+	}
+
+	slog.Info("File restored from trash", "id", req.ID, "path", relPath)
+	response.Success(w, map[string]any{"path": relPath})
+}
+
+// RotateHandler turns an image in the library clockwise by 90, 180 or 270
+// degrees.
+func (h *MediaHandlers) RotateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req struct {
+		Path    string `json:"path"`
+		Degrees int    `json:"degrees"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	method, err := h.organizer.Rotate(req.Path, req.Degrees)
+	if errors.Is(err, media.ErrInvalidPath) || errors.Is(err, media.ErrInvalidRotation) || errors.Is(err, media.ErrRotateUnsupported) {
+		response.BadRequest(w, err.Error())
+		return
+	}
+	if os.IsNotExist(err) {
+		response.NotFound(w, "File not found")
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to rotate file", "error", err, "path", req.Path)
+		response.InternalError(w, "Failed to rotate file")
+		return
+	}
+
+	slog.Info("File rotated", "path", req.Path, "degrees", req.Degrees, "method", method)
+	response.Success(w, map[string]any{
+		"path":    req.Path,
+		"degrees": req.Degrees,
+		"method":  method,
+	})
+}
+
+// RefreshMetadataHandler re-dates files already in the library and re-files
+// those that belong in another month. It only reports what would move unless
+// the request sets dryRun to false.
+func (h *MediaHandlers) RefreshMetadataHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req struct {
+		DryRun *bool `json:"dryRun"`
+	}
+
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			slog.Error("Failed to decode refresh metadata request", "error", err)
+			response.BadRequest(w, "Invalid request body")
+			return
+		}
+	}
+
+	apply := req.DryRun != nil && !*req.DryRun
+	report, err := h.organizer.RefreshMetadata(r.Context(), media.RefreshOptions{Apply: apply})
+	if err != nil {
+		slog.Error("Failed to refresh metadata", "error", err)
+		response.InternalError(w, "Failed to refresh metadata")
+		return
+	}
+
+	if apply {
+		slog.Info("Metadata refresh completed", "scanned", report.Scanned, "changed", len(report.Changes))
+	}
+	response.Success(w, report)
+}
+
+// OrphansHandler lists media files stored outside the Year/Month layout,
+// which date-based browsing can't reach.
+func (h *MediaHandlers) OrphansHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	orphans, err := h.organizer.ListOrphans(r.Context())
+	if err != nil {
+		slog.Error("Failed to list orphans", "error", err)
+		response.InternalError(w, "Failed to list orphans")
+		return
+	}
+
+	response.Success(w, map[string]any{
+		"files": orphans,
+		"total": len(orphans),
+	})
+}
+
+// ReorganizeOrphansHandler files every orphan where its date belongs.
+func (h *MediaHandlers) ReorganizeOrphansHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	changes, err := h.organizer.ReorganizeOrphans(r.Context())
+	if err != nil {
+		slog.Error("Failed to reorganize orphans", "error", err)
+		response.InternalError(w, "Failed to reorganize orphans")
+		return
+	}
+
+	slog.Info("Orphans reorganized", "files", len(changes))
+	response.Success(w, map[string]any{
+		"changes": changes,
+		"total":   len(changes),
+	})
+}
+
+// TestFilenameHandler reports whether a date can be read from a proposed
+// filename, so clients can warn before uploading a file that would be dated
+// by its upload time instead.
+func (h *MediaHandlers) TestFilenameHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		response.BadRequest(w, "Name is required")
+		return
+	}
+
+	date, pattern := h.organizer.Extractor().MatchFilename(name)
+	response.Success(w, map[string]any{
+		"name":      name,
+		"dateFound": date != nil,
+		"dateTaken": date,
+		"pattern":   pattern,
+	})
+}
+
+// PatternsHandler lists the filename date patterns in the order they are
+// tried, custom ones first.
+func (h *MediaHandlers) PatternsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	patterns := h.organizer.Extractor().FilenamePatterns()
+	response.Success(w, map[string]any{
+		"patterns": patterns,
+		"total":    len(patterns),
+	})
+}
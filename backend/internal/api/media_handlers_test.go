@@ -0,0 +1,635 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Steven-harris/sortify/backend/internal/media"
+)
+
+// writeMediaFile creates a file under root at the given relative path.
+func writeMediaFile(t *testing.T, root, relPath, content string) string {
+	t.Helper()
+
+	fullPath := filepath.Join(root, relPath)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		t.Fatalf("Failed to create directory for %s: %v", relPath, err)
+	}
+	if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to create file %s: %v", relPath, err)
+	}
+
+	return fullPath
+}
+
+func TestBrowseAndListHandlersETag(t *testing.T) {
+	mediaDir := t.TempDir()
+	handler := NewMediaHandlers(mediaDir)
+	writeMediaFile(t, mediaDir, "2024/March/IMG_20240315_143022.jpg", "content1")
+
+	endpoints := []struct {
+		name    string
+		url     string
+		handler http.HandlerFunc
+	}{
+		{"browse", "/api/media/browse", handler.BrowseHandler},
+		{"list", "/api/media/files?q=IMG", handler.ListFilesHandler},
+	}
+
+	for _, endpoint := range endpoints {
+		t.Run(endpoint.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			endpoint.handler(rr, httptest.NewRequest("GET", endpoint.url, nil))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+			}
+
+			etag := rr.Header().Get("ETag")
+			if etag == "" {
+				t.Fatal("Expected ETag header")
+			}
+
+			// Unchanged library: the cached copy is still valid
+			req := httptest.NewRequest("GET", endpoint.url, nil)
+			req.Header.Set("If-None-Match", etag)
+			rr = httptest.NewRecorder()
+			endpoint.handler(rr, req)
+			if rr.Code != http.StatusNotModified {
+				t.Errorf("Expected status %d, got %d", http.StatusNotModified, rr.Code)
+			}
+			if rr.Body.Len() != 0 {
+				t.Error("Expected empty body for 304 response")
+			}
+
+			// Organizing a new file changes the library and invalidates the tag
+			source := writeMediaFile(t, t.TempDir(), "IMG_20240316_101500.jpg", "new content "+endpoint.name)
+			if _, err := handler.organizer.OrganizeFile(source, "IMG_20240316_101500.jpg"); err != nil {
+				t.Fatalf("OrganizeFile failed: %v", err)
+			}
+
+			req = httptest.NewRequest("GET", endpoint.url, nil)
+			req.Header.Set("If-None-Match", etag)
+			rr = httptest.NewRecorder()
+			endpoint.handler(rr, req)
+			if rr.Code != http.StatusOK {
+				t.Errorf("Expected status %d after change, got %d", http.StatusOK, rr.Code)
+			}
+			if rr.Header().Get("ETag") == etag {
+				t.Error("Expected ETag to change after library change")
+			}
+		})
+	}
+}
+
+func TestListFilesHandlerClampsLimit(t *testing.T) {
+	mediaDir := t.TempDir()
+	handler := NewMediaHandlers(mediaDir)
+	handler.maxLimit = 5
+
+	monthDir := filepath.Join(mediaDir, "2024", "March")
+	if err := os.MkdirAll(monthDir, 0755); err != nil {
+		t.Fatalf("Failed to create month directory: %v", err)
+	}
+	for i := 0; i < 8; i++ {
+		name := filepath.Join(monthDir, fmt.Sprintf("photo_%02d.jpg", i))
+		if err := os.WriteFile(name, nil, 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name          string
+		url           string
+		expectedLimit int
+		clamped       bool
+	}{
+		{"excessive limit", "/api/media/files?limit=1000000", 5, true},
+		{"limit within cap", "/api/media/files?limit=3", 3, false},
+		{"default limit above cap", "/api/media/files", 5, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.ListFilesHandler(rr, httptest.NewRequest("GET", test.url, nil))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+			}
+
+			var result struct {
+				Files        []json.RawMessage `json:"files"`
+				Limit        int               `json:"limit"`
+				LimitClamped bool              `json:"limitClamped"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+
+			if result.Limit != test.expectedLimit || len(result.Files) != test.expectedLimit {
+				t.Errorf("Expected limit and page of %d, got limit %d with %d files", test.expectedLimit, result.Limit, len(result.Files))
+			}
+			if result.LimitClamped != test.clamped {
+				t.Errorf("Expected limitClamped %v, got %v", test.clamped, result.LimitClamped)
+			}
+
+			header := rr.Header().Get("X-Limit-Clamped")
+			if test.clamped && header != "5" {
+				t.Errorf("Expected X-Limit-Clamped 5, got %q", header)
+			}
+			if !test.clamped && header != "" {
+				t.Errorf("Expected no X-Limit-Clamped header, got %q", header)
+			}
+		})
+	}
+}
+
+func TestListFilesHandlerAccurateTotal(t *testing.T) {
+	mediaDir := t.TempDir()
+	handler := NewMediaHandlers(mediaDir)
+	handler.scanLimit = 100
+
+	const fileCount = 10050
+	monthDir := filepath.Join(mediaDir, "2024", "March")
+	if err := os.MkdirAll(monthDir, 0755); err != nil {
+		t.Fatalf("Failed to create month directory: %v", err)
+	}
+	for i := 0; i < fileCount; i++ {
+		name := filepath.Join(monthDir, fmt.Sprintf("photo_%05d.jpg", i))
+		if err := os.WriteFile(name, nil, 0644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	handler.ListFilesHandler(rr, httptest.NewRequest("GET", "/api/media/files?limit=10", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var result struct {
+		Files     []json.RawMessage `json:"files"`
+		Total     int               `json:"total"`
+		Truncated bool              `json:"truncated"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if result.Total != fileCount {
+		t.Errorf("Expected total %d, got %d", fileCount, result.Total)
+	}
+	if !result.Truncated {
+		t.Error("Expected truncation to be reported")
+	}
+	if len(result.Files) != 10 {
+		t.Errorf("Expected 10 files in page, got %d", len(result.Files))
+	}
+}
+
+func TestListFilesHandlerStructuredQuery(t *testing.T) {
+	mediaDir := t.TempDir()
+	handler := NewMediaHandlers(mediaDir)
+	for _, name := range []string{"2024/March/beach_01.jpg", "2024/March/beach_02.mp4", "2024/March/city_03.jpg", "2023/July/beach_04.jpg"} {
+		writeMediaFile(t, mediaDir, name, "media "+name)
+	}
+
+	tests := []struct {
+		query    string
+		expected []string
+	}{
+		{"beach", []string{"beach_01.jpg", "beach_02.mp4", "beach_04.jpg"}},
+		{"type:photo beach path:2024", []string{"beach_01.jpg"}},
+		{`type:photo,video name:"beach_0"`, []string{"beach_01.jpg", "beach_02.mp4", "beach_04.jpg"}},
+		{"type:video city", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.ListFilesHandler(rr, httptest.NewRequest("GET", "/api/media/files?q="+url.QueryEscape(test.query), nil))
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+			}
+
+			var result struct {
+				Files []media.MediaFileInfo `json:"files"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			var names []string
+			for _, file := range result.Files {
+				names = append(names, file.FileName)
+			}
+			sort.Strings(names)
+			if strings.Join(names, ",") != strings.Join(test.expected, ",") {
+				t.Errorf("Expected %v, got %v", test.expected, names)
+			}
+		})
+	}
+}
+
+func TestMetadataHandlerIfModifiedSince(t *testing.T) {
+	mediaDir := t.TempDir()
+	handler := NewMediaHandlers(mediaDir)
+	filePath := writeMediaFile(t, mediaDir, "2024/March/IMG_20240315_143022.jpg", "content")
+
+	modTime := time.Date(2024, 3, 15, 14, 30, 22, 0, time.UTC)
+	if err := os.Chtimes(filePath, modTime, modTime); err != nil {
+		t.Fatalf("Failed to set modtime: %v", err)
+	}
+
+	body := fmt.Sprintf(`{"filePath":%q}`, filePath)
+	tests := []struct {
+		name            string
+		method          string
+		ifModifiedSince string
+		expectedStatus  int
+	}{
+		{"no header", "GET", "", http.StatusOK},
+		{"same time", "GET", modTime.Format(http.TimeFormat), http.StatusNotModified},
+		{"later", "GET", modTime.Add(time.Hour).Format(http.TimeFormat), http.StatusNotModified},
+		{"earlier", "GET", modTime.Add(-time.Second).Format(http.TimeFormat), http.StatusOK},
+		{"malformed", "GET", "yesterday", http.StatusOK},
+		{"POST is never not modified", "POST", modTime.Add(time.Hour).Format(http.TimeFormat), http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest(test.method, "/api/media/metadata?filePath="+url.QueryEscape(filePath), strings.NewReader(body))
+			if test.ifModifiedSince != "" {
+				req.Header.Set("If-Modified-Since", test.ifModifiedSince)
+			}
+			rr := httptest.NewRecorder()
+			handler.MetadataHandler(rr, req)
+
+			if rr.Code != test.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", test.expectedStatus, rr.Code)
+			}
+			if lastModified := rr.Header().Get("Last-Modified"); lastModified != modTime.Format(http.TimeFormat) {
+				t.Errorf("Expected Last-Modified %q, got %q", modTime.Format(http.TimeFormat), lastModified)
+			}
+			if test.expectedStatus == http.StatusNotModified && rr.Body.Len() != 0 {
+				t.Error("Expected empty body for 304 response")
+			}
+			if test.expectedStatus == http.StatusOK && !strings.Contains(rr.Body.String(), "IMG_20240315_143022.jpg") {
+				t.Errorf("Expected metadata in body, got %s", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestRefreshMetadataHandlerDryRunByDefault(t *testing.T) {
+	mediaDir := t.TempDir()
+	handler := NewMediaHandlers(mediaDir)
+	writeMediaFile(t, mediaDir, "2023/May/IMG_20240315_143022.jpg", "misfiled")
+	expected := filepath.Join(mediaDir, "2024", "March", "IMG_20240315_143022.jpg")
+
+	rr := httptest.NewRecorder()
+	handler.RefreshMetadataHandler(rr, httptest.NewRequest("POST", "/api/media/refresh-metadata", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	var report media.RefreshReport
+	if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if !report.DryRun || len(report.Changes) != 1 {
+		t.Fatalf("Expected a dry run with one change, got %+v", report)
+	}
+	if _, err := os.Stat(expected); !os.IsNotExist(err) {
+		t.Error("Expected dry run not to move the file")
+	}
+
+	rr = httptest.NewRecorder()
+	handler.RefreshMetadataHandler(rr, httptest.NewRequest("POST", "/api/media/refresh-metadata", strings.NewReader(`{"dryRun":false}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if _, err := os.Stat(expected); err != nil {
+		t.Errorf("Expected file to be re-filed: %v", err)
+	}
+}
+
+func TestTestFilenameHandler(t *testing.T) {
+	handler := NewMediaHandlers(t.TempDir())
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		dateFound      bool
+		expectedDate   time.Time
+	}{
+		{"recognized", "?name=IMG_20240315_143022.jpg", http.StatusOK, true, time.Date(2024, 3, 15, 14, 30, 22, 0, time.UTC)},
+		{"unrecognized", "?name=holiday.jpg", http.StatusOK, false, time.Time{}},
+		{"missing name", "", http.StatusBadRequest, false, time.Time{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.TestFilenameHandler(rr, httptest.NewRequest("GET", "/api/media/test-filename"+test.query, nil))
+			if rr.Code != test.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", test.expectedStatus, rr.Code)
+			}
+			if test.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var result struct {
+				DateFound bool       `json:"dateFound"`
+				DateTaken *time.Time `json:"dateTaken"`
+				Pattern   string     `json:"pattern"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+			if result.DateFound != test.dateFound {
+				t.Fatalf("Expected dateFound %v, got %v", test.dateFound, result.DateFound)
+			}
+			if !test.dateFound {
+				if result.DateTaken != nil || result.Pattern != "" {
+					t.Errorf("Expected no date or pattern, got %v %q", result.DateTaken, result.Pattern)
+				}
+				return
+			}
+			if result.DateTaken == nil || !result.DateTaken.Equal(test.expectedDate) {
+				t.Errorf("Expected date %v, got %v", test.expectedDate, result.DateTaken)
+			}
+			if !strings.HasPrefix(result.Pattern, "IMG_") {
+				t.Errorf("Expected the IMG_ pattern to match, got %q", result.Pattern)
+			}
+		})
+	}
+}
+
+func TestPatternsHandler(t *testing.T) {
+	custom := `Scan (\d{4})\.(\d{2})\.(\d{2})`
+	handler := newMediaHandlers(media.NewOrganizerWithOptions(t.TempDir(), media.OrganizerOptions{
+		FilenamePatterns: []string{custom},
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.PatternsHandler(rr, httptest.NewRequest("GET", "/api/media/patterns", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var result struct {
+		Patterns []media.FilenamePattern `json:"patterns"`
+		Total    int                     `json:"total"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if result.Total != len(result.Patterns) || len(result.Patterns) < 2 {
+		t.Fatalf("Expected the custom and built-in patterns, got %+v", result)
+	}
+
+	if first := result.Patterns[0]; first.Pattern != custom || first.Source != media.PatternSourceCustom {
+		t.Errorf("Expected the custom pattern first, got %+v", first)
+	}
+	builtin := 0
+	for _, pattern := range result.Patterns[1:] {
+		if pattern.Source != media.PatternSourceBuiltin || pattern.Example == "" {
+			t.Errorf("Expected a built-in pattern with an example, got %+v", pattern)
+		}
+		builtin++
+	}
+	if builtin != len(media.NewExtractor().FilenamePatterns()) {
+		t.Errorf("Expected every built-in pattern listed, got %d", builtin)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.PatternsHandler(rr, httptest.NewRequest("POST", "/api/media/patterns", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, rr.Code)
+	}
+}
+
+func TestRotateHandler(t *testing.T) {
+	mediaDir := t.TempDir()
+	handler := NewMediaHandlers(mediaDir)
+	writeMediaFile(t, mediaDir, "2024/March/notes.txt", "not an image")
+
+	img := image.NewRGBA(image.Rect(0, 0, 6, 3))
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, img); err != nil {
+		t.Fatalf("Failed to encode image: %v", err)
+	}
+	fullPath := writeMediaFile(t, mediaDir, "2024/March/wide.png", encoded.String())
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{"rotated", `{"path":"2024/March/wide.png","degrees":90}`, http.StatusOK},
+		{"bad angle", `{"path":"2024/March/wide.png","degrees":45}`, http.StatusBadRequest},
+		{"missing path", `{"degrees":90}`, http.StatusBadRequest},
+		{"not an image", `{"path":"2024/March/notes.txt","degrees":90}`, http.StatusBadRequest},
+		{"missing file", `{"path":"2024/March/gone.png","degrees":90}`, http.StatusNotFound},
+		{"malformed body", `{`, http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.RotateHandler(rr, httptest.NewRequest("POST", "/api/media/rotate", strings.NewReader(test.body)))
+			if rr.Code != test.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", test.expectedStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	file, err := os.Open(fullPath)
+	if err != nil {
+		t.Fatalf("Failed to open image: %v", err)
+	}
+	defer file.Close()
+	config, err := png.DecodeConfig(file)
+	if err != nil {
+		t.Fatalf("Failed to decode image: %v", err)
+	}
+	if config.Width != 3 || config.Height != 6 {
+		t.Errorf("Expected 3x6 after rotating, got %dx%d", config.Width, config.Height)
+	}
+}
+
+func TestDeleteListRestoreHandlers(t *testing.T) {
+	mediaDir := t.TempDir()
+	handler := newMediaHandlers(media.NewOrganizerWithOptions(mediaDir, media.OrganizerOptions{TrashRetention: time.Hour}))
+	writeMediaFile(t, mediaDir, "2024/March/IMG_20240315_143022.jpg", "photo")
+
+	rr := httptest.NewRecorder()
+	handler.DeleteFileHandler(rr, httptest.NewRequest("DELETE", "/api/media/file?path=2024/March/IMG_20240315_143022.jpg", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	var trashed media.TrashedFile
+	if err := json.Unmarshal(rr.Body.Bytes(), &trashed); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.TrashHandler(rr, httptest.NewRequest("GET", "/api/media/trash", nil))
+	var listing struct {
+		Files []media.TrashedFile `json:"files"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &listing); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(listing.Files) != 1 || listing.Files[0].ID != trashed.ID {
+		t.Fatalf("Expected the deleted file in the trash, got %+v", listing.Files)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.RestoreHandler(rr, httptest.NewRequest("POST", "/api/media/restore", strings.NewReader(fmt.Sprintf(`{"id":%q}`, trashed.ID))))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(mediaDir, "2024", "March", "IMG_20240315_143022.jpg")); err != nil {
+		t.Errorf("Expected the file back in place: %v", err)
+	}
+
+	// Hard delete is still available on request
+	rr = httptest.NewRecorder()
+	handler.DeleteFileHandler(rr, httptest.NewRequest("DELETE", "/api/media/file?path=2024/March/IMG_20240315_143022.jpg&permanent=true", nil))
+	if rr.Code != http.StatusNoContent {
+		t.Fatalf("Expected status %d, got %d", http.StatusNoContent, rr.Code)
+	}
+	if files, _ := handler.organizer.ListTrash(); len(files) != 0 {
+		t.Errorf("Expected a permanent delete to bypass the trash, got %+v", files)
+	}
+}
+
+func TestDeleteRangeHandler(t *testing.T) {
+	mediaDir := t.TempDir()
+	handler := NewMediaHandlers(mediaDir)
+	writeMediaFile(t, mediaDir, "2024/February/IMG_20240229_120000.jpg", "before")
+	writeMediaFile(t, mediaDir, "2024/March/IMG_20240301_080000.jpg", "first day")
+	writeMediaFile(t, mediaDir, "2024/March/IMG_20240331_235959.jpg", "last day")
+	writeMediaFile(t, mediaDir, "2024/April/IMG_20240401_000000.jpg", "after")
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{"Missing confirmation", `{"from":"2024-03-01","to":"2024-03-31"}`, http.StatusBadRequest},
+		{"Invalid date", `{"from":"March","to":"2024-03-31","confirm":true}`, http.StatusBadRequest},
+		{"Reversed range", `{"from":"2024-03-31","to":"2024-03-01","confirm":true}`, http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.DeleteRangeHandler(rr, httptest.NewRequest("POST", "/api/media/delete-range", strings.NewReader(test.body)))
+			if rr.Code != test.expectedStatus {
+				t.Errorf("Expected status %d, got %d", test.expectedStatus, rr.Code)
+			}
+		})
+	}
+	if files, _ := handler.organizer.ScanFiles("", "", 10, 0); len(files) != 4 {
+		t.Fatalf("Expected rejected requests to delete nothing, got %d files left", len(files))
+	}
+
+	rr := httptest.NewRecorder()
+	handler.DeleteRangeHandler(rr, httptest.NewRequest("POST", "/api/media/delete-range", strings.NewReader(`{"from":"2024-03-01","to":"2024-03-31","confirm":true}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var result struct {
+		Deleted int `json:"deleted"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if result.Deleted != 2 {
+		t.Errorf("Expected 2 files deleted, got %d", result.Deleted)
+	}
+
+	files, err := handler.organizer.ScanFiles("", "", 10, 0)
+	if err != nil {
+		t.Fatalf("ScanFiles failed: %v", err)
+	}
+	var remaining []string
+	for _, file := range files {
+		remaining = append(remaining, file.FileName)
+	}
+	sort.Strings(remaining)
+	if strings.Join(remaining, ",") != "IMG_20240229_120000.jpg,IMG_20240401_000000.jpg" {
+		t.Errorf("Expected only the out-of-range files to survive, got %v", remaining)
+	}
+	if _, err := os.Stat(filepath.Join(mediaDir, "2024", "March")); !os.IsNotExist(err) {
+		t.Errorf("Expected the emptied March folder to be pruned, got %v", err)
+	}
+}
+
+func TestDeleteRangeHandlerUsesLibraryZone(t *testing.T) {
+	mediaDir := t.TempDir()
+	zone := time.FixedZone("AEST", 10*60*60)
+	handler := newMediaHandlers(media.NewOrganizerWithOptions(mediaDir, media.OrganizerOptions{Location: zone}))
+	// Half past midnight on 1 March in the library's zone is still February in UTC
+	writeMediaFile(t, mediaDir, "2024/March/IMG_20240301_003000.jpg", "just after midnight")
+
+	rr := httptest.NewRecorder()
+	handler.DeleteRangeHandler(rr, httptest.NewRequest("POST", "/api/media/delete-range", strings.NewReader(`{"from":"2024-03-01","to":"2024-03-01","confirm":true}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), `"deleted":1`) {
+		t.Errorf("Expected the file deleted with its local day, got %s", rr.Body.String())
+	}
+}
+
+func TestOrphanHandlers(t *testing.T) {
+	mediaDir := t.TempDir()
+	handler := NewMediaHandlers(mediaDir)
+	writeMediaFile(t, mediaDir, "IMG_20240315_143022.jpg", "dropped at the root")
+
+	rr := httptest.NewRecorder()
+	handler.OrphansHandler(rr, httptest.NewRequest("GET", "/api/media/orphans", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var listed struct {
+		Files []media.Orphan `json:"files"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(listed.Files) != 1 || listed.Files[0].RelativePath != "IMG_20240315_143022.jpg" {
+		t.Fatalf("Expected the root file as the only orphan, got %+v", listed.Files)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ReorganizeOrphansHandler(rr, httptest.NewRequest("POST", "/api/media/reorganize-orphans", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var result struct {
+		Changes []media.RefreshChange `json:"changes"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+	if len(result.Changes) != 1 || result.Changes[0].To != "2024/March/IMG_20240315_143022.jpg" {
+		t.Errorf("Expected the orphan to be filed under 2024/March, got %+v", result.Changes)
+	}
+	if _, err := os.Stat(filepath.Join(mediaDir, "2024", "March", "IMG_20240315_143022.jpg")); err != nil {
+		t.Errorf("Expected the organized file on disk: %v", err)
+	}
+}
@@ -1,9 +1,12 @@
 package api
 
 import (
+	"crypto/subtle"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/Steven-harris/sortify/backend/pkg/response"
 )
@@ -28,7 +31,7 @@ func CORS(allowedOrigins string) func(http.Handler) http.Handler {
 			}
 
 			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Requested-With, X-Session-Id, X-Chunk-Number, X-Chunk-Checksum, X-Checksum-Algorithm")
 			w.Header().Set("Access-Control-Max-Age", "86400") // 24 hours
 
 			// Handle preflight requests
@@ -69,3 +72,62 @@ func Recovery(next http.Handler) http.Handler {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// busyRetryAfter is how long clients turned away by an inFlightLimiter are
+// told to wait.
+const busyRetryAfter = 5 * time.Second
+
+// inFlightLimiter caps how many disk-heavy requests (organizing, imports,
+// rescans, exports) run at once across all clients, so they can't starve
+// browsing. Requests over the cap are turned away with 503 rather than
+// queued, since a queued upload completion would only time out client-side.
+type inFlightLimiter struct {
+	slots chan struct{}
+}
+
+// newInFlightLimiter returns a limiter admitting limit concurrent requests,
+// or nil, which limits nothing, when limit isn't positive.
+func newInFlightLimiter(limit int) *inFlightLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &inFlightLimiter{slots: make(chan struct{}, limit)}
+}
+
+func (l *inFlightLimiter) Limit(next http.HandlerFunc) http.HandlerFunc {
+	if l == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case l.slots <- struct{}{}:
+			defer func() { <-l.slots }()
+			next(w, r)
+		default:
+			slog.Warn("Turning away heavy request, server busy", "path", r.URL.Path, "limit", cap(l.slots))
+			w.Header().Set("Retry-After", strconv.Itoa(int(busyRetryAfter.Seconds())))
+			response.Error(w, http.StatusServiceUnavailable, "Server is busy, try again later")
+		}
+	}
+}
+
+// requireAdmin guards admin endpoints behind a bearer token. With no token
+// configured they are disabled outright rather than left open.
+func requireAdmin(token string) func(http.HandlerFunc) http.HandlerFunc {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				response.Error(w, http.StatusForbidden, "Admin endpoints are disabled; set ADMIN_TOKEN to enable them")
+				return
+			}
+
+			given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+				slog.Warn("Rejected admin request", "path", r.URL.Path, "remote_addr", r.RemoteAddr)
+				response.Unauthorized(w, "Invalid or missing admin token")
+				return
+			}
+			next(w, r)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInFlightLimiter(t *testing.T) {
+	limiter := newInFlightLimiter(2)
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mux := http.NewServeMux()
+	mux.HandleFunc("/heavy", limiter.Limit(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+	mux.HandleFunc("/light", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// Fill both slots with requests that block until released
+	var wg sync.WaitGroup
+	codes := make([]int, 2)
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rr := httptest.NewRecorder()
+			mux.ServeHTTP(rr, httptest.NewRequest("POST", "/heavy", nil))
+			codes[i] = rr.Code
+		}()
+		<-started
+	}
+
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("POST", "/heavy", nil))
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d beyond the limit, got %d", http.StatusServiceUnavailable, rr.Code)
+	}
+	if rr.Header().Get("Retry-After") == "" {
+		t.Error("Expected a Retry-After header on the rejected request")
+	}
+
+	start := time.Now()
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("GET", "/light", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected light request to succeed, got %d", rr.Code)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Light request took %v while heavy requests were saturated", elapsed)
+	}
+
+	close(release)
+	wg.Wait()
+	for i, code := range codes {
+		if code != http.StatusOK {
+			t.Errorf("Expected admitted request %d to succeed, got %d", i, code)
+		}
+	}
+
+	// Finished requests give their slots back
+	go func() { <-started }()
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, httptest.NewRequest("POST", "/heavy", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("Expected a freed slot to admit the next request, got %d", rr.Code)
+	}
+}
+
+func TestInFlightLimiterDisabled(t *testing.T) {
+	handler := newInFlightLimiter(0).Limit(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	rr := httptest.NewRecorder()
+	handler(rr, httptest.NewRequest("POST", "/heavy", nil))
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Expected a disabled limiter to pass requests through, got %d", rr.Code)
+	}
+}
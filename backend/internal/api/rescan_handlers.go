@@ -0,0 +1,55 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/Steven-harris/sortify/backend/internal/media"
+	"github.com/Steven-harris/sortify/backend/pkg/response"
+)
+
+// RescanHandler re-extracts metadata for the whole library. Clients sending
+// Accept: text/event-stream get a "progress" event per file followed by a
+// "complete" or "error" event; everyone else gets the result as JSON.
+func (h *MediaHandlers) RescanHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	if !wantsEventStream(r) {
+		result, err := h.organizer.Rescan(r.Context(), media.RescanOptions{})
+		if err != nil {
+			slog.Error("Failed to rescan library", "error", err)
+			response.InternalError(w, "Failed to rescan library")
+			return
+		}
+		response.Success(w, result)
+		return
+	}
+
+	stream, err := newEventStream(w)
+	if err != nil {
+		slog.Error("Failed to start rescan stream", "error", err)
+		response.InternalError(w, "Streaming is not supported")
+		return
+	}
+
+	result, err := h.organizer.Rescan(r.Context(), media.RescanOptions{
+		Progress: func(progress media.RescanProgress) {
+			// A disconnected client surfaces as a cancelled context, which
+			// stops the rescan on the next file
+			if err := stream.Send("progress", progress); err != nil {
+				slog.Debug("Failed to send rescan progress", "error", err)
+			}
+		},
+	})
+	if err != nil {
+		slog.Error("Failed to rescan library", "error", err)
+		stream.Send("error", response.Problem{Error: "Failed to rescan library"})
+		return
+	}
+
+	slog.Info("Library rescan completed", "total", result.Total, "failed", len(result.Failed))
+	stream.Send("complete", result)
+}
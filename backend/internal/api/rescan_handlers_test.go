@@ -0,0 +1,117 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Steven-harris/sortify/backend/internal/media"
+)
+
+func TestRescanHandlerStreamsProgress(t *testing.T) {
+	mediaDir := t.TempDir()
+	handler := NewMediaHandlers(mediaDir)
+	const files = 5
+	for i := 0; i < files; i++ {
+		writeMediaFile(t, mediaDir, fmt.Sprintf("2024/March/IMG_2024031%d_143022.jpg", i), fmt.Sprintf("content %d", i))
+	}
+	writeMediaFile(t, mediaDir, "temp/upload.jpg", "in flight")
+
+	server := httptest.NewServer(http.HandlerFunc(handler.RescanHandler))
+	defer server.Close()
+
+	req, err := http.NewRequest("POST", server.URL, nil)
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Fatalf("Expected text/event-stream, got %q", contentType)
+	}
+
+	var (
+		event    string
+		progress []media.RescanProgress
+		result   *media.RescanResult
+	)
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data := []byte(strings.TrimPrefix(line, "data: "))
+			switch event {
+			case "progress":
+				var p media.RescanProgress
+				if err := json.Unmarshal(data, &p); err != nil {
+					t.Fatalf("Failed to decode progress event: %v", err)
+				}
+				progress = append(progress, p)
+			case "complete":
+				result = &media.RescanResult{}
+				if err := json.Unmarshal(data, result); err != nil {
+					t.Fatalf("Failed to decode complete event: %v", err)
+				}
+			default:
+				t.Fatalf("Unexpected %q event: %s", event, data)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("Failed to read stream: %v", err)
+	}
+
+	if len(progress) != files {
+		t.Fatalf("Expected %d progress events, got %d", files, len(progress))
+	}
+	for i, p := range progress {
+		if p.Processed != i+1 {
+			t.Errorf("Expected event %d to report %d processed, got %d", i, i+1, p.Processed)
+		}
+		if p.Total != files {
+			t.Errorf("Expected total %d, got %d", files, p.Total)
+		}
+		if !strings.HasPrefix(p.Path, "2024/March/") {
+			t.Errorf("Expected library path, got %q", p.Path)
+		}
+	}
+
+	if result == nil {
+		t.Fatal("Expected a complete event")
+	}
+	if result.Total != files {
+		t.Errorf("Expected result total %d, got %d", files, result.Total)
+	}
+}
+
+func TestRescanHandlerJSON(t *testing.T) {
+	mediaDir := t.TempDir()
+	handler := NewMediaHandlers(mediaDir)
+	writeMediaFile(t, mediaDir, "2024/March/IMG_20240315_143022.jpg", "content")
+
+	rr := httptest.NewRecorder()
+	handler.RescanHandler(rr, httptest.NewRequest("POST", "/api/media/rescan", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+
+	var result media.RescanResult
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if result.Total != 1 {
+		t.Errorf("Expected total 1, got %d", result.Total)
+	}
+}
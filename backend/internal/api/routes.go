@@ -11,28 +11,94 @@ func (s *Server) setupRoutes() http.Handler {
 	handler = Logging(handler)
 	handler = Recovery(handler)
 
+	// Organizing, imports, rescans and exports share a concurrency cap
+	heavy := s.heavyLimiter.Limit
+	admin := requireAdmin(s.config.AdminToken)
+
+	// Endpoints whose GET only reports status cap just the work they start
+	heavyPost := func(next http.HandlerFunc) http.HandlerFunc {
+		limited := heavy(next)
+		return func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet {
+				next(w, r)
+				return
+			}
+			limited(w, r)
+		}
+	}
+
 	// Register routes
-	mux.HandleFunc("/", s.RootHandler)
+	if s.config.FrontendDir != "" {
+		mux.Handle("/", newSPAHandler(s.config.FrontendDir))
+	} else {
+		mux.HandleFunc("/", s.RootHandler)
+	}
 	mux.HandleFunc("/api/health", s.HealthHandler)
+	mux.HandleFunc("/api/metrics", s.MetricsHandler)
+	if s.config.DebugEndpoints {
+		mux.HandleFunc("/api/debug/stats", admin(s.DebugStatsHandler))
+	}
 
 	// Upload routes
 	mux.HandleFunc("/api/upload/start", s.uploadHandler.StartUploadHandler)
 	mux.HandleFunc("/api/upload/chunk", s.uploadHandler.UploadChunkHandler)
-	mux.HandleFunc("/api/upload/complete", s.uploadHandler.CompleteUploadHandler)
+	mux.HandleFunc("/api/upload/config", s.uploadHandler.UploadConfigHandler)
+	mux.HandleFunc("/api/upload/validate-batch", s.uploadHandler.ValidateBatchHandler)
+	mux.HandleFunc("/api/upload/complete", heavy(s.uploadHandler.CompleteUploadHandler))
+	mux.HandleFunc("/api/upload/finalize", heavy(s.uploadHandler.FinalizeUploadHandler))
 	mux.HandleFunc("/api/upload/progress", s.uploadHandler.GetProgressHandler)
+	mux.HandleFunc("/api/upload/missing", s.uploadHandler.MissingChunksHandler)
 	mux.HandleFunc("/api/upload/pause", s.uploadHandler.PauseUploadHandler)
 	mux.HandleFunc("/api/upload/resume", s.uploadHandler.ResumeUploadHandler)
 	mux.HandleFunc("/api/upload/cancel", s.uploadHandler.CancelUploadHandler)
+	mux.HandleFunc("/api/upload/abort-all", admin(s.uploadHandler.AbortAllHandler))
+	mux.HandleFunc("/api/upload/simple", heavy(s.uploadHandler.SimpleUploadHandler))
 
 	// Media browsing routes
 	mux.HandleFunc("/api/media/browse", s.mediaHandler.BrowseHandler)
 	mux.HandleFunc("/api/media/files", s.mediaHandler.ListFilesHandler)
 	mux.HandleFunc("/api/media/metadata", s.mediaHandler.MetadataHandler)
-	mux.HandleFunc("/api/media/user-date", s.mediaHandler.UserDateHandler)
+	mux.HandleFunc("/api/media/user-date", s.uploadHandler.UserDateHandler)
+	mux.HandleFunc("/api/media/verify-integrity", heavy(s.mediaHandler.VerifyIntegrityHandler))
+	mux.HandleFunc("/api/media/quarantine", admin(heavy(s.mediaHandler.QuarantineHandler)))
+	mux.HandleFunc("/api/media/cameras", s.mediaHandler.CamerasHandler)
+	mux.HandleFunc("/api/media/exif", s.mediaHandler.EXIFHandler)
+	mux.HandleFunc("/api/media/capture-info", s.mediaHandler.CaptureInfoHandler)
+	mux.HandleFunc("/api/media/file", s.mediaHandler.DeleteFileHandler)
+	mux.HandleFunc("/api/media/delete-range", admin(heavy(s.mediaHandler.DeleteRangeHandler)))
+	mux.HandleFunc("/api/media/trash", s.mediaHandler.TrashHandler)
+	mux.HandleFunc("/api/media/restore", s.mediaHandler.RestoreHandler)
+	mux.HandleFunc("/api/media/share", s.mediaHandler.ShareHandler)
+	mux.HandleFunc("/api/media/rotate", heavy(s.mediaHandler.RotateHandler))
+	mux.HandleFunc("/api/media/import", heavy(s.mediaHandler.ImportHandler))
+	mux.HandleFunc("/api/media/import/plan", heavy(s.mediaHandler.ImportPlanHandler))
+	mux.HandleFunc("/api/media/import/apply", heavy(s.mediaHandler.ApplyImportPlanHandler))
+	mux.HandleFunc("/api/media/pregenerate-thumbnails", heavyPost(s.mediaHandler.PregenerateThumbnailsHandler))
+	mux.HandleFunc("/api/media/rescan", heavy(s.mediaHandler.RescanHandler))
+	mux.HandleFunc("/api/media/refresh-metadata", heavy(s.mediaHandler.RefreshMetadataHandler))
+	mux.HandleFunc("/api/media/orphans", s.mediaHandler.OrphansHandler)
+	mux.HandleFunc("/api/media/reorganize-orphans", heavy(s.mediaHandler.ReorganizeOrphansHandler))
+	mux.HandleFunc("/api/media/test-filename", s.mediaHandler.TestFilenameHandler)
+	mux.HandleFunc("/api/media/patterns", s.mediaHandler.PatternsHandler)
+	mux.HandleFunc("/api/media/download-zip", heavy(s.mediaHandler.DownloadZipHandler))
 
 	// Static file serving for media files
-	mediaFileServer := http.FileServer(http.Dir(s.config.MediaPath))
-	mux.Handle("/media/", http.StripPrefix("/media/", mediaFileServer))
+	var mediaFS http.FileSystem = http.Dir(s.config.MediaPath)
+	if !s.config.MediaDirectoryListing {
+		mediaFS = noListingFileSystem{fs: mediaFS}
+	}
+	mediaFileServer := http.FileServer(mediaFS)
+
+	// Thumbnails and conversions are rendered on demand and share the heavy
+	// cap; originals are served straight from disk
+	renditions := heavy(s.mediaHandler.convertingFileServer(mediaFileServer).ServeHTTP)
+	mux.Handle("/media/", http.StripPrefix("/media/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("format") == "" {
+			mediaFileServer.ServeHTTP(w, r)
+			return
+		}
+		renditions(w, r)
+	})))
 
 	// Catch-all for undefined routes
 	mux.HandleFunc("/api/", s.NotFoundHandler)
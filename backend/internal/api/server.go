@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -12,6 +13,9 @@ import (
 	"time"
 
 	"github.com/Steven-harris/sortify/backend/internal/config"
+	"github.com/Steven-harris/sortify/backend/internal/media"
+	"github.com/Steven-harris/sortify/backend/internal/upload"
+	"github.com/Steven-harris/sortify/backend/internal/webhook"
 )
 
 type Server struct {
@@ -19,16 +23,140 @@ type Server struct {
 	server        *http.Server
 	uploadHandler *UploadHandlers
 	mediaHandler  *MediaHandlers
+	heavyLimiter  *inFlightLimiter // Nil when MAX_HEAVY_REQUESTS is unset
+	sessionStore  upload.SessionStore
+	webhooks      *webhook.Notifier // Nil when WEBHOOK_URL is unset
+	storeErr      error             // Session store misconfiguration, reported by Initialize
+	timezoneErr   error             // Unknown DEFAULT_TIMEZONE, reported by Initialize
 }
 
 func NewServer(cfg *config.Config) *Server {
 	// Create temporary directory for uploads
 	tempDir := filepath.Join(cfg.MediaPath, "temp")
 
+	location, timezoneErr := time.LoadLocation(cfg.DefaultTimezone)
+	if timezoneErr != nil {
+		location = time.UTC
+	}
+
+	converter := media.NewConverter(filepath.Join(cfg.CachePath, "converted"))
+	thumbnailer := media.NewThumbnailerWithLimit(filepath.Join(cfg.CachePath, "thumbnails"), cfg.ThumbnailSize, converter, cfg.ThumbnailCacheBytes)
+
+	webhooks := webhook.New(webhook.Options{
+		URL:          cfg.WebhookURL,
+		Events:       cfg.WebhookEvents,
+		Retries:      cfg.WebhookRetries,
+		RetryBackoff: cfg.WebhookRetryBackoff,
+		Timeout:      cfg.WebhookTimeout,
+	})
+
+	// Both handler sets share one organizer so library changes made by uploads
+	// are visible to the browse handlers' cache validation.
+	organizer := media.NewOrganizerWithOptions(cfg.MediaPath, media.OrganizerOptions{
+		ChecksumIndexPath:    filepath.Join(cfg.DataPath, "checksums.json"),
+		ChecksumSaveDelay:    media.DefaultChecksumSaveDelay,
+		DedupMode:            media.DedupMode(cfg.DedupMode),
+		HashAlgorithm:        media.HashAlgorithm(cfg.HashAlgorithm),
+		PreferRicherMetadata: cfg.DedupPreferRicherMetadata,
+		FutureDates:          media.FutureDatePolicy(cfg.FutureDates),
+		MonthFormat:          media.MonthFormat(cfg.MonthFormat),
+		ExtensionStyle:       media.ExtensionStyle(cfg.ExtensionStyle),
+		FileIDs:              media.FileIDMode(cfg.FileIDMode),
+		MaxFileNameLength:    cfg.MaxFileNameLength,
+		FileNameUnit:         media.FileNameUnit(cfg.FileNameUnit),
+		QuarantineAfter:      cfg.QuarantineAfter,
+		TrashRetention:       cfg.TrashRetention,
+		IncludeHidden:        cfg.IncludeHiddenFiles,
+		SeparateScreenshots:  cfg.SeparateScreenshots,
+		DocumentExtensions:   cfg.DocumentExtensions,
+		FilenamePatterns:     cfg.FilenamePatterns,
+		FFprobePath:          cfg.FFprobePath,
+		KeepNullIslandGPS:    cfg.KeepNullIslandGPS,
+		PreHashBytes:         cfg.DedupPreHashKiB * 1024,
+		MetadataCacheEntries: cfg.MetadataCacheEntries,
+		Location:             location,
+		ArchivePath:          cfg.ArchivePath,
+		Thumbnailer:          thumbnailer,
+		OnOrganized:          organizedWebhook(webhooks),
+	})
+
+	uploadOptions := upload.DefaultOptions()
+	uploadOptions.Metadata = upload.MetadataLimits{
+		MaxKeys:        cfg.MetadataMaxKeys,
+		MaxKeyLength:   cfg.MetadataMaxKeyLength,
+		MaxValueLength: cfg.MetadataMaxValueLength,
+		AllowedKeys:    cfg.MetadataAllowedKeys,
+	}
+	uploadOptions.SkipFullChecksum = cfg.UploadSkipFullChecksum
+	uploadOptions.Preallocation = upload.Preallocation(cfg.UploadPreallocation)
+	uploadOptions.WriteRetries = cfg.UploadWriteRetries
+	uploadOptions.WriteRetryBackoff = cfg.UploadWriteRetryBackoff
+	uploadOptions.SessionTTL = cfg.UploadSessionTTL
+	uploadOptions.PausedSessionTTL = cfg.UploadPausedSessionTTL
+	uploadOptions.SweepInterval = cfg.UploadSessionSweepInterval
+
+	sessionStore, storeErr := newSessionStore(cfg)
+	uploadOptions.Store = sessionStore
+
+	uploadHandler := newUploadHandlers(upload.NewManagerWithOptions(tempDir, cfg.MaxUploadSessions, uploadOptions), organizer)
+	uploadHandler.organizeTimeout = cfg.OrganizeTimeout
+	uploadHandler.maxLibraryBytes = cfg.MaxLibraryBytes
+	uploadHandler.simpleUploadLimit = cfg.SimpleUploadMaxBytes
+	uploadHandler.defaultChunkSize = cfg.UploadChunkSize
+	uploadHandler.maxChunkSize = cfg.UploadMaxChunkSize
+	uploadHandler.maxFileSize = cfg.UploadMaxFileSize
+	uploadHandler.requireDate = cfg.RequireDate
+	uploadHandler.postOrganizeSteps = []postOrganizeStep{thumbnailStep(thumbnailer, cfg.MediaPath)}
+
+	uploadHandler.webhooks = webhooks
+
+	mediaHandler := newMediaHandlers(organizer)
+	mediaHandler.scanLimit = cfg.ListScanLimit
+	mediaHandler.maxLimit = cfg.MaxPageLimit
+	mediaHandler.converter = converter
+	mediaHandler.thumbnailer = thumbnailer
+	mediaHandler.thumbnailWorkers = cfg.ThumbnailWorkers
+	mediaHandler.integrityBytesPerSecond = cfg.IntegrityBytesPerSecond
+	mediaHandler.importPath = cfg.ImportPath
+	mediaHandler.eventGap = cfg.EventGap
+	mediaHandler.burstGap = cfg.BurstGap
+	mediaHandler.preserveDirs = cfg.ImportPreserveDirs
+
 	return &Server{
 		config:        cfg,
-		uploadHandler: NewUploadHandlers(tempDir, cfg.MediaPath),
-		mediaHandler:  NewMediaHandlers(cfg.MediaPath),
+		uploadHandler: uploadHandler,
+		mediaHandler:  mediaHandler,
+		heavyLimiter:  newInFlightLimiter(cfg.MaxHeavyRequests),
+		sessionStore:  sessionStore,
+		webhooks:      webhooks,
+		storeErr:      storeErr,
+		timezoneErr:   timezoneErr,
+	}
+}
+
+// newSessionStore builds the configured upload session store. The file store
+// lets a single instance resume uploads after a restart. Sessions must be
+// shared when several instances serve the same uploads, which also requires
+// MEDIA_PATH (and so the temp directory) to be on shared storage.
+func newSessionStore(cfg *config.Config) (upload.SessionStore, error) {
+	switch cfg.SessionStore {
+	case "", "memory":
+		return upload.NewMemorySessionStore(), nil
+	case "file":
+		// Beside the temp files it describes, so both survive a restart together
+		store, err := upload.NewFileSessionStore(filepath.Join(cfg.MediaPath, "temp", "sessions"))
+		if err != nil {
+			return upload.NewMemorySessionStore(), err
+		}
+		return store, nil
+	case "redis":
+		store, err := upload.NewRedisSessionStore(cfg.RedisURL)
+		if err != nil {
+			return upload.NewMemorySessionStore(), err
+		}
+		return store, nil
+	default:
+		return upload.NewMemorySessionStore(), fmt.Errorf("unknown session store %q", cfg.SessionStore)
 	}
 }
 
@@ -46,6 +174,22 @@ func (s *Server) Start() error {
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 
+	sweepCtx, stopSweeper := context.WithCancel(context.Background())
+	defer stopSweeper()
+	go s.mediaHandler.organizer.RunTrashSweeper(sweepCtx, media.DefaultTrashSweepInterval)
+	maintainer := s.mediaHandler.organizer.StartMaintenance(media.MaintenanceOptions{
+		Interval:       s.config.MaintenanceInterval,
+		MaxFiles:       s.config.MaintenanceMaxFiles,
+		BytesPerSecond: s.config.IntegrityBytesPerSecond,
+	})
+	defer maintainer.Stop()
+	defer s.uploadHandler.manager.Stop()
+	go func() {
+		if err := s.mediaHandler.organizer.RebuildChecksumIndex(sweepCtx, s.config.IntegrityBytesPerSecond); err != nil {
+			slog.Error("Failed to rebuild checksum index", "error", err)
+		}
+	}()
+
 	go func() {
 		slog.Info("Starting server", "port", s.config.Port, "addr", s.server.Addr)
 		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -65,6 +209,14 @@ func (s *Server) Start() error {
 		return err
 	}
 
+	if err := s.mediaHandler.organizer.FlushChecksums(); err != nil {
+		slog.Error("Failed to save checksum index", "error", err)
+	}
+
+	if err := s.webhooks.Shutdown(ctx); err != nil {
+		slog.Warn("Gave up delivering queued webhooks", "error", err)
+	}
+
 	slog.Info("Server stopped gracefully")
 	return nil
 }
@@ -92,6 +244,28 @@ func (s *Server) Initialize() error {
 		return fmt.Errorf("failed to ensure directories: %w", err)
 	}
 
+	if s.timezoneErr != nil {
+		return fmt.Errorf("invalid default timezone: %w", s.timezoneErr)
+	}
+
+	if s.config.WebhookURL != "" {
+		if u, err := url.Parse(s.config.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid WEBHOOK_URL %q: must be an http or https URL", s.config.WebhookURL)
+		}
+	}
+	if err := webhook.ValidateEvents(s.config.WebhookEvents); err != nil {
+		return fmt.Errorf("invalid WEBHOOK_EVENTS: %w", err)
+	}
+
+	if s.storeErr != nil {
+		return fmt.Errorf("failed to configure session store: %w", s.storeErr)
+	}
+	if redisStore, ok := s.sessionStore.(*upload.RedisSessionStore); ok {
+		if err := redisStore.Ping(); err != nil {
+			return fmt.Errorf("failed to reach session store: %w", err)
+		}
+	}
+
 	slog.Info("Server initialization completed")
 	return nil
 }
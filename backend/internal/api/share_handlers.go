@@ -0,0 +1,95 @@
+package api
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/Steven-harris/sortify/backend/pkg/response"
+)
+
+var sharePage = template.Must(template.New("share").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<meta property="og:title" content="{{.Title}}">
+<meta property="og:type" content="website">
+<meta property="og:url" content="{{.URL}}">
+<meta property="og:image" content="{{.ThumbnailURL}}">
+{{- if .Width}}
+<meta property="og:image:width" content="{{.Width}}">
+<meta property="og:image:height" content="{{.Height}}">
+{{- end}}
+{{- if .DateTaken}}
+<meta property="og:description" content="Taken {{.DateTaken.Format "January 2, 2006"}}">
+{{- end}}
+<meta name="twitter:card" content="summary_large_image">
+</head>
+<body>
+<img src="{{.URL}}" alt="{{.Title}}">
+</body>
+</html>
+`))
+
+// ShareHandler returns link-preview data for the file with ?id=, as JSON or,
+// with ?format=html, as a page carrying Open Graph tags for crawlers. URLs
+// are absolute, since previews are fetched from elsewhere.
+func (h *MediaHandlers) ShareHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		response.BadRequest(w, "File ID is required")
+		return
+	}
+	format := r.URL.Query().Get("format")
+	if format != "" && format != "json" && format != "html" {
+		response.BadRequest(w, "Unsupported format, expected json or html")
+		return
+	}
+
+	share, err := h.organizer.ShareInfo(id)
+	if os.IsNotExist(err) {
+		response.NotFound(w, "File not found")
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to build share info", "error", err, "id", id)
+		response.InternalError(w, "Failed to build share info")
+		return
+	}
+	share.URL = absoluteURL(r, share.URL)
+	share.ThumbnailURL = absoluteURL(r, share.ThumbnailURL)
+
+	if format != "html" {
+		response.Success(w, share)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := sharePage.Execute(w, share); err != nil {
+		slog.Error("Failed to render share page", "error", err, "id", id)
+	}
+}
+
+// absoluteURL resolves a server-relative URL against the host the request
+// was made to.
+func absoluteURL(r *http.Request, relative string) string {
+	ref, err := url.Parse(relative)
+	if err != nil {
+		return relative
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	base := &url.URL{Scheme: scheme, Host: r.Host}
+	return base.ResolveReference(ref).String()
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Steven-harris/sortify/backend/internal/media"
+)
+
+func TestShareHandler(t *testing.T) {
+	mediaDir := t.TempDir()
+	handler := NewMediaHandlers(mediaDir)
+
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, image.NewRGBA(image.Rect(0, 0, 8, 5))); err != nil {
+		t.Fatalf("Failed to encode image: %v", err)
+	}
+	writeMediaFile(t, mediaDir, "2024/March/IMG_20240315_143022.png", encoded.String())
+
+	file, err := handler.organizer.FileInfo("2024/March/IMG_20240315_143022.png")
+	if err != nil {
+		t.Fatalf("FileInfo failed: %v", err)
+	}
+
+	share := func(query string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/media/share"+query, nil)
+		req.Host = "photos.example.com"
+		rr := httptest.NewRecorder()
+		handler.ShareHandler(rr, req)
+		return rr
+	}
+
+	rr := share("?id=" + file.ID)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var payload media.ShareInfo
+	if err := json.Unmarshal(rr.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if payload.URL != "http://photos.example.com/media/2024/March/IMG_20240315_143022.png" {
+		t.Errorf("Expected an absolute image URL, got %s", payload.URL)
+	}
+	if payload.ThumbnailURL != payload.URL {
+		t.Errorf("Expected the thumbnail to fall back to the image without a thumbnailer, got %s", payload.ThumbnailURL)
+	}
+	if payload.Width != 8 || payload.Height != 5 {
+		t.Errorf("Expected 8x5, got %dx%d", payload.Width, payload.Height)
+	}
+	if payload.Title != "IMG_20240315_143022" {
+		t.Errorf("Expected the file name as title, got %q", payload.Title)
+	}
+	if payload.DateTaken == nil || payload.DateTaken.Format("2006-01-02") != "2024-03-15" {
+		t.Errorf("Expected date taken 2024-03-15, got %v", payload.DateTaken)
+	}
+
+	rr = share("?format=html&id=" + file.ID)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	page := rr.Body.String()
+	for _, tag := range []string{
+		`<meta property="og:image" content="http://photos.example.com/media/2024/March/IMG_20240315_143022.png">`,
+		`<meta property="og:image:width" content="8">`,
+		`<meta property="og:title" content="IMG_20240315_143022">`,
+		`<meta property="og:description" content="Taken March 15, 2024">`,
+	} {
+		if !strings.Contains(page, tag) {
+			t.Errorf("Expected %s in the share page:\n%s", tag, page)
+		}
+	}
+
+	if rr := share("?id=0000000000000000"); rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown ID, got %d", http.StatusNotFound, rr.Code)
+	}
+	if rr := share(""); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d without an ID, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
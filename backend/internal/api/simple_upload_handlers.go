@@ -0,0 +1,167 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/Steven-harris/sortify/backend/internal/media"
+	"github.com/Steven-harris/sortify/backend/internal/models"
+	"github.com/Steven-harris/sortify/backend/internal/upload"
+	"github.com/Steven-harris/sortify/backend/pkg/response"
+)
+
+// multipartOverhead allows for the boundaries and part headers around the
+// file when bounding the whole request body.
+const multipartOverhead = 64 << 10
+
+// SimpleUploadHandler accepts a small file as the "file" part of a multipart
+// POST and organizes it immediately, skipping the start/chunk/complete round
+// trips that dominate for thumbnails and small photos. The file goes through
+// a one-chunk session, so it is checked and organized exactly like a chunked
+// upload and gets the same response; the session can't be resumed and is
+// dropped with the request. Larger files must use the chunked upload.
+func (h *UploadHandlers) SimpleUploadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	tooLarge := fmt.Sprintf("File exceeds the %d byte limit for single-request uploads; use the chunked upload", h.simpleUploadLimit)
+	if r.ContentLength > h.simpleUploadLimit+multipartOverhead {
+		response.Error(w, http.StatusRequestEntityTooLarge, tooLarge)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, h.simpleUploadLimit+multipartOverhead)
+
+	reader, err := r.MultipartReader()
+	if err != nil {
+		response.BadRequest(w, "Expected a multipart form")
+		return
+	}
+
+	// The file is staged until its size is known and a session can hold it
+	stagingDir, err := os.MkdirTemp(h.manager.TempDir(), "simple-")
+	if err != nil {
+		slog.Error("Failed to create staging directory", "error", err)
+		response.InternalError(w, "Failed to store upload")
+		return
+	}
+	defer os.RemoveAll(stagingDir)
+
+	var (
+		fileName      string
+		stagedPath    string
+		size          int64
+		mediaTypeHint string
+	)
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			response.Error(w, http.StatusRequestEntityTooLarge, tooLarge)
+			return
+		}
+		if err != nil {
+			response.BadRequest(w, "Failed to parse form data")
+			return
+		}
+
+		switch part.FormName() {
+		case "mediaTypeHint":
+			value, _ := io.ReadAll(io.LimitReader(part, 64))
+			if _, err := media.ParseMediaType(string(value), h.organizer.DocumentsEnabled()); err != nil {
+				response.BadRequest(w, fmt.Sprintf("Invalid media type hint: %v", err))
+				return
+			}
+			mediaTypeHint = string(value)
+		case "file":
+			fileName = filepath.Base(part.FileName())
+			if fileName == "." || fileName == string(filepath.Separator) {
+				response.BadRequest(w, "File name is required")
+				return
+			}
+			stagedPath = filepath.Join(stagingDir, fileName)
+			if size, err = stageSimpleUpload(part, stagedPath, h.simpleUploadLimit); err != nil {
+				if errors.Is(err, errSimpleUploadTooLarge) || errors.As(err, &maxBytesErr) {
+					response.Error(w, http.StatusRequestEntityTooLarge, tooLarge)
+					return
+				}
+				slog.Error("Failed to store upload", "error", err, "filename", fileName)
+				response.InternalError(w, "Failed to store upload")
+				return
+			}
+		}
+		part.Close()
+	}
+
+	if stagedPath == "" {
+		response.BadRequest(w, "File is required")
+		return
+	}
+
+	errs := response.ValidationErrors{}
+	h.validateFile(errs, fileName, size)
+	if errs.HasErrors() {
+		response.ValidationFailed(w, errs)
+		return
+	}
+
+	session, ok := h.createSession(w, &models.StartUploadRequest{
+		FileName:      fileName,
+		FileSize:      size,
+		ChunkSize:     size,
+		MediaTypeHint: mediaTypeHint,
+	})
+	if !ok {
+		return
+	}
+	// Gone already once organized; otherwise nobody can complete it later
+	defer h.manager.CleanupSession(session.ID)
+
+	staged, err := os.Open(stagedPath)
+	if err != nil {
+		slog.Error("Failed to open staged upload", "error", err, "filename", fileName)
+		response.InternalError(w, "Failed to store upload")
+		return
+	}
+	err = h.manager.UploadChunkFrom(session.ID, 0, staged, upload.Checksum{})
+	staged.Close()
+	if err != nil {
+		slog.Error("Failed to store upload", "error", err, "sessionId", session.ID, "filename", fileName)
+		response.InternalError(w, "Failed to store upload")
+		return
+	}
+
+	h.completeAndOrganize(w, r, models.CompleteUploadRequest{SessionID: session.ID}, "")
+}
+
+var errSimpleUploadTooLarge = errors.New("upload exceeds the single-request limit")
+
+// stageSimpleUpload copies at most limit bytes of r to path, failing with
+// errSimpleUploadTooLarge if there is more.
+func stageSimpleUpload(r io.Reader, path string, limit int64) (int64, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+
+	written, err := io.Copy(file, io.LimitReader(r, limit+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, err
+	}
+	if written > limit {
+		return 0, errSimpleUploadTooLarge
+	}
+	return written, nil
+}
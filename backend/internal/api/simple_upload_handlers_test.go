@@ -0,0 +1,145 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Steven-harris/sortify/backend/internal/media"
+)
+
+func newSimpleUploadRequest(t *testing.T, fileName string, content []byte) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", fileName)
+	if err != nil {
+		t.Fatalf("Failed to create form file: %v", err)
+	}
+	part.Write(content)
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/api/upload/simple", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestSimpleUploadHandler(t *testing.T) {
+	mediaDir := t.TempDir()
+	handler := NewUploadHandlers(filepath.Join(mediaDir, "temp"), mediaDir)
+
+	rr := httptest.NewRecorder()
+	handler.SimpleUploadHandler(rr, newSimpleUploadRequest(t, "IMG_20240315_143022.jpg", []byte("small photo")))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var result struct {
+		Organized bool            `json:"organized"`
+		MediaInfo media.MediaInfo `json:"mediaInfo"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	expected := filepath.Join("2024", "March", "IMG_20240315_143022.jpg")
+	if !result.Organized || result.MediaInfo.RelativePath != expected {
+		t.Errorf("Expected organized to %s, got %+v", expected, result)
+	}
+	if result.MediaInfo.DateTaken == nil || result.MediaInfo.DateTaken.Hour() != 14 {
+		t.Errorf("Expected date from the filename, got %v", result.MediaInfo.DateTaken)
+	}
+	if _, err := os.Stat(filepath.Join(mediaDir, expected)); err != nil {
+		t.Errorf("Expected file in library: %v", err)
+	}
+
+	// Staging leftovers would be swept into listings
+	assertNoLeftovers := func() {
+		t.Helper()
+		entries, _ := os.ReadDir(filepath.Join(mediaDir, "temp"))
+		if len(entries) != 0 {
+			t.Errorf("Expected staging directory cleaned up, found %d entries", len(entries))
+		}
+		if active, _ := handler.manager.ActiveSessions(); active != 0 {
+			t.Errorf("Expected no sessions left open, got %d", active)
+		}
+	}
+	assertNoLeftovers()
+
+	// Sending the same bytes again is a duplicate, answered like a chunked one
+	rr = httptest.NewRecorder()
+	handler.SimpleUploadHandler(rr, newSimpleUploadRequest(t, "IMG_20240315_143022.jpg", []byte("small photo")))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d for a duplicate, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if result.MediaInfo.RelativePath != expected {
+		t.Errorf("Expected the existing file %s, got %s", expected, result.MediaInfo.RelativePath)
+	}
+	entries, _ := os.ReadDir(filepath.Join(mediaDir, "2024", "March"))
+	if len(entries) != 1 {
+		t.Errorf("Expected the duplicate not filed again, found %d files", len(entries))
+	}
+	assertNoLeftovers()
+}
+
+func TestSimpleUploadHandlerCleansUpFailedSessions(t *testing.T) {
+	mediaDir := t.TempDir()
+	handler := NewUploadHandlers(filepath.Join(mediaDir, "temp"), mediaDir)
+	handler.requireDate = true
+
+	rr := httptest.NewRecorder()
+	handler.SimpleUploadHandler(rr, newSimpleUploadRequest(t, "undated.jpg", []byte("small photo")))
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusUnprocessableEntity, rr.Code, rr.Body.String())
+	}
+
+	entries, _ := os.ReadDir(filepath.Join(mediaDir, "temp"))
+	if len(entries) != 0 {
+		t.Errorf("Expected the session's upload removed, found %d entries", len(entries))
+	}
+}
+
+func TestSimpleUploadHandlerRejectsLargeFiles(t *testing.T) {
+	mediaDir := t.TempDir()
+	handler := NewUploadHandlers(filepath.Join(mediaDir, "temp"), mediaDir)
+	handler.simpleUploadLimit = 1024
+
+	rr := httptest.NewRecorder()
+	handler.SimpleUploadHandler(rr, newSimpleUploadRequest(t, "IMG_20240315_143022.jpg", bytes.Repeat([]byte("x"), 1025)))
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("Expected status %d, got %d", http.StatusRequestEntityTooLarge, rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "chunked upload") {
+		t.Errorf("Expected the error to point at the chunked upload, got %s", rr.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(mediaDir, "2024")); !os.IsNotExist(err) {
+		t.Error("Expected nothing organized for a rejected upload")
+	}
+}
+
+func TestSimpleUploadHandlerRequiresFile(t *testing.T) {
+	mediaDir := t.TempDir()
+	handler := NewUploadHandlers(filepath.Join(mediaDir, "temp"), mediaDir)
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	writer.WriteField("mediaTypeHint", "photo")
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/api/upload/simple", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr := httptest.NewRecorder()
+	handler.SimpleUploadHandler(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
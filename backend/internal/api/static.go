@@ -0,0 +1,76 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/Steven-harris/sortify/backend/pkg/response"
+)
+
+// noListingFileSystem hides directories from http.FileServer so it cannot render
+// listings that would let anyone enumerate the library, while individual files
+// are still served normally.
+type noListingFileSystem struct {
+	fs http.FileSystem
+}
+
+func (n noListingFileSystem) Open(name string) (http.File, error) {
+	file, err := n.fs.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	if stat.IsDir() {
+		file.Close()
+		return nil, os.ErrNotExist
+	}
+
+	return file, nil
+}
+
+// spaHandler serves a built single-page frontend. Paths that don't name a file
+// get index.html so the client-side router can take over; it is registered at
+// "/", so the more specific /api/ and /media/ routes still win.
+type spaHandler struct {
+	dir   string
+	files http.Handler
+}
+
+func newSPAHandler(dir string) spaHandler {
+	return spaHandler{
+		dir:   dir,
+		files: http.FileServer(noListingFileSystem{fs: http.Dir(dir)}),
+	}
+}
+
+func (h spaHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	name := path.Clean("/" + r.URL.Path)
+	if stat, err := os.Stat(filepath.Join(h.dir, filepath.FromSlash(name))); err == nil && !stat.IsDir() {
+		h.files.ServeHTTP(w, r)
+		return
+	}
+
+	// A missing script or stylesheet is a broken build, not a client route;
+	// answering with index.html would only hide it behind a parse error
+	if path.Ext(name) != "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	// index.html names the current asset bundles, so browsers must revalidate it
+	w.Header().Set("Cache-Control", "no-cache")
+	http.ServeFile(w, r, filepath.Join(h.dir, "index.html"))
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Steven-harris/sortify/backend/internal/config"
+)
+
+func TestMediaDirectoryListing(t *testing.T) {
+	mediaDir := t.TempDir()
+	writeMediaFile(t, mediaDir, "2024/March/IMG_20240315_143022.jpg", "content")
+
+	tests := []struct {
+		name           string
+		listing        bool
+		path           string
+		expectedStatus int
+	}{
+		{"Directory hidden", false, "/media/2024/", http.StatusNotFound},
+		{"Directory without slash hidden", false, "/media/2024", http.StatusNotFound},
+		{"File served", false, "/media/2024/March/IMG_20240315_143022.jpg", http.StatusOK},
+		{"Directory listed when enabled", true, "/media/2024/", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := NewServer(&config.Config{
+				MediaPath:             mediaDir,
+				CORSOrigins:           "*",
+				MediaDirectoryListing: test.listing,
+			})
+
+			rr := httptest.NewRecorder()
+			server.setupRoutes().ServeHTTP(rr, httptest.NewRequest("GET", test.path, nil))
+
+			if rr.Code != test.expectedStatus {
+				t.Errorf("Expected status %d, got %d", test.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestFrontendServing(t *testing.T) {
+	mediaDir := t.TempDir()
+	frontendDir := t.TempDir()
+	writeMediaFile(t, mediaDir, "2024/March/IMG_20240315_143022.jpg", "photo")
+	writeMediaFile(t, frontendDir, "index.html", "<html>app</html>")
+	writeMediaFile(t, frontendDir, "assets/app.js", "console.log('app')")
+
+	server := NewServer(&config.Config{
+		MediaPath:   mediaDir,
+		CORSOrigins: "*",
+		FrontendDir: frontendDir,
+	})
+	routes := server.setupRoutes()
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{"Root", "/", http.StatusOK, "<html>app</html>"},
+		{"Deep client route", "/albums/2024/March", http.StatusOK, "<html>app</html>"},
+		{"Asset", "/assets/app.js", http.StatusOK, "console.log('app')"},
+		{"Missing asset", "/assets/missing.js", http.StatusNotFound, ""},
+		{"Health", "/api/health", http.StatusOK, `"status":"healthy"`},
+		{"Unknown API route", "/api/nope", http.StatusNotFound, "Endpoint not found"},
+		{"Media", "/media/2024/March/IMG_20240315_143022.jpg", http.StatusOK, "photo"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			routes.ServeHTTP(rr, httptest.NewRequest("GET", test.path, nil))
+
+			if rr.Code != test.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", test.expectedStatus, rr.Code)
+			}
+			if !strings.Contains(rr.Body.String(), test.expectedBody) {
+				t.Errorf("Expected body containing %q, got %q", test.expectedBody, rr.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Steven-harris/sortify/backend/internal/media"
+	"github.com/Steven-harris/sortify/backend/pkg/response"
+)
+
+// thumbnailJob tracks the single background thumbnail pre-generation run.
+type thumbnailJob struct {
+	mutex      sync.Mutex
+	running    bool
+	progress   media.ThumbnailProgress
+	startedAt  *time.Time
+	finishedAt *time.Time
+	lastError  string
+}
+
+type thumbnailJobStatus struct {
+	Running    bool       `json:"running"`
+	StartedAt  *time.Time `json:"startedAt,omitempty"`
+	FinishedAt *time.Time `json:"finishedAt,omitempty"`
+	Error      string     `json:"error,omitempty"`
+	media.ThumbnailProgress
+}
+
+func (j *thumbnailJob) status() thumbnailJobStatus {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return thumbnailJobStatus{
+		Running:           j.running,
+		StartedAt:         j.startedAt,
+		FinishedAt:        j.finishedAt,
+		Error:             j.lastError,
+		ThumbnailProgress: j.progress,
+	}
+}
+
+// startThumbnailPregeneration launches a pre-generation run in the background
+// unless one is already in progress, and reports whether it started one.
+func (h *MediaHandlers) startThumbnailPregeneration() bool {
+	if h.thumbnailer == nil {
+		return false
+	}
+
+	job := &h.thumbnailJob
+	job.mutex.Lock()
+	if job.running {
+		job.mutex.Unlock()
+		return false
+	}
+	now := time.Now()
+	job.running = true
+	job.progress = media.ThumbnailProgress{}
+	job.startedAt, job.finishedAt, job.lastError = &now, nil, ""
+	job.mutex.Unlock()
+
+	go func() {
+		progress, err := h.organizer.PregenerateThumbnails(context.Background(), h.thumbnailer, media.PregenerateOptions{
+			Workers: h.thumbnailWorkers,
+			Progress: func(p media.ThumbnailProgress) {
+				job.mutex.Lock()
+				job.progress = p
+				job.mutex.Unlock()
+			},
+		})
+
+		finished := time.Now()
+		job.mutex.Lock()
+		job.running = false
+		job.progress = progress
+		job.finishedAt = &finished
+		if err != nil {
+			job.lastError = err.Error()
+		}
+		job.mutex.Unlock()
+
+		if err != nil {
+			slog.Error("Thumbnail pre-generation failed", "error", err)
+			return
+		}
+		slog.Info("Thumbnail pre-generation completed",
+			"total", progress.Total,
+			"generated", progress.Generated,
+			"skipped", progress.Skipped,
+			"failed", progress.Failed,
+			"duration", finished.Sub(now),
+		)
+	}()
+
+	return true
+}
+
+// PregenerateThumbnailsHandler starts thumbnail pre-generation on POST and
+// reports the progress of the current or last run on GET.
+func (h *MediaHandlers) PregenerateThumbnailsHandler(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		response.Success(w, h.thumbnailJob.status())
+	case http.MethodPost:
+		if h.thumbnailer == nil {
+			response.Error(w, http.StatusServiceUnavailable, "Thumbnails are not configured")
+			return
+		}
+		h.startThumbnailPregeneration()
+		// Already-running jobs are reported rather than rejected
+		response.JSON(w, http.StatusAccepted, h.thumbnailJob.status())
+	default:
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+	}
+}
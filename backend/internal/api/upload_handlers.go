@@ -1,31 +1,137 @@
 package api
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
+	"unicode"
 
 	"github.com/Steven-harris/sortify/backend/internal/media"
 	"github.com/Steven-harris/sortify/backend/internal/models"
 	"github.com/Steven-harris/sortify/backend/internal/upload"
+	"github.com/Steven-harris/sortify/backend/internal/webhook"
 	"github.com/Steven-harris/sortify/backend/pkg/response"
 )
 
+// mediaOrganizer is the part of media.Organizer the upload handlers depend on.
+type mediaOrganizer interface {
+	OrganizeFileWithOptions(ctx context.Context, tempFilePath, originalFileName string, opts media.OrganizeOptions) (*media.MediaInfo, error)
+	LibrarySize() (int64, error)
+	FileInfo(relPath string) (*media.MediaFileInfo, error)
+	MonthFolder(year int, month time.Month) string
+	FindByChecksums(checksums []string) map[string]string
+	DateRange() (time.Time, time.Time)
+	DocumentsEnabled() bool
+}
+
+// postOrganizeStep is a best-effort follow-up that runs once a file has been
+// safely organized. A failing step is reported as a warning, never as a failed
+// upload, because the file itself is already in the library.
+type postOrganizeStep struct {
+	name string
+	run  func(ctx context.Context, info *media.MediaInfo) error
+}
+
+// thumbnailStep pre-generates the thumbnail of each organized image so the
+// first browse of a fresh upload doesn't wait for it. Files the thumbnailer
+// can't handle, or HEICs without a converter installed, are skipped.
+func thumbnailStep(thumbnailer *media.Thumbnailer, mediaPath string) postOrganizeStep {
+	return postOrganizeStep{
+		name: "thumbnail generation",
+		run: func(ctx context.Context, info *media.MediaInfo) error {
+			fullPath := filepath.Join(mediaPath, filepath.FromSlash(info.RelativePath))
+			if info.RelativePath == "" || !thumbnailer.Supports(fullPath) {
+				return nil
+			}
+			_, err := thumbnailer.Thumbnail(ctx, fullPath)
+			if errors.Is(err, media.ErrThumbnailUnsupported) || errors.Is(err, media.ErrConversionUnavailable) {
+				return nil
+			}
+			return err
+		},
+	}
+}
+
+// uploadEvent is the data of the upload_failed and date_required webhooks.
+// SessionID is empty for simple uploads. file_organized is raised by the
+// organizer itself, see organizedWebhook.
+type uploadEvent struct {
+	SessionID string `json:"sessionId,omitempty"`
+	FileName  string `json:"fileName,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// organizedWebhook returns an OnOrganized hook raising file_organized for
+// every file the organizer files, whichever path it came in by.
+func organizedWebhook(webhooks *webhook.Notifier) func(media.OrganizedFile) {
+	return func(file media.OrganizedFile) {
+		webhooks.Send(webhook.EventFileOrganized, file)
+	}
+}
+
+const (
+	defaultSimpleUploadLimit = 8 << 20
+	defaultChunkSize         = 1 << 20
+	defaultMaxChunkSize      = 64 << 20
+	maxBatchFiles            = 10000
+)
+
 type UploadHandlers struct {
-	manager   *upload.Manager
-	organizer *media.Organizer
+	manager           *upload.Manager
+	organizer         mediaOrganizer
+	organizeTimeout   time.Duration // Zero disables the organize deadline
+	maxLibraryBytes   int64         // Zero disables the library quota
+	simpleUploadLimit int64         // Largest file accepted by SimpleUploadHandler
+	defaultChunkSize  int64         // Used when a session doesn't choose one
+	maxChunkSize      int64         // Advertised to clients by UploadConfigHandler
+	maxFileSize       int64         // Advertised to clients; zero means no limit
+	requireDate       bool          // Hold back uploads that can only be dated by upload time
+	postOrganizeSteps []postOrganizeStep
+	webhooks          *webhook.Notifier // Nil disables webhooks
 }
 
 func NewUploadHandlers(tempDir, mediaPath string) *UploadHandlers {
-	manager := upload.NewManager(tempDir, 10)
-	organizer := media.NewOrganizer(mediaPath)
+	return newUploadHandlers(upload.NewManager(tempDir, 10), media.NewOrganizer(mediaPath))
+}
+
+func newUploadHandlers(manager *upload.Manager, organizer mediaOrganizer) *UploadHandlers {
 	return &UploadHandlers{
-		manager:   manager,
-		organizer: organizer,
+		manager:           manager,
+		organizer:         organizer,
+		simpleUploadLimit: defaultSimpleUploadLimit,
+		defaultChunkSize:  defaultChunkSize,
+		maxChunkSize:      defaultMaxChunkSize,
+	}
+}
+
+// UploadConfigHandler reports the configured upload sizes and limits and the
+// extensions the library recognizes, so clients needn't hard-code them.
+// Sizes are in bytes; a maxFileSize of zero means no limit.
+func (h *UploadHandlers) UploadConfigHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
 	}
+
+	response.Success(w, map[string]any{
+		"defaultChunkSize":   h.defaultChunkSize,
+		"maxChunkSize":       h.maxChunkSize,
+		"maxFileSize":        h.maxFileSize,
+		"maxSessions":        h.manager.MaxSessions(),
+		"simpleUploadLimit":  h.simpleUploadLimit,
+		"imageExtensions":    media.SupportedImageExtensions(),
+		"videoExtensions":    media.SupportedVideoExtensions(),
+		"checksumAlgorithms": upload.ChecksumAlgorithms(),
+	})
 }
 
 func (h *UploadHandlers) StartUploadHandler(w http.ResponseWriter, r *http.Request) {
@@ -41,22 +147,30 @@ func (h *UploadHandlers) StartUploadHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	if req.FileName == "" {
-		response.BadRequest(w, "Filename is required")
-		return
+	errs := response.ValidationErrors{}
+	h.validateFile(errs, req.FileName, req.FileSize)
+	if req.MediaTypeHint != "" {
+		documents := h.organizer.DocumentsEnabled()
+		if _, err := media.ParseMediaType(req.MediaTypeHint, documents); err != nil {
+			choices := "must be one of photo, video, other"
+			if documents {
+				choices += ", document"
+			}
+			errs.Add("mediaTypeHint", choices)
+		}
 	}
-	if req.FileSize <= 0 {
-		response.BadRequest(w, "File size must be greater than 0")
+	h.validateDateTaken(errs, req.DateTaken)
+	if errs.HasErrors() {
+		response.ValidationFailed(w, errs)
 		return
 	}
+
 	if req.ChunkSize <= 0 {
-		req.ChunkSize = 1024 * 1024
+		req.ChunkSize = h.defaultChunkSize
 	}
 
-	session, err := h.manager.CreateSession(&req)
-	if err != nil {
-		slog.Error("Failed to create upload session", "error", err)
-		response.InternalError(w, "Failed to create upload session")
+	session, ok := h.createSession(w, &req)
+	if !ok {
 		return
 	}
 
@@ -74,7 +188,202 @@ func (h *UploadHandlers) StartUploadHandler(w http.ResponseWriter, r *http.Reque
 	response.Success(w, result)
 }
 
+// createSession opens a session for the upload if it fits the library quota,
+// writing the error response and returning false if it can't.
+func (h *UploadHandlers) createSession(w http.ResponseWriter, req *models.StartUploadRequest) (*models.UploadSession, bool) {
+	session, err := h.manager.CreateSessionWithinQuota(req, upload.Quota{Limit: h.maxLibraryBytes, Used: h.organizer.LibrarySize})
+	if errors.Is(err, upload.ErrQuotaExceeded) {
+		response.Error(w, http.StatusInsufficientStorage, "Upload would exceed the library storage quota")
+		return nil, false
+	}
+	if errors.Is(err, upload.ErrInvalidMetadata) || errors.Is(err, upload.ErrInvalidChunking) {
+		response.BadRequest(w, err.Error())
+		return nil, false
+	}
+	if errors.Is(err, upload.ErrTooManySessions) {
+		response.Error(w, http.StatusTooManyRequests, "Too many uploads in progress, try again later")
+		return nil, false
+	}
+	if errors.Is(err, upload.ErrInsufficientSpace) {
+		slog.Warn("Not enough disk space for upload", "error", err, "fileSize", req.FileSize)
+		response.Error(w, http.StatusInsufficientStorage, "Not enough disk space for this upload")
+		return nil, false
+	}
+	if err != nil {
+		slog.Error("Failed to create upload session", "error", err)
+		response.InternalError(w, "Failed to create upload session")
+		return nil, false
+	}
+	return session, true
+}
+
+// validateFile records what StartUploadHandler would reject about a file's
+// name and size.
+func (h *UploadHandlers) validateFile(errs response.ValidationErrors, fileName string, fileSize int64) {
+	if fileName == "" {
+		errs.Add("fileName", "required")
+	}
+	if fileSize <= 0 {
+		errs.Add("fileSize", "must be > 0")
+	}
+}
+
+// validateDateTaken records why a client-supplied capture date can't be used
+// to file an upload.
+func (h *UploadHandlers) validateDateTaken(errs response.ValidationErrors, dateTaken *time.Time) {
+	if dateTaken == nil {
+		return
+	}
+	earliest, latest := h.organizer.DateRange()
+	if dateTaken.Before(earliest) || dateTaken.After(latest) {
+		errs.Add("dateTaken", fmt.Sprintf("must be between %s and %s",
+			earliest.Format(time.DateOnly), latest.Format(time.DateOnly)))
+	}
+}
+
+// UserDateHandler stores a capture date the user supplied for an upload that
+// could not be dated, e.g. after completion was refused under REQUIRE_DATE.
+// Completing the session again organizes it with that date.
+func (h *UploadHandlers) UserDateHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req media.DateExtractionResponse
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("Failed to decode user date request", "error", err)
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	if req.SessionID == "" {
+		response.BadRequest(w, "Session ID is required")
+		return
+	}
+	errs := response.ValidationErrors{}
+	if req.DateTaken.IsZero() {
+		errs.Add("dateTaken", "is required")
+	} else {
+		h.validateDateTaken(errs, &req.DateTaken)
+	}
+	if errs.HasErrors() {
+		response.ValidationFailed(w, errs)
+		return
+	}
+
+	if err := h.manager.SetDateTaken(req.SessionID, req.DateTaken); errors.Is(err, upload.ErrSessionNotFound) {
+		response.NotFound(w, "Session not found")
+		return
+	} else if errors.Is(err, upload.ErrSessionClosed) {
+		response.Error(w, http.StatusConflict, err.Error())
+		return
+	} else if err != nil {
+		slog.Error("Failed to store user date", "error", err, "sessionId", req.SessionID)
+		response.InternalError(w, "Failed to store the date")
+		return
+	}
+
+	slog.Info("User provided date for upload",
+		"sessionId", req.SessionID,
+		"dateTaken", req.DateTaken,
+	)
+
+	response.NoContent(w)
+}
+
+// fitsQuota reports whether size more bytes fit under the library quota right
+// now, counting in-flight uploads. It only advises; createSession enforces
+// the quota when the space is actually reserved.
+func (h *UploadHandlers) fitsQuota(size int64) (bool, error) {
+	if h.maxLibraryBytes <= 0 {
+		return true, nil
+	}
+	librarySize, err := h.organizer.LibrarySize()
+	if err != nil {
+		return false, err
+	}
+	return librarySize+h.manager.ReservedBytes()+size <= h.maxLibraryBytes, nil
+}
+
+// ValidateBatchHandler checks a batch of files before any are uploaded:
+// whether each would be accepted and is a supported type, whether the library
+// already holds it, and whether the rest fit the quota and session limit.
+// Clients can then skip duplicates and warn about problems up front.
+func (h *UploadHandlers) ValidateBatchHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.ValidateBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("Failed to decode validate batch request", "error", err)
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+	if len(req.Files) == 0 {
+		response.BadRequest(w, "At least one file is required")
+		return
+	}
+	if len(req.Files) > maxBatchFiles {
+		response.BadRequest(w, fmt.Sprintf("At most %d files can be validated at once", maxBatchFiles))
+		return
+	}
+
+	// Looked up together so the batch costs one pass over the checksum index
+	var checksums []string
+	for _, file := range req.Files {
+		if file.Checksum != "" && upload.SHA256Checksum(file.Checksum).Validate() == nil {
+			checksums = append(checksums, file.Checksum)
+		}
+	}
+	existing := h.organizer.FindByChecksums(checksums)
+
+	result := models.ValidateBatchResponse{Files: make([]models.BatchFileResult, 0, len(req.Files))}
+	for _, file := range req.Files {
+		verdict := models.BatchFileResult{
+			FileName:  file.FileName,
+			Supported: media.IsSupportedFile(file.FileName),
+		}
+
+		errs := response.ValidationErrors{}
+		h.validateFile(errs, file.FileName, file.FileSize)
+		if file.Checksum != "" {
+			if err := upload.SHA256Checksum(file.Checksum).Validate(); err != nil {
+				errs.Add("checksum", err.Error())
+			} else {
+				verdict.ExistingPath, verdict.Duplicate = existing[file.Checksum]
+			}
+		}
+		if errs.HasErrors() {
+			verdict.Errors = errs
+		} else if !verdict.Duplicate {
+			result.UploadCount++
+			result.UploadBytes += file.FileSize
+		}
+
+		result.Files = append(result.Files, verdict)
+	}
+
+	fits, err := h.fitsQuota(result.UploadBytes)
+	if err != nil {
+		slog.Error("Failed to measure library size", "error", err)
+		response.InternalError(w, "Failed to check library quota")
+		return
+	}
+	result.FitsQuota = fits
+	result.AvailableSessions = h.manager.AvailableSessions()
+	result.FitsSessionLimit = result.UploadCount <= result.AvailableSessions
+
+	response.Success(w, result)
+}
+
 func (h *UploadHandlers) UploadChunkHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPut {
+		h.uploadRawChunk(w, r)
+		return
+	}
 	if r.Method != http.MethodPost {
 		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
@@ -86,28 +395,21 @@ func (h *UploadHandlers) UploadChunkHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	sessionID := r.FormValue("sessionId")
-	if sessionID == "" {
-		// Try fallback to snake_case for backward compatibility
-		sessionID = r.FormValue("sessionId")
-	}
-
-	chunkNumberStr := r.FormValue("chunkNumber")
-	if chunkNumberStr == "" {
-		// Try fallback to snake_case for backward compatibility
-		chunkNumberStr = r.FormValue("chunk_number")
+	sessionID := param(r.FormValue, "sessionId")
+	chunkNumberStr := param(r.FormValue, "chunkNumber")
+	checksum := upload.Checksum{
+		Algorithm: param(r.FormValue, "checksumAlgorithm"),
+		Value:     param(r.FormValue, "checksum"),
 	}
 
-	expectedChecksum := r.FormValue("checksum")
-
 	if sessionID == "" {
 		response.BadRequest(w, "Session ID is required")
 		return
 	}
 
-	chunkNumber, err := strconv.Atoi(chunkNumberStr)
+	placement, err := parseChunkPlacement(chunkNumberStr, param(r.FormValue, "offset"))
 	if err != nil {
-		response.BadRequest(w, "Invalid chunk number")
+		response.BadRequest(w, err.Error())
 		return
 	}
 
@@ -126,11 +428,24 @@ func (h *UploadHandlers) UploadChunkHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	if err := h.manager.UploadChunk(sessionID, chunkNumber, chunkData, expectedChecksum); err != nil {
+	if placement.byOffset {
+		err = h.manager.UploadChunkAt(sessionID, placement.offset, bytes.NewReader(chunkData), checksum)
+	} else {
+		err = h.manager.UploadChunkWithChecksum(sessionID, placement.chunkNumber, chunkData, checksum)
+	}
+	if err != nil {
+		if errors.Is(err, upload.ErrSessionClosed) {
+			response.Error(w, http.StatusConflict, err.Error())
+			return
+		}
+		if isChunkRequestError(err) {
+			response.BadRequest(w, err.Error())
+			return
+		}
 		slog.Error("Failed to upload chunk",
 			"error", err,
 			"sessionId", sessionID,
-			"chunk_number", chunkNumber,
+			"chunk", placement,
 		)
 		response.InternalError(w, fmt.Sprintf("Failed to upload chunk: %v", err))
 		return
@@ -145,7 +460,7 @@ func (h *UploadHandlers) UploadChunkHandler(w http.ResponseWriter, r *http.Reque
 
 	slog.Info("Chunk uploaded successfully",
 		"sessionId", sessionID,
-		"chunk_number", chunkNumber,
+		"chunk", placement,
 		"chunk_size", len(chunkData),
 		"progress", fmt.Sprintf("%.2f%%", progress.PercentComplete),
 	)
@@ -153,6 +468,75 @@ func (h *UploadHandlers) UploadChunkHandler(w http.ResponseWriter, r *http.Reque
 	response.Success(w, progress)
 }
 
+// uploadRawChunk handles PUT /api/upload/chunk, where the request body is the
+// chunk itself and the session, chunk number and checksum come from the query
+// string or X-Session-Id, X-Chunk-Number and X-Chunk-Checksum headers. An
+// offset (X-Chunk-Offset) may replace the chunk number. The body is streamed
+// to disk without multipart parsing or buffering.
+func (h *UploadHandlers) uploadRawChunk(w http.ResponseWriter, r *http.Request) {
+	queryOrHeader := func(name, header string) string {
+		if value := param(r.URL.Query().Get, name); value != "" {
+			return value
+		}
+		return r.Header.Get(header)
+	}
+
+	sessionID := queryOrHeader("sessionId", "X-Session-Id")
+	if sessionID == "" {
+		response.BadRequest(w, "Session ID is required")
+		return
+	}
+
+	placement, err := parseChunkPlacement(queryOrHeader("chunkNumber", "X-Chunk-Number"), queryOrHeader("offset", "X-Chunk-Offset"))
+	if err != nil {
+		response.BadRequest(w, err.Error())
+		return
+	}
+
+	checksum := upload.Checksum{
+		Algorithm: queryOrHeader("checksumAlgorithm", "X-Checksum-Algorithm"),
+		Value:     queryOrHeader("checksum", "X-Chunk-Checksum"),
+	}
+
+	if placement.byOffset {
+		err = h.manager.UploadChunkAt(sessionID, placement.offset, r.Body, checksum)
+	} else {
+		err = h.manager.UploadChunkFrom(sessionID, placement.chunkNumber, r.Body, checksum)
+	}
+	if err != nil {
+		if errors.Is(err, upload.ErrSessionClosed) {
+			response.Error(w, http.StatusConflict, err.Error())
+			return
+		}
+		if isChunkRequestError(err) {
+			response.BadRequest(w, err.Error())
+			return
+		}
+		slog.Error("Failed to upload chunk",
+			"error", err,
+			"sessionId", sessionID,
+			"chunk", placement,
+		)
+		response.InternalError(w, fmt.Sprintf("Failed to upload chunk: %v", err))
+		return
+	}
+
+	progress, err := h.manager.GetProgress(sessionID)
+	if err != nil {
+		slog.Error("Failed to get upload progress", "error", err)
+		response.InternalError(w, "Failed to get progress")
+		return
+	}
+
+	slog.Info("Chunk uploaded successfully",
+		"sessionId", sessionID,
+		"chunk", placement,
+		"progress", fmt.Sprintf("%.2f%%", progress.PercentComplete),
+	)
+
+	response.Success(w, progress)
+}
+
 func (h *UploadHandlers) CompleteUploadHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
@@ -166,17 +550,97 @@ func (h *UploadHandlers) CompleteUploadHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	h.completeAndOrganize(w, r, req, "")
+}
+
+// FinalizeUploadHandler completes an upload like CompleteUploadHandler but
+// files it in a folder the client chose, a Year/Month or an album, instead of
+// the one its date selects. Metadata is still extracted and duplicates in the
+// chosen folder are still detected.
+func (h *UploadHandlers) FinalizeUploadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.FinalizeUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		slog.Error("Failed to decode finalize upload request", "error", err)
+		response.BadRequest(w, "Invalid request body")
+		return
+	}
+
+	targetDir, err := h.finalizeTarget(req)
+	if err != nil {
+		response.BadRequest(w, err.Error())
+		return
+	}
+
+	h.completeAndOrganize(w, r, req.CompleteUploadRequest, targetDir)
+}
+
+// finalizeTarget validates the folder a finalize request asks for and
+// returns it library-relative; empty means the date-based folder.
+func (h *UploadHandlers) finalizeTarget(req models.FinalizeUploadRequest) (string, error) {
+	if req.Album != "" {
+		if req.Year != "" || req.Month != "" {
+			return "", errors.New("choose either an album or a year and month, not both")
+		}
+		return media.AlbumFolder(req.Album)
+	}
+
+	if req.Year == "" && req.Month == "" {
+		return "", nil
+	}
+	if req.Year == "" || req.Month == "" {
+		return "", errors.New("year and month must be given together")
+	}
+	year, err := strconv.Atoi(req.Year)
+	if err != nil || len(req.Year) != 4 || year < 1 {
+		return "", fmt.Errorf("invalid year %q", req.Year)
+	}
+	month, ok := media.ParseMonth(req.Month)
+	if !ok {
+		return "", fmt.Errorf("invalid month %q", req.Month)
+	}
+	return h.organizer.MonthFolder(year, month), nil
+}
+
+// completeAndOrganize verifies the session's upload and organizes it into
+// targetDir, or the date-based folder when targetDir is empty.
+func (h *UploadHandlers) completeAndOrganize(w http.ResponseWriter, r *http.Request, req models.CompleteUploadRequest, targetDir string) {
 	if req.SessionID == "" {
 		response.BadRequest(w, "Session ID is required")
 		return
 	}
 
-	if err := h.manager.CompleteUpload(req.SessionID, req.Checksum); err != nil {
+	var mediaTypeHint media.MediaType
+	if req.MediaTypeHint != "" {
+		hint, err := media.ParseMediaType(req.MediaTypeHint, h.organizer.DocumentsEnabled())
+		if err != nil {
+			response.BadRequest(w, fmt.Sprintf("Invalid media type hint: %v", err))
+			return
+		}
+		mediaTypeHint = hint
+	}
+	errs := response.ValidationErrors{}
+	h.validateDateTaken(errs, req.DateTaken)
+	if errs.HasErrors() {
+		response.ValidationFailed(w, errs)
+		return
+	}
+
+	complete := func() error { return h.manager.CompleteUpload(req.SessionID, req.Checksum) }
+	if req.TreeRoot != "" {
+		complete = func() error { return h.manager.CompleteUploadTree(req.SessionID, req.TreeRoot) }
+	}
+	if err := complete(); err != nil {
 		slog.Error("Failed to complete upload",
 			"error", err,
 			"sessionId", req.SessionID,
 		)
-		response.InternalError(w, fmt.Sprintf("Failed to complete upload: %v", err))
+		h.webhooks.Send(webhook.EventUploadFailed, uploadEvent{SessionID: req.SessionID, Error: err.Error()})
+		response.Error(w, completeErrorStatus(err), fmt.Sprintf("Failed to complete upload: %v", err))
 		return
 	}
 
@@ -200,22 +664,82 @@ func (h *UploadHandlers) CompleteUploadHandler(w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	mediaInfo, err := h.organizer.OrganizeFile(tempPath, session.FileName)
+	if mediaTypeHint == "" && session.MediaType != "" {
+		mediaTypeHint = media.MediaType(session.MediaType)
+	}
+	dateTaken := req.DateTaken
+	if dateTaken == nil {
+		dateTaken = session.DateTaken
+	}
+
+	ctx := r.Context()
+	if h.organizeTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.organizeTimeout)
+		defer cancel()
+	}
+
+	mediaInfo, err := h.organizer.OrganizeFileWithOptions(ctx, tempPath, session.FileName, media.OrganizeOptions{
+		MediaType:   mediaTypeHint,
+		TargetDir:   targetDir,
+		DateTaken:   dateTaken,
+		SessionID:   req.SessionID,
+		RequireDate: h.requireDate,
+	})
+	if errors.Is(err, context.DeadlineExceeded) {
+		slog.Warn("Organize timed out, keeping temp file for retry",
+			"sessionId", req.SessionID,
+			"filename", session.FileName,
+			"timeout", h.organizeTimeout,
+		)
+		response.Error(w, http.StatusGatewayTimeout, "Organizing the file took too long; the upload was kept and completion can be retried")
+		return
+	}
+	if errors.Is(err, media.ErrDateRequired) {
+		// Not the upload's fault: keep it for a retry once the user dates it
+		slog.Info("Upload needs a capture date", "sessionId", req.SessionID, "filename", session.FileName)
+		h.webhooks.Send(webhook.EventDateRequired, uploadEvent{SessionID: req.SessionID, FileName: session.FileName})
+		response.Error(w, http.StatusUnprocessableEntity,
+			"No capture date could be determined; send one to /api/media/user-date or as dateTaken, then complete the upload again")
+		return
+	}
 	if err != nil {
 		slog.Error("Failed to organize file",
 			"error", err,
 			"sessionId", req.SessionID,
 			"filename", session.FileName,
 		)
+		abandon := h.manager.FailSession
+		if session.Transactional {
+			abandon = h.manager.Rollback // All or nothing: drop the committed file too
+		}
+		if failErr := abandon(req.SessionID, fmt.Sprintf("failed to organize file: %v", err)); failErr != nil {
+			slog.Warn("Failed to mark session failed", "error", failErr, "sessionId", req.SessionID)
+		}
+		h.webhooks.Send(webhook.EventUploadFailed, uploadEvent{SessionID: req.SessionID, FileName: session.FileName, Error: err.Error()})
 		response.InternalError(w, fmt.Sprintf("Failed to organize file: %v", err))
 		return
 	}
 
+	warnings := []string{}
+
 	if err := h.manager.CleanupSession(req.SessionID); err != nil {
 		slog.Warn("Failed to cleanup session",
 			"error", err,
 			"sessionId", req.SessionID,
 		)
+		warnings = append(warnings, fmt.Sprintf("session cleanup failed: %v", err))
+	}
+
+	for _, step := range h.postOrganizeSteps {
+		if err := step.run(r.Context(), mediaInfo); err != nil {
+			slog.Warn("Post-organize step failed",
+				"error", err,
+				"step", step.name,
+				"sessionId", req.SessionID,
+			)
+			warnings = append(warnings, fmt.Sprintf("%s failed: %v", step.name, err))
+		}
 	}
 
 	slog.Info("Upload completed and organized successfully",
@@ -228,9 +752,10 @@ func (h *UploadHandlers) CompleteUploadHandler(w http.ResponseWriter, r *http.Re
 
 	result := map[string]any{
 		"sessionId": req.SessionID,
-		"filename":  mediaInfo.FileName,
+		"fileName":  mediaInfo.FileName,
 		"mediaInfo": mediaInfo,
 		"organized": true,
+		"warnings":  warnings,
 	}
 
 	response.Success(w, result)
@@ -242,11 +767,7 @@ func (h *UploadHandlers) GetProgressHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	sessionID := r.URL.Query().Get("sessionId")
-	if sessionID == "" {
-		// Try fallback to snake_case for backward compatibility
-		sessionID = r.URL.Query().Get("sessionId")
-	}
+	sessionID := param(r.URL.Query().Get, "sessionId")
 	if sessionID == "" {
 		response.BadRequest(w, "Session ID is required")
 		return
@@ -265,17 +786,49 @@ func (h *UploadHandlers) GetProgressHandler(w http.ResponseWriter, r *http.Reque
 	response.Success(w, progress)
 }
 
-func (h *UploadHandlers) PauseUploadHandler(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
+// MissingChunksHandler lists the chunk numbers a session has yet to receive,
+// so a resuming client can send exactly those.
+func (h *UploadHandlers) MissingChunksHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
 		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
 		return
 	}
 
-	sessionID := r.URL.Query().Get("sessionId")
+	sessionID := param(r.URL.Query().Get, "sessionId")
 	if sessionID == "" {
-		// Try fallback to snake_case for backward compatibility
-		sessionID = r.URL.Query().Get("sessionId")
+		slog.Warn("Missing chunks requested without a session ID")
+		response.BadRequest(w, "Session ID is required")
+		return
 	}
+
+	missing, err := h.manager.MissingChunks(sessionID)
+	if errors.Is(err, upload.ErrSessionNotFound) {
+		slog.Warn("Missing chunks requested for an unknown session", "sessionId", sessionID)
+		response.NotFound(w, "Session not found")
+		return
+	}
+	if err != nil {
+		slog.Error("Failed to list missing chunks",
+			"error", err,
+			"sessionId", sessionID,
+		)
+		response.InternalError(w, "Failed to list missing chunks")
+		return
+	}
+
+	response.Success(w, map[string]any{
+		"sessionId":     sessionID,
+		"missingChunks": missing,
+	})
+}
+
+func (h *UploadHandlers) PauseUploadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	sessionID := param(r.URL.Query().Get, "sessionId")
 	if sessionID == "" {
 		response.BadRequest(w, "Session ID is required")
 		return
@@ -300,11 +853,7 @@ func (h *UploadHandlers) ResumeUploadHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	sessionID := r.URL.Query().Get("sessionId")
-	if sessionID == "" {
-		// Try fallback to snake_case for backward compatibility
-		sessionID = r.URL.Query().Get("sessionId")
-	}
+	sessionID := param(r.URL.Query().Get, "sessionId")
 	if sessionID == "" {
 		response.BadRequest(w, "Session ID is required")
 		return
@@ -329,11 +878,7 @@ func (h *UploadHandlers) CancelUploadHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	sessionID := r.URL.Query().Get("sessionId")
-	if sessionID == "" {
-		// Try fallback to snake_case for backward compatibility
-		sessionID = r.URL.Query().Get("sessionId")
-	}
+	sessionID := param(r.URL.Query().Get, "sessionId")
 	if sessionID == "" {
 		response.BadRequest(w, "Session ID is required")
 		return
@@ -351,3 +896,110 @@ func (h *UploadHandlers) CancelUploadHandler(w http.ResponseWriter, r *http.Requ
 	slog.Info("Upload cancelled", "sessionId", sessionID)
 	response.NoContent(w)
 }
+
+// AbortAllHandler cancels every unfinished upload session and deletes their
+// temp files, for recovering from a wedged state or resetting between test runs.
+func (h *UploadHandlers) AbortAllHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	aborted, err := h.manager.AbortAll()
+	if err != nil {
+		slog.Error("Failed to abort uploads", "error", err, "aborted", aborted)
+		response.InternalError(w, fmt.Sprintf("Failed to abort uploads: %v", err))
+		return
+	}
+
+	slog.Warn("All uploads aborted", "aborted", aborted)
+	response.Success(w, map[string]int{"aborted": aborted})
+}
+
+// chunkPlacement says where a chunk goes: by number, or at an explicit byte
+// offset for clients resuming with a different chunk size.
+type chunkPlacement struct {
+	chunkNumber int
+	offset      int64
+	byOffset    bool
+}
+
+func (p chunkPlacement) String() string {
+	if p.byOffset {
+		return fmt.Sprintf("offset %d", p.offset)
+	}
+	return fmt.Sprintf("number %d", p.chunkNumber)
+}
+
+// parseChunkPlacement reads a chunk's placement from its chunk number and
+// offset parameters. An offset, when given, wins.
+func parseChunkPlacement(chunkNumber, offset string) (chunkPlacement, error) {
+	if offset != "" {
+		value, err := strconv.ParseInt(offset, 10, 64)
+		if err != nil || value < 0 {
+			return chunkPlacement{}, errors.New("Invalid chunk offset")
+		}
+		return chunkPlacement{offset: value, byOffset: true}, nil
+	}
+
+	value, err := strconv.Atoi(chunkNumber)
+	if err != nil {
+		return chunkPlacement{}, errors.New("Invalid chunk number")
+	}
+	return chunkPlacement{chunkNumber: value}, nil
+}
+
+// param reads a parameter through get, usually r.FormValue or
+// r.URL.Query().Get, by its camelCase name, falling back to the snake_case
+// spelling older clients send (session_id, chunk_number, checksum_algorithm).
+func param(get func(string) string, name string) string {
+	if value := get(name); value != "" {
+		return value
+	}
+
+	var snake strings.Builder
+	for _, c := range name {
+		if unicode.IsUpper(c) {
+			snake.WriteByte('_')
+			c = unicode.ToLower(c)
+		}
+		snake.WriteRune(c)
+	}
+	if legacy := snake.String(); legacy != name {
+		return get(legacy)
+	}
+	return ""
+}
+
+// completeErrorStatus maps a failed completion to a status that tells the
+// client whether to send the upload again differently (422), start over
+// (404), fix its request (400) or retry later (500).
+func completeErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, upload.ErrSizeMismatch),
+		errors.Is(err, upload.ErrIncompleteUpload),
+		errors.Is(err, upload.ErrChecksumMismatch):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, upload.ErrSessionNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, upload.ErrSessionClosed):
+		return http.StatusConflict
+	case isChecksumFormatError(err):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// isChecksumFormatError reports whether a chunk was rejected because the
+// client's checksum could never have matched, as opposed to a real mismatch.
+func isChecksumFormatError(err error) bool {
+	return errors.Is(err, upload.ErrUnsupportedChecksumAlgorithm) || errors.Is(err, upload.ErrMalformedChecksum)
+}
+
+// isChunkRequestError reports whether a chunk was rejected for something
+// only the client can fix: a bad checksum format, a chunk number outside the
+// session or bytes that don't fit.
+func isChunkRequestError(err error) bool {
+	return isChecksumFormatError(err) || errors.Is(err, upload.ErrChunkOutOfRange) || errors.Is(err, upload.ErrChunkOverrun)
+}
@@ -2,13 +2,28 @@ package api
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/Steven-harris/sortify/backend/internal/media"
 	"github.com/Steven-harris/sortify/backend/internal/models"
+	"github.com/Steven-harris/sortify/backend/internal/upload"
+	"github.com/Steven-harris/sortify/backend/internal/webhook"
 )
 
 func TestStartUploadHandler(t *testing.T) {
@@ -50,6 +65,16 @@ func TestStartUploadHandler(t *testing.T) {
 			},
 			expectedStatus: http.StatusOK, // Should default to 1MB chunks
 		},
+		{
+			name: "Oversized metadata",
+			request: &models.StartUploadRequest{
+				FileName:  "test.jpg",
+				FileSize:  1024,
+				ChunkSize: 256,
+				Metadata:  map[string]string{"notes": strings.Repeat("x", 4096)},
+			},
+			expectedStatus: http.StatusBadRequest,
+		},
 		{
 			name: "Empty filename",
 			request: &models.StartUploadRequest{
@@ -305,6 +330,65 @@ func TestGetProgressHandler(t *testing.T) {
 	}
 }
 
+func TestStartUploadHandlerReportsAllFieldErrors(t *testing.T) {
+	tempDir := t.TempDir()
+	mediaDir := t.TempDir()
+	handler := NewUploadHandlers(tempDir, mediaDir)
+
+	body, _ := json.Marshal(&models.StartUploadRequest{
+		FileName:      "",
+		FileSize:      0,
+		ChunkSize:     256,
+		MediaTypeHint: "hologram",
+	})
+	rr := httptest.NewRecorder()
+	handler.StartUploadHandler(rr, httptest.NewRequest("POST", "/api/upload/start", bytes.NewReader(body)))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
+	}
+
+	var problem struct {
+		Errors map[string]string `json:"errors"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &problem); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	expected := map[string]string{
+		"fileName":      "required",
+		"fileSize":      "must be > 0",
+		"mediaTypeHint": "must be one of photo, video, other",
+	}
+	for field, message := range expected {
+		if problem.Errors[field] != message {
+			t.Errorf("Expected %s error %q, got %q", field, message, problem.Errors[field])
+		}
+	}
+	if len(problem.Errors) != len(expected) {
+		t.Errorf("Expected %d field errors, got %v", len(expected), problem.Errors)
+	}
+}
+
+func TestStartUploadHandlerRejectsDisabledDocuments(t *testing.T) {
+	handler := NewUploadHandlers(t.TempDir(), t.TempDir())
+
+	body, _ := json.Marshal(&models.StartUploadRequest{
+		FileName:      "scan.pdf",
+		FileSize:      1024,
+		MediaTypeHint: "document",
+	})
+	rr := httptest.NewRecorder()
+	handler.StartUploadHandler(rr, httptest.NewRequest("POST", "/api/upload/start", bytes.NewReader(body)))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), "mediaTypeHint") {
+		t.Errorf("Expected a mediaTypeHint validation error, got %s", rr.Body.String())
+	}
+}
+
 func TestInvalidJSONRequest(t *testing.T) {
 	tempDir := t.TempDir()
 	mediaDir := t.TempDir()
@@ -320,3 +404,1035 @@ func TestInvalidJSONRequest(t *testing.T) {
 		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rr.Code)
 	}
 }
+
+// slowOrganizer blocks until the context is done, simulating a pathological organize.
+type slowOrganizer struct{}
+
+func (slowOrganizer) LibrarySize() (int64, error) {
+	return 0, nil
+}
+
+func (slowOrganizer) FileInfo(relPath string) (*media.MediaFileInfo, error) {
+	return nil, os.ErrNotExist
+}
+
+func (slowOrganizer) MonthFolder(year int, month time.Month) string {
+	return fmt.Sprintf("%04d/%s", year, month)
+}
+
+func (slowOrganizer) FindByChecksums(checksums []string) map[string]string {
+	return map[string]string{}
+}
+
+func (slowOrganizer) DateRange() (time.Time, time.Time) {
+	return time.Time{}, time.Now()
+}
+
+func (slowOrganizer) DocumentsEnabled() bool {
+	return false
+}
+
+func (slowOrganizer) OrganizeFileWithOptions(ctx context.Context, tempFilePath, originalFileName string, opts media.OrganizeOptions) (*media.MediaInfo, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestCompleteUploadHandlerOrganizeTimeout(t *testing.T) {
+	tempDir := t.TempDir()
+	mediaDir := t.TempDir()
+	handler := NewUploadHandlers(tempDir, mediaDir)
+	handler.organizer = slowOrganizer{}
+	handler.organizeTimeout = 20 * time.Millisecond
+
+	session, err := handler.manager.CreateSession(&models.StartUploadRequest{
+		FileName:  "test.jpg",
+		FileSize:  10,
+		ChunkSize: 10,
+	})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if err := handler.manager.UploadChunk(session.ID, 0, []byte("0123456789"), ""); err != nil {
+		t.Fatalf("UploadChunk failed: %v", err)
+	}
+
+	body, _ := json.Marshal(&models.CompleteUploadRequest{SessionID: session.ID})
+	req := httptest.NewRequest("POST", "/api/upload/complete", bytes.NewReader(body))
+	rr := httptest.NewRecorder()
+	handler.CompleteUploadHandler(rr, req)
+
+	if rr.Code != http.StatusGatewayTimeout {
+		t.Errorf("Expected status %d, got %d", http.StatusGatewayTimeout, rr.Code)
+	}
+
+	// The temp file and session must survive so the client can retry
+	if _, err := os.Stat(session.TempPath); err != nil {
+		t.Errorf("Temp file should be kept after timeout: %v", err)
+	}
+	if _, err := handler.manager.GetSession(session.ID); err != nil {
+		t.Errorf("Session should be kept after timeout: %v", err)
+	}
+}
+
+func TestCompleteUploadHandlerMediaTypeHint(t *testing.T) {
+	tempDir := t.TempDir()
+	mediaDir := t.TempDir()
+	handler := NewUploadHandlers(tempDir, mediaDir)
+
+	tests := []struct {
+		name           string
+		startHint      string
+		completeHint   string
+		expectedType   media.MediaType
+		expectedStatus int
+	}{
+		{"No hint sniffs extension", "", "", media.MediaTypeOther, http.StatusOK},
+		{"Start hint overrides", "video", "", media.MediaTypeVideo, http.StatusOK},
+		{"Complete hint wins", "photo", "video", media.MediaTypeVideo, http.StatusOK},
+		{"Unknown hint rejected", "", "hologram", "", http.StatusBadRequest},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content := []byte(fmt.Sprintf("clip%06d", i))
+			session, err := handler.manager.CreateSession(&models.StartUploadRequest{
+				FileName:      "VID_20240315_143022.dat",
+				FileSize:      int64(len(content)),
+				ChunkSize:     int64(len(content)),
+				MediaTypeHint: test.startHint,
+			})
+			if err != nil {
+				t.Fatalf("CreateSession failed: %v", err)
+			}
+			if err := handler.manager.UploadChunk(session.ID, 0, content, ""); err != nil {
+				t.Fatalf("UploadChunk failed: %v", err)
+			}
+
+			body, _ := json.Marshal(&models.CompleteUploadRequest{
+				SessionID:     session.ID,
+				MediaTypeHint: test.completeHint,
+			})
+			rr := httptest.NewRecorder()
+			handler.CompleteUploadHandler(rr, httptest.NewRequest("POST", "/api/upload/complete", bytes.NewReader(body)))
+
+			if rr.Code != test.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", test.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if test.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var result struct {
+				MediaInfo media.MediaInfo `json:"mediaInfo"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if result.MediaInfo.MediaType != test.expectedType {
+				t.Errorf("Expected media type %s, got %s", test.expectedType, result.MediaInfo.MediaType)
+			}
+		})
+	}
+}
+
+func TestCompleteUploadHandlerDateTaken(t *testing.T) {
+	tempDir := t.TempDir()
+	mediaDir := t.TempDir()
+	handler := NewUploadHandlers(tempDir, mediaDir)
+
+	august := time.Date(2019, 8, 1, 10, 0, 0, 0, time.UTC)
+	december := time.Date(2020, 12, 24, 18, 0, 0, 0, time.UTC)
+	tooEarly := time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		startDate      *time.Time
+		completeDate   *time.Time
+		expectedDir    string
+		expectedStatus int
+	}{
+		{"Start date wins over filename", &august, nil, filepath.Join("2019", "August"), http.StatusOK},
+		{"Complete date wins over start", &august, &december, filepath.Join("2020", "December"), http.StatusOK},
+		{"Out of range date rejected", nil, &tooEarly, "", http.StatusBadRequest},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content := []byte(fmt.Sprintf("photo%06d", i))
+			session, err := handler.manager.CreateSession(&models.StartUploadRequest{
+				FileName:  "IMG_20240315_143022.jpg",
+				FileSize:  int64(len(content)),
+				ChunkSize: int64(len(content)),
+				DateTaken: test.startDate,
+			})
+			if err != nil {
+				t.Fatalf("CreateSession failed: %v", err)
+			}
+			if err := handler.manager.UploadChunk(session.ID, 0, content, ""); err != nil {
+				t.Fatalf("UploadChunk failed: %v", err)
+			}
+
+			body, _ := json.Marshal(&models.CompleteUploadRequest{
+				SessionID: session.ID,
+				DateTaken: test.completeDate,
+			})
+			rr := httptest.NewRecorder()
+			handler.CompleteUploadHandler(rr, httptest.NewRequest("POST", "/api/upload/complete", bytes.NewReader(body)))
+
+			if rr.Code != test.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", test.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if test.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var result struct {
+				MediaInfo media.MediaInfo `json:"mediaInfo"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if dir := filepath.Dir(result.MediaInfo.RelativePath); dir != test.expectedDir {
+				t.Errorf("Expected the file in %s, got %s", test.expectedDir, result.MediaInfo.RelativePath)
+			}
+		})
+	}
+}
+
+func TestCompleteUploadHandlerRequireDate(t *testing.T) {
+	tests := []struct {
+		name           string
+		requireDate    bool
+		fileName       string
+		expectedStatus int
+	}{
+		{"Lenient files undated uploads", false, "holiday.jpg", http.StatusOK},
+		{"Strict asks for a date", true, "holiday.jpg", http.StatusUnprocessableEntity},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mediaDir := t.TempDir()
+			handler := NewUploadHandlers(t.TempDir(), mediaDir)
+			handler.requireDate = test.requireDate
+
+			content := []byte("undated photo")
+			session, err := handler.manager.CreateSession(&models.StartUploadRequest{
+				FileName:  test.fileName,
+				FileSize:  int64(len(content)),
+				ChunkSize: int64(len(content)),
+			})
+			if err != nil {
+				t.Fatalf("CreateSession failed: %v", err)
+			}
+			if err := handler.manager.UploadChunk(session.ID, 0, content, ""); err != nil {
+				t.Fatalf("UploadChunk failed: %v", err)
+			}
+
+			complete := func() *httptest.ResponseRecorder {
+				body, _ := json.Marshal(&models.CompleteUploadRequest{SessionID: session.ID})
+				rr := httptest.NewRecorder()
+				handler.CompleteUploadHandler(rr, httptest.NewRequest("POST", "/api/upload/complete", bytes.NewReader(body)))
+				return rr
+			}
+
+			rr := complete()
+			if rr.Code != test.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", test.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if test.expectedStatus == http.StatusOK {
+				return
+			}
+
+			// The upload is kept until the user dates it
+			if stored, err := handler.manager.GetSession(session.ID); err != nil || stored.Status == models.StatusFailed {
+				t.Fatalf("Expected the session kept for a retry, got %+v (%v)", stored, err)
+			}
+
+			rr = httptest.NewRecorder()
+			handler.UserDateHandler(rr, httptest.NewRequest("POST", "/api/media/user-date",
+				strings.NewReader(`{"sessionId": "`+session.ID+`", "dateTaken": "2019-08-01T10:00:00Z"}`)))
+			if rr.Code != http.StatusNoContent {
+				t.Fatalf("Expected status %d, got %d: %s", http.StatusNoContent, rr.Code, rr.Body.String())
+			}
+
+			rr = complete()
+			if rr.Code != http.StatusOK {
+				t.Fatalf("Expected the dated retry to succeed, got %d: %s", rr.Code, rr.Body.String())
+			}
+			if _, err := os.Stat(filepath.Join(mediaDir, "2019", "August", test.fileName)); err != nil {
+				t.Errorf("Expected the file filed under the user's date: %v", err)
+			}
+		})
+	}
+}
+
+func TestUserDateHandler(t *testing.T) {
+	handler := NewUploadHandlers(t.TempDir(), t.TempDir())
+	session, err := handler.manager.CreateSession(&models.StartUploadRequest{FileName: "holiday.jpg", FileSize: 10, ChunkSize: 10})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	failed, err := handler.manager.CreateSession(&models.StartUploadRequest{FileName: "broken.jpg", FileSize: 10, ChunkSize: 10})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	handler.manager.FailSession(failed.ID, "disk full")
+
+	tests := []struct {
+		name           string
+		body           string
+		expectedStatus int
+	}{
+		{"Stored", `{"sessionId": "` + session.ID + `", "dateTaken": "2019-08-01T10:00:00Z"}`, http.StatusNoContent},
+		{"Failed session", `{"sessionId": "` + failed.ID + `", "dateTaken": "2019-08-01T10:00:00Z"}`, http.StatusConflict},
+		{"Missing session", `{"dateTaken": "2019-08-01T10:00:00Z"}`, http.StatusBadRequest},
+		{"Missing date", `{"sessionId": "` + session.ID + `"}`, http.StatusBadRequest},
+		{"Unreasonable date", `{"sessionId": "` + session.ID + `", "dateTaken": "2999-01-01T00:00:00Z"}`, http.StatusBadRequest},
+		{"Unknown session", `{"sessionId": "nope", "dateTaken": "2019-08-01T10:00:00Z"}`, http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.UserDateHandler(rr, httptest.NewRequest("POST", "/api/media/user-date", strings.NewReader(test.body)))
+			if rr.Code != test.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", test.expectedStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	stored, _ := handler.manager.GetSession(session.ID)
+	if expected := time.Date(2019, 8, 1, 10, 0, 0, 0, time.UTC); stored.DateTaken == nil || !stored.DateTaken.Equal(expected) {
+		t.Errorf("Expected the session dated %v, got %v", expected, stored.DateTaken)
+	}
+}
+
+func TestStartUploadHandlerRejectsUnreasonableDate(t *testing.T) {
+	handler := NewUploadHandlers(t.TempDir(), t.TempDir())
+
+	body := `{"fileName": "IMG_0001.jpg", "fileSize": 10, "dateTaken": "2999-01-01T00:00:00Z"}`
+	rr := httptest.NewRecorder()
+	handler.StartUploadHandler(rr, httptest.NewRequest("POST", "/api/upload/start", strings.NewReader(body)))
+
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), "dateTaken") {
+		t.Errorf("Expected a dateTaken validation error, got %s", rr.Body.String())
+	}
+}
+
+func TestCompleteUploadHandlerPostStepFailure(t *testing.T) {
+	tempDir := t.TempDir()
+	mediaDir := t.TempDir()
+	handler := NewUploadHandlers(tempDir, mediaDir)
+	handler.postOrganizeSteps = []postOrganizeStep{
+		{name: "thumbnail generation", run: func(ctx context.Context, info *media.MediaInfo) error {
+			return errors.New("decoder unavailable")
+		}},
+	}
+
+	content := []byte("0123456789")
+	session, err := handler.manager.CreateSession(&models.StartUploadRequest{
+		FileName:  "IMG_20240315_143022.jpg",
+		FileSize:  int64(len(content)),
+		ChunkSize: int64(len(content)),
+	})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if err := handler.manager.UploadChunk(session.ID, 0, content, ""); err != nil {
+		t.Fatalf("UploadChunk failed: %v", err)
+	}
+
+	body, _ := json.Marshal(&models.CompleteUploadRequest{SessionID: session.ID})
+	rr := httptest.NewRecorder()
+	handler.CompleteUploadHandler(rr, httptest.NewRequest("POST", "/api/upload/complete", bytes.NewReader(body)))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var result struct {
+		Organized bool            `json:"organized"`
+		Warnings  []string        `json:"warnings"`
+		MediaInfo media.MediaInfo `json:"mediaInfo"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if !result.Organized {
+		t.Error("Expected upload to be reported as organized")
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "thumbnail generation failed") {
+		t.Errorf("Expected a single thumbnail warning, got %v", result.Warnings)
+	}
+	if result.MediaInfo.RelativePath == "" {
+		t.Fatal("Expected relative path of the organized file")
+	}
+	if _, err := os.Stat(filepath.Join(mediaDir, result.MediaInfo.RelativePath)); err != nil {
+		t.Errorf("Organized file should exist despite the failed post-step: %v", err)
+	}
+}
+
+func TestThumbnailStep(t *testing.T) {
+	mediaDir := t.TempDir()
+	thumbnailer := media.NewThumbnailer(t.TempDir(), 32, media.NewConverter(t.TempDir()))
+	step := thumbnailStep(thumbnailer, mediaDir)
+
+	var encoded bytes.Buffer
+	if err := png.Encode(&encoded, image.NewRGBA(image.Rect(0, 0, 64, 48))); err != nil {
+		t.Fatalf("Failed to encode image: %v", err)
+	}
+	imagePath := writeMediaFile(t, mediaDir, "2024/March/photo.png", encoded.String())
+	writeMediaFile(t, mediaDir, "2024/March/clip.mp4", "not really a video")
+
+	if err := step.run(context.Background(), &media.MediaInfo{RelativePath: "2024/March/photo.png"}); err != nil {
+		t.Fatalf("Expected the thumbnail to be generated, got %v", err)
+	}
+	if _, exists, err := thumbnailer.CachedPath(imagePath); err != nil || !exists {
+		t.Errorf("Expected a cached thumbnail after the step, got exists=%v err=%v", exists, err)
+	}
+
+	if err := step.run(context.Background(), &media.MediaInfo{RelativePath: "2024/March/clip.mp4"}); err != nil {
+		t.Errorf("Expected unsupported files to be skipped, got %v", err)
+	}
+}
+
+func TestCompleteUploadHandlerWebhook(t *testing.T) {
+	var mutex sync.Mutex
+	var events []webhook.Event
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event webhook.Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			t.Errorf("Failed to decode webhook body: %v", err)
+		}
+		mutex.Lock()
+		events = append(events, event)
+		mutex.Unlock()
+	}))
+	defer stub.Close()
+
+	mediaDir := t.TempDir()
+	notifier := webhook.New(webhook.Options{URL: stub.URL})
+	organizer := media.NewOrganizerWithOptions(mediaDir, media.OrganizerOptions{OnOrganized: organizedWebhook(notifier)})
+	handler := newUploadHandlers(upload.NewManager(t.TempDir(), 10), organizer)
+	handler.webhooks = notifier
+
+	dateTaken := time.Date(2024, 3, 15, 14, 30, 22, 0, time.UTC)
+	complete := func(fileName string, content []byte, checksum string) int {
+		session, err := handler.manager.CreateSession(&models.StartUploadRequest{
+			FileName:  fileName,
+			FileSize:  int64(len(content)),
+			ChunkSize: int64(len(content)),
+			DateTaken: &dateTaken,
+		})
+		if err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+		if err := handler.manager.UploadChunk(session.ID, 0, content, ""); err != nil {
+			t.Fatalf("UploadChunk failed: %v", err)
+		}
+
+		body, _ := json.Marshal(&models.CompleteUploadRequest{SessionID: session.ID, Checksum: checksum})
+		rr := httptest.NewRecorder()
+		handler.CompleteUploadHandler(rr, httptest.NewRequest("POST", "/api/upload/complete", bytes.NewReader(body)))
+		return rr.Code
+	}
+
+	if code := complete("IMG_20240315_143022.jpg", []byte("0123456789"), ""); code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, code)
+	}
+	if code := complete("IMG_20240316_090000.jpg", []byte("abcdefghij"), strings.Repeat("0", 64)); code != http.StatusUnprocessableEntity {
+		t.Fatalf("Expected status %d, got %d", http.StatusUnprocessableEntity, code)
+	}
+	handler.webhooks.Close() // Waits for delivery
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 webhook events, got %+v", events)
+	}
+
+	organized := events[0]
+	if organized.Type != webhook.EventFileOrganized {
+		t.Errorf("Expected %s event, got %s", webhook.EventFileOrganized, organized.Type)
+	}
+	data, _ := organized.Data.(map[string]any)
+	if data["fileName"] != "IMG_20240315_143022.jpg" || data["relativePath"] != "2024/March/IMG_20240315_143022.jpg" {
+		t.Errorf("Expected the organized file's name and path, got %v", data)
+	}
+	mediaInfo, _ := data["mediaInfo"].(map[string]any)
+	if mediaInfo["dateSource"] != string(media.DateSourceUserInput) {
+		t.Errorf("Expected the file's metadata, got %v", data["mediaInfo"])
+	}
+
+	failed := events[1]
+	if failed.Type != webhook.EventUploadFailed {
+		t.Errorf("Expected %s event, got %s", webhook.EventUploadFailed, failed.Type)
+	}
+	if data, _ := failed.Data.(map[string]any); data["sessionId"] == "" || !strings.Contains(fmt.Sprint(data["error"]), "checksum") {
+		t.Errorf("Expected the failed session and its error, got %v", failed.Data)
+	}
+}
+
+func TestCompleteUploadHandlerErrorStatus(t *testing.T) {
+	content := []byte("0123456789")
+
+	tests := []struct {
+		name           string
+		upload         bool
+		removeTemp     bool
+		request        models.CompleteUploadRequest
+		expectedStatus int
+	}{
+		{"Size mismatch", false, false, models.CompleteUploadRequest{}, http.StatusUnprocessableEntity},
+		{"Checksum mismatch", true, false, models.CompleteUploadRequest{Checksum: strings.Repeat("0", 64)}, http.StatusUnprocessableEntity},
+		{"Tree root mismatch", true, false, models.CompleteUploadRequest{TreeRoot: strings.Repeat("0", 64)}, http.StatusUnprocessableEntity},
+		{"Malformed tree root", true, false, models.CompleteUploadRequest{TreeRoot: "abc"}, http.StatusBadRequest},
+		{"Missing session", false, false, models.CompleteUploadRequest{SessionID: "gone"}, http.StatusNotFound},
+		{"Unreadable temp file", true, true, models.CompleteUploadRequest{Checksum: strings.Repeat("0", 64)}, http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler := NewUploadHandlers(t.TempDir(), t.TempDir())
+
+			session, err := handler.manager.CreateSession(&models.StartUploadRequest{
+				FileName:  "IMG_20240315_143022.jpg",
+				FileSize:  int64(len(content)),
+				ChunkSize: int64(len(content)),
+			})
+			if err != nil {
+				t.Fatalf("CreateSession failed: %v", err)
+			}
+			if test.upload {
+				if err := handler.manager.UploadChunk(session.ID, 0, content, ""); err != nil {
+					t.Fatalf("UploadChunk failed: %v", err)
+				}
+			}
+			if test.removeTemp {
+				os.Remove(session.TempPath)
+			}
+
+			req := test.request
+			if req.SessionID == "" {
+				req.SessionID = session.ID
+			}
+			body, _ := json.Marshal(&req)
+			rr := httptest.NewRecorder()
+			handler.CompleteUploadHandler(rr, httptest.NewRequest("POST", "/api/upload/complete", bytes.NewReader(body)))
+
+			if rr.Code != test.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", test.expectedStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	t.Run("Failed session", func(t *testing.T) {
+		handler := NewUploadHandlers(t.TempDir(), t.TempDir())
+		session, err := handler.manager.CreateSession(&models.StartUploadRequest{
+			FileName:  "IMG_20240315_143022.jpg",
+			FileSize:  int64(len(content)),
+			ChunkSize: int64(len(content)),
+		})
+		if err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+		if err := handler.manager.UploadChunk(session.ID, 0, content, ""); err != nil {
+			t.Fatalf("UploadChunk failed: %v", err)
+		}
+		if err := handler.manager.FailSession(session.ID, "organize failed"); err != nil {
+			t.Fatalf("FailSession failed: %v", err)
+		}
+
+		body, _ := json.Marshal(&models.CompleteUploadRequest{SessionID: session.ID})
+		rr := httptest.NewRecorder()
+		handler.CompleteUploadHandler(rr, httptest.NewRequest("POST", "/api/upload/complete", bytes.NewReader(body)))
+		if rr.Code != http.StatusConflict {
+			t.Errorf("Expected status %d, got %d: %s", http.StatusConflict, rr.Code, rr.Body.String())
+		}
+	})
+}
+
+func TestFinalizeUploadHandler(t *testing.T) {
+	tempDir := t.TempDir()
+	mediaDir := t.TempDir()
+	handler := NewUploadHandlers(tempDir, mediaDir)
+
+	tests := []struct {
+		name           string
+		year           string
+		month          string
+		album          string
+		expectedPath   string
+		expectedStatus int
+	}{
+		{"Chosen month", "2023", "7", "", "2023/July/IMG_20240315_143022.jpg", http.StatusOK},
+		{"Month by name", "2022", "December", "", "2022/December/IMG_20240315_143022.jpg", http.StatusOK},
+		{"Album", "", "", "Holiday", "Albums/Holiday/IMG_20240315_143022.jpg", http.StatusOK},
+		{"Month without year", "", "7", "", "", http.StatusBadRequest},
+		{"Invalid month", "2023", "13", "", "", http.StatusBadRequest},
+		{"Invalid year", "23", "7", "", "", http.StatusBadRequest},
+		{"Album escaping library", "", "", "../x", "", http.StatusBadRequest},
+		{"Album and month", "2023", "7", "Holiday", "", http.StatusBadRequest},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content := []byte(fmt.Sprintf("photo%06d", i))
+			session, err := handler.manager.CreateSession(&models.StartUploadRequest{
+				FileName:  "IMG_20240315_143022.jpg",
+				FileSize:  int64(len(content)),
+				ChunkSize: int64(len(content)),
+			})
+			if err != nil {
+				t.Fatalf("CreateSession failed: %v", err)
+			}
+			if err := handler.manager.UploadChunk(session.ID, 0, content, ""); err != nil {
+				t.Fatalf("UploadChunk failed: %v", err)
+			}
+
+			body, _ := json.Marshal(&models.FinalizeUploadRequest{
+				CompleteUploadRequest: models.CompleteUploadRequest{SessionID: session.ID},
+				Year:                  test.year,
+				Month:                 test.month,
+				Album:                 test.album,
+			})
+			rr := httptest.NewRecorder()
+			handler.FinalizeUploadHandler(rr, httptest.NewRequest("POST", "/api/upload/finalize", bytes.NewReader(body)))
+
+			if rr.Code != test.expectedStatus {
+				t.Fatalf("Expected status %d, got %d: %s", test.expectedStatus, rr.Code, rr.Body.String())
+			}
+			if test.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var result struct {
+				MediaInfo media.MediaInfo `json:"mediaInfo"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &result); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if result.MediaInfo.RelativePath != test.expectedPath {
+				t.Errorf("Expected relative path %s, got %s", test.expectedPath, result.MediaInfo.RelativePath)
+			}
+			if _, err := os.Stat(filepath.Join(mediaDir, filepath.FromSlash(test.expectedPath))); err != nil {
+				t.Errorf("Expected finalized file in chosen folder: %v", err)
+			}
+		})
+	}
+}
+
+func TestUploadConfigHandler(t *testing.T) {
+	handler := newUploadHandlers(upload.NewManager(t.TempDir(), 3), media.NewOrganizer(t.TempDir()))
+	handler.defaultChunkSize = 2 << 20
+	handler.maxChunkSize = 16 << 20
+	handler.maxFileSize = 4 << 30
+
+	rr := httptest.NewRecorder()
+	handler.UploadConfigHandler(rr, httptest.NewRequest("GET", "/api/upload/config", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var config struct {
+		DefaultChunkSize int64    `json:"defaultChunkSize"`
+		MaxChunkSize     int64    `json:"maxChunkSize"`
+		MaxFileSize      int64    `json:"maxFileSize"`
+		MaxSessions      int      `json:"maxSessions"`
+		ImageExtensions  []string `json:"imageExtensions"`
+		VideoExtensions  []string `json:"videoExtensions"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &config); err != nil {
+		t.Fatalf("Failed to unmarshal response: %v", err)
+	}
+
+	if config.DefaultChunkSize != 2<<20 {
+		t.Errorf("Expected default chunk size %d, got %d", 2<<20, config.DefaultChunkSize)
+	}
+	if config.MaxChunkSize != 16<<20 {
+		t.Errorf("Expected max chunk size %d, got %d", 16<<20, config.MaxChunkSize)
+	}
+	if config.MaxFileSize != 4<<30 {
+		t.Errorf("Expected max file size %d, got %d", int64(4<<30), config.MaxFileSize)
+	}
+	if config.MaxSessions != 3 {
+		t.Errorf("Expected max sessions 3, got %d", config.MaxSessions)
+	}
+	if !slices.Contains(config.ImageExtensions, ".jpg") || slices.Contains(config.ImageExtensions, ".mp4") {
+		t.Errorf("Unexpected image extensions %v", config.ImageExtensions)
+	}
+	if !slices.Contains(config.VideoExtensions, ".mp4") || slices.Contains(config.VideoExtensions, ".jpg") {
+		t.Errorf("Unexpected video extensions %v", config.VideoExtensions)
+	}
+}
+
+func TestStartUploadHandlerLibraryQuota(t *testing.T) {
+	tempDir := t.TempDir()
+	mediaDir := t.TempDir()
+	handler := NewUploadHandlers(tempDir, mediaDir)
+	handler.maxLibraryBytes = 150
+
+	// 100 bytes already in the library
+	existing := filepath.Join(mediaDir, "2024", "March", "existing.jpg")
+	if err := os.MkdirAll(filepath.Dir(existing), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(existing, make([]byte, 100), 0644); err != nil {
+		t.Fatalf("Failed to create existing file: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		fileSize       int64
+		expectedStatus int
+	}{
+		{"Within quota", 40, http.StatusOK},
+		{"Exceeds quota with in-flight upload", 40, http.StatusInsufficientStorage},
+		{"Fits remaining space", 10, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body, _ := json.Marshal(&models.StartUploadRequest{
+				FileName: "test.jpg",
+				FileSize: test.fileSize,
+			})
+			rr := httptest.NewRecorder()
+			handler.StartUploadHandler(rr, httptest.NewRequest("POST", "/api/upload/start", bytes.NewReader(body)))
+
+			if rr.Code != test.expectedStatus {
+				t.Errorf("Expected status %d, got %d", test.expectedStatus, rr.Code)
+			}
+		})
+	}
+}
+
+func TestUploadChunkHandlerRawBody(t *testing.T) {
+	handler := NewUploadHandlers(t.TempDir(), t.TempDir())
+
+	content := []byte("0123456789abcdefghijKLMNO")
+	session, err := handler.manager.CreateSession(&models.StartUploadRequest{
+		FileName:  "IMG_20240315_143022.jpg",
+		FileSize:  int64(len(content)),
+		ChunkSize: 10,
+	})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	put := func(chunk []byte, query string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("PUT", "/api/upload/chunk"+query, bytes.NewReader(chunk))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		rr := httptest.NewRecorder()
+		handler.UploadChunkHandler(rr, req)
+		return rr
+	}
+
+	// Out of order, mixing query parameters and headers
+	checksum := fmt.Sprintf("%x", sha256.Sum256(content[20:]))
+	rr := put(content[20:], fmt.Sprintf("?session_id=%s&chunk_number=2&checksum=%s", session.ID, checksum), nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d for last chunk, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	rr = put(content[:10], "", map[string]string{"X-Session-Id": session.ID, "X-Chunk-Number": "0"})
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d for first chunk, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	if rr := put([]byte("tampered!!"), "?sessionId="+session.ID+"&chunkNumber=1&checksum="+checksum, nil); rr.Code != http.StatusInternalServerError {
+		t.Errorf("Expected checksum mismatch to fail, got %d", rr.Code)
+	}
+	if rr := put(bytes.Repeat([]byte("x"), 11), "?session_id="+session.ID+"&chunk_number=1", nil); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected oversized chunk to be rejected, got %d", rr.Code)
+	}
+	if rr := put(content[10:20], "?chunk_number=1", nil); rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected missing session to be rejected, got %d", rr.Code)
+	}
+	for _, chunkNumber := range []string{"-1", "3"} {
+		if rr := put(content[:5], "?session_id="+session.ID+"&chunk_number="+chunkNumber, nil); rr.Code != http.StatusBadRequest {
+			t.Errorf("Expected chunk %s out of range to be rejected, got %d", chunkNumber, rr.Code)
+		}
+	}
+
+	rr = put(content[10:20], "?session_id="+session.ID+"&chunk_number=1", nil)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d for middle chunk, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	if err := handler.manager.CompleteUpload(session.ID, fmt.Sprintf("%x", sha256.Sum256(content))); err != nil {
+		t.Fatalf("CompleteUpload failed: %v", err)
+	}
+	assembled, err := os.ReadFile(session.TempPath)
+	if err != nil {
+		t.Fatalf("Failed to read assembled file: %v", err)
+	}
+	if !bytes.Equal(assembled, content) {
+		t.Errorf("Expected assembled content %q, got %q", content, assembled)
+	}
+}
+
+func TestUploadChunkHandlerRejectsOutOfRangeChunks(t *testing.T) {
+	handler := NewUploadHandlers(t.TempDir(), t.TempDir())
+	session, err := handler.manager.CreateSession(&models.StartUploadRequest{
+		FileName:  "IMG_20240315_143022.jpg",
+		FileSize:  25,
+		ChunkSize: 10,
+	})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		chunkNumber string
+		length      int
+		expected    string
+	}{
+		{"Negative", "-1", 10, "chunk number out of range: -1 is not in [0,3)"},
+		{"Too large", "3", 5, "chunk number out of range: 3 is not in [0,3)"},
+		{"Oversized final chunk", "2", 10, "chunk overruns its slot: chunk exceeds its expected length of 5 bytes"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body := &bytes.Buffer{}
+			writer := multipart.NewWriter(body)
+			writer.WriteField("sessionId", session.ID)
+			writer.WriteField("chunkNumber", test.chunkNumber)
+			part, _ := writer.CreateFormFile("chunk", "chunk")
+			part.Write(bytes.Repeat([]byte("x"), test.length))
+			writer.Close()
+
+			req := httptest.NewRequest("POST", "/api/upload/chunk", body)
+			req.Header.Set("Content-Type", writer.FormDataContentType())
+			rr := httptest.NewRecorder()
+			handler.UploadChunkHandler(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Fatalf("Expected status %d, got %d: %s", http.StatusBadRequest, rr.Code, rr.Body.String())
+			}
+			if !strings.Contains(rr.Body.String(), test.expected) {
+				t.Errorf("Expected %q in the response, got %s", test.expected, rr.Body.String())
+			}
+		})
+	}
+
+	stored, err := handler.manager.GetSession(session.ID)
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if stored.Status == models.StatusFailed || stored.UploadedSize != 0 {
+		t.Errorf("Expected rejected chunks to leave the session untouched, got %+v", stored)
+	}
+}
+
+func TestAbortAllHandlerRequiresAdminToken(t *testing.T) {
+	handler := NewUploadHandlers(t.TempDir(), t.TempDir())
+	session, err := handler.manager.CreateSession(&models.StartUploadRequest{FileName: "test.jpg", FileSize: 10, ChunkSize: 10})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		token          string
+		authorization  string
+		expectedStatus int
+	}{
+		{"Disabled without a token", "", "Bearer anything", http.StatusForbidden},
+		{"Missing token", "secret", "", http.StatusUnauthorized},
+		{"Wrong token", "secret", "Bearer guess", http.StatusUnauthorized},
+		{"Valid token", "secret", "Bearer secret", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/upload/abort-all", nil)
+			if test.authorization != "" {
+				req.Header.Set("Authorization", test.authorization)
+			}
+			rr := httptest.NewRecorder()
+			requireAdmin(test.token)(handler.AbortAllHandler)(rr, req)
+
+			if rr.Code != test.expectedStatus {
+				t.Errorf("Expected status %d, got %d: %s", test.expectedStatus, rr.Code, rr.Body.String())
+			}
+		})
+	}
+
+	if _, err := handler.manager.GetSession(session.ID); !errors.Is(err, upload.ErrSessionNotFound) {
+		t.Errorf("Expected the session to be aborted, got %v", err)
+	}
+	if _, err := os.Stat(session.TempPath); !os.IsNotExist(err) {
+		t.Error("Expected the temp file to be removed")
+	}
+}
+
+func TestUploadHandlersAcceptSnakeCaseParams(t *testing.T) {
+	handler := NewUploadHandlers(t.TempDir(), t.TempDir())
+
+	content := []byte("legacy client")
+	session, err := handler.manager.CreateSession(&models.StartUploadRequest{
+		FileName:  "IMG_20240315_143022.jpg",
+		FileSize:  int64(len(content)),
+		ChunkSize: int64(len(content)),
+	})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	writer.WriteField("session_id", session.ID)
+	writer.WriteField("chunk_number", "0")
+	writer.WriteField("checksum_algorithm", "sha256")
+	writer.WriteField("checksum", fmt.Sprintf("%x", sha256.Sum256(content)))
+	part, _ := writer.CreateFormFile("chunk", "chunk")
+	part.Write(content)
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/api/upload/chunk", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	rr := httptest.NewRecorder()
+	handler.UploadChunkHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d for a snake_case chunk, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/api/upload/progress?session_id="+session.ID, nil)
+	rr = httptest.NewRecorder()
+	handler.GetProgressHandler(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d for snake_case progress, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	var progress models.UploadProgress
+	if err := json.NewDecoder(rr.Body).Decode(&progress); err != nil {
+		t.Fatalf("Failed to decode progress: %v", err)
+	}
+	if progress.UploadedChunks != 1 {
+		t.Errorf("Expected 1 uploaded chunk, got %d", progress.UploadedChunks)
+	}
+}
+
+func TestMissingChunksHandler(t *testing.T) {
+	handler := NewUploadHandlers(t.TempDir(), t.TempDir())
+	session, err := handler.manager.CreateSession(&models.StartUploadRequest{
+		FileName:  "IMG_20240315_143022.jpg",
+		FileSize:  30,
+		ChunkSize: 10,
+	})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	for _, chunkNumber := range []int{0, 2} {
+		if err := handler.manager.UploadChunk(session.ID, chunkNumber, []byte("0123456789"), ""); err != nil {
+			t.Fatalf("UploadChunk failed: %v", err)
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	handler.MissingChunksHandler(rr, httptest.NewRequest("GET", "/api/upload/missing?session_id="+session.ID, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var result struct {
+		MissingChunks []int `json:"missingChunks"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&result); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(result.MissingChunks) != 1 || result.MissingChunks[0] != 1 {
+		t.Errorf("Expected chunk 1 missing, got %v", result.MissingChunks)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.MissingChunksHandler(rr, httptest.NewRequest("GET", "/api/upload/missing?sessionId=unknown", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d for an unknown session, got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestValidateBatchHandler(t *testing.T) {
+	handler := NewUploadHandlers(t.TempDir(), t.TempDir())
+
+	stored := []byte("already in the library")
+	tempFile := filepath.Join(t.TempDir(), "upload.tmp")
+	if err := os.WriteFile(tempFile, stored, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	info, err := handler.organizer.OrganizeFileWithOptions(context.Background(), tempFile, "IMG_20240315_143022.jpg", media.OrganizeOptions{})
+	if err != nil {
+		t.Fatalf("Failed to organize file: %v", err)
+	}
+
+	validate := func(files []models.BatchFile) (int, models.ValidateBatchResponse) {
+		body, _ := json.Marshal(models.ValidateBatchRequest{Files: files})
+		rr := httptest.NewRecorder()
+		handler.ValidateBatchHandler(rr, httptest.NewRequest("POST", "/api/upload/validate-batch", bytes.NewReader(body)))
+
+		var result models.ValidateBatchResponse
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&result); err != nil {
+				t.Fatalf("Failed to decode response: %v", err)
+			}
+		}
+		return rr.Code, result
+	}
+
+	code, result := validate([]models.BatchFile{
+		{FileName: "copy.jpg", FileSize: int64(len(stored)), Checksum: fmt.Sprintf("%x", sha256.Sum256(stored))},
+		{FileName: "new.mp4", FileSize: 300, Checksum: fmt.Sprintf("%x", sha256.Sum256([]byte("new")))},
+		{FileName: "notes.docx", FileSize: 200},
+		{FileName: "empty.jpg", FileSize: 0},
+		{FileName: "bad.jpg", FileSize: 10, Checksum: "not-hex"},
+	})
+	if code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, code)
+	}
+	if len(result.Files) != 5 {
+		t.Fatalf("Expected 5 results, got %+v", result.Files)
+	}
+
+	duplicate := result.Files[0]
+	if !duplicate.Duplicate || duplicate.ExistingPath != filepath.ToSlash(info.RelativePath) {
+		t.Errorf("Expected copy.jpg to be a duplicate of %s, got %+v", info.RelativePath, duplicate)
+	}
+	if fresh := result.Files[1]; fresh.Duplicate || !fresh.Supported || fresh.Errors != nil {
+		t.Errorf("Expected new.mp4 to be a valid new upload, got %+v", fresh)
+	}
+	if unsupported := result.Files[2]; unsupported.Supported || unsupported.Errors != nil {
+		t.Errorf("Expected notes.docx to be unsupported but accepted, got %+v", unsupported)
+	}
+	if empty := result.Files[3]; empty.Errors["fileSize"] == "" {
+		t.Errorf("Expected empty.jpg to be rejected for its size, got %+v", empty)
+	}
+	if bad := result.Files[4]; bad.Errors["checksum"] == "" {
+		t.Errorf("Expected bad.jpg to have a malformed checksum, got %+v", bad)
+	}
+
+	// Only new.mp4 and notes.docx still need uploading
+	if result.UploadCount != 2 || result.UploadBytes != 500 {
+		t.Errorf("Expected 2 uploads of 500 bytes, got %d of %d", result.UploadCount, result.UploadBytes)
+	}
+	if !result.FitsQuota || !result.FitsSessionLimit || result.AvailableSessions != 10 {
+		t.Errorf("Expected the batch to fit, got %+v", result)
+	}
+
+	handler.maxLibraryBytes = int64(len(stored)) + 400
+	if _, result := validate([]models.BatchFile{{FileName: "a.jpg", FileSize: 300}, {FileName: "b.jpg", FileSize: 300}}); result.FitsQuota {
+		t.Error("Expected the batch to exceed the library quota")
+	}
+
+	if code, _ := validate(nil); code != http.StatusBadRequest {
+		t.Errorf("Expected an empty batch to be rejected, got %d", code)
+	}
+}
@@ -0,0 +1,195 @@
+package api
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Steven-harris/sortify/backend/internal/media"
+	"github.com/Steven-harris/sortify/backend/pkg/response"
+)
+
+// maxZipSelection bounds how many paths a POSTed selection may list.
+const maxZipSelection = 10000
+
+// DownloadZipHandler streams a ZIP of library files. GET takes year and an
+// optional month and archives that folder; POST takes {"paths": [...]} of
+// library-relative files. Entries keep their library-relative paths and are
+// stored uncompressed, since photos and videos are already compressed. The
+// archive is written as it is built, so a failure part-way through leaves the
+// client with a truncated (invalid) ZIP rather than an error response.
+func (h *MediaHandlers) DownloadZipHandler(w http.ResponseWriter, r *http.Request) {
+	var (
+		paths    []string
+		fileName string
+		err      error
+	)
+
+	switch r.Method {
+	case http.MethodGet:
+		year := r.URL.Query().Get("year")
+		month := r.URL.Query().Get("month")
+		if year == "" {
+			response.BadRequest(w, "Year is required")
+			return
+		}
+		paths, err = h.organizer.MediaFilesIn(year, month)
+		if errors.Is(err, media.ErrInvalidPath) {
+			response.BadRequest(w, err.Error())
+			return
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			response.NotFound(w, "Folder not found")
+			return
+		}
+		if err != nil {
+			slog.Error("Failed to list files for download", "error", err, "year", year, "month", month)
+			response.InternalError(w, "Failed to list files")
+			return
+		}
+		fileName = zipFileName(year, month)
+
+	case http.MethodPost:
+		var req struct {
+			Paths []string `json:"paths"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			response.BadRequest(w, "Invalid request body")
+			return
+		}
+		if len(req.Paths) == 0 {
+			response.BadRequest(w, "At least one path is required")
+			return
+		}
+		if len(req.Paths) > maxZipSelection {
+			response.BadRequest(w, fmt.Sprintf("At most %d paths may be downloaded at once", maxZipSelection))
+			return
+		}
+		if paths, err = h.resolveSelection(req.Paths); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				response.NotFound(w, err.Error())
+			} else {
+				response.BadRequest(w, err.Error())
+			}
+			return
+		}
+		fileName = zipFileName("selection")
+
+	default:
+		response.Error(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+	w.WriteHeader(http.StatusOK)
+
+	if err := h.writeZip(r, w, paths); err != nil {
+		slog.Error("ZIP download aborted", "error", err, "filename", fileName)
+	}
+}
+
+// resolveSelection validates client-supplied paths, all of which must be
+// files inside the library, and returns them in library-relative form with
+// duplicates dropped.
+func (h *MediaHandlers) resolveSelection(requested []string) ([]string, error) {
+	seen := make(map[string]bool, len(requested))
+	paths := make([]string, 0, len(requested))
+	for _, relPath := range requested {
+		fullPath, err := h.organizer.ResolvePath(relPath)
+		if err != nil {
+			return nil, err
+		}
+
+		info, err := os.Stat(fullPath)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", relPath, fs.ErrNotExist)
+		}
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("%w: %q is not a file", media.ErrInvalidPath, relPath)
+		}
+
+		cleaned, err := filepath.Rel(h.organizer.MediaPath(), fullPath)
+		if err != nil {
+			return nil, err
+		}
+		if cleaned = filepath.ToSlash(cleaned); !seen[cleaned] {
+			seen[cleaned] = true
+			paths = append(paths, cleaned)
+		}
+	}
+	return paths, nil
+}
+
+// writeZip streams the library files at relPaths into a ZIP on w, stopping
+// if the client goes away.
+func (h *MediaHandlers) writeZip(r *http.Request, w io.Writer, relPaths []string) error {
+	archive := zip.NewWriter(w)
+	for _, relPath := range relPaths {
+		if err := r.Context().Err(); err != nil {
+			return err
+		}
+		if err := h.addZipEntry(archive, relPath); err != nil {
+			return fmt.Errorf("%s: %w", relPath, err)
+		}
+	}
+	return archive.Close()
+}
+
+func (h *MediaHandlers) addZipEntry(archive *zip.Writer, relPath string) error {
+	fullPath, err := h.organizer.ResolvePath(relPath)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(fullPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = relPath
+	header.Method = zip.Store
+
+	entry, err := archive.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(entry, file)
+	return err
+}
+
+// zipFileName builds a download name such as "sortify-2024-March.zip" from
+// the non-empty parts, keeping only characters safe in a header value.
+func zipFileName(parts ...string) string {
+	name := "sortify"
+	for _, part := range parts {
+		part = strings.Map(func(r rune) rune {
+			if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' {
+				return r
+			}
+			return -1
+		}, part)
+		if part != "" {
+			name += "-" + part
+		}
+	}
+	return name + ".zip"
+}
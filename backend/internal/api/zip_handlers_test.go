@@ -0,0 +1,132 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// readZipEntries returns the name and content of every entry in a ZIP body.
+func readZipEntries(t *testing.T, body []byte) map[string]string {
+	t.Helper()
+
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		t.Fatalf("Failed to read ZIP: %v", err)
+	}
+
+	entries := make(map[string]string)
+	for _, file := range archive.File {
+		reader, err := file.Open()
+		if err != nil {
+			t.Fatalf("Failed to open entry %s: %v", file.Name, err)
+		}
+		content, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			t.Fatalf("Failed to read entry %s: %v", file.Name, err)
+		}
+		entries[file.Name] = string(content)
+	}
+	return entries
+}
+
+func TestDownloadZipHandlerMonth(t *testing.T) {
+	mediaDir := t.TempDir()
+	handler := NewMediaHandlers(mediaDir)
+
+	writeMediaFile(t, mediaDir, "2024/March/IMG_001.jpg", "first")
+	writeMediaFile(t, mediaDir, "2024/March/Burst_20240315_150000/IMG_002.jpg", "second")
+	writeMediaFile(t, mediaDir, "2024/March/notes.txt", "not media")
+	writeMediaFile(t, mediaDir, "2024/April/IMG_003.jpg", "other month")
+
+	rr := httptest.NewRecorder()
+	handler.DownloadZipHandler(rr, httptest.NewRequest("GET", "/api/media/download-zip?year=2024&month=March", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	if contentType := rr.Header().Get("Content-Type"); contentType != "application/zip" {
+		t.Errorf("Expected Content-Type application/zip, got %q", contentType)
+	}
+	if disposition := rr.Header().Get("Content-Disposition"); disposition != `attachment; filename="sortify-2024-March.zip"` {
+		t.Errorf("Unexpected Content-Disposition %q", disposition)
+	}
+
+	entries := readZipEntries(t, rr.Body.Bytes())
+	expected := map[string]string{
+		"2024/March/IMG_001.jpg":                       "first",
+		"2024/March/Burst_20240315_150000/IMG_002.jpg": "second",
+	}
+	if len(entries) != len(expected) {
+		names := make([]string, 0, len(entries))
+		for name := range entries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		t.Fatalf("Expected %d entries, got %v", len(expected), names)
+	}
+	for name, content := range expected {
+		if entries[name] != content {
+			t.Errorf("Expected entry %s with %q, got %q", name, content, entries[name])
+		}
+	}
+}
+
+func TestDownloadZipHandlerSelection(t *testing.T) {
+	mediaDir := t.TempDir()
+	handler := NewMediaHandlers(mediaDir)
+
+	writeMediaFile(t, mediaDir, "2024/March/IMG_001.jpg", "first")
+	writeMediaFile(t, mediaDir, "2023/May/VID_001.mp4", "video")
+	writeMediaFile(t, mediaDir, "2023/May/IMG_002.jpg", "not selected")
+
+	body := `{"paths": ["2024/March/IMG_001.jpg", "2023/May/VID_001.mp4", "2024/March/IMG_001.jpg"]}`
+	rr := httptest.NewRecorder()
+	handler.DownloadZipHandler(rr, httptest.NewRequest("POST", "/api/media/download-zip", strings.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	entries := readZipEntries(t, rr.Body.Bytes())
+	if len(entries) != 2 || entries["2024/March/IMG_001.jpg"] != "first" || entries["2023/May/VID_001.mp4"] != "video" {
+		t.Errorf("Expected the two selected files once each, got %v", entries)
+	}
+}
+
+func TestDownloadZipHandlerRejectsBadPaths(t *testing.T) {
+	mediaDir := t.TempDir()
+	handler := NewMediaHandlers(mediaDir)
+	writeMediaFile(t, mediaDir, "2024/March/IMG_001.jpg", "first")
+
+	tests := []struct {
+		name     string
+		method   string
+		url      string
+		body     string
+		expected int
+	}{
+		{"missing year", "GET", "/api/media/download-zip", "", http.StatusBadRequest},
+		{"missing folder", "GET", "/api/media/download-zip?year=1999", "", http.StatusNotFound},
+		{"missing file", "POST", "/api/media/download-zip", `{"paths": ["2024/March/nope.jpg"]}`, http.StatusNotFound},
+		{"directory", "POST", "/api/media/download-zip", `{"paths": ["2024/March"]}`, http.StatusBadRequest},
+		{"empty selection", "POST", "/api/media/download-zip", `{"paths": []}`, http.StatusBadRequest},
+		{"library root", "POST", "/api/media/download-zip", `{"paths": ["../.."]}`, http.StatusBadRequest},
+		{"wrong method", "DELETE", "/api/media/download-zip", "", http.StatusMethodNotAllowed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.DownloadZipHandler(rr, httptest.NewRequest(test.method, test.url, strings.NewReader(test.body)))
+			if rr.Code != test.expected {
+				t.Errorf("Expected status %d, got %d: %s", test.expected, rr.Code, rr.Body.String())
+			}
+		})
+	}
+}
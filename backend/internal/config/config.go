@@ -4,21 +4,233 @@ import (
 	"log/slog"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	Port        string
-	MediaPath   string
-	LogLevel    string
-	CORSOrigins string
+	Port            string
+	MediaPath       string
+	CachePath       string
+	DataPath        string
+	ImportPath      string // Root of server-side folders that can be imported
+	ArchivePath     string // Verbatim copies of every upload; empty disables
+	LogLevel        string
+	CORSOrigins     string
+	AdminToken      string // Bearer token for admin endpoints; empty disables them
+	DebugEndpoints  bool   // Serve admin-only diagnostics under /api/debug/
+	OrganizeTimeout time.Duration
+
+	MetadataMaxKeys        int
+	MetadataMaxKeyLength   int
+	MetadataMaxValueLength int
+	MetadataAllowedKeys    []string
+
+	ListScanLimit int
+	MaxPageLimit  int // Largest limit a listing request may ask for; larger ones are clamped
+
+	MaxLibraryBytes int64 // Zero means unlimited
+
+	SimpleUploadMaxBytes int64 // Largest file accepted by the single-request upload
+
+	UploadChunkSize    int64 // Chunk size for sessions that don't choose one
+	UploadMaxChunkSize int64 // Advertised to clients by /api/upload/config
+	UploadMaxFileSize  int64 // Advertised to clients; zero means unlimited
+	MaxUploadSessions  int   // Sessions receiving chunks at once
+
+	UploadSessionTTL           time.Duration // Sessions idle this long are removed, paused ones aside; zero keeps them until cancelled
+	UploadPausedSessionTTL     time.Duration // Paused sessions idle this long are removed; zero keeps them until resumed or cancelled
+	UploadSessionSweepInterval time.Duration // How often idle sessions are looked for
+
+	MaxHeavyRequests int // Concurrent organizes, imports, rescans and exports; zero is unlimited
+
+	// Trust per-chunk checksums instead of reading the chunks back when an
+	// upload without a whole-file checksum completes. Faster for large
+	// videos, but on-disk corruption after a chunk was checked goes unnoticed.
+	UploadSkipFullChecksum bool
+
+	// "sparse" temp files are instant but reserve nothing, so a full disk
+	// surfaces mid-upload; "eager" reserves the space as the upload starts
+	// (Linux only) and refuses uploads that won't fit.
+	UploadPreallocation string
+
+	UploadWriteRetries      int           // Retries of chunk writes failing with transient I/O errors; zero disables
+	UploadWriteRetryBackoff time.Duration // Wait before the first retry, doubling after each
+
+	WebhookURL          string        // Receives a JSON POST for each upload event; empty disables webhooks
+	WebhookEvents       []string      // Event types sent, e.g. "file_organized,upload_failed"; empty sends all
+	WebhookRetries      int           // Retries of failed deliveries; zero disables
+	WebhookRetryBackoff time.Duration // Wait before the first retry, doubling after each
+	WebhookTimeout      time.Duration // Per-attempt request timeout
+
+	MediaDirectoryListing bool
+
+	FrontendDir string // Built single-page frontend served at /; empty serves API info there
+
+	IntegrityBytesPerSecond int64 // Read throttle for integrity verification, maintenance and checksum rebuilds
+
+	MaintenanceInterval time.Duration // How often indexes are reconciled with files changed on disk; zero disables
+	MaintenanceMaxFiles int           // New files hashed per maintenance pass; zero is unlimited
+
+	EventGap time.Duration // Gap that starts a new event for event-based imports
+	BurstGap time.Duration // Shots this close together on import form a burst; zero disables
+
+	ImportPreserveDirs int // Trailing source folders kept under the date folder on import; zero flattens
+
+	DefaultTimezone string // IANA zone for filename and file-time dates, e.g. "Europe/London"
+
+	DedupMode       string // "full" or "fast"
+	DedupPreHashKiB int64
+	HashAlgorithm   string // "sha256", "sha512" or "crc64"; for dedup and the checksum index only
+
+	DedupPreferRicherMetadata bool // Replace a stored JPEG with an incoming copy that has more EXIF
+
+	FutureDates string // "reset" or "flag" (file under ClockError/)
+	MonthFormat string // "name" (March), "number" (03) or "number-name" (03-March)
+
+	ExtensionStyle string // "keep", "lower" (.JPG -> .jpg) or "canonical" (also .jpeg -> .jpg)
+
+	FileIDMode string // "path" (short hash of the path) or "content" (full SHA-256, stable across moves)
+
+	MaxFileNameLength int    // Longest stored file name, extension included
+	FileNameUnit      string // What MaxFileNameLength counts: "bytes" (ext4, APFS) or "utf16" (NTFS, exFAT)
+
+	QuarantineAfter int // Failed /api/media/quarantine checks before a corrupt JPEG moves to Quarantine/; zero disables
+
+	TrashRetention time.Duration // How long deleted files stay restorable in .trash/; zero deletes outright
+
+	IncludeHiddenFiles bool // Scan dotfiles and OS junk (._*, .DS_Store, Thumbs.db) as media
+
+	SeparateScreenshots bool // File screenshots and screen recordings under Screenshots/YYYY/Month
+
+	DocumentExtensions []string // Opt-in document types, e.g. ".pdf", dated from their metadata and filed like photos
+
+	FilenamePatterns []string // Extra filename date regexes, separated by semicolons since regexes use commas
+
+	FFprobePath string // ffprobe binary for video duration, size and dates; videos go without when it's missing
+
+	RequireDate bool // Refuse uploads that can only be dated by upload time until the user supplies a date
+
+	KeepNullIslandGPS bool // Trust EXIF GPS of exactly 0,0, which cameras without a fix often write
+
+	SessionStore string // "memory", "file" (saved beside the temp files, surviving restarts) or "redis"
+	RedisURL     string
+
+	ThumbnailSize    int // Longest edge in pixels
+	ThumbnailWorkers int // Concurrency of thumbnail pre-generation
+
+	MetadataCacheEntries int   // Files whose extracted metadata is kept in memory
+	ThumbnailCacheBytes  int64 // Disk space for cached thumbnails and their placeholders; zero is unlimited
 }
 
 func Load() *Config {
 	config := &Config{
-		Port:        getEnv("PORT", "8080"),
-		MediaPath:   getEnv("MEDIA_PATH", "./media"),
-		LogLevel:    getEnv("LOG_LEVEL", "info"),
-		CORSOrigins: getEnv("CORS_ORIGINS", "*"),
+		Port:            getEnv("PORT", "8080"),
+		MediaPath:       getEnv("MEDIA_PATH", "./media"),
+		CachePath:       getEnv("CACHE_PATH", "./cache"),
+		DataPath:        getEnv("DATA_PATH", "./data"),
+		ImportPath:      getEnv("IMPORT_PATH", "./import"),
+		ArchivePath:     getEnv("ARCHIVE_PATH", ""),
+		LogLevel:        getEnv("LOG_LEVEL", "info"),
+		CORSOrigins:     getEnv("CORS_ORIGINS", "*"),
+		AdminToken:      getEnv("ADMIN_TOKEN", ""),
+		DebugEndpoints:  GetEnvAsBool("DEBUG_ENDPOINTS", false),
+		OrganizeTimeout: GetEnvAsDuration("ORGANIZE_TIMEOUT", 5*time.Minute),
+
+		MetadataMaxKeys:        GetEnvAsInt("METADATA_MAX_KEYS", 32),
+		MetadataMaxKeyLength:   GetEnvAsInt("METADATA_MAX_KEY_LENGTH", 64),
+		MetadataMaxValueLength: GetEnvAsInt("METADATA_MAX_VALUE_LENGTH", 1024),
+		MetadataAllowedKeys:    GetEnvAsList("METADATA_ALLOWED_KEYS"),
+
+		ListScanLimit: GetEnvAsInt("LIST_SCAN_LIMIT", 10000),
+		MaxPageLimit:  GetEnvAsInt("MAX_PAGE_LIMIT", 1000),
+
+		MaxLibraryBytes: GetEnvAsInt64("MAX_LIBRARY_BYTES", 0),
+
+		SimpleUploadMaxBytes: GetEnvAsInt64("SIMPLE_UPLOAD_MAX_BYTES", 8<<20),
+
+		UploadChunkSize:    GetEnvAsInt64("UPLOAD_CHUNK_SIZE", 1<<20),
+		UploadMaxChunkSize: GetEnvAsInt64("UPLOAD_MAX_CHUNK_SIZE", 64<<20),
+		UploadMaxFileSize:  GetEnvAsInt64("UPLOAD_MAX_FILE_SIZE", 0),
+		MaxUploadSessions:  GetEnvAsInt("MAX_UPLOAD_SESSIONS", 10),
+
+		UploadSessionTTL:           GetEnvAsDuration("UPLOAD_SESSION_TTL", 24*time.Hour),
+		UploadPausedSessionTTL:     GetEnvAsDuration("UPLOAD_PAUSED_SESSION_TTL", 0),
+		UploadSessionSweepInterval: GetEnvAsDuration("UPLOAD_SESSION_SWEEP_INTERVAL", 10*time.Minute),
+
+		MaxHeavyRequests: GetEnvAsInt("MAX_HEAVY_REQUESTS", 4),
+
+		UploadSkipFullChecksum: GetEnvAsBool("UPLOAD_SKIP_FULL_CHECKSUM", false),
+
+		UploadPreallocation: getEnv("UPLOAD_PREALLOCATION", "sparse"),
+
+		UploadWriteRetries:      GetEnvAsInt("UPLOAD_WRITE_RETRIES", 3),
+		UploadWriteRetryBackoff: GetEnvAsDuration("UPLOAD_WRITE_RETRY_BACKOFF", 50*time.Millisecond),
+
+		WebhookURL:          getEnv("WEBHOOK_URL", ""),
+		WebhookEvents:       GetEnvAsList("WEBHOOK_EVENTS"),
+		WebhookRetries:      GetEnvAsInt("WEBHOOK_RETRIES", 3),
+		WebhookRetryBackoff: GetEnvAsDuration("WEBHOOK_RETRY_BACKOFF", time.Second),
+		WebhookTimeout:      GetEnvAsDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+
+		MediaDirectoryListing: GetEnvAsBool("MEDIA_DIRECTORY_LISTING", false),
+
+		FrontendDir: getEnv("FRONTEND_DIR", ""),
+
+		IntegrityBytesPerSecond: GetEnvAsInt64("INTEGRITY_BYTES_PER_SECOND", 64<<20),
+
+		MaintenanceInterval: GetEnvAsDuration("MAINTENANCE_INTERVAL", 6*time.Hour),
+		MaintenanceMaxFiles: GetEnvAsInt("MAINTENANCE_MAX_FILES", 1000),
+
+		EventGap: GetEnvAsDuration("EVENT_GAP", 6*time.Hour),
+		BurstGap: GetEnvAsDuration("BURST_GAP", 0),
+
+		ImportPreserveDirs: GetEnvAsInt("IMPORT_PRESERVE_DIRS", 0),
+
+		DefaultTimezone: getEnv("DEFAULT_TIMEZONE", "UTC"),
+
+		DedupMode:       getEnv("DEDUP_MODE", "full"),
+		DedupPreHashKiB: GetEnvAsInt64("DEDUP_PREHASH_KIB", 64),
+		HashAlgorithm:   getEnv("HASH_ALGO", "sha256"),
+
+		DedupPreferRicherMetadata: GetEnvAsBool("DEDUP_PREFER_RICHER_METADATA", false),
+
+		FutureDates: getEnv("FUTURE_DATES", "reset"),
+		MonthFormat: getEnv("MONTH_FORMAT", "name"),
+
+		ExtensionStyle: getEnv("EXTENSION_STYLE", "keep"),
+
+		FileIDMode: getEnv("FILE_ID_MODE", "path"),
+
+		MaxFileNameLength: GetEnvAsInt("MAX_FILENAME_LENGTH", 200),
+		FileNameUnit:      getEnv("FILENAME_LENGTH_UNIT", "bytes"),
+
+		QuarantineAfter: GetEnvAsInt("QUARANTINE_AFTER", 0),
+
+		TrashRetention: GetEnvAsDuration("TRASH_RETENTION", 30*24*time.Hour),
+
+		IncludeHiddenFiles: GetEnvAsBool("INCLUDE_HIDDEN_FILES", false),
+
+		SeparateScreenshots: GetEnvAsBool("SEPARATE_SCREENSHOTS", false),
+
+		DocumentExtensions: GetEnvAsList("DOCUMENT_EXTENSIONS"),
+
+		FilenamePatterns: GetEnvAsListSep("FILENAME_PATTERNS", ";"),
+
+		FFprobePath: getEnv("FFPROBE_PATH", "ffprobe"),
+
+		RequireDate: GetEnvAsBool("REQUIRE_DATE", false),
+
+		KeepNullIslandGPS: GetEnvAsBool("KEEP_NULL_ISLAND_GPS", false),
+
+		SessionStore: getEnv("SESSION_STORE", "memory"),
+		RedisURL:     getEnv("REDIS_URL", "redis://localhost:6379/0"),
+
+		ThumbnailSize:    GetEnvAsInt("THUMBNAIL_SIZE", 320),
+		ThumbnailWorkers: GetEnvAsInt("THUMBNAIL_WORKERS", 4),
+
+		MetadataCacheEntries: GetEnvAsInt("METADATA_CACHE_ENTRIES", 100000),
+		ThumbnailCacheBytes:  GetEnvAsInt64("THUMBNAIL_CACHE_BYTES", 2<<30),
 	}
 
 	var logLevel slog.Level
@@ -43,7 +255,13 @@ func Load() *Config {
 	slog.Info("Configuration loaded",
 		"port", config.Port,
 		"media_path", config.MediaPath,
+		"cache_path", config.CachePath,
+		"data_path", config.DataPath,
+		"archive_path", config.ArchivePath,
 		"log_level", config.LogLevel,
+		"organize_timeout", config.OrganizeTimeout,
+		"default_timezone", config.DefaultTimezone,
+		"session_store", config.SessionStore,
 	)
 
 	return config
@@ -64,3 +282,46 @@ func GetEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func GetEnvAsInt64(key string, defaultValue int64) int64 {
+	if value := os.Getenv(key); value != "" {
+		if intValue, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return intValue
+		}
+	}
+	return defaultValue
+}
+
+func GetEnvAsBool(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
+
+func GetEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if duration, err := time.ParseDuration(value); err == nil {
+			return duration
+		}
+	}
+	return defaultValue
+}
+
+// GetEnvAsList splits a comma-separated variable, dropping empty entries.
+func GetEnvAsList(key string) []string {
+	return GetEnvAsListSep(key, ",")
+}
+
+// GetEnvAsListSep splits a variable on sep, dropping empty entries.
+func GetEnvAsListSep(key, sep string) []string {
+	var values []string
+	for _, value := range strings.Split(os.Getenv(key), sep) {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
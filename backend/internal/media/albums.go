@@ -0,0 +1,28 @@
+package media
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// AlbumsFolder holds user-named albums that files are placed in by hand
+// rather than by date.
+const AlbumsFolder = "Albums"
+
+// AlbumFolder returns the library-relative folder for the album name, which
+// must be a single folder name.
+func AlbumFolder(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("%w: invalid album name %q", ErrInvalidPath, name)
+	}
+	return filepath.Join(AlbumsFolder, name), nil
+}
+
+// MonthFolder returns the library-relative Year/Month folder for a date under
+// the configured month format, the folder date-based organizing would use.
+func (o *Organizer) MonthFolder(year int, month time.Month) string {
+	return filepath.Join(fmt.Sprintf("%04d", year), o.monthFormat.FolderName(month))
+}
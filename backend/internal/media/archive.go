@@ -0,0 +1,48 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// archiveOriginal keeps a verbatim copy of an organized file under
+// archivePath/YYYY/MM-DD by upload date, independent of the date it was
+// organized by. A hard link is used when the archive shares the library's
+// volume, so the copy costs no space; files are replaced rather than edited in
+// place, which keeps the two names from diverging.
+func (o *Organizer) archiveOriginal(libraryPath, fileName string, uploadedAt time.Time) (string, error) {
+	uploadedAt = uploadedAt.In(o.extractor.location)
+	archiveDir := filepath.Join(o.archivePath, uploadedAt.Format("2006"), uploadedAt.Format("01-02"))
+	if err := os.MkdirAll(archiveDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	basePath := filepath.Join(archiveDir, o.sanitizeFileName(fileName))
+
+	// Link fails with EEXIST instead of replacing, so it claims names as
+	// safely as claimFinalPath does
+	for counter := 0; ; counter++ {
+		archivePath := numberedPath(basePath, counter)
+		err := os.Link(libraryPath, archivePath)
+		if err == nil {
+			return archivePath, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			break // Different volume or no link support
+		}
+	}
+
+	archivePath, err := o.claimFinalPath(basePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to archive original: %w", err)
+	}
+	if err := copyFile(libraryPath, archivePath); err != nil {
+		os.Remove(archivePath)
+		return "", fmt.Errorf("failed to archive original: %w", err)
+	}
+	return archivePath, nil
+}
@@ -0,0 +1,90 @@
+package media
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncodeBlurhash(t *testing.T) {
+	gradient := image.NewRGBA(image.Rect(0, 0, 120, 80))
+	for y := 0; y < 80; y++ {
+		for x := 0; x < 120; x++ {
+			gradient.Set(x, y, color.RGBA{R: uint8(x * 2), G: uint8(y * 3), B: 90, A: 255})
+		}
+	}
+
+	hash := encodeBlurhash(gradient, blurhashXComponents, blurhashYComponents)
+	if len(hash) != 28 {
+		t.Fatalf("Expected a 28 character hash, got %q (%d)", hash, len(hash))
+	}
+	for _, r := range hash {
+		if !strings.ContainsRune(blurhashAlphabet, r) {
+			t.Fatalf("Unexpected character %q in hash %q", r, hash)
+		}
+	}
+	// 4x3 components encode as size flag 3 + 2*9 = 21
+	if hash[0] != 'L' {
+		t.Errorf("Expected size flag L, got %c", hash[0])
+	}
+	if again := encodeBlurhash(gradient, blurhashXComponents, blurhashYComponents); again != hash {
+		t.Errorf("Expected a deterministic hash, got %q then %q", hash, again)
+	}
+
+	// A flat color has no AC energy: maximum 0 and mid-point components
+	flat := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.RGBA{R: 255, A: 255}), image.Point{}, draw.Src)
+	flatHash := encodeBlurhash(flat, 1, 1)
+	if flatHash != "00"+encodeBase83(255<<16, 4) {
+		t.Errorf("Expected flat red hash, got %q", flatHash)
+	}
+}
+
+func TestScanFilesIncludesBlurhash(t *testing.T) {
+	mediaDir := t.TempDir()
+	thumbnailer := NewThumbnailer(t.TempDir(), 64, nil)
+	organizer := NewOrganizerWithOptions(mediaDir, OrganizerOptions{Thumbnailer: thumbnailer})
+
+	writeTestImage(t, filepath.Join(mediaDir, "2024", "03", "photo.jpg"), 200, 100)
+
+	files, err := organizer.ScanFiles("", "", 10, 0)
+	if err != nil {
+		t.Fatalf("ScanFiles failed: %v", err)
+	}
+	if len(files) != 1 || files[0].Blurhash != "" {
+		t.Fatalf("Expected no placeholder before thumbnails exist, got %+v", files)
+	}
+
+	if _, err := organizer.PregenerateThumbnails(context.Background(), thumbnailer, PregenerateOptions{Workers: 1}); err != nil {
+		t.Fatalf("PregenerateThumbnails failed: %v", err)
+	}
+
+	files, err = organizer.ScanFiles("", "", 10, 0)
+	if err != nil {
+		t.Fatalf("ScanFiles failed: %v", err)
+	}
+	if len(files) != 1 || len(files[0].Blurhash) != 28 {
+		t.Fatalf("Expected a 28 character placeholder, got %+v", files)
+	}
+
+	// Later listings answer from the metadata index, not the sidecar
+	cachedPath, _, err := thumbnailer.CachedPath(filepath.Join(mediaDir, "2024", "03", "photo.jpg"))
+	if err != nil {
+		t.Fatalf("CachedPath failed: %v", err)
+	}
+	if err := os.Remove(blurhashPath(cachedPath)); err != nil {
+		t.Fatalf("Failed to remove blurhash sidecar: %v", err)
+	}
+	again, err := organizer.ScanFiles("", "", 10, 0)
+	if err != nil {
+		t.Fatalf("ScanFiles failed: %v", err)
+	}
+	if len(again) != 1 || again[0].Blurhash != files[0].Blurhash {
+		t.Errorf("Expected the indexed placeholder %q, got %+v", files[0].Blurhash, again)
+	}
+}
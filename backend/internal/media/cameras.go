@@ -0,0 +1,120 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type CameraCount struct {
+	Make  string `json:"make,omitempty"`
+	Model string `json:"model,omitempty"`
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type LensCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type CameraStats struct {
+	Cameras []CameraCount `json:"cameras"`
+	Lenses  []LensCount   `json:"lenses"`
+}
+
+// CameraStats counts library photos per camera and per lens, most used first.
+// Metadata comes from the metadata index, so only new or changed files are
+// read from disk.
+func (o *Organizer) CameraStats(ctx context.Context) (*CameraStats, error) {
+	cameras := make(map[string]*CameraCount)
+	lenses := make(map[string]int)
+
+	err := filepath.Walk(o.mediaPath, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if o.skipDir(path, o.mediaPath) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !o.isMediaFile(path) {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(o.mediaPath, path)
+		if err != nil {
+			return nil
+		}
+
+		mediaInfo, err := o.metadataFor(path, relPath, info)
+		if err != nil || mediaInfo.Camera == nil {
+			return nil
+		}
+
+		camera := mediaInfo.Camera
+		if name := cameraName(camera.Make, camera.Model); name != "" {
+			count, ok := cameras[name]
+			if !ok {
+				count = &CameraCount{Make: camera.Make, Model: camera.Model, Name: name}
+				cameras[name] = count
+			}
+			count.Count++
+		}
+		if camera.LensModel != "" {
+			lenses[camera.LensModel]++
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect camera statistics: %w", err)
+	}
+
+	stats := &CameraStats{
+		Cameras: make([]CameraCount, 0, len(cameras)),
+		Lenses:  make([]LensCount, 0, len(lenses)),
+	}
+	for _, count := range cameras {
+		stats.Cameras = append(stats.Cameras, *count)
+	}
+	for name, count := range lenses {
+		stats.Lenses = append(stats.Lenses, LensCount{Name: name, Count: count})
+	}
+
+	sort.Slice(stats.Cameras, func(i, j int) bool {
+		if stats.Cameras[i].Count != stats.Cameras[j].Count {
+			return stats.Cameras[i].Count > stats.Cameras[j].Count
+		}
+		return stats.Cameras[i].Name < stats.Cameras[j].Name
+	})
+	sort.Slice(stats.Lenses, func(i, j int) bool {
+		if stats.Lenses[i].Count != stats.Lenses[j].Count {
+			return stats.Lenses[i].Count > stats.Lenses[j].Count
+		}
+		return stats.Lenses[i].Name < stats.Lenses[j].Name
+	})
+
+	return stats, nil
+}
+
+// cameraName joins make and model, dropping the make when the model already
+// starts with it ("Canon" + "Canon EOS R5").
+func cameraName(cameraMake, model string) string {
+	switch {
+	case model == "":
+		return cameraMake
+	case cameraMake == "" || strings.HasPrefix(strings.ToLower(model), strings.ToLower(cameraMake)):
+		return model
+	default:
+		return cameraMake + " " + model
+	}
+}
@@ -0,0 +1,63 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCameraStats(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+
+	monthDir := filepath.Join(mediaDir, "2024", "March")
+	if err := os.MkdirAll(monthDir, 0755); err != nil {
+		t.Fatalf("Failed to create month directory: %v", err)
+	}
+
+	photos := []exifFixture{
+		{Make: "Canon", Model: "Canon EOS R5", LensModel: "RF24-105mm F4 L IS USM"},
+		{Make: "Canon", Model: "Canon EOS R5", LensModel: "RF24-105mm F4 L IS USM"},
+		{Make: "Canon", Model: "Canon EOS R5", LensModel: "RF50mm F1.8 STM"},
+		{Make: "Apple", Model: "iPhone 15 Pro"},
+	}
+	for i, fixture := range photos {
+		name := filepath.Join(monthDir, fmt.Sprintf("photo_%d.jpg", i))
+		if err := os.WriteFile(name, buildEXIFJPEG(t, fixture), 0644); err != nil {
+			t.Fatalf("Failed to create photo: %v", err)
+		}
+	}
+
+	stats, err := organizer.CameraStats(context.Background())
+	if err != nil {
+		t.Fatalf("CameraStats failed: %v", err)
+	}
+
+	expectedCameras := []CameraCount{
+		{Make: "Canon", Model: "Canon EOS R5", Name: "Canon EOS R5", Count: 3},
+		{Make: "Apple", Model: "iPhone 15 Pro", Name: "Apple iPhone 15 Pro", Count: 1},
+	}
+	if len(stats.Cameras) != len(expectedCameras) {
+		t.Fatalf("Expected %d cameras, got %+v", len(expectedCameras), stats.Cameras)
+	}
+	for i, expected := range expectedCameras {
+		if stats.Cameras[i] != expected {
+			t.Errorf("Expected camera %d to be %+v, got %+v", i, expected, stats.Cameras[i])
+		}
+	}
+
+	expectedLenses := []LensCount{
+		{Name: "RF24-105mm F4 L IS USM", Count: 2},
+		{Name: "RF50mm F1.8 STM", Count: 1},
+	}
+	if len(stats.Lenses) != len(expectedLenses) {
+		t.Fatalf("Expected %d lenses, got %+v", len(expectedLenses), stats.Lenses)
+	}
+	for i, expected := range expectedLenses {
+		if stats.Lenses[i] != expected {
+			t.Errorf("Expected lens %d to be %+v, got %+v", i, expected, stats.Lenses[i])
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// placeholderDescriptions are ImageDescription values cameras write on every
+// shot; they say nothing about the photo and would match every search.
+var placeholderDescriptions = map[string]bool{
+	"OLYMPUS DIGITAL CAMERA":        true,
+	"SAMSUNG DIGITAL CAMERA":        true,
+	"KONICA MINOLTA DIGITAL CAMERA": true,
+	"MINOLTA DIGITAL CAMERA":        true,
+	"DIGITAL CAMERA":                true,
+}
+
+// exifCaption returns the user caption from ImageDescription and UserComment,
+// joined when both are set and differ.
+func exifCaption(x *exif.Exif) string {
+	var parts []string
+
+	if tag, err := x.Get(exif.ImageDescription); err == nil {
+		if s, err := tag.StringVal(); err == nil {
+			if s = cleanCaption(s); s != "" && !placeholderDescriptions[strings.ToUpper(s)] {
+				parts = append(parts, s)
+			}
+		}
+	}
+
+	if tag, err := x.Get(exif.UserComment); err == nil {
+		if s := decodeUserComment(tag.Val, x.Tiff.Order); s != "" && (len(parts) == 0 || s != parts[0]) {
+			parts = append(parts, s)
+		}
+	}
+
+	return strings.Join(parts, "\n")
+}
+
+// decodeUserComment decodes the UNDEFINED-typed UserComment tag, whose first
+// eight bytes name the character code. Writers disagree on UCS-2 byte order,
+// so a byte-order mark or the position of zero bytes wins over the file's
+// TIFF byte order.
+func decodeUserComment(raw []byte, order binary.ByteOrder) string {
+	if len(raw) < 8 {
+		return ""
+	}
+	code, body := string(bytes.TrimRight(raw[:8], "\x00 ")), raw[8:]
+
+	switch code {
+	case "UNICODE":
+		return cleanCaption(decodeUCS2(body, order))
+	case "ASCII", "":
+		// Undefined-code comments are UTF-8 in practice; anything else is
+		// unreadable without guessing a legacy encoding.
+		if !utf8.Valid(body) {
+			return ""
+		}
+		return cleanCaption(string(body))
+	default: // JIS and unknown codes
+		return ""
+	}
+}
+
+func decodeUCS2(body []byte, order binary.ByteOrder) string {
+	if len(body) >= 2 {
+		switch {
+		case body[0] == 0xFE && body[1] == 0xFF:
+			order, body = binary.BigEndian, body[2:]
+		case body[0] == 0xFF && body[1] == 0xFE:
+			order, body = binary.LittleEndian, body[2:]
+		default:
+			// Latin text has a zero high byte in every unit; whichever half
+			// of the pairs holds the zeros reveals the real order.
+			var evenZeros, oddZeros int
+			for i := 0; i+1 < len(body); i += 2 {
+				if body[i] == 0 {
+					evenZeros++
+				}
+				if body[i+1] == 0 {
+					oddZeros++
+				}
+			}
+			if evenZeros > oddZeros {
+				order = binary.BigEndian
+			} else if oddZeros > evenZeros {
+				order = binary.LittleEndian
+			}
+		}
+	}
+
+	units := make([]uint16, 0, len(body)/2)
+	for i := 0; i+1 < len(body); i += 2 {
+		units = append(units, order.Uint16(body[i:]))
+	}
+	return string(utf16.Decode(units))
+}
+
+// cleanCaption drops everything from the first NUL and trims the space
+// padding many writers fill fixed-size fields with.
+func cleanCaption(s string) string {
+	s, _, _ = strings.Cut(s, "\x00")
+	return strings.TrimSpace(s)
+}
@@ -0,0 +1,131 @@
+package media
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// CaptureInfo summarizes how a photo was taken.
+type CaptureInfo struct {
+	RelativePath string      `json:"relativePath"`
+	Summary      string      `json:"summary"` // e.g. "Canon EOS R5, 50mm, f/1.8, 1/200, ISO 100"; empty without EXIF
+	Camera       *CameraInfo `json:"camera,omitempty"`
+}
+
+// CaptureInfo returns the capture settings of a library file, read through
+// the metadata index.
+func (o *Organizer) CaptureInfo(relPath string) (*CaptureInfo, error) {
+	fullPath, err := o.ResolvePath(relPath)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := os.Stat(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	if stat.IsDir() {
+		return nil, os.ErrNotExist
+	}
+
+	rel, err := filepath.Rel(o.mediaPath, fullPath)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := o.metadataFor(fullPath, rel, stat)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CaptureInfo{
+		RelativePath: filepath.ToSlash(rel),
+		Summary:      CaptureSummary(info.Camera),
+		Camera:       info.Camera,
+	}, nil
+}
+
+// CaptureSummary formats the camera and exposure settings photographers quote,
+// "Canon EOS R5, 50mm, f/1.8, 1/200, ISO 100", leaving out whatever the file
+// didn't record.
+func CaptureSummary(camera *CameraInfo) string {
+	if camera == nil {
+		return ""
+	}
+
+	var parts []string
+	for _, part := range []string{
+		cameraName(camera.Make, camera.Model),
+		camera.FocalLength,
+		camera.Aperture,
+		camera.ShutterSpeed,
+	} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if camera.ISO != "" {
+		parts = append(parts, "ISO "+camera.ISO)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// readExposureFields fills in the exposure settings EXIF records alongside
+// the camera.
+func readExposureFields(x *exif.Exif, camera *CameraInfo) {
+	if num, den, ok := exifRational(x, exif.FocalLength); ok && num > 0 {
+		camera.FocalLength = formatDecimal(float64(num)/float64(den)) + "mm"
+	}
+	if num, den, ok := exifRational(x, exif.FNumber); ok && num > 0 {
+		camera.Aperture = "f/" + formatDecimal(float64(num)/float64(den))
+	}
+	if num, den, ok := exifRational(x, exif.ExposureTime); ok && num > 0 {
+		camera.ShutterSpeed = formatExposureTime(num, den)
+	}
+	if tag, err := x.Get(exif.ISOSpeedRatings); err == nil {
+		if iso, err := tag.Int(0); err == nil && iso > 0 {
+			camera.ISO = strconv.Itoa(iso)
+		}
+	}
+	if tag, err := x.Get(exif.Flash); err == nil {
+		if flash, err := tag.Int(0); err == nil {
+			// Bit 0 says whether it fired; the rest describe the mode
+			camera.Flash = "off"
+			if flash&1 == 1 {
+				camera.Flash = "fired"
+			}
+		}
+	}
+}
+
+func exifRational(x *exif.Exif, name exif.FieldName) (int64, int64, bool) {
+	tag, err := x.Get(name)
+	if err != nil {
+		return 0, 0, false
+	}
+	num, den, err := tag.Rat2(0)
+	if err != nil || den == 0 {
+		return 0, 0, false
+	}
+	return num, den, true
+}
+
+// formatExposureTime writes sub-second exposures as the fraction cameras
+// display, 1/200, and longer ones in seconds, 2s or 1.5s.
+func formatExposureTime(num, den int64) string {
+	if num >= den {
+		return formatDecimal(float64(num)/float64(den)) + "s"
+	}
+	// Cameras store 10/2000 as often as 1/200
+	return fmt.Sprintf("1/%s", formatDecimal(float64(den)/float64(num)))
+}
+
+// formatDecimal prints at most one decimal place, dropping a trailing ".0".
+func formatDecimal(value float64) string {
+	return strconv.FormatFloat(float64(int64(value*10+0.5))/10, 'f', -1, 64)
+}
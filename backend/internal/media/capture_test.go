@@ -0,0 +1,67 @@
+package media
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCaptureInfoSummary(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+
+	relPath := filepath.Join("2024", "March", "IMG_0001.jpg")
+	if err := os.MkdirAll(filepath.Join(mediaDir, "2024", "March"), 0755); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+	data := buildEXIFJPEG(t, exifFixture{
+		Make:         "Canon",
+		Model:        "Canon EOS R5",
+		LensModel:    "RF50mm F1.8 STM",
+		FocalLength:  [2]uint32{50, 1},
+		FNumber:      [2]uint32{18, 10},
+		ExposureTime: [2]uint32{10, 2000},
+		ISO:          100,
+		Flash:        16, // Off, compulsory suppression
+	})
+	if err := os.WriteFile(filepath.Join(mediaDir, relPath), data, 0644); err != nil {
+		t.Fatalf("Failed to write photo: %v", err)
+	}
+
+	info, err := organizer.CaptureInfo(relPath)
+	if err != nil {
+		t.Fatalf("CaptureInfo failed: %v", err)
+	}
+
+	if expected := "Canon EOS R5, 50mm, f/1.8, 1/200, ISO 100"; info.Summary != expected {
+		t.Errorf("Expected summary %q, got %q", expected, info.Summary)
+	}
+	camera := info.Camera
+	if camera == nil || camera.LensModel != "RF50mm F1.8 STM" || camera.Flash != "off" || camera.ShutterSpeed != "1/200" {
+		t.Errorf("Expected structured camera info, got %+v", camera)
+	}
+	if info.RelativePath != filepath.ToSlash(relPath) {
+		t.Errorf("Expected path %s, got %s", filepath.ToSlash(relPath), info.RelativePath)
+	}
+}
+
+func TestCaptureSummaryPartial(t *testing.T) {
+	tests := []struct {
+		name     string
+		camera   *CameraInfo
+		expected string
+	}{
+		{"No EXIF", nil, ""},
+		{"Camera only", &CameraInfo{Make: "Apple", Model: "iPhone 15"}, "Apple iPhone 15"},
+		{"Exposure only", &CameraInfo{Aperture: "f/2.8", ISO: "400"}, "f/2.8, ISO 400"},
+		{"Long exposure", &CameraInfo{Model: "X100V", ShutterSpeed: formatExposureTime(3, 2)}, "X100V, 1.5s"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if summary := CaptureSummary(test.camera); summary != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, summary)
+			}
+		})
+	}
+}
@@ -0,0 +1,448 @@
+package media
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChecksumIndex records the hash of every file at the time it entered the
+// library, keyed by slash-separated library-relative path, along with the
+// algorithm that produced them. It also maps paths to the content-based file
+// IDs handed out in FileIDContent mode, to the capture dates users supplied
+// and, for PreferRicherMetadata, to the hashes of JPEGs' image data without
+// their metadata. Trashed and quarantined files keep their entries under their
+// new paths so their IDs survive a restore, but are never offered as
+// duplicates or listed by Paths. With an empty path the index lives in memory
+// only.
+type ChecksumIndex struct {
+	path      string
+	algorithm HashAlgorithm
+	stale     bool // Entries were dropped because they used another algorithm
+	mutex     sync.RWMutex
+	entries   map[string]string
+	ids       map[string]string    // Path to file ID
+	idPaths   map[string]string    // File ID to path
+	images    map[string]string    // Path to JPEG image hash, see jpegContentHash
+	dates     map[string]time.Time // Path to user-supplied capture date
+
+	saveMutex    sync.Mutex    // Serializes writes of the index file
+	saveDelay    time.Duration // How long SaveSoon waits; zero saves at once
+	pendingMutex sync.Mutex
+	pending      *time.Timer // Scheduled by SaveSoon; nil when nothing is pending
+}
+
+// checksumIndexFile is the stored form of the index. Indexes saved before the
+// algorithm was recorded are a bare map of SHA-256 checksums.
+type checksumIndexFile struct {
+	Algorithm HashAlgorithm        `json:"algorithm"`
+	Entries   map[string]string    `json:"entries"`
+	IDs       map[string]string    `json:"ids,omitempty"`
+	Images    map[string]string    `json:"images,omitempty"`
+	Dates     map[string]time.Time `json:"dates,omitempty"`
+}
+
+// LoadChecksumIndex reads the index stored at path for checksums made with
+// algorithm; a missing file yields an empty index. Checksums stored with a
+// different algorithm can't be compared, so they are dropped and the index is
+// marked stale until RebuildChecksumIndex fills it again.
+func LoadChecksumIndex(path string, algorithm HashAlgorithm) (*ChecksumIndex, error) {
+	index := &ChecksumIndex{
+		path:      path,
+		algorithm: algorithm,
+		entries:   make(map[string]string),
+		ids:       make(map[string]string),
+		idPaths:   make(map[string]string),
+		images:    make(map[string]string),
+		dates:     make(map[string]time.Time),
+	}
+	if path == "" {
+		return index, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return index, nil
+	}
+	if err != nil {
+		return index, fmt.Errorf("failed to read checksum index: %w", err)
+	}
+
+	var stored checksumIndexFile
+	if err := json.Unmarshal(data, &stored); err != nil || stored.Algorithm == "" {
+		stored = checksumIndexFile{Algorithm: HashSHA256}
+		if err := json.Unmarshal(data, &stored.Entries); err != nil {
+			return index, fmt.Errorf("failed to parse checksum index: %w", err)
+		}
+	}
+
+	// File IDs and image hashes are always SHA-256 based, and dates aren't
+	// hashes at all, so they survive an algorithm change.
+	for relPath, id := range stored.IDs {
+		index.ids[relPath] = id
+		index.idPaths[id] = relPath
+	}
+	if stored.Images != nil {
+		index.images = stored.Images
+	}
+	if stored.Dates != nil {
+		index.dates = stored.Dates
+	}
+
+	if stored.Algorithm != algorithm {
+		slog.Info("Checksum index uses another hash algorithm, rebuilding",
+			"stored", stored.Algorithm, "configured", algorithm, "entries", len(stored.Entries))
+		index.stale = true
+		return index, nil
+	}
+	if stored.Entries != nil {
+		index.entries = stored.Entries
+	}
+
+	return index, nil
+}
+
+// Algorithm returns the hash algorithm the index's checksums were made with.
+func (c *ChecksumIndex) Algorithm() HashAlgorithm {
+	return c.algorithm
+}
+
+func (c *ChecksumIndex) Get(relPath string) (string, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	checksum, ok := c.entries[filepath.ToSlash(relPath)]
+	return checksum, ok
+}
+
+func (c *ChecksumIndex) Set(relPath, checksum string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.entries[filepath.ToSlash(relPath)] = checksum
+}
+
+func (c *ChecksumIndex) Delete(relPath string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	relPath = filepath.ToSlash(relPath)
+	delete(c.entries, relPath)
+	delete(c.images, relPath)
+	delete(c.dates, relPath)
+	if id, ok := c.ids[relPath]; ok {
+		delete(c.ids, relPath)
+		delete(c.idPaths, id)
+	}
+}
+
+// Move re-keys the checksum, image hash, user date and file ID of a file that
+// moved within the library, so its ID and date stay the same.
+func (c *ChecksumIndex) Move(fromRel, toRel string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	fromRel, toRel = filepath.ToSlash(fromRel), filepath.ToSlash(toRel)
+	if checksum, ok := c.entries[fromRel]; ok {
+		delete(c.entries, fromRel)
+		c.entries[toRel] = checksum
+	}
+	if image, ok := c.images[fromRel]; ok {
+		delete(c.images, fromRel)
+		c.images[toRel] = image
+	}
+	if date, ok := c.dates[fromRel]; ok {
+		delete(c.dates, fromRel)
+		c.dates[toRel] = date
+	}
+	if id, ok := c.ids[fromRel]; ok {
+		delete(c.ids, fromRel)
+		c.ids[toRel] = id
+		c.idPaths[id] = toRel
+	}
+}
+
+// ID returns the file ID assigned to relPath.
+func (c *ChecksumIndex) ID(relPath string) (string, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	id, ok := c.ids[filepath.ToSlash(relPath)]
+	return id, ok
+}
+
+// PathForID returns the path of the file assigned id.
+func (c *ChecksumIndex) PathForID(id string) (string, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	relPath, ok := c.idPaths[strings.ToLower(id)]
+	return relPath, ok
+}
+
+// AssignID gives relPath a file ID derived from digest, the hex SHA-256 of
+// its content, and returns it; a path that already has one keeps it. The
+// digest itself is the ID unless another file holds it, as identical copies
+// would, in which case the ID is a hash of the digest and the path.
+func (c *ChecksumIndex) AssignID(relPath, digest string) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	relPath = filepath.ToSlash(relPath)
+	if id, ok := c.ids[relPath]; ok {
+		return id
+	}
+
+	id := strings.ToLower(digest)
+	for n := 0; ; n++ {
+		if _, taken := c.idPaths[id]; !taken {
+			break
+		}
+		sum := sha256.Sum256(fmt.Appendf(nil, "%s\x00%s\x00%d", digest, relPath, n))
+		id = hex.EncodeToString(sum[:])
+	}
+	c.ids[relPath] = id
+	c.idPaths[id] = relPath
+	return id
+}
+
+// DeleteUnder drops the entries of every file under folder, as when a trash
+// batch is purged.
+func (c *ChecksumIndex) DeleteUnder(folder string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	prefix := strings.Trim(filepath.ToSlash(folder), "/") + "/"
+	for relPath := range c.entries {
+		if strings.HasPrefix(relPath, prefix) {
+			delete(c.entries, relPath)
+		}
+	}
+	for relPath := range c.images {
+		if strings.HasPrefix(relPath, prefix) {
+			delete(c.images, relPath)
+		}
+	}
+	for relPath := range c.dates {
+		if strings.HasPrefix(relPath, prefix) {
+			delete(c.dates, relPath)
+		}
+	}
+	for relPath, id := range c.ids {
+		if strings.HasPrefix(relPath, prefix) {
+			delete(c.ids, relPath)
+			delete(c.idPaths, id)
+		}
+	}
+}
+
+// ImageHash returns the image hash recorded for relPath.
+func (c *ChecksumIndex) ImageHash(relPath string) (string, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	hash, ok := c.images[filepath.ToSlash(relPath)]
+	return hash, ok
+}
+
+// SetImageHash records the image hash of the JPEG at relPath.
+func (c *ChecksumIndex) SetImageHash(relPath, hash string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.images[filepath.ToSlash(relPath)] = hash
+}
+
+// UserDate returns the capture date a user supplied for relPath.
+func (c *ChecksumIndex) UserDate(relPath string) (time.Time, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	date, ok := c.dates[filepath.ToSlash(relPath)]
+	return date, ok
+}
+
+// SetUserDate records the capture date a user supplied for relPath, which
+// outranks whatever the file itself says when it is dated again.
+func (c *ChecksumIndex) SetUserDate(relPath string, date time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.dates[filepath.ToSlash(relPath)] = date
+}
+
+// FindImage returns the path of an indexed library file under folder whose
+// image hash is hash.
+func (c *ChecksumIndex) FindImage(hash, folder string) (string, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	prefix := strings.Trim(filepath.ToSlash(folder), "/") + "/"
+	for relPath, stored := range c.images {
+		if stored == hash && inLibrary(relPath) && strings.HasPrefix(relPath, prefix) {
+			return relPath, true
+		}
+	}
+	return "", false
+}
+
+// inLibrary reports whether an indexed path is a library file rather than one
+// held in the trash or quarantine.
+func inLibrary(relPath string) bool {
+	top, _, _ := strings.Cut(relPath, "/")
+	return top != TrashFolder && top != QuarantineFolder
+}
+
+// Find returns the path of an indexed library file with the given checksum.
+func (c *ChecksumIndex) Find(checksum string) (string, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	for relPath, stored := range c.entries {
+		if inLibrary(relPath) && strings.EqualFold(stored, checksum) {
+			return relPath, true
+		}
+	}
+	return "", false
+}
+
+// FindAll looks up many checksums in one pass over the index, returning the
+// path of an indexed library file for each one found, keyed by checksum as
+// given.
+func (c *ChecksumIndex) FindAll(checksums []string) map[string]string {
+	wanted := make(map[string][]string, len(checksums))
+	for _, checksum := range checksums {
+		key := strings.ToLower(checksum)
+		wanted[key] = append(wanted[key], checksum)
+	}
+
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	found := make(map[string]string)
+	for relPath, stored := range c.entries {
+		if !inLibrary(relPath) {
+			continue
+		}
+		for _, checksum := range wanted[strings.ToLower(stored)] {
+			if existing, ok := found[checksum]; !ok || relPath < existing {
+				found[checksum] = relPath
+			}
+		}
+	}
+	return found
+}
+
+// Paths returns the indexed library paths under folder (all of them when
+// empty), sorted.
+func (c *ChecksumIndex) Paths(folder string) []string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	prefix := strings.Trim(filepath.ToSlash(folder), "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	var paths []string
+	for relPath := range c.entries {
+		if inLibrary(relPath) && strings.HasPrefix(relPath, prefix) {
+			paths = append(paths, relPath)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// DefaultChecksumSaveDelay is how long organizing waits to write the checksum
+// index, collecting the uploads that finish meanwhile into one write.
+const DefaultChecksumSaveDelay = 2 * time.Second
+
+// SaveSoon schedules a Save after the index's save delay, so a burst of
+// changes, such as a batch of uploads, is written once instead of once per
+// file. Without a delay it saves at once. Call Flush before exiting so a
+// pending save isn't lost.
+func (c *ChecksumIndex) SaveSoon() {
+	if c.saveDelay <= 0 {
+		if err := c.Save(); err != nil {
+			slog.Error("Failed to save checksum index", "error", err)
+		}
+		return
+	}
+
+	c.pendingMutex.Lock()
+	defer c.pendingMutex.Unlock()
+	if c.pending == nil {
+		c.pending = time.AfterFunc(c.saveDelay, func() {
+			c.pendingMutex.Lock()
+			c.pending = nil
+			c.pendingMutex.Unlock()
+
+			if err := c.Save(); err != nil {
+				slog.Error("Failed to save checksum index", "error", err)
+			}
+		})
+	}
+}
+
+// Flush writes a save SaveSoon scheduled without waiting for its delay.
+func (c *ChecksumIndex) Flush() error {
+	c.pendingMutex.Lock()
+	pending := c.pending
+	c.pending = nil
+	c.pendingMutex.Unlock()
+
+	if pending == nil {
+		return nil
+	}
+	// A timer that already fired is saving too; saves are serialized, so
+	// whichever runs second writes the latest state
+	pending.Stop()
+	return c.Save()
+}
+
+// Save writes the index atomically so a crash never leaves it half-written.
+func (c *ChecksumIndex) Save() error {
+	if c.path == "" {
+		return nil
+	}
+
+	c.saveMutex.Lock()
+	defer c.saveMutex.Unlock()
+
+	c.mutex.RLock()
+	data, err := json.Marshal(checksumIndexFile{Algorithm: c.algorithm, Entries: c.entries, IDs: c.ids, Images: c.images, Dates: c.dates})
+	c.mutex.RUnlock()
+	if err != nil {
+		return fmt.Errorf("failed to encode checksum index: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return fmt.Errorf("failed to create checksum index directory: %w", err)
+	}
+
+	tmpPath := c.path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("failed to write checksum index: %w", err)
+	}
+	if err := os.Rename(tmpPath, c.path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace checksum index: %w", err)
+	}
+
+	return nil
+}
+
+// FlushChecksums writes checksum index changes still waiting out
+// ChecksumSaveDelay. Call it before exiting.
+func (o *Organizer) FlushChecksums() error {
+	return o.checksums.Flush()
+}
@@ -0,0 +1,36 @@
+package media
+
+import (
+	"fmt"
+	"image"
+)
+
+// dominantColorSampleSize bounds the image averaged for its dominant color;
+// a handful of pixels already settles the mean.
+const dominantColorSampleSize = 16
+
+// encodeDominantColor returns the average color of img as "#rrggbb", for the
+// gallery to tint a tile while the image loads. Pixels are averaged in linear
+// light, as the eye mixes them, so a half black, half white image comes out
+// a light grey rather than #808080.
+func encodeDominantColor(img image.Image) string {
+	img = scaleToFit(img, dominantColorSampleSize)
+	bounds := img.Bounds()
+	pixels := bounds.Dx() * bounds.Dy()
+	if pixels == 0 {
+		return ""
+	}
+
+	var sum [3]float64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			r, g, b, _ := img.At(x, y).RGBA()
+			sum[0] += srgbToLinear(int(r >> 8))
+			sum[1] += srgbToLinear(int(g >> 8))
+			sum[2] += srgbToLinear(int(b >> 8))
+		}
+	}
+
+	n := float64(pixels)
+	return fmt.Sprintf("#%02x%02x%02x", linearToSRGB(sum[0]/n), linearToSRGB(sum[1]/n), linearToSRGB(sum[2]/n))
+}
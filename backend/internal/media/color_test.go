@@ -0,0 +1,113 @@
+package media
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncodeDominantColor(t *testing.T) {
+	solid := image.NewRGBA(image.Rect(0, 0, 120, 80))
+	draw.Draw(solid, solid.Bounds(), image.NewUniform(color.RGBA{R: 0x33, G: 0x66, B: 0xCC, A: 255}), image.Point{}, draw.Src)
+	if got := encodeDominantColor(solid); got != "#3366cc" {
+		t.Errorf("Expected #3366cc for a solid image, got %s", got)
+	}
+
+	// Black and white halves mix in linear light, lighter than #808080
+	halves := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	halves.Set(0, 0, color.Black)
+	halves.Set(1, 0, color.White)
+	if got := encodeDominantColor(halves); got != "#bcbcbc" {
+		t.Errorf("Expected #bcbcbc for black and white halves, got %s", got)
+	}
+
+	if got := encodeDominantColor(image.NewRGBA(image.Rectangle{})); got != "" {
+		t.Errorf("Expected no color for an empty image, got %s", got)
+	}
+}
+
+func TestScanFilesIncludesDominantColor(t *testing.T) {
+	mediaDir := t.TempDir()
+	thumbnailer := NewThumbnailer(t.TempDir(), 64, nil)
+	organizer := NewOrganizerWithOptions(mediaDir, OrganizerOptions{Thumbnailer: thumbnailer})
+
+	solid := image.NewRGBA(image.Rect(0, 0, 200, 100))
+	draw.Draw(solid, solid.Bounds(), image.NewUniform(color.RGBA{R: 0xCC, G: 0x33, B: 0x11, A: 255}), image.Point{}, draw.Src)
+	path := filepath.Join(mediaDir, "2024", "March", "red.png")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+	if err := png.Encode(file, solid); err != nil {
+		t.Fatalf("Failed to encode image: %v", err)
+	}
+	file.Close()
+
+	// Indexed before the thumbnail exists, then picked up once it does
+	if files, _ := organizer.ScanFiles("", "", 10, 0); len(files) != 1 || files[0].DominantColor != "" {
+		t.Fatalf("Expected no dominant color before thumbnails exist, got %+v", files)
+	}
+	if _, err := thumbnailer.Thumbnail(context.Background(), path); err != nil {
+		t.Fatalf("Thumbnail failed: %v", err)
+	}
+
+	files, err := organizer.ScanFiles("", "", 10, 0)
+	if err != nil {
+		t.Fatalf("ScanFiles failed: %v", err)
+	}
+	if len(files) != 1 || files[0].DominantColor != "#cc3311" {
+		t.Errorf("Expected dominant color #cc3311, got %+v", files)
+	}
+	if len(files[0].Blurhash) != 28 {
+		t.Errorf("Expected the blurhash alongside the color, got %q", files[0].Blurhash)
+	}
+}
+
+func TestMetadataIndexSkipsPlaceholdersWithoutThumbnails(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizerWithOptions(mediaDir, OrganizerOptions{Thumbnailer: NewThumbnailer(t.TempDir(), 64, nil)})
+
+	tests := []struct {
+		file     string
+		expected bool
+	}{
+		{"clip.mp4", true},   // No thumbnail can be made without a converter
+		{"photo.png", false}, // Placeholders may still turn up
+	}
+
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			path := filepath.Join(mediaDir, "2024", "March", test.file)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatalf("Failed to create directory: %v", err)
+			}
+			if err := os.WriteFile(path, []byte(test.file), 0644); err != nil {
+				t.Fatalf("Failed to create file: %v", err)
+			}
+			stat, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("Failed to stat file: %v", err)
+			}
+
+			relPath := filepath.Join("2024", "March", test.file)
+			if _, err := organizer.metadataFor(path, relPath, stat); err != nil {
+				t.Fatalf("metadataFor failed: %v", err)
+			}
+			entry, ok := organizer.metadata.lookup(relPath, stat)
+			if !ok {
+				t.Fatal("Expected the file to be indexed")
+			}
+			if entry.placeholdersDone != test.expected {
+				t.Errorf("Expected placeholdersDone %v, got %v", test.expected, entry.placeholdersDone)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package media
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// jpegContentHash hashes a JPEG with its metadata segments (APP0-APP15 and
+// comments) left out, so copies that differ only in EXIF hash alike. Files
+// that aren't JPEGs hash to "".
+func jpegContentHash(filePath string) (string, error) {
+	if ext := strings.ToLower(filepath.Ext(filePath)); ext != ".jpg" && ext != ".jpeg" {
+		return "", nil
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	var soi [2]byte
+	if _, err := io.ReadFull(reader, soi[:]); err != nil || soi != [2]byte{0xFF, 0xD8} {
+		return "", nil
+	}
+
+	hash := sha256.New()
+	for {
+		marker, err := nextJPEGMarker(reader)
+		if err != nil {
+			return "", nil // Truncated or malformed; treat as not comparable
+		}
+
+		switch {
+		case marker == 0xD9: // End of image
+			return fmt.Sprintf("%x", hash.Sum(nil)), nil
+		case marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7): // No payload
+			hash.Write([]byte{0xFF, marker})
+			continue
+		}
+
+		var length [2]byte
+		if _, err := io.ReadFull(reader, length[:]); err != nil {
+			return "", nil
+		}
+		payload := int64(binary.BigEndian.Uint16(length[:])) - 2
+		if payload < 0 {
+			return "", nil
+		}
+
+		if (marker >= 0xE0 && marker <= 0xEF) || marker == 0xFE {
+			if _, err := reader.Discard(int(payload)); err != nil {
+				return "", nil
+			}
+			continue
+		}
+
+		hash.Write([]byte{0xFF, marker})
+		hash.Write(length[:])
+		if _, err := io.CopyN(hash, reader, payload); err != nil {
+			return "", nil
+		}
+		if marker == 0xDA { // Start of scan: the rest is entropy-coded image data
+			if _, err := io.Copy(hash, reader); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("%x", hash.Sum(nil)), nil
+		}
+	}
+}
+
+// nextJPEGMarker reads the next marker, skipping fill bytes.
+func nextJPEGMarker(reader *bufio.Reader) (byte, error) {
+	b, err := reader.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b != 0xFF {
+		return 0, fmt.Errorf("expected marker, got 0x%02X", b)
+	}
+	for b == 0xFF {
+		if b, err = reader.ReadByte(); err != nil {
+			return 0, err
+		}
+	}
+	return b, nil
+}
+
+// findContentDuplicate returns the file in targetDir whose image matches
+// filePath's once metadata is ignored, or "" if there is none, along with
+// filePath's image hash. Library files are looked up by the image hash the
+// checksum index recorded for them, so nothing else is read.
+func (o *Organizer) findContentDuplicate(filePath, targetDir string) (string, string, error) {
+	imageHash, err := jpegContentHash(filePath)
+	if err != nil || imageHash == "" {
+		return "", "", err
+	}
+	folder, err := filepath.Rel(o.mediaPath, targetDir)
+	if err != nil {
+		return "", imageHash, err
+	}
+
+	relPath, ok := o.checksums.FindImage(imageHash, folder)
+	if !ok {
+		return "", imageHash, nil
+	}
+	match := filepath.Join(o.mediaPath, filepath.FromSlash(relPath))
+	if _, err := os.Stat(match); err != nil {
+		return "", imageHash, nil // Gone behind the server's back; maintenance drops the entry
+	}
+	return match, imageHash, nil
+}
+
+// indexImageHash records the image hash of the library JPEG at relPath if
+// the index has none yet, reporting whether it did.
+func (o *Organizer) indexImageHash(relPath string) bool {
+	if !o.preferRicherMetadata {
+		return false
+	}
+	if _, ok := o.checksums.ImageHash(relPath); ok {
+		return false
+	}
+	imageHash, err := jpegContentHash(filepath.Join(o.mediaPath, filepath.FromSlash(relPath)))
+	if err != nil || imageHash == "" {
+		return false
+	}
+	o.checksums.SetImageHash(relPath, imageHash)
+	return true
+}
+
+// metadataRichness scores how much a file records about where and when it
+// was taken.
+func metadataRichness(info *MediaInfo) int {
+	score := 0
+	if info.DateSource == DateSourceEXIF {
+		score++
+	}
+	if info.Location != nil {
+		score++
+	}
+	return score
+}
+
+// richerThan reports whether info records more than the stored file at
+// existingPath. An unreadable stored file is never replaced.
+func (o *Organizer) richerThan(info *MediaInfo, existingPath string) bool {
+	existing, err := o.extractor.ExtractMetadata(existingPath)
+	if err != nil {
+		slog.Warn("Failed to read stored duplicate's metadata", "error", err, "file", existingPath)
+		return false
+	}
+	return metadataRichness(info) > metadataRichness(existing)
+}
+
+// replaceStored renames the newly placed file over the stored copy it
+// supersedes and returns where the file now lives. The rename stays within
+// the library so the stored copy is never left half-written; should it fail,
+// both copies are kept.
+func (o *Organizer) replaceStored(newPath, storedPath string) string {
+	stat, err := os.Stat(storedPath)
+	if err == nil {
+		err = os.Rename(newPath, storedPath)
+	}
+	if err != nil {
+		slog.Error("Failed to replace stored duplicate, keeping both", "error", err, "stored", storedPath)
+		return newPath
+	}
+
+	if relPath, err := filepath.Rel(o.mediaPath, storedPath); err == nil {
+		o.metadata.forget(relPath)
+	}
+	o.addLibraryBytes(-stat.Size())
+	slog.Info("Replaced stored copy with a richer duplicate", "file", storedPath)
+	return storedPath
+}
@@ -0,0 +1,155 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// strippedJPEG encodes the same image buildEXIFJPEG wraps, without any EXIF.
+func strippedJPEG(t *testing.T) []byte {
+	t.Helper()
+
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, 1, 1)), nil); err != nil {
+		t.Fatalf("Failed to encode JPEG: %v", err)
+	}
+	return img.Bytes()
+}
+
+func organizeBytes(t *testing.T, organizer *Organizer, fileName string, content []byte) *MediaInfo {
+	t.Helper()
+
+	source := filepath.Join(t.TempDir(), fileName)
+	if err := os.WriteFile(source, content, 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	info, err := organizer.OrganizeFile(source, fileName)
+	if err != nil {
+		t.Fatalf("OrganizeFile failed: %v", err)
+	}
+	return info
+}
+
+func TestJPEGContentHashIgnoresMetadata(t *testing.T) {
+	dir := t.TempDir()
+	stripped := filepath.Join(dir, "stripped.jpg")
+	rich := filepath.Join(dir, "rich.jpg")
+	os.WriteFile(stripped, strippedJPEG(t), 0644)
+	os.WriteFile(rich, buildEXIFJPEG(t, exifFixture{Make: "Canon", DateTimeOriginal: "2024:03:15 14:30:22"}), 0644)
+
+	strippedHash, err := jpegContentHash(stripped)
+	if err != nil || strippedHash == "" {
+		t.Fatalf("Expected a content hash, got %q (%v)", strippedHash, err)
+	}
+	richHash, err := jpegContentHash(rich)
+	if err != nil {
+		t.Fatalf("jpegContentHash failed: %v", err)
+	}
+	if strippedHash != richHash {
+		t.Error("Expected copies differing only in EXIF to share a content hash")
+	}
+
+	notJPEG := filepath.Join(dir, "clip.mp4")
+	os.WriteFile(notJPEG, []byte("not an image"), 0644)
+	if hash, _ := jpegContentHash(notJPEG); hash != "" {
+		t.Errorf("Expected no content hash for a non-JPEG, got %q", hash)
+	}
+}
+
+func TestOrganizeFileRicherDuplicateReplacesStripped(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizerWithOptions(mediaDir, OrganizerOptions{PreferRicherMetadata: true})
+
+	const name = "IMG_20240315_143022.jpg"
+	stored := organizeBytes(t, organizer, name, strippedJPEG(t))
+	storedPath := filepath.Join(mediaDir, stored.RelativePath)
+
+	rich := buildEXIFJPEG(t, exifFixture{Make: "Canon", DateTimeOriginal: "2024:03:15 14:30:22"})
+	info := organizeBytes(t, organizer, "upload.jpg", rich)
+	if info.RelativePath != stored.RelativePath {
+		t.Errorf("Expected the richer copy to take the stored path %s, got %q", stored.RelativePath, info.RelativePath)
+	}
+
+	content, err := os.ReadFile(storedPath)
+	if err != nil {
+		t.Fatalf("Failed to read stored file: %v", err)
+	}
+	if !bytes.Equal(content, rich) {
+		t.Error("Expected the stored copy to be replaced by the richer one")
+	}
+
+	entries, _ := os.ReadDir(filepath.Dir(storedPath))
+	if len(entries) != 1 {
+		t.Errorf("Expected a single file after replacement, got %d", len(entries))
+	}
+	if hash, _ := organizer.checksums.Get(stored.RelativePath); hash != fmt.Sprintf("%x", sha256.Sum256(rich)) {
+		t.Error("Expected the checksum to describe the replacement")
+	}
+	if size, err := organizer.LibrarySize(); err != nil || size != int64(len(rich)) {
+		t.Errorf("Expected library size %d, got %d (%v)", len(rich), size, err)
+	}
+}
+
+func TestOrganizeFilePoorerDuplicateSkipped(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizerWithOptions(mediaDir, OrganizerOptions{PreferRicherMetadata: true})
+
+	rich := buildEXIFJPEG(t, exifFixture{Make: "Canon", DateTimeOriginal: "2024:03:15 14:30:22"})
+	stored := organizeBytes(t, organizer, "IMG_20240315_143022.jpg", rich)
+
+	info := organizeBytes(t, organizer, "IMG_20240315_143022.jpg", strippedJPEG(t))
+	if info.RelativePath != "" {
+		t.Errorf("Expected the stripped copy to be skipped, got %s", info.RelativePath)
+	}
+
+	content, _ := os.ReadFile(filepath.Join(mediaDir, stored.RelativePath))
+	if !bytes.Equal(content, rich) {
+		t.Error("Expected the richer stored copy to be kept")
+	}
+}
+
+func TestContentDuplicateOfFileAddedByHand(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizerWithOptions(mediaDir, OrganizerOptions{PreferRicherMetadata: true})
+
+	rich := buildEXIFJPEG(t, exifFixture{Make: "Canon", DateTimeOriginal: "2024:03:15 14:30:22"})
+	storedPath := filepath.Join(mediaDir, "2024", "March", "copied.jpg")
+	if err := os.MkdirAll(filepath.Dir(storedPath), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(storedPath, rich, 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	// Duplicates are found through the index, which maintenance fills in
+	if _, err := organizer.Reconcile(context.Background(), MaintenanceOptions{}); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if _, ok := organizer.checksums.ImageHash("2024/March/copied.jpg"); !ok {
+		t.Fatal("Expected maintenance to record the image hash")
+	}
+
+	info := organizeBytes(t, organizer, "IMG_20240315_143022.jpg", strippedJPEG(t))
+	if info.RelativePath != "" {
+		t.Errorf("Expected the stripped copy to be skipped, got %s", info.RelativePath)
+	}
+}
+
+func TestOrganizeFileContentDuplicatesKeptByDefault(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+
+	organizeBytes(t, organizer, "IMG_20240315_143022.jpg", strippedJPEG(t))
+	info := organizeBytes(t, organizer, "IMG_20240315_143022.jpg",
+		buildEXIFJPEG(t, exifFixture{DateTimeOriginal: "2024:03:15 14:30:22"}))
+	if info.RelativePath == "" {
+		t.Error("Expected both copies to be kept without PreferRicherMetadata")
+	}
+}
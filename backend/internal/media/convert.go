@@ -0,0 +1,132 @@
+package media
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var ErrConversionUnavailable = errors.New("no image converter available")
+
+// convertibleImageExts are still-image formats browsers cannot display natively.
+var convertibleImageExts = map[string]bool{
+	".heic": true, ".heif": true, ".tif": true, ".tiff": true, ".bmp": true,
+}
+
+// maxConversions caps the external converters running at once; each can use
+// a core and hundreds of megabytes on a large HEIC.
+const maxConversions = 4
+
+// Converter transcodes image formats browsers cannot display (HEIC, TIFF, ...)
+// to JPEG using whichever external tool is installed, caching the results.
+type Converter struct {
+	cacheDir string
+	slots    chan struct{} // Held while a converter runs
+}
+
+func NewConverter(cacheDir string) *Converter {
+	return &Converter{cacheDir: cacheDir, slots: make(chan struct{}, maxConversions)}
+}
+
+// NeedsConversion reports whether a file must be converted for web display.
+func NeedsConversion(filePath string) bool {
+	return convertibleImageExts[strings.ToLower(filepath.Ext(filePath))]
+}
+
+// ToJPEG returns the path of a JPEG rendition of srcPath, converting it on first
+// use. It returns ErrConversionUnavailable when no suitable tool is installed.
+// At most maxConversions run at once; others wait for a slot or for ctx.
+func (c *Converter) ToJPEG(ctx context.Context, srcPath string) (string, error) {
+	stat, err := os.Stat(srcPath)
+	if err != nil {
+		return "", err
+	}
+	if !stat.Mode().IsRegular() {
+		return "", fmt.Errorf("%s is not a regular file", srcPath)
+	}
+
+	// Key on path, size and modtime so an edited original is re-converted
+	key := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%d", srcPath, stat.Size(), stat.ModTime().UnixNano())))
+	cachedPath := filepath.Join(c.cacheDir, fmt.Sprintf("%x.jpg", key[:16]))
+
+	if _, err := os.Stat(cachedPath); err == nil {
+		return cachedPath, nil
+	}
+
+	select {
+	case c.slots <- struct{}{}:
+		defer func() { <-c.slots }()
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+
+	// Converted by another request while this one waited
+	if _, err := os.Stat(cachedPath); err == nil {
+		return cachedPath, nil
+	}
+
+	if err := os.MkdirAll(c.cacheDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create conversion cache: %w", err)
+	}
+
+	commands := converterCommands(srcPath)
+	if len(commands) == 0 {
+		return "", ErrConversionUnavailable
+	}
+
+	// Each conversion writes its own partial file, so concurrent requests for
+	// the same image can't interleave their output
+	partial, err := os.CreateTemp(c.cacheDir, filepath.Base(cachedPath)+".*.partial.jpg")
+	if err != nil {
+		return "", fmt.Errorf("failed to create conversion output: %w", err)
+	}
+	partial.Close()
+	partialPath := partial.Name()
+	defer os.Remove(partialPath)
+
+	var lastErr error
+	for _, args := range commands {
+		cmd := exec.CommandContext(ctx, args[0], append(args[1:], partialPath)...)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			lastErr = fmt.Errorf("%s: %w: %s", filepath.Base(args[0]), err, strings.TrimSpace(string(output)))
+			slog.Debug("Image conversion attempt failed", "error", lastErr, "file", srcPath)
+			continue
+		}
+
+		if err := os.Rename(partialPath, cachedPath); err != nil {
+			return "", fmt.Errorf("failed to store converted image: %w", err)
+		}
+		return cachedPath, nil
+	}
+
+	return "", fmt.Errorf("image conversion failed: %w", lastErr)
+}
+
+// converterCommands lists the installed tools able to convert srcPath, each as
+// an argv missing only the output path.
+func converterCommands(srcPath string) [][]string {
+	ext := strings.ToLower(filepath.Ext(srcPath))
+
+	var commands [][]string
+	if ext == ".heic" || ext == ".heif" {
+		if path, err := exec.LookPath("heif-convert"); err == nil {
+			commands = append(commands, []string{path, "-q", "90", srcPath})
+		}
+	}
+	if path, err := exec.LookPath("magick"); err == nil {
+		commands = append(commands, []string{path, srcPath + "[0]", "-auto-orient"})
+	} else if path, err := exec.LookPath("convert"); err == nil {
+		commands = append(commands, []string{path, srcPath + "[0]", "-auto-orient"})
+	}
+	if path, err := exec.LookPath("ffmpeg"); err == nil {
+		commands = append(commands, []string{path, "-v", "quiet", "-y", "-i", srcPath, "-frames:v", "1"})
+	}
+
+	return commands
+}
@@ -0,0 +1,178 @@
+package media
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// pdfScanBytes is how much of each end of a PDF is searched for its dates.
+// Writers put the info dictionary and XMP packet near the start or, after
+// incremental saves, near the end; huge scans aren't read in full.
+const pdfScanBytes = 1 << 20
+
+var (
+	// pdfCreationDate matches the info dictionary entry as a literal string,
+	// /CreationDate (D:20240315143022+01'00'), or a hex string.
+	pdfCreationDate = regexp.MustCompile(`/CreationDate\s*(?:\(([^)]*)\)|<([0-9A-Fa-f\s]*)>)`)
+	// xmpCreateDate matches the XMP equivalent, which PDF 1.5+ writers that
+	// compress the info dictionary still leave readable.
+	xmpCreateDate = regexp.MustCompile(`<xmp:CreateDate>([^<]+)</xmp:CreateDate>|xmp:CreateDate="([^"]+)"`)
+)
+
+// normalizeExtensions lowercases extensions and gives each a leading dot.
+func normalizeExtensions(exts []string) map[string]bool {
+	set := make(map[string]bool, len(exts))
+	for _, ext := range exts {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		set[ext] = true
+	}
+	return set
+}
+
+// isDocument reports whether fileName has one of the extensions documents
+// were enabled for.
+func (e *Extractor) isDocument(fileName string) bool {
+	return e.documentExts[strings.ToLower(filepath.Ext(fileName))]
+}
+
+// extractDateFromPDF dates a PDF by the CreationDate in its info dictionary,
+// falling back to its XMP CreateDate. Files that aren't PDFs, whatever their
+// extension, are left alone.
+func (e *Extractor) extractDateFromPDF(filePath string, info *MediaInfo) {
+	if info.MediaType != MediaTypeDocument {
+		return
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	head := make([]byte, pdfScanBytes)
+	n, _ := io.ReadFull(file, head)
+	head = head[:n]
+	if !bytes.HasPrefix(head, []byte("%PDF-")) {
+		return
+	}
+
+	sections := [][]byte{head}
+	if stat, err := file.Stat(); err == nil && stat.Size() > int64(n) {
+		tail := make([]byte, min(pdfScanBytes, stat.Size()-int64(n)))
+		if n, err := file.ReadAt(tail, stat.Size()-int64(len(tail))); err == nil || err == io.EOF {
+			// Incremental updates append, so the last dictionary is current
+			sections = [][]byte{tail[:n], head}
+		}
+	}
+
+	for _, section := range sections {
+		if date, ok := e.pdfDate(section); ok {
+			info.DateTaken = &date
+			info.DateSource = DateSourcePDF
+			slog.Debug("Date extracted from PDF metadata", "date", date, "file", filePath)
+			return
+		}
+	}
+}
+
+// pdfDate finds the last creation date in a piece of a PDF.
+func (e *Extractor) pdfDate(data []byte) (time.Time, bool) {
+	if matches := pdfCreationDate.FindAllSubmatch(data, -1); len(matches) > 0 {
+		match := matches[len(matches)-1]
+		value := string(match[1])
+		if match[2] != nil {
+			value = decodePDFHexString(match[2])
+		}
+		if date, ok := parsePDFDate(value, e.location); ok {
+			return date, true
+		}
+	}
+
+	if matches := xmpCreateDate.FindAllSubmatch(data, -1); len(matches) > 0 {
+		match := matches[len(matches)-1]
+		value := string(match[1]) + string(match[2])
+		for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02T15:04", "2006-01-02"} {
+			if date, err := time.ParseInLocation(layout, strings.TrimSpace(value), e.location); err == nil {
+				return date, true
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// decodePDFHexString decodes <...> string contents, which are UTF-16BE when
+// they start with a byte order mark and PDFDocEncoding, close enough to
+// Latin-1 for digits, otherwise.
+func decodePDFHexString(value []byte) string {
+	cleaned := bytes.Join(bytes.Fields(value), nil)
+	if len(cleaned)%2 == 1 {
+		cleaned = append(cleaned, '0')
+	}
+	decoded := make([]byte, hex.DecodedLen(len(cleaned)))
+	if _, err := hex.Decode(decoded, cleaned); err != nil {
+		return ""
+	}
+
+	if len(decoded) >= 2 && decoded[0] == 0xFE && decoded[1] == 0xFF {
+		units := make([]uint16, 0, len(decoded)/2)
+		for i := 2; i+1 < len(decoded); i += 2 {
+			units = append(units, uint16(decoded[i])<<8|uint16(decoded[i+1]))
+		}
+		return string(utf16.Decode(units))
+	}
+	return string(decoded)
+}
+
+// parsePDFDate parses a PDF date string, D:YYYYMMDDHHmmSSOHH'mm', where every
+// part after the year is optional. Dates without a zone are wall-clock time
+// in loc.
+func parsePDFDate(value string, loc *time.Location) (time.Time, bool) {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "D:")
+
+	digits := 0
+	for digits < len(value) && digits < 14 && value[digits] >= '0' && value[digits] <= '9' {
+		digits++
+	}
+	if digits < 4 || digits%2 != 0 {
+		return time.Time{}, false
+	}
+	// Pad missing parts with the earliest valid value: month and day 01
+	stamp := value[:digits] + "0101000000"[digits-4:]
+
+	zone := loc
+	if rest := strings.ReplaceAll(value[digits:], "'", ""); rest != "" {
+		switch {
+		case strings.HasPrefix(rest, "Z"):
+			zone = time.UTC
+		case len(rest) >= 3 && (rest[0] == '+' || rest[0] == '-'):
+			hhmm := (rest[1:] + "00")[:4]
+			offset, err := time.Parse("-0700", rest[:1]+hhmm)
+			if err != nil {
+				return time.Time{}, false
+			}
+			_, seconds := offset.Zone()
+			zone = time.FixedZone("", seconds)
+		}
+	}
+
+	date, err := time.ParseInLocation("20060102150405", stamp, zone)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return date, true
+}
@@ -0,0 +1,120 @@
+package media
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// minimalPDF returns a small PDF whose info dictionary holds info.
+func minimalPDF(info string) string {
+	return "%PDF-1.4\n" +
+		"1 0 obj << /Type /Catalog /Pages 2 0 R >> endobj\n" +
+		"2 0 obj << /Type /Pages /Kids [] /Count 0 >> endobj\n" +
+		"3 0 obj << " + info + " >> endobj\n" +
+		"trailer << /Root 1 0 R /Info 3 0 R >>\n%%EOF\n"
+}
+
+func TestParsePDFDate(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Time
+		ok       bool
+	}{
+		{"D:20240315143022+01'00'", time.Date(2024, 3, 15, 13, 30, 22, 0, time.UTC), true},
+		{"D:20240315143022-05'30", time.Date(2024, 3, 15, 20, 0, 22, 0, time.UTC), true},
+		{"D:20240315143022Z", time.Date(2024, 3, 15, 14, 30, 22, 0, time.UTC), true},
+		{"D:20240315143022", time.Date(2024, 3, 15, 14, 30, 22, 0, time.UTC), true},
+		{"D:202403", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), true},
+		{"20240315", time.Date(2024, 3, 15, 0, 0, 0, 0, time.UTC), true},
+		{"D:2024031", time.Time{}, false},
+		{"yesterday", time.Time{}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			date, ok := parsePDFDate(test.value, time.UTC)
+			if ok != test.ok {
+				t.Fatalf("Expected ok %v, got %v", test.ok, ok)
+			}
+			if ok && !date.Equal(test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, date)
+			}
+		})
+	}
+}
+
+func TestExtractDateFromPDF(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected time.Time
+	}{
+		{"Literal string", minimalPDF("/Producer (Scanner) /CreationDate (D:20230704180000Z)"), time.Date(2023, 7, 4, 18, 0, 0, 0, time.UTC)},
+		// "D:20230704" in UTF-16BE with a byte order mark
+		{"Hex string", minimalPDF("/CreationDate <FEFF0044003A00320030003200330030003700300034>"), time.Date(2023, 7, 4, 0, 0, 0, 0, time.UTC)},
+		{"XMP only", minimalPDF("/Producer (Scanner)") + "<xmp:CreateDate>2023-07-04T18:00:00Z</xmp:CreateDate>", time.Date(2023, 7, 4, 18, 0, 0, 0, time.UTC)},
+	}
+
+	extractor := NewExtractor()
+	extractor.documentExts = normalizeExtensions([]string{"pdf"})
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "scan.pdf")
+			if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+				t.Fatalf("Failed to write PDF: %v", err)
+			}
+
+			info, err := extractor.ExtractMetadata(path)
+			if err != nil {
+				t.Fatalf("ExtractMetadata failed: %v", err)
+			}
+			if info.MediaType != MediaTypeDocument {
+				t.Errorf("Expected a document, got %s", info.MediaType)
+			}
+			if info.DateSource != DateSourcePDF || info.DateTaken == nil || !info.DateTaken.Equal(test.expected) {
+				t.Errorf("Expected %v from the PDF, got %v from %s", test.expected, info.DateTaken, info.DateSource)
+			}
+		})
+	}
+}
+
+func TestOrganizeDocuments(t *testing.T) {
+	pdf := minimalPDF("/CreationDate (D:20230704180000Z)")
+
+	// Without the opt-in, PDFs stay "other" and undated by their metadata
+	organizer := NewOrganizer(t.TempDir())
+	info := organizeTestFile(t, organizer, "scan.pdf", pdf)
+	if info.MediaType != MediaTypeOther || info.DateSource == DateSourcePDF {
+		t.Errorf("Expected an undated other file, got %s dated from %s", info.MediaType, info.DateSource)
+	}
+
+	mediaDir := t.TempDir()
+	organizer = NewOrganizerWithOptions(mediaDir, OrganizerOptions{DocumentExtensions: []string{".PDF"}})
+
+	// Uploads are organized from a temp file without the extension
+	temp := filepath.Join(t.TempDir(), "upload_123.tmp")
+	if err := os.WriteFile(temp, []byte(pdf), 0644); err != nil {
+		t.Fatalf("Failed to write PDF: %v", err)
+	}
+	info, err := organizer.OrganizeFile(temp, "scan.pdf")
+	if err != nil {
+		t.Fatalf("OrganizeFile failed: %v", err)
+	}
+	if info.MediaType != MediaTypeDocument || info.DateSource != DateSourcePDF {
+		t.Errorf("Expected a document dated from the PDF, got %s from %s", info.MediaType, info.DateSource)
+	}
+	if expected := filepath.Join("2023", "July", "scan.pdf"); info.RelativePath != expected {
+		t.Errorf("Expected the document at %s, got %s", expected, info.RelativePath)
+	}
+
+	fileInfo, err := organizer.FileInfo(info.RelativePath)
+	if err != nil {
+		t.Fatalf("FileInfo failed: %v", err)
+	}
+	if fileInfo.MediaType != "document" {
+		t.Errorf("Expected the listing type document, got %s", fileInfo.MediaType)
+	}
+}
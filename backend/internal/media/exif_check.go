@@ -0,0 +1,79 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// tiffTypeSizes are the byte sizes of the TIFF field types goexif decodes.
+var tiffTypeSizes = map[uint16]uint64{
+	1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8,
+}
+
+// exifSubIFDTags point from one IFD to another (Exif, GPS and Interop).
+var exifSubIFDTags = map[uint16]bool{0x8769: true, 0x8825: true, 0xA005: true}
+
+// maxEXIFDirectories bounds how many IFDs checkEXIFCounts follows, so a
+// block whose IFDs point at each other can't keep it busy.
+const maxEXIFDirectories = 32
+
+// checkEXIFCounts rejects EXIF whose tags claim more values than the block
+// holds. goexif multiplies the count by the type size in 32 bits, so a huge
+// count can wrap around to a small size that passes its own checks, after
+// which it allocates a slice for every claimed value. That exhausts memory,
+// which unlike a panic can't be recovered from. Data without a recognisable
+// EXIF block is left for the decoder to judge.
+func checkEXIFCounts(data []byte) error {
+	tiff := data
+	if start := bytes.Index(data, []byte("Exif\x00\x00")); start >= 0 {
+		tiff = data[start+6:]
+	}
+	if len(tiff) < 8 {
+		return nil
+	}
+
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return nil
+	}
+
+	pending := []uint32{order.Uint32(tiff[4:8])}
+	visited := make(map[uint32]bool)
+	for len(pending) > 0 && len(visited) < maxEXIFDirectories {
+		offset := pending[0]
+		pending = pending[1:]
+		if offset == 0 || visited[offset] || uint64(offset)+2 > uint64(len(tiff)) {
+			continue
+		}
+		visited[offset] = true
+
+		entries := uint64(order.Uint16(tiff[offset:]))
+		for i := uint64(0); i < entries; i++ {
+			entry := uint64(offset) + 2 + 12*i
+			if entry+12 > uint64(len(tiff)) {
+				break // Truncated directory; the decoder reports that itself
+			}
+			tag := order.Uint16(tiff[entry:])
+			size := tiffTypeSizes[order.Uint16(tiff[entry+2:])]
+			count := uint64(order.Uint32(tiff[entry+4:]))
+
+			if size*count > uint64(len(tiff)) {
+				return fmt.Errorf("EXIF tag 0x%04X claims %d values, more than the %d-byte block holds", tag, count, len(tiff))
+			}
+			if exifSubIFDTags[tag] {
+				pending = append(pending, order.Uint32(tiff[entry+8:]))
+			}
+		}
+
+		if next := uint64(offset) + 2 + 12*entries; next+4 <= uint64(len(tiff)) {
+			pending = append(pending, order.Uint32(tiff[next:]))
+		}
+	}
+	return nil
+}
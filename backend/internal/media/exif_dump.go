@@ -0,0 +1,57 @@
+package media
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rwcarlsen/goexif/exif"
+	"github.com/rwcarlsen/goexif/tiff"
+)
+
+// exifDump collects every decoded tag as a string, for debugging dating.
+type exifDump map[string]string
+
+func (d exifDump) Walk(name exif.FieldName, tag *tiff.Tag) error {
+	if s, err := tag.StringVal(); err == nil {
+		d[string(name)] = strings.TrimRight(s, "\x00 ")
+		return nil
+	}
+	d[string(name)] = tag.String()
+	return nil
+}
+
+// EXIFTags returns all EXIF tags of a file keyed by field name. Files without
+// (readable) EXIF yield an empty map rather than an error; only failing to
+// open the file is reported.
+func (e *Extractor) EXIFTags(filePath string) (map[string]string, error) {
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if !stat.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", filePath)
+	}
+
+	x, err := e.decodeEXIF(filePath)
+	if err != nil {
+		return map[string]string{}, nil
+	}
+
+	dump := exifDump{}
+	if err := walkEXIF(x, dump); err != nil {
+		return nil, err
+	}
+	return dump, nil
+}
+
+// walkEXIF guards the walk the same way field reads are guarded, since the
+// string formatting of malformed values can panic.
+func walkEXIF(x *exif.Exif, w exif.Walker) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("EXIF walk panicked: %v", r)
+		}
+	}()
+	return x.Walk(w)
+}
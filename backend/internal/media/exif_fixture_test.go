@@ -0,0 +1,195 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"sort"
+	"testing"
+)
+
+// exifFixture lists the EXIF fields a test JPEG should carry; empty fields are
+// omitted.
+type exifFixture struct {
+	Make             string
+	Model            string
+	LensModel        string
+	DateTimeOriginal string // "2006:01:02 15:04:05"
+	Orientation      uint16
+	ImageDescription string
+	UserComment      []byte      // Raw value including the 8-byte character code
+	GPS              []exifEntry // Raw GPS IFD entries, for malformed-data tests
+	FocalLength      [2]uint32   // Numerator and denominator
+	FNumber          [2]uint32
+	ExposureTime     [2]uint32
+	ISO              uint16
+	Flash            uint16
+	Width, Height    int // Pixel size of the image data; zero means 1x1
+}
+
+type exifEntry struct {
+	tag   uint16
+	kind  uint16 // 2 ASCII, 3 SHORT, 4 LONG, 5 RATIONAL, 7 UNDEFINED
+	count uint32
+	data  []byte
+}
+
+func asciiEntry(tag uint16, value string) exifEntry {
+	data := append([]byte(value), 0)
+	return exifEntry{tag: tag, kind: 2, count: uint32(len(data)), data: data}
+}
+
+func shortEntry(tag uint16, value uint16) exifEntry {
+	data := make([]byte, 2)
+	binary.LittleEndian.PutUint16(data, value)
+	return exifEntry{tag: tag, kind: 3, count: 1, data: data}
+}
+
+func longEntry(tag uint16, value uint32) exifEntry {
+	data := make([]byte, 4)
+	binary.LittleEndian.PutUint32(data, value)
+	return exifEntry{tag: tag, kind: 4, count: 1, data: data}
+}
+
+func undefinedEntry(tag uint16, data []byte) exifEntry {
+	return exifEntry{tag: tag, kind: 7, count: uint32(len(data)), data: data}
+}
+
+// rationalEntry encodes numerator/denominator pairs as RATIONAL values.
+func rationalEntry(tag uint16, pairs ...uint32) exifEntry {
+	data := make([]byte, 4*len(pairs))
+	for i, value := range pairs {
+		binary.LittleEndian.PutUint32(data[4*i:], value)
+	}
+	return exifEntry{tag: tag, kind: 5, count: uint32(len(pairs) / 2), data: data}
+}
+
+// encodeIFD lays out an IFD starting at offset start within the TIFF block,
+// with out-of-line values placed directly after it.
+func encodeIFD(entries []exifEntry, start uint32) []byte {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].tag < entries[j].tag })
+
+	header := 2 + 12*len(entries) + 4
+	var head, data bytes.Buffer
+	binary.Write(&head, binary.LittleEndian, uint16(len(entries)))
+
+	for _, entry := range entries {
+		binary.Write(&head, binary.LittleEndian, entry.tag)
+		binary.Write(&head, binary.LittleEndian, entry.kind)
+		binary.Write(&head, binary.LittleEndian, entry.count)
+		if len(entry.data) <= 4 {
+			value := make([]byte, 4)
+			copy(value, entry.data)
+			head.Write(value)
+			continue
+		}
+		binary.Write(&head, binary.LittleEndian, start+uint32(header+data.Len()))
+		data.Write(entry.data)
+		if data.Len()%2 == 1 {
+			data.WriteByte(0)
+		}
+	}
+	binary.Write(&head, binary.LittleEndian, uint32(0)) // No next IFD
+
+	return append(head.Bytes(), data.Bytes()...)
+}
+
+// buildEXIFJPEG returns a tiny valid JPEG whose APP1 segment holds the
+// fixture's EXIF fields.
+func buildEXIFJPEG(t testing.TB, fixture exifFixture) []byte {
+	t.Helper()
+
+	tiff := buildEXIFTIFF(fixture)
+
+	width, height := max(fixture.Width, 1), max(fixture.Height, 1)
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatalf("Failed to encode JPEG: %v", err)
+	}
+
+	var out bytes.Buffer
+	out.Write([]byte{0xFF, 0xD8, 0xFF, 0xE1})
+	binary.Write(&out, binary.BigEndian, uint16(2+6+len(tiff)))
+	out.WriteString("Exif\x00\x00")
+	out.Write(tiff)
+	out.Write(img.Bytes()[2:]) // Skip the encoder's SOI marker
+	return out.Bytes()
+}
+
+// buildEXIFTIFF encodes the fixture's EXIF fields as a little-endian TIFF
+// structure, the form EXIF takes inside every container.
+func buildEXIFTIFF(fixture exifFixture) []byte {
+	var ifd0, exifIFD []exifEntry
+	if fixture.Make != "" {
+		ifd0 = append(ifd0, asciiEntry(0x010F, fixture.Make))
+	}
+	if fixture.Model != "" {
+		ifd0 = append(ifd0, asciiEntry(0x0110, fixture.Model))
+	}
+	if fixture.ImageDescription != "" {
+		ifd0 = append(ifd0, asciiEntry(0x010E, fixture.ImageDescription))
+	}
+	if fixture.Orientation != 0 {
+		ifd0 = append(ifd0, shortEntry(0x0112, fixture.Orientation))
+	}
+	if fixture.DateTimeOriginal != "" {
+		exifIFD = append(exifIFD, asciiEntry(0x9003, fixture.DateTimeOriginal))
+	}
+	if fixture.UserComment != nil {
+		exifIFD = append(exifIFD, undefinedEntry(0x9286, fixture.UserComment))
+	}
+	if fixture.LensModel != "" {
+		exifIFD = append(exifIFD, asciiEntry(0xA434, fixture.LensModel))
+	}
+	if fixture.ExposureTime[1] != 0 {
+		exifIFD = append(exifIFD, rationalEntry(0x829A, fixture.ExposureTime[:]...))
+	}
+	if fixture.FNumber[1] != 0 {
+		exifIFD = append(exifIFD, rationalEntry(0x829D, fixture.FNumber[:]...))
+	}
+	if fixture.ISO != 0 {
+		exifIFD = append(exifIFD, shortEntry(0x8827, fixture.ISO))
+	}
+	if fixture.Flash != 0 {
+		exifIFD = append(exifIFD, shortEntry(0x9209, fixture.Flash))
+	}
+	if fixture.FocalLength[1] != 0 {
+		exifIFD = append(exifIFD, rationalEntry(0x920A, fixture.FocalLength[:]...))
+	}
+
+	// Sub-IFD pointers don't change IFD0's size, so measure it with
+	// placeholders and then encode everything at its final offset.
+	subIFDs := []struct {
+		pointerTag uint16
+		entries    []exifEntry
+	}{
+		{0x8769, exifIFD},
+		{0x8825, fixture.GPS},
+	}
+	var pointers []exifEntry
+	for _, sub := range subIFDs {
+		if len(sub.entries) > 0 {
+			pointers = append(pointers, longEntry(sub.pointerTag, 0))
+		}
+	}
+	offset := uint32(8 + len(encodeIFD(append(append([]exifEntry{}, ifd0...), pointers...), 8)))
+
+	var blocks [][]byte
+	for _, sub := range subIFDs {
+		if len(sub.entries) == 0 {
+			continue
+		}
+		block := encodeIFD(sub.entries, offset)
+		ifd0 = append(ifd0, longEntry(sub.pointerTag, offset))
+		blocks = append(blocks, block)
+		offset += uint32(len(block))
+	}
+
+	tiff := []byte{'I', 'I', 42, 0, 8, 0, 0, 0}
+	tiff = append(tiff, encodeIFD(ifd0, 8)...)
+	for _, block := range blocks {
+		tiff = append(tiff, block...)
+	}
+	return tiff
+}
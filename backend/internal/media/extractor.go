@@ -1,12 +1,22 @@
 package media
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log/slog"
+	"math"
 	"mime"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -15,16 +25,60 @@ import (
 )
 
 type Extractor struct {
-	filenamePatterns []*regexp.Regexp
+	filenamePatterns []FilenamePattern
+	ffprobePath      string
+	probe            func(ctx context.Context, ffprobePath, filePath string) ([]byte, error)
+	exifPrefix       int64           // Bytes decoded first; the rest of exifReadLimit is only read if that fails
+	exifReadLimit    int64           // Bytes read from the file head when looking for EXIF
+	exifTimeout      time.Duration   // Upper bound on decoding a single file's EXIF
+	exifDecoders     chan struct{}   // Slots for decode goroutines, held until they return
+	location         *time.Location  // Zone for wall-clock dates and file-time normalization
+	keepNullIsland   bool            // Trust EXIF GPS of exactly 0,0
+	documentExts     map[string]bool // Extensions extracted as documents; empty disables them
 }
 
+const (
+	// EXIF lives in an APP1 segment near the start of the file, which is capped
+	// at 64 KiB; the headroom covers thumbnails and segments placed before it.
+	defaultEXIFPrefix    = 64 * 1024
+	defaultEXIFReadLimit = 512 * 1024
+	defaultEXIFTimeout   = 5 * time.Second
+	// A decoder can't be stopped, so one that times out keeps its slot until
+	// it returns; this bounds how many can pile up
+	defaultEXIFDecoders = 8
+)
+
 func NewExtractor() *Extractor {
 	return &Extractor{
 		filenamePatterns: buildFilenamePatterns(),
+		ffprobePath:      "ffprobe",
+		probe:            runFFprobe,
+		exifPrefix:       defaultEXIFPrefix,
+		exifReadLimit:    defaultEXIFReadLimit,
+		exifTimeout:      defaultEXIFTimeout,
+		exifDecoders:     make(chan struct{}, defaultEXIFDecoders),
+		location:         time.UTC,
+	}
+}
+
+// NewExtractorInLocation returns an extractor that interprets dates without a
+// zone (filenames, EXIF lacking an offset) as wall-clock time in loc and
+// reports file times in loc, so all date sources bucket consistently.
+func NewExtractorInLocation(loc *time.Location) *Extractor {
+	e := NewExtractor()
+	if loc != nil {
+		e.location = loc
 	}
+	return e
 }
 
 func (e *Extractor) ExtractMetadata(filePath string) (*MediaInfo, error) {
+	return e.ExtractMetadataAs(filePath, "")
+}
+
+// ExtractMetadataAs extracts metadata treating the file as mediaType instead of
+// sniffing it from the extension. An empty mediaType falls back to sniffing.
+func (e *Extractor) ExtractMetadataAs(filePath string, mediaType MediaType) (*MediaInfo, error) {
 	info := &MediaInfo{
 		FileName:      filepath.Base(filePath),
 		ExtraMetadata: make(map[string]string),
@@ -38,14 +92,30 @@ func (e *Extractor) ExtractMetadata(filePath string) (*MediaInfo, error) {
 
 	info.MimeType = mime.TypeByExtension(filepath.Ext(filePath))
 	info.MediaType = e.determineMediaType(info.MimeType)
+	if e.isDocument(filePath) {
+		info.MediaType = MediaTypeDocument
+	}
+	if mediaType != "" {
+		info.MediaType = mediaType
+	}
 
 	e.extractDateFromEXIF(filePath, info)
+	e.extractImageDimensions(filePath, info)
+	if info.DateTaken == nil {
+		e.extractDateFromPDF(filePath, info)
+	}
+	if info.DateTaken == nil {
+		e.extractDateFromTakeout(filePath, info)
+	}
+	// The user's Takeout date outranks whatever the container recorded
+	e.extractVideoMetadata(filePath, info)
 	if info.DateTaken == nil {
 		e.extractDateFromFilename(info.FileName, info)
 	}
 	if info.DateTaken == nil {
 		e.extractDateFromFileTime(fileInfo, info)
 	}
+	info.ScreenCapture = classifyScreenCapture(info)
 
 	slog.Info("Metadata extracted",
 		"filename", info.FileName,
@@ -76,20 +146,173 @@ func (e *Extractor) extractDateFromEXIF(filePath string, info *MediaInfo) {
 		return
 	}
 
-	file, err := os.Open(filePath)
+	x, err := e.decodeEXIF(filePath)
 	if err != nil {
-		slog.Debug("Failed to open file for EXIF", "error", err, "file", filePath)
+		slog.Debug("Failed to decode EXIF data", "error", err, "file", filePath)
 		return
 	}
+
+	// Tag accessors panic on some malformed values (e.g. a GPS coordinate with
+	// fewer than three components), so fields are read into a copy that is only
+	// kept when every read succeeds.
+	scratch := *info
+	if info.Camera != nil {
+		camera := *info.Camera
+		scratch.Camera = &camera
+	}
+	if err := e.readEXIFFields(x, &scratch, filePath); err != nil {
+		slog.Warn("Ignoring malformed EXIF data", "error", err, "file", filePath)
+		return
+	}
+	*info = scratch
+}
+
+var errEXIFTimeout = errors.New("EXIF decoding timed out")
+
+// extractImageDimensions reads a photo's pixel size from its header so grids
+// can reserve the right aspect ratio. The size is as displayed: photos whose
+// EXIF orientation turns them a quarter have width and height swapped.
+// Formats the standard decoders don't know, and corrupt or truncated files,
+// keep zero.
+func (e *Extractor) extractImageDimensions(filePath string, info *MediaInfo) {
+	if info.MediaType != MediaTypePhoto {
+		return
+	}
+
+	info.Width, info.Height = imageDimensions(filePath)
+	if info.Rotation == 90 || info.Rotation == 270 {
+		info.Width, info.Height = info.Height, info.Width
+	}
+}
+
+// decodeEXIF parses EXIF from the head of the file. Most files keep it in the
+// first exifPrefix bytes, so only those are read during scans; files whose
+// EXIF is cut off or sits behind large segments are read again up to
+// exifReadLimit. HEIF
+// files keep EXIF wherever their item locations say, so it is read from there.
+func (e *Extractor) decodeEXIF(filePath string) (*exif.Exif, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
 	defer file.Close()
 
-	x, err := exif.Decode(file)
+	prefix := min(e.exifPrefix, e.exifReadLimit)
+	if prefix <= 0 {
+		prefix = e.exifReadLimit
+	}
+	head, err := io.ReadAll(io.LimitReader(file, prefix))
 	if err != nil {
-		slog.Debug("Failed to decode EXIF data", "error", err, "file", filePath)
-		return
+		return nil, err
 	}
 
+	if isHEIF(head) {
+		stat, err := file.Stat()
+		if err != nil {
+			return nil, err
+		}
+		data, err := readHEIFEXIF(file, stat.Size(), e.exifReadLimit)
+		if err != nil {
+			return nil, err
+		}
+		return e.decodeEXIFBytes(data)
+	}
+
+	x, err := e.decodeEXIFBytes(head)
+	if err == nil || errors.Is(err, errEXIFTimeout) || int64(len(head)) < prefix || prefix >= e.exifReadLimit || !exifTruncated(head) {
+		return x, err
+	}
+
+	rest, err := io.ReadAll(io.LimitReader(file, e.exifReadLimit-prefix))
+	if err != nil {
+		return nil, err
+	}
+	return e.decodeEXIFBytes(append(head, rest...))
+}
+
+// exifTruncated reports whether EXIF may lie past the end of head: TIFF-based
+// raw files can point their IFDs anywhere, and a JPEG whose segments run past
+// head before the image data starts may have its EXIF cut off or still ahead.
+// Files without EXIF, such as PNGs or JPEGs whose image data starts in head,
+// aren't read again.
+func exifTruncated(head []byte) bool {
+	if bytes.HasPrefix(head, []byte("II*\x00")) || bytes.HasPrefix(head, []byte("MM\x00*")) {
+		return true
+	}
+	if len(head) < 2 || head[0] != 0xFF || head[1] != 0xD8 {
+		return false
+	}
+
+	for pos := 2; pos < len(head); {
+		if pos+4 > len(head) {
+			return true
+		}
+		if head[pos] != 0xFF || head[pos+1] == 0xDA || head[pos+1] == 0xD9 {
+			return false
+		}
+		pos += 2 + int(binary.BigEndian.Uint16(head[pos+2:]))
+	}
+	return true
+}
+
+// decodeEXIFBytes parses EXIF from a file head. Untrusted uploads go through
+// here, so tag counts that would exhaust memory are refused before decoding,
+// decoder panics become errors and a decoder that doesn't finish in time is
+// abandoned. Abandoned decoders hold one of the exifDecoders slots until they
+// return, so at most that many run at once; when stuck ones fill every slot,
+// decoding times out without starting another.
+func (e *Extractor) decodeEXIFBytes(head []byte) (*exif.Exif, error) {
+	if err := checkEXIFCounts(head); err != nil {
+		return nil, err
+	}
+
+	timeout := time.NewTimer(e.exifTimeout)
+	defer timeout.Stop()
+
+	select {
+	case e.exifDecoders <- struct{}{}:
+	case <-timeout.C:
+		return nil, fmt.Errorf("%w waiting for a decoder after %v", errEXIFTimeout, e.exifTimeout)
+	}
+
+	type decodeResult struct {
+		x   *exif.Exif
+		err error
+	}
+	done := make(chan decodeResult, 1)
+
+	go func() {
+		var result decodeResult
+		defer func() {
+			if r := recover(); r != nil {
+				result = decodeResult{err: fmt.Errorf("EXIF decoder panicked: %v", r)}
+			}
+			<-e.exifDecoders
+			done <- result
+		}()
+		result.x, result.err = exif.Decode(bytes.NewReader(head))
+	}()
+
+	select {
+	case result := <-done:
+		return result.x, result.err
+	case <-timeout.C:
+		return nil, fmt.Errorf("%w after %v", errEXIFTimeout, e.exifTimeout)
+	}
+}
+
+func (e *Extractor) readEXIFFields(x *exif.Exif, info *MediaInfo, filePath string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("EXIF field read panicked: %v", r)
+		}
+	}()
+
 	if dt, err := x.DateTime(); err == nil {
+		// goexif falls back to the server's zone when the file carries none
+		if tz, _ := x.TimeZone(); tz == nil {
+			dt = wallClock(dt.Year(), dt.Month(), dt.Day(), dt.Hour(), dt.Minute(), dt.Second(), dt.Nanosecond(), e.location)
+		}
 		info.DateTaken = &dt
 		info.DateSource = DateSourceEXIF
 		slog.Debug("Date extracted from EXIF", "date", dt, "file", filePath)
@@ -117,26 +340,156 @@ func (e *Extractor) extractDateFromEXIF(filePath string, info *MediaInfo) {
 		}
 	}
 
-	if lat, long, err := x.LatLong(); err == nil {
+	readExposureFields(x, info.Camera)
+
+	if orientation, err := x.Get(exif.Orientation); err == nil {
+		if value, err := orientation.Int(0); err == nil {
+			info.Rotation = exifOrientationRotation(value)
+		}
+	}
+
+	if lat, long, err := x.LatLong(); err == nil && plausibleGPS(lat, long, e.keepNullIsland) {
 		info.Location = &LocationInfo{
 			Latitude:  lat,
 			Longitude: long,
 		}
 	}
+
+	if caption := exifCaption(x); caption != "" {
+		if info.ExtraMetadata == nil {
+			info.ExtraMetadata = make(map[string]string)
+		}
+		info.ExtraMetadata["caption"] = caption
+	}
+
+	return nil
+}
+
+// plausibleGPS reports whether EXIF coordinates look like a real fix. Values
+// off the globe are never one. Many cameras write exactly 0,0 when they have
+// no fix, so that spot in the Gulf of Guinea is only trusted when
+// keepNullIsland is set.
+func plausibleGPS(lat, long float64, keepNullIsland bool) bool {
+	if math.IsNaN(lat) || math.IsNaN(long) || math.Abs(lat) > 90 || math.Abs(long) > 180 {
+		return false
+	}
+	return keepNullIsland || lat != 0 || long != 0
+}
+
+// exifOrientationRotation maps the EXIF orientation tag to the clockwise
+// rotation needed for display. Mirrored orientations are treated as their
+// unmirrored counterpart since only rotation is surfaced.
+func exifOrientationRotation(orientation int) int {
+	switch orientation {
+	case 3, 4:
+		return 180
+	case 5, 6:
+		return 90
+	case 7, 8:
+		return 270
+	default:
+		return 0
+	}
+}
+
+// extractVideoMetadata reads duration, frame size, display rotation and, when
+// nothing earlier dated the file, the recording date via ffprobe. It is
+// best-effort: without ffprobe installed, videos simply keep the defaults.
+func (e *Extractor) extractVideoMetadata(filePath string, info *MediaInfo) {
+	if info.MediaType != MediaTypeVideo || e.ffprobePath == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ffprobeTimeout)
+	defer cancel()
+
+	output, err := e.probe(ctx, e.ffprobePath, filePath)
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+			slog.Debug("ffprobe not available, skipping video metadata", "file", filePath)
+		} else {
+			slog.Debug("ffprobe failed", "error", err, "file", filePath)
+		}
+		return
+	}
+
+	result, err := parseProbeOutput(output)
+	if err != nil {
+		slog.Debug("Failed to read video metadata", "error", err, "file", filePath)
+		return
+	}
+
+	if duration, ok := result.duration(); ok {
+		d := Duration(duration)
+		info.Duration = &d
+		info.ExtraMetadata["duration"] = formatDuration(duration)
+	}
+
+	if stream := result.videoStream(); stream != nil {
+		info.Width, info.Height = stream.Width, stream.Height
+		info.Rotation = stream.rotation()
+		if info.Rotation != 0 {
+			info.ExtraMetadata["rotation"] = strconv.Itoa(info.Rotation)
+		}
+	}
+
+	if info.DateTaken == nil {
+		if date, ok := result.creationTime(); ok {
+			if date.Location() == time.UTC {
+				date = date.In(e.location)
+			}
+			info.DateTaken = &date
+			info.DateSource = DateSourceVideoMeta
+			slog.Debug("Date extracted from video metadata", "date", date, "file", filePath)
+		}
+	}
 }
 
 func (e *Extractor) extractDateFromFilename(filename string, info *MediaInfo) {
+	if date, _ := e.MatchFilename(filename); date != nil {
+		info.DateTaken = date
+		info.DateSource = DateSourceFileName
+		slog.Debug("Date extracted from filename", "filename", filename, "date", date)
+	}
+}
+
+// MatchFilename returns the date the filename patterns find in filename and
+// the pattern that found it, or nil and "" when none does.
+func (e *Extractor) MatchFilename(filename string) (*time.Time, string) {
 	for _, pattern := range e.filenamePatterns {
-		matches := pattern.FindStringSubmatch(filename)
+		matches := pattern.re.FindStringSubmatch(filename)
 		if len(matches) > 0 {
 			if date := e.parseFilenameMatches(matches); date != nil {
-				info.DateTaken = date
-				info.DateSource = DateSourceFileName
-				slog.Debug("Date extracted from filename", "filename", filename, "date", date)
-				return
+				return date, pattern.Pattern
 			}
 		}
 	}
+	return nil, ""
+}
+
+// FilenamePatterns returns the patterns MatchFilename tries, in order.
+func (e *Extractor) FilenamePatterns() []FilenamePattern {
+	return slices.Clone(e.filenamePatterns)
+}
+
+// addCustomFilenamePatterns puts user patterns ahead of the built-in ones, so
+// they win for files both match. Their groups capture year, month and day,
+// optionally followed by hour, minute and second. Patterns that don't compile
+// or capture too little are logged and skipped.
+func (e *Extractor) addCustomFilenamePatterns(exprs []string) {
+	var custom []FilenamePattern
+	for _, expr := range exprs {
+		compiled, err := regexp.Compile(expr)
+		if err == nil && compiled.NumSubexp() < 3 {
+			err = fmt.Errorf("captures %d groups, need year, month and day", compiled.NumSubexp())
+		}
+		if err != nil {
+			slog.Error("Skipping custom filename pattern", "pattern", expr, "error", err)
+			continue
+		}
+		custom = append(custom, FilenamePattern{Pattern: expr, Source: PatternSourceCustom, re: compiled})
+	}
+	e.filenamePatterns = append(custom, e.filenamePatterns...)
 }
 
 func (e *Extractor) parseFilenameMatches(matches []string) *time.Time {
@@ -165,47 +518,125 @@ func (e *Extractor) parseFilenameMatches(matches []string) *time.Time {
 		}
 	}
 
-	date := time.Date(year, time.Month(month), day, hour, minute, second, 0, time.UTC)
+	nanosecond := 0
+	if len(matches) >= 8 && matches[7] != "" {
+		// Scale 3-digit milliseconds or 6-digit microseconds to nanoseconds
+		if fraction, err := strconv.Atoi((matches[7] + "000000000")[:9]); err == nil {
+			nanosecond = fraction
+		}
+	}
+
+	date := wallClock(year, time.Month(month), day, hour, minute, second, nanosecond, e.location)
 	return &date
 }
 
+// wallClock is time.Date for a wall-clock reading that must stay on its
+// calendar day. Where a DST transition skips midnight, as in Santiago or
+// Havana, time.Date resolves a bare date to 23:00 the evening before; this
+// returns the first instant of the intended day instead. Readings repeated
+// when clocks go back already resolve to the first occurrence, which is on
+// the right day.
+func wallClock(year int, month time.Month, day, hour, minute, second, nanosecond int, loc *time.Location) time.Time {
+	date := time.Date(year, month, day, hour, minute, second, nanosecond, loc)
+	if y, m, d := date.Date(); y == year && m == month && d == day {
+		return date
+	}
+
+	// The reading fell in a gap; the day starts when the gap ends
+	_, gapEnd := date.ZoneBounds()
+	if gapEnd.IsZero() {
+		return date
+	}
+	return gapEnd
+}
+
 func (e *Extractor) extractDateFromFileTime(fileInfo os.FileInfo, info *MediaInfo) {
-	modTime := fileInfo.ModTime()
+	modTime := e.fileTime(fileInfo)
 	info.DateTaken = &modTime
 	info.DateSource = DateSourceFileTime
 	slog.Debug("Using file modification time", "date", modTime)
 }
 
+// fileTime returns the modification time in the extractor's zone.
+func (e *Extractor) fileTime(fileInfo os.FileInfo) time.Time {
+	return fileInfo.ModTime().In(e.location)
+}
+
 func (e *Extractor) NeedsUserInput(info *MediaInfo) bool {
 	return info.DateSource == DateSourceFileTime || info.DateSource == DateSourceUnknown
 }
 
-func buildFilenamePatterns() []*regexp.Regexp {
-	patterns := []string{
-		// IMG_20231225_143022.jpg
-		`IMG_(\d{4})(\d{2})(\d{2})_(\d{2})(\d{2})(\d{2})`,
-		// 20231225_143022.jpg
-		`(\d{4})(\d{2})(\d{2})_(\d{2})(\d{2})(\d{2})`,
-		// 2023-12-25_14-30-22.jpg
-		`(\d{4})-(\d{2})-(\d{2})_(\d{2})-(\d{2})-(\d{2})`,
-		// 2023-12-25.jpg
-		`(\d{4})-(\d{2})-(\d{2})`,
-		// 20231225.jpg
-		`(\d{4})(\d{2})(\d{2})`,
-		// VID_20231225_143022.mp4
-		`VID_(\d{4})(\d{2})(\d{2})_(\d{2})(\d{2})(\d{2})`,
-		// Screenshot_2023-12-25-14-30-22.png
-		`Screenshot_(\d{4})-(\d{2})-(\d{2})-(\d{2})-(\d{2})-(\d{2})`,
-		// WhatsApp Image 2023-12-25 at 14.30.22.jpeg
-		`WhatsApp.+(\d{4})-(\d{2})-(\d{2}).+(\d{2})\.(\d{2})\.(\d{2})`,
-	}
-
-	var compiledPatterns []*regexp.Regexp
+// fractionalSeconds optionally captures milliseconds or microseconds directly
+// after the seconds, as Pixel and burst-mode filenames include them.
+const fractionalSeconds = `(?:\.?(\d{6}|\d{3}))?`
+
+// Filename pattern priorities. Extraction stops at the first pattern that
+// matches, so more specific patterns must be tried first: a bare date would
+// otherwise claim IMG_20231225_143022.jpg and drop the time of day.
+const (
+	priorityDate           = 10 // Date only
+	priorityDateTime       = 20 // Date and time of day
+	priorityPrefixDateTime = 30 // Date and time anchored to a known app prefix
+)
+
+type filenamePattern struct {
+	expr     string
+	priority int
+	example  string
+}
+
+// Where a filename pattern came from.
+const (
+	PatternSourceBuiltin = "builtin"
+	PatternSourceCustom  = "custom"
+)
+
+// FilenamePattern is a regular expression dates are read from filenames with.
+type FilenamePattern struct {
+	Pattern string `json:"pattern"`
+	Source  string `json:"source"`
+	Example string `json:"example,omitempty"` // A filename it dates; empty for custom patterns
+	re      *regexp.Regexp
+}
+
+// buildFilenamePatterns compiles the filename patterns ordered from highest to
+// lowest priority; patterns of equal priority keep their declared order.
+func buildFilenamePatterns() []FilenamePattern {
+	patterns := []filenamePattern{
+		// IMG_20231225_143022.jpg, IMG_20231225_143022123.jpg
+		{`IMG_(\d{4})(\d{2})(\d{2})_(\d{2})(\d{2})(\d{2})` + fractionalSeconds, priorityPrefixDateTime, "IMG_20231225_143022.jpg"},
+		{`VID_(\d{4})(\d{2})(\d{2})_(\d{2})(\d{2})(\d{2})` + fractionalSeconds, priorityPrefixDateTime, "VID_20231225_143022.mp4"},
+		{`Screenshot_(\d{4})-(\d{2})-(\d{2})-(\d{2})-(\d{2})-(\d{2})`, priorityPrefixDateTime, "Screenshot_2023-12-25-14-30-22.png"},
+		{`WhatsApp.+(\d{4})-(\d{2})-(\d{2}).+(\d{2})\.(\d{2})\.(\d{2})`, priorityPrefixDateTime, "WhatsApp Image 2023-12-25 at 14.30.22.jpeg"},
+		// 20231225_143022.jpg, PXL_20231225_143022123.jpg, 20231225_143022.123456.jpg
+		{`(\d{4})(\d{2})(\d{2})_(\d{2})(\d{2})(\d{2})` + fractionalSeconds, priorityDateTime, "20231225_143022.jpg"},
+		{`(\d{4})-(\d{2})-(\d{2})_(\d{2})-(\d{2})-(\d{2})`, priorityDateTime, "2023-12-25_14-30-22.jpg"},
+		// EXIF's own layout, and the underscores it becomes once stored, since
+		// colons are sanitized out of library file names
+		{`(\d{4})[:_](\d{2})[:_](\d{2})[ _T](\d{2})[:_](\d{2})[:_](\d{2})`, priorityDateTime, "2023:12:25 14:30:22.jpg"},
+		{`(\d{4})-(\d{2})-(\d{2})`, priorityDate, "2023-12-25.jpg"},
+		// Only as the whole name's start, so counters such as IMG_2048_01_02
+		// aren't mistaken for dates
+		{`^((?:19|20)\d{2})[:_](\d{2})[:_](\d{2})(?:\D|$)`, priorityDate, "2023:12:25.jpg"},
+		// Not inside a longer run of digits such as a counter
+		{`(?:^|\D)(\d{4})(\d{2})(\d{2})(?:\D|$)`, priorityDate, "20231225.jpg"},
+	}
+
+	sort.SliceStable(patterns, func(i, j int) bool {
+		return patterns[i].priority > patterns[j].priority
+	})
+
+	var compiledPatterns []FilenamePattern
 	for _, pattern := range patterns {
-		if compiled, err := regexp.Compile(pattern); err == nil {
-			compiledPatterns = append(compiledPatterns, compiled)
+		if compiled, err := regexp.Compile(pattern.expr); err == nil {
+			compiledPatterns = append(compiledPatterns, FilenamePattern{
+				Pattern: pattern.expr,
+				Source:  PatternSourceBuiltin,
+				Example: pattern.example,
+				re:      compiled,
+			})
 		} else {
-			slog.Error("Failed to compile filename pattern", "pattern", pattern, "error", err)
+			slog.Error("Failed to compile filename pattern", "pattern", pattern.expr, "error", err)
 		}
 	}
 
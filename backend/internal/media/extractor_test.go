@@ -1,8 +1,17 @@
 package media
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
 	"os"
 	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -41,7 +50,62 @@ func TestExtractDateFromFilename(t *testing.T) {
 		},
 		{
 			filename:     "Screenshot_2020-01-01-10-30-45.png",
-			expectedDate: timePtr(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)), // The pattern only extracts date, not time
+			expectedDate: timePtr(time.Date(2020, 1, 1, 10, 30, 45, 0, time.UTC)),
+			hasDate:      true,
+		},
+		{
+			filename:     "WhatsApp Image 2023-12-25 at 14.30.22.jpeg",
+			expectedDate: timePtr(time.Date(2023, 12, 25, 14, 30, 22, 0, time.UTC)),
+			hasDate:      true,
+		},
+		{
+			filename:     "2023:12:25 14:30:22.jpg",
+			expectedDate: timePtr(time.Date(2023, 12, 25, 14, 30, 22, 0, time.UTC)),
+			hasDate:      true,
+		},
+		{
+			filename:     "2023_12_25 14_30_22.jpg",
+			expectedDate: timePtr(time.Date(2023, 12, 25, 14, 30, 22, 0, time.UTC)),
+			hasDate:      true,
+		},
+		{
+			filename:     "2023:12:25.jpg",
+			expectedDate: timePtr(time.Date(2023, 12, 25, 0, 0, 0, 0, time.UTC)),
+			hasDate:      true,
+		},
+		{
+			filename:     "IMG_2048_01_02.jpg",
+			expectedDate: nil,
+			hasDate:      false,
+		},
+		{
+			filename:     "1234_01_02.jpg",
+			expectedDate: nil,
+			hasDate:      false,
+		},
+		{
+			filename:     "scan_20231225.jpg",
+			expectedDate: timePtr(time.Date(2023, 12, 25, 0, 0, 0, 0, time.UTC)),
+			hasDate:      true,
+		},
+		{
+			filename:     "DSC_1234567890123.jpg",
+			expectedDate: nil,
+			hasDate:      false,
+		},
+		{
+			filename:     "PXL_20231225_143022123.jpg",
+			expectedDate: timePtr(time.Date(2023, 12, 25, 14, 30, 22, 123000000, time.UTC)),
+			hasDate:      true,
+		},
+		{
+			filename:     "IMG_20231225_143022123456.jpg",
+			expectedDate: timePtr(time.Date(2023, 12, 25, 14, 30, 22, 123456000, time.UTC)),
+			hasDate:      true,
+		},
+		{
+			filename:     "20231225_143022.250.jpg",
+			expectedDate: timePtr(time.Date(2023, 12, 25, 14, 30, 22, 250000000, time.UTC)),
 			hasDate:      true,
 		},
 		{
@@ -82,6 +146,90 @@ func TestExtractDateFromFilename(t *testing.T) {
 	}
 }
 
+func TestExtractDateFromFilenameAcrossDST(t *testing.T) {
+	tests := []struct {
+		name     string
+		zone     string
+		filename string
+		expected string // Local wall clock
+	}{
+		// Clocks jump from 00:00 to 01:00, so midnight never happens
+		{"Spring forward at midnight", "America/Santiago", "scan_20220911.jpg", "2022-09-11 01:00:00"},
+		{"Spring forward keeps later times", "America/Santiago", "IMG_20220911_083000.jpg", "2022-09-11 08:30:00"},
+		// Clocks go back from 01:00 to 00:00, so midnight happens twice
+		{"Fall back at midnight", "America/Havana", "scan_20221106.jpg", "2022-11-06 00:00:00"},
+		{"Fall back outside midnight", "America/New_York", "scan_20221106.jpg", "2022-11-06 00:00:00"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			loc, err := time.LoadLocation(test.zone)
+			if err != nil {
+				t.Skipf("Time zone data unavailable: %v", err)
+			}
+
+			info := &MediaInfo{ExtraMetadata: make(map[string]string)}
+			NewExtractorInLocation(loc).extractDateFromFilename(test.filename, info)
+
+			if info.DateTaken == nil {
+				t.Fatal("Expected date to be extracted, got nil")
+			}
+			if got := info.DateTaken.In(loc).Format(time.DateTime); got != test.expected {
+				t.Errorf("Expected %s in %s, got %s", test.expected, test.zone, got)
+			}
+		})
+	}
+}
+
+func TestExtractDateFromTakeoutSidecar(t *testing.T) {
+	// 2020-03-15 14:30:22 UTC
+	sidecar := `{"title": "%s", "photoTakenTime": {"timestamp": "1584282622", "formatted": "Mar 15, 2020, 2:30:22 PM UTC"}}`
+	expected := time.Date(2020, 3, 15, 14, 30, 22, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		fileName       string
+		sidecarSuffix  string
+		exif           *exifFixture
+		expectedSource DateSource
+	}{
+		{"Sidecar overrides filename", "IMG_20240101_120000.jpg", ".json", nil, DateSourceTakeout},
+		{"Supplemental metadata sidecar", "photo.jpg", ".supplemental-metadata.json", nil, DateSourceTakeout},
+		{"No sidecar", "IMG_20240101_120000.jpg", "", nil, DateSourceFileName},
+		{"EXIF beats sidecar", "photo.jpg", ".json", &exifFixture{DateTimeOriginal: "2020:03:15 14:30:22"}, DateSourceEXIF},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			content := buildEXIFJPEG(t, exifFixture{})
+			if test.exif != nil {
+				content = buildEXIFJPEG(t, *test.exif)
+			}
+			path := filepath.Join(dir, test.fileName)
+			if err := os.WriteFile(path, content, 0644); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+			if test.sidecarSuffix != "" {
+				if err := os.WriteFile(path+test.sidecarSuffix, []byte(fmt.Sprintf(sidecar, test.fileName)), 0644); err != nil {
+					t.Fatalf("Failed to create sidecar: %v", err)
+				}
+			}
+
+			info, err := NewExtractor().ExtractMetadata(path)
+			if err != nil {
+				t.Fatalf("ExtractMetadata failed: %v", err)
+			}
+			if info.DateSource != test.expectedSource {
+				t.Errorf("Expected date source %s, got %s", test.expectedSource, info.DateSource)
+			}
+			if test.expectedSource != DateSourceFileName && !info.DateTaken.Equal(expected) {
+				t.Errorf("Expected date %v, got %v", expected, info.DateTaken)
+			}
+		})
+	}
+}
+
 func TestExtractMetadataFromJPEG(t *testing.T) {
 	// Create a test JPEG file without EXIF data
 	tempDir := t.TempDir()
@@ -233,23 +381,26 @@ func TestBuildFilenamePatterns(t *testing.T) {
 
 	// Test that each pattern compiles
 	for i, pattern := range patterns {
-		if pattern == nil {
+		if pattern.re == nil {
 			t.Errorf("Pattern %d should not be nil", i)
 		}
 	}
 
-	// Test a known pattern matches expected format
+	// The first matching pattern wins, so it must be the datetime pattern
+	// that keeps the time rather than a bare-date pattern
 	testString := "IMG_20240315_143022"
-	matched := false
+	var matches []string
 	for _, pattern := range patterns {
-		if pattern.MatchString(testString) {
-			matched = true
+		if matches = pattern.re.FindStringSubmatch(testString); matches != nil {
 			break
 		}
 	}
 
-	if !matched {
-		t.Error("Expected at least one pattern to match IMG_20240315_143022 format")
+	if matches == nil {
+		t.Fatal("Expected at least one pattern to match IMG_20240315_143022 format")
+	}
+	if len(matches) < 7 || matches[4] != "14" || matches[5] != "30" || matches[6] != "22" {
+		t.Errorf("Expected first match to capture time 14:30:22, got %q", matches)
 	}
 }
 
@@ -327,3 +478,571 @@ func TestNeedsUserInput(t *testing.T) {
 func timePtr(t time.Time) *time.Time {
 	return &t
 }
+
+func TestExtractMetadataMalformedEXIF(t *testing.T) {
+	tempDir := t.TempDir()
+
+	valid := buildEXIFJPEG(t, exifFixture{Make: "Canon", DateTimeOriginal: "2024:03:15 14:30:22"})
+	tests := []struct {
+		name    string
+		content []byte
+	}{
+		{"Truncated EXIF segment", valid[:40]},
+		{"Garbage after SOI", append([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0xFF, 0xFF}, bytes.Repeat([]byte{0xFF}, 4096)...)},
+	}
+
+	extractor := NewExtractor()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testFile := filepath.Join(tempDir, "IMG_20200101_120000.jpg")
+			if err := os.WriteFile(testFile, test.content, 0644); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+
+			info, err := extractor.ExtractMetadata(testFile)
+			if err != nil {
+				t.Fatalf("ExtractMetadata failed: %v", err)
+			}
+
+			// Treated as having no EXIF: the date falls back to the filename
+			if info.DateSource != DateSourceFileName {
+				t.Errorf("Expected date source %s, got %s", DateSourceFileName, info.DateSource)
+			}
+			if info.Location != nil {
+				t.Errorf("Expected no location, got %+v", info.Location)
+			}
+		})
+	}
+}
+
+func TestRescanSurvivesMalformedEXIF(t *testing.T) {
+	// goexif sizes a tag as count*typeSize in 32 bits; this latitude's count
+	// wraps that to 8 bytes, after which goexif would allocate ~12GB for the
+	// claimed values and the process would die, panic recovery or not.
+	malformed := buildEXIFJPEG(t, exifFixture{
+		Make:             "Canon",
+		DateTimeOriginal: "2024:03:15 14:30:22",
+		GPS: []exifEntry{
+			asciiEntry(0x0001, "N"),
+			{tag: 0x0002, kind: 5, count: 1<<29 + 1, data: make([]byte, 8)},
+			asciiEntry(0x0003, "E"),
+			rationalEntry(0x0004, 1, 1, 2, 1, 3, 1),
+		},
+	})
+
+	fixturePath := filepath.Join(t.TempDir(), "fixture.jpg")
+	if err := os.WriteFile(fixturePath, malformed, 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if _, err := NewExtractor().decodeEXIF(fixturePath); err == nil || !strings.Contains(err.Error(), "claims") {
+		t.Fatalf("Expected the oversized tag count to be refused, got %v", err)
+	}
+
+	mediaDir := t.TempDir()
+	monthDir := filepath.Join(mediaDir, "2020", "January")
+	if err := os.MkdirAll(monthDir, 0755); err != nil {
+		t.Fatalf("Failed to create month folder: %v", err)
+	}
+	valid := buildEXIFJPEG(t, exifFixture{DateTimeOriginal: "2020:01:02 10:00:00"})
+	for name, content := range map[string][]byte{
+		"IMG_20200101_120000.jpg": malformed,
+		"valid.jpg":               valid,
+	} {
+		if err := os.WriteFile(filepath.Join(monthDir, name), content, 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	organizer := NewOrganizer(mediaDir)
+	result, err := organizer.Rescan(context.Background(), RescanOptions{})
+	if err != nil {
+		t.Fatalf("Rescan failed: %v", err)
+	}
+	if result.Total != 2 || len(result.Failed) != 0 {
+		t.Errorf("Expected 2 files rescanned without failures, got %d with failures %v", result.Total, result.Failed)
+	}
+
+	files, err := organizer.ScanFiles("2020", "January", 10, 0)
+	if err != nil {
+		t.Fatalf("ScanFiles failed: %v", err)
+	}
+	dates := make(map[string]time.Time)
+	for _, file := range files {
+		if file.DateTaken != nil {
+			dates[file.FileName] = *file.DateTaken
+		}
+	}
+	// The malformed file's EXIF is ignored as a whole, so it is dated by name
+	if expected := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC); !dates["IMG_20200101_120000.jpg"].Equal(expected) {
+		t.Errorf("Expected malformed file dated %v from its name, got %v", expected, dates["IMG_20200101_120000.jpg"])
+	}
+	if expected := time.Date(2020, 1, 2, 10, 0, 0, 0, time.UTC); !dates["valid.jpg"].Equal(expected) {
+		t.Errorf("Expected valid file dated %v from EXIF, got %v", expected, dates["valid.jpg"])
+	}
+}
+
+func TestReadEXIFFieldsRecoversPanic(t *testing.T) {
+	// A nil decode result panics inside goexif's accessors; the panic must
+	// surface as an error and leave the info untouched.
+	info := &MediaInfo{DateSource: DateSourceUnknown}
+	if err := NewExtractor().readEXIFFields(nil, info, "photo.jpg"); err == nil {
+		t.Error("Expected error from panicking EXIF read")
+	}
+	if info.DateSource != DateSourceUnknown {
+		t.Errorf("Expected date source %s, got %s", DateSourceUnknown, info.DateSource)
+	}
+}
+
+func TestDecodeEXIFBytesBoundsDecoders(t *testing.T) {
+	photo := buildEXIFJPEG(t, exifFixture{DateTimeOriginal: "2024:03:15 14:30:22"})
+	extractor := NewExtractor()
+	extractor.exifDecoders = make(chan struct{}, 1)
+	extractor.exifTimeout = 50 * time.Millisecond
+
+	// A stuck decoder holds the only slot
+	extractor.exifDecoders <- struct{}{}
+	if _, err := extractor.decodeEXIFBytes(photo); !errors.Is(err, errEXIFTimeout) {
+		t.Errorf("Expected a timeout while every decoder is busy, got %v", err)
+	}
+
+	<-extractor.exifDecoders
+	if _, err := extractor.decodeEXIFBytes(photo); err != nil {
+		t.Fatalf("Expected the freed slot to decode, got %v", err)
+	}
+	if len(extractor.exifDecoders) != 0 {
+		t.Error("Expected the slot released once decoding finished")
+	}
+}
+
+func TestExtractMetadataEXIFReadLimit(t *testing.T) {
+	// EXIF placed beyond the read limit is not found, proving only the file
+	// head is read.
+	padding := append([]byte{0xFF, 0xD8}, bytes.Repeat([]byte{0}, 2048)...)
+	content := append(padding, buildEXIFJPEG(t, exifFixture{DateTimeOriginal: "2024:03:15 14:30:22"})[2:]...)
+
+	testFile := filepath.Join(t.TempDir(), "photo.jpg")
+	if err := os.WriteFile(testFile, content, 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	extractor := NewExtractor()
+	extractor.exifReadLimit = 1024
+
+	info, err := extractor.ExtractMetadata(testFile)
+	if err != nil {
+		t.Fatalf("ExtractMetadata failed: %v", err)
+	}
+	if info.DateSource == DateSourceEXIF {
+		t.Error("Expected EXIF beyond the read limit to be ignored")
+	}
+
+	extractor.exifReadLimit = defaultEXIFReadLimit
+	if info, err = extractor.ExtractMetadata(testFile); err != nil || info.DateSource != DateSourceEXIF {
+		t.Errorf("Expected EXIF date within the default limit, got %v (err %v)", info.DateSource, err)
+	}
+}
+
+func TestExtractMetadataEXIFPrefix(t *testing.T) {
+	fixture := exifFixture{
+		Make:             "Canon",
+		Model:            "Canon EOS R5",
+		DateTimeOriginal: "2024:03:15 14:30:22",
+		Orientation:      6,
+		FocalLength:      [2]uint32{50, 1},
+		FNumber:          [2]uint32{18, 10},
+		ExposureTime:     [2]uint32{1, 200},
+		ISO:              100,
+	}
+	photo := buildEXIFJPEG(t, fixture)
+
+	// Two ICC profile segments push whatever follows them past the prefix
+	var profile []byte
+	for range 2 {
+		profile = append(profile, 0xFF, 0xE2, 0xEA, 0x62)
+		profile = append(profile, bytes.Repeat([]byte{0}, 60000)...)
+	}
+	imageData := append([]byte{0xFF, 0xDA, 0x00, 0x02}, bytes.Repeat([]byte{0}, 1<<20)...)
+
+	tests := []struct {
+		name    string
+		content []byte
+		hasEXIF bool
+	}{
+		{"EXIF in the prefix", photo, true},
+		{"Large file", append(append([]byte{}, photo...), bytes.Repeat([]byte{0}, 1<<20)...), true},
+		// Only found by reading past the prefix
+		{"EXIF behind large segments", slices.Concat([]byte{0xFF, 0xD8}, profile, photo[2:]), true},
+		{"No EXIF behind large segments", slices.Concat([]byte{0xFF, 0xD8}, profile, imageData), false},
+		{"No EXIF", slices.Concat([]byte{0xFF, 0xD8}, imageData), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testFile := filepath.Join(t.TempDir(), "photo.jpg")
+			if err := os.WriteFile(testFile, test.content, 0644); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+
+			whole := NewExtractor()
+			whole.exifPrefix = whole.exifReadLimit
+			expected, err := whole.ExtractMetadata(testFile)
+			if err != nil {
+				t.Fatalf("ExtractMetadata failed: %v", err)
+			}
+			if (expected.DateSource == DateSourceEXIF) != test.hasEXIF {
+				t.Fatalf("Expected EXIF found to be %v, got date source %s", test.hasEXIF, expected.DateSource)
+			}
+
+			actual, err := NewExtractor().ExtractMetadata(testFile)
+			if err != nil {
+				t.Fatalf("ExtractMetadata failed: %v", err)
+			}
+			if !reflect.DeepEqual(actual, expected) {
+				t.Errorf("Expected the same metadata as a single read\nwant %+v\ngot  %+v", expected, actual)
+			}
+		})
+	}
+}
+
+func TestEXIFTruncated(t *testing.T) {
+	photo := buildEXIFJPEG(t, exifFixture{DateTimeOriginal: "2024:03:15 14:30:22"})
+
+	tests := []struct {
+		name     string
+		head     []byte
+		expected bool
+	}{
+		{"Whole EXIF segment", photo, false},
+		{"EXIF segment cut off", photo[:len(photo)/2], true},
+		{"Head ends between segments", []byte{0xFF, 0xD8, 0xFF, 0xE0, 0x00, 0x04, 0x00, 0x00}, true},
+		{"Segment runs past head", []byte{0xFF, 0xD8, 0xFF, 0xE2, 0xEA, 0x62, 0x00}, true},
+		{"Image data reached", []byte{0xFF, 0xD8, 0xFF, 0xDA, 0x00, 0x02, 0x00, 0x00}, false},
+		{"Not a segment", []byte{0xFF, 0xD8, 0x00, 0x00, 0x00, 0x00}, false},
+		{"TIFF", []byte("II*\x00\x08\x00\x00\x00"), true},
+		{"PNG", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0dIHDR"), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if actual := exifTruncated(test.head); actual != test.expected {
+				t.Errorf("Expected %v, got %v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func BenchmarkExtractMetadataEXIF(b *testing.B) {
+	// A camera JPEG: EXIF up front, then megabytes of image data
+	content := append(buildEXIFJPEG(b, exifFixture{DateTimeOriginal: "2024:03:15 14:30:22"}), bytes.Repeat([]byte{0}, 8<<20)...)
+	testFile := filepath.Join(b.TempDir(), "photo.jpg")
+	if err := os.WriteFile(testFile, content, 0644); err != nil {
+		b.Fatalf("Failed to create test file: %v", err)
+	}
+
+	for _, prefix := range []int64{defaultEXIFPrefix, defaultEXIFReadLimit} {
+		b.Run(fmt.Sprintf("prefix=%dKiB", prefix/1024), func(b *testing.B) {
+			extractor := NewExtractor()
+			extractor.exifPrefix = prefix
+			for i := 0; i < b.N; i++ {
+				if _, err := extractor.ExtractMetadata(testFile); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestExtractMetadataCaption(t *testing.T) {
+	ucs2 := func(order string, s string) []byte {
+		out := []byte("UNICODE\x00")
+		for _, r := range s {
+			if order == "BE" {
+				out = append(out, byte(r>>8), byte(r))
+			} else {
+				out = append(out, byte(r), byte(r>>8))
+			}
+		}
+		return out
+	}
+
+	tests := []struct {
+		name     string
+		fixture  exifFixture
+		expected string
+	}{
+		{
+			name:     "Image description",
+			fixture:  exifFixture{ImageDescription: "Sunset at Brighton beach   "},
+			expected: "Sunset at Brighton beach",
+		},
+		{
+			name:     "Camera placeholder description",
+			fixture:  exifFixture{ImageDescription: "OLYMPUS DIGITAL CAMERA         "},
+			expected: "",
+		},
+		{
+			name:     "ASCII user comment",
+			fixture:  exifFixture{UserComment: []byte("ASCII\x00\x00\x00Grandma's birthday\x00\x00")},
+			expected: "Grandma's birthday",
+		},
+		{
+			name:     "UCS-2 comment in file byte order",
+			fixture:  exifFixture{UserComment: ucs2("LE", "Café trip")},
+			expected: "Café trip",
+		},
+		{
+			// Big-endian text inside a little-endian file, as some editors write
+			name:     "UCS-2 comment in foreign byte order",
+			fixture:  exifFixture{UserComment: ucs2("BE", "Ski week")},
+			expected: "Ski week",
+		},
+		{
+			name:     "Undefined code blank comment",
+			fixture:  exifFixture{UserComment: append(make([]byte, 8), "                "...)},
+			expected: "",
+		},
+		{
+			name: "Description and comment",
+			fixture: exifFixture{
+				ImageDescription: "Harbour",
+				UserComment:      []byte("ASCII\x00\x00\x00Fishing boats"),
+			},
+			expected: "Harbour\nFishing boats",
+		},
+	}
+
+	tempDir := t.TempDir()
+	extractor := NewExtractor()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testFile := filepath.Join(tempDir, "photo.jpg")
+			if err := os.WriteFile(testFile, buildEXIFJPEG(t, test.fixture), 0644); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+
+			info, err := extractor.ExtractMetadata(testFile)
+			if err != nil {
+				t.Fatalf("ExtractMetadata failed: %v", err)
+			}
+			if caption := info.ExtraMetadata["caption"]; caption != test.expected {
+				t.Errorf("Expected caption %q, got %q", test.expected, caption)
+			}
+		})
+	}
+}
+
+func TestScanFilesCaptionSearch(t *testing.T) {
+	mediaDir := t.TempDir()
+	monthDir := filepath.Join(mediaDir, "2024", "03")
+	if err := os.MkdirAll(monthDir, 0755); err != nil {
+		t.Fatalf("Failed to create month directory: %v", err)
+	}
+
+	captioned := buildEXIFJPEG(t, exifFixture{ImageDescription: "Lighthouse at dusk"})
+	if err := os.WriteFile(filepath.Join(monthDir, "IMG_0001.jpg"), captioned, 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(monthDir, "IMG_0002.jpg"), buildEXIFJPEG(t, exifFixture{}), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	files, err := NewOrganizer(mediaDir).ScanFiles("", "", 10, 0)
+	if err != nil {
+		t.Fatalf("ScanFiles failed: %v", err)
+	}
+
+	var matches []string
+	for _, file := range files {
+		if file.MatchesQuery("LIGHTHOUSE") {
+			matches = append(matches, file.FileName)
+		}
+	}
+	if len(matches) != 1 || matches[0] != "IMG_0001.jpg" {
+		t.Errorf("Expected only IMG_0001.jpg to match the caption, got %v", matches)
+	}
+}
+
+func TestEXIFTags(t *testing.T) {
+	tempDir := t.TempDir()
+	extractor := NewExtractor()
+
+	photo := filepath.Join(tempDir, "photo.jpg")
+	content := buildEXIFJPEG(t, exifFixture{
+		Make:             "Canon",
+		Model:            "EOS R5",
+		DateTimeOriginal: "2024:03:15 14:30:22",
+		Orientation:      6,
+	})
+	if err := os.WriteFile(photo, content, 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tags, err := extractor.EXIFTags(photo)
+	if err != nil {
+		t.Fatalf("EXIFTags failed: %v", err)
+	}
+
+	expected := map[string]string{
+		"Make":             "Canon",
+		"Model":            "EOS R5",
+		"DateTimeOriginal": "2024:03:15 14:30:22",
+		"Orientation":      "6",
+	}
+	for name, value := range expected {
+		if tags[name] != value {
+			t.Errorf("Expected %s %q, got %q", name, value, tags[name])
+		}
+	}
+
+	plain := filepath.Join(tempDir, "plain.jpg")
+	if err := os.WriteFile(plain, []byte("no exif here"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if tags, err := extractor.EXIFTags(plain); err != nil || len(tags) != 0 {
+		t.Errorf("Expected empty tags for a file without EXIF, got %v (err %v)", tags, err)
+	}
+
+	if _, err := extractor.EXIFTags(filepath.Join(tempDir, "missing.jpg")); !os.IsNotExist(err) {
+		t.Errorf("Expected not-exist error for a missing file, got %v", err)
+	}
+}
+
+func TestExtractMetadataDiscardsNullIslandGPS(t *testing.T) {
+	gps := func(lat, long uint32) []exifEntry {
+		return []exifEntry{
+			asciiEntry(0x0001, "N"),
+			rationalEntry(0x0002, lat, 1, 0, 1, 0, 1),
+			asciiEntry(0x0003, "E"),
+			rationalEntry(0x0004, long, 1, 0, 1, 0, 1),
+		}
+	}
+
+	tests := []struct {
+		name           string
+		gps            []exifEntry
+		keepNullIsland bool
+		expected       *LocationInfo
+	}{
+		{"Null Island", gps(0, 0), false, nil},
+		{"Null Island kept", gps(0, 0), true, &LocationInfo{}},
+		{"Equator", gps(0, 32), false, &LocationInfo{Longitude: 32}},
+		{"Off the globe", gps(91, 10), false, nil},
+		{"Real fix", gps(51, 1), false, &LocationInfo{Latitude: 51, Longitude: 1}},
+	}
+
+	tempDir := t.TempDir()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testFile := filepath.Join(tempDir, "photo.jpg")
+			content := buildEXIFJPEG(t, exifFixture{DateTimeOriginal: "2024:03:15 14:30:22", GPS: test.gps})
+			if err := os.WriteFile(testFile, content, 0644); err != nil {
+				t.Fatalf("Failed to create test file: %v", err)
+			}
+
+			extractor := NewExtractor()
+			extractor.keepNullIsland = test.keepNullIsland
+			info, err := extractor.ExtractMetadata(testFile)
+			if err != nil {
+				t.Fatalf("ExtractMetadata failed: %v", err)
+			}
+
+			if test.expected == nil {
+				if info.Location != nil {
+					t.Errorf("Expected no location, got %+v", info.Location)
+				}
+				return
+			}
+			if info.Location == nil || info.Location.Latitude != test.expected.Latitude || info.Location.Longitude != test.expected.Longitude {
+				t.Errorf("Expected location %+v, got %+v", test.expected, info.Location)
+			}
+		})
+	}
+}
+
+func TestFilenamePatternExamples(t *testing.T) {
+	extractor := NewExtractor()
+	for _, pattern := range extractor.FilenamePatterns() {
+		if date, matched := extractor.MatchFilename(pattern.Example); date == nil || matched != pattern.Pattern {
+			t.Errorf("Expected %q to be dated by %s, got %v from %q", pattern.Example, pattern.Pattern, date, matched)
+		}
+	}
+}
+
+func TestCustomFilenamePatterns(t *testing.T) {
+	extractor := NewExtractor()
+	builtin := len(extractor.FilenamePatterns())
+
+	custom := `Scan (\d{4})\.(\d{2})\.(\d{2})`
+	extractor.addCustomFilenamePatterns([]string{
+		custom,
+		`(\d{4`,          // Doesn't compile
+		`(\d{4})(\d{2})`, // No day
+	})
+
+	patterns := extractor.FilenamePatterns()
+	if len(patterns) != builtin+1 {
+		t.Fatalf("Expected only the valid custom pattern added, got %d patterns", len(patterns))
+	}
+	if patterns[0].Pattern != custom || patterns[0].Source != PatternSourceCustom {
+		t.Errorf("Expected the custom pattern first, got %+v", patterns[0])
+	}
+
+	date, matched := extractor.MatchFilename("Scan 2019.07.04 0001.jpg")
+	if date == nil || matched != custom {
+		t.Fatalf("Expected the custom pattern to date the file, got %v from %q", date, matched)
+	}
+	if expected := time.Date(2019, 7, 4, 0, 0, 0, 0, time.UTC); !date.Equal(expected) {
+		t.Errorf("Expected %v, got %v", expected, date)
+	}
+}
+
+func TestExtractMetadataImageDimensions(t *testing.T) {
+	dir := t.TempDir()
+	writeTestImage(t, filepath.Join(dir, "wide.png"), 40, 30)
+	writeTestImage(t, filepath.Join(dir, "tall.jpg"), 20, 50)
+
+	// A real PNG cut off before its header is complete
+	var full bytes.Buffer
+	if err := png.Encode(&full, image.NewGray(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatalf("Failed to encode image: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "truncated.png"), full.Bytes()[:12], 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "corrupt.jpg"), []byte("not a jpeg"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	// Stored landscape, shown portrait by a camera held upright
+	portrait := buildEXIFJPEG(t, exifFixture{Orientation: 6, DateTimeOriginal: "2024:03:15 14:30:22", Width: 40, Height: 20})
+	if err := os.WriteFile(filepath.Join(dir, "portrait.jpg"), portrait, 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	tests := []struct {
+		file   string
+		width  int
+		height int
+	}{
+		{"wide.png", 40, 30},
+		{"tall.jpg", 20, 50},
+		{"portrait.jpg", 20, 40},
+		{"truncated.png", 0, 0},
+		{"corrupt.jpg", 0, 0},
+	}
+
+	extractor := NewExtractor()
+	for _, test := range tests {
+		t.Run(test.file, func(t *testing.T) {
+			info, err := extractor.ExtractMetadata(filepath.Join(dir, test.file))
+			if err != nil {
+				t.Fatalf("ExtractMetadata failed: %v", err)
+			}
+			if info.Width != test.width || info.Height != test.height {
+				t.Errorf("Expected %dx%d, got %dx%d", test.width, test.height, info.Width, info.Height)
+			}
+			if info.DateTaken == nil {
+				t.Error("Expected extraction to carry on and date the file")
+			}
+		})
+	}
+}
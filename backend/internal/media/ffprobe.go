@@ -0,0 +1,150 @@
+package media
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+const ffprobeTimeout = 30 * time.Second
+
+// probeResult mirrors the parts of `ffprobe -show_format -show_streams` output
+// that Sortify uses.
+type probeResult struct {
+	Streams []probeStream `json:"streams"`
+	Format  probeFormat   `json:"format"`
+}
+
+type probeStream struct {
+	CodecType    string            `json:"codec_type"`
+	Width        int               `json:"width"`
+	Height       int               `json:"height"`
+	Duration     string            `json:"duration"`
+	Tags         map[string]string `json:"tags"`
+	SideDataList []probeSideData   `json:"side_data_list"`
+}
+
+type probeSideData struct {
+	SideDataType string  `json:"side_data_type"`
+	Rotation     float64 `json:"rotation"`
+}
+
+type probeFormat struct {
+	Duration string            `json:"duration"`
+	Tags     map[string]string `json:"tags"`
+}
+
+// runFFprobe invokes ffprobe and returns its JSON output.
+func runFFprobe(ctx context.Context, ffprobePath, filePath string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, ffprobePath,
+		"-v", "quiet",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		filePath,
+	)
+	return cmd.Output()
+}
+
+func parseProbeOutput(output []byte) (*probeResult, error) {
+	var result probeResult
+	if err := json.Unmarshal(output, &result); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+	return &result, nil
+}
+
+// videoStream returns the first video stream, if any.
+func (p *probeResult) videoStream() *probeStream {
+	for i := range p.Streams {
+		if p.Streams[i].CodecType == "video" {
+			return &p.Streams[i]
+		}
+	}
+	return nil
+}
+
+// duration prefers the container duration and falls back to the video stream's.
+func (p *probeResult) duration() (time.Duration, bool) {
+	value := p.Format.Duration
+	if value == "" {
+		if stream := p.videoStream(); stream != nil {
+			value = stream.Duration
+		}
+	}
+
+	seconds, err := strconv.ParseFloat(value, 64)
+	if err != nil || seconds <= 0 {
+		return 0, false
+	}
+	return time.Duration(seconds * float64(time.Second)), true
+}
+
+// videoDateLayouts are the forms creation dates take in ffprobe tags: ISO 8601
+// in UTC from most muxers, with a numeric offset from Apple devices, and
+// without a zone from old ffmpeg builds.
+var videoDateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999-0700",
+	"2006-01-02 15:04:05",
+}
+
+// creationTime returns when the clip was recorded. Apple's creationdate tag
+// keeps the local offset, so it is preferred over creation_time. Cameras that
+// never set the date leave the QuickTime or Unix epoch, which is ignored.
+func (p *probeResult) creationTime() (time.Time, bool) {
+	values := []string{
+		p.Format.Tags["com.apple.quicktime.creationdate"],
+		p.Format.Tags["creation_time"],
+	}
+	if stream := p.videoStream(); stream != nil {
+		values = append(values, stream.Tags["creation_time"])
+	}
+
+	for _, value := range values {
+		for _, layout := range videoDateLayouts {
+			date, err := time.Parse(layout, value)
+			if err == nil && date.Year() > 1970 {
+				return date, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// rotation returns the clockwise rotation a player must apply for correct
+// display. iPhone footage stores it either as a display matrix (reported
+// counter-clockwise by ffprobe) or, with older muxers, as a "rotate" tag.
+func (s *probeStream) rotation() int {
+	for _, sideData := range s.SideDataList {
+		if sideData.SideDataType == "Display Matrix" {
+			return normalizeRotation(-int(math.Round(sideData.Rotation)))
+		}
+	}
+
+	if tag, ok := s.Tags["rotate"]; ok {
+		if degrees, err := strconv.Atoi(tag); err == nil {
+			return normalizeRotation(degrees)
+		}
+	}
+
+	return 0
+}
+
+func normalizeRotation(degrees int) int {
+	return ((degrees % 360) + 360) % 360
+}
+
+// formatDuration renders a duration as H:MM:SS, or M:SS when under an hour.
+func formatDuration(d time.Duration) string {
+	total := int(d.Round(time.Second).Seconds())
+	hours, minutes, seconds := total/3600, (total%3600)/60, total%60
+	if hours > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", hours, minutes, seconds)
+	}
+	return fmt.Sprintf("%d:%02d", minutes, seconds)
+}
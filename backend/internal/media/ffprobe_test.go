@@ -0,0 +1,249 @@
+package media
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseProbeOutputRotation(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected int
+	}{
+		{
+			name: "Display matrix",
+			output: `{"streams":[{"codec_type":"audio"},{"codec_type":"video","width":1920,"height":1080,
+				"side_data_list":[{"side_data_type":"Display Matrix","rotation":-90}]}],"format":{"duration":"12.5"}}`,
+			expected: 90,
+		},
+		{
+			name:     "Legacy rotate tag",
+			output:   `{"streams":[{"codec_type":"video","tags":{"rotate":"270"}}],"format":{}}`,
+			expected: 270,
+		},
+		{
+			name:     "No rotation",
+			output:   `{"streams":[{"codec_type":"video"}],"format":{}}`,
+			expected: 0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := parseProbeOutput([]byte(test.output))
+			if err != nil {
+				t.Fatalf("parseProbeOutput failed: %v", err)
+			}
+
+			stream := result.videoStream()
+			if stream == nil {
+				t.Fatal("Expected a video stream")
+			}
+			if rotation := stream.rotation(); rotation != test.expected {
+				t.Errorf("Expected rotation %d, got %d", test.expected, rotation)
+			}
+		})
+	}
+}
+
+func TestParseProbeOutputCreationTime(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		expected string // RFC 3339, empty for no date
+	}{
+		{
+			name:     "Container creation time",
+			output:   `{"streams":[{"codec_type":"video"}],"format":{"tags":{"creation_time":"2024-03-15T10:30:00.000000Z"}}}`,
+			expected: "2024-03-15T10:30:00Z",
+		},
+		{
+			name: "Apple creation date keeps its offset",
+			output: `{"streams":[{"codec_type":"video"}],"format":{"tags":{
+				"creation_time":"2024-03-15T17:30:00.000000Z","com.apple.quicktime.creationdate":"2024-03-15T10:30:00-0700"}}}`,
+			expected: "2024-03-15T10:30:00-07:00",
+		},
+		{
+			name:     "Stream creation time",
+			output:   `{"streams":[{"codec_type":"video","tags":{"creation_time":"2023-07-04 20:15:00"}}],"format":{}}`,
+			expected: "2023-07-04T20:15:00Z",
+		},
+		{
+			name:   "Unset QuickTime date",
+			output: `{"streams":[{"codec_type":"video"}],"format":{"tags":{"creation_time":"1904-01-01T00:00:00.000000Z"}}}`,
+		},
+		{
+			name:   "No tags",
+			output: `{"streams":[{"codec_type":"video"}],"format":{}}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := parseProbeOutput([]byte(test.output))
+			if err != nil {
+				t.Fatalf("parseProbeOutput failed: %v", err)
+			}
+
+			date, ok := result.creationTime()
+			if test.expected == "" {
+				if ok {
+					t.Errorf("Expected no creation time, got %v", date)
+				}
+				return
+			}
+			if !ok || date.Format(time.RFC3339) != test.expected {
+				t.Errorf("Expected creation time %s, got %v (found %v)", test.expected, date, ok)
+			}
+		})
+	}
+}
+
+func TestExtractMetadataVideoProbe(t *testing.T) {
+	videoPath := filepath.Join(t.TempDir(), "clip.mp4")
+	if err := os.WriteFile(videoPath, []byte("not really a video"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	extractor := NewExtractor()
+	extractor.ffprobePath = "/opt/ffmpeg/bin/ffprobe"
+	var probedWith, probed string
+	extractor.probe = func(ctx context.Context, ffprobePath, filePath string) ([]byte, error) {
+		probedWith, probed = ffprobePath, filePath
+		return []byte(`{"streams":[{"codec_type":"audio"},{"codec_type":"video","width":1920,"height":1080}],
+			"format":{"duration":"65.5","tags":{"creation_time":"2024-03-15T10:30:00.000000Z"}}}`), nil
+	}
+
+	info, err := extractor.ExtractMetadata(videoPath)
+	if err != nil {
+		t.Fatalf("ExtractMetadata failed: %v", err)
+	}
+
+	if probedWith != "/opt/ffmpeg/bin/ffprobe" || probed != videoPath {
+		t.Errorf("Expected %s to be probed with the configured ffprobe, got %q on %q", videoPath, probedWith, probed)
+	}
+	if info.Duration == nil || time.Duration(*info.Duration) != 65500*time.Millisecond {
+		t.Errorf("Expected 65.5s duration, got %v", info.Duration)
+	}
+	if info.Width != 1920 || info.Height != 1080 {
+		t.Errorf("Expected 1920x1080, got %dx%d", info.Width, info.Height)
+	}
+	expected := time.Date(2024, 3, 15, 10, 30, 0, 0, time.UTC)
+	if info.DateTaken == nil || !info.DateTaken.Equal(expected) {
+		t.Errorf("Expected date %v, got %v", expected, info.DateTaken)
+	}
+	if info.DateSource != DateSourceVideoMeta {
+		t.Errorf("Expected date source %s, got %s", DateSourceVideoMeta, info.DateSource)
+	}
+}
+
+func TestExtractMetadataVideoProbeAfterTakeout(t *testing.T) {
+	videoPath := filepath.Join(t.TempDir(), "clip.mp4")
+	if err := os.WriteFile(videoPath, []byte("not really a video"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	if err := os.WriteFile(videoPath+".json", []byte(`{"photoTakenTime":{"timestamp":"1562263200"}}`), 0644); err != nil {
+		t.Fatalf("Failed to create sidecar: %v", err)
+	}
+
+	extractor := NewExtractor()
+	extractor.ffprobePath = "ffprobe"
+	extractor.probe = func(ctx context.Context, ffprobePath, filePath string) ([]byte, error) {
+		return []byte(`{"streams":[{"codec_type":"video","width":1920,"height":1080}],
+			"format":{"duration":"65.5","tags":{"creation_time":"2024-03-15T10:30:00.000000Z"}}}`), nil
+	}
+
+	info, err := extractor.ExtractMetadata(videoPath)
+	if err != nil {
+		t.Fatalf("ExtractMetadata failed: %v", err)
+	}
+
+	if info.DateSource != DateSourceTakeout || info.DateTaken == nil || info.DateTaken.Unix() != 1562263200 {
+		t.Errorf("Expected the Takeout date, got %v from %s", info.DateTaken, info.DateSource)
+	}
+	if info.Duration == nil || info.Width != 1920 {
+		t.Errorf("Expected the video still probed, got %+v", info)
+	}
+}
+
+func TestExtractMetadataWithoutFFprobe(t *testing.T) {
+	videoPath := filepath.Join(t.TempDir(), "clip.mp4")
+	if err := os.WriteFile(videoPath, []byte("not really a video"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	for _, ffprobePath := range []string{"sortify-no-such-ffprobe", filepath.Join(t.TempDir(), "ffprobe")} {
+		extractor := NewExtractor()
+		extractor.ffprobePath = ffprobePath
+
+		info, err := extractor.ExtractMetadata(videoPath)
+		if err != nil {
+			t.Fatalf("Expected missing ffprobe %s to be skipped, got %v", ffprobePath, err)
+		}
+		if info.Duration != nil || info.Width != 0 || info.DateSource != DateSourceFileTime {
+			t.Errorf("Expected no video metadata without ffprobe, got %+v", info)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		expected string
+	}{
+		{12500 * time.Millisecond, "0:13"},
+		{125 * time.Second, "2:05"},
+		{time.Hour + 2*time.Minute + 5*time.Second, "1:02:05"},
+	}
+
+	for _, test := range tests {
+		if got := formatDuration(test.duration); got != test.expected {
+			t.Errorf("formatDuration(%v) = %s, expected %s", test.duration, got, test.expected)
+		}
+	}
+}
+
+func TestExtractMetadataRotatedVideo(t *testing.T) {
+	ffmpeg, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		t.Skip("ffmpeg not available")
+	}
+	if _, err := exec.LookPath("ffprobe"); err != nil {
+		t.Skip("ffprobe not available")
+	}
+
+	// Generate a short portrait clip the way an iPhone stores it: landscape
+	// frames plus a rotation hint in the track.
+	videoPath := filepath.Join(t.TempDir(), "IMG_0001.mov")
+	cmd := exec.Command(ffmpeg, "-v", "quiet",
+		"-f", "lavfi", "-i", "testsrc=size=64x32:duration=2",
+		"-metadata:s:v:0", "rotate=90",
+		videoPath,
+	)
+	if err := cmd.Run(); err != nil {
+		t.Skipf("Failed to generate fixture video: %v", err)
+	}
+
+	info, err := NewExtractor().ExtractMetadata(videoPath)
+	if err != nil {
+		t.Fatalf("ExtractMetadata failed: %v", err)
+	}
+
+	if info.Rotation != 90 {
+		t.Errorf("Expected rotation 90, got %d", info.Rotation)
+	}
+	if info.ExtraMetadata["rotation"] != "90" {
+		t.Errorf("Expected rotation metadata 90, got %q", info.ExtraMetadata["rotation"])
+	}
+	if info.Duration == nil || time.Duration(*info.Duration).Round(time.Second) != 2*time.Second {
+		t.Errorf("Expected 2s duration, got %v", info.Duration)
+	}
+	if info.ExtraMetadata["duration"] != "0:02" {
+		t.Errorf("Expected formatted duration 0:02, got %q", info.ExtraMetadata["duration"])
+	}
+}
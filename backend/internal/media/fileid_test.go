@@ -0,0 +1,186 @@
+package media
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestContentFileIDSurvivesMove(t *testing.T) {
+	mediaDir := t.TempDir()
+	indexPath := filepath.Join(t.TempDir(), "checksums.json")
+	organizer := NewOrganizerWithOptions(mediaDir, OrganizerOptions{ChecksumIndexPath: indexPath, FileIDs: FileIDContent})
+
+	content := []byte("holiday photo")
+	source := filepath.Join(t.TempDir(), "holiday 2024.03.15.jpg")
+	if err := os.WriteFile(source, content, 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	modTime := time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(source, modTime, modTime); err != nil {
+		t.Fatalf("Failed to set modification time: %v", err)
+	}
+	info, err := organizer.OrganizeFile(source, "holiday 2024.03.15.jpg")
+	if err != nil {
+		t.Fatalf("OrganizeFile failed: %v", err)
+	}
+
+	before, err := organizer.FileInfo(info.RelativePath)
+	if err != nil {
+		t.Fatalf("FileInfo failed: %v", err)
+	}
+	sum := sha256.Sum256(content)
+	if want := hex.EncodeToString(sum[:]); before.ID != want {
+		t.Fatalf("Expected ID %s, got %s", want, before.ID)
+	}
+
+	// Re-filing moves the file to 2024/March
+	organizer.extractor.addCustomFilenamePatterns([]string{`(\d{4})\.(\d{2})\.(\d{2})`})
+	report, err := organizer.RefreshMetadata(context.Background(), RefreshOptions{Apply: true})
+	if err != nil {
+		t.Fatalf("RefreshMetadata failed: %v", err)
+	}
+	if len(report.Changes) != 1 || report.Changes[0].Error != "" {
+		t.Fatalf("Expected one applied change, got %+v", report)
+	}
+	newPath := filepath.Join("2024", "March", "holiday 2024.03.15.jpg")
+
+	after, err := organizer.FileInfo(newPath)
+	if err != nil {
+		t.Fatalf("FileInfo failed: %v", err)
+	}
+	if after.ID != before.ID {
+		t.Errorf("Expected ID %s to survive the move, got %s", before.ID, after.ID)
+	}
+	found, err := organizer.FindFileByID(before.ID)
+	if err != nil {
+		t.Fatalf("FindFileByID failed: %v", err)
+	}
+	if found.RelativePath != newPath {
+		t.Errorf("Expected ID to resolve to %s, got %s", newPath, found.RelativePath)
+	}
+
+	// The mapping persists with the checksum index
+	reloaded := NewOrganizerWithOptions(mediaDir, OrganizerOptions{ChecksumIndexPath: indexPath, FileIDs: FileIDContent})
+	if relPath, ok := reloaded.checksums.PathForID(before.ID); !ok || relPath != filepath.ToSlash(newPath) {
+		t.Errorf("Expected reloaded index to map ID to %s, got %q", newPath, relPath)
+	}
+}
+
+func TestContentFileIDsAreUnique(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizerWithOptions(mediaDir, OrganizerOptions{FileIDs: FileIDContent})
+
+	// Identical copies dropped into the library by hand share a checksum
+	files := map[string]string{
+		"2024/March/a.jpg":       "same content",
+		"2024/March/copy.jpg":    "same content",
+		"2024/April/another.jpg": "same content",
+		"2024/April/b.jpg":       "different content",
+	}
+	for rel, content := range files {
+		path := filepath.Join(mediaDir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	// Listings never read files to work out an ID; maintenance hands them out
+	if info, err := organizer.FileInfo(filepath.FromSlash("2024/March/a.jpg")); err != nil {
+		t.Fatalf("FileInfo failed: %v", err)
+	} else if len(info.ID) == 2*sha256.Size {
+		t.Errorf("Expected a path ID before maintenance, got %q", info.ID)
+	}
+	if _, err := organizer.Reconcile(context.Background(), MaintenanceOptions{}); err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+
+	seen := make(map[string]string)
+	for rel := range files {
+		info, err := organizer.FileInfo(filepath.FromSlash(rel))
+		if err != nil {
+			t.Fatalf("FileInfo failed: %v", err)
+		}
+		if len(info.ID) != 2*sha256.Size {
+			t.Errorf("Expected a full SHA-256 ID for %s, got %q", rel, info.ID)
+		}
+		if other, ok := seen[info.ID]; ok {
+			t.Errorf("Expected distinct IDs, %s and %s share %s", other, rel, info.ID)
+		}
+		seen[info.ID] = rel
+
+		found, err := organizer.FindFileByID(info.ID)
+		if err != nil {
+			t.Fatalf("FindFileByID failed: %v", err)
+		}
+		if filepath.ToSlash(found.RelativePath) != rel {
+			t.Errorf("Expected ID of %s to resolve to it, got %s", rel, found.RelativePath)
+		}
+
+		again, err := organizer.FileInfo(filepath.FromSlash(rel))
+		if err != nil {
+			t.Fatalf("FileInfo failed: %v", err)
+		}
+		if again.ID != info.ID {
+			t.Errorf("Expected %s to keep ID %s, got %s", rel, info.ID, again.ID)
+		}
+	}
+}
+
+func TestContentFileIDSurvivesTrash(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizerWithOptions(mediaDir, OrganizerOptions{FileIDs: FileIDContent, TrashRetention: time.Hour})
+
+	source := filepath.Join(t.TempDir(), "IMG_20240315_143022.jpg")
+	if err := os.WriteFile(source, []byte("trashed photo"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	info, err := organizer.OrganizeFile(source, "IMG_20240315_143022.jpg")
+	if err != nil {
+		t.Fatalf("OrganizeFile failed: %v", err)
+	}
+	before, err := organizer.FileInfo(info.RelativePath)
+	if err != nil {
+		t.Fatalf("FileInfo failed: %v", err)
+	}
+
+	trashed, err := organizer.TrashFile(info.RelativePath)
+	if err != nil {
+		t.Fatalf("TrashFile failed: %v", err)
+	}
+	if _, err := organizer.FindFileByID(before.ID); !os.IsNotExist(err) {
+		t.Errorf("Expected a trashed file's ID not to resolve, got %v", err)
+	}
+	sum := sha256.Sum256([]byte("trashed photo"))
+	if _, ok := organizer.FindByChecksum(hex.EncodeToString(sum[:])); ok {
+		t.Error("Expected a trashed file not to count as a duplicate")
+	}
+
+	restored, err := organizer.RestoreFile(trashed.ID)
+	if err != nil {
+		t.Fatalf("RestoreFile failed: %v", err)
+	}
+	found, err := organizer.FindFileByID(before.ID)
+	if err != nil {
+		t.Fatalf("FindFileByID failed: %v", err)
+	}
+	if filepath.ToSlash(found.RelativePath) != restored {
+		t.Errorf("Expected ID to resolve to %s after restore, got %s", restored, found.RelativePath)
+	}
+}
+
+func TestPathFileIDsByDefault(t *testing.T) {
+	organizer := NewOrganizer(t.TempDir())
+
+	sum := sha256.Sum256([]byte("2024/March/a.jpg"))
+	if id, want := organizer.generateFileID("2024/March/a.jpg"), hex.EncodeToString(sum[:8]); id != want {
+		t.Errorf("Expected path ID %s, got %s", want, id)
+	}
+}
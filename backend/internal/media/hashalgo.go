@@ -0,0 +1,78 @@
+package media
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"hash"
+	"hash/crc64"
+	"log/slog"
+)
+
+// HashAlgorithm selects the hash the library uses for duplicate detection and
+// its checksum index. Checksums clients send with uploads are always SHA-256,
+// whatever this is set to.
+//
+// BLAKE3 and xxHash would be faster still, but neither is in the standard
+// library and the module keeps its dependencies to EXIF parsing. SHA-512 and
+// CRC-64 stand in for them: a quicker collision-resistant hash, and a much
+// quicker one that isn't.
+type HashAlgorithm string
+
+const (
+	// HashSHA256 is the default.
+	HashSHA256 HashAlgorithm = "sha256"
+	// HashSHA512 is usually faster than SHA-256 on 64-bit CPUs without SHA
+	// instructions.
+	HashSHA512 HashAlgorithm = "sha512"
+	// HashCRC64 is several times faster again but not collision resistant, so
+	// it only suits libraries whose files come from trusted sources.
+	HashCRC64 HashAlgorithm = "crc64"
+)
+
+var crc64Table = crc64.MakeTable(crc64.ECMA)
+
+var hashAlgorithms = map[HashAlgorithm]func() hash.Hash{
+	HashSHA256: sha256.New,
+	HashSHA512: sha512.New,
+	HashCRC64:  func() hash.Hash { return crc64.New(crc64Table) },
+}
+
+// RebuildChecksumIndex re-records the checksum of every library file after
+// HASH_ALGO changed and the stored checksums were dropped, reading at no more
+// than bytesPerSecond (zero means unthrottled) so a large library doesn't
+// starve uploads. It does nothing when the index is current.
+func (o *Organizer) RebuildChecksumIndex(ctx context.Context, bytesPerSecond int64) error {
+	if !o.checksums.stale {
+		return nil
+	}
+
+	slog.Info("Rebuilding checksum index", "algorithm", o.checksums.Algorithm())
+	report, err := o.VerifyIntegrity(ctx, VerifyOptions{BytesPerSecond: bytesPerSecond})
+	if err != nil {
+		return err
+	}
+	o.checksums.stale = false
+
+	slog.Info("Checksum index rebuilt", "files", report.Indexed)
+	return nil
+}
+
+// FindByChecksum returns the library path of a file whose content has the
+// given SHA-256 checksum, as clients compute them. Under any other HASH_ALGO
+// the index can't answer and nothing is found.
+func (o *Organizer) FindByChecksum(checksum string) (string, bool) {
+	if o.checksums.Algorithm() != HashSHA256 || checksum == "" {
+		return "", false
+	}
+	return o.checksums.Find(checksum)
+}
+
+// FindByChecksums is FindByChecksum for a batch, answered in a single pass
+// over the index. Checksums without a match are left out of the result.
+func (o *Organizer) FindByChecksums(checksums []string) map[string]string {
+	if o.checksums.Algorithm() != HashSHA256 {
+		return map[string]string{}
+	}
+	return o.checksums.FindAll(checksums)
+}
@@ -0,0 +1,156 @@
+package media
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDedupWithHashAlgorithm(t *testing.T) {
+	tests := []struct {
+		algorithm   HashAlgorithm
+		checksumLen int
+	}{
+		{HashSHA256, 64},
+		{HashSHA512, 128},
+		{HashCRC64, 16},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.algorithm), func(t *testing.T) {
+			for _, mode := range []DedupMode{DedupFull, DedupFast} {
+				mediaDir := t.TempDir()
+				organizer := NewOrganizerWithOptions(mediaDir, OrganizerOptions{HashAlgorithm: test.algorithm, DedupMode: mode})
+
+				first := organizeTestFile(t, organizer, "IMG_20240315_143022.jpg", "same bytes")
+				if checksum, _ := organizer.checksums.Get(first.RelativePath); len(checksum) != test.checksumLen {
+					t.Errorf("%s: expected a %d-character checksum, got %q", mode, test.checksumLen, checksum)
+				}
+
+				organizeTestFile(t, organizer, "IMG_20240315_143022.jpg", "same bytes")
+				organizeTestFile(t, organizer, "IMG_20240315_143022.jpg", "other bytes")
+
+				entries, err := os.ReadDir(filepath.Join(mediaDir, "2024", "March"))
+				if err != nil {
+					t.Fatalf("Failed to read folder: %v", err)
+				}
+				if len(entries) != 2 {
+					t.Errorf("%s: expected the identical copy to be dropped and the different one kept, got %d files", mode, len(entries))
+				}
+			}
+		})
+	}
+}
+
+func TestChecksumIndexRebuildsOnAlgorithmChange(t *testing.T) {
+	mediaDir := t.TempDir()
+	indexPath := filepath.Join(t.TempDir(), "checksums.json")
+
+	organizer := NewOrganizerWithOptions(mediaDir, OrganizerOptions{ChecksumIndexPath: indexPath})
+	photo := organizeTestFile(t, organizer, "IMG_20240315_143022.jpg", "photo")
+	sha256Checksum, _ := organizer.checksums.Get(photo.RelativePath)
+
+	// Reopening with the same algorithm keeps the index
+	organizer = NewOrganizerWithOptions(mediaDir, OrganizerOptions{ChecksumIndexPath: indexPath})
+	if checksum, ok := organizer.checksums.Get(photo.RelativePath); !ok || checksum != sha256Checksum {
+		t.Fatalf("Expected the stored checksum %s, got %q", sha256Checksum, checksum)
+	}
+
+	organizer = NewOrganizerWithOptions(mediaDir, OrganizerOptions{ChecksumIndexPath: indexPath, HashAlgorithm: HashSHA512})
+	if _, ok := organizer.checksums.Get(photo.RelativePath); ok {
+		t.Fatal("Expected SHA-256 checksums to be dropped under sha512")
+	}
+	if err := organizer.RebuildChecksumIndex(context.Background(), 0); err != nil {
+		t.Fatalf("RebuildChecksumIndex failed: %v", err)
+	}
+	checksum, ok := organizer.checksums.Get(photo.RelativePath)
+	if !ok || len(checksum) != 128 {
+		t.Fatalf("Expected a rebuilt SHA-512 checksum, got %q", checksum)
+	}
+
+	// The rebuilt index persists with its algorithm, so verification passes
+	organizer = NewOrganizerWithOptions(mediaDir, OrganizerOptions{ChecksumIndexPath: indexPath, HashAlgorithm: HashSHA512})
+	report, err := organizer.VerifyIntegrity(context.Background(), VerifyOptions{})
+	if err != nil {
+		t.Fatalf("VerifyIntegrity failed: %v", err)
+	}
+	if report.Checked != 1 || len(report.Mismatches) != 0 {
+		t.Errorf("Expected one clean check, got %+v", report)
+	}
+}
+
+func TestChecksumIndexSaveDelay(t *testing.T) {
+	mediaDir := t.TempDir()
+	indexPath := filepath.Join(t.TempDir(), "checksums.json")
+	organizer := NewOrganizerWithOptions(mediaDir, OrganizerOptions{ChecksumIndexPath: indexPath, ChecksumSaveDelay: time.Hour})
+
+	for _, content := range []string{"first", "second", "third"} {
+		organizeTestFile(t, organizer, "IMG_20240315_143022.jpg", content)
+	}
+	if _, err := os.Stat(indexPath); !os.IsNotExist(err) {
+		t.Fatalf("Expected the index write to wait out the delay, got %v", err)
+	}
+
+	if err := organizer.FlushChecksums(); err != nil {
+		t.Fatalf("FlushChecksums failed: %v", err)
+	}
+	reloaded, err := LoadChecksumIndex(indexPath, HashSHA256)
+	if err != nil {
+		t.Fatalf("LoadChecksumIndex failed: %v", err)
+	}
+	if paths := reloaded.Paths(""); len(paths) != 3 {
+		t.Errorf("Expected all 3 files saved in one write, got %v", paths)
+	}
+
+	// Without a flush the write happens once the delay passes
+	organizer.checksums.saveDelay = 10 * time.Millisecond
+	organizeTestFile(t, organizer, "IMG_20240315_143022.jpg", "fourth")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if reloaded, _ = LoadChecksumIndex(indexPath, HashSHA256); len(reloaded.Paths("")) == 4 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the delayed write, got %v", reloaded.Paths(""))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLoadLegacyChecksumIndex(t *testing.T) {
+	indexPath := filepath.Join(t.TempDir(), "checksums.json")
+	if err := os.WriteFile(indexPath, []byte(`{"2024/March/photo.jpg":"abc123"}`), 0644); err != nil {
+		t.Fatalf("Failed to write index: %v", err)
+	}
+
+	index, err := LoadChecksumIndex(indexPath, HashSHA256)
+	if err != nil {
+		t.Fatalf("LoadChecksumIndex failed: %v", err)
+	}
+	if checksum, _ := index.Get("2024/March/photo.jpg"); checksum != "abc123" {
+		t.Errorf("Expected legacy entries to be read as SHA-256, got %q", checksum)
+	}
+}
+
+func TestFindByChecksums(t *testing.T) {
+	organizer := NewOrganizer(t.TempDir())
+	stored := organizeTestFile(t, organizer, "IMG_20240315_143022.jpg", "stored")
+
+	storedSum := sha256.Sum256([]byte("stored"))
+	missingSum := sha256.Sum256([]byte("missing"))
+	upper := strings.ToUpper(hex.EncodeToString(storedSum[:]))
+	missing := hex.EncodeToString(missingSum[:])
+
+	found := organizer.FindByChecksums([]string{upper, missing})
+	if found[upper] != filepath.ToSlash(stored.RelativePath) {
+		t.Errorf("Expected %s for the stored file, got %q", stored.RelativePath, found[upper])
+	}
+	if _, ok := found[missing]; ok {
+		t.Error("Expected no match for a checksum the library doesn't hold")
+	}
+}
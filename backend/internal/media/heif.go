@@ -0,0 +1,297 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+)
+
+func init() {
+	// Neither Go's built-in table nor most mime.types files know HEIF, and
+	// media types are sniffed from MIME types
+	for ext, mimeType := range map[string]string{".heic": "image/heic", ".heif": "image/heif"} {
+		if mime.TypeByExtension(ext) == "" {
+			mime.AddExtensionType(ext, mimeType)
+		}
+	}
+}
+
+// heifBrands are the ftyp brands of HEIF images, such as an iPhone's .heic
+// photos.
+var heifBrands = map[string]bool{
+	"heic": true, "heix": true, "heim": true, "heis": true,
+	"hevc": true, "hevx": true, "mif1": true, "msf1": true,
+}
+
+// isHEIF reports whether head, the start of a file, opens with a file type
+// box naming a HEIF brand. Uploads sit in temp files without their extension,
+// so the content decides.
+func isHEIF(head []byte) bool {
+	if len(head) < 16 || string(head[4:8]) != "ftyp" {
+		return false
+	}
+	size := int(binary.BigEndian.Uint32(head))
+	if size < 16 || size > len(head) {
+		size = len(head)
+	}
+	// Major brand, minor version, then compatible brands
+	for offset := 8; offset+4 <= size; offset += 4 {
+		if offset != 12 && heifBrands[string(head[offset:offset+4])] {
+			return true
+		}
+	}
+	return false
+}
+
+var errNoHEIFEXIF = errors.New("no EXIF item in HEIF file")
+
+// maxHEIFMetaSize bounds the meta box read into memory; Apple's are a few KiB.
+const maxHEIFMetaSize = 1 << 20
+
+// heifBox is an ISO base media file format box: a size, a four-character
+// type and its payload.
+type heifBox struct {
+	boxType string
+	offset  int64 // Of the payload
+	size    int64 // Of the payload
+}
+
+// readHEIFBoxes lists the boxes in r between start and end.
+func readHEIFBoxes(r io.ReaderAt, start, end int64) ([]heifBox, error) {
+	var boxes []heifBox
+	for offset := start; offset+8 <= end; {
+		var header [16]byte
+		if _, err := r.ReadAt(header[:8], offset); err != nil {
+			return nil, err
+		}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		headerSize := int64(8)
+		switch size {
+		case 0: // Runs to the end
+			size = end - offset
+		case 1: // 64-bit size follows the type
+			if _, err := r.ReadAt(header[8:16], offset+8); err != nil {
+				return nil, err
+			}
+			size, headerSize = int64(binary.BigEndian.Uint64(header[8:16])), 16
+		}
+		if size < headerSize || offset+size > end {
+			return nil, fmt.Errorf("malformed %q box at %d", header[4:8], offset)
+		}
+
+		boxes = append(boxes, heifBox{boxType: string(header[4:8]), offset: offset + headerSize, size: size - headerSize})
+		offset += size
+	}
+	return boxes, nil
+}
+
+// heifReader decodes big-endian fields from a box payload, remembering the
+// first read past its end.
+type heifReader struct {
+	data []byte
+	err  error
+}
+
+func (h *heifReader) uint(n int) uint64 {
+	if h.err != nil {
+		return 0
+	}
+	if n > len(h.data) {
+		h.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	var value uint64
+	for _, b := range h.data[:n] {
+		value = value<<8 | uint64(b)
+	}
+	h.data = h.data[n:]
+	return value
+}
+
+func (h *heifReader) fourCC() string {
+	if h.err != nil || len(h.data) < 4 {
+		h.err = io.ErrUnexpectedEOF
+		return ""
+	}
+	value := string(h.data[:4])
+	h.data = h.data[4:]
+	return value
+}
+
+// readHEIFEXIF returns the TIFF-encoded EXIF of the HEIF file in r, which is
+// size bytes long. HEIF keeps EXIF as an item of type "Exif" listed in the
+// meta box; its data, located by the item location box, starts with the
+// offset of the TIFF header. At most limit bytes of EXIF are read.
+func readHEIFEXIF(r io.ReaderAt, size, limit int64) ([]byte, error) {
+	boxes, err := readHEIFBoxes(r, 0, size)
+	if err != nil {
+		return nil, err
+	}
+	if len(boxes) == 0 || boxes[0].boxType != "ftyp" {
+		return nil, errors.New("not a HEIF file")
+	}
+
+	var meta *heifBox
+	for i := range boxes {
+		if boxes[i].boxType == "meta" {
+			meta = &boxes[i]
+			break
+		}
+	}
+	if meta == nil || meta.size < 4 {
+		return nil, errNoHEIFEXIF
+	}
+	if meta.size > maxHEIFMetaSize {
+		return nil, fmt.Errorf("HEIF meta box of %d bytes exceeds %d", meta.size, maxHEIFMetaSize)
+	}
+
+	// meta is a full box: version and flags precede its children
+	children, err := readHEIFBoxes(r, meta.offset+4, meta.offset+meta.size)
+	if err != nil {
+		return nil, err
+	}
+	payload := func(boxType string) ([]byte, error) {
+		for _, child := range children {
+			if child.boxType == boxType {
+				data := make([]byte, child.size)
+				_, err := r.ReadAt(data, child.offset)
+				return data, err
+			}
+		}
+		return nil, errNoHEIFEXIF
+	}
+
+	iinf, err := payload("iinf")
+	if err != nil {
+		return nil, err
+	}
+	itemID, err := heifEXIFItem(iinf)
+	if err != nil {
+		return nil, err
+	}
+
+	iloc, err := payload("iloc")
+	if err != nil {
+		return nil, err
+	}
+	extents, err := heifItemExtents(iloc, itemID)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	for _, extent := range extents {
+		if extent[1] > uint64(limit-int64(len(data))) || extent[0] > uint64(size) {
+			return nil, fmt.Errorf("HEIF EXIF exceeds %d bytes", limit)
+		}
+		chunk := make([]byte, extent[1])
+		if _, err := r.ReadAt(chunk, int64(extent[0])); err != nil {
+			return nil, fmt.Errorf("failed to read HEIF EXIF: %w", err)
+		}
+		data = append(data, chunk...)
+	}
+
+	if len(data) < 4 {
+		return nil, errNoHEIFEXIF
+	}
+	headerOffset := uint64(binary.BigEndian.Uint32(data)) + 4
+	if headerOffset > uint64(len(data)) {
+		return nil, errors.New("HEIF EXIF header offset out of range")
+	}
+	return data[headerOffset:], nil
+}
+
+// heifEXIFItem returns the ID of the "Exif" item in an item info box.
+func heifEXIFItem(iinf []byte) (uint64, error) {
+	h := &heifReader{data: iinf}
+	version := h.uint(1)
+	h.uint(3) // Flags
+	count := h.uint(2)
+	if version > 0 {
+		count = count<<16 | h.uint(2)
+	}
+
+	boxes, err := readHEIFBoxes(bytes.NewReader(h.data), 0, int64(len(h.data)))
+	if err != nil {
+		return 0, err
+	}
+	for i, box := range boxes {
+		if uint64(i) >= count {
+			break
+		}
+		if box.boxType != "infe" {
+			continue
+		}
+
+		infe := &heifReader{data: h.data[box.offset : box.offset+box.size]}
+		infeVersion := infe.uint(1)
+		infe.uint(3) // Flags
+		if infeVersion < 2 {
+			continue // Versions before 2 carry no item type
+		}
+		id := infe.uint(2)
+		if infeVersion >= 3 {
+			id = id<<16 | infe.uint(2)
+		}
+		infe.uint(2) // Protection index
+		if infe.fourCC() == "Exif" && infe.err == nil {
+			return id, nil
+		}
+	}
+	return 0, errNoHEIFEXIF
+}
+
+// heifItemExtents returns the file offset and length of each extent of item
+// itemID in an item location box. Only items stored in the file itself, as
+// cameras write them, are supported.
+func heifItemExtents(iloc []byte, itemID uint64) ([][2]uint64, error) {
+	h := &heifReader{data: iloc}
+	version := h.uint(1)
+	h.uint(3) // Flags
+	sizes := h.uint(1)
+	offsetSize, lengthSize := int(sizes>>4), int(sizes&0x0F)
+	sizes = h.uint(1)
+	baseOffsetSize, indexSize := int(sizes>>4), int(sizes&0x0F)
+	if version == 0 {
+		indexSize = 0
+	}
+
+	idSize := 2
+	if version >= 2 {
+		idSize = 4
+	}
+	count := h.uint(idSize)
+
+	for i := uint64(0); i < count && h.err == nil; i++ {
+		id := h.uint(idSize)
+		constructionMethod := uint64(0)
+		if version >= 1 {
+			constructionMethod = h.uint(2) & 0x0F
+		}
+		h.uint(2) // Data reference index
+		baseOffset := h.uint(baseOffsetSize)
+
+		extentCount := h.uint(2)
+		var extents [][2]uint64
+		for j := uint64(0); j < extentCount && h.err == nil; j++ {
+			h.uint(indexSize)
+			offset := h.uint(offsetSize)
+			length := h.uint(lengthSize)
+			extents = append(extents, [2]uint64{baseOffset + offset, length})
+		}
+
+		if id == itemID && h.err == nil {
+			if constructionMethod != 0 {
+				return nil, fmt.Errorf("unsupported HEIF construction method %d", constructionMethod)
+			}
+			return extents, nil
+		}
+	}
+	if h.err != nil {
+		return nil, fmt.Errorf("malformed HEIF item locations: %w", h.err)
+	}
+	return nil, errNoHEIFEXIF
+}
@@ -0,0 +1,148 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// heifBoxBytes encodes a box of the given type around payload.
+func heifBoxBytes(boxType string, payload ...[]byte) []byte {
+	body := bytes.Join(payload, nil)
+	box := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	return append(append(box, boxType...), body...)
+}
+
+// buildEXIFHEIC returns a minimal HEIF file laid out as an iPhone writes it:
+// an "Exif" item listed in the meta box and stored in mdat behind the
+// "Exif\0\0" prefix. The image item itself holds no data.
+func buildEXIFHEIC(t testing.TB, fixture exifFixture) []byte {
+	t.Helper()
+
+	ftyp := heifBoxBytes("ftyp", []byte("heic\x00\x00\x00\x00mif1heic"))
+	hdlr := heifBoxBytes("hdlr", make([]byte, 8), []byte("pict"), make([]byte, 13))
+	iinf := heifBoxBytes("iinf",
+		[]byte{0, 0, 0, 0, 0, 2},
+		heifBoxBytes("infe", []byte{2, 0, 0, 0, 0, 1, 0, 0}, []byte("hvc1\x00")),
+		heifBoxBytes("infe", []byte{2, 0, 0, 0, 0, 2, 0, 0}, []byte("Exif\x00")),
+	)
+
+	item := append([]byte{0, 0, 0, 6}, "Exif\x00\x00"...)
+	item = append(item, buildEXIFTIFF(fixture)...)
+
+	iloc := func(offset uint32) []byte {
+		payload := []byte{0, 0, 0, 0, 0x44, 0x00, 0, 1, 0, 2, 0, 0, 0, 1}
+		payload = binary.BigEndian.AppendUint32(payload, offset)
+		payload = binary.BigEndian.AppendUint32(payload, uint32(len(item)))
+		return heifBoxBytes("iloc", payload)
+	}
+	meta := func(offset uint32) []byte {
+		return heifBoxBytes("meta", []byte{0, 0, 0, 0}, hdlr, iinf, iloc(offset))
+	}
+
+	// mdat's payload follows ftyp, meta and mdat's own header
+	offset := uint32(len(ftyp) + len(meta(0)) + 8)
+	return bytes.Join([][]byte{ftyp, meta(offset), heifBoxBytes("mdat", item)}, nil)
+}
+
+func TestExtractMetadataHEIC(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "IMG_0001.HEIC")
+	data := buildEXIFHEIC(t, exifFixture{Make: "Apple", Model: "iPhone 15 Pro", DateTimeOriginal: "2022:05:01 09:00:00"})
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	info, err := NewExtractor().ExtractMetadata(path)
+	if err != nil {
+		t.Fatalf("ExtractMetadata failed: %v", err)
+	}
+
+	if info.MediaType != MediaTypePhoto {
+		t.Errorf("Expected media type %s, got %s", MediaTypePhoto, info.MediaType)
+	}
+	if info.DateSource != DateSourceEXIF {
+		t.Errorf("Expected date source %s, got %s", DateSourceEXIF, info.DateSource)
+	}
+	expected := time.Date(2022, 5, 1, 9, 0, 0, 0, time.UTC)
+	if info.DateTaken == nil || !info.DateTaken.Equal(expected) {
+		t.Errorf("Expected date %v, got %v", expected, info.DateTaken)
+	}
+	if info.Camera == nil || info.Camera.Make != "Apple" || info.Camera.Model != "iPhone 15 Pro" {
+		t.Errorf("Expected Apple iPhone 15 Pro, got %+v", info.Camera)
+	}
+}
+
+func TestOrganizeFileHEIC(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+
+	source := filepath.Join(t.TempDir(), "upload_1.tmp")
+	data := buildEXIFHEIC(t, exifFixture{Make: "Apple", DateTimeOriginal: "2022:05:01 09:00:00"})
+	if err := os.WriteFile(source, data, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	info, err := organizer.OrganizeFileWithOptions(context.Background(), source, "IMG_0001.heic", OrganizeOptions{MediaType: MediaTypePhoto})
+	if err != nil {
+		t.Fatalf("OrganizeFileWithOptions failed: %v", err)
+	}
+	if info.DateSource != DateSourceEXIF {
+		t.Errorf("Expected date source %s, got %s", DateSourceEXIF, info.DateSource)
+	}
+	expectedPath := filepath.Join("2022", "May", "IMG_0001.heic")
+	if info.RelativePath != expectedPath {
+		t.Errorf("Expected %s, got %s", expectedPath, info.RelativePath)
+	}
+
+	files, err := organizer.ScanFiles("", "", 10, 0)
+	if err != nil {
+		t.Fatalf("ScanFiles failed: %v", err)
+	}
+	if len(files) != 1 || files[0].MediaType != "image" {
+		t.Errorf("Expected the HEIC listed as an image, got %+v", files)
+	}
+}
+
+func TestReadHEIFEXIFMalformed(t *testing.T) {
+	valid := buildEXIFHEIC(t, exifFixture{DateTimeOriginal: "2022:05:01 09:00:00"})
+
+	noEXIF := bytes.Replace(valid, []byte("Exif\x00"), []byte("mime\x00"), 1)
+	badExtent := bytes.Clone(valid)
+	extentOffset := bytes.Index(badExtent, []byte("iloc")) + 4 + 14
+	binary.BigEndian.PutUint32(badExtent[extentOffset:], 1<<30)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"Not HEIF", []byte("\xFF\xD8\xFF\xE0 a JPEG")},
+		{"Truncated", valid[:len(valid)/2]},
+		{"No EXIF item", noEXIF},
+		{"Extent outside the file", badExtent},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := readHEIFEXIF(bytes.NewReader(test.data), int64(len(test.data)), defaultEXIFReadLimit); err == nil {
+				t.Error("Expected an error")
+			}
+
+			// Extraction carries on without EXIF
+			path := filepath.Join(t.TempDir(), "photo.heic")
+			if err := os.WriteFile(path, test.data, 0644); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+			info, err := NewExtractor().ExtractMetadata(path)
+			if err != nil {
+				t.Fatalf("ExtractMetadata failed: %v", err)
+			}
+			if info.DateSource != DateSourceFileTime {
+				t.Errorf("Expected date source %s, got %s", DateSourceFileTime, info.DateSource)
+			}
+		})
+	}
+}
@@ -0,0 +1,436 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ImportStrategy decides which library folder imported files land in.
+type ImportStrategy string
+
+const (
+	// ImportByMonth files each photo into its Year/Month folder, exactly as
+	// uploads are organized.
+	ImportByMonth ImportStrategy = "month"
+	// ImportByEvent clusters the batch by DateTaken, starting a new event
+	// whenever consecutive shots are further apart than the event gap, and
+	// files each event into a folder named after its date range.
+	ImportByEvent ImportStrategy = "events"
+)
+
+const DefaultEventGap = 6 * time.Hour
+
+// minBurstFrames is the fewest shots treated as a burst; two photos taken a
+// second apart are usually deliberate.
+const minBurstFrames = 3
+
+type ImportOptions struct {
+	Strategy ImportStrategy
+	EventGap time.Duration // Only used by ImportByEvent; zero means DefaultEventGap
+	BurstGap time.Duration // Shots at most this far apart form a burst; zero disables burst detection
+
+	// PreserveDirs keeps the last N folders of each file's source path as
+	// subfolders of the folder the strategy picks, so Vacation2023/beach/img.jpg
+	// lands in 2023/July/beach with N=1. Zero flattens every file into it.
+	PreserveDirs int
+}
+
+type ImportedFile struct {
+	Source       string `json:"source"`
+	RelativePath string `json:"relativePath,omitempty"`
+	Duplicate    bool   `json:"duplicate,omitempty"`
+}
+
+type ImportFailure struct {
+	Source string `json:"source"`
+	Error  string `json:"error"`
+}
+
+type ImportEvent struct {
+	Folder string    `json:"folder"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+	Files  int       `json:"files"`
+}
+
+// ImportBurst is a run of rapid shots moved into its own subfolder, apart from
+// the representative frame left in the main folder.
+type ImportBurst struct {
+	Folder         string    `json:"folder"`
+	Representative string    `json:"representative"` // Source of the frame kept in the main folder
+	Start          time.Time `json:"start"`
+	Files          int       `json:"files"` // Including the representative
+}
+
+type ImportResult struct {
+	Imported []ImportedFile  `json:"imported"`
+	Failed   []ImportFailure `json:"failed"`
+	Events   []ImportEvent   `json:"events,omitempty"`
+	Bursts   []ImportBurst   `json:"bursts,omitempty"`
+}
+
+type importItem struct {
+	source    string // Path relative to the import directory
+	path      string
+	dateTaken *time.Time
+	dateFrom  DateSource
+	targetDir string
+	subfolder string // Preserved source folders, below targetDir
+	checksum  string // Planned checksum the staged copy must still have
+}
+
+// ImportDirectory copies every media file under sourceDir into the library.
+// Sources are never modified: each file is staged in the library's temp
+// directory and organized from there.
+func (o *Organizer) ImportDirectory(ctx context.Context, sourceDir string, opts ImportOptions) (*ImportResult, error) {
+	items, err := o.collectImportItems(sourceDir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{
+		Imported: []ImportedFile{},
+		Failed:   []ImportFailure{},
+	}
+
+	if result.Events, err = o.assignFolders(items, opts); err != nil {
+		return nil, err
+	}
+	if opts.BurstGap > 0 {
+		result.Bursts = o.assignBurstFolders(items, opts.BurstGap)
+	}
+
+	stagingDir, err := o.newStagingDir()
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(stagingDir)
+
+	for _, item := range items {
+		if err := ctx.Err(); err != nil {
+			return result, fmt.Errorf("import aborted: %w", err)
+		}
+
+		imported, err := o.importFile(ctx, stagingDir, item)
+		if err != nil {
+			slog.Warn("Failed to import file", "error", err, "source", item.source)
+			result.Failed = append(result.Failed, ImportFailure{Source: item.source, Error: err.Error()})
+			continue
+		}
+		result.Imported = append(result.Imported, *imported)
+	}
+
+	slog.Info("Import completed",
+		"source", sourceDir,
+		"strategy", opts.Strategy,
+		"imported", len(result.Imported),
+		"failed", len(result.Failed),
+	)
+
+	return result, nil
+}
+
+func (o *Organizer) collectImportItems(sourceDir string) ([]*importItem, error) {
+	if stat, err := os.Stat(sourceDir); err != nil {
+		return nil, fmt.Errorf("failed to read import directory: %w", err)
+	} else if !stat.IsDir() {
+		return nil, fmt.Errorf("import path %s is not a directory", sourceDir)
+	}
+
+	var items []*importItem
+	err := filepath.Walk(sourceDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if o.isHiddenDir(path, sourceDir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !o.isMediaFile(path) {
+			return nil
+		}
+
+		source, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return nil
+		}
+		items = append(items, &importItem{source: filepath.ToSlash(source), path: path})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan import directory: %w", err)
+	}
+
+	return items, nil
+}
+
+// assignFolders applies the import strategy and any preserved source folders
+// to items, returning the events it created.
+func (o *Organizer) assignFolders(items []*importItem, opts ImportOptions) ([]ImportEvent, error) {
+	if opts.PreserveDirs > 0 {
+		for _, item := range items {
+			item.subfolder = o.preservedSubfolder(item.source, opts.PreserveDirs)
+		}
+	}
+
+	switch opts.Strategy {
+	case "", ImportByMonth:
+		return nil, nil
+	case ImportByEvent:
+		gap := opts.EventGap
+		if gap <= 0 {
+			gap = DefaultEventGap
+		}
+		return o.assignEventFolders(items, gap), nil
+	default:
+		return nil, fmt.Errorf("unknown import strategy %q", opts.Strategy)
+	}
+}
+
+// datedItems extracts the DateTaken of any item not yet dated and returns the
+// dated items in chronological order.
+func (o *Organizer) datedItems(items []*importItem) []*importItem {
+	var dated []*importItem
+	for _, item := range items {
+		if item.dateTaken == nil {
+			info, err := o.extractor.ExtractMetadata(item.path)
+			if err != nil || info.DateTaken == nil {
+				continue
+			}
+			item.dateTaken = info.DateTaken
+			item.dateFrom = info.DateSource
+		}
+		dated = append(dated, item)
+	}
+
+	sort.SliceStable(dated, func(i, j int) bool {
+		return dated[i].dateTaken.Before(*dated[j].dateTaken)
+	})
+	return dated
+}
+
+// assignEventFolders sets each dated item's target folder to its event's
+// folder. Undated items keep the default month-based placement.
+func (o *Organizer) assignEventFolders(items []*importItem, gap time.Duration) []ImportEvent {
+	var events []ImportEvent
+	for _, cluster := range clusterByGap(o.datedItems(items), gap) {
+		start := *cluster[0].dateTaken
+		end := *cluster[len(cluster)-1].dateTaken
+		folder := eventFolderName(start, end)
+
+		for _, item := range cluster {
+			item.targetDir = folder
+		}
+		events = append(events, ImportEvent{Folder: folder, Start: start, End: end, Files: len(cluster)})
+	}
+
+	return events
+}
+
+// assignBurstFolders moves every burst but its first frame into a
+// Burst_<timestamp> subfolder of the folder the first frame is filed in, so
+// the burst shows up once in the main view. It runs after any event
+// assignment so bursts nest inside their event. Only capture times the
+// camera recorded count: files copied together share modification times
+// seconds apart without having been shot as a burst.
+func (o *Organizer) assignBurstFolders(items []*importItem, gap time.Duration) []ImportBurst {
+	var shots []*importItem
+	for _, item := range o.datedItems(items) {
+		switch item.dateFrom {
+		case DateSourceEXIF, DateSourceVideoMeta, DateSourceFileName:
+			shots = append(shots, item)
+		}
+	}
+
+	var bursts []ImportBurst
+	for _, cluster := range clusterByGap(shots, gap) {
+		if len(cluster) < minBurstFrames {
+			continue
+		}
+
+		representative := cluster[0]
+		baseDir := representative.targetDir
+		if baseDir == "" {
+			monthDir, err := o.getTargetDirectory(representative.dateTaken)
+			if err != nil {
+				continue
+			}
+			if baseDir, err = filepath.Rel(o.mediaPath, monthDir); err != nil {
+				continue
+			}
+		}
+
+		start := *representative.dateTaken
+		folder := filepath.Join(baseDir, representative.subfolder, "Burst_"+start.Format("20060102_150405"))
+		for _, item := range cluster[1:] {
+			item.targetDir = folder
+			item.subfolder = "" // The burst nests under the representative's folders
+		}
+
+		bursts = append(bursts, ImportBurst{
+			Folder:         folder,
+			Representative: representative.source,
+			Start:          start,
+			Files:          len(cluster),
+		})
+	}
+
+	return bursts
+}
+
+// preservedSubfolder returns the last n folders of a slash-separated source
+// path, each sanitized like a file name. Folders that sanitize to nothing,
+// such as "..", are dropped; leading dots are stripped so no folder comes out
+// hidden, and names the library reserves, which walks skip, get an
+// underscore appended.
+func (o *Organizer) preservedSubfolder(source string, n int) string {
+	dir := path.Dir(source)
+	if dir == "." {
+		return ""
+	}
+
+	folders := strings.Split(dir, "/")
+	var kept []string
+	for _, folder := range folders[max(len(folders)-n, 0):] {
+		if strings.Trim(folder, " .") == "" {
+			continue
+		}
+		name := strings.TrimLeft(o.sanitizeFileName(folder), ".")
+		if name == "" {
+			continue
+		}
+		if isReservedFolder(name) {
+			name += "_"
+		}
+		kept = append(kept, name)
+	}
+	return filepath.Join(kept...)
+}
+
+// isReservedFolder reports whether a folder name is one the library keeps
+// for itself.
+func isReservedFolder(name string) bool {
+	return strings.EqualFold(name, "temp") || strings.EqualFold(name, QuarantineFolder) || strings.EqualFold(name, TrashFolder)
+}
+
+// clusterByGap splits items, sorted by date, wherever consecutive dates are
+// more than gap apart.
+func clusterByGap(items []*importItem, gap time.Duration) [][]*importItem {
+	var clusters [][]*importItem
+	for i, item := range items {
+		if i == 0 || item.dateTaken.Sub(*items[i-1].dateTaken) > gap {
+			clusters = append(clusters, nil)
+		}
+		clusters[len(clusters)-1] = append(clusters[len(clusters)-1], item)
+	}
+	return clusters
+}
+
+// eventFolderName names an event folder by its date range under the year it
+// started, e.g. "2024/2024-03-15" or "2024/2024-03-15_2024-03-17".
+func eventFolderName(start, end time.Time) string {
+	name := start.Format("2006-01-02")
+	if last := end.Format("2006-01-02"); last != name {
+		name += "_" + last
+	}
+	return filepath.Join(start.Format("2006"), name)
+}
+
+func (o *Organizer) importFile(ctx context.Context, stagingDir string, item *importItem) (*ImportedFile, error) {
+	// Staging under the original base name keeps filename date parsing intact
+	fileName := filepath.Base(item.path)
+	stagedPath := filepath.Join(stagingDir, fileName)
+	if err := copyFile(item.path, stagedPath); err != nil {
+		return nil, fmt.Errorf("failed to stage file: %w", err)
+	}
+
+	// A planned file is checked again as staged: the copy is what gets
+	// stored, so a source edited since it was verified is caught here
+	if item.checksum != "" {
+		hash, err := o.calculateFileHash(stagedPath)
+		if err != nil || hash != item.checksum {
+			os.Remove(stagedPath)
+			if err != nil {
+				return nil, fmt.Errorf("failed to hash file: %w", err)
+			}
+			return nil, ErrSourceChanged
+		}
+	}
+
+	// The Takeout sidecar travels with the file so organizing can date it
+	if sidecar := takeoutSidecar(item.path); sidecar != "" {
+		stagedSidecar := stagedPath + sidecar[len(item.path):]
+		if err := copyFile(sidecar, stagedSidecar); err != nil {
+			slog.Warn("Failed to stage Takeout sidecar", "error", err, "file", sidecar)
+		}
+		defer os.Remove(stagedSidecar)
+	}
+
+	info, err := o.OrganizeFileWithOptions(ctx, stagedPath, fileName, OrganizeOptions{TargetDir: item.targetDir, Subfolder: item.subfolder})
+	if err != nil {
+		os.Remove(stagedPath)
+		return nil, err
+	}
+
+	return &ImportedFile{
+		Source:       item.source,
+		RelativePath: filepath.ToSlash(info.RelativePath),
+		Duplicate:    info.RelativePath == "",
+	}, nil
+}
+
+// newStagingDir creates a fresh directory in the library's temp directory
+// for files being imported.
+func (o *Organizer) newStagingDir() (string, error) {
+	tempDir := filepath.Join(o.mediaPath, "temp")
+	if err := os.MkdirAll(tempDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	stagingDir, err := os.MkdirTemp(tempDir, fmt.Sprintf("import-%d-", o.clock.Now().UnixNano()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create staging directory: %w", err)
+	}
+	return stagingDir, nil
+}
+
+// copyFile copies src to dst, preserving the modification time so file-time
+// date fallbacks see the original's timestamp.
+func copyFile(src, dst string) error {
+	source, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	stat, err := source.Stat()
+	if err != nil {
+		return err
+	}
+
+	target, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(target, source); err != nil {
+		target.Close()
+		os.Remove(dst)
+		return err
+	}
+	if err := target.Close(); err != nil {
+		os.Remove(dst)
+		return err
+	}
+
+	return os.Chtimes(dst, stat.ModTime(), stat.ModTime())
+}
@@ -0,0 +1,366 @@
+package media
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestImportDirectoryByEvent(t *testing.T) {
+	mediaDir := t.TempDir()
+	importDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+
+	// A morning at the beach, then an evening out the next day: the 30 hour
+	// gap splits them into two events.
+	beach := []string{"IMG_20240315_090000.jpg", "IMG_20240315_101500.jpg", "IMG_20240315_113000.jpg"}
+	dinner := []string{"IMG_20240316_193000.jpg", "IMG_20240316_231500.jpg", "IMG_20240317_003000.jpg"}
+	for _, name := range append(append([]string{}, beach...), dinner...) {
+		if err := os.WriteFile(filepath.Join(importDir, name), []byte("photo "+name), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	result, err := organizer.ImportDirectory(context.Background(), importDir, ImportOptions{
+		Strategy: ImportByEvent,
+		EventGap: 6 * time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("ImportDirectory failed: %v", err)
+	}
+
+	if len(result.Failed) != 0 {
+		t.Errorf("Expected no failures, got %+v", result.Failed)
+	}
+	if len(result.Events) != 2 {
+		t.Fatalf("Expected 2 events, got %+v", result.Events)
+	}
+
+	expected := []struct {
+		folder string
+		files  []string
+	}{
+		{filepath.Join("2024", "2024-03-15"), beach},
+		{filepath.Join("2024", "2024-03-16_2024-03-17"), dinner},
+	}
+	for i, event := range expected {
+		if result.Events[i].Folder != event.folder || result.Events[i].Files != len(event.files) {
+			t.Errorf("Expected event %s with %d files, got %+v", event.folder, len(event.files), result.Events[i])
+		}
+		for _, name := range event.files {
+			if _, err := os.Stat(filepath.Join(mediaDir, event.folder, name)); err != nil {
+				t.Errorf("Expected %s in %s: %v", name, event.folder, err)
+			}
+		}
+	}
+
+	// Imports copy: the source folder is left untouched
+	for _, name := range beach {
+		if _, err := os.Stat(filepath.Join(importDir, name)); err != nil {
+			t.Errorf("Expected source %s to remain: %v", name, err)
+		}
+	}
+}
+
+func TestImportDirectoryByMonth(t *testing.T) {
+	mediaDir := t.TempDir()
+	importDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+
+	if err := os.MkdirAll(filepath.Join(importDir, "phone"), 0755); err != nil {
+		t.Fatalf("Failed to create import subfolder: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(importDir, "phone", "IMG_20240315_090000.jpg"), []byte("photo"), 0644); err != nil {
+		t.Fatalf("Failed to create photo: %v", err)
+	}
+
+	result, err := organizer.ImportDirectory(context.Background(), importDir, ImportOptions{})
+	if err != nil {
+		t.Fatalf("ImportDirectory failed: %v", err)
+	}
+
+	if len(result.Imported) != 1 {
+		t.Fatalf("Expected 1 imported file, got %+v", result.Imported)
+	}
+	if result.Imported[0].Source != "phone/IMG_20240315_090000.jpg" {
+		t.Errorf("Expected source phone/IMG_20240315_090000.jpg, got %s", result.Imported[0].Source)
+	}
+	if result.Imported[0].RelativePath != "2024/March/IMG_20240315_090000.jpg" {
+		t.Errorf("Expected 2024/March/IMG_20240315_090000.jpg, got %s", result.Imported[0].RelativePath)
+	}
+
+	// Importing the same folder again finds only duplicates
+	result, err = organizer.ImportDirectory(context.Background(), importDir, ImportOptions{})
+	if err != nil {
+		t.Fatalf("Second ImportDirectory failed: %v", err)
+	}
+	if len(result.Imported) != 1 || !result.Imported[0].Duplicate {
+		t.Errorf("Expected the re-import to be reported as a duplicate, got %+v", result.Imported)
+	}
+}
+
+func TestImportDirectoryPreserveDirs(t *testing.T) {
+	files := []string{
+		"Vacation2023/beach/IMG_20230715_090000.jpg",
+		"Vacation2023/IMG_20230716_090000.jpg",
+		"Vacation2023/sun*set?/IMG_20230717_090000.jpg",
+		"IMG_20230718_090000.jpg",
+	}
+
+	tests := []struct {
+		name         string
+		preserveDirs int
+		expected     []string
+	}{
+		{"Flattened", 0, []string{
+			"2023/July/IMG_20230715_090000.jpg",
+			"2023/July/IMG_20230716_090000.jpg",
+			"2023/July/IMG_20230717_090000.jpg",
+			"2023/July/IMG_20230718_090000.jpg",
+		}},
+		{"Album folder kept", 1, []string{
+			"2023/July/beach/IMG_20230715_090000.jpg",
+			"2023/July/Vacation2023/IMG_20230716_090000.jpg",
+			"2023/July/sun_set_/IMG_20230717_090000.jpg",
+			"2023/July/IMG_20230718_090000.jpg",
+		}},
+		{"More levels than the path has", 3, []string{
+			"2023/July/Vacation2023/beach/IMG_20230715_090000.jpg",
+			"2023/July/Vacation2023/IMG_20230716_090000.jpg",
+			"2023/July/Vacation2023/sun_set_/IMG_20230717_090000.jpg",
+			"2023/July/IMG_20230718_090000.jpg",
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mediaDir := t.TempDir()
+			importDir := t.TempDir()
+			organizer := NewOrganizer(mediaDir)
+
+			for _, file := range files {
+				path := filepath.Join(importDir, filepath.FromSlash(file))
+				if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+					t.Fatalf("Failed to create import folder: %v", err)
+				}
+				if err := os.WriteFile(path, []byte("photo "+file), 0644); err != nil {
+					t.Fatalf("Failed to create %s: %v", file, err)
+				}
+			}
+
+			result, err := organizer.ImportDirectory(context.Background(), importDir, ImportOptions{PreserveDirs: test.preserveDirs})
+			if err != nil {
+				t.Fatalf("ImportDirectory failed: %v", err)
+			}
+			if len(result.Failed) != 0 {
+				t.Errorf("Expected no failures, got %+v", result.Failed)
+			}
+
+			for _, relPath := range test.expected {
+				if _, err := os.Stat(filepath.Join(mediaDir, filepath.FromSlash(relPath))); err != nil {
+					t.Errorf("Expected %s: %v", relPath, err)
+				}
+			}
+		})
+	}
+}
+
+func TestPreservedSubfolderAvoidsReservedNames(t *testing.T) {
+	organizer := NewOrganizer(t.TempDir())
+
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{"Trip/beach/photo.jpg", "Trip/beach"},
+		{"Trip/temp/photo.jpg", "Trip/temp_"},
+		{"Trip/Quarantine/photo.jpg", "Trip/Quarantine_"},
+		{"Trip/.album/photo.jpg", "Trip/album"},
+		{"Trip/.trash/photo.jpg", "Trip/trash"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			if got := filepath.ToSlash(organizer.preservedSubfolder(test.source, 2)); got != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestImportDirectorySkipsHiddenFiles(t *testing.T) {
+	mediaDir := t.TempDir()
+	importDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+
+	for _, name := range []string{"IMG_20240315_090000.jpg", "._IMG_20240315_090000.jpg", ".DS_Store"} {
+		if err := os.WriteFile(filepath.Join(importDir, name), []byte("content of "+name), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	result, err := organizer.ImportDirectory(context.Background(), importDir, ImportOptions{})
+	if err != nil {
+		t.Fatalf("ImportDirectory failed: %v", err)
+	}
+	if len(result.Imported) != 1 || result.Imported[0].Source != "IMG_20240315_090000.jpg" {
+		t.Errorf("Expected only IMG_20240315_090000.jpg to be imported, got %+v", result.Imported)
+	}
+}
+
+func TestImportDirectoryTakeoutSidecar(t *testing.T) {
+	mediaDir := t.TempDir()
+	importDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+
+	// A Takeout export: EXIF stripped, the date kept in the sidecar
+	files := map[string]string{
+		"photo.jpg":      "stripped photo",
+		"photo.jpg.json": `{"photoTakenTime": {"timestamp": "1584282622"}}`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(importDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	result, err := organizer.ImportDirectory(context.Background(), importDir, ImportOptions{})
+	if err != nil {
+		t.Fatalf("ImportDirectory failed: %v", err)
+	}
+	if len(result.Imported) != 1 {
+		t.Fatalf("Expected only the photo to be imported, got %+v", result.Imported)
+	}
+	if expected := "2020/March/photo.jpg"; result.Imported[0].RelativePath != expected {
+		t.Errorf("Expected photo filed at %s, got %s", expected, result.Imported[0].RelativePath)
+	}
+}
+
+func TestImportDirectoryBursts(t *testing.T) {
+	mediaDir := t.TempDir()
+	importDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+
+	// Four frames within two seconds, then unrelated shots minutes later
+	burst := []string{
+		"IMG_20240315_143022.jpg",
+		"IMG_20240315_143022500.jpg",
+		"IMG_20240315_143023.jpg",
+		"IMG_20240315_143024.jpg",
+	}
+	singles := []string{"IMG_20240315_150000.jpg", "IMG_20240315_150500.jpg"}
+	for _, name := range append(append([]string{}, burst...), singles...) {
+		if err := os.WriteFile(filepath.Join(importDir, name), []byte("photo "+name), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	result, err := organizer.ImportDirectory(context.Background(), importDir, ImportOptions{
+		BurstGap: 2 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("ImportDirectory failed: %v", err)
+	}
+
+	if len(result.Imported) != len(burst)+len(singles) {
+		t.Fatalf("Expected %d imported files, got %+v", len(burst)+len(singles), result)
+	}
+	if len(result.Bursts) != 1 {
+		t.Fatalf("Expected 1 burst, got %+v", result.Bursts)
+	}
+
+	monthDir := filepath.Join("2024", "March")
+	burstDir := filepath.Join(monthDir, "Burst_20240315_143022")
+	if got := result.Bursts[0]; got.Folder != burstDir || got.Files != len(burst) || got.Representative != burst[0] {
+		t.Errorf("Expected burst %s of %d files represented by %s, got %+v", burstDir, len(burst), burst[0], got)
+	}
+
+	// The representative and the unrelated shots stay in the month folder
+	for _, name := range append([]string{burst[0]}, singles...) {
+		if _, err := os.Stat(filepath.Join(mediaDir, monthDir, name)); err != nil {
+			t.Errorf("Expected %s in %s: %v", name, monthDir, err)
+		}
+	}
+	for _, name := range burst[1:] {
+		if _, err := os.Stat(filepath.Join(mediaDir, burstDir, name)); err != nil {
+			t.Errorf("Expected %s in %s: %v", name, burstDir, err)
+		}
+	}
+}
+
+func TestImportDirectoryBurstsIgnoreFileTimes(t *testing.T) {
+	mediaDir := t.TempDir()
+	importDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+
+	// Undated files copied in one go, a second apart
+	copied := time.Date(2024, 3, 15, 14, 30, 22, 0, time.UTC)
+	for i, name := range []string{"a.jpg", "b.jpg", "c.jpg"} {
+		path := filepath.Join(importDir, name)
+		if err := os.WriteFile(path, []byte("photo "+name), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+		modTime := copied.Add(time.Duration(i) * time.Second)
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatalf("Failed to set modification time: %v", err)
+		}
+	}
+
+	result, err := organizer.ImportDirectory(context.Background(), importDir, ImportOptions{BurstGap: 2 * time.Second})
+	if err != nil {
+		t.Fatalf("ImportDirectory failed: %v", err)
+	}
+	if len(result.Bursts) != 0 {
+		t.Errorf("Expected file times not to form a burst, got %+v", result.Bursts)
+	}
+}
+
+func TestImportDirectoryBurstsDisabled(t *testing.T) {
+	mediaDir := t.TempDir()
+	importDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+
+	for _, name := range []string{"IMG_20240315_143022.jpg", "IMG_20240315_143023.jpg", "IMG_20240315_143024.jpg"} {
+		if err := os.WriteFile(filepath.Join(importDir, name), []byte("photo "+name), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	result, err := organizer.ImportDirectory(context.Background(), importDir, ImportOptions{})
+	if err != nil {
+		t.Fatalf("ImportDirectory failed: %v", err)
+	}
+	if len(result.Bursts) != 0 {
+		t.Errorf("Expected no bursts when detection is disabled, got %+v", result.Bursts)
+	}
+}
+
+func TestImportDirectoryReportsOrganizedFiles(t *testing.T) {
+	mediaDir := t.TempDir()
+	importDir := t.TempDir()
+
+	var organized []OrganizedFile
+	organizer := NewOrganizerWithOptions(mediaDir, OrganizerOptions{
+		OnOrganized: func(file OrganizedFile) { organized = append(organized, file) },
+	})
+
+	writeImportFiles(t, importDir, map[string]string{
+		"IMG_20240315_090000.jpg":      "first",
+		"copy/IMG_20240315_090000.jpg": "first",
+	})
+	if _, err := organizer.ImportDirectory(context.Background(), importDir, ImportOptions{}); err != nil {
+		t.Fatalf("ImportDirectory failed: %v", err)
+	}
+
+	if len(organized) != 2 {
+		t.Fatalf("Expected 2 organized files, got %+v", organized)
+	}
+	if organized[0].RelativePath != "2024/March/IMG_20240315_090000.jpg" || organized[0].MediaInfo == nil {
+		t.Errorf("Expected the stored file with its metadata, got %+v", organized[0])
+	}
+	if !organized[1].Duplicate || organized[1].RelativePath != "" {
+		t.Errorf("Expected the second copy reported as a duplicate, got %+v", organized[1])
+	}
+}
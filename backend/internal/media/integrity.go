@@ -0,0 +1,208 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// VerifyOptions controls one integrity verification pass.
+type VerifyOptions struct {
+	Folder         string // Library-relative folder to verify; empty for everything
+	Cursor         string // Resume after this path, as returned in NextCursor
+	Limit          int    // Maximum files to hash in this pass; zero means no limit
+	BytesPerSecond int64  // Read throttle; zero means unthrottled
+}
+
+type IntegrityMismatch struct {
+	RelativePath string `json:"relativePath"`
+	Expected     string `json:"expected"`
+	Actual       string `json:"actual"`
+}
+
+type IntegrityReport struct {
+	Checked    int                 `json:"checked"`
+	Indexed    int                 `json:"indexed"` // Files with no stored checksum, recorded now
+	Mismatches []IntegrityMismatch `json:"mismatches"`
+	Missing    []string            `json:"missing"`
+	NextCursor string              `json:"nextCursor,omitempty"` // Empty once the pass is complete
+	Complete   bool                `json:"complete"`
+}
+
+// VerifyIntegrity rehashes library files and compares them with the checksums
+// recorded when they were organized, reporting silent corruption and indexed
+// files that have disappeared. Files that predate the index are hashed and
+// recorded so later passes can verify them. Large libraries are verified in
+// batches: pass NextCursor back as Cursor until Complete is true.
+func (o *Organizer) VerifyIntegrity(ctx context.Context, opts VerifyOptions) (*IntegrityReport, error) {
+	root := o.mediaPath
+	if opts.Folder != "" {
+		var err error
+		if root, err = o.ResolvePath(opts.Folder); err != nil {
+			return nil, err
+		}
+	}
+
+	folder, err := filepath.Rel(o.mediaPath, root)
+	if err != nil {
+		return nil, err
+	}
+	folder = filepath.ToSlash(folder)
+	if folder == "." {
+		folder = ""
+	}
+
+	// Collect and sort paths up front so the cursor order matches the
+	// checksum index order regardless of how the walk orders directories.
+	var candidates []string
+	err = filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if o.skipDir(path, o.mediaPath) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !o.isMediaFile(path) {
+			return nil
+		}
+		if relPath, err := filepath.Rel(o.mediaPath, path); err == nil {
+			candidates = append(candidates, filepath.ToSlash(relPath))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("integrity verification aborted: %w", err)
+	}
+	sort.Strings(candidates)
+
+	report := &IntegrityReport{
+		Mismatches: []IntegrityMismatch{},
+		Missing:    []string{},
+		Complete:   true,
+	}
+	seen := make(map[string]bool)
+	lastPath := ""
+
+	for _, relPath := range candidates {
+		if opts.Cursor != "" && relPath <= opts.Cursor {
+			continue
+		}
+		if opts.Limit > 0 && report.Checked+report.Indexed >= opts.Limit {
+			report.Complete = false
+			break
+		}
+
+		actual, err := hashFileThrottled(ctx, filepath.Join(o.mediaPath, filepath.FromSlash(relPath)), o.newHash(), opts.BytesPerSecond)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, fmt.Errorf("integrity verification aborted: %w", ctxErr)
+			}
+			continue // Unreadable now; reported as missing below
+		}
+		seen[relPath] = true
+		lastPath = relPath
+
+		expected, ok := o.checksums.Get(relPath)
+		if !ok {
+			o.checksums.Set(relPath, actual)
+			report.Indexed++
+			continue
+		}
+
+		report.Checked++
+		if expected != actual {
+			report.Mismatches = append(report.Mismatches, IntegrityMismatch{
+				RelativePath: relPath,
+				Expected:     expected,
+				Actual:       actual,
+			})
+		}
+	}
+
+	// Indexed paths inside the range this pass covered that the walk never
+	// reached no longer exist.
+	for _, relPath := range o.checksums.Paths(folder) {
+		if opts.Cursor != "" && relPath <= opts.Cursor {
+			continue
+		}
+		if !report.Complete && relPath > lastPath {
+			break
+		}
+		if !seen[relPath] {
+			report.Missing = append(report.Missing, relPath)
+		}
+	}
+
+	if !report.Complete {
+		report.NextCursor = lastPath
+	}
+
+	if report.Indexed > 0 {
+		if err := o.checksums.Save(); err != nil {
+			return nil, err
+		}
+	}
+
+	return report, nil
+}
+
+// hashFileThrottled feeds a file through hash, reading no faster than
+// bytesPerSecond so verification doesn't starve uploads of disk bandwidth.
+func hashFileThrottled(ctx context.Context, filePath string, hash hash.Hash, bytesPerSecond int64) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if bytesPerSecond > 0 {
+		reader = &throttledReader{ctx: ctx, reader: file, bytesPerSecond: bytesPerSecond, start: time.Now()}
+	}
+
+	if _, err := io.Copy(hash, reader); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
+type throttledReader struct {
+	ctx            context.Context
+	reader         io.Reader
+	bytesPerSecond int64
+	start          time.Time
+	read           int64
+}
+
+func (t *throttledReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > t.bytesPerSecond {
+		p = p[:t.bytesPerSecond]
+	}
+
+	n, err := t.reader.Read(p)
+	t.read += int64(n)
+
+	// Sleep until the average rate is back under the limit
+	expected := time.Duration(float64(t.read) / float64(t.bytesPerSecond) * float64(time.Second))
+	if wait := expected - time.Since(t.start); wait > 0 {
+		select {
+		case <-time.After(wait):
+		case <-t.ctx.Done():
+			return n, t.ctx.Err()
+		}
+	}
+
+	return n, err
+}
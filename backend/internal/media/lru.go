@@ -0,0 +1,202 @@
+package media
+
+import (
+	"container/list"
+	"sync"
+)
+
+// CacheStats reports the size and effectiveness of a bounded cache.
+type CacheStats struct {
+	Entries    int   `json:"entries"`
+	Bytes      int64 `json:"bytes"`
+	MaxEntries int   `json:"maxEntries"` // Zero means unbounded
+	MaxBytes   int64 `json:"maxBytes"`   // Zero means unbounded
+	Hits       int64 `json:"hits"`
+	Misses     int64 `json:"misses"`
+	Evictions  int64 `json:"evictions"`
+}
+
+// lruCache is a concurrency-safe map that evicts its least recently used
+// entries once it holds more than maxEntries entries or maxBytes bytes, as
+// measured by sizeOf. A zero limit doesn't bound that dimension. Pinned keys
+// are never evicted, so the cache can run over its limits until they are
+// unpinned.
+type lruCache[K comparable, V any] struct {
+	mutex      sync.Mutex
+	order      *list.List // Most recently used at the front
+	items      map[K]*list.Element
+	pins       map[K]int // Pin count of each pinned key, present in the cache or not
+	maxEntries int
+	maxBytes   int64
+	bytes      int64
+	sizeOf     func(V) int64 // Nil counts every entry as zero bytes
+	onEvict    func(K, V)    // Called, without the lock held, for entries pushed out by the limits
+
+	hits, misses, evictions int64
+}
+
+type lruItem[K comparable, V any] struct {
+	key   K
+	value V
+	size  int64
+}
+
+func newLRUCache[K comparable, V any](maxEntries int, maxBytes int64, sizeOf func(V) int64) *lruCache[K, V] {
+	return &lruCache[K, V]{
+		order:      list.New(),
+		items:      make(map[K]*list.Element),
+		pins:       make(map[K]int),
+		maxEntries: max(maxEntries, 0),
+		maxBytes:   max(maxBytes, 0),
+		sizeOf:     sizeOf,
+	}
+}
+
+// get returns the value for key, marking it most recently used. Every call
+// counts as a hit or a miss.
+func (c *lruCache[K, V]) get(key K) (V, bool) {
+	return c.getValid(key, nil)
+}
+
+// getValid is get for caches whose entries can go stale: an entry valid
+// rejects counts as a miss and is dropped.
+func (c *lruCache[K, V]) getValid(key K, valid func(V) bool) (V, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	element, ok := c.items[key]
+	if ok && valid != nil && !valid(element.Value.(*lruItem[K, V]).value) {
+		c.removeElement(element)
+		ok = false
+	}
+	if !ok {
+		c.misses++
+		var zero V
+		return zero, false
+	}
+	c.hits++
+	c.order.MoveToFront(element)
+	return element.Value.(*lruItem[K, V]).value, true
+}
+
+// add stores value under key as the most recently used entry, then evicts
+// from the other end until the cache is back within its limits. The newest
+// entry is never evicted, even when it alone exceeds maxBytes.
+func (c *lruCache[K, V]) add(key K, value V) {
+	var size int64
+	if c.sizeOf != nil {
+		size = c.sizeOf(value)
+	}
+
+	c.mutex.Lock()
+	if element, ok := c.items[key]; ok {
+		item := element.Value.(*lruItem[K, V])
+		c.bytes += size - item.size
+		item.value, item.size = value, size
+		c.order.MoveToFront(element)
+	} else {
+		c.items[key] = c.order.PushFront(&lruItem[K, V]{key: key, value: value, size: size})
+		c.bytes += size
+	}
+
+	c.trim()
+}
+
+// pin keeps key from being evicted until a matching unpin. Keys can be
+// pinned before they are added, so an entry being created is safe too.
+func (c *lruCache[K, V]) pin(key K) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.pins[key]++
+}
+
+// unpin releases a pin, evicting whatever the pin kept the cache from
+// evicting once the key is no longer pinned.
+func (c *lruCache[K, V]) unpin(key K) {
+	c.mutex.Lock()
+	if c.pins[key]--; c.pins[key] <= 0 {
+		delete(c.pins, key)
+	}
+	c.trim()
+}
+
+// trim evicts unpinned entries, least recently used first, until the cache is
+// back within its limits, never evicting the newest entry. It must be called
+// with the lock held and releases it before calling onEvict.
+func (c *lruCache[K, V]) trim() {
+	var evicted []*lruItem[K, V]
+	for element := c.order.Back(); element != nil && element != c.order.Front() && c.overLimits(); {
+		previous := element.Prev()
+		if item := element.Value.(*lruItem[K, V]); c.pins[item.key] == 0 {
+			evicted = append(evicted, c.removeElement(element))
+			c.evictions++
+		}
+		element = previous
+	}
+	onEvict := c.onEvict
+	c.mutex.Unlock()
+
+	if onEvict != nil {
+		for _, item := range evicted {
+			onEvict(item.key, item.value)
+		}
+	}
+}
+
+func (c *lruCache[K, V]) overLimits() bool {
+	return (c.maxEntries > 0 && c.order.Len() > c.maxEntries) || (c.maxBytes > 0 && c.bytes > c.maxBytes)
+}
+
+func (c *lruCache[K, V]) remove(key K) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.items[key]; ok {
+		c.removeElement(element)
+	}
+}
+
+func (c *lruCache[K, V]) removeElement(element *list.Element) *lruItem[K, V] {
+	item := c.order.Remove(element).(*lruItem[K, V])
+	delete(c.items, item.key)
+	c.bytes -= item.size
+	return item
+}
+
+// purge drops every entry without calling onEvict.
+func (c *lruCache[K, V]) purge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.order.Init()
+	c.items = make(map[K]*list.Element)
+	c.bytes = 0
+}
+
+// keys returns the cached keys, most recently used first.
+func (c *lruCache[K, V]) keys() []K {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	keys := make([]K, 0, c.order.Len())
+	for element := c.order.Front(); element != nil; element = element.Next() {
+		keys = append(keys, element.Value.(*lruItem[K, V]).key)
+	}
+	return keys
+}
+
+func (c *lruCache[K, V]) stats() CacheStats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return CacheStats{
+		Entries:    c.order.Len(),
+		Bytes:      c.bytes,
+		MaxEntries: c.maxEntries,
+		MaxBytes:   c.maxBytes,
+		Hits:       c.hits,
+		Misses:     c.misses,
+		Evictions:  c.evictions,
+	}
+}
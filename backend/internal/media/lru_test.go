@@ -0,0 +1,225 @@
+package media
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLRUCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	var evicted []string
+	cache := newLRUCache[string, int](3, 0, nil)
+	cache.onEvict = func(key string, _ int) { evicted = append(evicted, key) }
+
+	cache.add("a", 1)
+	cache.add("b", 2)
+	cache.add("c", 3)
+	cache.get("a") // "b" is now the oldest
+	cache.add("d", 4)
+
+	if _, ok := cache.get("b"); ok {
+		t.Error("Expected b to be evicted")
+	}
+	if !reflect.DeepEqual(evicted, []string{"b"}) {
+		t.Errorf("Expected [b] evicted, got %v", evicted)
+	}
+	if keys := cache.keys(); !reflect.DeepEqual(keys, []string{"d", "a", "c"}) {
+		t.Errorf("Expected [d a c] most recent first, got %v", keys)
+	}
+
+	stats := cache.stats()
+	if stats.Hits != 1 || stats.Misses != 1 || stats.Evictions != 1 || stats.Entries != 3 {
+		t.Errorf("Expected 1 hit, 1 miss, 1 eviction and 3 entries, got %+v", stats)
+	}
+}
+
+func TestLRUCacheByteLimit(t *testing.T) {
+	cache := newLRUCache[string, int64](0, 100, func(size int64) int64 { return size })
+
+	cache.add("a", 40)
+	cache.add("b", 40)
+	cache.add("c", 40) // Over by 20; "a" goes
+	if keys := cache.keys(); !reflect.DeepEqual(keys, []string{"c", "b"}) {
+		t.Errorf("Expected [c b], got %v", keys)
+	}
+
+	cache.add("b", 90) // Growing an entry evicts the others
+	if keys := cache.keys(); !reflect.DeepEqual(keys, []string{"b"}) {
+		t.Errorf("Expected [b], got %v", keys)
+	}
+
+	cache.add("huge", 500) // Kept alone though over the limit
+	if stats := cache.stats(); stats.Entries != 1 || stats.Bytes != 500 {
+		t.Errorf("Expected only the oversized entry, got %+v", stats)
+	}
+}
+
+func TestLRUCachePinnedEntriesSurviveEviction(t *testing.T) {
+	var evicted []string
+	cache := newLRUCache[string, int](2, 0, nil)
+	cache.onEvict = func(key string, _ int) { evicted = append(evicted, key) }
+
+	cache.pin("a") // Pinned before it is added, as a thumbnail being generated is
+	cache.add("a", 1)
+	cache.add("b", 2)
+	cache.add("c", 3)
+
+	if !reflect.DeepEqual(evicted, []string{"b"}) {
+		t.Errorf("Expected only the unpinned b evicted, got %v", evicted)
+	}
+
+	cache.add("d", 4)
+	if keys := cache.keys(); !reflect.DeepEqual(keys, []string{"d", "a"}) {
+		t.Errorf("Expected [d a] with a pinned, got %v", keys)
+	}
+
+	cache.pin("d")
+	cache.add("e", 5) // Everything but the newest is pinned; the cache runs over
+	if stats := cache.stats(); stats.Entries != 3 {
+		t.Errorf("Expected 3 entries while a and d are pinned, got %+v", stats)
+	}
+
+	cache.unpin("a")
+	if !reflect.DeepEqual(evicted, []string{"b", "c", "a"}) {
+		t.Errorf("Expected a evicted once unpinned, got %v", evicted)
+	}
+	if keys := cache.keys(); !reflect.DeepEqual(keys, []string{"e", "d"}) {
+		t.Errorf("Expected [e d], got %v", keys)
+	}
+}
+
+func TestMetadataIndexBoundAndCounters(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizerWithOptions(mediaDir, OrganizerOptions{MetadataCacheEntries: 2})
+
+	var infos []os.FileInfo
+	for i, name := range []string{"a.jpg", "b.jpg", "c.jpg"} {
+		path := filepath.Join(mediaDir, name)
+		if err := os.WriteFile(path, []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to write file: %v", err)
+		}
+		fileInfo, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("Stat failed: %v", err)
+		}
+		infos = append(infos, fileInfo)
+		if _, err := organizer.metadataFor(path, name, fileInfo); err != nil {
+			t.Fatalf("metadataFor %d failed: %v", i, err)
+		}
+	}
+
+	if _, ok := organizer.metadata.get("a.jpg", infos[0]); ok {
+		t.Error("Expected the oldest entry to be evicted")
+	}
+	if _, ok := organizer.metadata.get("c.jpg", infos[2]); !ok {
+		t.Error("Expected the newest entry to be cached")
+	}
+
+	// A modified file is a miss, not a stale hit
+	path := filepath.Join(mediaDir, "c.jpg")
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+	modified, _ := os.Stat(path)
+	if _, ok := organizer.metadata.get("c.jpg", modified); ok {
+		t.Error("Expected a changed file to miss")
+	}
+
+	// Three extraction misses, then the lookups above
+	stats := organizer.MetadataCacheStats()
+	if stats.Hits != 1 || stats.Misses != 5 || stats.Entries != 1 || stats.MaxEntries != 2 {
+		t.Errorf("Expected 1 hit, 5 misses and 1 of 2 entries, got %+v", stats)
+	}
+}
+
+func TestThumbnailCacheLimit(t *testing.T) {
+	mediaDir := t.TempDir()
+	cacheDir := t.TempDir()
+
+	var sources []string
+	for _, name := range []string{"a.png", "b.png", "c.png"} {
+		path := filepath.Join(mediaDir, name)
+		writeTestImage(t, path, 64, 64)
+		sources = append(sources, path)
+	}
+
+	// Measure one thumbnail, with its placeholder sidecars, to size the limit at two
+	probe := NewThumbnailer(t.TempDir(), 32, nil)
+	probePath, err := probe.Thumbnail(context.Background(), sources[0])
+	if err != nil {
+		t.Fatalf("Thumbnail failed: %v", err)
+	}
+	stat, _ := os.Stat(probePath)
+	size := cachedSize(probePath)
+	if size <= stat.Size() {
+		t.Fatalf("Expected the sidecars to count toward the cached size, got %d for a %d byte thumbnail", size, stat.Size())
+	}
+
+	thumbnailer := NewThumbnailerWithLimit(cacheDir, 32, nil, 2*size)
+	paths := make([]string, len(sources))
+	for i, source := range sources {
+		if paths[i], err = thumbnailer.Thumbnail(context.Background(), source); err != nil {
+			t.Fatalf("Thumbnail failed: %v", err)
+		}
+	}
+	if _, err := thumbnailer.Thumbnail(context.Background(), sources[2]); err != nil {
+		t.Fatalf("Thumbnail failed: %v", err)
+	}
+
+	if _, err := os.Stat(paths[0]); !os.IsNotExist(err) {
+		t.Error("Expected the least recently used thumbnail to be deleted")
+	}
+	if _, err := os.Stat(blurhashPath(paths[0])); !os.IsNotExist(err) {
+		t.Error("Expected the evicted thumbnail's blurhash to be deleted")
+	}
+	for _, path := range paths[1:] {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected %s to stay cached: %v", filepath.Base(path), err)
+		}
+	}
+
+	stats := thumbnailer.CacheStats()
+	if stats.Hits != 1 || stats.Misses != 3 || stats.Evictions != 1 || stats.Entries != 2 {
+		t.Errorf("Expected 1 hit, 3 misses, 1 eviction and 2 entries, got %+v", stats)
+	}
+
+	// A restart picks up the cache already on disk
+	reloaded := NewThumbnailerWithLimit(cacheDir, 32, nil, 2*size)
+	if stats := reloaded.CacheStats(); stats.Entries != 2 || stats.Bytes != 2*size {
+		t.Errorf("Expected the 2 cached thumbnails tracked after a restart, got %+v", stats)
+	}
+}
+
+func TestThumbnailAcquirePinsAgainstEviction(t *testing.T) {
+	mediaDir := t.TempDir()
+
+	var sources []string
+	for _, name := range []string{"a.png", "b.png"} {
+		path := filepath.Join(mediaDir, name)
+		writeTestImage(t, path, 64, 64)
+		sources = append(sources, path)
+	}
+
+	// Room for a single thumbnail
+	thumbnailer := NewThumbnailerWithLimit(t.TempDir(), 32, nil, 1)
+	served, release, err := thumbnailer.AcquireThumbnail(context.Background(), sources[0])
+	if err != nil {
+		t.Fatalf("AcquireThumbnail failed: %v", err)
+	}
+	if _, err := thumbnailer.Thumbnail(context.Background(), sources[1]); err != nil {
+		t.Fatalf("Thumbnail failed: %v", err)
+	}
+
+	if _, err := os.Stat(served); err != nil {
+		t.Errorf("Expected the thumbnail being served to survive eviction: %v", err)
+	}
+
+	release()
+	if _, err := os.Stat(served); !os.IsNotExist(err) {
+		t.Error("Expected the thumbnail to be evicted once released")
+	}
+}
@@ -0,0 +1,213 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// defaultMaintenancePoll is how often a Maintainer checks the clock for a due
+// pass when the interval is longer.
+const defaultMaintenancePoll = time.Minute
+
+// MaintenanceOptions configures background index maintenance.
+type MaintenanceOptions struct {
+	Interval       time.Duration // Time between passes; zero disables maintenance
+	MaxFiles       int           // New files hashed per pass, the rest waiting for the next; zero means no limit
+	BytesPerSecond int64         // Read throttle while hashing new files; zero means unthrottled
+	PollInterval   time.Duration // How often the clock is checked for a due pass; zero means a minute
+}
+
+type MaintenanceReport struct {
+	Added    []string `json:"added"`    // Files on disk the indexes didn't know about
+	Removed  []string `json:"removed"`  // Indexed files no longer on disk
+	Deferred int      `json:"deferred"` // New files left for a later pass by MaxFiles
+	Bytes    int64    `json:"bytes"`
+}
+
+// Reconcile brings the checksum and metadata indexes and the library size
+// back in line with the files actually on disk, for changes made behind the
+// server's back: files copied into the library are hashed and indexed, and
+// entries for deleted files are dropped. It reads at most MaxFiles new files
+// per pass, at no more than BytesPerSecond.
+func (o *Organizer) Reconcile(ctx context.Context, opts MaintenanceOptions) (*MaintenanceReport, error) {
+	var (
+		present []string
+		bytes   int64
+	)
+	mark, marked := o.markLibrarySize()
+	err := filepath.Walk(o.mediaPath, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if o.skipDir(path, o.mediaPath) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		bytes += info.Size()
+		if !o.isMediaFile(path) {
+			return nil
+		}
+		if relPath, err := filepath.Rel(o.mediaPath, path); err == nil {
+			present = append(present, filepath.ToSlash(relPath))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("maintenance aborted: %w", err)
+	}
+	sort.Strings(present)
+
+	report := &MaintenanceReport{Added: []string{}, Removed: []string{}, Bytes: bytes}
+	onDisk := make(map[string]bool, len(present))
+	assigned := 0 // Content IDs and image hashes added for files indexed without them
+	for _, relPath := range present {
+		onDisk[relPath] = true
+		if checksum, ok := o.checksums.Get(relPath); ok {
+			ok, err := o.assignFileID(ctx, relPath, checksum, opts.BytesPerSecond)
+			if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+				return nil, fmt.Errorf("maintenance aborted: %w", ctxErr)
+			}
+			if ok {
+				assigned++
+			}
+			if o.indexImageHash(relPath) {
+				assigned++
+			}
+			continue
+		}
+		if opts.MaxFiles > 0 && len(report.Added) >= opts.MaxFiles {
+			report.Deferred++
+			continue
+		}
+
+		path := filepath.Join(o.mediaPath, filepath.FromSlash(relPath))
+		checksum, err := hashFileThrottled(ctx, path, o.newHash(), opts.BytesPerSecond)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, fmt.Errorf("maintenance aborted: %w", ctxErr)
+			}
+			continue // Removed or unreadable since the walk; the next pass decides
+		}
+		o.checksums.Set(relPath, checksum)
+		if _, err := o.assignFileID(ctx, relPath, checksum, opts.BytesPerSecond); err != nil {
+			slog.Debug("Failed to assign file ID during maintenance", "file", relPath, "error", err)
+		}
+		o.indexImageHash(relPath)
+		if fileInfo, err := os.Stat(path); err == nil {
+			if _, err := o.metadataFor(path, relPath, fileInfo); err != nil {
+				slog.Debug("Failed to index metadata during maintenance", "file", relPath, "error", err)
+			}
+		}
+		report.Added = append(report.Added, relPath)
+		slog.Info("Indexed file added to the library externally", "file", relPath)
+	}
+
+	// Files organized while the walk ran are indexed but weren't seen, so
+	// only entries whose file is really gone are dropped.
+	gone := func(relPath string) bool {
+		if onDisk[relPath] {
+			return false
+		}
+		_, err := os.Stat(filepath.Join(o.mediaPath, filepath.FromSlash(relPath)))
+		return os.IsNotExist(err)
+	}
+	for _, relPath := range o.checksums.Paths("") {
+		if gone(relPath) {
+			o.checksums.Delete(relPath)
+			o.metadata.forget(relPath)
+			report.Removed = append(report.Removed, relPath)
+			slog.Info("Dropped index entry for missing file", "file", relPath)
+		}
+	}
+	for _, relPath := range o.metadata.paths() {
+		if gone(relPath) {
+			o.metadata.forget(relPath)
+		}
+	}
+
+	if len(report.Added) > 0 || len(report.Removed) > 0 || assigned > 0 {
+		if err := o.checksums.Save(); err != nil {
+			return nil, err
+		}
+	}
+
+	changed := o.settleLibrarySize(mark, marked, bytes)
+	if changed || len(report.Added) > 0 || len(report.Removed) > 0 {
+		o.version.Add(1)
+	}
+	return report, nil
+}
+
+// Maintainer runs Reconcile in the background every Interval, as measured by
+// the organizer's clock.
+type Maintainer struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// StartMaintenance starts periodic reconciliation. It returns nil, which is
+// safe to Stop, when opts.Interval is zero.
+func (o *Organizer) StartMaintenance(opts MaintenanceOptions) *Maintainer {
+	if opts.Interval <= 0 {
+		return nil
+	}
+	poll := opts.PollInterval
+	if poll <= 0 {
+		poll = min(defaultMaintenancePoll, opts.Interval)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &Maintainer{cancel: cancel, done: make(chan struct{})}
+
+	go func() {
+		defer close(m.done)
+
+		ticker := time.NewTicker(poll)
+		defer ticker.Stop()
+
+		next := o.clock.Now().Add(opts.Interval)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if o.clock.Now().Before(next) {
+				continue
+			}
+
+			report, err := o.Reconcile(ctx, opts)
+			if err != nil {
+				if ctx.Err() == nil {
+					slog.Error("Index maintenance failed", "error", err)
+				}
+			} else if len(report.Added) > 0 || len(report.Removed) > 0 || report.Deferred > 0 {
+				slog.Info("Index maintenance reconciled library",
+					"added", len(report.Added), "removed", len(report.Removed), "deferred", report.Deferred)
+			}
+			next = o.clock.Now().Add(opts.Interval)
+		}
+	}()
+
+	return m
+}
+
+// Stop ends maintenance, abandoning a pass in progress, and waits for the
+// background goroutine to exit.
+func (m *Maintainer) Stop() {
+	if m == nil {
+		return
+	}
+	m.cancel()
+	<-m.done
+}
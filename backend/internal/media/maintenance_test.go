@@ -0,0 +1,145 @@
+package media
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Steven-harris/sortify/backend/internal/clock"
+)
+
+func TestReconcile(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+
+	kept := organizeTestFile(t, organizer, "IMG_20240315_143022.jpg", "kept")
+	deleted := organizeTestFile(t, organizer, "IMG_20240316_101500.jpg", "deleted")
+	if err := os.Remove(filepath.Join(mediaDir, deleted.RelativePath)); err != nil {
+		t.Fatalf("Failed to delete file: %v", err)
+	}
+
+	for _, name := range []string{"copied1.jpg", "copied2.jpg", "copied3.jpg"} {
+		if err := os.WriteFile(filepath.Join(mediaDir, "2024", "March", name), []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to copy in file: %v", err)
+		}
+	}
+
+	report, err := organizer.Reconcile(context.Background(), MaintenanceOptions{MaxFiles: 2})
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if len(report.Added) != 2 || report.Deferred != 1 {
+		t.Errorf("Expected 2 files added and 1 deferred, got %v and %d", report.Added, report.Deferred)
+	}
+	if len(report.Removed) != 1 || report.Removed[0] != filepath.ToSlash(deleted.RelativePath) {
+		t.Errorf("Expected %s removed, got %v", deleted.RelativePath, report.Removed)
+	}
+	if _, ok := organizer.checksums.Get(deleted.RelativePath); ok {
+		t.Error("Expected the deleted file's checksum to be dropped")
+	}
+	if _, ok := organizer.checksums.Get(kept.RelativePath); !ok {
+		t.Error("Expected the kept file's checksum to stay")
+	}
+
+	// The next pass picks up what the limit left
+	report, err = organizer.Reconcile(context.Background(), MaintenanceOptions{MaxFiles: 2})
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if len(report.Added) != 1 || report.Deferred != 0 || len(report.Removed) != 0 {
+		t.Errorf("Expected only the deferred file added, got %+v", report)
+	}
+
+	size, err := organizer.LibrarySize()
+	if err != nil {
+		t.Fatalf("LibrarySize failed: %v", err)
+	}
+	if size != report.Bytes || size != int64(len("kept")+3*len("copied1.jpg")) {
+		t.Errorf("Expected the library size to be re-measured, got %d", size)
+	}
+}
+
+func TestReconcileSkipsQuarantine(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+
+	quarantined := filepath.Join(mediaDir, QuarantineFolder, "2024", "March", "broken.jpg")
+	if err := os.MkdirAll(filepath.Dir(quarantined), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(quarantined, []byte("broken"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	report, err := organizer.Reconcile(context.Background(), MaintenanceOptions{})
+	if err != nil {
+		t.Fatalf("Reconcile failed: %v", err)
+	}
+	if len(report.Added) != 0 || report.Bytes != 0 {
+		t.Errorf("Expected the quarantine to be left out, got %+v", report)
+	}
+}
+
+func TestSettleLibrarySizeKeepsConcurrentChanges(t *testing.T) {
+	organizer := NewOrganizer(t.TempDir())
+	if _, err := organizer.LibrarySize(); err != nil {
+		t.Fatalf("LibrarySize failed: %v", err)
+	}
+
+	mark, marked := organizer.markLibrarySize()
+	organizer.addLibraryBytes(100) // Organized while the walk ran, after it passed the folder
+	organizer.settleLibrarySize(mark, marked, 40)
+
+	if size, _ := organizer.LibrarySize(); size != 140 {
+		t.Errorf("Expected 140 bytes, got %d", size)
+	}
+}
+
+func TestMaintenanceIndexesExternalFiles(t *testing.T) {
+	mediaDir := t.TempDir()
+	clk := clock.NewFake(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC))
+	organizer := NewOrganizerWithOptions(mediaDir, OrganizerOptions{Clock: clk})
+
+	maintainer := organizer.StartMaintenance(MaintenanceOptions{Interval: time.Hour, PollInterval: time.Millisecond})
+	defer maintainer.Stop()
+
+	relPath := filepath.Join("2024", "June", "copied.jpg")
+	if err := os.MkdirAll(filepath.Join(mediaDir, "2024", "June"), 0755); err != nil {
+		t.Fatalf("Failed to create folder: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(mediaDir, relPath), []byte("copied in"), 0644); err != nil {
+		t.Fatalf("Failed to copy in file: %v", err)
+	}
+
+	// Not due yet, however often the maintainer wakes
+	time.Sleep(20 * time.Millisecond)
+	if _, ok := organizer.checksums.Get(relPath); ok {
+		t.Fatal("Expected no maintenance before the interval elapsed")
+	}
+
+	clk.Advance(time.Hour)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, ok := organizer.checksums.Get(relPath); ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the external file to be indexed after a maintenance cycle")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	maintainer.Stop()
+	maintainer.Stop() // Stopping twice is harmless
+}
+
+func TestStartMaintenanceDisabled(t *testing.T) {
+	organizer := NewOrganizer(t.TempDir())
+	maintainer := organizer.StartMaintenance(MaintenanceOptions{})
+	if maintainer != nil {
+		t.Error("Expected no maintainer with a zero interval")
+	}
+	maintainer.Stop()
+}
@@ -0,0 +1,149 @@
+package media
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// DefaultMetadataCacheEntries bounds the metadata index when no limit is
+// configured; an entry is roughly a kilobyte.
+const DefaultMetadataCacheEntries = 100000
+
+// metadataIndex caches extracted metadata per library file so listings and
+// aggregate queries don't re-read EXIF for files that haven't changed. An
+// entry is only trusted while the file's size and modification time match.
+// It holds at most maxEntries files, forgetting the least recently used.
+type metadataIndex struct {
+	cache *lruCache[string, metadataEntry]
+}
+
+type metadataEntry struct {
+	size    int64
+	modTime time.Time
+	info    *MediaInfo
+	// placeholdersDone is set once info has its blurhash and dominant color,
+	// or when the file can never have a thumbnail to take them from, so hits
+	// stop looking for them on disk
+	placeholdersDone bool
+}
+
+func newMetadataIndex(maxEntries int) *metadataIndex {
+	return &metadataIndex{cache: newLRUCache[string, metadataEntry](maxEntries, 0, nil)}
+}
+
+func (m *metadataIndex) get(relPath string, fileInfo os.FileInfo) (*MediaInfo, bool) {
+	entry, ok := m.lookup(relPath, fileInfo)
+	return entry.info, ok
+}
+
+func (m *metadataIndex) lookup(relPath string, fileInfo os.FileInfo) (metadataEntry, bool) {
+	return m.cache.getValid(filepath.ToSlash(relPath), func(entry metadataEntry) bool {
+		return entry.size == fileInfo.Size() && entry.modTime.Equal(fileInfo.ModTime())
+	})
+}
+
+func (m *metadataIndex) put(relPath string, fileInfo os.FileInfo, info *MediaInfo, placeholdersDone bool) {
+	m.cache.add(filepath.ToSlash(relPath), metadataEntry{
+		size:             fileInfo.Size(),
+		modTime:          fileInfo.ModTime(),
+		info:             info,
+		placeholdersDone: placeholdersDone,
+	})
+}
+
+func (m *metadataIndex) forget(relPath string) {
+	m.cache.remove(filepath.ToSlash(relPath))
+}
+
+// paths returns the library-relative paths with an entry, in no order.
+func (m *metadataIndex) paths() []string {
+	return m.cache.keys()
+}
+
+func (m *metadataIndex) reset() {
+	m.cache.purge()
+}
+
+// MetadataCacheStats reports the metadata index's size and hit rate.
+func (o *Organizer) MetadataCacheStats() CacheStats {
+	return o.metadata.cache.stats()
+}
+
+// metadataFor returns the metadata for a library file, extracting and
+// indexing it on a miss. Callers must treat the result as read-only.
+func (o *Organizer) metadataFor(path, relPath string, fileInfo os.FileInfo) (*MediaInfo, error) {
+	if entry, ok := o.metadata.lookup(relPath, fileInfo); ok {
+		info := entry.info
+		if entry.placeholdersDone {
+			return info, nil
+		}
+		placeholders := o.missingPlaceholders(path, info)
+		if len(placeholders) == 0 {
+			return info, nil
+		}
+
+		// The thumbnail may have been generated since the entry was indexed.
+		// Entries are shared, so the placeholders go on a copy.
+		updated := *info
+		updated.ExtraMetadata = make(map[string]string, len(info.ExtraMetadata)+len(placeholders))
+		for key, value := range info.ExtraMetadata {
+			updated.ExtraMetadata[key] = value
+		}
+		for key, value := range placeholders {
+			updated.ExtraMetadata[key] = value
+		}
+		o.metadata.put(relPath, fileInfo, &updated, o.placeholdersDone(path, &updated))
+		return &updated, nil
+	}
+
+	info, err := o.extractor.ExtractMetadata(path)
+	if err != nil {
+		return nil, err
+	}
+	o.applyUserDate(relPath, info)
+
+	for key, value := range o.missingPlaceholders(path, info) {
+		info.ExtraMetadata[key] = value
+	}
+
+	o.metadata.put(relPath, fileInfo, info, o.placeholdersDone(path, info))
+	return info, nil
+}
+
+// applyUserDate dates info by the capture date a user supplied for relPath,
+// if any, as that outranks anything extracted from the file.
+func (o *Organizer) applyUserDate(relPath string, info *MediaInfo) {
+	if date, ok := o.checksums.UserDate(relPath); ok {
+		info.DateTaken = &date
+		info.DateSource = DateSourceUserInput
+	}
+}
+
+// placeholdersDone reports whether info needs no more looking for
+// placeholders: it has both, or the file will never have a thumbnail.
+func (o *Organizer) placeholdersDone(path string, info *MediaInfo) bool {
+	if o.thumbnailer == nil || !o.thumbnailer.Supports(path) {
+		return true
+	}
+	return info.ExtraMetadata["blurhash"] != "" && info.ExtraMetadata["dominantColor"] != ""
+}
+
+// missingPlaceholders returns the blurhash and dominant color stored with
+// the file's thumbnail that info doesn't have yet, keyed as in
+// ExtraMetadata.
+func (o *Organizer) missingPlaceholders(path string, info *MediaInfo) map[string]string {
+	if o.placeholdersDone(path, info) {
+		return nil
+	}
+
+	hash, dominantColor := o.thumbnailer.Placeholders(path)
+	placeholders := make(map[string]string, 2)
+	if hash != "" && info.ExtraMetadata["blurhash"] == "" {
+		placeholders["blurhash"] = hash
+	}
+	if dominantColor != "" && info.ExtraMetadata["dominantColor"] == "" {
+		placeholders["dominantColor"] = dominantColor
+	}
+	return placeholders
+}
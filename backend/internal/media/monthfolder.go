@@ -0,0 +1,128 @@
+package media
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MonthFormat selects how month folders under each year are named.
+type MonthFormat string
+
+const (
+	// MonthFormatName uses the English month name, e.g. "2024/March".
+	MonthFormatName MonthFormat = "name"
+	// MonthFormatNumber uses the zero-padded month number, e.g. "2024/03".
+	MonthFormatNumber MonthFormat = "number"
+	// MonthFormatNumberName sorts like a number and reads like a name,
+	// e.g. "2024/03-March".
+	MonthFormatNumberName MonthFormat = "number-name"
+
+	// DefaultMonthFormat is used when no format, or an unknown one, is
+	// configured.
+	DefaultMonthFormat = MonthFormatName
+)
+
+// monthFormats lists every supported format. Libraries keep the folders made
+// under earlier settings, so reading the library accepts them all.
+var monthFormats = []MonthFormat{MonthFormatName, MonthFormatNumber, MonthFormatNumberName}
+
+// FolderName returns the folder name for month.
+func (f MonthFormat) FolderName(month time.Month) string {
+	switch f {
+	case MonthFormatNumber:
+		return fmt.Sprintf("%02d", int(month))
+	case MonthFormatNumberName:
+		return fmt.Sprintf("%02d-%s", int(month), month)
+	default:
+		return month.String()
+	}
+}
+
+// ParseFolder reports which month a folder name created under f stands for.
+func (f MonthFormat) ParseFolder(name string) (time.Month, bool) {
+	switch f {
+	case MonthFormatNumber:
+		return parseMonthNumber(name)
+	case MonthFormatNumberName:
+		number, monthName, ok := strings.Cut(name, "-")
+		if !ok {
+			return 0, false
+		}
+		month, ok := parseMonthNumber(number)
+		if !ok || monthName != month.String() {
+			return 0, false
+		}
+		return month, true
+	default:
+		return parseMonthName(name)
+	}
+}
+
+// ParseMonthFolder parses a library-relative year and month folder pair,
+// returning the first instant of that month in UTC.
+func (f MonthFormat) ParseMonthFolder(year, month string) (time.Time, bool) {
+	y, ok := parseYearFolder(year)
+	if !ok {
+		return time.Time{}, false
+	}
+	m, ok := f.ParseFolder(month)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC), true
+}
+
+// ParseAnyMonthFolder is ParseMonthFolder accepting month folders made under
+// any supported format, as a library whose MONTH_FORMAT changed holds both.
+func ParseAnyMonthFolder(year, month string) (time.Time, bool) {
+	for _, format := range monthFormats {
+		if date, ok := format.ParseMonthFolder(year, month); ok {
+			return date, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// ParseMonth accepts a month given in any supported form ("March", "03",
+// "3" or "03-March"), as clients browsing the library may not know the
+// configured format.
+func ParseMonth(value string) (time.Month, bool) {
+	for _, format := range monthFormats {
+		if month, ok := format.ParseFolder(value); ok {
+			return month, true
+		}
+	}
+	return 0, false
+}
+
+func parseYearFolder(name string) (int, bool) {
+	if len(name) != 4 || strings.Trim(name, "0123456789") != "" {
+		return 0, false
+	}
+	year, err := strconv.Atoi(name)
+	if err != nil || year < 1 {
+		return 0, false
+	}
+	return year, true
+}
+
+func parseMonthNumber(value string) (time.Month, bool) {
+	if len(value) == 0 || len(value) > 2 || strings.Trim(value, "0123456789") != "" {
+		return 0, false
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < 1 || number > 12 {
+		return 0, false
+	}
+	return time.Month(number), true
+}
+
+func parseMonthName(value string) (time.Month, bool) {
+	parsed, err := time.Parse("January", value)
+	if err != nil {
+		return 0, false
+	}
+	return parsed.Month(), true
+}
@@ -0,0 +1,77 @@
+package media
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMonthFormatFolderName(t *testing.T) {
+	tests := []struct {
+		format   MonthFormat
+		expected string
+	}{
+		{MonthFormatName, "March"},
+		{MonthFormatNumber, "03"},
+		{MonthFormatNumberName, "03-March"},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.format), func(t *testing.T) {
+			name := test.format.FolderName(time.March)
+			if name != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, name)
+			}
+
+			month, ok := test.format.ParseFolder(name)
+			if !ok || month != time.March {
+				t.Errorf("Expected %q to parse back to March, got %v (ok=%v)", name, month, ok)
+			}
+		})
+	}
+}
+
+func TestMonthFormatParseMonthFolder(t *testing.T) {
+	tests := []struct {
+		format MonthFormat
+		year   string
+		month  string
+		valid  bool
+	}{
+		{MonthFormatName, "2024", "March", true},
+		{MonthFormatName, "2024", "03", false},
+		{MonthFormatNumber, "2024", "03", true},
+		{MonthFormatNumber, "2024", "13", false},
+		{MonthFormatNumber, "2024", "+3", false},
+		{MonthFormatNumber, "2024", "March", false},
+		{MonthFormatNumberName, "2024", "03-March", true},
+		{MonthFormatNumberName, "2024", "03-April", false},
+		{MonthFormatName, "24", "March", false},
+		{MonthFormatName, "Quarantine", "March", false},
+		{MonthFormatNumber, "2024", "2024-03-15", false},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.format)+"/"+test.year+"/"+test.month, func(t *testing.T) {
+			date, ok := test.format.ParseMonthFolder(test.year, test.month)
+			if ok != test.valid {
+				t.Fatalf("Expected valid=%v, got %v", test.valid, ok)
+			}
+			if ok && !date.Equal(time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)) {
+				t.Errorf("Expected 2024-03-01, got %v", date)
+			}
+		})
+	}
+}
+
+func TestParseMonth(t *testing.T) {
+	for _, value := range []string{"March", "03", "3", "03-March"} {
+		if month, ok := ParseMonth(value); !ok || month != time.March {
+			t.Errorf("Expected %q to parse as March, got %v (ok=%v)", value, month, ok)
+		}
+	}
+	for _, value := range []string{"", "Marzo", "00", "2024-03-15"} {
+		if _, ok := ParseMonth(value); ok {
+			t.Errorf("Expected %q not to parse as a month", value)
+		}
+	}
+}
@@ -1,112 +1,712 @@
 package media
 
 import (
+	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"io/fs"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/Steven-harris/sortify/backend/internal/clock"
 )
 
+var ErrInvalidPath = errors.New("invalid library path")
+
+// ErrDateRequired is returned when OrganizeOptions.RequireDate is set and a
+// file could only be dated by its modification time, if at all.
+var ErrDateRequired = errors.New("no capture date could be determined")
+
 type Organizer struct {
 	mediaPath string
 	extractor *Extractor
+	version   atomic.Uint64 // Bumped whenever the library contents change
+	clock     clock.Clock
+	checksums *ChecksumIndex
+	metadata  *metadataIndex
+	dedupMode DedupMode
+	preHash   int64
+	newHash   func() hash.Hash // Hash behind dedup and the checksum index
+
+	preferRicherMetadata bool
+	includeHidden        bool
+	separateScreenshots  bool
+
+	futureDates    FutureDatePolicy
+	monthFormat    MonthFormat
+	extensionStyle ExtensionStyle
+	fileIDs        FileIDMode
+
+	maxNameLength int // Longest stored file name, counted in nameUnit
+	nameUnit      FileNameUnit
+
+	quarantineAfter int // Zero disables quarantining undecodable files
+	failures        extractionFailures
+
+	trashRetention time.Duration // How long soft-deleted files are kept; zero deletes outright
+
+	archivePath string // Empty disables archiving of originals
+
+	thumbnailer *Thumbnailer // Source of blurhash placeholders; may be nil
+
+	onOrganized func(OrganizedFile) // May be nil
+
+	statsMutex  sync.Mutex
+	sizeKnown   bool
+	librarySize int64
+
+	structureMutex   sync.Mutex
+	structure        map[string]any // Cached GetDirectoryStructure result
+	structureVersion uint64
+}
+
+// OrganizerOptions configures optional Organizer behaviour.
+type OrganizerOptions struct {
+	ChecksumIndexPath    string        // Where file checksums persist; empty keeps them in memory
+	ChecksumSaveDelay    time.Duration // Coalesces index writes after organizing into one per delay; zero writes each time
+	DedupMode            DedupMode
+	HashAlgorithm        HashAlgorithm  // Hash for dedup and the checksum index; empty means SHA-256
+	PreHashBytes         int64          // Bytes hashed from each end of a file in DedupFast mode
+	PreferRicherMetadata bool           // JPEGs differing only in metadata are duplicates; the one with more EXIF is kept
+	Location             *time.Location // Zone dates are bucketed in; nil means UTC
+	ArchivePath          string         // Also keep each original under ARCHIVE_PATH/YYYY/MM-DD by upload date
+	Thumbnailer          *Thumbnailer   // Lets listings include blurhash placeholders
+	FutureDates          FutureDatePolicy
+	MonthFormat          MonthFormat
+	ExtensionStyle       ExtensionStyle
+	FileIDs              FileIDMode
+	MaxFileNameLength    int                 // Longest stored file name including extension; zero means 200
+	FileNameUnit         FileNameUnit        // What MaxFileNameLength counts; empty means bytes
+	QuarantineAfter      int                 // Failed QuarantineCorrupt passes before a corrupt JPEG moves to Quarantine/; zero disables
+	Clock                clock.Clock         // Nil uses the system clock
+	IncludeHidden        bool                // Treat dotfiles and OS junk such as ._AppleDouble forks as media
+	SeparateScreenshots  bool                // File screenshots and screen recordings under Screenshots/ instead of the date folders
+	KeepNullIslandGPS    bool                // Keep EXIF GPS of exactly 0,0 rather than treating it as no fix
+	DocumentExtensions   []string            // Extensions, e.g. ".pdf", scanned, browsed and dated as documents; empty disables them
+	FilenamePatterns     []string            // Extra filename date regexes capturing year, month, day[, hour, minute, second], tried before the built-in ones
+	FFprobePath          string              // ffprobe binary video metadata is read with; empty means "ffprobe" on PATH
+	TrashRetention       time.Duration       // How long TrashFile keeps files restorable; zero disables the trash
+	MetadataCacheEntries int                 // Files whose metadata stays cached, least recently used dropped first; zero means DefaultMetadataCacheEntries
+	OnOrganized          func(OrganizedFile) // Called for each file filed into the library, e.g. to raise a webhook
+}
+
+// DedupMode selects how the duplicate check compares an incoming file with the
+// files already in its target directory.
+type DedupMode string
+
+const (
+	// DedupFull hashes every candidate file completely.
+	DedupFull DedupMode = "full"
+	// DedupFast compares size and a hash of the first and last PreHashBytes,
+	// confirming with a full hash only when those match.
+	DedupFast DedupMode = "fast"
+)
+
+const defaultPreHashBytes = 64 * 1024
+
+// FutureDatePolicy selects what happens to files dated after the present,
+// which almost always means the camera clock was set wrong.
+type FutureDatePolicy string
+
+const (
+	// FutureDatesReset files dates more than a year ahead under the current
+	// time and accepts anything nearer as-is.
+	FutureDatesReset FutureDatePolicy = "reset"
+	// FutureDatesFlag files anything dated past futureDateGrace into
+	// ClockErrorFolder, recording the claimed date so it can be fixed.
+	FutureDatesFlag FutureDatePolicy = "flag"
+)
+
+// ExtensionStyle selects how the extension of an organized file is written.
+type ExtensionStyle string
+
+const (
+	// ExtensionsKeep stores the extension as uploaded.
+	ExtensionsKeep ExtensionStyle = "keep"
+	// ExtensionsLower lowercases the extension, so "PHOTO.JPG" is stored as
+	// "PHOTO.jpg".
+	ExtensionsLower ExtensionStyle = "lower"
+	// ExtensionsCanonical lowercases the extension and replaces alternate
+	// spellings with the usual one, so "photo.jpeg" is stored as "photo.jpg".
+	ExtensionsCanonical ExtensionStyle = "canonical"
+)
+
+// canonicalExts maps alternate extension spellings to the one
+// ExtensionsCanonical stores.
+var canonicalExts = map[string]string{
+	".jpeg": ".jpg",
 }
 
+// FileNameUnit selects what a file name's length is counted in, following
+// the filesystem the library lives on.
+type FileNameUnit string
+
+const (
+	// FileNameBytes counts UTF-8 bytes, as ext4, XFS, ZFS and APFS do.
+	FileNameBytes FileNameUnit = "bytes"
+	// FileNameUTF16 counts UTF-16 code units, as NTFS and exFAT do.
+	FileNameUTF16 FileNameUnit = "utf16"
+)
+
+// defaultMaxFileNameLength stays under the common 255 limit, leaving room for
+// the "(1)" counter claimFinalPath adds to clashing names.
+const defaultMaxFileNameLength = 200
+
+// ClockErrorFolder holds files whose claimed date is in the future.
+const ClockErrorFolder = "ClockError"
+
+// futureDateGrace absorbs timezone differences between camera and server so
+// today's photos taken "ahead" of the server clock aren't flagged.
+const futureDateGrace = 24 * time.Hour
+
 func NewOrganizer(mediaPath string) *Organizer {
+	return NewOrganizerWithOptions(mediaPath, OrganizerOptions{})
+}
+
+func NewOrganizerWithOptions(mediaPath string, options OrganizerOptions) *Organizer {
+	hashAlgorithm := options.HashAlgorithm
+	newHash, ok := hashAlgorithms[hashAlgorithm]
+	if !ok {
+		if hashAlgorithm != "" {
+			slog.Warn("Unknown hash algorithm, using sha256", "algorithm", hashAlgorithm)
+		}
+		hashAlgorithm, newHash = HashSHA256, sha256.New
+	}
+
+	checksums, err := LoadChecksumIndex(options.ChecksumIndexPath, hashAlgorithm)
+	if err != nil {
+		slog.Error("Failed to load checksum index, starting empty", "error", err, "path", options.ChecksumIndexPath)
+	}
+	checksums.saveDelay = options.ChecksumSaveDelay
+
+	dedupMode := options.DedupMode
+	if dedupMode != DedupFull && dedupMode != DedupFast {
+		if dedupMode != "" {
+			slog.Warn("Unknown dedup mode, using full hashing", "mode", dedupMode)
+		}
+		dedupMode = DedupFull
+	}
+
+	futureDates := options.FutureDates
+	if futureDates != FutureDatesReset && futureDates != FutureDatesFlag {
+		if futureDates != "" {
+			slog.Warn("Unknown future date policy, resetting future dates", "policy", futureDates)
+		}
+		futureDates = FutureDatesReset
+	}
+
+	monthFormat := options.MonthFormat
+	if monthFormat != MonthFormatName && monthFormat != MonthFormatNumber && monthFormat != MonthFormatNumberName {
+		if monthFormat != "" {
+			slog.Warn("Unknown month format, using the default", "format", monthFormat, "default", DefaultMonthFormat)
+		}
+		monthFormat = DefaultMonthFormat
+	}
+
+	extensionStyle := options.ExtensionStyle
+	if extensionStyle != ExtensionsKeep && extensionStyle != ExtensionsLower && extensionStyle != ExtensionsCanonical {
+		if extensionStyle != "" {
+			slog.Warn("Unknown extension style, keeping extensions as uploaded", "style", extensionStyle)
+		}
+		extensionStyle = ExtensionsKeep
+	}
+
+	fileIDs := options.FileIDs
+	if fileIDs != FileIDPath && fileIDs != FileIDContent {
+		if fileIDs != "" {
+			slog.Warn("Unknown file ID mode, deriving IDs from paths", "mode", fileIDs)
+		}
+		fileIDs = FileIDPath
+	}
+
+	nameUnit := options.FileNameUnit
+	if nameUnit != FileNameBytes && nameUnit != FileNameUTF16 {
+		if nameUnit != "" {
+			slog.Warn("Unknown file name unit, counting bytes", "unit", nameUnit)
+		}
+		nameUnit = FileNameBytes
+	}
+
+	maxNameLength := options.MaxFileNameLength
+	if maxNameLength <= 0 {
+		maxNameLength = defaultMaxFileNameLength
+	}
+
+	preHash := options.PreHashBytes
+	if preHash <= 0 {
+		preHash = defaultPreHashBytes
+	}
+
+	metadataEntries := options.MetadataCacheEntries
+	if metadataEntries <= 0 {
+		metadataEntries = DefaultMetadataCacheEntries
+	}
+
+	clk := options.Clock
+	if clk == nil {
+		clk = clock.System
+	}
+
+	extractor := NewExtractorInLocation(options.Location)
+	extractor.keepNullIsland = options.KeepNullIslandGPS
+	extractor.documentExts = normalizeExtensions(options.DocumentExtensions)
+	extractor.addCustomFilenamePatterns(options.FilenamePatterns)
+	if options.FFprobePath != "" {
+		extractor.ffprobePath = options.FFprobePath
+	}
+
 	return &Organizer{
 		mediaPath: mediaPath,
-		extractor: NewExtractor(),
+		clock:     clk,
+		extractor: extractor,
+		checksums: checksums,
+		metadata:  newMetadataIndex(metadataEntries),
+		dedupMode: dedupMode,
+		preHash:   preHash,
+		newHash:   newHash,
+
+		preferRicherMetadata: options.PreferRicherMetadata,
+		includeHidden:        options.IncludeHidden,
+		separateScreenshots:  options.SeparateScreenshots,
+
+		futureDates:    futureDates,
+		monthFormat:    monthFormat,
+		extensionStyle: extensionStyle,
+		fileIDs:        fileIDs,
+		maxNameLength:  maxNameLength,
+		nameUnit:       nameUnit,
+
+		quarantineAfter: options.QuarantineAfter,
+		trashRetention:  options.TrashRetention,
+
+		archivePath: options.ArchivePath,
+		thumbnailer: options.Thumbnailer,
+		onOrganized: options.OnOrganized,
 	}
 }
 
+// Extractor returns the organizer's metadata extractor, configured with the
+// same zone used for bucketing.
+func (o *Organizer) Extractor() *Extractor {
+	return o.extractor
+}
+
+// DocumentsEnabled reports whether DocumentExtensions names any extensions,
+// so files can be organized as documents.
+func (o *Organizer) DocumentsEnabled() bool {
+	return len(o.extractor.documentExts) > 0
+}
+
 func (o *Organizer) OrganizeFile(tempFilePath, originalFileName string) (*MediaInfo, error) {
-	info, err := o.extractor.ExtractMetadata(tempFilePath)
+	return o.OrganizeFileContext(context.Background(), tempFilePath, originalFileName)
+}
+
+// OrganizeOptions carries per-file overrides for an organize call.
+type OrganizeOptions struct {
+	MediaType MediaType  // Overrides the extension-sniffed media type when set
+	TargetDir string     // Library-relative folder overriding the date-based one
+	Subfolder string     // Relative path appended to the chosen folder, e.g. a preserved album name
+	DateTaken *time.Time // Capture date the client vouches for; replaces EXIF and filename dating
+	SessionID string     // Upload session the file came from, passed on to OnOrganized
+
+	// RequireDate refuses files without an EXIF, filename, sidecar or
+	// client-supplied date with ErrDateRequired, leaving them in place
+	RequireDate bool
+}
+
+// OrganizedFile is what OnOrganized hears about a file filed into the
+// library, whether uploaded, imported, or moved by a refresh or reorganize.
+type OrganizedFile struct {
+	SessionID    string     `json:"sessionId,omitempty"`
+	FileName     string     `json:"fileName,omitempty"`
+	RelativePath string     `json:"relativePath,omitempty"` // Empty when the library already held the file
+	From         string     `json:"from,omitempty"`         // Library path of a file that was moved
+	Duplicate    bool       `json:"duplicate,omitempty"`
+	MediaInfo    *MediaInfo `json:"mediaInfo,omitempty"` // Nil for a moved file
+}
+
+// notifyOrganized passes file to the OnOrganized hook, if any.
+func (o *Organizer) notifyOrganized(file OrganizedFile) {
+	if o.onOrganized != nil {
+		o.onOrganized(file)
+	}
+}
+
+// OrganizeFileContext organizes the file like OrganizeFile but aborts when ctx is
+// done. Cancellation is only honoured before the file is moved, so an aborted
+// organize always leaves the temp file in place for a retry.
+func (o *Organizer) OrganizeFileContext(ctx context.Context, tempFilePath, originalFileName string) (*MediaInfo, error) {
+	return o.OrganizeFileWithOptions(ctx, tempFilePath, originalFileName, OrganizeOptions{})
+}
+
+// placement is where organizing a file would put it, decided before anything
+// on disk changes.
+type placement struct {
+	info       *MediaInfo
+	hash       string
+	targetDir  string
+	duplicate  bool   // Already stored; there is nothing to organize
+	supersedes string // Stored copy of the same image with poorer metadata
+	imageHash  string // Hash of a JPEG's image data, with PreferRicherMetadata
+}
+
+// place dates the file and picks its target folder, then checks whether the
+// library already holds it. It only reads the file and the library.
+func (o *Organizer) place(ctx context.Context, filePath, originalFileName string, opts OrganizeOptions) (*placement, error) {
+	mediaType := opts.MediaType
+	if mediaType == "" && o.extractor.isDocument(originalFileName) {
+		// Upload temp files lack the extension that marks a document
+		mediaType = MediaTypeDocument
+	}
+
+	info, err := o.extractor.ExtractMetadataAs(filePath, mediaType)
 	if err != nil {
 		return nil, fmt.Errorf("failed to extract metadata: %w", err)
 	}
 
 	info.FileName = originalFileName
+	if info.ScreenCapture == "" {
+		info.ScreenCapture = screenCaptureByName(originalFileName)
+	}
 
-	tempFileName := filepath.Base(tempFilePath)
-	if info.DateSource == "filename" && tempFileName != originalFileName {
+	tempFileName := filepath.Base(filePath)
+	if opts.DateTaken != nil {
+		dateTaken := *opts.DateTaken
+		info.DateTaken = &dateTaken
+		info.DateSource = DateSourceUserInput
+	} else if info.DateSource == DateSourceFileName && tempFileName != originalFileName {
 		info.DateTaken = nil
-		info.DateSource = ""
+		info.DateSource = DateSourceUnknown
 
 		o.extractor.ExtractDateFromFilename(originalFileName, info)
 
 		if info.DateTaken == nil {
-			if fileInfo, err := os.Stat(tempFilePath); err == nil {
+			if fileInfo, err := os.Stat(filePath); err == nil {
 				if fileInfo.ModTime().Year() > 1970 { // Reasonable date check
-					info.DateTaken = &[]time.Time{fileInfo.ModTime()}[0]
-					info.DateSource = "file_time"
+					info.DateTaken = &[]time.Time{o.extractor.fileTime(fileInfo)}[0]
+					info.DateSource = DateSourceFileTime
 				}
 			}
 		}
+	} else if tempFileName != originalFileName && o.extractor.NeedsUserInput(info) {
+		// An upload's temp file is named after its session, so only the
+		// original name can date it
+		o.extractor.ExtractDateFromFilename(originalFileName, info)
+	}
+	if opts.RequireDate && o.extractor.NeedsUserInput(info) {
+		return nil, fmt.Errorf("%w for %s", ErrDateRequired, originalFileName)
+	}
+
+	hash, err := o.calculateFileHash(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash file: %w", err)
+	}
+
+	var targetDir string
+	if opts.TargetDir != "" {
+		targetDir, err = o.ResolvePath(opts.TargetDir)
+	} else if o.isClockError(info.DateTaken) {
+		slog.Warn("File dated in the future, filing under clock errors",
+			"file", originalFileName,
+			"claimedDate", info.DateTaken,
+		)
+		info.ExtraMetadata["claimedDate"] = info.DateTaken.Format(time.RFC3339)
+		targetDir = filepath.Join(o.mediaPath, ClockErrorFolder)
+	} else if o.separateScreenshots && info.ScreenCapture != "" {
+		targetDir, err = o.screenshotDirectory(info.DateTaken)
+	} else {
+		targetDir, err = o.getTargetDirectory(info.DateTaken)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to determine target directory: %w", err)
+	}
+	if opts.Subfolder != "" {
+		if !filepath.IsLocal(opts.Subfolder) {
+			return nil, fmt.Errorf("subfolder %q escapes its target directory", opts.Subfolder)
+		}
+		targetDir = filepath.Join(targetDir, opts.Subfolder)
 	}
 
-	if duplicate, err := o.checkDuplicate(tempFilePath, info); err != nil {
+	p := &placement{info: info, hash: hash, targetDir: targetDir}
+
+	if duplicate, err := o.findDuplicate(ctx, filePath, hash, targetDir); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, fmt.Errorf("organize aborted: %w", ctxErr)
+		}
 		slog.Error("Failed to check for duplicates", "error", err, "file", originalFileName)
 	} else if duplicate {
-		slog.Info("Duplicate file detected, skipping", "file", originalFileName)
-		os.Remove(tempFilePath) // Clean up temp file
-		return info, nil
+		slog.Debug("Duplicate file detected", "file", originalFileName)
+		p.duplicate = true
+		return p, nil
 	}
 
-	targetDir, err := o.getTargetDirectory(info.DateTaken)
+	if o.preferRicherMetadata {
+		existing, imageHash, err := o.findContentDuplicate(filePath, targetDir)
+		p.imageHash = imageHash
+		if err != nil {
+			slog.Error("Failed to check for content duplicates", "error", err, "file", originalFileName)
+		} else if existing != "" {
+			if !o.richerThan(info, existing) {
+				slog.Debug("Duplicate image without richer metadata", "file", originalFileName, "existing", existing)
+				p.duplicate = true
+				return p, nil
+			}
+			p.supersedes = existing
+		}
+	}
+
+	return p, nil
+}
+
+func (o *Organizer) OrganizeFileWithOptions(ctx context.Context, tempFilePath, originalFileName string, opts OrganizeOptions) (*MediaInfo, error) {
+	p, err := o.place(ctx, tempFilePath, originalFileName, opts)
 	if err != nil {
-		return nil, fmt.Errorf("failed to determine target directory: %w", err)
+		return nil, err
+	}
+	info, targetDir, supersedes := p.info, p.targetDir, p.supersedes
+
+	if p.duplicate {
+		slog.Info("Duplicate file detected, skipping", "file", originalFileName)
+		os.Remove(tempFilePath) // Clean up temp file
+		o.notifyOrganized(OrganizedFile{SessionID: opts.SessionID, FileName: originalFileName, Duplicate: true, MediaInfo: info})
+		return info, nil
 	}
 
 	if err := os.MkdirAll(targetDir, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create target directory: %w", err)
 	}
 
-	sanitizedFilename := o.sanitizeFileName(originalFileName)
-	finalPath := filepath.Join(targetDir, sanitizedFilename)
-	finalPath = o.handleDuplicates(finalPath)
+	if err := ctx.Err(); err != nil {
+		o.pruneEmptyDirs(targetDir) // Don't leave a folder this organize created
+		return nil, fmt.Errorf("organize aborted: %w", err)
+	}
+
+	sanitizedFilename := o.normalizeExtension(o.sanitizeFileName(originalFileName))
+	if sanitizedFilename != originalFileName {
+		info.ExtraMetadata["originalFilename"] = originalFileName
+	}
+	finalPath, err := o.claimFinalPath(filepath.Join(targetDir, sanitizedFilename))
+	if err != nil {
+		o.pruneEmptyDirs(targetDir)
+		return nil, err
+	}
 
 	if err := o.moveFile(tempFilePath, finalPath); err != nil {
+		os.Remove(finalPath) // Release the claimed name
+		o.pruneEmptyDirs(targetDir)
 		return nil, fmt.Errorf("failed to move file: %w", err)
 	}
 
+	if supersedes != "" {
+		finalPath = o.replaceStored(finalPath, supersedes)
+	}
+
+	if relPath, err := filepath.Rel(o.mediaPath, finalPath); err == nil {
+		info.RelativePath = relPath
+
+		o.checksums.Set(relPath, p.hash)
+		if p.imageHash != "" {
+			o.checksums.SetImageHash(relPath, p.imageHash)
+		}
+		if info.DateSource == DateSourceUserInput && info.DateTaken != nil {
+			o.checksums.SetUserDate(relPath, *info.DateTaken)
+		}
+		if _, err := o.assignFileID(ctx, relPath, p.hash, 0); err != nil {
+			slog.Warn("Failed to assign file ID", "error", err, "file", relPath)
+		}
+		o.checksums.SaveSoon()
+	}
+
+	if o.archivePath != "" {
+		// The file is already safely in the library, so a failed archive is
+		// reported but doesn't fail the upload.
+		if archivePath, err := o.archiveOriginal(finalPath, originalFileName, o.clock.Now()); err != nil {
+			slog.Error("Failed to archive original", "error", err, "file", originalFileName)
+		} else {
+			slog.Debug("Original archived", "file", originalFileName, "archivePath", archivePath)
+		}
+	}
+
+	o.addLibraryBytes(info.FileSize)
+
+	o.version.Add(1)
+
 	slog.Info("File organized successfully",
 		"originalFile", originalFileName,
 		"finalPath", finalPath,
 		"dateTaken", info.DateTaken,
 		"dateSource", info.DateSource,
 	)
+	o.notifyOrganized(OrganizedFile{
+		SessionID:    opts.SessionID,
+		FileName:     originalFileName,
+		RelativePath: filepath.ToSlash(info.RelativePath),
+		MediaInfo:    info,
+	})
 
 	return info, nil
 }
 
-func (o *Organizer) handleDuplicates(targetPath string) string {
-	if _, err := os.Stat(targetPath); os.IsNotExist(err) {
-		return targetPath
+// ResolvePath turns a library-relative path from a client into an absolute path,
+// rejecting anything that would escape the media directory.
+func (o *Organizer) ResolvePath(relPath string) (string, error) {
+	if relPath == "" {
+		return "", fmt.Errorf("%w: path is required", ErrInvalidPath)
+	}
+
+	fullPath, err := ResolveWithin(o.mediaPath, relPath)
+	if err != nil {
+		return "", err
+	}
+	if fullPath == filepath.Clean(o.mediaPath) {
+		return "", fmt.Errorf("%w: %q is outside the media library", ErrInvalidPath, relPath)
+	}
+
+	return fullPath, nil
+}
+
+// ResolveWithin joins a client-supplied relative path onto root, clamping ".."
+// segments so the result can never leave root. An empty path yields root.
+func ResolveWithin(root, relPath string) (string, error) {
+	cleaned := filepath.Clean("/" + filepath.ToSlash(relPath))
+	fullPath := filepath.Join(root, filepath.FromSlash(cleaned))
+
+	rel, err := filepath.Rel(root, fullPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("%w: %q is outside %s", ErrInvalidPath, relPath, root)
 	}
 
-	dir := filepath.Dir(targetPath)
-	filename := filepath.Base(targetPath)
-	ext := filepath.Ext(filename)
-	nameWithoutExt := strings.TrimSuffix(filename, ext)
+	return fullPath, nil
+}
 
-	counter := 1
-	for {
-		newFilename := fmt.Sprintf("%s(%d)%s", nameWithoutExt, counter, ext)
-		newPath := filepath.Join(dir, newFilename)
+// MediaPath returns the library root.
+func (o *Organizer) MediaPath() string {
+	return o.mediaPath
+}
 
-		if _, err := os.Stat(newPath); os.IsNotExist(err) {
-			return newPath
+// Location returns the zone dates are bucketed in, for reading dates given
+// without one the way the library files them.
+func (o *Organizer) Location() *time.Location {
+	return o.extractor.location
+}
+
+// Version returns a counter that changes whenever files are added to or removed
+// from the library, suitable for cache validation.
+func (o *Organizer) Version() uint64 {
+	return o.version.Load()
+}
+
+// LibrarySize returns the total bytes stored in the library, excluding in-flight
+// uploads. The first call walks the library; afterwards the total is kept up to
+// date as files are organized so quota checks stay cheap.
+func (o *Organizer) LibrarySize() (int64, error) {
+	o.statsMutex.Lock()
+	defer o.statsMutex.Unlock()
+
+	if o.sizeKnown {
+		return o.librarySize, nil
+	}
+
+	var total int64
+	err := filepath.Walk(o.mediaPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() && o.skipDir(path, o.mediaPath) {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() {
+			total += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to measure library size: %w", err)
+	}
+
+	o.librarySize = total
+	o.sizeKnown = true
+	return total, nil
+}
+
+// addLibraryBytes adjusts the cached library size; before the first walk there
+// is nothing to adjust because the walk will see the change itself.
+func (o *Organizer) addLibraryBytes(delta int64) {
+	o.statsMutex.Lock()
+	defer o.statsMutex.Unlock()
+
+	if o.sizeKnown {
+		o.librarySize += delta
+	}
+}
+
+// markLibrarySize snapshots the cached library size before a walk that
+// measures it again; known is false before the first measurement.
+func (o *Organizer) markLibrarySize() (size int64, known bool) {
+	o.statsMutex.Lock()
+	defer o.statsMutex.Unlock()
+	return o.librarySize, o.sizeKnown
+}
+
+// settleLibrarySize applies the size a walk measured as a delta from the mark
+// taken when it started, so addLibraryBytes calls made while it ran are kept.
+// It reports whether the cached size changed.
+func (o *Organizer) settleLibrarySize(mark int64, marked bool, measured int64) bool {
+	o.statsMutex.Lock()
+	defer o.statsMutex.Unlock()
+
+	if !marked || !o.sizeKnown {
+		changed := !o.sizeKnown || o.librarySize != measured
+		o.librarySize = measured
+		o.sizeKnown = true
+		return changed
+	}
+	delta := measured - mark
+	o.librarySize += delta
+	return delta != 0
+}
+
+// claimFinalPath reserves a free name for targetPath, adding a "(n)" suffix
+// while the name is taken. Each name is claimed by creating an empty
+// placeholder with O_EXCL, so two organizes finishing at once can't both pick
+// the same name; the caller moves the real file over the placeholder.
+func (o *Organizer) claimFinalPath(targetPath string) (string, error) {
+	for counter := 0; ; counter++ {
+		candidate := numberedPath(targetPath, counter)
+		placeholder, err := os.OpenFile(candidate, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err == nil {
+			placeholder.Close()
+			return candidate, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return "", fmt.Errorf("failed to claim %s: %w", candidate, err)
 		}
-		counter++
 	}
 }
 
-func (o *Organizer) checkDuplicate(filePath string, info *MediaInfo) (bool, error) {
+// numberedPath returns path for counter 0 and "name(counter).ext" otherwise.
+func numberedPath(path string, counter int) string {
+	if counter == 0 {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s(%d)%s", strings.TrimSuffix(path, ext), counter, ext)
+}
+
+func (o *Organizer) checkDuplicate(ctx context.Context, filePath string, info *MediaInfo) (bool, error) {
 	hash, err := o.calculateFileHash(filePath)
 	if err != nil {
 		return false, err
@@ -117,20 +717,52 @@ func (o *Organizer) checkDuplicate(filePath string, info *MediaInfo) (bool, erro
 		return false, err
 	}
 
+	return o.findDuplicate(ctx, filePath, hash, targetDir)
+}
+
+// findDuplicate looks for a file with the given hash in targetDir.
+func (o *Organizer) findDuplicate(ctx context.Context, filePath, hash, targetDir string) (bool, error) {
 	if _, err := os.Stat(targetDir); os.IsNotExist(err) {
 		return false, nil
 	}
 
+	var size int64
+	var preHash string
+	if o.dedupMode == DedupFast {
+		stat, err := os.Stat(filePath)
+		if err != nil {
+			return false, err
+		}
+		size = stat.Size()
+		if preHash, err = o.calculatePreHash(filePath, size); err != nil {
+			return false, err
+		}
+	}
+
 	var foundDuplicate bool
-	err = filepath.Walk(targetDir, func(path string, fileInfo os.FileInfo, err error) error {
+	err := filepath.Walk(targetDir, func(path string, fileInfo os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+
 		if err != nil {
 			return nil
 		}
 
-		if fileInfo.IsDir() {
+		if fileInfo.IsDir() || o.isHidden(fileInfo.Name()) {
 			return nil
 		}
 
+		if o.dedupMode == DedupFast {
+			if fileInfo.Size() != size {
+				return nil
+			}
+			existingPreHash, err := o.calculatePreHash(path, size)
+			if err != nil || existingPreHash != preHash {
+				return nil
+			}
+		}
+
 		existingHash, err := o.calculateFileHash(path)
 		if err != nil {
 			return nil
@@ -148,6 +780,35 @@ func (o *Organizer) checkDuplicate(filePath string, info *MediaInfo) (bool, erro
 	return foundDuplicate, err
 }
 
+// calculatePreHash hashes the file size plus its first and last o.preHash
+// bytes. Equal pre-hashes only suggest a duplicate; callers must confirm with
+// a full hash.
+func (o *Organizer) calculatePreHash(filePath string, size int64) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := o.newHash()
+	fmt.Fprintf(hash, "%d:", size)
+
+	if size <= 2*o.preHash {
+		if _, err := io.Copy(hash, file); err != nil {
+			return "", err
+		}
+	} else {
+		if _, err := io.CopyN(hash, file, o.preHash); err != nil {
+			return "", err
+		}
+		if _, err := io.Copy(hash, io.NewSectionReader(file, size-o.preHash, o.preHash)); err != nil {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
+
 func (o *Organizer) calculateFileHash(filePath string) (string, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
@@ -155,7 +816,7 @@ func (o *Organizer) calculateFileHash(filePath string) (string, error) {
 	}
 	defer file.Close()
 
-	hash := sha256.New()
+	hash := o.newHash()
 	if _, err := io.Copy(hash, file); err != nil {
 		return "", err
 	}
@@ -168,7 +829,7 @@ func (o *Organizer) getTargetDirectory(dateTaken *time.Time) (string, error) {
 	validatedDate := o.validateDate(dateTaken)
 
 	year := validatedDate.Format("2006")
-	month := validatedDate.Format("January") // Use full English month name
+	month := o.monthFormat.FolderName(validatedDate.Month())
 
 	targetDir := filepath.Join(o.mediaPath, year, month)
 	return targetDir, nil
@@ -195,11 +856,26 @@ func (o *Organizer) getFinalPath(targetDir, fileName string) string {
 		}
 	}
 
-	timestamp := time.Now().Unix()
+	timestamp := o.clock.Now().Unix()
 	newName := fmt.Sprintf("%s_%d%s", nameWithoutExt, timestamp, ext)
 	return filepath.Join(targetDir, newName)
 }
 
+// normalizeExtension rewrites fileName's extension according to the
+// configured ExtensionStyle.
+func (o *Organizer) normalizeExtension(fileName string) string {
+	if o.extensionStyle == ExtensionsKeep {
+		return fileName
+	}
+
+	ext := filepath.Ext(fileName)
+	normalized := strings.ToLower(ext)
+	if canonical, ok := canonicalExts[normalized]; ok && o.extensionStyle == ExtensionsCanonical {
+		normalized = canonical
+	}
+	return strings.TrimSuffix(fileName, ext) + normalized
+}
+
 // sanitizeFileName removes or replaces problematic characters in filenames
 func (o *Organizer) sanitizeFileName(fileName string) string {
 	if fileName == "" {
@@ -244,29 +920,74 @@ func (o *Organizer) sanitizeFileName(fileName string) string {
 		return "untitled"
 	}
 
-	// Ensure filename isn't too long (most filesystems support 255 characters)
-	if len(result) > 200 { // Leave some room for numbering if needed
-		ext := filepath.Ext(result)
-		nameWithoutExt := result[:len(result)-len(ext)]
-		if len(nameWithoutExt) > 200-len(ext) {
-			nameWithoutExt = nameWithoutExt[:200-len(ext)]
+	return truncateFileName(result, o.maxNameLength, o.nameUnit)
+}
+
+// truncateFileName shortens name to at most limit units, cutting only on rune
+// boundaries so the result stays valid UTF-8. The extension is kept unless it
+// leaves no room for even one character of the rest of the name.
+func truncateFileName(name string, limit int, unit FileNameUnit) string {
+	width := func(r rune, size int) int {
+		if unit == FileNameUTF16 {
+			return utf16.RuneLen(r)
+		}
+		return size
+	}
+	length := func(s string) int {
+		n := 0
+		for len(s) > 0 {
+			r, size := utf8.DecodeRuneInString(s)
+			n += width(r, size)
+			s = s[size:]
+		}
+		return n
+	}
+
+	if length(name) <= limit {
+		return name
+	}
+
+	cut := func(s string, budget int) string {
+		end := 0
+		for end < len(s) {
+			r, size := utf8.DecodeRuneInString(s[end:])
+			if budget -= width(r, size); budget < 0 {
+				break
+			}
+			end += size
 		}
-		result = nameWithoutExt + ext
+		return strings.TrimRight(s[:end], " .")
+	}
+
+	ext := filepath.Ext(name)
+	if base := cut(strings.TrimSuffix(name, ext), limit-length(ext)); base != "" {
+		return base + ext
 	}
+	return cut(name, limit)
+}
+
+// isClockError reports whether dateTaken should be flagged rather than
+// trusted under the FutureDatesFlag policy.
+func (o *Organizer) isClockError(dateTaken *time.Time) bool {
+	return o.futureDates == FutureDatesFlag && dateTaken != nil &&
+		dateTaken.After(o.clock.Now().Add(futureDateGrace))
+}
 
-	return result
+// DateRange returns the earliest and latest dates a file can be filed under:
+// from the start of the digital photography era to one year ahead.
+func (o *Organizer) DateRange() (time.Time, time.Time) {
+	return time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC), o.clock.Now().AddDate(1, 0, 0)
 }
 
 // validateDate ensures the date is reasonable and handles edge cases
 func (o *Organizer) validateDate(dateTaken *time.Time) *time.Time {
+	now := o.clock.Now()
 	if dateTaken == nil {
-		now := time.Now()
 		return &now
 	}
 
 	// Check for unreasonable dates (before digital photography era or too far in future)
-	minDate := time.Date(1990, 1, 1, 0, 0, 0, 0, time.UTC)
-	maxDate := time.Now().AddDate(1, 0, 0) // One year in the future
+	minDate, maxDate := o.DateRange()
 
 	if dateTaken.Before(minDate) || dateTaken.After(maxDate) {
 		slog.Warn("Date outside reasonable range, using current time",
@@ -274,7 +995,6 @@ func (o *Organizer) validateDate(dateTaken *time.Time) *time.Time {
 			"min_date", minDate,
 			"max_date", maxDate,
 		)
-		now := time.Now()
 		return &now
 	}
 
@@ -315,86 +1035,157 @@ func (o *Organizer) copyAndDelete(src, dst string) error {
 	return os.Remove(src)
 }
 
+// GetDirectoryStructure returns the number of media files in each month
+// folder, keyed by year and then month. The result is cached until the
+// library version changes, and concurrent callers wait for a single walk
+// rather than each walking a large library at once. Callers must treat the
+// result as read-only.
 func (o *Organizer) GetDirectoryStructure() (map[string]any, error) {
+	o.structureMutex.Lock()
+	defer o.structureMutex.Unlock()
+
+	version := o.version.Load()
+	if o.structure != nil && o.structureVersion == version {
+		return o.structure, nil
+	}
+
+	structure, err := o.walkDirectoryStructure()
+	if err != nil {
+		return nil, err
+	}
+
+	o.structure, o.structureVersion = structure, version
+	return structure, nil
+}
+
+// walkDirectoryStructure tallies every month in one walk of the library.
+// Files in nested folders such as bursts count towards their month.
+func (o *Organizer) walkDirectoryStructure() (map[string]any, error) {
 	structure := make(map[string]any)
+	months := func(year string) map[string]int {
+		if structure[year] == nil {
+			structure[year] = make(map[string]int)
+		}
+		return structure[year].(map[string]int)
+	}
 
-	err := filepath.Walk(o.mediaPath, func(path string, info os.FileInfo, err error) error {
+	// WalkDir avoids a stat per file, which dominates on large libraries
+	err := filepath.WalkDir(o.mediaPath, func(path string, entry fs.DirEntry, err error) error {
 		if err != nil {
 			return nil
 		}
-
-		if info.IsDir() && info.Name() == "temp" {
+		if entry.IsDir() && o.skipDir(path, o.mediaPath) {
 			return filepath.SkipDir
 		}
 
-		if info.IsDir() {
-			relPath, err := filepath.Rel(o.mediaPath, path)
-			if err != nil {
-				return nil
-			}
+		relPath, err := filepath.Rel(o.mediaPath, path)
+		if err != nil || relPath == "." {
+			return nil
+		}
 
-			if relPath == "." {
-				return nil
+		parts := strings.SplitN(filepath.ToSlash(relPath), "/", 3)
+		if _, ok := parseYearFolder(parts[0]); !ok {
+			if entry.IsDir() {
+				return filepath.SkipDir
 			}
+			return nil
+		}
 
-			parts := filepath.SplitList(relPath)
-			if len(parts) == 1 && len(parts[0]) == 4 { // Year directory
-				if structure[parts[0]] == nil {
-					structure[parts[0]] = make(map[string]int)
-				}
-			} else if len(parts) == 2 && len(parts[1]) == 2 { // Month directory
-				year := parts[0]
-				month := parts[1]
-
-				if structure[year] == nil {
-					structure[year] = make(map[string]int)
-				}
-
-				fileCount := o.countFilesInDirectory(path)
-				structure[year].(map[string]int)[month] = fileCount
+		switch {
+		case entry.IsDir() && len(parts) == 1:
+			months(parts[0])
+		case entry.IsDir() && len(parts) == 2:
+			counts := months(parts[0])
+			if _, ok := counts[parts[1]]; !ok {
+				counts[parts[1]] = 0 // Empty months are listed too
 			}
+		case !entry.IsDir() && len(parts) == 3 && o.isMediaFile(path):
+			months(parts[0])[parts[1]]++
 		}
-
 		return nil
 	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory structure: %w", err)
+	}
 
-	return structure, err
+	return structure, nil
 }
 
-func (o *Organizer) countFilesInDirectory(dirPath string) int {
-	count := 0
-	filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return nil
+// monthFolderNames maps a month given in any supported form to its folder
+// under the configured format, so "3" finds "2024/March", followed by its
+// folders under the other formats, which a library whose format changed
+// still holds. Anything that isn't a month, such as an event folder, is
+// returned unchanged.
+func (o *Organizer) monthFolderNames(month string) []string {
+	parsed, ok := ParseMonth(month)
+	if !ok {
+		return []string{month}
+	}
+
+	names := []string{o.monthFormat.FolderName(parsed)}
+	for _, format := range monthFormats {
+		if name := format.FolderName(parsed); !slices.Contains(names, name) {
+			names = append(names, name)
 		}
-		if !info.IsDir() {
-			count++
+	}
+	return names
+}
+
+// listingDirs returns the existing folders holding year/month: the library
+// root without a year, the year folder without a month, and otherwise every
+// folder of that month, whatever format it was made under.
+func (o *Organizer) listingDirs(year, month string) []string {
+	var dirs []string
+	switch {
+	case year == "":
+		dirs = []string{o.mediaPath}
+	case month == "":
+		dirs = []string{filepath.Join(o.mediaPath, year)}
+	default:
+		for _, name := range o.monthFolderNames(month) {
+			dirs = append(dirs, filepath.Join(o.mediaPath, year, name))
 		}
-		return nil
-	})
-	return count
+	}
+
+	existing := dirs[:0]
+	for _, dir := range dirs {
+		if _, err := os.Stat(dir); err == nil {
+			existing = append(existing, dir)
+		}
+	}
+	return existing
 }
 
 func (o *Organizer) ScanFiles(year, month string, limit, offset int) ([]MediaFileInfo, error) {
 	var files []MediaFileInfo
-	var targetPath string
 
-	if year == "" {
-		targetPath = o.mediaPath
-	} else if month == "" {
-		targetPath = filepath.Join(o.mediaPath, year)
-	} else {
-		targetPath = filepath.Join(o.mediaPath, year, month)
+	slog.Debug("ScanFiles called", "year", year, "month", month, "limit", limit, "offset", offset)
+
+	for _, targetPath := range o.listingDirs(year, month) {
+		if err := o.scanDir(targetPath, &files); err != nil {
+			return nil, err
+		}
 	}
 
-	slog.Debug("ScanFiles called", "year", year, "month", month, "limit", limit, "offset", offset)
-	slog.Debug("Media path configuration", "mediaPath", o.mediaPath, "targetPath", targetPath)
+	o.sortFiles(files)
+
+	start := offset
+	end := offset + limit
+
+	if start >= len(files) {
+		return []MediaFileInfo{}, nil
+	}
 
-	if _, err := os.Stat(targetPath); os.IsNotExist(err) {
-		slog.Debug("Target directory does not exist", "targetPath", targetPath)
-		return files, nil
+	if end > len(files) {
+		end = len(files)
 	}
 
+	return files[start:end], nil
+}
+
+// scanDir appends the listing entries of the media files under targetPath to
+// files.
+func (o *Organizer) scanDir(targetPath string, files *[]MediaFileInfo) error {
 	slog.Debug("Starting filepath.Walk", "targetPath", targetPath)
 
 	err := filepath.Walk(targetPath, func(path string, info os.FileInfo, err error) error {
@@ -406,13 +1197,12 @@ func (o *Organizer) ScanFiles(year, month string, limit, offset int) ([]MediaFil
 		slog.Debug("Walking path", "path", path, "isDir", info.IsDir(), "name", info.Name())
 
 		if info.IsDir() {
+			if o.skipDir(path, targetPath) {
+				return filepath.SkipDir
+			}
 			slog.Debug("Skipping directory", "path", path)
 			return nil
 		}
-		if strings.Contains(path, "/temp/") || strings.Contains(path, "\\temp\\") {
-			slog.Debug("Skipping temp file", "path", path)
-			return nil
-		}
 
 		slog.Debug("Processing file", "path", path, "name", info.Name())
 
@@ -428,7 +1218,7 @@ func (o *Organizer) ScanFiles(year, month string, limit, offset int) ([]MediaFil
 			relPath = path
 		}
 
-		mediaInfo, err := o.extractor.ExtractMetadata(path)
+		mediaInfo, err := o.metadataFor(path, relPath, info)
 		if err != nil {
 			slog.Warn("Failed to extract metadata", "file", path, "error", err)
 			mediaInfo = &MediaInfo{
@@ -437,87 +1227,275 @@ func (o *Organizer) ScanFiles(year, month string, limit, offset int) ([]MediaFil
 			}
 		}
 
-		fileInfo := MediaFileInfo{
-			ID:           o.generateFileID(relPath),
-			FileName:     info.Name(),
-			RelativePath: relPath,
-			Size:         info.Size(),
-			ModTime:      info.ModTime(),
-			MediaType:    o.getMediaType(path),
-			URL:          fmt.Sprintf("/media/%s", relPath),
-		}
+		*files = append(*files, o.newFileInfo(path, relPath, info, mediaInfo))
+		return nil
+	})
 
-		if mediaInfo != nil {
-			if mediaInfo.DateTaken != nil {
-				fileInfo.DateTaken = mediaInfo.DateTaken
-			}
-			if mediaInfo.Camera != nil {
-				camera := mediaInfo.Camera.Make
-				if mediaInfo.Camera.Model != "" {
-					if camera != "" {
-						camera += " " + mediaInfo.Camera.Model
-					} else {
-						camera = mediaInfo.Camera.Model
-					}
+	if err != nil {
+		return fmt.Errorf("failed to scan files: %w", err)
+	}
+	return nil
+}
+
+// newFileInfo builds the listing entry for a library file from its stat and
+// extracted metadata; mediaInfo may be nil.
+func (o *Organizer) newFileInfo(path, relPath string, info os.FileInfo, mediaInfo *MediaInfo) MediaFileInfo {
+	fileInfo := MediaFileInfo{
+		ID:           o.generateFileID(relPath),
+		FileName:     info.Name(),
+		RelativePath: relPath,
+		Size:         info.Size(),
+		ModTime:      info.ModTime(),
+		MediaType:    o.getMediaType(path),
+		URL:          fmt.Sprintf("/media/%s", relPath),
+	}
+
+	if mediaInfo != nil {
+		if mediaInfo.DateTaken != nil {
+			fileInfo.DateTaken = mediaInfo.DateTaken
+		}
+		if mediaInfo.Camera != nil {
+			camera := mediaInfo.Camera.Make
+			if mediaInfo.Camera.Model != "" {
+				if camera != "" {
+					camera += " " + mediaInfo.Camera.Model
+				} else {
+					camera = mediaInfo.Camera.Model
 				}
-				fileInfo.Camera = camera
 			}
-			if mediaInfo.Location != nil {
-				fileInfo.Location = fmt.Sprintf("%f,%f", mediaInfo.Location.Latitude, mediaInfo.Location.Longitude)
-			}
-			fileInfo.Width = mediaInfo.Width
-			fileInfo.Height = mediaInfo.Height
-			fileInfo.Duration = mediaInfo.Duration
+			fileInfo.Camera = camera
+		}
+		if mediaInfo.Location != nil {
+			fileInfo.Location = fmt.Sprintf("%f,%f", mediaInfo.Location.Latitude, mediaInfo.Location.Longitude)
 		}
+		fileInfo.Width = mediaInfo.Width
+		fileInfo.Height = mediaInfo.Height
+		fileInfo.Duration = mediaInfo.Duration
+		fileInfo.Rotation = mediaInfo.Rotation
+		fileInfo.Caption = mediaInfo.ExtraMetadata["caption"]
+		fileInfo.Blurhash = mediaInfo.ExtraMetadata["blurhash"]
+		fileInfo.DominantColor = mediaInfo.ExtraMetadata["dominantColor"]
+		fileInfo.ScreenCapture = mediaInfo.ScreenCapture
+	}
 
-		files = append(files, fileInfo)
-		return nil
-	})
+	return fileInfo
+}
 
+// FileInfo returns the listing entry for a single library file.
+func (o *Organizer) FileInfo(relPath string) (*MediaFileInfo, error) {
+	fullPath, err := o.ResolvePath(relPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to scan files: %w", err)
+		return nil, err
 	}
 
-	o.sortFiles(files)
+	stat, err := os.Stat(fullPath)
+	if err != nil {
+		return nil, err
+	}
 
-	start := offset
-	end := offset + limit
+	rel, err := filepath.Rel(o.mediaPath, fullPath)
+	if err != nil {
+		return nil, err
+	}
 
-	if start >= len(files) {
-		return []MediaFileInfo{}, nil
+	mediaInfo, err := o.metadataFor(fullPath, rel, stat)
+	if err != nil {
+		return nil, err
 	}
 
-	if end > len(files) {
-		end = len(files)
+	fileInfo := o.newFileInfo(fullPath, rel, stat, mediaInfo)
+	return &fileInfo, nil
+}
+
+// CountFiles counts the media files ScanFiles would return for year/month
+// without extracting any metadata, so totals stay accurate on libraries too
+// large to materialize.
+func (o *Organizer) CountFiles(year, month string) (int, error) {
+	count := 0
+	for _, targetPath := range o.listingDirs(year, month) {
+		n, err := o.countDir(targetPath)
+		if err != nil {
+			return 0, err
+		}
+		count += n
 	}
+	return count, nil
+}
 
-	return files[start:end], nil
+// countDir counts the media files under targetPath.
+func (o *Organizer) countDir(targetPath string) (int, error) {
+	count := 0
+	err := filepath.Walk(targetPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if o.skipDir(path, targetPath) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if o.isMediaFile(path) {
+			count++
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count files: %w", err)
+	}
+
+	return count, nil
 }
 
-func (o *Organizer) isMediaFile(filePath string) bool {
-	ext := strings.ToLower(filepath.Ext(filePath))
-	supportedExts := map[string]bool{
+// MediaFilesIn lists the library-relative paths of the media files under a
+// year folder, or one of its months in every format it was filed under, in
+// walk order. A missing folder is reported as fs.ErrNotExist.
+func (o *Organizer) MediaFilesIn(year, month string) ([]string, error) {
+	relDirs := []string{year}
+	if month != "" {
+		relDirs = nil
+		for _, name := range o.monthFolderNames(month) {
+			relDirs = append(relDirs, filepath.Join(year, name))
+		}
+	}
+
+	var paths []string
+	found := false
+	for _, relDir := range relDirs {
+		dir, err := o.ResolvePath(relDir)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			continue
+		}
+		found = true
+		if paths, err = o.appendMediaFiles(paths, dir); err != nil {
+			return nil, err
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("%s: %w", filepath.ToSlash(relDirs[0]), fs.ErrNotExist)
+	}
+	return paths, nil
+}
+
+// appendMediaFiles appends the library-relative paths of the media files
+// under dir to paths.
+func (o *Organizer) appendMediaFiles(paths []string, dir string) ([]string, error) {
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if o.skipDir(path, dir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if o.isMediaFile(path) {
+			relPath, err := filepath.Rel(o.mediaPath, path)
+			if err != nil {
+				return err
+			}
+			paths = append(paths, filepath.ToSlash(relPath))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return paths, nil
+}
+
+var (
+	imageExts = map[string]bool{
 		".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".bmp": true, ".tiff": true,
+		".heic": true, ".heif": true,
+	}
+	videoExts = map[string]bool{
 		".mp4": true, ".mov": true, ".avi": true, ".mkv": true, ".webm": true, ".m4v": true,
 		".3gp": true, ".wmv": true, ".flv": true,
 	}
-	return supportedExts[ext]
+)
+
+// SupportedImageExtensions lists, sorted, the image extensions the library
+// scans and browses.
+func SupportedImageExtensions() []string {
+	return sortedKeys(imageExts)
+}
+
+// SupportedVideoExtensions lists, sorted, the video extensions the library
+// scans and browses.
+func SupportedVideoExtensions() []string {
+	return sortedKeys(videoExts)
+}
+
+// IsSupportedFile reports whether fileName has an extension the library scans
+// and browses. Other files can still be uploaded but only show up as "other".
+func IsSupportedFile(fileName string) bool {
+	ext := strings.ToLower(filepath.Ext(fileName))
+	return imageExts[ext] || videoExts[ext]
+}
+
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
 
-func (o *Organizer) getMediaType(filePath string) string {
-	ext := strings.ToLower(filepath.Ext(filePath))
-	imageExts := map[string]bool{
-		".jpg": true, ".jpeg": true, ".png": true, ".gif": true, ".bmp": true, ".tiff": true,
+func (o *Organizer) isMediaFile(filePath string) bool {
+	if o.isHidden(filepath.Base(filePath)) {
+		return false
 	}
-	if imageExts[ext] {
-		return "image"
+	return IsSupportedFile(filePath) || o.extractor.isDocument(filePath)
+}
+
+// junkFiles are operating system metadata files, matched case-insensitively.
+var junkFiles = map[string]bool{
+	".ds_store":   true,
+	"thumbs.db":   true,
+	"desktop.ini": true,
+}
+
+// isHidden reports whether a file or folder name is hidden or OS junk, such
+// as the "._photo.jpg" resource forks macOS leaves on shared drives. Scans and
+// imports skip these unless IncludeHidden is set.
+func (o *Organizer) isHidden(name string) bool {
+	if o.includeHidden {
+		return false
 	}
-	return "video"
+	return strings.HasPrefix(name, ".") || junkFiles[strings.ToLower(name)]
 }
 
-func (o *Organizer) generateFileID(relPath string) string {
-	hash := sha256.Sum256([]byte(relPath))
-	return fmt.Sprintf("%x", hash[:8])
+// isHiddenDir reports whether a walk rooted at root should skip the folder
+// at path. The root itself is never skipped.
+func (o *Organizer) isHiddenDir(path, root string) bool {
+	return path != root && o.isHidden(filepath.Base(path))
+}
+
+// skipDir reports whether a walk of the library from root leaves out the
+// folder at path: upload temp folders, the trash, the quarantine and hidden
+// folders. Every library walker goes through it so they agree on what the
+// library holds.
+func (o *Organizer) skipDir(path, root string) bool {
+	if path != root && filepath.Base(path) == "temp" {
+		return true
+	}
+	return o.isQuarantineDir(path) || o.isTrashDir(path) || o.isHiddenDir(path, root)
+}
+
+func (o *Organizer) getMediaType(filePath string) string {
+	if imageExts[strings.ToLower(filepath.Ext(filePath))] {
+		return "image"
+	}
+	if o.extractor.isDocument(filePath) {
+		return "document"
+	}
+	return "video"
 }
 
 func (o *Organizer) sortFiles(files []MediaFileInfo) {
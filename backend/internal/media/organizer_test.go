@@ -1,13 +1,19 @@
 package media
 
 import (
+	"context"
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"testing"
 	"time"
+	"unicode/utf8"
+
+	"github.com/Steven-harris/sortify/backend/internal/clock"
 )
 
 func TestNewOrganizer(t *testing.T) {
@@ -266,6 +272,83 @@ func TestOrganizeFileConflict(t *testing.T) {
 	}
 }
 
+func TestOrganizeFileExtensionStyle(t *testing.T) {
+	tests := []struct {
+		style    ExtensionStyle
+		fileName string
+		expected string
+	}{
+		{"", "PHOTO.JPG", "PHOTO.JPG"},
+		{ExtensionsKeep, "photo.jpeg", "photo.jpeg"},
+		{ExtensionsLower, "PHOTO.JPG", "PHOTO.jpg"},
+		{ExtensionsLower, "photo.JPEG", "photo.jpeg"},
+		{ExtensionsCanonical, "PHOTO.JPEG", "PHOTO.jpg"},
+		{ExtensionsCanonical, "clip.MOV", "clip.mov"},
+	}
+
+	for _, test := range tests {
+		t.Run(string(test.style)+"/"+test.fileName, func(t *testing.T) {
+			organizer := NewOrganizerWithOptions(t.TempDir(), OrganizerOptions{ExtensionStyle: test.style})
+			info := organizeTestFile(t, organizer, test.fileName, "content of "+test.fileName)
+
+			if base := filepath.Base(info.RelativePath); base != test.expected {
+				t.Errorf("Expected stored name %s, got %s", test.expected, base)
+			}
+			if info.FileName != test.fileName {
+				t.Errorf("Expected file name %s, got %s", test.fileName, info.FileName)
+			}
+
+			original, recorded := info.ExtraMetadata["originalFilename"]
+			if renamed := test.expected != test.fileName; renamed != recorded || (recorded && original != test.fileName) {
+				t.Errorf("Expected originalFilename %q only when renamed, got %q", test.fileName, original)
+			}
+		})
+	}
+}
+
+func TestSanitizeFileNameLength(t *testing.T) {
+	// "写真" is 3 bytes and 1 UTF-16 unit per rune; "😀" is 4 bytes and 2 units
+	kanji := strings.Repeat("写真", 60) + ".jpg"
+	emoji := strings.Repeat("😀", 70) + ".jpg"
+
+	tests := []struct {
+		name     string
+		options  OrganizerOptions
+		fileName string
+		expected string
+	}{
+		{"Short names are untouched", OrganizerOptions{}, "写真.jpg", "写真.jpg"},
+		{"Default limit in bytes", OrganizerOptions{}, kanji, strings.Repeat("写真", 32) + "写.jpg"},
+		{"Cut never splits a rune", OrganizerOptions{MaxFileNameLength: 10}, kanji, "写真.jpg"},
+		{"UTF-16 units", OrganizerOptions{MaxFileNameLength: 100, FileNameUnit: FileNameUTF16}, kanji, strings.Repeat("写真", 48) + ".jpg"},
+		{"Surrogate pairs", OrganizerOptions{MaxFileNameLength: 9, FileNameUnit: FileNameUTF16}, emoji, "😀😀.jpg"},
+		{"Extension longer than the limit", OrganizerOptions{MaxFileNameLength: 7}, "写真写真.jpeg", "写真"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			organizer := NewOrganizerWithOptions(t.TempDir(), test.options)
+			result := organizer.sanitizeFileName(test.fileName)
+
+			if !utf8.ValidString(result) {
+				t.Fatalf("Expected valid UTF-8, got %q", result)
+			}
+			if result != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, result)
+			}
+		})
+	}
+
+	// Every byte limit across a multibyte boundary yields a whole-rune name
+	for limit := 8; limit <= 20; limit++ {
+		organizer := NewOrganizerWithOptions(t.TempDir(), OrganizerOptions{MaxFileNameLength: limit})
+		result := organizer.sanitizeFileName(emoji)
+		if !utf8.ValidString(result) || len(result) > limit || !strings.HasSuffix(result, ".jpg") {
+			t.Errorf("Limit %d: expected a valid name of at most %d bytes ending in .jpg, got %q", limit, limit, result)
+		}
+	}
+}
+
 func TestCheckDuplicate(t *testing.T) {
 	tempDir := t.TempDir()
 	organizer := NewOrganizer(tempDir)
@@ -295,7 +378,7 @@ func TestCheckDuplicate(t *testing.T) {
 	}
 
 	// This should not be a duplicate since no organized files exist yet
-	isDuplicate, err := organizer.checkDuplicate(file1, info)
+	isDuplicate, err := organizer.checkDuplicate(context.Background(), file1, info)
 	if err != nil {
 		t.Fatalf("checkDuplicate failed: %v", err)
 	}
@@ -460,3 +543,854 @@ func TestGetDirectoryStructure(t *testing.T) {
 		t.Error("Expected 2023 to exist in directory structure")
 	}
 }
+
+func TestGetDirectoryStructureCounts(t *testing.T) {
+	tempDir := t.TempDir()
+	organizer := NewOrganizer(tempDir)
+
+	for _, path := range []string{
+		"2024/March/IMG_20240315_143022.jpg",
+		"2024/March/Burst_20240315_150000/IMG_20240315_150001.jpg",
+		"2024/March/notes.txt",
+		"2023/December/VID_20231225_120000.mp4",
+		"temp/upload.jpg",
+	} {
+		fullPath := filepath.Join(tempDir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", path, err)
+		}
+		if err := os.WriteFile(fullPath, []byte(path), 0644); err != nil {
+			t.Fatalf("Failed to create file %s: %v", path, err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(tempDir, "2024", "April"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	structure, err := organizer.GetDirectoryStructure()
+	if err != nil {
+		t.Fatalf("GetDirectoryStructure failed: %v", err)
+	}
+
+	expected := map[string]map[string]int{
+		"2024": {"March": 2, "April": 0},
+		"2023": {"December": 1},
+	}
+	if len(structure) != len(expected) {
+		t.Errorf("Expected years %v, got %v", expected, structure)
+	}
+	for year, months := range expected {
+		got, ok := structure[year].(map[string]int)
+		if !ok {
+			t.Errorf("Expected %s in structure, got %v", year, structure)
+			continue
+		}
+		for month, count := range months {
+			if gotCount, ok := got[month]; !ok || gotCount != count {
+				t.Errorf("Expected %s/%s to have %d files, got %d (present: %v)", year, month, count, gotCount, ok)
+			}
+		}
+	}
+}
+
+func TestGetDirectoryStructureCached(t *testing.T) {
+	tempDir := t.TempDir()
+	organizer := NewOrganizer(tempDir)
+	organizeTestFile(t, organizer, "IMG_20240315_143022.jpg", "first")
+
+	marchCount := func() int {
+		t.Helper()
+		structure, err := organizer.GetDirectoryStructure()
+		if err != nil {
+			t.Fatalf("GetDirectoryStructure failed: %v", err)
+		}
+		months, _ := structure["2024"].(map[string]int)
+		return months["March"]
+	}
+
+	if count := marchCount(); count != 1 {
+		t.Fatalf("Expected 1 file in March, got %d", count)
+	}
+
+	// A file added behind the organizer's back isn't seen: the cached
+	// structure is reused while the library version is unchanged
+	if err := os.WriteFile(filepath.Join(tempDir, "2024", "March", "manual.jpg"), []byte("manual"), 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	if count := marchCount(); count != 1 {
+		t.Errorf("Expected cached count 1, got %d", count)
+	}
+
+	// Organizing a file changes the version and forces a fresh walk
+	organizeTestFile(t, organizer, "IMG_20240316_101500.jpg", "second")
+	if count := marchCount(); count != 3 {
+		t.Errorf("Expected 3 files in March after a library change, got %d", count)
+	}
+}
+
+// nestedDirectoryStructure is the former implementation: list the year and
+// month folders, then walk each month again to count its files.
+func nestedDirectoryStructure(mediaPath string) map[string]map[string]int {
+	structure := make(map[string]map[string]int)
+	years, _ := os.ReadDir(mediaPath)
+	for _, year := range years {
+		if !year.IsDir() || len(year.Name()) != 4 {
+			continue
+		}
+		structure[year.Name()] = make(map[string]int)
+		months, _ := os.ReadDir(filepath.Join(mediaPath, year.Name()))
+		for _, month := range months {
+			if !month.IsDir() {
+				continue
+			}
+			count := 0
+			filepath.Walk(filepath.Join(mediaPath, year.Name(), month.Name()), func(path string, info os.FileInfo, err error) error {
+				if err == nil && !info.IsDir() {
+					count++
+				}
+				return nil
+			})
+			structure[year.Name()][month.Name()] = count
+		}
+	}
+	return structure
+}
+
+func TestWalkDirectoryStructureMatchesNestedCount(t *testing.T) {
+	tempDir := t.TempDir()
+	for _, path := range []string{
+		"2022/July/IMG_001.jpg",
+		"2024/January/IMG_001.jpg",
+		"2024/January/IMG_002.png",
+		"2024/March/VID_001.mp4",
+		"2024/March/Burst_20240315_150000/IMG_003.jpg",
+		"2024/March/Burst_20240315_150000/IMG_004.jpg",
+	} {
+		fullPath := filepath.Join(tempDir, path)
+		if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+			t.Fatalf("Failed to create directory for %s: %v", path, err)
+		}
+		if err := os.WriteFile(fullPath, []byte(path), 0644); err != nil {
+			t.Fatalf("Failed to create file %s: %v", path, err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(tempDir, "2023", "May"), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	structure, err := NewOrganizer(tempDir).walkDirectoryStructure()
+	if err != nil {
+		t.Fatalf("walkDirectoryStructure failed: %v", err)
+	}
+
+	expected := nestedDirectoryStructure(tempDir)
+	if len(structure) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, structure)
+	}
+	for year, months := range expected {
+		got, _ := structure[year].(map[string]int)
+		if len(got) != len(months) {
+			t.Errorf("Expected %s months %v, got %v", year, months, got)
+			continue
+		}
+		for month, count := range months {
+			if got[month] != count {
+				t.Errorf("Expected %s/%s to have %d files, got %d", year, month, count, got[month])
+			}
+		}
+	}
+}
+
+func TestScanFilesSkipsHiddenFiles(t *testing.T) {
+	files := []string{
+		"2024/March/photo.jpg",
+		"2024/March/._photo.jpg",
+		"2024/March/.DS_Store",
+		"2024/March/Thumbs.db",
+		"2024/March/.thumbnails/cached.jpg",
+	}
+
+	tests := []struct {
+		name          string
+		includeHidden bool
+		expected      []string
+	}{
+		{"hidden skipped", false, []string{"photo.jpg"}},
+		{"hidden included", true, []string{"._photo.jpg", "cached.jpg", "photo.jpg"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			for _, path := range files {
+				fullPath := filepath.Join(tempDir, path)
+				if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+					t.Fatalf("Failed to create directory for %s: %v", path, err)
+				}
+				if err := os.WriteFile(fullPath, []byte(path), 0644); err != nil {
+					t.Fatalf("Failed to create file %s: %v", path, err)
+				}
+			}
+			organizer := NewOrganizerWithOptions(tempDir, OrganizerOptions{IncludeHidden: test.includeHidden})
+
+			scanned, err := organizer.ScanFiles("2024", "March", 100, 0)
+			if err != nil {
+				t.Fatalf("ScanFiles failed: %v", err)
+			}
+			var names []string
+			for _, file := range scanned {
+				names = append(names, file.FileName)
+			}
+			sort.Strings(names)
+			if strings.Join(names, ",") != strings.Join(test.expected, ",") {
+				t.Errorf("Expected %v, got %v", test.expected, names)
+			}
+
+			count, err := organizer.CountFiles("2024", "March")
+			if err != nil {
+				t.Fatalf("CountFiles failed: %v", err)
+			}
+			if count != len(test.expected) {
+				t.Errorf("Expected count %d, got %d", len(test.expected), count)
+			}
+		})
+	}
+}
+
+func TestBrowseNumericMonthFormat(t *testing.T) {
+	tempDir := t.TempDir()
+	organizer := NewOrganizerWithOptions(tempDir, OrganizerOptions{MonthFormat: MonthFormatNumber})
+
+	info := organizeTestFile(t, organizer, "IMG_20240315_143022.jpg", "numeric month")
+	expectedPath := filepath.Join("2024", "03", "IMG_20240315_143022.jpg")
+	if info.RelativePath != expectedPath {
+		t.Fatalf("Expected file at %s, got %s", expectedPath, info.RelativePath)
+	}
+
+	// An event folder beside the month isn't a month, but still isn't lost
+	eventDir := filepath.Join(tempDir, "2024", "2024-03-20")
+	if err := os.MkdirAll(eventDir, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	structure, err := organizer.GetDirectoryStructure()
+	if err != nil {
+		t.Fatalf("GetDirectoryStructure failed: %v", err)
+	}
+	months, _ := structure["2024"].(map[string]int)
+	if months["03"] != 1 {
+		t.Errorf("Expected 1 file in 2024/03, got %v", months)
+	}
+
+	for _, month := range []string{"03", "3", "March"} {
+		files, err := organizer.ScanFiles("2024", month, 10, 0)
+		if err != nil {
+			t.Fatalf("ScanFiles(%q) failed: %v", month, err)
+		}
+		if len(files) != 1 {
+			t.Errorf("Expected ScanFiles(%q) to find 1 file, got %d", month, len(files))
+		}
+	}
+}
+
+func TestBrowseFoldersOfEarlierMonthFormat(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Organized under the default format, then browsed after switching formats
+	info := organizeTestFile(t, NewOrganizer(tempDir), "IMG_20240315_143022.jpg", "named month")
+	expectedPath := filepath.Join("2024", "March", "IMG_20240315_143022.jpg")
+	if info.RelativePath != expectedPath {
+		t.Fatalf("Expected file at %s, got %s", expectedPath, info.RelativePath)
+	}
+
+	organizer := NewOrganizerWithOptions(tempDir, OrganizerOptions{MonthFormat: MonthFormatNumber})
+	files, err := organizer.ScanFiles("2024", "3", 10, 0)
+	if err != nil {
+		t.Fatalf("ScanFiles failed: %v", err)
+	}
+	if len(files) != 1 {
+		t.Errorf("Expected ScanFiles to find 1 file, got %d", len(files))
+	}
+	count, err := organizer.CountFiles("2024", "3")
+	if err != nil {
+		t.Fatalf("CountFiles failed: %v", err)
+	}
+	if count != 1 {
+		t.Errorf("Expected count 1, got %d", count)
+	}
+}
+
+func BenchmarkGetDirectoryStructure(b *testing.B) {
+	tempDir := b.TempDir()
+	for month := time.January; month <= time.December; month++ {
+		dir := filepath.Join(tempDir, "2024", month.String())
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			b.Fatalf("Failed to create directory: %v", err)
+		}
+		for i := 0; i < 50; i++ {
+			if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("IMG_%03d.jpg", i)), nil, 0644); err != nil {
+				b.Fatalf("Failed to create file: %v", err)
+			}
+		}
+	}
+	organizer := NewOrganizer(tempDir)
+
+	b.Run("nested", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			nestedDirectoryStructure(tempDir)
+		}
+	})
+
+	b.Run("walk", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := organizer.walkDirectoryStructure(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := organizer.GetDirectoryStructure(); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestOrganizeFileContextCancelled(t *testing.T) {
+	tempDir := t.TempDir()
+	organizer := NewOrganizer(tempDir)
+
+	// Seed the target month so the duplicate scan has work to do
+	targetDir := filepath.Join(tempDir, "2024", "March")
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		t.Fatalf("Failed to create target directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(targetDir, "existing.jpg"), []byte("existing"), 0644); err != nil {
+		t.Fatalf("Failed to create existing file: %v", err)
+	}
+
+	sourceFile := filepath.Join(tempDir, "source", "IMG_20240315_143022.jpg")
+	if err := os.MkdirAll(filepath.Dir(sourceFile), 0755); err != nil {
+		t.Fatalf("Failed to create source directory: %v", err)
+	}
+	if err := os.WriteFile(sourceFile, []byte("new content"), 0644); err != nil {
+		t.Fatalf("Failed to create source file: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := organizer.OrganizeFileContext(ctx, sourceFile, "IMG_20240315_143022.jpg")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+
+	if _, err := os.Stat(sourceFile); err != nil {
+		t.Errorf("Source file should be kept when organize is aborted: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(targetDir, "IMG_20240315_143022.jpg")); !os.IsNotExist(err) {
+		t.Error("File should not be moved when organize is aborted")
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+
+	tests := []struct {
+		relPath  string
+		expected string
+		wantErr  bool
+	}{
+		{"2024/March/photo.jpg", filepath.Join(mediaDir, "2024", "March", "photo.jpg"), false},
+		{"/2024/March/photo.jpg", filepath.Join(mediaDir, "2024", "March", "photo.jpg"), false},
+		{"../../etc/passwd", filepath.Join(mediaDir, "etc", "passwd"), false},
+		{"", "", true},
+		{"/", "", true},
+	}
+
+	for _, test := range tests {
+		result, err := organizer.ResolvePath(test.relPath)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("ResolvePath(%q): expected error, got %s", test.relPath, result)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ResolvePath(%q) failed: %v", test.relPath, err)
+			continue
+		}
+		if result != test.expected {
+			t.Errorf("ResolvePath(%q) = %s, expected %s", test.relPath, result, test.expected)
+		}
+	}
+}
+
+func TestScanFilesOrdersBurstFrames(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+
+	// Two frames within the same second, 150 ms apart, written out of order
+	monthDir := filepath.Join(mediaDir, "2023", "December")
+	if err := os.MkdirAll(monthDir, 0755); err != nil {
+		t.Fatalf("Failed to create month directory: %v", err)
+	}
+	for _, name := range []string{"PXL_20231225_143022273.jpg", "PXL_20231225_143022123.jpg"} {
+		if err := os.WriteFile(filepath.Join(monthDir, name), []byte(name), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+
+	files, err := organizer.ScanFiles("", "", 10, 0)
+	if err != nil {
+		t.Fatalf("ScanFiles failed: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("Expected 2 files, got %d", len(files))
+	}
+
+	// Newest first, as for every other listing
+	if files[0].FileName != "PXL_20231225_143022273.jpg" || files[1].FileName != "PXL_20231225_143022123.jpg" {
+		t.Errorf("Expected burst frames newest first, got %s, %s", files[0].FileName, files[1].FileName)
+	}
+	if gap := files[0].DateTaken.Sub(*files[1].DateTaken); gap != 150*time.Millisecond {
+		t.Errorf("Expected frames 150ms apart, got %v", gap)
+	}
+}
+
+func TestCheckDuplicateFastMode(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizerWithOptions(mediaDir, OrganizerOptions{DedupMode: DedupFast, PreHashBytes: 16})
+
+	head := strings.Repeat("H", 16)
+	tail := strings.Repeat("T", 16)
+	existing := head + "middle-one" + tail
+
+	date := time.Date(2024, 3, 15, 14, 30, 22, 0, time.UTC)
+	info := &MediaInfo{DateTaken: &date}
+
+	targetDir, err := organizer.getTargetDirectory(&date)
+	if err != nil {
+		t.Fatalf("getTargetDirectory failed: %v", err)
+	}
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		t.Fatalf("Failed to create target directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(targetDir, "existing.jpg"), []byte(existing), 0644); err != nil {
+		t.Fatalf("Failed to create existing file: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		content   string
+		duplicate bool
+	}{
+		{"Identical file", existing, true},
+		{"Same head, tail and size, different middle", head + "middle-two" + tail, false},
+		{"Different size", head + "middle" + tail, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			candidate := filepath.Join(t.TempDir(), "candidate.jpg")
+			if err := os.WriteFile(candidate, []byte(test.content), 0644); err != nil {
+				t.Fatalf("Failed to create candidate file: %v", err)
+			}
+
+			duplicate, err := organizer.checkDuplicate(context.Background(), candidate, info)
+			if err != nil {
+				t.Fatalf("checkDuplicate failed: %v", err)
+			}
+			if duplicate != test.duplicate {
+				t.Errorf("Expected duplicate=%v, got %v", test.duplicate, duplicate)
+			}
+		})
+	}
+}
+
+func TestOrganizeFileDefaultTimezone(t *testing.T) {
+	// Late evening on 31 March in New York (EDT) is already 1 April in UTC
+	newYork := time.FixedZone("EDT", -4*60*60)
+	mediaDir := t.TempDir()
+	organizer := NewOrganizerWithOptions(mediaDir, OrganizerOptions{Location: newYork})
+
+	named := organizeTestFile(t, organizer, "IMG_20240331_210000.jpg", "filename dated")
+	if named.DateSource != DateSourceFileName {
+		t.Fatalf("Expected date source %s, got %s", DateSourceFileName, named.DateSource)
+	}
+
+	source := filepath.Join(t.TempDir(), "scan.jpg")
+	if err := os.WriteFile(source, []byte("file time dated"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	modTime := time.Date(2024, 3, 31, 22, 0, 0, 0, newYork)
+	if err := os.Chtimes(source, modTime, modTime); err != nil {
+		t.Fatalf("Failed to set modification time: %v", err)
+	}
+	timed, err := organizer.OrganizeFile(source, "scan.jpg")
+	if err != nil {
+		t.Fatalf("OrganizeFile failed: %v", err)
+	}
+	if timed.DateSource != DateSourceFileTime {
+		t.Fatalf("Expected date source %s, got %s", DateSourceFileTime, timed.DateSource)
+	}
+
+	for _, info := range []*MediaInfo{named, timed} {
+		if dir := filepath.Dir(info.RelativePath); dir != filepath.Join("2024", "March") {
+			t.Errorf("Expected %s in 2024/March, got %s", info.FileName, dir)
+		}
+		if info.DateTaken.Location() != newYork {
+			t.Errorf("Expected %s dated in %s, got %s", info.FileName, newYork, info.DateTaken.Location())
+		}
+	}
+}
+
+func TestOrganizeFileArchivesOriginal(t *testing.T) {
+	root := t.TempDir()
+	mediaDir := filepath.Join(root, "media")
+	archiveDir := filepath.Join(root, "archive")
+	organizer := NewOrganizerWithOptions(mediaDir, OrganizerOptions{ArchivePath: archiveDir})
+
+	first := organizeTestFile(t, organizer, "IMG_20200101_120000.jpg", "first upload")
+	second := organizeTestFile(t, organizer, "IMG_20200101_120000.jpg", "same name, other content")
+
+	now := time.Now().UTC()
+	dayDir := filepath.Join(archiveDir, now.Format("2006"), now.Format("01-02"))
+
+	for i, info := range []*MediaInfo{first, second} {
+		libraryPath := filepath.Join(mediaDir, info.RelativePath)
+		libraryStat, err := os.Stat(libraryPath)
+		if err != nil {
+			t.Fatalf("Expected organized file at %s: %v", info.RelativePath, err)
+		}
+		if !strings.HasPrefix(info.RelativePath, filepath.Join("2020", "January")) {
+			t.Errorf("Expected file organized by its own date, got %s", info.RelativePath)
+		}
+
+		archiveName := "IMG_20200101_120000.jpg"
+		if i == 1 {
+			archiveName = "IMG_20200101_120000(1).jpg"
+		}
+		archiveStat, err := os.Stat(filepath.Join(dayDir, archiveName))
+		if err != nil {
+			t.Fatalf("Expected archived original %s: %v", archiveName, err)
+		}
+		// Same volume, so the archive is a hard link rather than a copy
+		if !os.SameFile(libraryStat, archiveStat) {
+			t.Errorf("Expected %s to be hard-linked to the library file", archiveName)
+		}
+	}
+}
+
+func TestOrganizeFileConcurrentSameName(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+
+	const uploads = 8
+	sources := make([]string, uploads)
+	for i := range sources {
+		sources[i] = filepath.Join(t.TempDir(), "IMG_20240315_143022.jpg")
+		if err := os.WriteFile(sources[i], []byte(fmt.Sprintf("distinct content %d", i)), 0644); err != nil {
+			t.Fatalf("Failed to create test file: %v", err)
+		}
+	}
+
+	start := make(chan struct{})
+	results := make(chan *MediaInfo, uploads)
+	errs := make(chan error, uploads)
+	for _, source := range sources {
+		go func(source string) {
+			<-start
+			info, err := organizer.OrganizeFile(source, "IMG_20240315_143022.jpg")
+			if err != nil {
+				errs <- err
+				return
+			}
+			results <- info
+		}(source)
+	}
+	close(start)
+
+	seen := make(map[string]bool)
+	for i := 0; i < uploads; i++ {
+		select {
+		case err := <-errs:
+			t.Fatalf("OrganizeFile failed: %v", err)
+		case info := <-results:
+			if seen[info.RelativePath] {
+				t.Errorf("Two uploads were given %s", info.RelativePath)
+			}
+			seen[info.RelativePath] = true
+		}
+	}
+
+	// Every upload must have survived with its own content
+	contents := make(map[string]bool)
+	for relPath := range seen {
+		data, err := os.ReadFile(filepath.Join(mediaDir, relPath))
+		if err != nil {
+			t.Fatalf("Failed to read %s: %v", relPath, err)
+		}
+		contents[string(data)] = true
+	}
+	if len(contents) != uploads {
+		t.Errorf("Expected %d distinct files to survive, got %d", uploads, len(contents))
+	}
+}
+
+func TestOrganizeFileFutureDates(t *testing.T) {
+	claimed := time.Now().AddDate(0, 0, 10).Truncate(time.Second).UTC()
+	fileName := "IMG_" + claimed.Format("20060102_150405") + ".jpg"
+	monthDir := filepath.Join(claimed.Format("2006"), claimed.Format("January"))
+
+	tests := []struct {
+		name        string
+		policy      FutureDatePolicy
+		expectedDir string
+		flagged     bool
+	}{
+		{"default keeps near-future date", "", monthDir, false},
+		{"reset keeps near-future date", FutureDatesReset, monthDir, false},
+		{"flag files under clock errors", FutureDatesFlag, ClockErrorFolder, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			organizer := NewOrganizerWithOptions(t.TempDir(), OrganizerOptions{FutureDates: test.policy})
+			info := organizeTestFile(t, organizer, fileName, "clock is wrong")
+
+			if dir := filepath.Dir(info.RelativePath); dir != test.expectedDir {
+				t.Errorf("Expected file in %s, got %s", test.expectedDir, dir)
+			}
+			if info.DateTaken == nil || !info.DateTaken.Equal(claimed) {
+				t.Errorf("Expected claimed date %v to be kept, got %v", claimed, info.DateTaken)
+			}
+
+			claimedDate, ok := info.ExtraMetadata["claimedDate"]
+			if ok != test.flagged {
+				t.Fatalf("Expected claimedDate recorded to be %v, got %v", test.flagged, ok)
+			}
+			if test.flagged && claimedDate != claimed.Format(time.RFC3339) {
+				t.Errorf("Expected claimedDate %s, got %s", claimed.Format(time.RFC3339), claimedDate)
+			}
+		})
+	}
+}
+
+func TestOrganizeFileFutureDatesGraceBoundary(t *testing.T) {
+	taken := time.Date(2024, 3, 15, 14, 30, 22, 0, time.UTC)
+	fake := clock.NewFake(taken.Add(-futureDateGrace - time.Second))
+	organizer := NewOrganizerWithOptions(t.TempDir(), OrganizerOptions{
+		FutureDates: FutureDatesFlag,
+		Clock:       fake,
+	})
+
+	// One second beyond the grace period the date is a clock error
+	info := organizeTestFile(t, organizer, "IMG_20240315_143022.jpg", "too early")
+	if dir := filepath.Dir(info.RelativePath); dir != ClockErrorFolder {
+		t.Errorf("Expected file in %s, got %s", ClockErrorFolder, dir)
+	}
+
+	// Exactly at the grace boundary it is accepted
+	fake.Advance(time.Second)
+	info = organizeTestFile(t, organizer, "IMG_20240315_143022.jpg", "on time")
+	if dir := filepath.Dir(info.RelativePath); dir != filepath.Join("2024", "March") {
+		t.Errorf("Expected file in 2024/March, got %s", dir)
+	}
+}
+
+func TestOrganizeFileUndatedUsesClock(t *testing.T) {
+	now := time.Date(2019, 7, 4, 9, 0, 0, 0, time.UTC)
+	organizer := NewOrganizerWithOptions(t.TempDir(), OrganizerOptions{Clock: clock.NewFake(now)})
+
+	// A date before 1990 is unreasonable, so the clock's date is used
+	date := time.Date(1985, 1, 1, 0, 0, 0, 0, time.UTC)
+	validated := organizer.validateDate(&date)
+	if !validated.Equal(now) {
+		t.Errorf("Expected %v, got %v", now, validated)
+	}
+	if validated := organizer.validateDate(nil); !validated.Equal(now) {
+		t.Errorf("Expected %v for a missing date, got %v", now, validated)
+	}
+}
+
+func TestOrganizeFileFutureDatesWithinGrace(t *testing.T) {
+	organizer := NewOrganizerWithOptions(t.TempDir(), OrganizerOptions{FutureDates: FutureDatesFlag})
+
+	// A few hours ahead is timezone skew, not a broken clock
+	ahead := time.Now().Add(3 * time.Hour).UTC()
+	info := organizeTestFile(t, organizer, "IMG_"+ahead.Format("20060102_150405")+".jpg", "just ahead")
+	if dir := filepath.Dir(info.RelativePath); dir == ClockErrorFolder {
+		t.Errorf("Expected a date within the grace period not to be flagged, got %s", dir)
+	}
+}
+
+func TestOrganizeFileClientDate(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+
+	// Both the EXIF and the filename disagree with the client
+	source := filepath.Join(t.TempDir(), "upload_1.tmp")
+	data := buildEXIFJPEG(t, exifFixture{Make: "Canon", DateTimeOriginal: "2022:05:01 09:00:00"})
+	if err := os.WriteFile(source, data, 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	dateTaken := time.Date(2019, 8, 1, 10, 0, 0, 0, time.UTC)
+	info, err := organizer.OrganizeFileWithOptions(context.Background(), source, "IMG_20240315_143022.jpg", OrganizeOptions{DateTaken: &dateTaken})
+	if err != nil {
+		t.Fatalf("OrganizeFileWithOptions failed: %v", err)
+	}
+
+	if info.DateSource != DateSourceUserInput {
+		t.Errorf("Expected date source %s, got %s", DateSourceUserInput, info.DateSource)
+	}
+	if info.DateTaken == nil || !info.DateTaken.Equal(dateTaken) {
+		t.Errorf("Expected date %v, got %v", dateTaken, info.DateTaken)
+	}
+	if expected := filepath.Join("2019", "August", "IMG_20240315_143022.jpg"); info.RelativePath != expected {
+		t.Errorf("Expected %s, got %s", expected, info.RelativePath)
+	}
+}
+
+func TestOrganizeFileDatesUploadByOriginalName(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+
+	// Nothing in the temp file or its name dates it
+	source := filepath.Join(t.TempDir(), "upload_1.tmp")
+	if err := os.WriteFile(source, []byte("small photo"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	info, err := organizer.OrganizeFile(source, "IMG_20240315_143022.jpg")
+	if err != nil {
+		t.Fatalf("OrganizeFile failed: %v", err)
+	}
+
+	if info.DateSource != DateSourceFileName {
+		t.Errorf("Expected date source %s, got %s", DateSourceFileName, info.DateSource)
+	}
+	if expected := filepath.Join("2024", "March", "IMG_20240315_143022.jpg"); info.RelativePath != expected {
+		t.Errorf("Expected %s, got %s", expected, info.RelativePath)
+	}
+}
+
+func TestOrganizeFileColonDelimitedName(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+
+	source := filepath.Join(t.TempDir(), "2023:12:25 14:30:22.jpg")
+	if err := os.WriteFile(source, []byte("exported photo"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	info, err := organizer.OrganizeFile(source, "2023:12:25 14:30:22.jpg")
+	if err != nil {
+		t.Fatalf("OrganizeFile failed: %v", err)
+	}
+
+	expectedDate := time.Date(2023, 12, 25, 14, 30, 22, 0, time.UTC)
+	if info.DateSource != DateSourceFileName || info.DateTaken == nil || !info.DateTaken.Equal(expectedDate) {
+		t.Errorf("Expected date %v from the filename, got %v from %s", expectedDate, info.DateTaken, info.DateSource)
+	}
+	expectedPath := filepath.Join("2023", "December", "2023_12_25 14_30_22.jpg")
+	if info.RelativePath != expectedPath {
+		t.Fatalf("Expected %s, got %s", expectedPath, info.RelativePath)
+	}
+
+	// The sanitized name still dates the stored file
+	stored, err := organizer.Extractor().ExtractMetadata(filepath.Join(mediaDir, expectedPath))
+	if err != nil {
+		t.Fatalf("ExtractMetadata failed: %v", err)
+	}
+	if stored.DateTaken == nil || !stored.DateTaken.Equal(expectedDate) {
+		t.Errorf("Expected stored file dated %v, got %v", expectedDate, stored.DateTaken)
+	}
+}
+
+func TestOrganizeFileRequireDate(t *testing.T) {
+	dateTaken := time.Date(2019, 8, 1, 10, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		requireDate    bool
+		tempName       string
+		fileName       string
+		dateTaken      *time.Time
+		expectedErr    bool
+		expectedSource DateSource
+	}{
+		{"Lenient undated", false, "upload_1.tmp", "holiday.jpg", nil, false, DateSourceFileTime},
+		{"Strict undated", true, "upload_1.tmp", "holiday.jpg", nil, true, ""},
+		{"Strict dated by the client", true, "upload_1.tmp", "holiday.jpg", &dateTaken, false, DateSourceUserInput},
+		// Only the original name counts; a date in the temp name is ignored
+		{"Lenient dated by the temp name only", false, "IMG_20240315_143022.jpg", "holiday.jpg", nil, false, DateSourceFileTime},
+		{"Strict dated by the temp name only", true, "IMG_20240315_143022.jpg", "holiday.jpg", nil, true, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			organizer := NewOrganizer(t.TempDir())
+
+			// Uploads are organized from a temp file named after their session
+			source := filepath.Join(t.TempDir(), test.tempName)
+			if err := os.WriteFile(source, []byte("undated photo"), 0644); err != nil {
+				t.Fatalf("Failed to write file: %v", err)
+			}
+
+			info, err := organizer.OrganizeFileWithOptions(context.Background(), source, test.fileName, OrganizeOptions{
+				DateTaken:   test.dateTaken,
+				RequireDate: test.requireDate,
+			})
+			if test.expectedErr {
+				if !errors.Is(err, ErrDateRequired) {
+					t.Fatalf("Expected %v, got %v", ErrDateRequired, err)
+				}
+				if _, err := os.Stat(source); err != nil {
+					t.Errorf("Expected the file left in place: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("OrganizeFileWithOptions failed: %v", err)
+			}
+			if info.DateSource != test.expectedSource {
+				t.Errorf("Expected date source %s, got %s", test.expectedSource, info.DateSource)
+			}
+		})
+	}
+}
+
+func TestSkipDir(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+
+	tests := []struct {
+		name     string
+		path     string
+		root     string
+		expected bool
+	}{
+		{"Library root", mediaDir, mediaDir, false},
+		{"Month folder", filepath.Join(mediaDir, "2024", "March"), mediaDir, false},
+		{"Upload temp", filepath.Join(mediaDir, "temp"), mediaDir, true},
+		{"Nested temp", filepath.Join(mediaDir, "2024", "temp"), mediaDir, true},
+		{"Trash", filepath.Join(mediaDir, TrashFolder), mediaDir, true},
+		{"Quarantine", filepath.Join(mediaDir, QuarantineFolder), mediaDir, true},
+		{"Hidden", filepath.Join(mediaDir, "2024", ".thumbnails"), mediaDir, true},
+		{"Hidden root", filepath.Join(mediaDir, ".library"), filepath.Join(mediaDir, ".library"), false},
+		{"Temp root", filepath.Join(mediaDir, "temp"), filepath.Join(mediaDir, "temp"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := organizer.skipDir(tt.path, tt.root); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
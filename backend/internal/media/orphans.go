@@ -0,0 +1,186 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Orphan is a media file outside every folder organizing files things into,
+// typically dropped into the library by hand. Date-based browsing never shows
+// it.
+type Orphan struct {
+	RelativePath string    `json:"relativePath"`
+	Size         int64     `json:"size"`
+	ModTime      time.Time `json:"modTime"`
+}
+
+// ListOrphans returns the library's media files that sit neither in a
+// Year/Month or event folder nor in one of the deliberately placed folders
+// (albums, ClockError, Screenshots), sorted by path.
+func (o *Organizer) ListOrphans(ctx context.Context) ([]Orphan, error) {
+	orphans := []Orphan{}
+	err := filepath.Walk(o.mediaPath, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if o.skipDir(path, o.mediaPath) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !o.isMediaFile(path) {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(o.mediaPath, path)
+		if err != nil || !o.isOrphan(relPath) {
+			return nil
+		}
+		orphans = append(orphans, Orphan{
+			RelativePath: filepath.ToSlash(relPath),
+			Size:         info.Size(),
+			ModTime:      info.ModTime(),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orphans: %w", err)
+	}
+
+	sort.Slice(orphans, func(i, j int) bool {
+		return orphans[i].RelativePath < orphans[j].RelativePath
+	})
+	return orphans, nil
+}
+
+// isOrphan reports whether a library-relative file path is outside the
+// folders organizing creates. Anything below a Year/Month or Year/event
+// folder counts as placed, so bursts nested inside them are too.
+func (o *Organizer) isOrphan(relPath string) bool {
+	parts := strings.Split(filepath.ToSlash(relPath), "/")
+	switch parts[0] {
+	case AlbumsFolder, ClockErrorFolder, ScreenshotsFolder:
+		return len(parts) < 2
+	}
+	if len(parts) < 3 {
+		return true
+	}
+	if _, ok := parseYearFolder(parts[0]); !ok {
+		return true
+	}
+	if _, ok := ParseAnyMonthFolder(parts[0], parts[1]); ok {
+		return false
+	}
+	return !isEventFolder(parts[1])
+}
+
+// isEventFolder reports whether name is a folder eventFolderName creates.
+func isEventFolder(name string) bool {
+	first, last, ranged := strings.Cut(name, "_")
+	if _, err := time.Parse("2006-01-02", first); err != nil {
+		return false
+	}
+	if ranged {
+		if _, err := time.Parse("2006-01-02", last); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// ReorganizeOrphans runs every orphan through OrganizeFile, moving it to the
+// folder its date selects. An orphan that folder already holds a copy of is
+// deleted as DeleteRange deletes, through the trash when it is enabled.
+// Failures are reported per file and the rest carry on.
+func (o *Organizer) ReorganizeOrphans(ctx context.Context) ([]RefreshChange, error) {
+	orphans, err := o.ListOrphans(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []RefreshChange{}
+	for _, orphan := range orphans {
+		if err := ctx.Err(); err != nil {
+			return changes, fmt.Errorf("reorganizing orphans aborted: %w", err)
+		}
+
+		change := RefreshChange{From: orphan.RelativePath}
+		path := filepath.Join(o.mediaPath, filepath.FromSlash(orphan.RelativePath))
+
+		// Organizing would remove a duplicate outright; it is a library file,
+		// so it gets the same second chance as any other delete
+		p, err := o.place(ctx, path, filepath.Base(path), OrganizeOptions{})
+		if err == nil && p.duplicate {
+			if o.TrashEnabled() {
+				_, err = o.TrashFile(orphan.RelativePath)
+			} else {
+				err = o.DeleteFile(orphan.RelativePath)
+			}
+			if err == nil {
+				o.metadata.forget(orphan.RelativePath)
+				change.DateTaken = p.info.DateTaken
+				change.DateSource = p.info.DateSource
+				change.Duplicate = true
+				changes = append(changes, change)
+				o.notifyOrganized(OrganizedFile{FileName: filepath.Base(path), From: orphan.RelativePath, Duplicate: true, MediaInfo: p.info})
+				continue
+			}
+		}
+		if err != nil {
+			slog.Error("Failed to reorganize orphan", "error", err, "file", orphan.RelativePath)
+			change.Error = err.Error()
+			changes = append(changes, change)
+			continue
+		}
+
+		info, err := o.OrganizeFileContext(ctx, path, filepath.Base(path))
+		if err != nil {
+			slog.Error("Failed to reorganize orphan", "error", err, "file", orphan.RelativePath)
+			change.Error = err.Error()
+			changes = append(changes, change)
+			continue
+		}
+
+		// Organizing counted the file as new; it was already in the library,
+		// so a moved file takes its old entry, and with it its ID, along
+		if _, hasID := o.checksums.ID(orphan.RelativePath); hasID && info.RelativePath != "" {
+			checksum, _ := o.checksums.Get(info.RelativePath)
+			o.checksums.Delete(info.RelativePath)
+			o.checksums.Move(orphan.RelativePath, info.RelativePath)
+			o.checksums.Set(info.RelativePath, checksum)
+		} else {
+			o.checksums.Delete(orphan.RelativePath)
+		}
+		o.metadata.forget(orphan.RelativePath)
+		o.addLibraryBytes(-orphan.Size)
+		o.pruneEmptyDirs(filepath.Dir(path))
+
+		change.DateTaken = info.DateTaken
+		change.DateSource = info.DateSource
+		if info.RelativePath == "" {
+			change.Duplicate = true
+		} else {
+			change.To = filepath.ToSlash(info.RelativePath)
+		}
+		changes = append(changes, change)
+	}
+
+	if len(changes) > 0 {
+		if err := o.checksums.Save(); err != nil {
+			slog.Error("Failed to save checksum index", "error", err)
+		}
+		o.version.Add(1)
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,118 @@
+package media
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestListAndReorganizeOrphans(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+
+	writeImportFiles(t, mediaDir, map[string]string{
+		"IMG_20240315_143022.jpg":                 "dropped at the root",
+		"2024/IMG_20240316_101500.jpg":            "dropped in a year",
+		"Misc/IMG_20230704_180000.jpg":            "odd folder",
+		"Misc/IMG_20240301_120000.jpg":            "already stored",
+		"2024/March/IMG_20240301_120000.jpg":      "already stored",
+		"2024/2024-03-15/IMG_20240315_090000.jpg": "event",
+		"2024/March/Burst_20240315/IMG_0001.jpg":  "burst",
+		"Albums/Holiday/IMG_20240315_100000.jpg":  "album",
+		"ClockError/IMG_20990101_000000.jpg":      "future",
+		"notes.txt":                               "not media",
+	})
+	if _, err := organizer.LibrarySize(); err != nil {
+		t.Fatalf("Failed to measure library: %v", err)
+	}
+
+	orphans, err := organizer.ListOrphans(context.Background())
+	if err != nil {
+		t.Fatalf("ListOrphans failed: %v", err)
+	}
+	expected := []string{
+		"2024/IMG_20240316_101500.jpg",
+		"IMG_20240315_143022.jpg",
+		"Misc/IMG_20230704_180000.jpg",
+		"Misc/IMG_20240301_120000.jpg",
+	}
+	if len(orphans) != len(expected) {
+		t.Fatalf("Expected orphans %v, got %+v", expected, orphans)
+	}
+	for i, orphan := range orphans {
+		if orphan.RelativePath != expected[i] {
+			t.Errorf("Expected orphan %s, got %s", expected[i], orphan.RelativePath)
+		}
+	}
+
+	changes, err := organizer.ReorganizeOrphans(context.Background())
+	if err != nil {
+		t.Fatalf("ReorganizeOrphans failed: %v", err)
+	}
+	moved := map[string]RefreshChange{}
+	for _, change := range changes {
+		moved[change.From] = change
+	}
+	for from, to := range map[string]string{
+		"IMG_20240315_143022.jpg":      "2024/March/IMG_20240315_143022.jpg",
+		"2024/IMG_20240316_101500.jpg": "2024/March/IMG_20240316_101500.jpg",
+		"Misc/IMG_20230704_180000.jpg": "2023/July/IMG_20230704_180000.jpg",
+	} {
+		if moved[from].To != to {
+			t.Errorf("Expected %s to move to %s, got %+v", from, to, moved[from])
+		}
+		if _, err := os.Stat(filepath.Join(mediaDir, filepath.FromSlash(to))); err != nil {
+			t.Errorf("Expected %s in the library: %v", to, err)
+		}
+	}
+	if !moved["Misc/IMG_20240301_120000.jpg"].Duplicate {
+		t.Errorf("Expected the stored copy to make the orphan a duplicate, got %+v", moved["Misc/IMG_20240301_120000.jpg"])
+	}
+
+	if _, err := os.Stat(filepath.Join(mediaDir, "Misc")); !os.IsNotExist(err) {
+		t.Error("Expected the emptied folder to be pruned")
+	}
+	if orphans, _ := organizer.ListOrphans(context.Background()); len(orphans) != 0 {
+		t.Errorf("Expected no orphans left, got %+v", orphans)
+	}
+
+	size, _ := organizer.LibrarySize()
+	expectedSize := int64(0)
+	filepath.Walk(mediaDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			expectedSize += info.Size()
+		}
+		return nil
+	})
+	if size != expectedSize {
+		t.Errorf("Expected library size %d after reorganizing, got %d", expectedSize, size)
+	}
+}
+
+func TestReorganizeOrphanDuplicateGoesToTrash(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizerWithOptions(mediaDir, OrganizerOptions{TrashRetention: time.Hour})
+
+	writeImportFiles(t, mediaDir, map[string]string{
+		"Misc/IMG_20240301_120000.jpg":       "already stored",
+		"2024/March/IMG_20240301_120000.jpg": "already stored",
+	})
+
+	changes, err := organizer.ReorganizeOrphans(context.Background())
+	if err != nil {
+		t.Fatalf("ReorganizeOrphans failed: %v", err)
+	}
+	if len(changes) != 1 || !changes[0].Duplicate {
+		t.Fatalf("Expected the orphan to be a duplicate, got %+v", changes)
+	}
+
+	trashed, err := organizer.ListTrash()
+	if err != nil {
+		t.Fatalf("ListTrash failed: %v", err)
+	}
+	if len(trashed) != 1 || trashed[0].OriginalPath != "Misc/IMG_20240301_120000.jpg" {
+		t.Errorf("Expected the duplicate orphan in the trash, got %+v", trashed)
+	}
+}
@@ -0,0 +1,230 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrSourceChanged reports a planned file that no longer matches its plan.
+var ErrSourceChanged = errors.New("source changed since the plan was made")
+
+// FilePlan is what organizing a file would do, worked out without touching
+// the file or the library.
+type FilePlan struct {
+	Source     string     `json:"source"`
+	Size       int64      `json:"size"`
+	ModTime    time.Time  `json:"modTime"`
+	Checksum   string     `json:"checksum"`
+	DateTaken  *time.Time `json:"dateTaken,omitempty"`
+	DateSource DateSource `json:"dateSource,omitempty"`
+	TargetPath string     `json:"targetPath,omitempty"` // Library-relative; empty for duplicates
+	Duplicate  bool       `json:"duplicate,omitempty"`
+}
+
+// ImportPlan is a dry run of importing SourceDir, to be reviewed and then
+// handed to Planner.Apply.
+type ImportPlan struct {
+	SourceDir string          `json:"sourceDir"`
+	Strategy  ImportStrategy  `json:"strategy,omitempty"`
+	CreatedAt time.Time       `json:"createdAt"`
+	Files     []FilePlan      `json:"files"`
+	Failed    []ImportFailure `json:"failed"`
+	Events    []ImportEvent   `json:"events,omitempty"`
+	Bursts    []ImportBurst   `json:"bursts,omitempty"`
+}
+
+// PlanFile reports where OrganizeFileWithOptions would store the file at
+// filePath and whether it would be skipped as a duplicate.
+func (o *Organizer) PlanFile(ctx context.Context, filePath, originalFileName string, opts OrganizeOptions) (*FilePlan, error) {
+	return o.planFile(ctx, filePath, originalFileName, opts, nil)
+}
+
+// planFile is PlanFile treating the library-relative paths in claimed as
+// taken, so files planned together don't share a name.
+func (o *Organizer) planFile(ctx context.Context, filePath, originalFileName string, opts OrganizeOptions, claimed map[string]bool) (*FilePlan, error) {
+	stat, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := o.place(ctx, filePath, originalFileName, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &FilePlan{
+		Size:       stat.Size(),
+		ModTime:    stat.ModTime(),
+		Checksum:   p.hash,
+		DateTaken:  p.info.DateTaken,
+		DateSource: p.info.DateSource,
+		Duplicate:  p.duplicate,
+	}
+	if p.duplicate {
+		return plan, nil
+	}
+
+	basePath := filepath.Join(p.targetDir, o.normalizeExtension(o.sanitizeFileName(originalFileName)))
+	for counter := 0; ; counter++ {
+		candidate := numberedPath(basePath, counter)
+		rel, err := filepath.Rel(o.mediaPath, candidate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to plan target path: %w", err)
+		}
+		rel = filepath.ToSlash(rel)
+		if _, err := os.Lstat(candidate); os.IsNotExist(err) && !claimed[rel] {
+			plan.TargetPath = rel
+			return plan, nil
+		}
+	}
+}
+
+// Planner plans imports ahead of time and applies reviewed plans.
+type Planner struct {
+	organizer *Organizer
+}
+
+func NewPlanner(organizer *Organizer) *Planner {
+	return &Planner{organizer: organizer}
+}
+
+// Plan works out what ImportDirectory would do with sourceDir, including
+// event and burst folders, without copying anything.
+func (p *Planner) Plan(ctx context.Context, sourceDir string, opts ImportOptions) (*ImportPlan, error) {
+	o := p.organizer
+
+	items, err := o.collectImportItems(sourceDir)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &ImportPlan{
+		SourceDir: sourceDir,
+		Strategy:  opts.Strategy,
+		CreatedAt: o.clock.Now(),
+		Files:     []FilePlan{},
+		Failed:    []ImportFailure{},
+	}
+	if plan.Events, err = o.assignFolders(items, opts); err != nil {
+		return nil, err
+	}
+	if opts.BurstGap > 0 {
+		plan.Bursts = o.assignBurstFolders(items, opts.BurstGap)
+	}
+
+	claimed := make(map[string]bool)
+	planned := make(map[string]bool) // Folder and checksum of each file the plan stores
+	for _, item := range items {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("planning aborted: %w", err)
+		}
+
+		file, err := o.planFile(ctx, item.path, filepath.Base(item.path), OrganizeOptions{TargetDir: item.targetDir, Subfolder: item.subfolder}, claimed)
+		if err != nil {
+			plan.Failed = append(plan.Failed, ImportFailure{Source: item.source, Error: err.Error()})
+			continue
+		}
+		file.Source = item.source
+
+		// A second copy bound for the same folder is a duplicate of the
+		// first, as the first will be stored by the time it is organized
+		key := filepath.Dir(file.TargetPath) + "|" + file.Checksum
+		if !file.Duplicate && planned[key] {
+			file.Duplicate = true
+		}
+		if file.Duplicate {
+			file.TargetPath = ""
+		} else {
+			claimed[file.TargetPath] = true
+			planned[key] = true
+		}
+		plan.Files = append(plan.Files, *file)
+	}
+
+	return plan, nil
+}
+
+// Apply imports the files in a reviewed plan into the folders it chose. The
+// plan is not trusted: each source must still match the size and modification
+// time it was planned with, the copy actually stored must match the planned
+// checksum, and duplicates are checked afresh. Files added to the source
+// folder since planning are left alone.
+func (p *Planner) Apply(ctx context.Context, plan *ImportPlan) (*ImportResult, error) {
+	o := p.organizer
+
+	result := &ImportResult{
+		Imported: []ImportedFile{},
+		Failed:   []ImportFailure{},
+		Events:   plan.Events,
+		Bursts:   plan.Bursts,
+	}
+
+	stagingDir, err := o.newStagingDir()
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(stagingDir)
+
+	for _, file := range plan.Files {
+		if err := ctx.Err(); err != nil {
+			return result, fmt.Errorf("import aborted: %w", err)
+		}
+
+		if file.Duplicate {
+			result.Imported = append(result.Imported, ImportedFile{Source: file.Source, Duplicate: true})
+			continue
+		}
+
+		item, err := o.verifyPlannedFile(plan.SourceDir, file)
+		if err == nil {
+			var imported *ImportedFile
+			if imported, err = o.importFile(ctx, stagingDir, item); err == nil {
+				result.Imported = append(result.Imported, *imported)
+				continue
+			}
+		}
+		slog.Warn("Failed to import planned file", "error", err, "source", file.Source)
+		result.Failed = append(result.Failed, ImportFailure{Source: file.Source, Error: err.Error()})
+	}
+
+	slog.Info("Import plan applied",
+		"source", plan.SourceDir,
+		"imported", len(result.Imported),
+		"failed", len(result.Failed),
+	)
+
+	return result, nil
+}
+
+// verifyPlannedFile checks that a planned source looks unchanged and returns
+// it as an import item bound for the planned folder. Its checksum is checked
+// by importFile once staged, so an edit made after this check is caught too.
+func (o *Organizer) verifyPlannedFile(sourceDir string, file FilePlan) (*importItem, error) {
+	path, err := ResolveWithin(sourceDir, file.Source)
+	if err != nil {
+		return nil, err
+	}
+	if file.TargetPath == "" {
+		return nil, fmt.Errorf("%w: no target path planned", ErrInvalidPath)
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("source no longer readable: %w", err)
+	}
+	if stat.Size() != file.Size || !stat.ModTime().Equal(file.ModTime) {
+		return nil, ErrSourceChanged
+	}
+
+	return &importItem{
+		source:    file.Source,
+		path:      path,
+		targetDir: filepath.Dir(filepath.FromSlash(file.TargetPath)),
+		checksum:  file.Checksum,
+	}, nil
+}
@@ -0,0 +1,183 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeImportFiles creates files, keyed by path relative to dir.
+func writeImportFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("Failed to create folder for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+	}
+}
+
+func TestPlanMatchesImport(t *testing.T) {
+	mediaDir := t.TempDir()
+	importDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+
+	writeImportFiles(t, mediaDir, map[string]string{"2024/March/IMG_20240301_120000.jpg": "already stored"})
+	writeImportFiles(t, importDir, map[string]string{
+		"a/IMG_20240315_090000.jpg":    "first",
+		"b/IMG_20240315_090000.jpg":    "same name, other shot",
+		"c/IMG_20240315_090000.jpg":    "first",
+		"old/IMG_20240301_120000.jpg":  "already stored",
+		"trip/IMG_20230704_180000.jpg": "fireworks",
+	})
+
+	plan, err := NewPlanner(organizer).Plan(context.Background(), importDir, ImportOptions{})
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+	if len(plan.Failed) != 0 {
+		t.Errorf("Expected no planning failures, got %+v", plan.Failed)
+	}
+
+	expected := map[string]FilePlan{
+		"a/IMG_20240315_090000.jpg":    {TargetPath: "2024/March/IMG_20240315_090000.jpg"},
+		"b/IMG_20240315_090000.jpg":    {TargetPath: "2024/March/IMG_20240315_090000(1).jpg"},
+		"c/IMG_20240315_090000.jpg":    {Duplicate: true},
+		"old/IMG_20240301_120000.jpg":  {Duplicate: true},
+		"trip/IMG_20230704_180000.jpg": {TargetPath: "2023/July/IMG_20230704_180000.jpg"},
+	}
+	if len(plan.Files) != len(expected) {
+		t.Fatalf("Expected %d planned files, got %+v", len(expected), plan.Files)
+	}
+	planned := make(map[string]FilePlan)
+	for _, file := range plan.Files {
+		planned[file.Source] = file
+		want := expected[file.Source]
+		if file.TargetPath != want.TargetPath || file.Duplicate != want.Duplicate {
+			t.Errorf("%s: expected target %q duplicate %v, got %q %v", file.Source, want.TargetPath, want.Duplicate, file.TargetPath, file.Duplicate)
+		}
+		if file.DateSource != DateSourceFileName || file.DateTaken == nil {
+			t.Errorf("%s: expected a filename date, got %v from %q", file.Source, file.DateTaken, file.DateSource)
+		}
+	}
+
+	// Planning leaves the library alone
+	if _, err := os.Stat(filepath.Join(mediaDir, "2023")); !os.IsNotExist(err) {
+		t.Error("Expected planning not to create folders")
+	}
+
+	result, err := organizer.ImportDirectory(context.Background(), importDir, ImportOptions{})
+	if err != nil {
+		t.Fatalf("ImportDirectory failed: %v", err)
+	}
+	for _, imported := range result.Imported {
+		file := planned[imported.Source]
+		if imported.RelativePath != file.TargetPath || imported.Duplicate != file.Duplicate {
+			t.Errorf("%s: planned %q duplicate %v, import gave %q %v", imported.Source, file.TargetPath, file.Duplicate, imported.RelativePath, imported.Duplicate)
+		}
+	}
+}
+
+func TestApplyImportPlan(t *testing.T) {
+	mediaDir := t.TempDir()
+	importDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+	planner := NewPlanner(organizer)
+
+	writeImportFiles(t, importDir, map[string]string{
+		"IMG_20240315_090000.jpg": "kept",
+		"IMG_20240316_090000.jpg": "edited after planning",
+		"IMG_20240317_090000.jpg": "moved by the reviewer",
+	})
+
+	plan, err := planner.Plan(context.Background(), importDir, ImportOptions{})
+	if err != nil {
+		t.Fatalf("Plan failed: %v", err)
+	}
+
+	// The reviewer files one photo elsewhere; meanwhile one source changes
+	// and a new one appears
+	for i := range plan.Files {
+		if plan.Files[i].Source == "IMG_20240317_090000.jpg" {
+			plan.Files[i].TargetPath = "Holidays/IMG_20240317_090000.jpg"
+		}
+	}
+	edited := filepath.Join(importDir, "IMG_20240316_090000.jpg")
+	if err := os.WriteFile(edited, []byte("edited content"), 0644); err != nil {
+		t.Fatalf("Failed to edit source: %v", err)
+	}
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(edited, later, later)
+	writeImportFiles(t, importDir, map[string]string{"IMG_20240318_090000.jpg": "not in the plan"})
+
+	result, err := planner.Apply(context.Background(), plan)
+	if err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+
+	if len(result.Imported) != 2 {
+		t.Fatalf("Expected 2 imported files, got %+v", result.Imported)
+	}
+	for _, path := range []string{"2024/March/IMG_20240315_090000.jpg", "Holidays/IMG_20240317_090000.jpg"} {
+		if _, err := os.Stat(filepath.Join(mediaDir, filepath.FromSlash(path))); err != nil {
+			t.Errorf("Expected %s in the library: %v", path, err)
+		}
+	}
+	if len(result.Failed) != 1 || result.Failed[0].Source != "IMG_20240316_090000.jpg" || result.Failed[0].Error != ErrSourceChanged.Error() {
+		t.Errorf("Expected the edited source to fail as changed, got %+v", result.Failed)
+	}
+	for _, name := range []string{"IMG_20240316_090000.jpg", "IMG_20240318_090000.jpg"} {
+		if _, err := os.Stat(filepath.Join(mediaDir, "2024", "March", name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s not to be imported", name)
+		}
+	}
+}
+
+func TestApplyImportPlanKeepsSourcesInside(t *testing.T) {
+	organizer := NewOrganizer(t.TempDir())
+	importDir := t.TempDir()
+	writeImportFiles(t, filepath.Dir(importDir), map[string]string{"outside.jpg": "not yours"})
+
+	// Sources come back from the client; ".." is clamped to the source folder
+	_, err := organizer.verifyPlannedFile(importDir, FilePlan{Source: "../outside.jpg", TargetPath: "2024/March/outside.jpg"})
+	if !os.IsNotExist(errors.Unwrap(err)) {
+		t.Errorf("Expected the escaping source not to be found, got %v", err)
+	}
+}
+
+func TestImportFileChecksStagedCopy(t *testing.T) {
+	mediaDir := t.TempDir()
+	importDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+	writeImportFiles(t, importDir, map[string]string{"IMG_20240315_090000.jpg": "rewritten"})
+
+	stagingDir, err := organizer.newStagingDir()
+	if err != nil {
+		t.Fatalf("newStagingDir failed: %v", err)
+	}
+	defer os.RemoveAll(stagingDir)
+
+	// The source was rewritten after it was verified against its plan
+	item := &importItem{
+		source:    "IMG_20240315_090000.jpg",
+		path:      filepath.Join(importDir, "IMG_20240315_090000.jpg"),
+		targetDir: filepath.Join("2024", "March"),
+		checksum:  "planned checksum",
+	}
+	if _, err := organizer.importFile(context.Background(), stagingDir, item); !errors.Is(err, ErrSourceChanged) {
+		t.Fatalf("Expected ErrSourceChanged, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(mediaDir, "2024", "March", "IMG_20240315_090000.jpg")); !os.IsNotExist(err) {
+		t.Errorf("Expected the changed file not to be imported")
+	}
+	if entries, _ := os.ReadDir(stagingDir); len(entries) != 0 {
+		t.Errorf("Expected the staged copy to be removed, got %d entries", len(entries))
+	}
+}
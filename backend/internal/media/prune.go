@@ -0,0 +1,103 @@
+package media
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// pruneEmptyDirs removes dir and then each parent that is left empty, stopping
+// at the library root. The upload temp directory is never removed, even when
+// empty, since in-flight uploads rely on it existing.
+func (o *Organizer) pruneEmptyDirs(dir string) {
+	root := filepath.Clean(o.mediaPath)
+	tempDir := filepath.Join(root, "temp")
+
+	for dir = filepath.Clean(dir); ; dir = filepath.Dir(dir) {
+		rel, err := filepath.Rel(root, dir)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return
+		}
+		if dir == tempDir || strings.HasPrefix(dir, tempDir+string(filepath.Separator)) {
+			return
+		}
+
+		// Remove refuses non-empty directories, which ends the walk
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		slog.Debug("Removed empty directory", "path", dir)
+	}
+}
+
+// DeleteFile removes a file from the library along with its checksum, and
+// prunes the month and year folders it leaves empty.
+func (o *Organizer) DeleteFile(relPath string) error {
+	fullPath, err := o.ResolvePath(relPath)
+	if err != nil {
+		return err
+	}
+
+	stat, err := os.Stat(fullPath)
+	if err != nil {
+		return err
+	}
+	if !stat.Mode().IsRegular() {
+		return fmt.Errorf("%w: %q is not a file", ErrInvalidPath, relPath)
+	}
+
+	if err := os.Remove(fullPath); err != nil {
+		return fmt.Errorf("failed to delete file: %w", err)
+	}
+
+	if rel, err := filepath.Rel(o.mediaPath, fullPath); err == nil {
+		o.checksums.Delete(rel)
+		if err := o.checksums.Save(); err != nil {
+			slog.Error("Failed to save checksum index", "error", err)
+		}
+	}
+
+	o.pruneEmptyDirs(filepath.Dir(fullPath))
+	o.addLibraryBytes(-stat.Size())
+	o.version.Add(1)
+
+	return nil
+}
+
+// DeleteRange removes every library file whose date falls in [from, until),
+// moving them to the trash when it is enabled. A file's date is when it was
+// taken, or its modification time when that isn't known. Folders left empty
+// are pruned as each file goes. It returns how many files were removed; on
+// error, the files before it have already gone.
+func (o *Organizer) DeleteRange(from, until time.Time) (int, error) {
+	files, err := o.ScanFiles("", "", math.MaxInt, 0)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, file := range files {
+		date := file.ModTime
+		if file.DateTaken != nil {
+			date = *file.DateTaken
+		}
+		if date.Before(from) || !date.Before(until) {
+			continue
+		}
+
+		if o.TrashEnabled() {
+			_, err = o.TrashFile(file.RelativePath)
+		} else {
+			err = o.DeleteFile(file.RelativePath)
+		}
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete %s: %w", file.RelativePath, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
@@ -0,0 +1,114 @@
+package media
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDeleteFilePrunesEmptyDirectories(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+
+	lonely := organizeTestFile(t, organizer, "IMG_20200101_120000.jpg", "only file of 2020")
+	first := organizeTestFile(t, organizer, "IMG_20240315_143022.jpg", "first of March")
+	organizeTestFile(t, organizer, "IMG_20240316_101500.jpg", "second of March")
+
+	if err := organizer.DeleteFile(lonely.RelativePath); err != nil {
+		t.Fatalf("DeleteFile failed: %v", err)
+	}
+	for _, dir := range []string{filepath.Join("2020", "January"), "2020"} {
+		if _, err := os.Stat(filepath.Join(mediaDir, dir)); !os.IsNotExist(err) {
+			t.Errorf("Expected empty %s to be removed, got %v", dir, err)
+		}
+	}
+
+	if err := organizer.DeleteFile(first.RelativePath); err != nil {
+		t.Fatalf("DeleteFile failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(mediaDir, "2024", "March")); err != nil {
+		t.Errorf("Expected March to remain while it still holds a file: %v", err)
+	}
+
+	if _, err := os.Stat(mediaDir); err != nil {
+		t.Errorf("Library root must never be pruned: %v", err)
+	}
+	if err := organizer.DeleteFile(first.RelativePath); !os.IsNotExist(err) {
+		t.Errorf("Expected not-exist error deleting twice, got %v", err)
+	}
+}
+
+func TestPruneEmptyDirsKeepsTemp(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+
+	nested := filepath.Join(mediaDir, "temp", "import-1")
+	if err := os.MkdirAll(nested, 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+
+	organizer.pruneEmptyDirs(nested)
+	organizer.pruneEmptyDirs(filepath.Join(mediaDir, "temp"))
+
+	if _, err := os.Stat(nested); err != nil {
+		t.Errorf("Expected directories under temp to be left alone: %v", err)
+	}
+}
+
+func TestDeleteRange(t *testing.T) {
+	tests := []struct {
+		name      string
+		retention time.Duration
+	}{
+		{"Deleted outright", 0},
+		{"Moved to the trash", 24 * time.Hour},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			mediaDir := t.TempDir()
+			organizer := NewOrganizerWithOptions(mediaDir, OrganizerOptions{TrashRetention: test.retention})
+
+			before := organizeTestFile(t, organizer, "IMG_20240229_235959.jpg", "last day of February")
+			first := organizeTestFile(t, organizer, "IMG_20240301_000000.jpg", "first moment of March")
+			second := organizeTestFile(t, organizer, "IMG_20240315_143022.jpg", "middle of March")
+			after := organizeTestFile(t, organizer, "IMG_20240401_000000.jpg", "first moment of April")
+
+			from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+			deleted, err := organizer.DeleteRange(from, from.AddDate(0, 1, 0))
+			if err != nil {
+				t.Fatalf("DeleteRange failed: %v", err)
+			}
+			if deleted != 2 {
+				t.Errorf("Expected 2 files deleted, got %d", deleted)
+			}
+
+			for _, gone := range []*MediaInfo{first, second} {
+				if _, err := os.Stat(filepath.Join(mediaDir, gone.RelativePath)); !os.IsNotExist(err) {
+					t.Errorf("Expected %s to be deleted, got %v", gone.RelativePath, err)
+				}
+			}
+			for _, kept := range []*MediaInfo{before, after} {
+				if _, err := os.Stat(filepath.Join(mediaDir, kept.RelativePath)); err != nil {
+					t.Errorf("Expected %s to survive: %v", kept.RelativePath, err)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(mediaDir, "2024", "March")); !os.IsNotExist(err) {
+				t.Errorf("Expected the emptied March folder to be pruned, got %v", err)
+			}
+
+			trashed, err := organizer.ListTrash()
+			if err != nil {
+				t.Fatalf("ListTrash failed: %v", err)
+			}
+			expectedTrashed := 0
+			if test.retention > 0 {
+				expectedTrashed = 2
+			}
+			if len(trashed) != expectedTrashed {
+				t.Errorf("Expected %d files in the trash, got %d", expectedTrashed, len(trashed))
+			}
+		})
+	}
+}
@@ -0,0 +1,186 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image/jpeg"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// QuarantineFolder holds library files that repeatedly failed corruption
+// checks, each next to a note explaining why. Listings skip it.
+const QuarantineFolder = "Quarantine"
+
+// ErrUndecodable marks a file whose content is corrupt, as opposed to one
+// that merely lacks metadata.
+var ErrUndecodable = errors.New("file cannot be decoded")
+
+// ErrQuarantineDisabled is returned by QuarantineCorrupt when QuarantineAfter
+// is zero.
+var ErrQuarantineDisabled = errors.New("quarantine is disabled")
+
+// extractionFailures counts decode failures per library file.
+type extractionFailures struct {
+	mutex  sync.Mutex
+	counts map[string]int
+}
+
+func (f *extractionFailures) add(relPath string) int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.counts == nil {
+		f.counts = make(map[string]int)
+	}
+	f.counts[relPath]++
+	return f.counts[relPath]
+}
+
+func (f *extractionFailures) clear(relPath string) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	delete(f.counts, relPath)
+}
+
+// verifyDecodable reads a JPEG's header and reports ErrUndecodable only when
+// the JPEG decoder finds the data malformed. Features it doesn't support,
+// read errors and other formats say nothing about corruption and pass.
+func verifyDecodable(filePath string) error {
+	if ext := strings.ToLower(filepath.Ext(filePath)); ext != ".jpg" && ext != ".jpeg" {
+		return nil
+	}
+
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	var formatErr jpeg.FormatError
+	if _, err := jpeg.DecodeConfig(file); errors.As(err, &formatErr) {
+		return fmt.Errorf("%w: %v", ErrUndecodable, err)
+	}
+	return nil
+}
+
+// isQuarantineDir reports whether dir is the library's quarantine folder.
+func (o *Organizer) isQuarantineDir(dir string) bool {
+	return dir == filepath.Join(o.mediaPath, QuarantineFolder)
+}
+
+// QuarantineReport is the outcome of a QuarantineCorrupt pass.
+type QuarantineReport struct {
+	Checked     int      `json:"checked"`
+	Failing     []string `json:"failing"`     // Corrupt, with fewer failed passes than QuarantineAfter
+	Quarantined []string `json:"quarantined"` // Moved to QuarantineFolder by this pass
+}
+
+// QuarantineCorrupt checks every JPEG in the library for malformed data and
+// moves those that have failed QuarantineAfter passes in a row to
+// QuarantineFolder. It is the only thing that quarantines: listings never
+// move files, however broken.
+func (o *Organizer) QuarantineCorrupt(ctx context.Context) (*QuarantineReport, error) {
+	if o.quarantineAfter <= 0 {
+		return nil, ErrQuarantineDisabled
+	}
+
+	report := &QuarantineReport{Failing: []string{}, Quarantined: []string{}}
+	var corrupt []string
+	err := filepath.Walk(o.mediaPath, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if o.skipDir(path, o.mediaPath) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !o.isMediaFile(path) {
+			return nil
+		}
+		relPath, err := filepath.Rel(o.mediaPath, path)
+		if err != nil {
+			return nil
+		}
+
+		report.Checked++
+		if verifyDecodable(path) == nil {
+			o.failures.clear(relPath)
+			return nil
+		}
+		corrupt = append(corrupt, relPath)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("corruption check aborted: %w", err)
+	}
+
+	// Moved after the walk so it never sees the quarantine change under it
+	for _, relPath := range corrupt {
+		path := filepath.Join(o.mediaPath, relPath)
+		if o.failures.add(relPath) < o.quarantineAfter {
+			report.Failing = append(report.Failing, filepath.ToSlash(relPath))
+			continue
+		}
+		if err := o.quarantine(path, relPath, verifyDecodable(path)); err != nil {
+			slog.Error("Failed to quarantine file", "error", err, "file", relPath)
+			continue
+		}
+		o.failures.clear(relPath)
+		report.Quarantined = append(report.Quarantined, filepath.ToSlash(relPath))
+	}
+	return report, nil
+}
+
+// quarantine moves a library file to the same relative path under
+// QuarantineFolder and writes "<name>.error.txt" beside it.
+func (o *Organizer) quarantine(path, relPath string, cause error) error {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	targetDir := filepath.Join(o.mediaPath, QuarantineFolder, filepath.Dir(relPath))
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return fmt.Errorf("failed to create quarantine directory: %w", err)
+	}
+
+	finalPath, err := o.claimFinalPath(filepath.Join(targetDir, filepath.Base(path)))
+	if err != nil {
+		return err
+	}
+	if err := o.moveFile(path, finalPath); err != nil {
+		os.Remove(finalPath)
+		return fmt.Errorf("failed to move file: %w", err)
+	}
+	// Walkers skip the quarantine, so it no longer counts toward the library
+	o.addLibraryBytes(-stat.Size())
+
+	note := fmt.Sprintf("Quarantined: %s\nOriginal path: %s\nError: %v\n",
+		o.clock.Now().Format(time.RFC3339), filepath.ToSlash(relPath), cause)
+	if err := os.WriteFile(finalPath+".error.txt", []byte(note), 0644); err != nil {
+		slog.Warn("Failed to write quarantine note", "error", err, "file", finalPath)
+	}
+
+	if quarantinedRel, err := filepath.Rel(o.mediaPath, finalPath); err == nil {
+		o.checksums.Move(relPath, quarantinedRel)
+	}
+	if err := o.checksums.Save(); err != nil {
+		slog.Error("Failed to save checksum index", "error", err)
+	}
+	o.pruneEmptyDirs(filepath.Dir(path))
+	o.version.Add(1)
+
+	slog.Warn("File quarantined after repeated corruption checks", "file", relPath, "error", cause)
+	return nil
+}
@@ -0,0 +1,88 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestQuarantineCorruptMovesCorruptImages(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizerWithOptions(mediaDir, OrganizerOptions{QuarantineAfter: 2})
+
+	monthDir := filepath.Join(mediaDir, "2024", "March")
+	// A valid image with no date anywhere must not be mistaken for corrupt
+	writeTestImage(t, filepath.Join(monthDir, "valid.png"), 8, 8)
+	corrupt := filepath.Join(monthDir, "corrupt.jpg")
+	if err := os.WriteFile(corrupt, []byte("definitely not a jpeg"), 0644); err != nil {
+		t.Fatalf("Failed to create corrupt file: %v", err)
+	}
+	// Arithmetic coding is valid JPEG the decoder merely doesn't support
+	unsupported := filepath.Join(monthDir, "arithmetic.jpg")
+	if err := os.WriteFile(unsupported, []byte{0xFF, 0xD8, 0xFF, 0xC9, 0x00, 0x02}, 0644); err != nil {
+		t.Fatalf("Failed to create unsupported file: %v", err)
+	}
+
+	// Listing never quarantines, however often it runs
+	for i := 0; i < 3; i++ {
+		files, err := organizer.ScanFiles("", "", 100, 0)
+		if err != nil {
+			t.Fatalf("ScanFiles failed: %v", err)
+		}
+		if len(files) != 3 {
+			t.Fatalf("Expected all files listed, got %d", len(files))
+		}
+	}
+
+	// The first failed check only counts
+	report, err := organizer.QuarantineCorrupt(context.Background())
+	if err != nil {
+		t.Fatalf("QuarantineCorrupt failed: %v", err)
+	}
+	if report.Checked != 3 || len(report.Failing) != 1 || len(report.Quarantined) != 0 {
+		t.Fatalf("Expected one failing file after the first check, got %+v", report)
+	}
+
+	report, err = organizer.QuarantineCorrupt(context.Background())
+	if err != nil {
+		t.Fatalf("QuarantineCorrupt failed: %v", err)
+	}
+	if len(report.Quarantined) != 1 || report.Quarantined[0] != "2024/March/corrupt.jpg" {
+		t.Fatalf("Expected corrupt.jpg quarantined, got %+v", report)
+	}
+
+	if _, err := os.Stat(corrupt); !os.IsNotExist(err) {
+		t.Error("Expected corrupt file to leave the library folder")
+	}
+	quarantined := filepath.Join(mediaDir, QuarantineFolder, "2024", "March", "corrupt.jpg")
+	if _, err := os.Stat(quarantined); err != nil {
+		t.Errorf("Expected corrupt file in quarantine: %v", err)
+	}
+	note, err := os.ReadFile(quarantined + ".error.txt")
+	if err != nil {
+		t.Fatalf("Expected quarantine note: %v", err)
+	}
+	if !strings.Contains(string(note), "2024/March/corrupt.jpg") {
+		t.Errorf("Expected note to record the original path, got %q", note)
+	}
+
+	if count, err := organizer.CountFiles("", ""); err != nil || count != 2 {
+		t.Errorf("Expected quarantine excluded from counts, got %d (%v)", count, err)
+	}
+	for _, kept := range []string{filepath.Join(monthDir, "valid.png"), unsupported} {
+		if _, err := os.Stat(kept); err != nil {
+			t.Errorf("Expected %s to stay put: %v", filepath.Base(kept), err)
+		}
+	}
+}
+
+func TestQuarantineDisabled(t *testing.T) {
+	organizer := NewOrganizer(t.TempDir())
+
+	if _, err := organizer.QuarantineCorrupt(context.Background()); !errors.Is(err, ErrQuarantineDisabled) {
+		t.Errorf("Expected %v, got %v", ErrQuarantineDisabled, err)
+	}
+}
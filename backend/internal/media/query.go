@@ -0,0 +1,164 @@
+package media
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Query is a parsed library search. A search without any field:value term is
+// a single phrase, matched as a case-insensitive substring of the name,
+// camera, location or caption, so "canon eos" and "Lisbon, Portugal" mean
+// what they always have. A search with at least one field:value term uses
+// the structured grammar
+//
+//	query = term { " " term }
+//	term  = [ field ":" ] value
+//	value = word | '"' text '"'
+//
+// where field is one of name, camera, location, caption, type, year or
+// path. A term without a field is free text, matched against the name,
+// camera, location, caption and capture date. Every term must match; a value
+// may list alternatives separated by commas, any of which may match, so
+//
+//	camera:canon,nikon type:photo 2024
+//
+// finds photos from either brand that mention 2024. Matching is a
+// case-insensitive substring test, except type and year, which must match
+// exactly; type takes a listing type (image, video or document), with photo
+// accepted for image. An unknown field such as "foo:bar" is searched as free
+// text, so a search whose only colons are unknown fields stays a phrase.
+type Query struct {
+	phrase string // Set when the search has no field:value term
+	terms  []queryTerm
+}
+
+type queryTerm struct {
+	field  string // Empty for free text
+	values []string
+}
+
+var queryFields = map[string]bool{
+	"name":     true,
+	"camera":   true,
+	"location": true,
+	"caption":  true,
+	"type":     true,
+	"year":     true,
+	"path":     true,
+}
+
+// ParseQuery parses a search as a phrase or in the structured Query grammar.
+// It never fails: stray quotes run to the end of the query and empty terms
+// are ignored.
+func ParseQuery(s string) Query {
+	tokens := tokenizeQuery(s)
+	if !slices.ContainsFunc(tokens, func(token string) bool { return queryField(token) != "" }) {
+		return Query{phrase: s}
+	}
+
+	var q Query
+	for _, token := range tokens {
+		field, value := queryField(token), token
+		if field != "" {
+			value = token[len(field)+1:]
+		}
+
+		var values []string
+		for _, alternative := range strings.Split(value, ",") {
+			if alternative = strings.ToLower(strings.TrimSpace(alternative)); alternative != "" {
+				values = append(values, alternative)
+			}
+		}
+		if len(values) > 0 {
+			q.terms = append(q.terms, queryTerm{field: field, values: values})
+		}
+	}
+	return q
+}
+
+// queryField returns the lower-cased field of a field:value token, or "" for
+// free text.
+func queryField(token string) string {
+	if name, _, ok := strings.Cut(token, ":"); ok && queryFields[strings.ToLower(name)] {
+		return strings.ToLower(name)
+	}
+	return ""
+}
+
+// tokenizeQuery splits on whitespace outside double quotes, dropping the
+// quotes, so camera:"EOS R5" is one token.
+func tokenizeQuery(s string) []string {
+	var tokens []string
+	var token strings.Builder
+	quoted := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case unicode.IsSpace(r) && !quoted:
+			if token.Len() > 0 {
+				tokens = append(tokens, token.String())
+				token.Reset()
+			}
+		default:
+			token.WriteRune(r)
+		}
+	}
+	if token.Len() > 0 {
+		tokens = append(tokens, token.String())
+	}
+	return tokens
+}
+
+// Matches reports whether the phrase, or every term of the query, matches
+// the file.
+func (q Query) Matches(f *MediaFileInfo) bool {
+	if q.phrase != "" {
+		return f.MatchesQuery(q.phrase)
+	}
+	for _, term := range q.terms {
+		if !term.matches(f) {
+			return false
+		}
+	}
+	return true
+}
+
+func (t queryTerm) matches(f *MediaFileInfo) bool {
+	for _, value := range t.values {
+		if t.matchesValue(f, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (t queryTerm) matchesValue(f *MediaFileInfo, value string) bool {
+	contains := func(field string) bool {
+		return strings.Contains(strings.ToLower(field), value)
+	}
+
+	switch t.field {
+	case "name":
+		return contains(f.FileName)
+	case "camera":
+		return contains(f.Camera)
+	case "location":
+		return contains(f.Location)
+	case "caption":
+		return contains(f.Caption)
+	case "path":
+		return contains(f.RelativePath)
+	case "type":
+		if value == "photo" {
+			value = "image"
+		}
+		return strings.EqualFold(f.MediaType, value)
+	case "year":
+		return f.DateTaken != nil && strconv.Itoa(f.DateTaken.Year()) == value
+	default:
+		return f.MatchesQuery(value) || (f.DateTaken != nil && contains(f.DateTaken.Format("2006-01-02")))
+	}
+}
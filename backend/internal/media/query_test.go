@@ -0,0 +1,66 @@
+package media
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQueryMatches(t *testing.T) {
+	date := func(year int) *time.Time {
+		d := time.Date(year, 3, 15, 14, 30, 22, 0, time.UTC)
+		return &d
+	}
+	files := []MediaFileInfo{
+		{FileName: "IMG_0001.jpg", RelativePath: "2024/March/IMG_0001.jpg", MediaType: "image", Camera: "Canon EOS R5", DateTaken: date(2024)},
+		{FileName: "IMG_0002.jpg", RelativePath: "2023/March/IMG_0002.jpg", MediaType: "image", Camera: "Canon EOS R5", DateTaken: date(2023)},
+		{FileName: "MVI_0003.mp4", RelativePath: "2024/March/MVI_0003.mp4", MediaType: "video", Camera: "Canon EOS R5", DateTaken: date(2024)},
+		{FileName: "DSC_0004.jpg", RelativePath: "2024/March/DSC_0004.jpg", MediaType: "image", Camera: "Nikon Z6", Location: "Lisbon, Portugal", DateTaken: date(2024)},
+		{FileName: "beach 2024.png", RelativePath: "2022/July/beach 2024.png", MediaType: "image", Caption: "Sunset at the beach"},
+	}
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{"Empty query matches everything", "", []string{"IMG_0001.jpg", "IMG_0002.jpg", "MVI_0003.mp4", "DSC_0004.jpg", "beach 2024.png"}},
+		{"Camera, type and free text combine with AND", "camera:Canon type:photo 2024", []string{"IMG_0001.jpg"}},
+		{"Plain search is a phrase", "2024", []string{"beach 2024.png"}},
+		{"Phrase keeps its spaces", "canon eos", []string{"IMG_0001.jpg", "IMG_0002.jpg", "MVI_0003.mp4"}},
+		{"Phrase keeps its commas", "Lisbon, Portugal", []string{"DSC_0004.jpg"}},
+		{"Free text matches the capture date or name", "type:photo 2024", []string{"IMG_0001.jpg", "DSC_0004.jpg", "beach 2024.png"}},
+		{"Commas are alternatives", "camera:canon,nikon year:2024 type:photo", []string{"IMG_0001.jpg", "DSC_0004.jpg"}},
+		{"Quoted value", `camera:"eos r5" type:video`, []string{"MVI_0003.mp4"}},
+		{"Quoted free text", `type:photo "at the beach"`, []string{"beach 2024.png"}},
+		{"Type matches exactly", "type:imag", nil},
+		{"Image by its listing type", "type:image camera:nikon", []string{"DSC_0004.jpg"}},
+		{"Year needs a capture date", "year:2024 type:photo", []string{"IMG_0001.jpg", "DSC_0004.jpg"}},
+		{"Location and path", "location:lisbon path:2024/", []string{"DSC_0004.jpg"}},
+		{"Field names ignore case", "CAMERA:nikon", []string{"DSC_0004.jpg"}},
+		{"Unknown field is a phrase", "lens:50mm", nil},
+		{"Every free text word must match", "type:video canon mvi", []string{"MVI_0003.mp4"}},
+		{"Every free text word must match, with no match", "type:photo canon video", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			query := ParseQuery(test.query)
+
+			var matched []string
+			for i := range files {
+				if query.Matches(&files[i]) {
+					matched = append(matched, files[i].FileName)
+				}
+			}
+			if len(matched) != len(test.expected) {
+				t.Fatalf("Expected %v, got %v", test.expected, matched)
+			}
+			for i := range matched {
+				if matched[i] != test.expected[i] {
+					t.Errorf("Expected %v, got %v", test.expected, matched)
+					break
+				}
+			}
+		})
+	}
+}
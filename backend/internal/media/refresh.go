@@ -0,0 +1,181 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+type RefreshOptions struct {
+	Apply bool // Move files; otherwise only report what would change
+}
+
+type RefreshChange struct {
+	From       string     `json:"from"`
+	To         string     `json:"to,omitempty"` // Empty when the file was a duplicate and removed
+	DateTaken  *time.Time `json:"dateTaken"`
+	DateSource DateSource `json:"dateSource"`
+	Duplicate  bool       `json:"duplicate,omitempty"` // An identical file already sits in the new folder
+	Error      string     `json:"error,omitempty"`
+}
+
+type RefreshReport struct {
+	DryRun  bool            `json:"dryRun"`
+	Scanned int             `json:"scanned"`
+	Changes []RefreshChange `json:"changes"`
+}
+
+// RefreshMetadata re-extracts the date of every file in a Year/Month folder
+// and re-files those whose date now belongs to a different month, so better
+// patterns or new EXIF support fix files already in the library. Files in
+// other folders (events, bursts, ClockError) were placed deliberately and are
+// left alone, as are files that would only be dated by file time again. A
+// date a user supplied still outranks the file's own.
+func (o *Organizer) RefreshMetadata(ctx context.Context, opts RefreshOptions) (*RefreshReport, error) {
+	report := &RefreshReport{DryRun: !opts.Apply, Changes: []RefreshChange{}}
+
+	var paths []string
+	err := filepath.Walk(o.mediaPath, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if o.skipDir(path, o.mediaPath) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if o.isMediaFile(path) && o.isMonthFolder(filepath.Dir(path)) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("metadata refresh aborted: %w", err)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return report, fmt.Errorf("metadata refresh aborted: %w", err)
+		}
+		report.Scanned++
+
+		info, err := o.extractor.ExtractMetadata(path)
+		if err != nil {
+			slog.Warn("Failed to re-extract metadata", "error", err, "file", path)
+			continue
+		}
+		rel, _ := filepath.Rel(o.mediaPath, path)
+		o.applyUserDate(rel, info)
+		if o.extractor.NeedsUserInput(info) {
+			continue
+		}
+
+		targetDir, err := o.getTargetDirectory(info.DateTaken)
+		if err != nil || targetDir == filepath.Dir(path) {
+			continue
+		}
+
+		from, _ := filepath.Rel(o.mediaPath, path)
+		change := RefreshChange{
+			From:       filepath.ToSlash(from),
+			DateTaken:  info.DateTaken,
+			DateSource: info.DateSource,
+		}
+		if !opts.Apply {
+			to, _ := filepath.Rel(o.mediaPath, filepath.Join(targetDir, filepath.Base(path)))
+			change.To = filepath.ToSlash(to)
+		} else if err := o.refile(ctx, path, targetDir, &change); err != nil {
+			slog.Error("Failed to re-file", "error", err, "file", path)
+			change.Error = err.Error()
+		}
+		report.Changes = append(report.Changes, change)
+	}
+
+	if opts.Apply && len(report.Changes) > 0 {
+		if err := o.checksums.Save(); err != nil {
+			slog.Error("Failed to save checksum index", "error", err)
+		}
+		o.version.Add(1)
+	}
+
+	return report, nil
+}
+
+// refile moves a library file into targetDir, or removes it when targetDir
+// already holds an identical copy.
+func (o *Organizer) refile(ctx context.Context, path, targetDir string, change *RefreshChange) error {
+	hash, err := o.calculateFileHash(path)
+	if err != nil {
+		return fmt.Errorf("failed to hash file: %w", err)
+	}
+
+	duplicate, err := o.findDuplicate(ctx, path, hash, targetDir)
+	if err != nil {
+		return fmt.Errorf("failed to check for duplicates: %w", err)
+	}
+
+	fromRel, _ := filepath.Rel(o.mediaPath, path)
+	if duplicate {
+		stat, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return fmt.Errorf("failed to remove duplicate: %w", err)
+		}
+		o.checksums.Delete(fromRel)
+		o.addLibraryBytes(-stat.Size())
+		o.pruneEmptyDirs(filepath.Dir(path))
+		change.Duplicate = true
+		o.notifyOrganized(OrganizedFile{FileName: filepath.Base(path), From: filepath.ToSlash(fromRel), Duplicate: true})
+		return nil
+	}
+
+	if err := os.MkdirAll(targetDir, 0755); err != nil {
+		return fmt.Errorf("failed to create target directory: %w", err)
+	}
+	finalPath, err := o.claimFinalPath(filepath.Join(targetDir, filepath.Base(path)))
+	if err != nil {
+		o.pruneEmptyDirs(targetDir)
+		return err
+	}
+	if err := o.moveFile(path, finalPath); err != nil {
+		os.Remove(finalPath)
+		o.pruneEmptyDirs(targetDir)
+		return fmt.Errorf("failed to move file: %w", err)
+	}
+
+	toRel, _ := filepath.Rel(o.mediaPath, finalPath)
+	o.checksums.Move(fromRel, toRel)
+	o.checksums.Set(toRel, hash)
+	o.pruneEmptyDirs(filepath.Dir(path))
+	change.To = filepath.ToSlash(toRel)
+	o.notifyOrganized(OrganizedFile{FileName: filepath.Base(path), RelativePath: change.To, From: filepath.ToSlash(fromRel)})
+	return nil
+}
+
+// isMonthFolder reports whether dir is a <media>/YYYY/Month folder created by
+// date-based organization under any month format, so refreshing also moves
+// files out of folders made under an earlier MONTH_FORMAT.
+func (o *Organizer) isMonthFolder(dir string) bool {
+	rel, err := filepath.Rel(o.mediaPath, dir)
+	if err != nil {
+		return false
+	}
+	year, month := filepath.Split(rel)
+	year = filepath.Clean(year)
+	if filepath.Dir(year) != "." {
+		return false
+	}
+	_, ok := ParseAnyMonthFolder(year, month)
+	return ok
+}
@@ -0,0 +1,146 @@
+package media
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRefreshMetadataRefilesAfterNewPattern(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+
+	// No built-in pattern understands dotted dates, so the file is dated by
+	// its modification time
+	source := filepath.Join(t.TempDir(), "holiday 2024.03.15.jpg")
+	if err := os.WriteFile(source, []byte("holiday photo"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+	modTime := time.Date(2023, 1, 10, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(source, modTime, modTime); err != nil {
+		t.Fatalf("Failed to set modification time: %v", err)
+	}
+	info, err := organizer.OrganizeFile(source, "holiday 2024.03.15.jpg")
+	if err != nil {
+		t.Fatalf("OrganizeFile failed: %v", err)
+	}
+	oldPath := filepath.Join("2023", "January", "holiday 2024.03.15.jpg")
+	if info.RelativePath != oldPath {
+		t.Fatalf("Expected file at %s, got %s", oldPath, info.RelativePath)
+	}
+
+	// Nothing to change until the extractor learns the pattern
+	report, err := organizer.RefreshMetadata(context.Background(), RefreshOptions{})
+	if err != nil {
+		t.Fatalf("RefreshMetadata failed: %v", err)
+	}
+	if report.Scanned != 1 || len(report.Changes) != 0 {
+		t.Fatalf("Expected 1 file scanned and no changes, got %+v", report)
+	}
+
+	organizer.extractor.addCustomFilenamePatterns([]string{`(\d{4})\.(\d{2})\.(\d{2})`})
+	newPath := filepath.Join("2024", "March", "holiday 2024.03.15.jpg")
+
+	// Dry run reports the move without making it
+	report, err = organizer.RefreshMetadata(context.Background(), RefreshOptions{})
+	if err != nil {
+		t.Fatalf("RefreshMetadata failed: %v", err)
+	}
+	if !report.DryRun || len(report.Changes) != 1 {
+		t.Fatalf("Expected one planned change, got %+v", report)
+	}
+	if change := report.Changes[0]; change.From != filepath.ToSlash(oldPath) || change.To != filepath.ToSlash(newPath) {
+		t.Errorf("Expected %s -> %s, got %+v", oldPath, newPath, change)
+	}
+	if _, err := os.Stat(filepath.Join(mediaDir, oldPath)); err != nil {
+		t.Errorf("Expected dry run to leave the file in place: %v", err)
+	}
+
+	report, err = organizer.RefreshMetadata(context.Background(), RefreshOptions{Apply: true})
+	if err != nil {
+		t.Fatalf("RefreshMetadata failed: %v", err)
+	}
+	if report.DryRun || len(report.Changes) != 1 || report.Changes[0].Error != "" {
+		t.Fatalf("Expected one applied change, got %+v", report)
+	}
+	if _, err := os.Stat(filepath.Join(mediaDir, newPath)); err != nil {
+		t.Errorf("Expected file re-filed to %s: %v", newPath, err)
+	}
+	if _, err := os.Stat(filepath.Join(mediaDir, "2023")); !os.IsNotExist(err) {
+		t.Error("Expected the emptied 2023 folder to be pruned")
+	}
+	if _, ok := organizer.checksums.Get(newPath); !ok {
+		t.Error("Expected checksum to follow the file")
+	}
+}
+
+func TestRefreshMetadataDropsDuplicates(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+
+	organizeTestFile(t, organizer, "IMG_20240315_143022.jpg", "same photo")
+
+	// The same photo filed under the wrong month by hand
+	misplaced := filepath.Join(mediaDir, "2023", "May", "IMG_20240315_143022.jpg")
+	if err := os.MkdirAll(filepath.Dir(misplaced), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(misplaced, []byte("same photo"), 0644); err != nil {
+		t.Fatalf("Failed to create test file: %v", err)
+	}
+
+	report, err := organizer.RefreshMetadata(context.Background(), RefreshOptions{Apply: true})
+	if err != nil {
+		t.Fatalf("RefreshMetadata failed: %v", err)
+	}
+	if len(report.Changes) != 1 || !report.Changes[0].Duplicate {
+		t.Fatalf("Expected the misplaced copy to be reported as a duplicate, got %+v", report.Changes)
+	}
+	if _, err := os.Stat(misplaced); !os.IsNotExist(err) {
+		t.Error("Expected the duplicate to be removed")
+	}
+	if _, err := os.Stat(filepath.Join(mediaDir, "2024", "March", "IMG_20240315_143022.jpg")); err != nil {
+		t.Errorf("Expected the original to remain: %v", err)
+	}
+}
+
+func TestRefreshMetadataKeepsUserDate(t *testing.T) {
+	mediaDir := t.TempDir()
+	indexPath := filepath.Join(t.TempDir(), "checksums.json")
+	organizer := NewOrganizerWithOptions(mediaDir, OrganizerOptions{ChecksumIndexPath: indexPath})
+
+	// The user knows better than the filename
+	source := filepath.Join(t.TempDir(), "IMG_20240315_143022.jpg")
+	if err := os.WriteFile(source, []byte("scanned print"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	dateTaken := time.Date(1998, 7, 4, 12, 0, 0, 0, time.UTC)
+	info, err := organizer.OrganizeFileWithOptions(context.Background(), source, "IMG_20240315_143022.jpg", OrganizeOptions{DateTaken: &dateTaken})
+	if err != nil {
+		t.Fatalf("OrganizeFileWithOptions failed: %v", err)
+	}
+	expectedPath := filepath.Join("1998", "July", "IMG_20240315_143022.jpg")
+	if info.RelativePath != expectedPath {
+		t.Fatalf("Expected %s, got %s", expectedPath, info.RelativePath)
+	}
+
+	// The date is persisted, so a restarted organizer still honours it
+	organizer = NewOrganizerWithOptions(mediaDir, OrganizerOptions{ChecksumIndexPath: indexPath})
+	report, err := organizer.RefreshMetadata(context.Background(), RefreshOptions{Apply: true})
+	if err != nil {
+		t.Fatalf("RefreshMetadata failed: %v", err)
+	}
+	if len(report.Changes) != 0 {
+		t.Errorf("Expected no changes, got %+v", report.Changes)
+	}
+
+	files, err := organizer.ScanFiles("1998", "July", 10, 0)
+	if err != nil {
+		t.Fatalf("ScanFiles failed: %v", err)
+	}
+	if len(files) != 1 || files[0].DateTaken == nil || !files[0].DateTaken.Equal(dateTaken) {
+		t.Errorf("Expected the listing to show the user's date, got %+v", files)
+	}
+}
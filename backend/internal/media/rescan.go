@@ -0,0 +1,101 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+type RescanOptions struct {
+	Progress func(RescanProgress) // Called after each file from the scanning goroutine
+}
+
+type RescanProgress struct {
+	Processed int    `json:"processed"`
+	Total     int    `json:"total"`
+	Path      string `json:"path"`            // File just processed, relative to the library
+	Error     string `json:"error,omitempty"` // Why metadata extraction failed for Path
+}
+
+type RescanResult struct {
+	Total  int      `json:"total"`
+	Failed []string `json:"failed"` // Files whose metadata could not be extracted
+	Bytes  int64    `json:"bytes"`
+}
+
+// Rescan re-extracts metadata for every media file in the library, replacing
+// the cached index, and re-measures the library size. Files are counted before
+// any are processed so progress can be reported against a known total.
+func (o *Organizer) Rescan(ctx context.Context, opts RescanOptions) (*RescanResult, error) {
+	type scanEntry struct {
+		path    string
+		relPath string
+	}
+
+	var (
+		entries []scanEntry
+		bytes   int64
+	)
+	mark, marked := o.markLibrarySize()
+	err := filepath.Walk(o.mediaPath, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if o.skipDir(path, o.mediaPath) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		bytes += info.Size()
+		if !o.isMediaFile(path) {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(o.mediaPath, path)
+		if err != nil {
+			return nil
+		}
+		entries = append(entries, scanEntry{path: path, relPath: relPath})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("rescan aborted: %w", err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].relPath < entries[j].relPath })
+
+	// Start from an empty index so entries for files removed behind our back
+	// don't linger
+	o.metadata.reset()
+
+	result := &RescanResult{Total: len(entries), Failed: []string{}, Bytes: bytes}
+	for i, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, fmt.Errorf("rescan aborted: %w", err)
+		}
+
+		progress := RescanProgress{Processed: i + 1, Total: len(entries), Path: filepath.ToSlash(entry.relPath)}
+
+		fileInfo, err := os.Stat(entry.path)
+		if err == nil {
+			_, err = o.metadataFor(entry.path, entry.relPath, fileInfo)
+		}
+		if err != nil {
+			result.Failed = append(result.Failed, progress.Path)
+			progress.Error = err.Error()
+		}
+
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+	}
+
+	o.settleLibrarySize(mark, marked, bytes)
+	o.version.Add(1)
+	return result, nil
+}
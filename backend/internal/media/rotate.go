@@ -0,0 +1,294 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidRotation   = errors.New("rotation must be 90, 180 or 270 degrees")
+	ErrRotateUnsupported = errors.New("only JPEG and PNG images can be rotated")
+)
+
+// Rotation methods reported by Rotate.
+const (
+	RotatedOrientation = "orientation" // EXIF orientation tag rewritten; lossless
+	RotatedPixels      = "pixels"      // Image re-encoded with its pixels turned
+)
+
+// exifOrientations maps each EXIF orientation to the transform it applies
+// for display, as the matrix [a b c d] taking (x, y) to (ax+by, cx+dy) with
+// y pointing down.
+var exifOrientations = map[uint16][4]int{
+	1: {1, 0, 0, 1},   // As stored
+	2: {-1, 0, 0, 1},  // Mirrored horizontally
+	3: {-1, 0, 0, -1}, // Rotated 180°
+	4: {1, 0, 0, -1},  // Mirrored vertically
+	5: {0, 1, 1, 0},   // Transposed
+	6: {0, -1, 1, 0},  // Rotated 90° clockwise
+	7: {0, -1, -1, 0}, // Transversed
+	8: {0, 1, -1, 0},  // Rotated 270° clockwise
+}
+
+// rotatedOrientation returns the orientation that displays like current
+// followed by a further clockwise turn of degrees.
+func rotatedOrientation(current uint16, degrees int) uint16 {
+	t := exifOrientations[current]
+	for ; degrees > 0; degrees -= 90 {
+		t = [4]int{-t[2], -t[3], t[0], t[1]} // (x, y) -> (-y, x) applied after t
+	}
+	for orientation, matrix := range exifOrientations {
+		if matrix == t {
+			return orientation
+		}
+	}
+	return 1
+}
+
+// Rotate turns the library image at relPath clockwise by degrees and reports
+// how. A JPEG carrying an EXIF orientation tag only has that tag rewritten,
+// leaving the image data untouched; other JPEGs and PNGs are re-encoded, with
+// a JPEG's metadata segments carried over. The modification time moves on by
+// a second: enough for HTTP caches and the thumbnail cache to see a new file,
+// without shifting file-time dating.
+func (o *Organizer) Rotate(relPath string, degrees int) (string, error) {
+	if degrees != 90 && degrees != 180 && degrees != 270 {
+		return "", ErrInvalidRotation
+	}
+
+	fullPath, err := o.ResolvePath(relPath)
+	if err != nil {
+		return "", err
+	}
+	stat, err := os.Stat(fullPath)
+	if err != nil {
+		return "", err
+	}
+	if !stat.Mode().IsRegular() {
+		return "", fmt.Errorf("%w: %q is not a file", ErrInvalidPath, relPath)
+	}
+
+	ext := strings.ToLower(filepath.Ext(fullPath))
+	if ext != ".jpg" && ext != ".jpeg" && ext != ".png" {
+		return "", ErrRotateUnsupported
+	}
+
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		return "", err
+	}
+
+	method := RotatedPixels
+	var rotated []byte
+	if offset, order, current, ok := findEXIFOrientation(data); ok {
+		method = RotatedOrientation
+		rotated = bytes.Clone(data)
+		order.PutUint16(rotated[offset:], rotatedOrientation(current, degrees))
+	} else if rotated, err = rotatePixels(data, ext, degrees); err != nil {
+		return "", err
+	}
+
+	// The rewrite changes the thumbnail's cache key, so look it up first; it is
+	// dropped afterwards so a concurrent request can't cache the old pixels
+	var staleThumbnail string
+	if o.thumbnailer != nil {
+		staleThumbnail, _, _ = o.thumbnailer.CachedPath(fullPath)
+	}
+	modTime := stat.ModTime().Truncate(time.Second).Add(time.Second)
+	if err := replaceFile(fullPath, rotated, stat.Mode(), modTime); err != nil {
+		return "", fmt.Errorf("failed to write rotated image: %w", err)
+	}
+	if staleThumbnail != "" {
+		o.thumbnailer.evict(staleThumbnail)
+	}
+
+	if rel, err := filepath.Rel(o.mediaPath, fullPath); err == nil {
+		o.metadata.forget(rel)
+		if hash, err := o.calculateFileHash(fullPath); err == nil {
+			o.checksums.Set(rel, hash)
+			if err := o.checksums.Save(); err != nil {
+				slog.Error("Failed to save checksum index", "error", err)
+			}
+		}
+	}
+	o.addLibraryBytes(int64(len(rotated)) - stat.Size())
+	o.version.Add(1)
+
+	return method, nil
+}
+
+// findEXIFOrientation locates the orientation tag in a JPEG's EXIF, returning
+// the offset of its value within data, the byte order to write it in and its
+// current value.
+func findEXIFOrientation(data []byte) (int, binary.ByteOrder, uint16, bool) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 0, nil, 0, false
+	}
+
+	for pos := 2; pos+4 <= len(data) && data[pos] == 0xFF; {
+		marker := data[pos+1]
+		if marker == 0xDA || marker == 0xD9 { // Image data reached; no EXIF before it
+			break
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		segment := pos + 4
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			break
+		}
+
+		if marker == 0xE1 && bytes.HasPrefix(data[segment:end], []byte("Exif\x00\x00")) {
+			tiff := segment + 6
+			if offset, order, value, ok := orientationInTIFF(data[tiff:end]); ok {
+				return tiff + offset, order, value, true
+			}
+			return 0, nil, 0, false
+		}
+		pos = end
+	}
+	return 0, nil, 0, false
+}
+
+// orientationInTIFF finds the orientation tag in IFD0 of a TIFF block.
+func orientationInTIFF(tiff []byte) (int, binary.ByteOrder, uint16, bool) {
+	if len(tiff) < 8 {
+		return 0, nil, 0, false
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0, nil, 0, false
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 0, nil, 0, false
+	}
+	for i := 0; i < int(order.Uint16(tiff[ifd:])); i++ {
+		entry := ifd + 2 + 12*i
+		if entry+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[entry:]) != 0x0112 {
+			continue
+		}
+		// A single SHORT, stored inline in the value field
+		if order.Uint16(tiff[entry+2:]) != 3 || order.Uint32(tiff[entry+4:]) != 1 {
+			return 0, nil, 0, false
+		}
+		value := order.Uint16(tiff[entry+8:])
+		if _, known := exifOrientations[value]; !known {
+			value = 1
+		}
+		return entry + 8, order, value, true
+	}
+	return 0, nil, 0, false
+}
+
+// rotatePixels decodes the image, turns it clockwise and re-encodes it in
+// its own format.
+func rotatePixels(data []byte, ext string, degrees int) ([]byte, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	img = rotateImage(img, degrees)
+
+	var out bytes.Buffer
+	if ext == ".png" {
+		if err := png.Encode(&out, img); err != nil {
+			return nil, fmt.Errorf("failed to encode image: %w", err)
+		}
+		return out.Bytes(), nil
+	}
+
+	if err := jpeg.Encode(&out, img, &jpeg.Options{Quality: 95}); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	// Carry EXIF and other metadata over so dates and GPS survive
+	encoded := out.Bytes()
+	return append(append([]byte{0xFF, 0xD8}, jpegMetadataSegments(data)...), encoded[2:]...), nil
+}
+
+// rotateImage returns img turned clockwise by a multiple of 90 degrees.
+func rotateImage(img image.Image, degrees int) image.Image {
+	bounds := img.Bounds()
+	w, h := bounds.Dx(), bounds.Dy()
+	if degrees != 180 {
+		w, h = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			var dx, dy int
+			switch degrees {
+			case 90:
+				dx, dy = bounds.Dy()-1-y, x
+			case 180:
+				dx, dy = bounds.Dx()-1-x, bounds.Dy()-1-y
+			default: // 270
+				dx, dy = y, bounds.Dx()-1-x
+			}
+			dst.Set(dx, dy, img.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+	return dst
+}
+
+// jpegMetadataSegments returns the raw APPn and comment segments at the head
+// of a JPEG.
+func jpegMetadataSegments(data []byte) []byte {
+	var segments []byte
+	for pos := 2; pos+4 <= len(data) && data[pos] == 0xFF; {
+		marker := data[pos+1]
+		end := pos + 2 + int(binary.BigEndian.Uint16(data[pos+2:]))
+		if marker == 0xDA || end > len(data) {
+			break
+		}
+		if (marker >= 0xE1 && marker <= 0xEF) || marker == 0xFE {
+			segments = append(segments, data[pos:end]...)
+		}
+		pos = end
+	}
+	return segments
+}
+
+// replaceFile swaps path's contents for data via a temp file in the same
+// folder, with the given mode and modification time.
+func replaceFile(path string, data []byte, mode os.FileMode, modTime time.Time) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".rotate-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), mode.Perm()); err != nil {
+		return err
+	}
+	if err := os.Chtimes(tmp.Name(), modTime, modTime); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,190 @@
+package media
+
+import (
+	"context"
+	"errors"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func decodeDimensions(t *testing.T, path string) (int, int) {
+	t.Helper()
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open image: %v", err)
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		t.Fatalf("Failed to decode image: %v", err)
+	}
+	return config.Width, config.Height
+}
+
+func TestRotatePixels(t *testing.T) {
+	for _, name := range []string{"wide.jpg", "wide.png"} {
+		t.Run(name, func(t *testing.T) {
+			mediaDir := t.TempDir()
+			thumbnailer := NewThumbnailer(t.TempDir(), 64, nil)
+			organizer := NewOrganizerWithOptions(mediaDir, OrganizerOptions{Thumbnailer: thumbnailer})
+
+			relPath := filepath.Join("2024", "March", name)
+			fullPath := filepath.Join(mediaDir, relPath)
+			writeTestImage(t, fullPath, 40, 20)
+			before, _ := os.Stat(fullPath)
+			if _, err := organizer.LibrarySize(); err != nil {
+				t.Fatalf("Failed to measure library: %v", err)
+			}
+
+			thumbPath, err := thumbnailer.Thumbnail(context.Background(), fullPath)
+			if err != nil {
+				t.Fatalf("Failed to generate thumbnail: %v", err)
+			}
+
+			method, err := organizer.Rotate(relPath, 90)
+			if err != nil {
+				t.Fatalf("Rotate failed: %v", err)
+			}
+			if method != RotatedPixels {
+				t.Errorf("Expected method %q, got %q", RotatedPixels, method)
+			}
+			if w, h := decodeDimensions(t, fullPath); w != 20 || h != 40 {
+				t.Errorf("Expected 20x40 after rotating, got %dx%d", w, h)
+			}
+			if _, err := os.Stat(thumbPath); !os.IsNotExist(err) {
+				t.Error("Expected the cached thumbnail to be removed")
+			}
+			fresh, err := thumbnailer.Thumbnail(context.Background(), fullPath)
+			if err != nil {
+				t.Fatalf("Failed to regenerate thumbnail: %v", err)
+			}
+			if w, h := decodeDimensions(t, fresh); w != 20 || h != 40 {
+				t.Errorf("Expected a 20x40 thumbnail after rotating, got %dx%d", w, h)
+			}
+
+			after, _ := os.Stat(fullPath)
+			if expected := before.ModTime().Truncate(time.Second).Add(time.Second); !after.ModTime().Equal(expected) {
+				t.Errorf("Expected modtime %v, got %v", expected, after.ModTime())
+			}
+			if got, _ := organizer.LibrarySize(); got != after.Size() {
+				t.Errorf("Expected library size %d, got %d", after.Size(), got)
+			}
+		})
+	}
+}
+
+func TestRotateEXIFOrientation(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+
+	relPath := filepath.Join("2024", "March", "photo.jpg")
+	fullPath := filepath.Join(mediaDir, relPath)
+	data := buildEXIFJPEG(t, exifFixture{Orientation: 1, DateTimeOriginal: "2024:03:15 14:30:22"})
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(fullPath, data, 0644); err != nil {
+		t.Fatalf("Failed to write image: %v", err)
+	}
+
+	for _, step := range []struct {
+		degrees  int
+		rotation int
+	}{
+		{90, 90},
+		{180, 270},
+		{90, 0},
+	} {
+		method, err := organizer.Rotate(relPath, step.degrees)
+		if err != nil {
+			t.Fatalf("Rotate failed: %v", err)
+		}
+		if method != RotatedOrientation {
+			t.Errorf("Expected method %q, got %q", RotatedOrientation, method)
+		}
+
+		info, err := NewExtractor().ExtractMetadata(fullPath)
+		if err != nil {
+			t.Fatalf("Failed to extract metadata: %v", err)
+		}
+		if info.Rotation != step.rotation {
+			t.Errorf("Expected rotation %d after turning %d, got %d", step.rotation, step.degrees, info.Rotation)
+		}
+		if info.DateTaken == nil || info.DateTaken.Year() != 2024 {
+			t.Errorf("Expected EXIF date to survive rotation, got %v", info.DateTaken)
+		}
+	}
+
+	rotated, err := os.ReadFile(fullPath)
+	if err != nil {
+		t.Fatalf("Failed to read image: %v", err)
+	}
+	if len(rotated) != len(data) {
+		t.Errorf("Expected the tag to be rewritten in place, size changed from %d to %d", len(data), len(rotated))
+	}
+}
+
+func TestRotatedOrientation(t *testing.T) {
+	tests := []struct {
+		current  uint16
+		degrees  int
+		expected uint16
+	}{
+		{1, 90, 6},
+		{1, 180, 3},
+		{1, 270, 8},
+		{6, 90, 3},
+		{8, 90, 1},
+		{3, 270, 6},
+		{2, 90, 7},
+		{2, 180, 4},
+		{5, 90, 2},
+	}
+
+	for _, tt := range tests {
+		if got := rotatedOrientation(tt.current, tt.degrees); got != tt.expected {
+			t.Errorf("rotatedOrientation(%d, %d): expected %d, got %d", tt.current, tt.degrees, tt.expected, got)
+		}
+	}
+}
+
+func TestRotateRejectsInvalidRequests(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+	writeTestImage(t, filepath.Join(mediaDir, "2024", "March", "photo.jpg"), 8, 8)
+	if err := os.WriteFile(filepath.Join(mediaDir, "2024", "March", "clip.mp4"), []byte("video"), 0644); err != nil {
+		t.Fatalf("Failed to write video: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		path     string
+		degrees  int
+		expected error
+	}{
+		{"Unsupported angle", "2024/March/photo.jpg", 45, ErrInvalidRotation},
+		{"No turn", "2024/March/photo.jpg", 0, ErrInvalidRotation},
+		{"Missing path", "", 90, ErrInvalidPath},
+		{"Folder", "2024/March", 90, ErrInvalidPath},
+		{"Video", "2024/March/clip.mp4", 90, ErrRotateUnsupported},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := organizer.Rotate(tt.path, tt.degrees); !errors.Is(err, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+		})
+	}
+
+	if _, err := organizer.Rotate("2024/March/missing.jpg", 90); !os.IsNotExist(err) {
+		t.Errorf("Expected a not-exist error for a missing file, got %v", err)
+	}
+}
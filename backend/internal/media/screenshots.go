@@ -0,0 +1,95 @@
+package media
+
+import (
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ScreenshotsFolder holds screenshots and screen recordings when
+// SeparateScreenshots is set, in Year/Month folders of their own so they
+// don't clutter the photo timeline.
+const ScreenshotsFolder = "Screenshots"
+
+// ScreenCapture classifies a file as captured from a screen rather than a
+// camera.
+type ScreenCapture string
+
+const (
+	CaptureScreenshot      ScreenCapture = "screenshot"
+	CaptureScreenRecording ScreenCapture = "screenRecording"
+)
+
+// screenCaptureNames are the file name prefixes phones and desktops give
+// captures, compared case-insensitively with spaces, dashes and underscores
+// removed: "Screenshot_20240315-143022.png" (Android), "Screen Shot 2020-…"
+// and "Screen Recording 2024-…" (macOS), "RPReplay_Final…" (iOS).
+var screenCaptureNames = []struct {
+	prefix string
+	kind   ScreenCapture
+}{
+	{"screenshot", CaptureScreenshot},
+	{"screenrecord", CaptureScreenRecording},
+	{"rpreplay", CaptureScreenRecording},
+}
+
+// screenResolutions are the exact pixel sizes of common phone and desktop
+// screens, smaller side first.
+var screenResolutions = map[[2]int]bool{
+	// iPhone
+	{640, 1136}: true, {750, 1334}: true, {1242, 2208}: true, {1125, 2436}: true,
+	{828, 1792}: true, {1242, 2688}: true, {1170, 2532}: true, {1284, 2778}: true,
+	{1179, 2556}: true, {1290, 2796}: true, {1206, 2622}: true, {1320, 2868}: true,
+	// Android
+	{720, 1600}: true, {1080, 2280}: true, {1080, 2340}: true, {1080, 2400}: true,
+	{1440, 3040}: true, {1440, 3120}: true, {1440, 3200}: true,
+	// Desktop
+	{768, 1366}: true, {900, 1440}: true, {1080, 1920}: true, {1200, 1920}: true,
+	{1440, 2560}: true, {1600, 2560}: true, {1800, 2880}: true, {1964, 3024}: true,
+	{2234, 3456}: true, {2160, 3840}: true,
+}
+
+// screenCaptureByName classifies a file by the name its device gave it.
+func screenCaptureByName(fileName string) ScreenCapture {
+	name := strings.ToLower(fileName)
+	name = strings.NewReplacer(" ", "", "_", "", "-", "").Replace(name)
+	for _, pattern := range screenCaptureNames {
+		if strings.HasPrefix(name, pattern.prefix) {
+			return pattern.kind
+		}
+	}
+	return ""
+}
+
+// classifyScreenCapture classifies an extracted file, first by name, then,
+// for images with no camera EXIF, by whether their dimensions match a screen
+// exactly. Camera photos always carry a make or model and rarely come out at
+// a screen's exact size.
+func classifyScreenCapture(info *MediaInfo) ScreenCapture {
+	if kind := screenCaptureByName(info.FileName); kind != "" {
+		return kind
+	}
+	if info.MediaType != MediaTypePhoto || (info.Camera != nil && (info.Camera.Make != "" || info.Camera.Model != "")) {
+		return ""
+	}
+
+	width, height := info.Width, info.Height
+	if screenResolutions[[2]int{min(width, height), max(width, height)}] {
+		return CaptureScreenshot
+	}
+	return ""
+}
+
+// screenshotDirectory returns the Screenshots folder a capture taken at
+// dateTaken belongs in.
+func (o *Organizer) screenshotDirectory(dateTaken *time.Time) (string, error) {
+	dated, err := o.getTargetDirectory(dateTaken)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(o.mediaPath, dated)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(o.mediaPath, ScreenshotsFolder, rel), nil
+}
@@ -0,0 +1,107 @@
+package media
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestScreenCaptureByName(t *testing.T) {
+	tests := []struct {
+		fileName string
+		expected ScreenCapture
+	}{
+		{"Screenshot_20240315-143022.png", CaptureScreenshot},
+		{"Screen Shot 2020-03-15 at 14.30.22.png", CaptureScreenshot},
+		{"screenshot (12).PNG", CaptureScreenshot},
+		{"Screen Recording 2024-03-15 at 14.30.22.mov", CaptureScreenRecording},
+		{"Screen_Recording_20240315-143022.mp4", CaptureScreenRecording},
+		{"RPReplay_Final1710513022.mp4", CaptureScreenRecording},
+		{"IMG_20240315_143022.jpg", ""},
+		{"my screenshot.png", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.fileName, func(t *testing.T) {
+			if kind := screenCaptureByName(test.fileName); kind != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, kind)
+			}
+		})
+	}
+}
+
+func TestClassifyScreenCaptureByResolution(t *testing.T) {
+	dir := t.TempDir()
+	writePNG := func(name string, width, height int) string {
+		path := filepath.Join(dir, name)
+		file, err := os.Create(path)
+		if err != nil {
+			t.Fatalf("Failed to create image: %v", err)
+		}
+		defer file.Close()
+		if err := png.Encode(file, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+			t.Fatalf("Failed to encode image: %v", err)
+		}
+		return path
+	}
+
+	phone := writePNG("IMG_0001.png", 1170, 2532)
+	landscape := writePNG("IMG_0002.png", 2532, 1170)
+	other := writePNG("IMG_0003.png", 1000, 2000)
+
+	tests := []struct {
+		name     string
+		path     string
+		camera   *CameraInfo
+		expected ScreenCapture
+	}{
+		{"Phone screen size", phone, nil, CaptureScreenshot},
+		{"Rotated screen size", landscape, nil, CaptureScreenshot},
+		{"Other size", other, nil, ""},
+		{"Camera EXIF", phone, &CameraInfo{Make: "Apple", Model: "iPhone 15"}, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info := &MediaInfo{FileName: filepath.Base(test.path), MediaType: MediaTypePhoto, Camera: test.camera}
+			info.Width, info.Height = imageDimensions(test.path)
+			if kind := classifyScreenCapture(info); kind != test.expected {
+				t.Errorf("Expected %q, got %q", test.expected, kind)
+			}
+		})
+	}
+}
+
+func TestOrganizeFileSeparateScreenshots(t *testing.T) {
+	for _, separate := range []bool{false, true} {
+		mediaDir := t.TempDir()
+		organizer := NewOrganizerWithOptions(mediaDir, OrganizerOptions{SeparateScreenshots: separate})
+
+		screenshot := organizeTestFile(t, organizer, "Screenshot_20240315-143022.png", "screen")
+		photo := organizeTestFile(t, organizer, "IMG_20240315_143022.jpg", "photo")
+
+		if screenshot.ScreenCapture != CaptureScreenshot {
+			t.Errorf("Expected the screenshot to be classified, got %q", screenshot.ScreenCapture)
+		}
+		expected := filepath.Join("2024", "March", "Screenshot_20240315-143022.png")
+		if separate {
+			expected = filepath.Join(ScreenshotsFolder, expected)
+		}
+		if screenshot.RelativePath != expected {
+			t.Errorf("Separate %v: expected the screenshot at %s, got %s", separate, expected, screenshot.RelativePath)
+		}
+		if photo.RelativePath != filepath.Join("2024", "March", "IMG_20240315_143022.jpg") {
+			t.Errorf("Separate %v: expected the photo in its date folder, got %s", separate, photo.RelativePath)
+		}
+
+		fileInfo, err := organizer.FileInfo(screenshot.RelativePath)
+		if err != nil {
+			t.Fatalf("FileInfo failed: %v", err)
+		}
+		if fileInfo.ScreenCapture != CaptureScreenshot {
+			t.Errorf("Expected the listing to report a screenshot, got %q", fileInfo.ScreenCapture)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package media
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ShareInfo is what a link preview needs to show a library file: where to
+// fetch it and its thumbnail, how large it is and what to call it.
+type ShareInfo struct {
+	ID           string     `json:"id"`
+	Title        string     `json:"title"`
+	MediaType    string     `json:"type"`
+	URL          string     `json:"url"`
+	ThumbnailURL string     `json:"thumbnailUrl"`
+	Width        int        `json:"width,omitempty"`
+	Height       int        `json:"height,omitempty"`
+	DateTaken    *time.Time `json:"dateTaken,omitempty"`
+}
+
+// errFound stops a walk once the file it looks for turns up.
+var errFound = errors.New("found")
+
+// FindFileByID returns the listing entry of the library file with the given
+// ID, as reported in MediaFileInfo.ID. Content IDs are looked up in the
+// checksum index, which records every ID handed out; path IDs can only be
+// matched by walking the library, and only the match has its metadata
+// extracted.
+func (o *Organizer) FindFileByID(id string) (*MediaFileInfo, error) {
+	if o.fileIDs == FileIDContent {
+		relPath, ok := o.checksums.PathForID(id)
+		if !ok || !inLibrary(relPath) {
+			return nil, os.ErrNotExist
+		}
+		if _, err := os.Stat(filepath.Join(o.mediaPath, filepath.FromSlash(relPath))); err != nil {
+			return nil, os.ErrNotExist
+		}
+		return o.FileInfo(relPath)
+	}
+
+	var match string
+	err := filepath.Walk(o.mediaPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if o.skipDir(path, o.mediaPath) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !o.isMediaFile(path) {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(o.mediaPath, path)
+		if err != nil || o.generateFileID(relPath) != id {
+			return nil
+		}
+		match = relPath
+		return errFound
+	})
+	if err != nil && !errors.Is(err, errFound) {
+		return nil, fmt.Errorf("failed to search library: %w", err)
+	}
+	if match == "" {
+		return nil, os.ErrNotExist
+	}
+
+	return o.FileInfo(match)
+}
+
+// ShareInfo describes the file with the given ID for link previews. URLs are
+// relative to the server; the thumbnail URL falls back to the file itself
+// when no thumbnail can be made for it. The size is that of the image the
+// thumbnail URL serves.
+func (o *Organizer) ShareInfo(id string) (*ShareInfo, error) {
+	file, err := o.FindFileByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	share := &ShareInfo{
+		ID:           file.ID,
+		Title:        file.Caption,
+		MediaType:    file.MediaType,
+		URL:          mediaURL(file.RelativePath),
+		ThumbnailURL: mediaURL(file.RelativePath),
+		Width:        file.Width,
+		Height:       file.Height,
+		DateTaken:    file.DateTaken,
+	}
+	if share.Title == "" {
+		share.Title = strings.TrimSuffix(file.FileName, filepath.Ext(file.FileName))
+	}
+
+	fullPath := filepath.Join(o.mediaPath, file.RelativePath)
+	if share.Width == 0 && file.MediaType == "image" {
+		share.Width, share.Height = imageDimensions(fullPath)
+	}
+	// The preview shows the thumbnail, so its size is the one to report
+	if o.thumbnailer != nil && o.thumbnailer.Supports(fullPath) {
+		share.ThumbnailURL += "?format=thumbnail"
+		share.Width, share.Height = o.thumbnailer.Size(fullPath, share.Width, share.Height)
+	}
+
+	return share, nil
+}
+
+// mediaURL returns the server-relative URL of a library file, escaping each
+// path segment so names with spaces, '#' or '?' survive.
+func mediaURL(relPath string) string {
+	segments := strings.Split(filepath.ToSlash(relPath), "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	return "/media/" + strings.Join(segments, "/")
+}
+
+// imageDimensions reads an image's size from its header, or returns zeros
+// for formats the standard decoders don't know.
+func imageDimensions(path string) (int, int) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, 0
+	}
+	defer file.Close()
+
+	config, _, err := image.DecodeConfig(file)
+	if err != nil {
+		return 0, 0
+	}
+	return config.Width, config.Height
+}
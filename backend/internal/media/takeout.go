@@ -0,0 +1,66 @@
+package media
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"strconv"
+	"time"
+)
+
+// takeoutSidecarSuffixes are appended to a media file's full name to form its
+// Google Takeout sidecar; newer exports use the longer form.
+var takeoutSidecarSuffixes = []string{".json", ".supplemental-metadata.json"}
+
+// maxSidecarBytes caps how much of a sidecar is read; real ones are a few KB.
+const maxSidecarBytes = 1 << 20
+
+// takeoutSidecar returns the path of filePath's Takeout sidecar, or "" if it
+// has none.
+func takeoutSidecar(filePath string) string {
+	for _, suffix := range takeoutSidecarSuffixes {
+		if stat, err := os.Stat(filePath + suffix); err == nil && stat.Mode().IsRegular() {
+			return filePath + suffix
+		}
+	}
+	return ""
+}
+
+type takeoutMetadata struct {
+	PhotoTakenTime struct {
+		Timestamp json.Number `json:"timestamp"` // Unix seconds, written as a string
+	} `json:"photoTakenTime"`
+}
+
+// extractDateFromTakeout dates the file from the photoTakenTime in its Takeout
+// sidecar, which survives even when the export stripped the EXIF.
+func (e *Extractor) extractDateFromTakeout(filePath string, info *MediaInfo) {
+	sidecar := takeoutSidecar(filePath)
+	if sidecar == "" {
+		return
+	}
+
+	file, err := os.Open(sidecar)
+	if err != nil {
+		slog.Debug("Failed to open Takeout sidecar", "error", err, "file", sidecar)
+		return
+	}
+	defer file.Close()
+
+	var metadata takeoutMetadata
+	if err := json.NewDecoder(io.LimitReader(file, maxSidecarBytes)).Decode(&metadata); err != nil {
+		slog.Debug("Failed to parse Takeout sidecar", "error", err, "file", sidecar)
+		return
+	}
+
+	seconds, err := strconv.ParseInt(metadata.PhotoTakenTime.Timestamp.String(), 10, 64)
+	if err != nil || seconds <= 0 {
+		return
+	}
+
+	date := time.Unix(seconds, 0).In(e.location)
+	info.DateTaken = &date
+	info.DateSource = DateSourceTakeout
+	slog.Debug("Date extracted from Takeout sidecar", "date", date, "file", filePath)
+}
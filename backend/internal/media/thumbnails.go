@@ -0,0 +1,490 @@
+package media
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // Register decoders used by image.Decode
+	"image/jpeg"
+	_ "image/png"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const DefaultThumbnailSize = 320
+
+var ErrThumbnailUnsupported = errors.New("thumbnails are not supported for this file type")
+
+// decodableImageExts can be decoded without external tools; the convertible
+// formats go through the Converter first.
+var decodableImageExts = map[string]bool{
+	".jpg": true, ".jpeg": true, ".png": true, ".gif": true,
+}
+
+// Thumbnailer renders and caches downscaled JPEG previews of library images.
+type Thumbnailer struct {
+	cacheDir  string
+	maxSize   int                      // Longest edge in pixels
+	converter *Converter               // Nil skips formats the standard library cannot decode
+	cache     *lruCache[string, int64] // Cached thumbnail paths and their sizes
+}
+
+func NewThumbnailer(cacheDir string, maxSize int, converter *Converter) *Thumbnailer {
+	return NewThumbnailerWithLimit(cacheDir, maxSize, converter, 0)
+}
+
+// NewThumbnailerWithLimit is NewThumbnailer with the cache capped at
+// maxCacheBytes of thumbnails and their placeholder sidecars, deleting the
+// least recently used beyond it; zero leaves it unbounded. Thumbnails already
+// in cacheDir count against the cap, oldest first.
+func NewThumbnailerWithLimit(cacheDir string, maxSize int, converter *Converter, maxCacheBytes int64) *Thumbnailer {
+	if maxSize <= 0 {
+		maxSize = DefaultThumbnailSize
+	}
+	t := &Thumbnailer{
+		cacheDir:  cacheDir,
+		maxSize:   maxSize,
+		converter: converter,
+		cache:     newLRUCache[string, int64](0, maxCacheBytes, func(size int64) int64 { return size }),
+	}
+	t.cache.onEvict = func(cachedPath string, _ int64) { t.removeCached(cachedPath) }
+	t.loadCache()
+	return t
+}
+
+// loadCache tracks the thumbnails left by earlier runs, least recently
+// modified first so they are the first evicted.
+func (t *Thumbnailer) loadCache() {
+	entries, err := os.ReadDir(t.cacheDir)
+	if err != nil {
+		return
+	}
+
+	type cached struct {
+		path    string
+		size    int64
+		modTime int64
+	}
+	var thumbnails []cached
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".jpg") || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if info, err := entry.Info(); err == nil {
+			path := filepath.Join(t.cacheDir, entry.Name())
+			thumbnails = append(thumbnails, cached{path, cachedSize(path), info.ModTime().UnixNano()})
+		}
+	}
+	sort.Slice(thumbnails, func(i, j int) bool { return thumbnails[i].modTime < thumbnails[j].modTime })
+
+	for _, thumbnail := range thumbnails {
+		t.cache.add(thumbnail.path, thumbnail.size)
+	}
+}
+
+// CacheStats reports the thumbnail cache's size and hit rate.
+func (t *Thumbnailer) CacheStats() CacheStats {
+	return t.cache.stats()
+}
+
+// Supports reports whether a thumbnail can be attempted for the file.
+func (t *Thumbnailer) Supports(filePath string) bool {
+	ext := strings.ToLower(filepath.Ext(filePath))
+	return decodableImageExts[ext] || (t.converter != nil && convertibleImageExts[ext])
+}
+
+// CachedPath returns where the thumbnail for srcPath is stored and whether it
+// already exists. The key includes size and modtime so edits invalidate it.
+func (t *Thumbnailer) CachedPath(srcPath string) (string, bool, error) {
+	stat, err := os.Stat(srcPath)
+	if err != nil {
+		return "", false, err
+	}
+
+	key := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%d|%d", srcPath, stat.Size(), stat.ModTime().UnixNano(), t.maxSize)))
+	cachedPath := filepath.Join(t.cacheDir, fmt.Sprintf("%x.jpg", key[:16]))
+
+	_, err = os.Stat(cachedPath)
+	return cachedPath, err == nil, nil
+}
+
+// Thumbnail returns the path of the cached thumbnail for srcPath, generating
+// it on first use. The cache may evict it at any time after; callers about
+// to read it use AcquireThumbnail instead.
+func (t *Thumbnailer) Thumbnail(ctx context.Context, srcPath string) (string, error) {
+	cachedPath, release, err := t.AcquireThumbnail(ctx, srcPath)
+	if err != nil {
+		return "", err
+	}
+	release()
+	return cachedPath, nil
+}
+
+// AcquireThumbnail is Thumbnail for callers that read the thumbnail, such
+// as a request serving it: it is pinned in the cache, whatever the size
+// limit, until release is called.
+func (t *Thumbnailer) AcquireThumbnail(ctx context.Context, srcPath string) (cachedPath string, release func(), err error) {
+	cachedPath, _, err = t.CachedPath(srcPath)
+	if err != nil {
+		return "", nil, err
+	}
+
+	// Pinned before it is looked at, so it can't be evicted in between
+	t.cache.pin(cachedPath)
+	release = func() { t.cache.unpin(cachedPath) }
+	if err := t.render(ctx, srcPath, cachedPath); err != nil {
+		release()
+		return "", nil, err
+	}
+	return cachedPath, release, nil
+}
+
+// render makes sure the thumbnail of srcPath is at cachedPath, generating it
+// unless it is already there, and tracks it in the cache.
+func (t *Thumbnailer) render(ctx context.Context, srcPath, cachedPath string) error {
+	_, err := os.Stat(cachedPath)
+	exists := err == nil
+
+	// Thumbnails written by another process since startup aren't tracked
+	// yet; they count as a miss once and are tracked from then on. Only
+	// then are their placeholders checked, so hits don't read the sidecars.
+	if _, tracked := t.cache.getValid(cachedPath, func(int64) bool { return exists }); exists && !tracked {
+		if t.readBlurhash(cachedPath) == "" || t.readDominantColor(cachedPath) == "" {
+			// Cached before placeholders existed; the thumbnail is cheap to decode
+			if thumb, err := decodeImage(cachedPath); err == nil {
+				t.writePlaceholders(cachedPath, thumb)
+			}
+		}
+		t.cache.add(cachedPath, cachedSize(cachedPath))
+	}
+	if exists {
+		return nil
+	}
+	if !t.Supports(srcPath) {
+		return ErrThumbnailUnsupported
+	}
+
+	decodePath := srcPath
+	if NeedsConversion(srcPath) {
+		if decodePath, err = t.converter.ToJPEG(ctx, srcPath); err != nil {
+			return err
+		}
+	}
+
+	img, err := decodeImage(decodePath)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(t.cacheDir, 0755); err != nil {
+		return fmt.Errorf("failed to create thumbnail cache: %w", err)
+	}
+
+	// Concurrent requests for the same file each write their own temp file;
+	// whichever rename lands last wins with identical content.
+	tmp, err := os.CreateTemp(t.cacheDir, ".thumb-*.jpg")
+	if err != nil {
+		return fmt.Errorf("failed to create thumbnail: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	thumb := scaleToFit(img, t.maxSize)
+	if err := jpeg.Encode(tmp, thumb, &jpeg.Options{Quality: 80}); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write thumbnail: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), cachedPath); err != nil {
+		return fmt.Errorf("failed to store thumbnail: %w", err)
+	}
+
+	t.writePlaceholders(cachedPath, thumb)
+	t.cache.add(cachedPath, cachedSize(cachedPath))
+	return nil
+}
+
+// cachedSize is the disk space a cached thumbnail takes with its placeholder
+// sidecars, which is what counts toward the cache limit.
+func cachedSize(cachedPath string) int64 {
+	var size int64
+	for _, path := range []string{cachedPath, blurhashPath(cachedPath), dominantColorPath(cachedPath)} {
+		if stat, err := os.Stat(path); err == nil {
+			size += stat.Size()
+		}
+	}
+	return size
+}
+
+// Placeholders returns the blurhash and dominant color stored alongside the
+// file's cached thumbnail; each is "" until the thumbnail has been generated.
+func (t *Thumbnailer) Placeholders(srcPath string) (blurhash, dominantColor string) {
+	cachedPath, exists, err := t.CachedPath(srcPath)
+	if err != nil || !exists {
+		return "", ""
+	}
+	return t.readBlurhash(cachedPath), t.readDominantColor(cachedPath)
+}
+
+// Invalidate drops the cached thumbnail and its placeholders for srcPath. Call it
+// after rewriting a file in a way that may keep its size and modtime.
+func (t *Thumbnailer) Invalidate(srcPath string) {
+	cachedPath, exists, err := t.CachedPath(srcPath)
+	if err != nil || !exists {
+		return
+	}
+	t.evict(cachedPath)
+}
+
+// evict stops tracking a cached thumbnail and deletes it with its placeholders.
+func (t *Thumbnailer) evict(cachedPath string) {
+	t.cache.remove(cachedPath)
+	t.removeCached(cachedPath)
+}
+
+// removeCached deletes a cached thumbnail and its placeholders.
+func (t *Thumbnailer) removeCached(cachedPath string) {
+	for _, path := range []string{cachedPath, blurhashPath(cachedPath), dominantColorPath(cachedPath)} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			slog.Warn("Failed to remove cached thumbnail", "path", path, "error", err)
+		}
+	}
+}
+
+func blurhashPath(thumbnailPath string) string {
+	return strings.TrimSuffix(thumbnailPath, ".jpg") + ".blurhash"
+}
+
+func dominantColorPath(thumbnailPath string) string {
+	return strings.TrimSuffix(thumbnailPath, ".jpg") + ".color"
+}
+
+func (t *Thumbnailer) readBlurhash(thumbnailPath string) string {
+	data, err := os.ReadFile(blurhashPath(thumbnailPath))
+	if err != nil || len(data) != blurhashLength(blurhashXComponents, blurhashYComponents) {
+		return ""
+	}
+	return string(data)
+}
+
+func (t *Thumbnailer) readDominantColor(thumbnailPath string) string {
+	data, err := os.ReadFile(dominantColorPath(thumbnailPath))
+	if err != nil || len(data) != len("#rrggbb") || data[0] != '#' {
+		return ""
+	}
+	return string(data)
+}
+
+// writePlaceholders stores the thumbnail's blurhash and dominant color next
+// to it. Both are computed from the already-decoded thumbnail rather than the
+// original, and a failure only costs the placeholder.
+func (t *Thumbnailer) writePlaceholders(thumbnailPath string, thumb image.Image) {
+	t.writeSidecar(blurhashPath(thumbnailPath), encodeBlurhash(thumb, blurhashXComponents, blurhashYComponents))
+	t.writeSidecar(dominantColorPath(thumbnailPath), encodeDominantColor(thumb))
+}
+
+// writeSidecar atomically replaces the file at path with value.
+func (t *Thumbnailer) writeSidecar(path, value string) {
+	tmp, err := os.CreateTemp(t.cacheDir, ".placeholder-*")
+	if err != nil {
+		slog.Warn("Failed to store thumbnail placeholder", "path", path, "error", err)
+		return
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.WriteString(value)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		slog.Warn("Failed to store thumbnail placeholder", "path", path, "error", err)
+	}
+}
+
+func decodeImage(filePath string) (image.Image, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return img, nil
+}
+
+// fitWithin returns the size a width x height image is scaled to so that its
+// longest edge is at most maxSize.
+func fitWithin(width, height, maxSize int) (int, int) {
+	if width <= maxSize && height <= maxSize {
+		return width, height
+	}
+	if height > width {
+		return max(width*maxSize/height, 1), maxSize
+	}
+	return maxSize, max(height*maxSize/width, 1)
+}
+
+// Size returns the dimensions of srcPath's thumbnail: those of the cached
+// copy when there is one, otherwise what scaling width x height would give.
+func (t *Thumbnailer) Size(srcPath string, width, height int) (int, int) {
+	if cachedPath, exists, err := t.CachedPath(srcPath); err == nil && exists {
+		if w, h := imageDimensions(cachedPath); w > 0 {
+			return w, h
+		}
+	}
+	if width <= 0 || height <= 0 {
+		return 0, 0
+	}
+	return fitWithin(width, height, t.maxSize)
+}
+
+// scaleToFit box-filters img down so its longest edge is at most maxSize.
+// Smaller images are returned unchanged.
+func scaleToFit(img image.Image, maxSize int) image.Image {
+	bounds := img.Bounds()
+	srcW, srcH := bounds.Dx(), bounds.Dy()
+	if srcW <= maxSize && srcH <= maxSize {
+		return img
+	}
+	dstW, dstH := fitWithin(srcW, srcH, maxSize)
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstW, dstH))
+	for y := 0; y < dstH; y++ {
+		y0 := bounds.Min.Y + y*srcH/dstH
+		y1 := max(bounds.Min.Y+(y+1)*srcH/dstH, y0+1)
+		for x := 0; x < dstW; x++ {
+			x0 := bounds.Min.X + x*srcW/dstW
+			x1 := max(bounds.Min.X+(x+1)*srcW/dstW, x0+1)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := img.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA(x, y, color.RGBA{
+				R: uint8(r / n >> 8),
+				G: uint8(g / n >> 8),
+				B: uint8(b / n >> 8),
+				A: uint8(a / n >> 8),
+			})
+		}
+	}
+	return dst
+}
+
+type PregenerateOptions struct {
+	Workers  int                     // Concurrent thumbnail renders; <= 0 means 1
+	Progress func(ThumbnailProgress) // Called after each file, never concurrently
+}
+
+type ThumbnailProgress struct {
+	Total     int `json:"total"`
+	Done      int `json:"done"`
+	Generated int `json:"generated"`
+	Skipped   int `json:"skipped"` // Already cached
+	Failed    int `json:"failed"`
+}
+
+// PregenerateThumbnails renders missing thumbnails for every supported image
+// in the library with a bounded worker pool, so the first gallery load after
+// a bulk import does not pay for them. Failures are counted, not fatal.
+func (o *Organizer) PregenerateThumbnails(ctx context.Context, thumbnailer *Thumbnailer, opts PregenerateOptions) (ThumbnailProgress, error) {
+	var files []string
+	err := filepath.Walk(o.mediaPath, func(path string, info os.FileInfo, err error) error {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() {
+			if o.skipDir(path, o.mediaPath) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if o.isMediaFile(path) && thumbnailer.Supports(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return ThumbnailProgress{}, fmt.Errorf("thumbnail pre-generation aborted: %w", err)
+	}
+	sort.Strings(files)
+
+	workers := max(opts.Workers, 1)
+
+	var (
+		generated, skipped, failed atomic.Int64
+		progressMutex              sync.Mutex
+		wg                         sync.WaitGroup
+	)
+	snapshot := func() ThumbnailProgress {
+		progress := ThumbnailProgress{
+			Total:     len(files),
+			Generated: int(generated.Load()),
+			Skipped:   int(skipped.Load()),
+			Failed:    int(failed.Load()),
+		}
+		progress.Done = progress.Generated + progress.Skipped + progress.Failed
+		return progress
+	}
+
+	paths := make(chan string)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for path := range paths {
+				if hash, dominantColor := thumbnailer.Placeholders(path); hash != "" && dominantColor != "" {
+					skipped.Add(1)
+				} else if _, err := thumbnailer.Thumbnail(ctx, path); err != nil {
+					failed.Add(1)
+				} else {
+					generated.Add(1)
+				}
+
+				if opts.Progress != nil {
+					progressMutex.Lock()
+					opts.Progress(snapshot())
+					progressMutex.Unlock()
+				}
+			}
+		}()
+	}
+
+feed:
+	for _, path := range files {
+		select {
+		case paths <- path:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(paths)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return snapshot(), fmt.Errorf("thumbnail pre-generation aborted: %w", err)
+	}
+	return snapshot(), nil
+}
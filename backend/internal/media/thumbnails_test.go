@@ -0,0 +1,143 @@
+package media
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTestImage(t *testing.T, path string, width, height int) {
+	t.Helper()
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create image: %v", err)
+	}
+	defer file.Close()
+
+	if filepath.Ext(path) == ".png" {
+		err = png.Encode(file, img)
+	} else {
+		err = jpeg.Encode(file, img, nil)
+	}
+	if err != nil {
+		t.Fatalf("Failed to encode image: %v", err)
+	}
+}
+
+func TestPregenerateThumbnails(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizer(mediaDir)
+	thumbnailer := NewThumbnailer(t.TempDir(), 64, nil)
+
+	images := []string{
+		filepath.Join(mediaDir, "2024", "03", "wide.jpg"),
+		filepath.Join(mediaDir, "2024", "03", "tall.png"),
+		filepath.Join(mediaDir, "2024", "04", "small.jpg"),
+	}
+	writeTestImage(t, images[0], 200, 100)
+	writeTestImage(t, images[1], 90, 180)
+	writeTestImage(t, images[2], 32, 32)
+	if err := os.WriteFile(filepath.Join(mediaDir, "2024", "04", "clip.mp4"), []byte("not an image"), 0644); err != nil {
+		t.Fatalf("Failed to create video: %v", err)
+	}
+
+	var updates int
+	progress, err := organizer.PregenerateThumbnails(context.Background(), thumbnailer, PregenerateOptions{
+		Workers:  2,
+		Progress: func(ThumbnailProgress) { updates++ },
+	})
+	if err != nil {
+		t.Fatalf("PregenerateThumbnails failed: %v", err)
+	}
+
+	if progress.Total != len(images) || progress.Generated != len(images) || progress.Done != len(images) {
+		t.Errorf("Expected %d thumbnails generated, got %+v", len(images), progress)
+	}
+	if updates != len(images) {
+		t.Errorf("Expected %d progress updates, got %d", len(images), updates)
+	}
+
+	for _, path := range images {
+		cachedPath, exists, err := thumbnailer.CachedPath(path)
+		if err != nil || !exists {
+			t.Fatalf("Expected cached thumbnail for %s (err %v)", filepath.Base(path), err)
+		}
+
+		file, err := os.Open(cachedPath)
+		if err != nil {
+			t.Fatalf("Failed to open thumbnail: %v", err)
+		}
+		config, err := jpeg.DecodeConfig(file)
+		file.Close()
+		if err != nil {
+			t.Fatalf("Thumbnail is not a JPEG: %v", err)
+		}
+		if config.Width > 64 || config.Height > 64 {
+			t.Errorf("Expected %s thumbnail within 64px, got %dx%d", filepath.Base(path), config.Width, config.Height)
+		}
+	}
+
+	// A second run finds everything cached
+	progress, err = organizer.PregenerateThumbnails(context.Background(), thumbnailer, PregenerateOptions{Workers: 2})
+	if err != nil {
+		t.Fatalf("PregenerateThumbnails failed: %v", err)
+	}
+	if progress.Skipped != len(images) || progress.Generated != 0 {
+		t.Errorf("Expected all %d thumbnails skipped, got %+v", len(images), progress)
+	}
+}
+
+func TestShareInfoReportsThumbnail(t *testing.T) {
+	mediaDir := t.TempDir()
+	relPath := filepath.Join("2024", "March", "Beach #1?.png")
+	writeTestImage(t, filepath.Join(mediaDir, relPath), 200, 100)
+
+	thumbnailer := NewThumbnailer(t.TempDir(), 64, nil)
+	organizer := NewOrganizerWithOptions(mediaDir, OrganizerOptions{Thumbnailer: thumbnailer})
+
+	file, err := organizer.FileInfo(relPath)
+	if err != nil {
+		t.Fatalf("FileInfo failed: %v", err)
+	}
+
+	share, err := organizer.ShareInfo(file.ID)
+	if err != nil {
+		t.Fatalf("ShareInfo failed: %v", err)
+	}
+	if share.URL != "/media/2024/March/Beach%20%231%3F.png" {
+		t.Errorf("Expected an escaped URL, got %s", share.URL)
+	}
+	if share.ThumbnailURL != share.URL+"?format=thumbnail" {
+		t.Errorf("Expected the thumbnail URL, got %s", share.ThumbnailURL)
+	}
+	if share.Width != 64 || share.Height != 32 {
+		t.Errorf("Expected the thumbnail's 64x32 before generation, got %dx%d", share.Width, share.Height)
+	}
+
+	if _, err := thumbnailer.Thumbnail(context.Background(), filepath.Join(mediaDir, relPath)); err != nil {
+		t.Fatalf("Thumbnail failed: %v", err)
+	}
+	share, err = organizer.ShareInfo(file.ID)
+	if err != nil {
+		t.Fatalf("ShareInfo failed: %v", err)
+	}
+	if share.Width != 64 || share.Height != 32 {
+		t.Errorf("Expected the cached thumbnail's 64x32, got %dx%d", share.Width, share.Height)
+	}
+}
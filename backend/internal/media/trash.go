@@ -0,0 +1,289 @@
+package media
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TrashFolder holds soft-deleted files until they are restored or purged.
+// Each deletion goes into a folder named after its time in Unix nanoseconds,
+// below which the file keeps its library-relative path, so a trash ID such as
+// "1710512345000000000/2024/March/IMG_0001.jpg" says both when it was deleted
+// and where it goes back to.
+const TrashFolder = ".trash"
+
+// DefaultTrashSweepInterval is how often RunTrashSweeper purges expired trash.
+const DefaultTrashSweepInterval = time.Hour
+
+type TrashedFile struct {
+	ID           string    `json:"id"`
+	OriginalPath string    `json:"originalPath"`
+	DeletedAt    time.Time `json:"deletedAt"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+	Size         int64     `json:"size"`
+}
+
+// isTrashDir reports whether dir is the library's trash folder.
+func (o *Organizer) isTrashDir(dir string) bool {
+	return dir == filepath.Join(o.mediaPath, TrashFolder)
+}
+
+// TrashEnabled reports whether deletes go to the trash rather than removing
+// files outright.
+func (o *Organizer) TrashEnabled() bool {
+	return o.trashRetention > 0
+}
+
+// TrashFile moves a library file into the trash, where it can be restored
+// until the retention period runs out. The library forgets it just as
+// DeleteFile does.
+func (o *Organizer) TrashFile(relPath string) (*TrashedFile, error) {
+	fullPath, err := o.ResolvePath(relPath)
+	if err != nil {
+		return nil, err
+	}
+	if o.isTrashDir(fullPath) || strings.HasPrefix(fullPath, filepath.Join(o.mediaPath, TrashFolder)+string(filepath.Separator)) {
+		return nil, fmt.Errorf("%w: %q is already in the trash", ErrInvalidPath, relPath)
+	}
+
+	stat, err := os.Stat(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	if !stat.Mode().IsRegular() {
+		return nil, fmt.Errorf("%w: %q is not a file", ErrInvalidPath, relPath)
+	}
+
+	rel, err := filepath.Rel(o.mediaPath, fullPath)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPath, err)
+	}
+
+	deletedAt := o.clock.Now()
+	batch := strconv.FormatInt(deletedAt.UnixNano(), 10)
+	trashPath := filepath.Join(o.mediaPath, TrashFolder, batch, rel)
+	if err := os.MkdirAll(filepath.Dir(trashPath), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create trash directory: %w", err)
+	}
+	if trashPath, err = o.claimFinalPath(trashPath); err != nil {
+		return nil, err
+	}
+	if err := o.moveFile(fullPath, trashPath); err != nil {
+		os.Remove(trashPath)
+		return nil, fmt.Errorf("failed to move file to trash: %w", err)
+	}
+
+	// The entry goes along so the file keeps its ID if it is restored
+	if trashRel, err := filepath.Rel(o.mediaPath, trashPath); err == nil {
+		o.checksums.Move(rel, trashRel)
+	}
+	if err := o.checksums.Save(); err != nil {
+		slog.Error("Failed to save checksum index", "error", err)
+	}
+	o.pruneEmptyDirs(filepath.Dir(fullPath))
+	o.addLibraryBytes(-stat.Size())
+	o.version.Add(1)
+
+	id, _ := filepath.Rel(filepath.Join(o.mediaPath, TrashFolder), trashPath)
+	return &TrashedFile{
+		ID:           filepath.ToSlash(id),
+		OriginalPath: filepath.ToSlash(rel),
+		DeletedAt:    deletedAt,
+		ExpiresAt:    deletedAt.Add(o.trashRetention),
+		Size:         stat.Size(),
+	}, nil
+}
+
+// ListTrash returns everything in the trash, most recently deleted first.
+func (o *Organizer) ListTrash() ([]TrashedFile, error) {
+	trashDir := filepath.Join(o.mediaPath, TrashFolder)
+	files := []TrashedFile{}
+
+	err := filepath.Walk(trashDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) && path == trashDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		id, err := filepath.Rel(trashDir, path)
+		if err != nil {
+			return nil
+		}
+		file, ok := o.parseTrashID(filepath.ToSlash(id))
+		if !ok {
+			return nil
+		}
+		file.Size = info.Size()
+		files = append(files, file)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trash: %w", err)
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		return files[i].DeletedAt.After(files[j].DeletedAt)
+	})
+	return files, nil
+}
+
+// parseTrashID splits an ID into its deletion time and original path.
+func (o *Organizer) parseTrashID(id string) (TrashedFile, bool) {
+	batch, original, ok := strings.Cut(id, "/")
+	if !ok || original == "" {
+		return TrashedFile{}, false
+	}
+	nanos, err := strconv.ParseInt(batch, 10, 64)
+	if err != nil {
+		return TrashedFile{}, false
+	}
+
+	deletedAt := time.Unix(0, nanos).UTC()
+	return TrashedFile{
+		ID:           id,
+		OriginalPath: original,
+		DeletedAt:    deletedAt,
+		ExpiresAt:    deletedAt.Add(o.trashRetention),
+	}, true
+}
+
+// RestoreFile moves a trashed file back to where it was deleted from and
+// returns its library-relative path. If that name has been taken since, the
+// file comes back numbered alongside it.
+func (o *Organizer) RestoreFile(id string) (string, error) {
+	trashDir := filepath.Join(o.mediaPath, TrashFolder)
+	trashPath, err := ResolveWithin(trashDir, id)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(trashDir, trashPath)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidPath, err)
+	}
+	file, ok := o.parseTrashID(filepath.ToSlash(rel))
+	if !ok {
+		return "", fmt.Errorf("%w: %q is not a trash ID", ErrInvalidPath, id)
+	}
+
+	stat, err := os.Stat(trashPath)
+	if err != nil {
+		return "", err
+	}
+	if !stat.Mode().IsRegular() {
+		return "", fmt.Errorf("%w: %q is not a file", ErrInvalidPath, id)
+	}
+
+	targetPath, err := o.ResolvePath(file.OriginalPath)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return "", fmt.Errorf("failed to create target directory: %w", err)
+	}
+	finalPath, err := o.claimFinalPath(targetPath)
+	if err != nil {
+		return "", err
+	}
+	if err := o.moveFile(trashPath, finalPath); err != nil {
+		os.Remove(finalPath)
+		return "", fmt.Errorf("failed to restore file: %w", err)
+	}
+	o.pruneEmptyDirs(filepath.Dir(trashPath))
+
+	restored, err := filepath.Rel(o.mediaPath, finalPath)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidPath, err)
+	}
+	o.checksums.Move(filepath.Join(TrashFolder, rel), restored)
+	if _, ok := o.checksums.Get(restored); !ok {
+		if hash, err := o.calculateFileHash(finalPath); err == nil {
+			o.checksums.Set(restored, hash)
+		}
+	}
+	if err := o.checksums.Save(); err != nil {
+		slog.Error("Failed to save checksum index", "error", err)
+	}
+	o.addLibraryBytes(stat.Size())
+	o.version.Add(1)
+
+	return filepath.ToSlash(restored), nil
+}
+
+// PurgeTrash permanently removes deletions older than the retention period
+// and reports how many files went.
+func (o *Organizer) PurgeTrash() (int, error) {
+	trashDir := filepath.Join(o.mediaPath, TrashFolder)
+	entries, err := os.ReadDir(trashDir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read trash: %w", err)
+	}
+
+	cutoff := o.clock.Now().Add(-o.trashRetention)
+	purged := 0
+	for _, entry := range entries {
+		nanos, err := strconv.ParseInt(entry.Name(), 10, 64)
+		if err != nil || !entry.IsDir() || !time.Unix(0, nanos).Before(cutoff) {
+			continue
+		}
+
+		batch := filepath.Join(trashDir, entry.Name())
+		filepath.Walk(batch, func(path string, info os.FileInfo, err error) error {
+			if err == nil && !info.IsDir() {
+				purged++
+			}
+			return nil
+		})
+		if err := os.RemoveAll(batch); err != nil {
+			return purged, fmt.Errorf("failed to purge trash: %w", err)
+		}
+		o.checksums.DeleteUnder(filepath.Join(TrashFolder, entry.Name()))
+	}
+
+	if purged > 0 {
+		if err := o.checksums.Save(); err != nil {
+			slog.Error("Failed to save checksum index", "error", err)
+		}
+		slog.Info("Purged expired trash", "files", purged)
+	}
+	if remaining, err := os.ReadDir(trashDir); err == nil && len(remaining) == 0 {
+		os.Remove(trashDir)
+	}
+	return purged, nil
+}
+
+// RunTrashSweeper purges expired trash every interval until ctx is done.
+func (o *Organizer) RunTrashSweeper(ctx context.Context, interval time.Duration) {
+	if !o.TrashEnabled() {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := o.PurgeTrash(); err != nil {
+			slog.Error("Failed to purge trash", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
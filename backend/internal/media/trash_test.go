@@ -0,0 +1,126 @@
+package media
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Steven-harris/sortify/backend/internal/clock"
+)
+
+func TestTrashListRestore(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizerWithOptions(mediaDir, OrganizerOptions{TrashRetention: 24 * time.Hour})
+
+	photo := organizeTestFile(t, organizer, "IMG_20240315_143022.jpg", "holiday photo")
+	if _, err := organizer.LibrarySize(); err != nil {
+		t.Fatalf("Failed to measure library: %v", err)
+	}
+
+	trashed, err := organizer.TrashFile(photo.RelativePath)
+	if err != nil {
+		t.Fatalf("TrashFile failed: %v", err)
+	}
+	if trashed.OriginalPath != "2024/March/IMG_20240315_143022.jpg" {
+		t.Errorf("Expected original path 2024/March/IMG_20240315_143022.jpg, got %s", trashed.OriginalPath)
+	}
+	if _, err := os.Stat(filepath.Join(mediaDir, photo.RelativePath)); !os.IsNotExist(err) {
+		t.Error("Expected the file to leave the library")
+	}
+	if files, _ := organizer.ScanFiles("2024", "March", 0, 0); len(files) != 0 {
+		t.Errorf("Expected trashed files to stay out of listings, got %+v", files)
+	}
+	if size, _ := organizer.LibrarySize(); size != 0 {
+		t.Errorf("Expected trashed bytes not to count toward the library, got %d", size)
+	}
+
+	listed, err := organizer.ListTrash()
+	if err != nil {
+		t.Fatalf("ListTrash failed: %v", err)
+	}
+	if len(listed) != 1 || listed[0].ID != trashed.ID || listed[0].Size != int64(len("holiday photo")) {
+		t.Fatalf("Expected the trashed file to be listed, got %+v", listed)
+	}
+
+	// The name was reused meanwhile, so the restored file comes back numbered
+	organizeTestFile(t, organizer, "IMG_20240315_143022.jpg", "a different photo")
+
+	restored, err := organizer.RestoreFile(trashed.ID)
+	if err != nil {
+		t.Fatalf("RestoreFile failed: %v", err)
+	}
+	if restored != "2024/March/IMG_20240315_143022(1).jpg" {
+		t.Errorf("Expected 2024/March/IMG_20240315_143022(1).jpg, got %s", restored)
+	}
+	content, err := os.ReadFile(filepath.Join(mediaDir, filepath.FromSlash(restored)))
+	if err != nil || string(content) != "holiday photo" {
+		t.Errorf("Expected the restored content, got %q (%v)", content, err)
+	}
+	if _, ok := organizer.checksums.Get(filepath.FromSlash(restored)); !ok {
+		t.Error("Expected the restored file to be indexed")
+	}
+
+	if listed, _ := organizer.ListTrash(); len(listed) != 0 {
+		t.Errorf("Expected an empty trash after restoring, got %+v", listed)
+	}
+	if _, err := os.Stat(filepath.Join(mediaDir, TrashFolder)); !os.IsNotExist(err) {
+		t.Error("Expected the empty trash folder to be pruned")
+	}
+	if _, err := organizer.RestoreFile(trashed.ID); !os.IsNotExist(err) {
+		t.Errorf("Expected restoring twice to fail with not-exist, got %v", err)
+	}
+}
+
+func TestPurgeTrashRetention(t *testing.T) {
+	mediaDir := t.TempDir()
+	clk := clock.NewFake(time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC))
+	organizer := NewOrganizerWithOptions(mediaDir, OrganizerOptions{TrashRetention: 7 * 24 * time.Hour, Clock: clk})
+
+	old := organizeTestFile(t, organizer, "IMG_20240315_143022.jpg", "deleted long ago")
+	recent := organizeTestFile(t, organizer, "IMG_20240316_101500.jpg", "deleted yesterday")
+
+	if _, err := organizer.TrashFile(old.RelativePath); err != nil {
+		t.Fatalf("TrashFile failed: %v", err)
+	}
+	clk.Advance(7 * 24 * time.Hour)
+	kept, err := organizer.TrashFile(recent.RelativePath)
+	if err != nil {
+		t.Fatalf("TrashFile failed: %v", err)
+	}
+	clk.Advance(time.Hour)
+
+	purged, err := organizer.PurgeTrash()
+	if err != nil {
+		t.Fatalf("PurgeTrash failed: %v", err)
+	}
+	if purged != 1 {
+		t.Errorf("Expected 1 purged file, got %d", purged)
+	}
+
+	listed, err := organizer.ListTrash()
+	if err != nil {
+		t.Fatalf("ListTrash failed: %v", err)
+	}
+	if len(listed) != 1 || listed[0].ID != kept.ID {
+		t.Errorf("Expected only the recent deletion to remain, got %+v", listed)
+	}
+}
+
+func TestTrashFileRejectsTrashPaths(t *testing.T) {
+	mediaDir := t.TempDir()
+	organizer := NewOrganizerWithOptions(mediaDir, OrganizerOptions{TrashRetention: time.Hour})
+
+	photo := organizeTestFile(t, organizer, "IMG_20240315_143022.jpg", "photo")
+	trashed, err := organizer.TrashFile(photo.RelativePath)
+	if err != nil {
+		t.Fatalf("TrashFile failed: %v", err)
+	}
+
+	if _, err := organizer.TrashFile(TrashFolder + "/" + trashed.ID); err == nil {
+		t.Error("Expected trashing a file already in the trash to fail")
+	}
+	if _, err := organizer.RestoreFile("not-a-batch/2024/March/photo.jpg"); err == nil {
+		t.Error("Expected a malformed trash ID to be rejected")
+	}
+}
@@ -1,11 +1,16 @@
 package media
 
 import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type MediaInfo struct {
-	FileName      string            `json:"filename"`
+	FileName      string            `json:"fileName"`
+	RelativePath  string            `json:"relativePath,omitempty"` // Location within the library once organized
 	FileSize      int64             `json:"fileSize"`
 	MimeType      string            `json:"mimeType"`
 	MediaType     MediaType         `json:"mediaType"`
@@ -13,25 +18,66 @@ type MediaInfo struct {
 	DateSource    DateSource        `json:"dateSource"`
 	Width         int               `json:"width,omitempty"`
 	Height        int               `json:"height,omitempty"`
-	Duration      *time.Duration    `json:"duration,omitempty"`
+	Duration      *Duration         `json:"duration,omitempty"`
+	Rotation      int               `json:"rotation,omitempty"` // Clockwise degrees needed for upright display
 	Camera        *CameraInfo       `json:"camera,omitempty"`
 	Location      *LocationInfo     `json:"location,omitempty"`
+	ScreenCapture ScreenCapture     `json:"screenCapture,omitempty"` // Set for screenshots and screen recordings
 	ExtraMetadata map[string]string `json:"extraMetadata,omitempty"`
 }
 
+// Duration is the length of a video or audio clip. It encodes to JSON as
+// fractional seconds, e.g. 125.5, instead of time.Duration's nanoseconds.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return strconv.AppendFloat(nil, time.Duration(d).Seconds(), 'f', -1, 64), nil
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var seconds float64
+	if err := json.Unmarshal(data, &seconds); err != nil {
+		return fmt.Errorf("duration must be a number of seconds: %w", err)
+	}
+	*d = Duration(seconds * float64(time.Second))
+	return nil
+}
+
 type MediaType string
 
 const (
 	MediaTypePhoto MediaType = "photo"
 	MediaTypeVideo MediaType = "video"
 	MediaTypeOther MediaType = "other"
+	// MediaTypeDocument is a scanned document or other file with an extension
+	// in DocumentExtensions.
+	MediaTypeDocument MediaType = "document"
 )
 
+// ParseMediaType validates a client-supplied media type name. "document" is
+// only accepted when documents are enabled.
+func ParseMediaType(value string, documents bool) (MediaType, error) {
+	switch mediaType := MediaType(value); mediaType {
+	case MediaTypePhoto, MediaTypeVideo, MediaTypeOther:
+		return mediaType, nil
+	case MediaTypeDocument:
+		if !documents {
+			return "", fmt.Errorf("media type %q is disabled, see DOCUMENT_EXTENSIONS", value)
+		}
+		return mediaType, nil
+	default:
+		return "", fmt.Errorf("unknown media type %q", value)
+	}
+}
+
 type DateSource string
 
 const (
 	DateSourceEXIF      DateSource = "exif"
 	DateSourceFileName  DateSource = "filename"
+	DateSourceTakeout   DateSource = "takeout"   // Google Takeout JSON sidecar
+	DateSourcePDF       DateSource = "pdf"       // CreationDate of a PDF document
+	DateSourceVideoMeta DateSource = "videoMeta" // Creation time in a video's container, read by ffprobe
 	DateSourceFileTime  DateSource = "fileTime"
 	DateSourceUserInput DateSource = "userInput"
 	DateSourceUnknown   DateSource = "unknown"
@@ -56,7 +102,7 @@ type LocationInfo struct {
 }
 
 type DateExtractionRequest struct {
-	FileName     string `json:"filename"`
+	FileName     string `json:"fileName"`
 	OriginalPath string `json:"originalPath"`
 	SessionID    string `json:"sessionId"`
 }
@@ -67,17 +113,35 @@ type DateExtractionResponse struct {
 }
 
 type MediaFileInfo struct {
-	ID           string         `json:"id"`
-	FileName     string         `json:"filename"`
-	RelativePath string         `json:"relativePath"`
-	Size         int64          `json:"size"`
-	ModTime      time.Time      `json:"modTime"`
-	MediaType    string         `json:"type"`
-	URL          string         `json:"url"`
-	DateTaken    *time.Time     `json:"dateTaken,omitempty"`
-	Camera       string         `json:"camera,omitempty"`
-	Location     string         `json:"location,omitempty"`
-	Width        int            `json:"width,omitempty"`
-	Height       int            `json:"height,omitempty"`
-	Duration     *time.Duration `json:"duration,omitempty"`
+	ID            string     `json:"id"`
+	FileName      string     `json:"fileName"`
+	RelativePath  string     `json:"relativePath"`
+	Size          int64      `json:"size"`
+	ModTime       time.Time  `json:"modTime"`
+	MediaType     string     `json:"type"`
+	URL           string     `json:"url"`
+	DateTaken     *time.Time `json:"dateTaken,omitempty"`
+	Camera        string     `json:"camera,omitempty"`
+	Location      string     `json:"location,omitempty"`
+	Width         int        `json:"width,omitempty"`
+	Height        int        `json:"height,omitempty"`
+	Duration      *Duration  `json:"duration,omitempty"`
+	Rotation      int        `json:"rotation,omitempty"`
+	Caption       string     `json:"caption,omitempty"`
+	Blurhash      string     `json:"blurhash,omitempty"`      // Placeholder shown while the image loads
+	DominantColor string     `json:"dominantColor,omitempty"` // Average color as #rrggbb, for theming the tile
+
+	ScreenCapture ScreenCapture `json:"screenCapture,omitempty"`
+}
+
+// MatchesQuery reports whether a free-text search matches the file's name,
+// camera, location or caption, ignoring case.
+func (f *MediaFileInfo) MatchesQuery(query string) bool {
+	query = strings.ToLower(query)
+	for _, field := range []string{f.FileName, f.Camera, f.Location, f.Caption} {
+		if strings.Contains(strings.ToLower(field), query) {
+			return true
+		}
+	}
+	return false
 }
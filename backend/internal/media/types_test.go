@@ -0,0 +1,103 @@
+package media
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDurationJSON(t *testing.T) {
+	duration := Duration(125*time.Second + 500*time.Millisecond)
+
+	tests := []struct {
+		name  string
+		value any
+	}{
+		{"MediaInfo", MediaInfo{Duration: &duration}},
+		{"MediaFileInfo", MediaFileInfo{Duration: &duration}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := json.Marshal(test.value)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+			if !strings.Contains(string(data), `"duration":125.5`) {
+				t.Errorf("Expected duration in seconds, got %s", data)
+			}
+		})
+	}
+
+	// Files without a duration leave the field out entirely
+	data, err := json.Marshal(MediaFileInfo{})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if strings.Contains(string(data), "duration") {
+		t.Errorf("Expected no duration field, got %s", data)
+	}
+
+	var decoded MediaFileInfo
+	if err := json.Unmarshal([]byte(`{"duration":2.25}`), &decoded); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if decoded.Duration == nil || time.Duration(*decoded.Duration) != 2250*time.Millisecond {
+		t.Errorf("Expected 2.25s, got %v", decoded.Duration)
+	}
+
+	if err := json.Unmarshal([]byte(`{"duration":"2m"}`), &decoded); err == nil {
+		t.Error("Expected an error for a non-numeric duration")
+	}
+}
+
+func TestMediaTypesUseCamelCaseJSON(t *testing.T) {
+	camelCase := regexp.MustCompile(`^[a-z][a-zA-Z0-9]*$`)
+
+	for _, value := range []any{
+		MediaInfo{}, CameraInfo{}, LocationInfo{},
+		DateExtractionRequest{}, DateExtractionResponse{}, MediaFileInfo{},
+	} {
+		typ := reflect.TypeOf(value)
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			if name == "-" || (name == "" && field.Anonymous) {
+				continue
+			}
+			if !camelCase.MatchString(name) {
+				t.Errorf("Expected %s.%s to use a camelCase JSON name, got %q", typ.Name(), field.Name, name)
+			}
+		}
+	}
+}
+
+func TestParseMediaType(t *testing.T) {
+	tests := []struct {
+		value     string
+		documents bool
+		expectErr bool
+	}{
+		{"photo", false, false},
+		{"video", false, false},
+		{"other", false, false},
+		{"document", true, false},
+		{"document", false, true},
+		{"Photo", true, true},
+		{"", true, true},
+	}
+
+	for _, test := range tests {
+		mediaType, err := ParseMediaType(test.value, test.documents)
+		if (err != nil) != test.expectErr {
+			t.Errorf("ParseMediaType(%q, %v): expected error %v, got %v", test.value, test.documents, test.expectErr, err)
+			continue
+		}
+		if err == nil && string(mediaType) != test.value {
+			t.Errorf("ParseMediaType(%q, %v): expected %q, got %q", test.value, test.documents, test.value, mediaType)
+		}
+	}
+}
@@ -6,18 +6,38 @@ import (
 
 // UploadSession represents an active upload session
 type UploadSession struct {
-	ID           string            `json:"id"`
-	FileName     string            `json:"filename"`
-	FileSize     int64             `json:"fileSize"`
-	ChunkSize    int64             `json:"chunkSize"`
-	TotalChunks  int               `json:"totalChunks"`
-	UploadedSize int64             `json:"uploadedSize"`
-	Checksum     string            `json:"checksum"` // Expected SHA256 checksum
-	TempPath     string            `json:"tempPath"` // Temporary file path
-	Metadata     map[string]string `json:"metadata"` // Additional metadata
-	CreatedAt    time.Time         `json:"createdAt"`
-	UpdatedAt    time.Time         `json:"updatedAt"`
-	Status       UploadStatus      `json:"status"`
+	ID            string            `json:"id"`
+	FileName      string            `json:"fileName"`
+	FileSize      int64             `json:"fileSize"`
+	ChunkSize     int64             `json:"chunkSize"`
+	TotalChunks   int               `json:"totalChunks"`
+	UploadedSize  int64             `json:"uploadedSize"`
+	SentBytes     int64             `json:"sentBytes,omitempty"`      // Bytes written including resends, unlike UploadedSize
+	Received      map[int]bool      `json:"receivedChunks,omitempty"` // Distinct chunk numbers written so far
+	Verified      map[int]bool      `json:"verifiedChunks,omitempty"` // Chunks whose current bytes passed a checksum
+	ChunkHashes   map[int]string    `json:"chunkHashes,omitempty"`    // SHA-256 of chunks sent with one, for tree-hash completion
+	Ranges        []ByteRange       `json:"ranges,omitempty"`         // Merged byte ranges written so far, sorted by offset
+	Checksum      string            `json:"checksum"`                 // Expected SHA256 checksum
+	TempPath      string            `json:"tempPath"`                 // Temporary file path
+	Metadata      map[string]string `json:"metadata"`                 // Additional metadata
+	MediaType     string            `json:"mediaType,omitempty"`      // Client hint overriding extension sniffing
+	DateTaken     *time.Time        `json:"dateTaken,omitempty"`      // Client-supplied capture date overriding EXIF and filename
+	Transactional bool              `json:"transactional,omitempty"`  // Data is staged until completion commits it
+	CreatedAt     time.Time         `json:"createdAt"`
+	UpdatedAt     time.Time         `json:"updatedAt"`
+	Status        UploadStatus      `json:"status"`
+	Error         string            `json:"error,omitempty"` // Why the session failed
+}
+
+// ByteRange is a span of a file, Length bytes from Offset.
+type ByteRange struct {
+	Offset int64 `json:"offset"`
+	Length int64 `json:"length"`
+}
+
+// End returns the offset just past the range.
+func (r ByteRange) End() int64 {
+	return r.Offset + r.Length
 }
 
 // UploadStatus represents the status of an upload
@@ -32,12 +52,20 @@ const (
 	StatusCancelled   UploadStatus = "cancelled"
 )
 
+// Terminal reports whether an upload in this status can no longer receive
+// chunks. Terminal sessions linger until cleaned up but hold no upload slot.
+func (s UploadStatus) Terminal() bool {
+	return s == StatusCompleted || s == StatusFailed || s == StatusCancelled
+}
+
 // ChunkInfo represents information about an uploaded chunk
 type ChunkInfo struct {
 	SessionID   string `json:"sessionId"`
 	ChunkNumber int    `json:"chunkNumber"`
 	ChunkSize   int64  `json:"chunkSize"`
-	Checksum    string `json:"checksum"` // SHA256 of this specific chunk
+	Checksum    string `json:"checksum"` // Hex digest of this specific chunk
+	// ChecksumAlgorithm names the digest: md5, sha1, sha256 (default) or sha512
+	ChecksumAlgorithm string `json:"checksumAlgorithm,omitempty"`
 }
 
 // UploadProgress represents the current progress of an upload
@@ -50,15 +78,22 @@ type UploadProgress struct {
 	TotalChunks     int     `json:"totalChunks"`
 	PercentComplete float64 `json:"percentComplete"`
 	Status          string  `json:"status"`
+	Error           string  `json:"error,omitempty"` // Set once the upload has failed
+	// ReceivedRanges lets a client resuming with a different chunk size work
+	// out which bytes are still missing.
+	ReceivedRanges []ByteRange `json:"receivedRanges,omitempty"`
 }
 
 // StartUploadRequest represents the request to start an upload
 type StartUploadRequest struct {
-	FileName  string            `json:"fileName"`
-	FileSize  int64             `json:"fileSize"`
-	ChunkSize int64             `json:"chunkSize"`
-	Checksum  string            `json:"checksum"`
-	Metadata  map[string]string `json:"metadata"`
+	FileName      string            `json:"fileName"`
+	FileSize      int64             `json:"fileSize"`
+	ChunkSize     int64             `json:"chunkSize"`
+	Checksum      string            `json:"checksum"`
+	Metadata      map[string]string `json:"metadata"`
+	MediaTypeHint string            `json:"mediaTypeHint,omitempty"` // Optional: photo, video or other
+	DateTaken     *time.Time        `json:"dateTaken,omitempty"`     // Optional: authoritative capture date, e.g. from the OS
+	Transactional bool              `json:"transactional,omitempty"` // Stage chunks and delete them all if completion fails
 }
 
 // UploadChunkRequest represents the request to upload a chunk
@@ -71,6 +106,52 @@ type UploadChunkRequest struct {
 
 // CompleteUploadRequest represents the request to complete an upload
 type CompleteUploadRequest struct {
-	SessionID string `json:"sessionId"`
-	Checksum  string `json:"checksum"`
+	SessionID     string     `json:"sessionId"`
+	Checksum      string     `json:"checksum"`
+	TreeRoot      string     `json:"treeRoot,omitempty"`      // Merkle root of chunk SHA-256s; replaces the whole-file checksum
+	MediaTypeHint string     `json:"mediaTypeHint,omitempty"` // Overrides the hint given at start
+	DateTaken     *time.Time `json:"dateTaken,omitempty"`     // Overrides the date given at start
+}
+
+// FinalizeUploadRequest completes an upload into a chosen folder: either
+// Year and Month together, or an Album. With neither it behaves like
+// CompleteUploadRequest.
+type FinalizeUploadRequest struct {
+	CompleteUploadRequest
+	Year  string `json:"year,omitempty"`
+	Month string `json:"month,omitempty"` // Name or number, e.g. "March" or "3"
+	Album string `json:"album,omitempty"`
+}
+
+// ValidateBatchRequest lists the files a client is about to upload, so they
+// can be checked before any bytes are sent.
+type ValidateBatchRequest struct {
+	Files []BatchFile `json:"files"`
+}
+
+// BatchFile describes one file of a batch.
+type BatchFile struct {
+	FileName string `json:"fileName"`
+	FileSize int64  `json:"fileSize"`
+	Checksum string `json:"checksum,omitempty"` // SHA-256 of the content, enabling the duplicate check
+}
+
+// BatchFileResult is the verdict on one file of a batch.
+type BatchFileResult struct {
+	FileName     string            `json:"fileName"`
+	Supported    bool              `json:"supported"`              // The library recognizes the file type
+	Duplicate    bool              `json:"duplicate"`              // The library already holds the content
+	ExistingPath string            `json:"existingPath,omitempty"` // Where the duplicate is stored
+	Errors       map[string]string `json:"errors,omitempty"`       // Fields StartUpload would reject
+}
+
+// ValidateBatchResponse reports per-file results and whether the files still
+// worth uploading, those that are valid and not duplicates, fit the limits.
+type ValidateBatchResponse struct {
+	Files             []BatchFileResult `json:"files"`
+	UploadCount       int               `json:"uploadCount"`
+	UploadBytes       int64             `json:"uploadBytes"`
+	FitsQuota         bool              `json:"fitsQuota"`
+	AvailableSessions int               `json:"availableSessions"`
+	FitsSessionLimit  bool              `json:"fitsSessionLimit"` // Every upload could be started at once
 }
@@ -0,0 +1,141 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+)
+
+var camelCaseKey = regexp.MustCompile(`^[a-z][a-zA-Z0-9]*$`)
+
+func TestModelJSONRoundTrip(t *testing.T) {
+	now := time.Date(2024, 3, 15, 14, 30, 22, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		value any
+		empty func() any
+	}{
+		{"UploadSession", &UploadSession{
+			ID:           "session-1",
+			FileName:     "IMG_20240315_143022.jpg",
+			FileSize:     2048,
+			ChunkSize:    1024,
+			TotalChunks:  2,
+			UploadedSize: 1024,
+			Received:     map[int]bool{0: true},
+			Verified:     map[int]bool{0: true},
+			ChunkHashes:  map[int]string{0: "abc"},
+			Ranges:       []ByteRange{{Offset: 0, Length: 1024}},
+			Checksum:     "def",
+			TempPath:     "/tmp/session-1",
+			Metadata:     map[string]string{"camera": "X100"},
+			MediaType:    "photo",
+			CreatedAt:    now,
+			UpdatedAt:    now,
+			Status:       StatusUploading,
+			Error:        "none",
+		}, func() any { return &UploadSession{} }},
+		{"ChunkInfo", &ChunkInfo{
+			SessionID:         "session-1",
+			ChunkNumber:       1,
+			ChunkSize:         1024,
+			Checksum:          "abc",
+			ChecksumAlgorithm: "sha256",
+		}, func() any { return &ChunkInfo{} }},
+		{"UploadProgress", &UploadProgress{
+			SessionID:       "session-1",
+			FileName:        "IMG_20240315_143022.jpg",
+			UploadedBytes:   1024,
+			TotalBytes:      2048,
+			UploadedChunks:  1,
+			TotalChunks:     2,
+			PercentComplete: 50,
+			Status:          string(StatusUploading),
+			Error:           "none",
+			ReceivedRanges:  []ByteRange{{Offset: 0, Length: 1024}},
+		}, func() any { return &UploadProgress{} }},
+		{"StartUploadRequest", &StartUploadRequest{
+			FileName:      "IMG_20240315_143022.jpg",
+			FileSize:      2048,
+			ChunkSize:     1024,
+			Checksum:      "abc",
+			Metadata:      map[string]string{"camera": "X100"},
+			MediaTypeHint: "photo",
+		}, func() any { return &StartUploadRequest{} }},
+		{"UploadChunkRequest", &UploadChunkRequest{
+			SessionID:   "session-1",
+			ChunkNumber: 1,
+			ChunkSize:   1024,
+			Checksum:    "abc",
+		}, func() any { return &UploadChunkRequest{} }},
+		{"FinalizeUploadRequest", &FinalizeUploadRequest{
+			CompleteUploadRequest: CompleteUploadRequest{
+				SessionID:     "session-1",
+				Checksum:      "abc",
+				TreeRoot:      "def",
+				MediaTypeHint: "video",
+			},
+			Year:  "2024",
+			Month: "March",
+			Album: "Holiday",
+		}, func() any { return &FinalizeUploadRequest{} }},
+		{"ValidateBatchRequest", &ValidateBatchRequest{
+			Files: []BatchFile{{FileName: "IMG_20240315_143022.jpg", FileSize: 2048, Checksum: "abc"}},
+		}, func() any { return &ValidateBatchRequest{} }},
+		{"ValidateBatchResponse", &ValidateBatchResponse{
+			Files: []BatchFileResult{{
+				FileName:     "IMG_20240315_143022.jpg",
+				Supported:    true,
+				Duplicate:    true,
+				ExistingPath: "2024/March/IMG_20240315_143022.jpg",
+				Errors:       map[string]string{"fileSize": "must be > 0"},
+			}},
+			UploadCount:       1,
+			UploadBytes:       2048,
+			FitsQuota:         true,
+			AvailableSessions: 10,
+			FitsSessionLimit:  true,
+		}, func() any { return &ValidateBatchResponse{} }},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := json.Marshal(test.value)
+			if err != nil {
+				t.Fatalf("Marshal failed: %v", err)
+			}
+
+			var fields map[string]json.RawMessage
+			if err := json.Unmarshal(data, &fields); err != nil {
+				t.Fatalf("Unmarshal into map failed: %v", err)
+			}
+			for key := range fields {
+				if !camelCaseKey.MatchString(key) {
+					t.Errorf("Expected camelCase field names, got %q", key)
+				}
+			}
+
+			decoded := test.empty()
+			if err := json.Unmarshal(data, decoded); err != nil {
+				t.Fatalf("Unmarshal failed: %v", err)
+			}
+			if !reflect.DeepEqual(decoded, test.value) {
+				t.Errorf("Expected %+v after a round trip, got %+v", test.value, decoded)
+			}
+		})
+	}
+}
+
+func TestUploadSessionLegacyFileName(t *testing.T) {
+	// Sessions stored before the switch to camelCase used "filename"
+	var session UploadSession
+	if err := json.Unmarshal([]byte(`{"id":"session-1","filename":"photo.jpg"}`), &session); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if session.FileName != "photo.jpg" {
+		t.Errorf("Expected file name photo.jpg, got %q", session.FileName)
+	}
+}
@@ -13,14 +13,22 @@ import (
 )
 
 type Manager struct {
-	mediaPath string
-	extractor *media.Extractor
+	mediaPath   string
+	extractor   *media.Extractor
+	monthFormat media.MonthFormat
 }
 
 func NewManager(mediaPath string) *Manager {
+	return NewManagerWithMonthFormat(mediaPath, media.DefaultMonthFormat)
+}
+
+// NewManagerWithMonthFormat creates a manager whose month folders are named
+// and parsed using format.
+func NewManagerWithMonthFormat(mediaPath string, format media.MonthFormat) *Manager {
 	return &Manager{
-		mediaPath: mediaPath,
-		extractor: media.NewExtractor(),
+		mediaPath:   mediaPath,
+		extractor:   media.NewExtractor(),
+		monthFormat: format,
 	}
 }
 
@@ -77,7 +85,7 @@ func (m *Manager) getTargetDirectory(dateTaken *time.Time) string {
 	}
 
 	year := fmt.Sprintf("%04d", dateTaken.Year())
-	month := fmt.Sprintf("%02d", dateTaken.Month())
+	month := m.monthFormat.FolderName(dateTaken.Month())
 
 	return filepath.Join(m.mediaPath, year, month)
 }
@@ -228,6 +236,9 @@ func (m *Manager) GetAvailableDates() ([]DateInfo, error) {
 			if !monthEntry.IsDir() {
 				continue
 			}
+			if _, ok := media.ParseAnyMonthFolder(yearEntry.Name(), monthEntry.Name()); !ok {
+				continue // Event, burst and other non-month folders
+			}
 
 			dates = append(dates, DateInfo{
 				Year:  yearEntry.Name(),
@@ -0,0 +1,105 @@
+package upload
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrUnsupportedChecksumAlgorithm = errors.New("unsupported checksum algorithm")
+	ErrMalformedChecksum            = errors.New("malformed checksum")
+)
+
+// DefaultChecksumAlgorithm is assumed when a client sends a checksum without
+// naming its algorithm.
+const DefaultChecksumAlgorithm = "sha256"
+
+var checksumAlgorithms = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// ChecksumAlgorithms lists, sorted, the algorithms chunk checksums may use.
+func ChecksumAlgorithms() []string {
+	algorithms := make([]string, 0, len(checksumAlgorithms))
+	for algorithm := range checksumAlgorithms {
+		algorithms = append(algorithms, algorithm)
+	}
+	sort.Strings(algorithms)
+	return algorithms
+}
+
+// Checksum is a client-supplied chunk digest. An empty Value skips
+// verification; an empty Algorithm means DefaultChecksumAlgorithm.
+type Checksum struct {
+	Algorithm string
+	Value     string
+}
+
+// SHA256Checksum wraps a hex SHA-256 digest, the format UploadChunk expects.
+func SHA256Checksum(value string) Checksum {
+	return Checksum{Algorithm: DefaultChecksumAlgorithm, Value: value}
+}
+
+// Validate reports whether the checksum names a supported algorithm and is
+// well-formed for it, without anything to compare it against yet.
+func (c Checksum) Validate() error {
+	_, err := c.newHash()
+	return err
+}
+
+// newHash validates the checksum up front, so a client using the wrong
+// algorithm or encoding gets told so instead of a mismatch, and returns a hash
+// to compute the actual digest with. It returns nil when there is nothing to
+// verify.
+func (c Checksum) newHash() (hash.Hash, error) {
+	algorithm := c.algorithm()
+	newHash, ok := checksumAlgorithms[algorithm]
+	if !ok {
+		return nil, fmt.Errorf("%w %q, expected one of md5, sha1, sha256, sha512", ErrUnsupportedChecksumAlgorithm, c.Algorithm)
+	}
+	if c.Value == "" {
+		return nil, nil
+	}
+
+	h := newHash()
+	if decoded, err := hex.DecodeString(c.Value); err != nil || len(decoded) != h.Size() {
+		return nil, fmt.Errorf("%w: %s checksums are %d hex characters, got %q", ErrMalformedChecksum, algorithm, 2*h.Size(), c.Value)
+	}
+	return h, nil
+}
+
+// algorithm returns the checksum's algorithm in the form checksumAlgorithms
+// is keyed by.
+func (c Checksum) algorithm() string {
+	algorithm := strings.ReplaceAll(strings.ToLower(c.Algorithm), "-", "")
+	if algorithm == "" {
+		return DefaultChecksumAlgorithm
+	}
+	return algorithm
+}
+
+// sha256Digest returns the hex digest accumulated in h when c is a SHA-256
+// checksum, which tree-hash completion can reuse, and "" otherwise.
+func (c Checksum) sha256Digest(h hash.Hash) string {
+	if h == nil || c.algorithm() != "sha256" {
+		return ""
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// matches reports whether the digest accumulated in h equals the expected
+// value, ignoring hex case.
+func (c Checksum) matches(h hash.Hash) bool {
+	return strings.EqualFold(hex.EncodeToString(h.Sum(nil)), c.Value)
+}
@@ -0,0 +1,230 @@
+package upload
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/Steven-harris/sortify/backend/internal/models"
+)
+
+// FileSessionStore keeps upload sessions in memory and writes each one to its
+// own JSON file in a directory whenever it changes, so uploads in progress
+// survive a restart and clients can carry on sending chunks with the same
+// session ID, while a chunk only costs rewriting its own session. Only one
+// process may use the directory; share sessions between instances with
+// RedisSessionStore instead.
+type FileSessionStore struct {
+	dir      string
+	mutex    sync.Mutex
+	sessions map[string]*models.UploadSession
+}
+
+// NewFileSessionStore loads the sessions saved in dir, creating it if
+// needed. Sessions that can't be resumed are pruned as they load, along with
+// their temp files: completed, failed and cancelled ones, and any whose temp
+// file has gone.
+func NewFileSessionStore(dir string) (*FileSessionStore, error) {
+	store := &FileSessionStore{dir: dir, sessions: make(map[string]*models.UploadSession)}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return store, fmt.Errorf("failed to create session store directory: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return store, fmt.Errorf("failed to read session store: %w", err)
+	}
+
+	pruned := 0
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		if strings.HasSuffix(entry.Name(), ".tmp") {
+			os.Remove(path) // Left by a write a crash interrupted
+			continue
+		}
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return store, fmt.Errorf("failed to read session store: %w", err)
+		}
+		var session models.UploadSession
+		if err := json.Unmarshal(data, &session); err != nil || session.ID == "" {
+			slog.Warn("Skipping unreadable upload session", "path", path, "error", err)
+			continue
+		}
+
+		if session.Status.Terminal() {
+			os.Remove(session.TempPath)
+			os.Remove(path)
+			pruned++
+			continue
+		}
+		if _, err := os.Stat(session.TempPath); err != nil {
+			slog.Warn("Dropping upload session whose temp file is gone", "sessionId", session.ID, "path", session.TempPath)
+			os.Remove(path)
+			pruned++
+			continue
+		}
+		if session.Received == nil {
+			session.Received = make(map[int]bool)
+		}
+		store.sessions[session.ID] = &session
+	}
+
+	slog.Info("Upload sessions restored", "sessions", len(store.sessions), "pruned", pruned, "path", dir)
+	if pruned > 0 {
+		if err := syncDir(dir); err != nil {
+			return store, err
+		}
+	}
+	return store, nil
+}
+
+func (s *FileSessionStore) Get(id string) (*models.UploadSession, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, exists := s.sessions[id]
+	if !exists {
+		return nil, ErrSessionNotFound
+	}
+	return copySession(session), nil
+}
+
+func (s *FileSessionStore) Put(session *models.UploadSession) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.commit(session.ID, copySession(session))
+}
+
+func (s *FileSessionStore) Delete(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, exists := s.sessions[id]; !exists {
+		return nil
+	}
+	return s.commit(id, nil)
+}
+
+func (s *FileSessionStore) List() ([]*models.UploadSession, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	sessions := make([]*models.UploadSession, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, copySession(session))
+	}
+	return sessions, nil
+}
+
+func (s *FileSessionStore) Update(id string, fn func(session *models.UploadSession) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, exists := s.sessions[id]
+	if !exists {
+		return ErrSessionNotFound
+	}
+
+	updated := copySession(session)
+	if err := fn(updated); err != nil {
+		return err
+	}
+	return s.commit(id, updated)
+}
+
+func (s *FileSessionStore) DeleteIf(id string, cond func(session *models.UploadSession) bool) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, exists := s.sessions[id]
+	if !exists || !cond(copySession(session)) {
+		return false, nil
+	}
+	if err := s.commit(id, nil); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// commit stores session under id, or removes it when nil, writing only that
+// session's file. Memory is updated once the file is, so it never runs ahead
+// of the disk. The caller holds the mutex.
+func (s *FileSessionStore) commit(id string, session *models.UploadSession) error {
+	if filepath.Base(id) != id || id == "." {
+		return fmt.Errorf("invalid session ID %q", id)
+	}
+	path := filepath.Join(s.dir, id+".json")
+
+	if session == nil {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove session: %w", err)
+		}
+		if err := syncDir(s.dir); err != nil {
+			return err
+		}
+		delete(s.sessions, id)
+		return nil
+	}
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+	if err := writeFileSynced(path, data); err != nil {
+		return err
+	}
+	s.sessions[id] = session
+	return nil
+}
+
+// writeFileSynced replaces path with data atomically and durably: the data is
+// flushed before the rename, and the directory after it, so a crash leaves
+// either the old file or the new one.
+func writeFileSynced(path string, data []byte) error {
+	tmpPath := path + ".tmp"
+	file, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write session: %w", err)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace session: %w", err)
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+// syncDir flushes dir's entries, making renames and removals in it durable.
+func syncDir(dir string) error {
+	file, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open session store directory: %w", err)
+	}
+	defer file.Close()
+
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync session store directory: %w", err)
+	}
+	return nil
+}
@@ -2,60 +2,191 @@ package upload
 
 import (
 	"crypto/sha256"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"math"
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"github.com/Steven-harris/sortify/backend/internal/clock"
 	"github.com/Steven-harris/sortify/backend/internal/models"
 )
 
+var ErrTooManySessions = errors.New("maximum concurrent uploads reached")
+
+// ErrQuotaExceeded rejects a session whose upload, with the library and the
+// uploads already in progress, would exceed the quota it was created under.
+var ErrQuotaExceeded = errors.New("upload would exceed the library storage quota")
+
+// Quota caps the bytes the library and its in-progress uploads may hold
+// together.
+type Quota struct {
+	Limit int64                 // Zero disables the quota
+	Used  func() (int64, error) // Bytes the library already holds
+}
+
+// ErrSessionClosed rejects chunks for a completed, failed or cancelled
+// session.
+var ErrSessionClosed = errors.New("upload session is closed")
+
+// Upload errors the client caused by describing or sending chunks that don't
+// fit the file.
+var (
+	ErrInvalidChunking = errors.New("invalid upload size")
+	ErrChunkOverrun    = errors.New("chunk overruns its slot")
+	ErrChunkOutOfRange = errors.New("chunk number out of range")
+)
+
+// Completion errors the client caused, which resending the upload differently
+// can fix, as opposed to failures on the server's side.
+var (
+	ErrSizeMismatch     = errors.New("uploaded size mismatch")
+	ErrIncompleteUpload = errors.New("incomplete upload")
+	ErrChecksumMismatch = errors.New("file checksum mismatch")
+)
+
 type Manager struct {
-	sessions    map[string]*models.UploadSession
+	sessions    SessionStore
 	tempDir     string
 	maxSessions int
+	options     Options
+	clock       clock.Clock
 	mutex       sync.RWMutex
+
+	fileChecksum  func(filePath string) (string, error)     // Replaced in tests to count full reads
+	chunkReadBack func(session *models.UploadSession) error // Replaced in tests to count read-backs
+	openChunkFile func(filePath string) (chunkFile, error)  // Replaced in tests to inject write failures
+
+	stopSweep chan struct{} // Nil when no sweeper runs
+	sweepDone chan struct{}
+	stopOnce  sync.Once
+
+	fallbackOnce sync.Once // Warns once that eager preallocation fell back to sparse
 }
 
 func NewManager(tempDir string, maxSessions int) *Manager {
+	return NewManagerWithOptions(tempDir, maxSessions, DefaultOptions())
+}
+
+func NewManagerWithOptions(tempDir string, maxSessions int, options Options) *Manager {
 	os.MkdirAll(tempDir, 0755)
 
-	return &Manager{
-		sessions:    make(map[string]*models.UploadSession),
+	store := options.Store
+	if store == nil {
+		store = NewMemorySessionStore()
+	}
+
+	clk := options.Clock
+	if clk == nil {
+		clk = clock.System
+	}
+
+	if options.Preallocation != PreallocateSparse && options.Preallocation != PreallocateEager {
+		if options.Preallocation != "" {
+			slog.Warn("Unknown preallocation strategy, allocating sparse files", "strategy", options.Preallocation)
+		}
+		options.Preallocation = PreallocateSparse
+	}
+
+	m := &Manager{
+		sessions:    store,
 		tempDir:     tempDir,
 		maxSessions: maxSessions,
+		options:     options,
+		clock:       clk,
+
+		fileChecksum:  calculateFileChecksum,
+		chunkReadBack: verifyChunkHashes,
+		openChunkFile: openChunkFile,
 	}
+	if options.SessionTTL > 0 {
+		m.startSweeper()
+	}
+	return m
+}
+
+// NewManagerWithTTL is NewManager with stale sessions swept away: every
+// sweepInterval, sessions other than paused ones idle for longer than ttl
+// are removed.
+// Call Stop when done with the manager.
+func NewManagerWithTTL(tempDir string, maxSessions int, ttl, sweepInterval time.Duration) *Manager {
+	options := DefaultOptions()
+	options.SessionTTL = ttl
+	options.SweepInterval = sweepInterval
+	return NewManagerWithOptions(tempDir, maxSessions, options)
 }
 
+// CreateSession starts a new upload session. Only sessions that can still
+// receive chunks count against maxSessions; completed, failed and cancelled
+// sessions awaiting cleanup don't. Counting and storing happen under the
+// write lock so concurrent calls can't both take the last slot, and since a
+// session never leaves a terminal status, a session finishing mid-count can
+// only make the check stricter, never admit one too many.
 func (m *Manager) CreateSession(req *models.StartUploadRequest) (*models.UploadSession, error) {
+	return m.CreateSessionWithinQuota(req, Quota{})
+}
+
+// CreateSessionWithinQuota is CreateSession for an upload that must fit
+// quota. The check and the reservation the new session makes happen under the
+// same lock, so concurrent uploads can't each see room for themselves and
+// jointly overshoot the quota.
+func (m *Manager) CreateSessionWithinQuota(req *models.StartUploadRequest, quota Quota) (*models.UploadSession, error) {
+	if err := validateMetadata(req.Metadata, m.options.Metadata); err != nil {
+		return nil, err
+	}
+	if err := validateChunking(req.FileSize, req.ChunkSize); err != nil {
+		return nil, err
+	}
+
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	if len(m.sessions) >= m.maxSessions {
-		return nil, fmt.Errorf("maximum concurrent uploads reached")
+	active, err := m.activeSessions()
+	if err != nil {
+		return nil, err
+	}
+	if active >= m.maxSessions {
+		return nil, ErrTooManySessions
+	}
+	if err := m.checkQuota(quota, req.FileSize); err != nil {
+		return nil, err
 	}
 
-	sessionID := generateSessionID()
+	now := m.clock.Now()
+	sessionID := generateSessionID(now)
 
-	totalChunks := int((req.FileSize + req.ChunkSize - 1) / req.ChunkSize)
+	totalChunks := int(chunkCount(req.FileSize, req.ChunkSize))
 
 	tempPath := filepath.Join(m.tempDir, sessionID+".tmp")
+	if req.Transactional {
+		tempPath = m.stagingPath(sessionID)
+		if err := os.MkdirAll(filepath.Dir(tempPath), 0755); err != nil {
+			return nil, fmt.Errorf("failed to create staging directory: %w", err)
+		}
+	}
 
 	session := &models.UploadSession{
-		ID:           sessionID,
-		FileName:     req.FileName,
-		FileSize:     req.FileSize,
-		ChunkSize:    req.ChunkSize,
-		TotalChunks:  totalChunks,
-		UploadedSize: 0,
-		Checksum:     req.Checksum,
-		TempPath:     tempPath,
-		Metadata:     req.Metadata,
-		CreatedAt:    time.Now(),
-		UpdatedAt:    time.Now(),
-		Status:       models.StatusInitialized,
+		ID:            sessionID,
+		FileName:      req.FileName,
+		FileSize:      req.FileSize,
+		ChunkSize:     req.ChunkSize,
+		TotalChunks:   totalChunks,
+		UploadedSize:  0,
+		Received:      make(map[int]bool),
+		Checksum:      req.Checksum,
+		TempPath:      tempPath,
+		Metadata:      req.Metadata,
+		MediaType:     req.MediaTypeHint,
+		DateTaken:     req.DateTaken,
+		Transactional: req.Transactional,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+		Status:        models.StatusInitialized,
 	}
 
 	file, err := os.Create(tempPath)
@@ -63,84 +194,524 @@ func (m *Manager) CreateSession(req *models.StartUploadRequest) (*models.UploadS
 		return nil, fmt.Errorf("failed to create temporary file: %w", err)
 	}
 
-	if err := file.Truncate(req.FileSize); err != nil {
+	if err := m.allocate(file, req.FileSize); err != nil {
 		file.Close()
 		os.Remove(tempPath)
-		return nil, fmt.Errorf("failed to allocate file space: %w", err)
+		return nil, err
 	}
 	file.Close()
 
-	m.sessions[sessionID] = session
+	if err := m.sessions.Put(session); err != nil {
+		os.Remove(tempPath)
+		return nil, fmt.Errorf("failed to store session: %w", err)
+	}
 	return session, nil
 }
 
-func (m *Manager) GetSession(sessionID string) (*models.UploadSession, error) {
+// validateChunking rejects sizes no sequence of chunks can fill. The chunk
+// count is derived from them, so anything accepted here has a last chunk
+// that ends exactly at fileSize.
+func validateChunking(fileSize, chunkSize int64) error {
+	if fileSize < 0 {
+		return fmt.Errorf("%w: file size %d is negative", ErrInvalidChunking, fileSize)
+	}
+	if chunkSize <= 0 {
+		return fmt.Errorf("%w: chunk size must be positive, got %d", ErrInvalidChunking, chunkSize)
+	}
+	if chunkCount(fileSize, chunkSize) > math.MaxInt32 {
+		return fmt.Errorf("%w: %d byte chunks make too many for a %d byte file", ErrInvalidChunking, chunkSize, fileSize)
+	}
+	return nil
+}
+
+// chunkCount is how many chunks of chunkSize it takes to cover fileSize,
+// rounding up without the overflow fileSize+chunkSize-1 risks for a huge
+// chunkSize. An empty file takes none.
+func chunkCount(fileSize, chunkSize int64) int64 {
+	if fileSize <= 0 {
+		return 0
+	}
+	return (fileSize-1)/chunkSize + 1
+}
+
+// checkWritable rejects chunks for sessions in a terminal status. Writing
+// would put them back to uploading, reviving a session that no longer holds
+// an upload slot without passing CreateSession's admission check.
+func checkWritable(session *models.UploadSession) error {
+	if session.Status.Terminal() {
+		return fmt.Errorf("%w: session is %s", ErrSessionClosed, session.Status)
+	}
+	return nil
+}
+
+// checkChunkNumber rejects chunk numbers outside the session, which would
+// otherwise be written before the start of the file or past its end.
+func checkChunkNumber(session *models.UploadSession, chunkNumber int) error {
+	if chunkNumber < 0 || chunkNumber >= session.TotalChunks {
+		return fmt.Errorf("%w: %d is not in [0,%d)", ErrChunkOutOfRange, chunkNumber, session.TotalChunks)
+	}
+	return nil
+}
+
+// checkChunkLength rejects a chunk longer than its slot, which would clobber
+// the next chunk or grow the file past its declared size.
+func checkChunkLength(session *models.UploadSession, offset, length int64) error {
+	maxLength := min(session.ChunkSize, session.FileSize-offset)
+	if length > maxLength {
+		return fmt.Errorf("%w: chunk exceeds its expected length of %d bytes", ErrChunkOverrun, max(maxLength, 0))
+	}
+	return nil
+}
+
+// TempDir returns the directory in-flight upload data is written to.
+func (m *Manager) TempDir() string {
+	return m.tempDir
+}
+
+// MaxSessions returns how many sessions may be receiving chunks at once.
+func (m *Manager) MaxSessions() int {
+	return m.maxSessions
+}
+
+// ActiveSessions returns how many sessions currently hold an upload slot.
+func (m *Manager) ActiveSessions() (int, error) {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 
-	session, exists := m.sessions[sessionID]
-	if !exists {
-		return nil, fmt.Errorf("session not found")
+	return m.activeSessions()
+}
+
+// AvailableSessions returns how many more sessions CreateSession would admit
+// right now.
+func (m *Manager) AvailableSessions() int {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	active, err := m.activeSessions()
+	if err != nil {
+		slog.Error("Failed to count sessions", "error", err)
+		return 0
 	}
+	return max(m.maxSessions-active, 0)
+}
 
-	return session, nil
+// activeSessions counts the sessions that can still receive chunks. Callers
+// hold the mutex.
+func (m *Manager) activeSessions() (int, error) {
+	sessions, err := m.sessions.List()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	active := 0
+	for _, session := range sessions {
+		if !session.Status.Terminal() {
+			active++
+		}
+	}
+	return active, nil
+}
+
+// ReservedBytes returns the declared size of every session still in progress,
+// i.e. bytes that will land in the library once those uploads complete.
+func (m *Manager) ReservedBytes() int64 {
+	total, err := m.reservedBytes()
+	if err != nil {
+		slog.Error("Failed to list sessions", "error", err)
+		return 0
+	}
+	return total
+}
+
+func (m *Manager) reservedBytes() (int64, error) {
+	sessions, err := m.sessions.List()
+	if err != nil {
+		return 0, err
+	}
+
+	// Failed and cancelled uploads will never land, and completed ones are
+	// counted by the library once organized
+	var total int64
+	for _, session := range sessions {
+		if !session.Status.Terminal() {
+			total += session.FileSize
+		}
+	}
+	return total, nil
+}
+
+// checkQuota rejects size more bytes that don't fit quota. Callers hold the
+// mutex, so the sessions reserved can't change until the new one is stored.
+func (m *Manager) checkQuota(quota Quota, size int64) error {
+	if quota.Limit <= 0 {
+		return nil
+	}
+	used, err := quota.Used()
+	if err != nil {
+		return fmt.Errorf("failed to measure library size: %w", err)
+	}
+	reserved, err := m.reservedBytes()
+	if err != nil {
+		return fmt.Errorf("failed to list sessions: %w", err)
+	}
+	if used+reserved+size > quota.Limit {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+func (m *Manager) GetSession(sessionID string) (*models.UploadSession, error) {
+	return m.sessions.Get(sessionID)
+}
+
+// update applies fn to the stored session, atomically when the store supports it.
+func (m *Manager) update(sessionID string, fn func(session *models.UploadSession) error) error {
+	if updater, ok := m.sessions.(SessionUpdater); ok {
+		return updater.Update(sessionID, fn)
+	}
+
+	session, err := m.sessions.Get(sessionID)
+	if err != nil {
+		return err
+	}
+	if err := fn(session); err != nil {
+		return err
+	}
+	return m.sessions.Put(session)
+}
+
+// deleteIf deletes the stored session if it still satisfies cond, atomically
+// when the store supports it, and reports whether it did.
+func (m *Manager) deleteIf(sessionID string, cond func(session *models.UploadSession) bool) (bool, error) {
+	if remover, ok := m.sessions.(SessionRemover); ok {
+		return remover.DeleteIf(sessionID, cond)
+	}
+
+	session, err := m.sessions.Get(sessionID)
+	if errors.Is(err, ErrSessionNotFound) {
+		return false, nil
+	}
+	if err != nil || !cond(session) {
+		return false, err
+	}
+	if err := m.sessions.Delete(sessionID); err != nil && !errors.Is(err, ErrSessionNotFound) {
+		return false, err
+	}
+	return true, nil
 }
 
 func (m *Manager) UploadChunk(sessionID string, chunkNumber int, chunkData []byte, expectedChecksum string) error {
+	return m.UploadChunkWithChecksum(sessionID, chunkNumber, chunkData, SHA256Checksum(expectedChecksum))
+}
+
+// UploadChunkWithChecksum is UploadChunk for a checksum in any supported
+// algorithm. A malformed checksum or unknown algorithm is rejected before
+// anything is written.
+func (m *Manager) UploadChunkWithChecksum(sessionID string, chunkNumber int, chunkData []byte, checksum Checksum) error {
+	hash, err := checksum.newHash()
+	if err != nil {
+		return err
+	}
+
+	m.mutex.RLock()
+	session, err := m.sessions.Get(sessionID)
+	m.mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+	if err := checkWritable(session); err != nil {
+		return err
+	}
+	if err := checkChunkNumber(session, chunkNumber); err != nil {
+		return err
+	}
+
+	if hash != nil {
+		hash.Write(chunkData)
+		if !checksum.matches(hash) {
+			return fmt.Errorf("chunk checksum mismatch")
+		}
+	}
+
+	offset := int64(chunkNumber) * session.ChunkSize
+	if err := checkChunkLength(session, offset, int64(len(chunkData))); err != nil {
+		return err
+	}
+
+	// Written, and retried, without the lock so a slow disk stalls only this
+	// upload
+	if err := m.writeChunkAt(sessionID, session.TempPath, offset, chunkData); err != nil {
+		m.FailSession(sessionID, err.Error())
+		return err
+	}
+
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	session, exists := m.sessions[sessionID]
-	if !exists {
-		return fmt.Errorf("session not found")
+	return m.update(sessionID, func(session *models.UploadSession) error {
+		// The session may have closed while the chunk was written
+		if err := checkWritable(session); err != nil {
+			return err
+		}
+		recordWrite(session, offset, int64(len(chunkData)))
+		session.Received[chunkNumber] = true
+		markVerified(session, chunkNumber, hash != nil)
+		recordChunkHash(session, chunkNumber, checksum.sha256Digest(hash))
+		session.UpdatedAt = m.clock.Now()
+		session.Status = models.StatusUploading
+		return nil
+	})
+}
+
+// recordWrite notes that length bytes landed at offset and returns the chunk
+// they exactly fill, or -1. Every chunk the write overlaps loses the checksum
+// it was verified against, since its bytes changed, and counts as received
+// once its whole slot has been written. UploadedSize follows the written
+// ranges, so a chunk sent again after a resume isn't counted twice.
+func recordWrite(session *models.UploadSession, offset, length int64) int {
+	// Sessions stored before ranges were recorded keep counting bytes
+	legacy := len(session.Ranges) == 0 && session.UploadedSize > 0
+	session.Ranges = addRange(session.Ranges, offset, length)
+	session.SentBytes += max(length, 0)
+	if legacy {
+		session.UploadedSize += length
+	} else {
+		session.UploadedSize = coveredBytes(session.Ranges)
+	}
+	if session.Received == nil {
+		session.Received = make(map[int]bool)
+	}
+	if length <= 0 || session.ChunkSize <= 0 {
+		return -1
 	}
 
-	hash := sha256.Sum256(chunkData)
-	actualChecksum := fmt.Sprintf("%x", hash)
-	if expectedChecksum != "" && actualChecksum != expectedChecksum {
-		return fmt.Errorf("chunk checksum mismatch")
+	filled := -1
+	first := int(offset / session.ChunkSize)
+	last := int((offset + length - 1) / session.ChunkSize)
+	for chunkNumber := first; chunkNumber <= last; chunkNumber++ {
+		start := int64(chunkNumber) * session.ChunkSize
+		size := min(session.ChunkSize, session.FileSize-start)
+		if start == offset && size == length {
+			filled = chunkNumber
+		}
+		delete(session.Verified, chunkNumber)
+		delete(session.ChunkHashes, chunkNumber)
+		if covers(session.Ranges, start, size) {
+			session.Received[chunkNumber] = true
+		}
 	}
+	return filled
+}
 
-	offset := int64(chunkNumber) * session.ChunkSize
+// forgetWrite undoes recordWrite's ranges for rejected bytes, which may have
+// overwritten earlier good copies of the chunks they overlap.
+func forgetWrite(session *models.UploadSession, offset, length int64) {
+	// Sessions stored before ranges were recorded count bytes beyond their
+	// ranges, as in recordWrite; only what this write added comes off
+	before := coveredBytes(session.Ranges)
+	legacy := session.UploadedSize > before
+	session.Ranges = removeRange(session.Ranges, offset, length)
+	session.SentBytes = max(session.SentBytes-max(length, 0), 0)
+	if legacy {
+		session.UploadedSize = max(session.UploadedSize-(before-coveredBytes(session.Ranges)), 0)
+	} else {
+		session.UploadedSize = coveredBytes(session.Ranges)
+	}
+	if length <= 0 || session.ChunkSize <= 0 {
+		return
+	}
+	for chunkNumber := int(offset / session.ChunkSize); int64(chunkNumber)*session.ChunkSize < offset+length; chunkNumber++ {
+		delete(session.Received, chunkNumber)
+		delete(session.Verified, chunkNumber)
+		delete(session.ChunkHashes, chunkNumber)
+	}
+}
+
+// markVerified records whether the bytes just written for chunkNumber were
+// checked, forgetting an earlier verified copy they replaced.
+func markVerified(session *models.UploadSession, chunkNumber int, verified bool) {
+	if !verified {
+		delete(session.Verified, chunkNumber)
+		return
+	}
+	if session.Verified == nil {
+		session.Verified = make(map[int]bool)
+	}
+	session.Verified[chunkNumber] = true
+}
+
+// UploadChunkFrom streams a chunk from r straight into the temp file. Unlike
+// UploadChunk the body is not buffered, so the write happens outside the
+// manager lock and length and checksum can only be verified afterwards; a
+// rejected chunk is marked missing so it must be sent again.
+func (m *Manager) UploadChunkFrom(sessionID string, chunkNumber int, r io.Reader, checksum Checksum) error {
+	return m.streamChunk(sessionID, r, checksum, func(session *models.UploadSession) (chunkPlacement, error) {
+		if err := checkChunkNumber(session, chunkNumber); err != nil {
+			return chunkPlacement{}, err
+		}
+		offset := int64(chunkNumber) * session.ChunkSize
+		maxLength := min(session.ChunkSize, session.FileSize-offset)
+		return chunkPlacement{offset: offset, maxLength: maxLength, chunkNumber: chunkNumber}, nil
+	})
+}
+
+// UploadChunkAt is UploadChunkFrom for a chunk placed at an explicit byte
+// offset instead of one derived from its number and the session's chunk size.
+// A client resuming with a different chunk size, e.g. from another device,
+// sends offsets so its bytes still land where they belong; the upload is
+// complete once the written ranges cover the whole file.
+func (m *Manager) UploadChunkAt(sessionID string, offset int64, r io.Reader, checksum Checksum) error {
+	return m.streamChunk(sessionID, r, checksum, func(session *models.UploadSession) (chunkPlacement, error) {
+		if offset < 0 || offset >= session.FileSize {
+			return chunkPlacement{}, fmt.Errorf("chunk offset %d out of range", offset)
+		}
+		return chunkPlacement{offset: offset, maxLength: session.FileSize - offset, chunkNumber: -1}, nil
+	})
+}
 
-	file, err := os.OpenFile(session.TempPath, os.O_WRONLY, 0644)
+// chunkPlacement is where a streamed chunk goes: its offset, the most bytes
+// it may hold and, for chunks sent by number, which chunk it is.
+type chunkPlacement struct {
+	offset      int64
+	maxLength   int64
+	chunkNumber int // -1 when placed by offset
+}
+
+// streamChunk writes r at the place place picks for the session.
+func (m *Manager) streamChunk(sessionID string, r io.Reader, checksum Checksum, place func(session *models.UploadSession) (chunkPlacement, error)) error {
+	hash, err := checksum.newHash()
 	if err != nil {
-		return fmt.Errorf("failed to open temporary file: %w", err)
+		return err
+	}
+
+	m.mutex.RLock()
+	session, err := m.sessions.Get(sessionID)
+	m.mutex.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	if err := checkWritable(session); err != nil {
+		return err
+	}
+
+	// Placed before the file is opened, so a chunk that doesn't belong in
+	// the session never touches it
+	placement, err := place(session)
+	if err != nil {
+		return err
+	}
+
+	// Only opening can be retried; the body is consumed as it is written
+	var file chunkFile
+	err = m.retryIO(sessionID, func() (err error) {
+		file, err = m.openChunkFile(session.TempPath)
+		return err
+	})
+	if err != nil {
+		err = fmt.Errorf("failed to open temporary file: %w", err)
+		m.FailSession(sessionID, err.Error())
+		return err
 	}
 	defer file.Close()
 
-	if _, err := file.Seek(offset, 0); err != nil {
-		return fmt.Errorf("failed to seek to chunk position: %w", err)
+	var writer io.Writer = io.NewOffsetWriter(file, placement.offset)
+	if hash != nil {
+		writer = io.MultiWriter(writer, hash)
 	}
 
-	if _, err := file.Write(chunkData); err != nil {
-		return fmt.Errorf("failed to write chunk data: %w", err)
+	// Never write past the chunk's slot, which would clobber its neighbour
+	written, err := io.Copy(writer, io.LimitReader(r, placement.maxLength))
+
+	var rejected error
+	if err != nil {
+		// Typically a client gone mid-body; what arrived is already written
+		rejected = fmt.Errorf("failed to write chunk data: %w", err)
+	} else if n, _ := r.Read(make([]byte, 1)); n > 0 {
+		rejected = fmt.Errorf("%w: chunk exceeds its expected length of %d bytes", ErrChunkOverrun, placement.maxLength)
+	} else if hash != nil && !checksum.matches(hash) {
+		rejected = fmt.Errorf("chunk checksum mismatch")
 	}
 
-	session.UploadedSize += int64(len(chunkData))
-	session.UpdatedAt = time.Now()
-	session.Status = models.StatusUploading
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 
-	return nil
+	if rejected != nil {
+		// The bad or partial bytes may have overwritten an earlier good copy.
+		// They were sent all the same, so they are recorded before being
+		// forgotten along with that copy.
+		m.update(sessionID, func(session *models.UploadSession) error {
+			recordWrite(session, placement.offset, written)
+			forgetWrite(session, placement.offset, written)
+			if placement.chunkNumber >= 0 {
+				delete(session.Received, placement.chunkNumber)
+				delete(session.Verified, placement.chunkNumber)
+				delete(session.ChunkHashes, placement.chunkNumber)
+			}
+			return nil
+		})
+		return rejected
+	}
+
+	return m.update(sessionID, func(session *models.UploadSession) error {
+		// The session may have closed while the body streamed in
+		if err := checkWritable(session); err != nil {
+			return err
+		}
+		chunkNumber := recordWrite(session, placement.offset, written)
+		if placement.chunkNumber >= 0 {
+			chunkNumber = placement.chunkNumber
+			session.Received[chunkNumber] = true
+		}
+		if chunkNumber >= 0 {
+			markVerified(session, chunkNumber, hash != nil)
+			recordChunkHash(session, chunkNumber, checksum.sha256Digest(hash))
+		}
+		session.UpdatedAt = m.clock.Now()
+		session.Status = models.StatusUploading
+		return nil
+	})
+}
+
+// checkCompletable reports whether a completion repeated on an already
+// completed session, which succeeds again without re-verifying so organizing
+// can be retried, and refuses failed and cancelled sessions.
+func checkCompletable(session *models.UploadSession) (bool, error) {
+	switch session.Status {
+	case models.StatusCompleted:
+		return true, nil
+	case models.StatusFailed, models.StatusCancelled:
+		return false, fmt.Errorf("%w: session is %s", ErrSessionClosed, session.Status)
+	}
+	return false, nil
 }
 
 func (m *Manager) CompleteUpload(sessionID string, expectedChecksum string) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	session, exists := m.sessions[sessionID]
-	if !exists {
-		return fmt.Errorf("session not found")
+	session, err := m.sessions.Get(sessionID)
+	if err != nil {
+		return err
 	}
-
-	if session.UploadedSize != session.FileSize {
-		return fmt.Errorf("uploaded size mismatch: expected %d, got %d", session.FileSize, session.UploadedSize)
+	if done, err := checkCompletable(session); done || err != nil {
+		return err
+	}
+	if err := checkReceived(session); err != nil {
+		return err
 	}
 
-	if expectedChecksum != "" || session.Checksum != "" {
-		actualChecksum, err := m.calculateFileChecksum(session.TempPath)
+	// A checksum the client declared is always verified; the skip only
+	// applies to reading back chunks checked as they were written
+	if expectedChecksum == "" && session.Checksum == "" {
+		if m.options.SkipFullChecksum && allChunksVerified(session) {
+			slog.Info("Skipping chunk read-back, all chunks were verified", "sessionId", sessionID)
+		} else if err := m.chunkReadBack(session); err != nil {
+			return m.reject(session, err)
+		}
+	} else {
+		actualChecksum, err := m.fileChecksum(session.TempPath)
 		if err != nil {
-			return fmt.Errorf("failed to calculate file checksum: %w", err)
+			return m.reject(session, fmt.Errorf("failed to calculate file checksum: %w", err))
 		}
 
 		checksumToVerify := expectedChecksum
@@ -148,24 +719,21 @@ func (m *Manager) CompleteUpload(sessionID string, expectedChecksum string) erro
 			checksumToVerify = session.Checksum
 		}
 
-		if checksumToVerify != "" && actualChecksum != checksumToVerify {
-			return fmt.Errorf("file checksum mismatch")
+		if actualChecksum != checksumToVerify {
+			return m.reject(session, ErrChecksumMismatch)
 		}
 	}
 
-	session.Status = models.StatusCompleted
-	session.UpdatedAt = time.Now()
-
-	return nil
+	return m.commit(session)
 }
 
 func (m *Manager) GetProgress(sessionID string) (*models.UploadProgress, error) {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 
-	session, exists := m.sessions[sessionID]
-	if !exists {
-		return nil, fmt.Errorf("session not found")
+	session, err := m.sessions.Get(sessionID)
+	if err != nil {
+		return nil, err
 	}
 
 	percentComplete := float64(0)
@@ -173,83 +741,163 @@ func (m *Manager) GetProgress(sessionID string) (*models.UploadProgress, error)
 		percentComplete = float64(session.UploadedSize) / float64(session.FileSize) * 100
 	}
 
-	uploadedChunks := int(session.UploadedSize / session.ChunkSize)
-	if session.UploadedSize%session.ChunkSize > 0 {
-		uploadedChunks++
-	}
-
 	return &models.UploadProgress{
 		SessionID:       session.ID,
 		FileName:        session.FileName,
 		UploadedBytes:   session.UploadedSize,
 		TotalBytes:      session.FileSize,
-		UploadedChunks:  uploadedChunks,
+		UploadedChunks:  len(session.Received),
 		TotalChunks:     session.TotalChunks,
 		PercentComplete: percentComplete,
 		Status:          string(session.Status),
+		Error:           session.Error,
+		ReceivedRanges:  session.Ranges,
 	}, nil
 }
 
-func (m *Manager) PauseUpload(sessionID string) error {
+// MissingChunks returns, in order, the numbers of the session's chunks that
+// have not been received, so a resuming client sends only those.
+func (m *Manager) MissingChunks(sessionID string) ([]int, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	session, err := m.sessions.Get(sessionID)
+	if err != nil {
+		return nil, err
+	}
+
+	missing := []int{}
+	for chunkNumber := 0; chunkNumber < session.TotalChunks; chunkNumber++ {
+		if !session.Received[chunkNumber] {
+			missing = append(missing, chunkNumber)
+		}
+	}
+	return missing, nil
+}
+
+// FailSession marks a session failed and records why, so clients polling
+// its progress learn the upload cannot complete. The temp file is kept until
+// the session is cleaned up.
+func (m *Manager) FailSession(sessionID, reason string) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	session, exists := m.sessions[sessionID]
-	if !exists {
-		return fmt.Errorf("session not found")
+	return m.markFailed(sessionID, reason)
+}
+
+func (m *Manager) markFailed(sessionID, reason string) error {
+	return m.update(sessionID, func(session *models.UploadSession) error {
+		session.Status = models.StatusFailed
+		session.Error = reason
+		session.UpdatedAt = m.clock.Now()
+		return nil
+	})
+}
+
+// fail marks the session failed with cause as the reason and returns cause.
+// The caller must hold the manager lock.
+func (m *Manager) fail(sessionID string, cause error) error {
+	if err := m.markFailed(sessionID, cause.Error()); err != nil {
+		slog.Error("Failed to mark session failed", "error", err, "sessionId", sessionID)
 	}
+	return cause
+}
 
-	session.Status = models.StatusPaused
-	session.UpdatedAt = time.Now()
+// SetDateTaken records a capture date the user supplied for the session's
+// file, used when it is organized unless completion sends another. Failed and
+// cancelled sessions will never be organized and are refused.
+func (m *Manager) SetDateTaken(sessionID string, dateTaken time.Time) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 
-	return nil
+	return m.update(sessionID, func(session *models.UploadSession) error {
+		if session.Status == models.StatusFailed || session.Status == models.StatusCancelled {
+			return fmt.Errorf("%w: session is %s", ErrSessionClosed, session.Status)
+		}
+		session.DateTaken = &dateTaken
+		session.UpdatedAt = m.clock.Now()
+		return nil
+	})
 }
 
-func (m *Manager) ResumeUpload(sessionID string) error {
+func (m *Manager) PauseUpload(sessionID string) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	session, exists := m.sessions[sessionID]
-	if !exists {
-		return fmt.Errorf("session not found")
-	}
+	return m.update(sessionID, func(session *models.UploadSession) error {
+		session.Status = models.StatusPaused
+		session.UpdatedAt = m.clock.Now()
+		return nil
+	})
+}
 
-	if session.Status != models.StatusPaused {
-		return fmt.Errorf("session is not paused")
-	}
+func (m *Manager) ResumeUpload(sessionID string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 
-	session.Status = models.StatusUploading
-	session.UpdatedAt = time.Now()
+	return m.update(sessionID, func(session *models.UploadSession) error {
+		if session.Status != models.StatusPaused {
+			return fmt.Errorf("session is not paused")
+		}
 
-	return nil
+		session.Status = models.StatusUploading
+		session.UpdatedAt = m.clock.Now()
+		return nil
+	})
 }
 
 func (m *Manager) CancelUpload(sessionID string) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	session, exists := m.sessions[sessionID]
-	if !exists {
-		return fmt.Errorf("session not found")
+	session, err := m.sessions.Get(sessionID)
+	if err != nil {
+		return err
 	}
 
 	os.Remove(session.TempPath)
 
-	session.Status = models.StatusCancelled
-	session.UpdatedAt = time.Now()
+	return m.sessions.Delete(sessionID)
+}
 
-	delete(m.sessions, sessionID)
+// AbortAll cancels every session still receiving chunks, including ones other
+// instances sharing the store are serving, deleting their temp files, and
+// reports how many went. Completed sessions are left alone: their files are
+// being, or are about to be, organized from the temp file.
+func (m *Manager) AbortAll() (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
 
-	return nil
+	sessions, err := m.sessions.List()
+	if err != nil {
+		return 0, err
+	}
+
+	aborted := 0
+	for _, session := range sessions {
+		// Checked again as it is deleted, in case another instance completed it
+		deleted, err := m.deleteIf(session.ID, func(session *models.UploadSession) bool {
+			return session.Status != models.StatusCompleted
+		})
+		if err != nil {
+			return aborted, err
+		}
+		if deleted {
+			os.Remove(session.TempPath)
+			aborted++
+		}
+	}
+
+	return aborted, nil
 }
 
 func (m *Manager) GetTempFilePath(sessionID string) (string, error) {
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 
-	session, exists := m.sessions[sessionID]
-	if !exists {
-		return "", fmt.Errorf("session not found")
+	session, err := m.sessions.Get(sessionID)
+	if err != nil {
+		return "", err
 	}
 
 	if session.Status != models.StatusCompleted {
@@ -263,19 +911,60 @@ func (m *Manager) CleanupSession(sessionID string) error {
 	m.mutex.Lock()
 	defer m.mutex.Unlock()
 
-	session, exists := m.sessions[sessionID]
-	if !exists {
-		return fmt.Errorf("session not found")
+	session, err := m.sessions.Get(sessionID)
+	if err != nil {
+		return err
 	}
 
 	os.Remove(session.TempPath)
 
-	delete(m.sessions, sessionID)
+	return m.sessions.Delete(sessionID)
+}
+
+// checkReceived reports an error unless every chunk of the session has
+// arrived. Written ranges covering the whole file settle it whatever chunk
+// sizes delivered them; sessions stored before ranges were recorded are
+// judged by their chunks alone.
+func checkReceived(session *models.UploadSession) error {
+	if covers(session.Ranges, 0, session.FileSize) {
+		return nil
+	}
 
+	// A chunk sent twice can make up the bytes while another never arrived,
+	// so the chunk count comes first; it is a plain size mismatch when the
+	// bytes sent fall short as well
+	if received := len(session.Received); received != session.TotalChunks {
+		// Sessions stored before SentBytes counted every write in UploadedSize
+		if sent := max(session.SentBytes, session.UploadedSize); sent < session.FileSize {
+			return fmt.Errorf("%w: expected %d, got %d", ErrSizeMismatch, session.FileSize, sent)
+		}
+		return fmt.Errorf("%w: received %d of %d chunks, %d still expected",
+			ErrIncompleteUpload, received, session.TotalChunks, session.TotalChunks-received)
+	}
+	if session.UploadedSize != session.FileSize {
+		return fmt.Errorf("%w: expected %d, got %d", ErrSizeMismatch, session.FileSize, session.UploadedSize)
+	}
+	if len(session.Ranges) > 0 {
+		return fmt.Errorf("%w: %d of %d bytes written", ErrIncompleteUpload, coveredBytes(session.Ranges), session.FileSize)
+	}
 	return nil
 }
 
-func (m *Manager) calculateFileChecksum(filePath string) (string, error) {
+// allChunksVerified reports whether every chunk's current bytes were checked
+// against a client checksum as they were written.
+func allChunksVerified(session *models.UploadSession) bool {
+	if len(session.Verified) != session.TotalChunks {
+		return false
+	}
+	for chunkNumber := range session.Received {
+		if !session.Verified[chunkNumber] {
+			return false
+		}
+	}
+	return true
+}
+
+func calculateFileChecksum(filePath string) (string, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return "", err
@@ -290,6 +979,10 @@ func (m *Manager) calculateFileChecksum(filePath string) (string, error) {
 	return fmt.Sprintf("%x", hash.Sum(nil)), nil
 }
 
-func generateSessionID() string {
-	return fmt.Sprintf("upload_%d_%d", time.Now().UnixNano(), time.Now().Unix())
+var sessionSequence atomic.Uint64
+
+// generateSessionID derives an ID from now, with a sequence number keeping IDs
+// unique when the clock hasn't moved between calls.
+func generateSessionID(now time.Time) string {
+	return fmt.Sprintf("upload_%d_%d", now.UnixNano(), sessionSequence.Add(1))
 }
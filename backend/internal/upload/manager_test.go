@@ -1,11 +1,22 @@
 package upload
 
 import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
 	"crypto/sha256"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"testing/iotest"
+	"time"
 
+	"github.com/Steven-harris/sortify/backend/internal/clock"
 	"github.com/Steven-harris/sortify/backend/internal/models"
 )
 
@@ -21,8 +32,12 @@ func TestNewManager(t *testing.T) {
 		t.Errorf("Expected maxSessions 5, got %d", manager.maxSessions)
 	}
 
-	if len(manager.sessions) != 0 {
-		t.Errorf("Expected empty sessions map, got %d sessions", len(manager.sessions))
+	sessions, err := manager.sessions.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(sessions) != 0 {
+		t.Errorf("Expected empty session store, got %d sessions", len(sessions))
 	}
 }
 
@@ -85,6 +100,47 @@ func TestCreateSession(t *testing.T) {
 	}
 }
 
+func TestSessionTimestampsFollowClock(t *testing.T) {
+	start := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	fake := clock.NewFake(start)
+
+	options := DefaultOptions()
+	options.Clock = fake
+	manager := NewManagerWithOptions(t.TempDir(), 5, options)
+
+	req := &models.StartUploadRequest{FileName: "test.jpg", FileSize: 10, ChunkSize: 10}
+	first, err := manager.CreateSession(req)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	second, err := manager.CreateSession(req)
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if first.ID == second.ID {
+		t.Errorf("Expected distinct session IDs while the clock stands still, both were %s", first.ID)
+	}
+	if !first.CreatedAt.Equal(start) || !first.UpdatedAt.Equal(start) {
+		t.Errorf("Expected timestamps %v, got created %v updated %v", start, first.CreatedAt, first.UpdatedAt)
+	}
+
+	fake.Advance(5 * time.Minute)
+	if err := manager.UploadChunk(first.ID, 0, []byte("0123456789"), ""); err != nil {
+		t.Fatalf("UploadChunk failed: %v", err)
+	}
+
+	updated, err := manager.GetSession(first.ID)
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if !updated.CreatedAt.Equal(start) {
+		t.Errorf("Expected CreatedAt to stay %v, got %v", start, updated.CreatedAt)
+	}
+	if expected := start.Add(5 * time.Minute); !updated.UpdatedAt.Equal(expected) {
+		t.Errorf("Expected UpdatedAt %v, got %v", expected, updated.UpdatedAt)
+	}
+}
+
 func TestCreateSessionMaxLimit(t *testing.T) {
 	tempDir := t.TempDir()
 	manager := NewManager(tempDir, 2) // Max 2 sessions
@@ -116,6 +172,195 @@ func TestCreateSessionMaxLimit(t *testing.T) {
 	}
 }
 
+func TestCreateSessionMaxLimitConcurrent(t *testing.T) {
+	const maxSessions = 4
+	manager := NewManager(t.TempDir(), maxSessions)
+
+	// createConcurrently fires attempts CreateSession calls at once and
+	// returns the sessions that were admitted.
+	createConcurrently := func(attempts int) []*models.UploadSession {
+		var (
+			wg       sync.WaitGroup
+			mutex    sync.Mutex
+			admitted []*models.UploadSession
+		)
+		start := make(chan struct{})
+		for i := 0; i < attempts; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				<-start
+				session, err := manager.CreateSession(&models.StartUploadRequest{
+					FileName:  fmt.Sprintf("test%d.jpg", i),
+					FileSize:  4,
+					ChunkSize: 4,
+				})
+				if err != nil {
+					if !errors.Is(err, ErrTooManySessions) {
+						t.Errorf("Expected ErrTooManySessions, got %v", err)
+					}
+					return
+				}
+				mutex.Lock()
+				admitted = append(admitted, session)
+				mutex.Unlock()
+			}(i)
+		}
+		close(start)
+		wg.Wait()
+		return admitted
+	}
+
+	admitted := createConcurrently(10)
+	if len(admitted) != maxSessions {
+		t.Fatalf("Expected exactly %d sessions admitted, got %d", maxSessions, len(admitted))
+	}
+
+	// Completed sessions stay stored until cleanup but free their slots.
+	// Uploads racing the completions may claim those slots, never more.
+	var wg sync.WaitGroup
+	for _, session := range admitted[:2] {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			if err := manager.UploadChunk(id, 0, []byte("data"), ""); err != nil {
+				t.Errorf("UploadChunk failed: %v", err)
+				return
+			}
+			if err := manager.CompleteUpload(id, ""); err != nil {
+				t.Errorf("CompleteUpload failed: %v", err)
+			}
+		}(session.ID)
+	}
+	racing := createConcurrently(10)
+	wg.Wait()
+	if len(racing) > 2 {
+		t.Fatalf("Expected at most 2 sessions admitted while others completed, got %d", len(racing))
+	}
+
+	// Whatever slots the race left free are still available, and no more
+	if after := createConcurrently(10); len(racing)+len(after) != 2 {
+		t.Errorf("Expected exactly 2 sessions admitted into freed slots, got %d", len(racing)+len(after))
+	}
+	if _, err := manager.CreateSession(&models.StartUploadRequest{FileName: "late.jpg", FileSize: 4, ChunkSize: 4}); !errors.Is(err, ErrTooManySessions) {
+		t.Errorf("Expected ErrTooManySessions once all slots are taken, got %v", err)
+	}
+}
+
+func TestCreateSessionWithinQuotaConcurrent(t *testing.T) {
+	manager := NewManager(t.TempDir(), 20)
+	// 100 bytes already in the library leave room for three 30 byte uploads
+	quota := Quota{Limit: 200, Used: func() (int64, error) { return 100, nil }}
+
+	var (
+		wg       sync.WaitGroup
+		mutex    sync.Mutex
+		admitted int
+	)
+	start := make(chan struct{})
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			_, err := manager.CreateSessionWithinQuota(&models.StartUploadRequest{
+				FileName:  fmt.Sprintf("test%d.jpg", i),
+				FileSize:  30,
+				ChunkSize: 30,
+			}, quota)
+			if err != nil {
+				if !errors.Is(err, ErrQuotaExceeded) {
+					t.Errorf("Expected ErrQuotaExceeded, got %v", err)
+				}
+				return
+			}
+			mutex.Lock()
+			admitted++
+			mutex.Unlock()
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	if admitted != 3 {
+		t.Errorf("Expected exactly 3 sessions admitted, got %d", admitted)
+	}
+	if reserved := manager.ReservedBytes(); reserved != 90 {
+		t.Errorf("Expected 90 bytes reserved, got %d", reserved)
+	}
+
+	// Without a limit the quota is ignored
+	if _, err := manager.CreateSessionWithinQuota(&models.StartUploadRequest{FileName: "big.jpg", FileSize: 1000, ChunkSize: 1000}, Quota{}); err != nil {
+		t.Errorf("Expected no quota to admit any size, got %v", err)
+	}
+}
+
+func TestCreateSessionWithinQuotaIgnoresTerminalSessions(t *testing.T) {
+	manager := NewManager(t.TempDir(), 5)
+	quota := Quota{Limit: 100, Used: func() (int64, error) { return 0, nil }}
+
+	failed, err := manager.CreateSessionWithinQuota(&models.StartUploadRequest{FileName: "failed.jpg", FileSize: 80, ChunkSize: 80}, quota)
+	if err != nil {
+		t.Fatalf("CreateSessionWithinQuota failed: %v", err)
+	}
+	if _, err := manager.CreateSessionWithinQuota(&models.StartUploadRequest{FileName: "next.jpg", FileSize: 80, ChunkSize: 80}, quota); !errors.Is(err, ErrQuotaExceeded) {
+		t.Fatalf("Expected ErrQuotaExceeded while the first upload is in progress, got %v", err)
+	}
+
+	if err := manager.FailSession(failed.ID, "disk error"); err != nil {
+		t.Fatalf("FailSession failed: %v", err)
+	}
+	if reserved := manager.ReservedBytes(); reserved != 0 {
+		t.Errorf("Expected a failed session to reserve nothing, got %d", reserved)
+	}
+	if _, err := manager.CreateSessionWithinQuota(&models.StartUploadRequest{FileName: "next.jpg", FileSize: 80, ChunkSize: 80}, quota); err != nil {
+		t.Errorf("Expected the failed upload's bytes to be free again, got %v", err)
+	}
+}
+
+func TestCreateSessionMetadataLimits(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManagerWithOptions(tempDir, 5, Options{
+		Metadata: MetadataLimits{
+			MaxKeys:        2,
+			MaxKeyLength:   8,
+			MaxValueLength: 16,
+			AllowedKeys:    []string{"source", "album"},
+		},
+	})
+
+	tests := []struct {
+		name     string
+		metadata map[string]string
+		valid    bool
+	}{
+		{"Valid metadata", map[string]string{"source": "phone", "album": "beach"}, true},
+		{"No metadata", nil, true},
+		{"Too many keys", map[string]string{"source": "a", "album": "b", "extra": "c"}, false},
+		{"Key too long", map[string]string{"averylongkey": "a"}, false},
+		{"Value too large", map[string]string{"source": strings.Repeat("x", 17)}, false},
+		{"Key not allowed", map[string]string{"camera": "test"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := manager.CreateSession(&models.StartUploadRequest{
+				FileName:  "test.jpg",
+				FileSize:  10,
+				ChunkSize: 10,
+				Metadata:  test.metadata,
+			})
+
+			if test.valid && err != nil {
+				t.Errorf("Expected metadata to be accepted, got %v", err)
+			}
+			if !test.valid && !errors.Is(err, ErrInvalidMetadata) {
+				t.Errorf("Expected ErrInvalidMetadata, got %v", err)
+			}
+		})
+	}
+}
+
 func TestGetSession(t *testing.T) {
 	tempDir := t.TempDir()
 	manager := NewManager(tempDir, 5)
@@ -226,7 +471,7 @@ func TestUploadChunkChecksumValidation(t *testing.T) {
 	}
 
 	chunkData := []byte("test chunk data")
-	wrongChecksum := "wrong_checksum"
+	wrongChecksum := fmt.Sprintf("%x", sha256.Sum256([]byte("other data")))
 
 	// Upload chunk with wrong checksum - should fail
 	err = manager.UploadChunk(session.ID, 0, chunkData, wrongChecksum)
@@ -239,6 +484,193 @@ func TestUploadChunkChecksumValidation(t *testing.T) {
 	}
 }
 
+func TestUploadChunkChecksumFormat(t *testing.T) {
+	manager := NewManager(t.TempDir(), 5)
+	chunkData := []byte("test chunk data")
+
+	tests := []struct {
+		name     string
+		checksum Checksum
+		expected error
+	}{
+		{"Wrong length SHA-256", SHA256Checksum(fmt.Sprintf("%x", md5.Sum(chunkData))), ErrMalformedChecksum},
+		{"Not hex", SHA256Checksum(strings.Repeat("z", 64)), ErrMalformedChecksum},
+		{"Unsupported algorithm", Checksum{Algorithm: "crc32", Value: "cbf43926"}, ErrUnsupportedChecksumAlgorithm},
+		{"Default algorithm", Checksum{Value: fmt.Sprintf("%x", sha256.Sum256(chunkData))}, nil},
+		{"MD5", Checksum{Algorithm: "MD5", Value: fmt.Sprintf("%X", md5.Sum(chunkData))}, nil},
+		{"SHA-1 hyphenated", Checksum{Algorithm: "sha-1", Value: fmt.Sprintf("%x", sha1.Sum(chunkData))}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			session, err := manager.CreateSession(&models.StartUploadRequest{
+				FileName:  "test.jpg",
+				FileSize:  int64(len(chunkData)),
+				ChunkSize: int64(len(chunkData)),
+			})
+			if err != nil {
+				t.Fatalf("CreateSession failed: %v", err)
+			}
+			defer manager.CancelUpload(session.ID)
+
+			err = manager.UploadChunkWithChecksum(session.ID, 0, chunkData, test.checksum)
+			if test.expected == nil {
+				if err != nil {
+					t.Errorf("Expected chunk to be accepted, got %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, test.expected) {
+				t.Fatalf("Expected %v, got %v", test.expected, err)
+			}
+
+			// Rejected up front, before anything was recorded
+			stored, _ := manager.GetSession(session.ID)
+			if len(stored.Received) != 0 {
+				t.Errorf("Expected no chunks recorded, got %v", stored.Received)
+			}
+		})
+	}
+}
+
+func TestCreateSessionChunking(t *testing.T) {
+	manager := NewManager(t.TempDir(), 5)
+
+	tests := []struct {
+		name      string
+		fileSize  int64
+		chunkSize int64
+		expected  error
+		chunks    int
+	}{
+		{"Uneven last chunk", 1000, 256, nil, 4},
+		{"Single chunk larger than file", 10, 256, nil, 1},
+		{"Negative file size", -1, 256, ErrInvalidChunking, 0},
+		{"Zero chunk size", 1000, 0, ErrInvalidChunking, 0},
+		{"Negative chunk size", 1000, -256, ErrInvalidChunking, 0},
+		{"Too many chunks", 1 << 40, 1, ErrInvalidChunking, 0},
+		{"Huge chunk size", 1000, math.MaxInt64, nil, 1},
+		{"Empty file", 0, 256, nil, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			session, err := manager.CreateSession(&models.StartUploadRequest{
+				FileName:  "test.jpg",
+				FileSize:  test.fileSize,
+				ChunkSize: test.chunkSize,
+			})
+			if !errors.Is(err, test.expected) {
+				t.Fatalf("Expected %v, got %v", test.expected, err)
+			}
+			if err == nil {
+				if session.TotalChunks != test.chunks {
+					t.Errorf("Expected %d chunks, got %d", test.chunks, session.TotalChunks)
+				}
+				manager.CancelUpload(session.ID)
+			}
+		})
+	}
+}
+
+func TestUploadChunkOverrun(t *testing.T) {
+	tests := []struct {
+		name        string
+		chunkNumber int
+		length      int
+		expected    error
+	}{
+		{"Final chunk fits", 3, 232, nil},
+		{"Final chunk overruns file size", 3, 256, ErrChunkOverrun},
+		{"Middle chunk overruns its slot", 1, 300, ErrChunkOverrun},
+		{"Chunk past the end", 4, 1, ErrChunkOutOfRange},
+		{"Negative chunk", -1, 10, ErrChunkOutOfRange},
+	}
+
+	uploads := map[string]func(manager *Manager, sessionID string, chunkNumber int, data []byte) error{
+		"buffered": func(manager *Manager, sessionID string, chunkNumber int, data []byte) error {
+			return manager.UploadChunk(sessionID, chunkNumber, data, "")
+		},
+		"streamed": func(manager *Manager, sessionID string, chunkNumber int, data []byte) error {
+			return manager.UploadChunkFrom(sessionID, chunkNumber, bytes.NewReader(data), Checksum{})
+		},
+	}
+
+	for _, test := range tests {
+		for mode, send := range uploads {
+			t.Run(test.name+" "+mode, func(t *testing.T) {
+				manager := NewManager(t.TempDir(), 5)
+				session, err := manager.CreateSession(&models.StartUploadRequest{
+					FileName:  "test.jpg",
+					FileSize:  1000,
+					ChunkSize: 256,
+				})
+				if err != nil {
+					t.Fatalf("CreateSession failed: %v", err)
+				}
+
+				err = send(manager, session.ID, test.chunkNumber, bytes.Repeat([]byte("x"), test.length))
+				if !errors.Is(err, test.expected) {
+					t.Fatalf("Expected %v, got %v", test.expected, err)
+				}
+				if test.expected == ErrChunkOutOfRange {
+					expected := fmt.Sprintf("chunk number out of range: %d is not in [0,4)", test.chunkNumber)
+					if err.Error() != expected {
+						t.Errorf("Expected %q, got %q", expected, err.Error())
+					}
+				}
+
+				// Nothing may land past the declared size
+				if stat, err := os.Stat(session.TempPath); err != nil || stat.Size() != 1000 {
+					t.Errorf("Expected the temp file to stay 1000 bytes, got %v (%v)", stat.Size(), err)
+				}
+				if test.expected == nil {
+					return
+				}
+				stored, _ := manager.GetSession(session.ID)
+				if len(stored.Received) != 0 || stored.UploadedSize != 0 || stored.Status == models.StatusFailed {
+					t.Errorf("Expected the chunk rejected without failing the session, got %+v", stored)
+				}
+			})
+		}
+	}
+}
+
+func TestUploadChunkCutOffForgetsResentChunk(t *testing.T) {
+	options := DefaultOptions()
+	options.SkipFullChecksum = true
+	manager := NewManagerWithOptions(t.TempDir(), 5, options)
+
+	session, err := manager.CreateSession(&models.StartUploadRequest{FileName: "clip.mp4", FileSize: 10, ChunkSize: 5})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	chunks := [][]byte{[]byte("01234"), []byte("56789")}
+	for i, chunk := range chunks {
+		if err := manager.UploadChunk(session.ID, i, chunk, fmt.Sprintf("%x", sha256.Sum256(chunk))); err != nil {
+			t.Fatalf("UploadChunk %d failed: %v", i, err)
+		}
+	}
+
+	// The client resends chunk 1 and disconnects after two bytes
+	cutOff := io.MultiReader(strings.NewReader("xx"), iotest.ErrReader(io.ErrUnexpectedEOF))
+	if err := manager.UploadChunkFrom(session.ID, 1, cutOff, Checksum{}); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("Expected the cut-off body to fail, got %v", err)
+	}
+
+	stored, _ := manager.GetSession(session.ID)
+	if stored.Received[1] || stored.Verified[1] || stored.ChunkHashes[1] != "" {
+		t.Errorf("Expected the overwritten chunk to be forgotten, got received=%v verified=%v hash=%q",
+			stored.Received[1], stored.Verified[1], stored.ChunkHashes[1])
+	}
+	if err := manager.CompleteUpload(session.ID, ""); !errors.Is(err, ErrIncompleteUpload) {
+		t.Errorf("Expected ErrIncompleteUpload, got %v", err)
+	}
+	if err := manager.CompleteUploadTree(session.ID, chunkTreeRoot(chunks)); !errors.Is(err, ErrIncompleteUpload) {
+		t.Errorf("Expected the tree completion to be refused too, got %v", err)
+	}
+}
+
 func TestCompleteUpload(t *testing.T) {
 	tempDir := t.TempDir()
 	manager := NewManager(tempDir, 5)
@@ -318,6 +750,536 @@ func TestCompleteUploadSizeMismatch(t *testing.T) {
 	}
 }
 
+func TestCompleteUploadMissingChunk(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(tempDir, 5)
+
+	session, err := manager.CreateSession(&models.StartUploadRequest{
+		FileName:  "test.jpg",
+		FileSize:  20,
+		ChunkSize: 10,
+	})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	// Sending chunk 0 twice reaches the byte total while chunk 1 never arrives
+	chunk := []byte("0123456789")
+	for i := 0; i < 2; i++ {
+		if err := manager.UploadChunk(session.ID, 0, chunk, ""); err != nil {
+			t.Fatalf("UploadChunk failed: %v", err)
+		}
+	}
+
+	err = manager.CompleteUpload(session.ID, "")
+	if err == nil {
+		t.Fatal("Expected error for missing chunk, got nil")
+	}
+
+	if err.Error() != "incomplete upload: received 1 of 2 chunks, 1 still expected" {
+		t.Errorf("Expected missing chunk error, got %v", err)
+	}
+}
+
+func TestForgetWriteKeepsLegacyCount(t *testing.T) {
+	// Stored before ranges were recorded: chunk 0 counted in bytes only
+	session := &models.UploadSession{FileSize: 30, ChunkSize: 10, TotalChunks: 3, UploadedSize: 10}
+
+	recordWrite(session, 10, 10)
+	if session.UploadedSize != 20 {
+		t.Fatalf("Expected 20 bytes after the write, got %d", session.UploadedSize)
+	}
+	forgetWrite(session, 10, 10)
+	if session.UploadedSize != 10 {
+		t.Errorf("Expected the earlier 10 bytes to stay counted, got %d", session.UploadedSize)
+	}
+	if session.Received[1] {
+		t.Error("Expected the rejected chunk to be missing again")
+	}
+}
+
+func TestMissingChunksAfterResend(t *testing.T) {
+	manager := NewManager(t.TempDir(), 5)
+	session, err := manager.CreateSession(&models.StartUploadRequest{
+		FileName:  "test.jpg",
+		FileSize:  25,
+		ChunkSize: 10,
+	})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	for _, chunkNumber := range []int{0, 2, 0} {
+		chunk := []byte("0123456789")
+		if chunkNumber == 2 {
+			chunk = chunk[:5]
+		}
+		if err := manager.UploadChunk(session.ID, chunkNumber, chunk, ""); err != nil {
+			t.Fatalf("UploadChunk %d failed: %v", chunkNumber, err)
+		}
+	}
+
+	missing, err := manager.MissingChunks(session.ID)
+	if err != nil {
+		t.Fatalf("MissingChunks failed: %v", err)
+	}
+	if len(missing) != 1 || missing[0] != 1 {
+		t.Errorf("Expected chunk 1 missing, got %v", missing)
+	}
+
+	progress, err := manager.GetProgress(session.ID)
+	if err != nil {
+		t.Fatalf("GetProgress failed: %v", err)
+	}
+	if progress.UploadedBytes != 15 {
+		t.Errorf("Expected the resent chunk counted once for 15 bytes, got %d", progress.UploadedBytes)
+	}
+	if progress.UploadedChunks != 2 {
+		t.Errorf("Expected 2 uploaded chunks, got %d", progress.UploadedChunks)
+	}
+
+	if _, err := manager.MissingChunks("unknown"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestCompleteUploadChecksumMismatchFailsSession(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(tempDir, 5)
+
+	session, err := manager.CreateSession(&models.StartUploadRequest{
+		FileName:  "test.jpg",
+		FileSize:  10,
+		ChunkSize: 10,
+		Checksum:  strings.Repeat("0", 64),
+	})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	if err := manager.UploadChunk(session.ID, 0, []byte("0123456789"), ""); err != nil {
+		t.Fatalf("UploadChunk failed: %v", err)
+	}
+
+	err = manager.CompleteUpload(session.ID, "")
+	if err == nil || err.Error() != "file checksum mismatch" {
+		t.Fatalf("Expected file checksum mismatch, got %v", err)
+	}
+
+	failed, err := manager.GetSession(session.ID)
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if failed.Status != models.StatusFailed {
+		t.Errorf("Expected status %s, got %s", models.StatusFailed, failed.Status)
+	}
+	if failed.Error != "file checksum mismatch" {
+		t.Errorf("Expected error 'file checksum mismatch', got %q", failed.Error)
+	}
+
+	progress, err := manager.GetProgress(session.ID)
+	if err != nil {
+		t.Fatalf("GetProgress failed: %v", err)
+	}
+	if progress.Status != string(models.StatusFailed) || progress.Error != "file checksum mismatch" {
+		t.Errorf("Expected failed progress with reason, got status %q error %q", progress.Status, progress.Error)
+	}
+}
+
+func TestCompleteUploadSkipFullChecksum(t *testing.T) {
+	chunks := [][]byte{[]byte("01234"), []byte("56789")}
+	fileChecksum := fmt.Sprintf("%x", sha256.Sum256([]byte("0123456789")))
+
+	tests := []struct {
+		name              string
+		skip              bool
+		verifyChunks      []bool
+		startChecksum     string
+		completeChecksum  string
+		expectedReads     int
+		expectedReadBacks int
+	}{
+		{"Verification on by default", false, []bool{true, true}, "", "", 0, 1},
+		{"All chunks verified", true, []bool{true, true}, "", "", 0, 0},
+		{"Unverified chunk forces read-back", true, []bool{true, false}, "", "", 0, 1},
+		{"Checksum at start is always verified", true, []bool{true, true}, fileChecksum, "", 1, 0},
+		{"Checksum on complete is always verified", true, []bool{true, true}, "", fileChecksum, 1, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			options := DefaultOptions()
+			options.SkipFullChecksum = test.skip
+			manager := NewManagerWithOptions(t.TempDir(), 5, options)
+
+			reads, readBacks := 0, 0
+			manager.fileChecksum = func(filePath string) (string, error) {
+				reads++
+				return calculateFileChecksum(filePath)
+			}
+			manager.chunkReadBack = func(session *models.UploadSession) error {
+				readBacks++
+				return verifyChunkHashes(session)
+			}
+
+			session, err := manager.CreateSession(&models.StartUploadRequest{
+				FileName:  "clip.mp4",
+				FileSize:  10,
+				ChunkSize: 5,
+				Checksum:  test.startChecksum,
+			})
+			if err != nil {
+				t.Fatalf("CreateSession failed: %v", err)
+			}
+
+			for i, chunk := range chunks {
+				checksum := ""
+				if test.verifyChunks[i] {
+					checksum = fmt.Sprintf("%x", sha256.Sum256(chunk))
+				}
+				if err := manager.UploadChunk(session.ID, i, chunk, checksum); err != nil {
+					t.Fatalf("UploadChunk %d failed: %v", i, err)
+				}
+			}
+
+			if err := manager.CompleteUpload(session.ID, test.completeChecksum); err != nil {
+				t.Fatalf("CompleteUpload failed: %v", err)
+			}
+			if reads != test.expectedReads {
+				t.Errorf("Expected %d full-file reads, got %d", test.expectedReads, reads)
+			}
+			if readBacks != test.expectedReadBacks {
+				t.Errorf("Expected %d chunk read-backs, got %d", test.expectedReadBacks, readBacks)
+			}
+		})
+	}
+}
+
+func TestCompleteUploadReadsBackVerifiedChunks(t *testing.T) {
+	manager := NewManager(t.TempDir(), 5)
+
+	session, err := manager.CreateSession(&models.StartUploadRequest{FileName: "clip.mp4", FileSize: 10, ChunkSize: 5})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	for i, chunk := range [][]byte{[]byte("01234"), []byte("56789")} {
+		if err := manager.UploadChunk(session.ID, i, chunk, fmt.Sprintf("%x", sha256.Sum256(chunk))); err != nil {
+			t.Fatalf("UploadChunk %d failed: %v", i, err)
+		}
+	}
+
+	// The second chunk is damaged on disk after it was checked
+	if err := os.WriteFile(session.TempPath, []byte("01234xxxxx"), 0644); err != nil {
+		t.Fatalf("Failed to damage temp file: %v", err)
+	}
+
+	if err := manager.CompleteUpload(session.ID, ""); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("Expected ErrChecksumMismatch, got %v", err)
+	}
+}
+
+// chunkTreeRoot is the tree root a client computes over its chunks.
+func chunkTreeRoot(chunks [][]byte) string {
+	leaves := make([][]byte, len(chunks))
+	for i, chunk := range chunks {
+		sum := sha256.Sum256(chunk)
+		leaves[i] = sum[:]
+	}
+	return fmt.Sprintf("%x", TreeHash(leaves))
+}
+
+func TestTreeHash(t *testing.T) {
+	a, b, c := []byte("a"), []byte("b"), []byte("c")
+	pair := func(left, right []byte) []byte {
+		sum := sha256.Sum256(append(append([]byte{}, left...), right...))
+		return sum[:]
+	}
+
+	tests := []struct {
+		name     string
+		leaves   [][]byte
+		expected []byte
+	}{
+		{"Single leaf is the root", [][]byte{a}, a},
+		{"Pair", [][]byte{a, b}, pair(a, b)},
+		{"Odd leaf moves up", [][]byte{a, b, c}, pair(pair(a, b), c)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if root := TreeHash(test.leaves); string(root) != string(test.expected) {
+				t.Errorf("Expected %x, got %x", test.expected, root)
+			}
+		})
+	}
+}
+
+func TestCompleteUploadTree(t *testing.T) {
+	chunks := [][]byte{[]byte("01234"), []byte("56789"), []byte("ab")}
+	root := chunkTreeRoot(chunks)
+
+	tests := []struct {
+		name          string
+		sent          [][]byte
+		verifyChunks  []bool
+		expectedError string
+	}{
+		{"Chunk digests reused", chunks, []bool{true, true, true}, ""},
+		{"Unverified chunks read back", chunks, []bool{false, true, false}, ""},
+		{"Tampered chunk caught", [][]byte{chunks[0], []byte("5678X"), chunks[2]}, []bool{true, true, true}, "file checksum mismatch: tree root does not match the chunks"},
+		{"Tampered unverified chunk caught", [][]byte{chunks[0], []byte("5678X"), chunks[2]}, []bool{true, false, true}, "file checksum mismatch: tree root does not match the chunks"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manager := NewManager(t.TempDir(), 5)
+
+			reads := 0
+			manager.fileChecksum = func(filePath string) (string, error) {
+				reads++
+				return calculateFileChecksum(filePath)
+			}
+
+			session, err := manager.CreateSession(&models.StartUploadRequest{
+				FileName:  "clip.mp4",
+				FileSize:  12,
+				ChunkSize: 5,
+			})
+			if err != nil {
+				t.Fatalf("CreateSession failed: %v", err)
+			}
+
+			for i, chunk := range test.sent {
+				// A tampered chunk arrives with a checksum of its own bytes,
+				// so only the root can catch it
+				checksum := ""
+				if test.verifyChunks[i] {
+					checksum = fmt.Sprintf("%x", sha256.Sum256(chunk))
+				}
+				if err := manager.UploadChunk(session.ID, i, chunk, checksum); err != nil {
+					t.Fatalf("UploadChunk %d failed: %v", i, err)
+				}
+			}
+
+			err = manager.CompleteUploadTree(session.ID, root)
+			if reads != 0 {
+				t.Errorf("Expected no full-file reads, got %d", reads)
+			}
+
+			completed, _ := manager.GetSession(session.ID)
+			if test.expectedError == "" {
+				if err != nil {
+					t.Fatalf("CompleteUploadTree failed: %v", err)
+				}
+				if completed.Status != models.StatusCompleted {
+					t.Errorf("Expected status %s, got %s", models.StatusCompleted, completed.Status)
+				}
+				return
+			}
+
+			if err == nil || err.Error() != test.expectedError {
+				t.Fatalf("Expected %q, got %v", test.expectedError, err)
+			}
+			if completed.Status != models.StatusFailed || completed.Error != test.expectedError {
+				t.Errorf("Expected failed session with %q, got %s %q", test.expectedError, completed.Status, completed.Error)
+			}
+		})
+	}
+}
+
+func TestCompleteUploadTreeMalformedRoot(t *testing.T) {
+	manager := NewManager(t.TempDir(), 5)
+
+	session, err := manager.CreateSession(&models.StartUploadRequest{FileName: "test.jpg", FileSize: 5, ChunkSize: 5})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if err := manager.UploadChunk(session.ID, 0, []byte("01234"), ""); err != nil {
+		t.Fatalf("UploadChunk failed: %v", err)
+	}
+
+	if err := manager.CompleteUploadTree(session.ID, "abc"); !errors.Is(err, ErrMalformedChecksum) {
+		t.Errorf("Expected ErrMalformedChecksum, got %v", err)
+	}
+}
+
+func TestResumeWithDifferentChunkSize(t *testing.T) {
+	manager := NewManager(t.TempDir(), 5)
+
+	content := []byte("0123456789abcdefghijklmnopqrstuvwxyzABCD")
+	session, err := manager.CreateSession(&models.StartUploadRequest{
+		FileName:  "test.jpg",
+		FileSize:  int64(len(content)),
+		ChunkSize: 8,
+	})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	// The first device sends chunks 0-2 of 8 bytes
+	for chunkNumber := 0; chunkNumber < 3; chunkNumber++ {
+		if err := manager.UploadChunk(session.ID, chunkNumber, content[chunkNumber*8:(chunkNumber+1)*8], ""); err != nil {
+			t.Fatalf("UploadChunk %d failed: %v", chunkNumber, err)
+		}
+	}
+
+	progress, err := manager.GetProgress(session.ID)
+	if err != nil {
+		t.Fatalf("GetProgress failed: %v", err)
+	}
+	if len(progress.ReceivedRanges) != 1 || progress.ReceivedRanges[0] != (models.ByteRange{Offset: 0, Length: 24}) {
+		t.Fatalf("Expected bytes 0-24 received, got %+v", progress.ReceivedRanges)
+	}
+
+	// The second device resumes with 5-byte chunks placed by offset, one
+	// straddling the old chunk boundary at 32
+	for offset := int64(24); offset < int64(len(content)); offset += 5 {
+		end := min(offset+5, int64(len(content)))
+		if err := manager.UploadChunkAt(session.ID, offset, bytes.NewReader(content[offset:end]), SHA256Checksum(fmt.Sprintf("%x", sha256.Sum256(content[offset:end])))); err != nil {
+			t.Fatalf("UploadChunkAt %d failed: %v", offset, err)
+		}
+		if end < int64(len(content)) {
+			if err := manager.CompleteUpload(session.ID, ""); !errors.Is(err, ErrIncompleteUpload) && !errors.Is(err, ErrSizeMismatch) {
+				t.Fatalf("Expected an incomplete upload at offset %d, got %v", end, err)
+			}
+		}
+	}
+
+	if err := manager.CompleteUpload(session.ID, fmt.Sprintf("%x", sha256.Sum256(content))); err != nil {
+		t.Fatalf("CompleteUpload failed: %v", err)
+	}
+	assembled, err := os.ReadFile(session.TempPath)
+	if err != nil {
+		t.Fatalf("Failed to read assembled file: %v", err)
+	}
+	if !bytes.Equal(assembled, content) {
+		t.Errorf("Expected assembled content %q, got %q", content, assembled)
+	}
+}
+
+func TestUploadChunkAtRejectedChunk(t *testing.T) {
+	manager := NewManager(t.TempDir(), 5)
+
+	content := []byte("0123456789abcdefghij")
+	session, err := manager.CreateSession(&models.StartUploadRequest{
+		FileName:  "test.jpg",
+		FileSize:  int64(len(content)),
+		ChunkSize: 10,
+	})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if err := manager.UploadChunk(session.ID, 0, content[:10], ""); err != nil {
+		t.Fatalf("UploadChunk failed: %v", err)
+	}
+	if err := manager.UploadChunkAt(session.ID, 20, bytes.NewReader(content[10:]), Checksum{}); err == nil {
+		t.Fatal("Expected an out-of-range offset to be rejected")
+	}
+
+	// A bad checksum straddling both chunks un-receives the bytes it clobbered
+	err = manager.UploadChunkAt(session.ID, 5, bytes.NewReader(content[5:]), SHA256Checksum(fmt.Sprintf("%x", sha256.Sum256([]byte("other")))))
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("Expected a checksum mismatch, got %v", err)
+	}
+	stored, _ := manager.GetSession(session.ID)
+	if len(stored.Ranges) != 1 || stored.Ranges[0] != (models.ByteRange{Offset: 0, Length: 5}) {
+		t.Errorf("Expected only bytes 0-5 left received, got %+v", stored.Ranges)
+	}
+	if stored.Received[0] {
+		t.Error("Expected the clobbered chunk to need resending")
+	}
+
+	if err := manager.UploadChunkAt(session.ID, 0, bytes.NewReader(content), Checksum{}); err != nil {
+		t.Fatalf("UploadChunkAt failed: %v", err)
+	}
+	if err := manager.CompleteUpload(session.ID, fmt.Sprintf("%x", sha256.Sum256(content))); err != nil {
+		t.Errorf("CompleteUpload failed: %v", err)
+	}
+}
+
+func TestUploadChunkRejectsClosedSessions(t *testing.T) {
+	tests := []struct {
+		name  string
+		close func(manager *Manager, sessionID string) error
+	}{
+		{"Completed", func(manager *Manager, sessionID string) error {
+			if err := manager.UploadChunk(sessionID, 0, []byte("0123456789"), ""); err != nil {
+				return err
+			}
+			return manager.CompleteUpload(sessionID, "")
+		}},
+		{"Failed", func(manager *Manager, sessionID string) error {
+			return manager.FailSession(sessionID, "disk on fire")
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manager := NewManager(t.TempDir(), 1)
+			session, err := manager.CreateSession(&models.StartUploadRequest{FileName: "test.jpg", FileSize: 10, ChunkSize: 10})
+			if err != nil {
+				t.Fatalf("CreateSession failed: %v", err)
+			}
+			if err := test.close(manager, session.ID); err != nil {
+				t.Fatalf("Failed to close session: %v", err)
+			}
+			before, _ := manager.GetSession(session.ID)
+
+			err = manager.UploadChunk(session.ID, 0, []byte("9876543210"), "")
+			if !errors.Is(err, ErrSessionClosed) {
+				t.Fatalf("Expected ErrSessionClosed, got %v", err)
+			}
+			err = manager.UploadChunkFrom(session.ID, 0, strings.NewReader("9876543210"), Checksum{})
+			if !errors.Is(err, ErrSessionClosed) {
+				t.Fatalf("Expected ErrSessionClosed streaming, got %v", err)
+			}
+			if test.name == "Completed" {
+				if data, _ := os.ReadFile(session.TempPath); string(data) != "0123456789" {
+					t.Errorf("Expected the completed file untouched, got %q", data)
+				}
+			}
+
+			after, _ := manager.GetSession(session.ID)
+			if after.Status != before.Status {
+				t.Errorf("Expected the session to stay %s, got %s", before.Status, after.Status)
+			}
+			if active, _ := manager.ActiveSessions(); active != 0 {
+				t.Errorf("Expected the closed session not to hold a slot, got %d active", active)
+			}
+		})
+	}
+}
+
+func TestFailSession(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(tempDir, 5)
+
+	session, err := manager.CreateSession(&models.StartUploadRequest{
+		FileName:  "test.jpg",
+		FileSize:  10,
+		ChunkSize: 10,
+	})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	if err := manager.FailSession(session.ID, "failed to organize file: disk full"); err != nil {
+		t.Fatalf("FailSession failed: %v", err)
+	}
+
+	failed, err := manager.GetSession(session.ID)
+	if err != nil {
+		t.Fatalf("GetSession failed: %v", err)
+	}
+	if failed.Status != models.StatusFailed || failed.Error != "failed to organize file: disk full" {
+		t.Errorf("Expected failed session with reason, got status %q error %q", failed.Status, failed.Error)
+	}
+
+	if err := manager.FailSession("missing", "reason"); err == nil {
+		t.Error("Expected error failing an unknown session, got nil")
+	}
+}
+
 func TestGetProgress(t *testing.T) {
 	tempDir := t.TempDir()
 	manager := NewManager(tempDir, 5)
@@ -458,6 +1420,65 @@ func TestCancelUpload(t *testing.T) {
 	}
 }
 
+func TestAbortAll(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(tempDir, 5)
+
+	var tempPaths []string
+	for i := 0; i < 3; i++ {
+		session, err := manager.CreateSession(&models.StartUploadRequest{
+			FileName:  fmt.Sprintf("test%d.jpg", i),
+			FileSize:  1024,
+			ChunkSize: 256,
+		})
+		if err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+		tempPaths = append(tempPaths, session.TempPath)
+	}
+
+	// A completed session is being organized and must keep its temp file
+	completed, err := manager.CreateSession(&models.StartUploadRequest{FileName: "done.jpg", FileSize: 4, ChunkSize: 4})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if err := manager.UploadChunk(completed.ID, 0, []byte("done"), ""); err != nil {
+		t.Fatalf("UploadChunk failed: %v", err)
+	}
+	if err := manager.CompleteUpload(completed.ID, ""); err != nil {
+		t.Fatalf("CompleteUpload failed: %v", err)
+	}
+
+	aborted, err := manager.AbortAll()
+	if err != nil {
+		t.Fatalf("AbortAll failed: %v", err)
+	}
+	if aborted != 3 {
+		t.Errorf("Expected 3 aborted sessions, got %d", aborted)
+	}
+
+	sessions, err := manager.sessions.List()
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != completed.ID {
+		t.Errorf("Expected only the completed session left, got %d", len(sessions))
+	}
+	if _, err := os.Stat(completed.TempPath); err != nil {
+		t.Errorf("Expected the completed session's temp file to be kept: %v", err)
+	}
+	for _, tempPath := range tempPaths {
+		if _, err := os.Stat(tempPath); !os.IsNotExist(err) {
+			t.Errorf("Expected temp file %s to be removed", tempPath)
+		}
+	}
+
+	// The freed slots can be used again
+	if _, err := manager.CreateSession(&models.StartUploadRequest{FileName: "again.jpg", FileSize: 1, ChunkSize: 1}); err != nil {
+		t.Errorf("Expected a new session after aborting, got %v", err)
+	}
+}
+
 func TestCleanupSession(t *testing.T) {
 	tempDir := t.TempDir()
 	manager := NewManager(tempDir, 5)
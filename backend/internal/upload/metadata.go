@@ -0,0 +1,31 @@
+package upload
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+var ErrInvalidMetadata = errors.New("invalid metadata")
+
+// validateMetadata rejects metadata maps that exceed the configured limits so a
+// client cannot park arbitrarily large blobs in server memory.
+func validateMetadata(metadata map[string]string, limits MetadataLimits) error {
+	if limits.MaxKeys > 0 && len(metadata) > limits.MaxKeys {
+		return fmt.Errorf("%w: %d keys exceeds the limit of %d", ErrInvalidMetadata, len(metadata), limits.MaxKeys)
+	}
+
+	for key, value := range metadata {
+		if limits.MaxKeyLength > 0 && len(key) > limits.MaxKeyLength {
+			return fmt.Errorf("%w: key %.32q exceeds %d bytes", ErrInvalidMetadata, key, limits.MaxKeyLength)
+		}
+		if limits.MaxValueLength > 0 && len(value) > limits.MaxValueLength {
+			return fmt.Errorf("%w: value for key %q exceeds %d bytes", ErrInvalidMetadata, key, limits.MaxValueLength)
+		}
+		if len(limits.AllowedKeys) > 0 && !slices.Contains(limits.AllowedKeys, key) {
+			return fmt.Errorf("%w: key %q is not allowed", ErrInvalidMetadata, key)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package upload
+
+import (
+	"time"
+
+	"github.com/Steven-harris/sortify/backend/internal/clock"
+)
+
+// Options tunes Manager behaviour beyond the session limit.
+type Options struct {
+	Metadata MetadataLimits
+	Store    SessionStore // Nil keeps sessions in process memory
+	Clock    clock.Clock  // Nil uses the system clock
+
+	// SkipFullChecksum trusts per-chunk checksums in place of reading every
+	// chunk back on completion, when all of them were verified and the client
+	// declared no whole-file checksum, which is always verified. It saves
+	// minutes on multi-GB videos, but no longer catches a chunk written to
+	// the wrong offset or corrupted on disk after it was checked.
+	SkipFullChecksum bool
+
+	// Preallocation chooses between sparse temp files and eagerly reserved
+	// ones that fail fast on a full disk; empty means sparse.
+	Preallocation Preallocation
+
+	// WriteRetries is how many times a chunk write failing with a transient
+	// I/O error, such as EINTR or a network filesystem timeout, is retried,
+	// waiting WriteRetryBackoff and then twice as long each time, for at most
+	// five seconds in all. Permanent errors such as a full disk fail at once.
+	// Zero disables retries.
+	WriteRetries      int
+	WriteRetryBackoff time.Duration
+
+	// SessionTTL is how long a session may go untouched before a background
+	// sweep, every SweepInterval by Clock, removes it and its temp file to
+	// free its slot. Organizing removes a completed session, so one still
+	// there after SessionTTL failed to organize and was never retried.
+	// Paused sessions are kept for PausedSessionTTL instead, zero keeping
+	// them until resumed or cancelled. Zero SessionTTL disables the sweep.
+	SessionTTL        time.Duration
+	PausedSessionTTL  time.Duration
+	SweepInterval     time.Duration // Zero sweeps every defaultSweepInterval, or every SessionTTL if that is shorter
+	SweepPollInterval time.Duration // How often the clock is checked for a due sweep; zero means a minute, or SweepInterval if shorter
+}
+
+// MetadataLimits bounds the client-supplied metadata stored on each session.
+type MetadataLimits struct {
+	MaxKeys        int
+	MaxKeyLength   int
+	MaxValueLength int
+	AllowedKeys    []string // Empty allows any key
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Metadata: MetadataLimits{
+			MaxKeys:        32,
+			MaxKeyLength:   64,
+			MaxValueLength: 1024,
+		},
+		WriteRetries:      defaultWriteRetries,
+		WriteRetryBackoff: defaultWriteRetryBackoff,
+	}
+}
@@ -0,0 +1,48 @@
+package upload
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+)
+
+// Preallocation selects how CreateSession sizes a session's temp file.
+type Preallocation string
+
+const (
+	// PreallocateSparse only sets the file's length. It is instant, but most
+	// filesystems reserve no blocks for it, so a full disk only shows when a
+	// chunk fails to write partway through the upload.
+	PreallocateSparse Preallocation = "sparse"
+	// PreallocateEager reserves every block up front, so an upload that
+	// can't fit fails as it starts with ErrInsufficientSpace. Where the OS or
+	// filesystem can't reserve space it falls back to sparse.
+	PreallocateEager Preallocation = "eager"
+)
+
+// ErrInsufficientSpace means eager preallocation found too little free disk
+// space for the upload.
+var ErrInsufficientSpace = errors.New("insufficient disk space")
+
+// errReserveUnsupported is reserve's answer where blocks can't be reserved.
+var errReserveUnsupported = errors.New("space reservation not supported")
+
+// allocate sizes a new temp file to size bytes following the manager's
+// preallocation strategy.
+func (m *Manager) allocate(file *os.File, size int64) error {
+	if m.options.Preallocation == PreallocateEager && size > 0 {
+		err := reserve(file, size)
+		if !errors.Is(err, errReserveUnsupported) {
+			return err
+		}
+		m.fallbackOnce.Do(func() {
+			slog.Warn("Eager preallocation is not supported here, allocating sparse files", "tempDir", m.tempDir)
+		})
+	}
+
+	if err := file.Truncate(size); err != nil {
+		return fmt.Errorf("failed to allocate file space: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+//go:build linux
+
+package upload
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// reserve allocates size bytes of disk blocks to file with fallocate, which
+// also sets its length.
+func reserve(file *os.File, size int64) error {
+	for {
+		err := syscall.Fallocate(int(file.Fd()), 0, 0, size)
+		switch {
+		case err == nil:
+			return nil
+		case errors.Is(err, syscall.EINTR):
+			continue
+		case errors.Is(err, syscall.ENOSPC):
+			return fmt.Errorf("%w: cannot reserve %d bytes", ErrInsufficientSpace, size)
+		case errors.Is(err, syscall.EOPNOTSUPP), errors.Is(err, syscall.ENOSYS):
+			return errReserveUnsupported
+		default:
+			return fmt.Errorf("failed to reserve file space: %w", err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+//go:build linux
+
+package upload
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/Steven-harris/sortify/backend/internal/models"
+)
+
+func TestEagerPreallocation(t *testing.T) {
+	tempDir := t.TempDir()
+	options := DefaultOptions()
+	options.Preallocation = PreallocateEager
+	manager := NewManagerWithOptions(tempDir, 5, options)
+
+	// A small upload has its blocks reserved rather than left sparse
+	session, err := manager.CreateSession(&models.StartUploadRequest{FileName: "small.jpg", FileSize: 1 << 20, ChunkSize: 1 << 16})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	stat, err := os.Stat(session.TempPath)
+	if err != nil {
+		t.Fatalf("Failed to stat temp file: %v", err)
+	}
+	if stat.Size() != 1<<20 {
+		t.Errorf("Expected a %d byte temp file, got %d", 1<<20, stat.Size())
+	}
+	if blocks := stat.Sys().(*syscall.Stat_t).Blocks * 512; blocks < 1<<20 {
+		t.Skipf("Filesystem did not reserve blocks (%d bytes allocated), eager preallocation unsupported", blocks)
+	}
+
+	var fs syscall.Statfs_t
+	if err := syscall.Statfs(tempDir, &fs); err != nil {
+		t.Fatalf("Statfs failed: %v", err)
+	}
+	free := int64(fs.Bavail) * int64(fs.Bsize)
+
+	_, err = manager.CreateSession(&models.StartUploadRequest{FileName: "huge.mov", FileSize: free + 1<<30, ChunkSize: 64 << 20})
+	if !errors.Is(err, ErrInsufficientSpace) {
+		t.Fatalf("Expected ErrInsufficientSpace for %d bytes with %d free, got %v", free+1<<30, free, err)
+	}
+
+	// Nothing of the refused upload is left behind
+	temps, _ := filepath.Glob(filepath.Join(tempDir, "*.tmp"))
+	if len(temps) != 1 {
+		t.Errorf("Expected only the small upload's temp file, got %v", temps)
+	}
+	if active, _ := manager.ActiveSessions(); active != 1 {
+		t.Errorf("Expected the refused upload not to hold a slot, got %d active", active)
+	}
+}
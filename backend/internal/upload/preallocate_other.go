@@ -0,0 +1,10 @@
+//go:build !linux
+
+package upload
+
+import "os"
+
+// reserve has no portable equivalent of fallocate outside Linux.
+func reserve(file *os.File, size int64) error {
+	return errReserveUnsupported
+}
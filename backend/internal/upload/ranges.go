@@ -0,0 +1,74 @@
+package upload
+
+import (
+	"sort"
+
+	"github.com/Steven-harris/sortify/backend/internal/models"
+)
+
+// addRange returns ranges with [offset, offset+length) added, merging
+// overlapping and touching ranges so the result stays sorted and disjoint.
+func addRange(ranges []models.ByteRange, offset, length int64) []models.ByteRange {
+	if length <= 0 {
+		return ranges
+	}
+
+	merged := append(make([]models.ByteRange, 0, len(ranges)+1), ranges...)
+	merged = append(merged, models.ByteRange{Offset: offset, Length: length})
+	sort.Slice(merged, func(i, j int) bool {
+		return merged[i].Offset < merged[j].Offset
+	})
+
+	result := merged[:1]
+	for _, r := range merged[1:] {
+		last := &result[len(result)-1]
+		if r.Offset <= last.End() {
+			last.Length = max(last.End(), r.End()) - last.Offset
+			continue
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+// removeRange returns ranges without [offset, offset+length).
+func removeRange(ranges []models.ByteRange, offset, length int64) []models.ByteRange {
+	if length <= 0 {
+		return ranges
+	}
+
+	end := offset + length
+	result := make([]models.ByteRange, 0, len(ranges)+1)
+	for _, r := range ranges {
+		if r.End() <= offset || r.Offset >= end {
+			result = append(result, r)
+			continue
+		}
+		if r.Offset < offset {
+			result = append(result, models.ByteRange{Offset: r.Offset, Length: offset - r.Offset})
+		}
+		if r.End() > end {
+			result = append(result, models.ByteRange{Offset: end, Length: r.End() - end})
+		}
+	}
+	return result
+}
+
+// covers reports whether ranges include every byte of [offset, offset+length).
+func covers(ranges []models.ByteRange, offset, length int64) bool {
+	for _, r := range ranges {
+		if r.Offset <= offset && r.End() >= offset+length {
+			return true
+		}
+	}
+	return length <= 0
+}
+
+// coveredBytes returns how many bytes ranges span.
+func coveredBytes(ranges []models.ByteRange) int64 {
+	var total int64
+	for _, r := range ranges {
+		total += r.Length
+	}
+	return total
+}
@@ -0,0 +1,308 @@
+package upload
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Steven-harris/sortify/backend/internal/models"
+)
+
+const redisUpdateRetries = 10
+
+// redisRetryBackoff is the longest wait before the first retry of a
+// transaction; it grows with each attempt. The wait is random so instances
+// colliding on a session don't keep colliding.
+const redisRetryBackoff = 2 * time.Millisecond
+
+// RedisSessionStore keeps upload sessions in Redis so every instance behind a
+// load balancer sees the same sessions. Each session is a JSON string under
+// <prefix>session:<id>, and a set at <prefix>sessions indexes them for List.
+type RedisSessionStore struct {
+	addr     string
+	password string
+	db       int
+	prefix   string
+	timeout  time.Duration
+
+	mutex sync.Mutex // Serializes use of the single connection
+	conn  *respConn
+}
+
+// NewRedisSessionStore parses a redis://[:password@]host:port[/db] URL. The
+// connection is established lazily and re-established after errors.
+func NewRedisSessionStore(redisURL string) (*RedisSessionStore, error) {
+	parsed, err := url.Parse(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+	if parsed.Scheme != "redis" {
+		return nil, fmt.Errorf("invalid redis URL: unsupported scheme %q", parsed.Scheme)
+	}
+
+	addr := parsed.Host
+	if addr == "" {
+		return nil, fmt.Errorf("invalid redis URL: missing host")
+	}
+	if parsed.Port() == "" {
+		addr += ":6379"
+	}
+
+	store := &RedisSessionStore{
+		addr:    addr,
+		prefix:  "sortify:upload:",
+		timeout: 5 * time.Second,
+	}
+
+	if parsed.User != nil {
+		store.password, _ = parsed.User.Password()
+		if store.password == "" {
+			store.password = parsed.User.Username()
+		}
+	}
+
+	if dbPath := strings.Trim(parsed.Path, "/"); dbPath != "" {
+		if store.db, err = strconv.Atoi(dbPath); err != nil {
+			return nil, fmt.Errorf("invalid redis URL: database %q is not a number", dbPath)
+		}
+	}
+
+	return store, nil
+}
+
+// Ping verifies that Redis is reachable.
+func (s *RedisSessionStore) Ping() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	_, err := s.do("PING")
+	return err
+}
+
+func (s *RedisSessionStore) Get(id string) (*models.UploadSession, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.get(id)
+}
+
+func (s *RedisSessionStore) Put(session *models.UploadSession) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return fmt.Errorf("failed to encode session: %w", err)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.transaction(
+		[]string{"SET", s.sessionKey(session.ID), string(data)},
+		[]string{"SADD", s.indexKey(), session.ID},
+	)
+}
+
+func (s *RedisSessionStore) Delete(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.transaction(
+		[]string{"DEL", s.sessionKey(id)},
+		[]string{"SREM", s.indexKey(), id},
+	)
+}
+
+func (s *RedisSessionStore) List() ([]*models.UploadSession, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	reply, err := s.do("SMEMBERS", s.indexKey())
+	if err != nil {
+		return nil, err
+	}
+
+	ids, _ := reply.([]any)
+	sessions := make([]*models.UploadSession, 0, len(ids))
+	for _, rawID := range ids {
+		id, _ := rawID.([]byte)
+		session, err := s.get(string(id))
+		if err == ErrSessionNotFound {
+			continue // Deleted between SMEMBERS and GET
+		}
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+// Update runs fn under WATCH so a concurrent change from another instance
+// makes the transaction fail and fn is retried against the fresh session.
+func (s *RedisSessionStore) Update(id string, fn func(session *models.UploadSession) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.watch(id, func(session *models.UploadSession) ([][]string, error) {
+		if err := fn(session); err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(session)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode session: %w", err)
+		}
+		return [][]string{{"SET", s.sessionKey(id), string(data)}}, nil
+	})
+}
+
+// DeleteIf deletes the session under WATCH, so one another instance changes
+// after cond approved it is checked again rather than deleted.
+func (s *RedisSessionStore) DeleteIf(id string, cond func(session *models.UploadSession) bool) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	deleted := false
+	err := s.watch(id, func(session *models.UploadSession) ([][]string, error) {
+		deleted = cond(session)
+		if !deleted {
+			return nil, nil
+		}
+		return [][]string{
+			{"DEL", s.sessionKey(id)},
+			{"SREM", s.indexKey(), id},
+		}, nil
+	})
+	if err == ErrSessionNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return deleted, nil
+}
+
+// watch reads the session under WATCH and runs the commands build returns
+// for it in one transaction, which Redis discards if the session changed in
+// between; build then runs again against the fresh session. No commands
+// means there is nothing to write.
+func (s *RedisSessionStore) watch(id string, build func(session *models.UploadSession) ([][]string, error)) error {
+	key := s.sessionKey(id)
+	for attempt := 0; attempt < redisUpdateRetries; attempt++ {
+		if _, err := s.do("WATCH", key); err != nil {
+			return err
+		}
+
+		var commands [][]string
+		session, err := s.get(id)
+		if err == nil {
+			commands, err = build(session)
+		}
+		if err != nil || len(commands) == 0 {
+			s.do("UNWATCH")
+			return err
+		}
+
+		committed, err := s.exec(commands...)
+		if err != nil {
+			return err
+		}
+		if committed {
+			return nil
+		}
+		time.Sleep(rand.N(redisRetryBackoff * time.Duration(attempt+1)))
+	}
+
+	return fmt.Errorf("session %s is being updated concurrently, gave up after %d attempts", id, redisUpdateRetries)
+}
+
+func (s *RedisSessionStore) get(id string) (*models.UploadSession, error) {
+	reply, err := s.do("GET", s.sessionKey(id))
+	if err != nil {
+		return nil, err
+	}
+
+	data, ok := reply.([]byte)
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+
+	var session models.UploadSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to decode session %s: %w", id, err)
+	}
+	if session.Received == nil {
+		session.Received = make(map[int]bool)
+	}
+
+	return &session, nil
+}
+
+func (s *RedisSessionStore) transaction(commands ...[]string) error {
+	_, err := s.exec(commands...)
+	return err
+}
+
+// exec runs commands inside MULTI/EXEC. It reports false when a WATCHed key
+// changed and Redis discarded the transaction.
+func (s *RedisSessionStore) exec(commands ...[]string) (bool, error) {
+	if _, err := s.do("MULTI"); err != nil {
+		return false, err
+	}
+	for _, command := range commands {
+		if _, err := s.do(command...); err != nil {
+			s.do("DISCARD")
+			return false, err
+		}
+	}
+
+	reply, err := s.do("EXEC")
+	if err != nil {
+		return false, err
+	}
+	if reply == nil {
+		return false, nil
+	}
+
+	for _, item := range reply.([]any) {
+		if replyErr, ok := item.(redisError); ok {
+			return false, replyErr
+		}
+	}
+	return true, nil
+}
+
+// do runs a command on the shared connection, dialing on first use and
+// dropping the connection after I/O errors so the next call reconnects.
+func (s *RedisSessionStore) do(args ...string) (any, error) {
+	if s.conn == nil {
+		conn, err := dialRESP(s.addr, s.password, s.db, s.timeout)
+		if err != nil {
+			return nil, err
+		}
+		s.conn = conn
+	}
+
+	reply, err := s.conn.do(args...)
+	if err != nil {
+		if _, isReplyErr := err.(redisError); !isReplyErr {
+			s.conn.Close()
+			s.conn = nil
+		}
+		return nil, err
+	}
+
+	return reply, nil
+}
+
+func (s *RedisSessionStore) sessionKey(id string) string {
+	return s.prefix + "session:" + id
+}
+
+func (s *RedisSessionStore) indexKey() string {
+	return s.prefix + "sessions"
+}
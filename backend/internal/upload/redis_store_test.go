@@ -0,0 +1,255 @@
+package upload
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Steven-harris/sortify/backend/internal/models"
+)
+
+// fakeRedis serves the subset of Redis RedisSessionStore uses, including
+// WATCH/MULTI/EXEC, so the store's transactions run against real semantics.
+type fakeRedis struct {
+	mutex    sync.Mutex
+	strings  map[string]string
+	sets     map[string]map[string]bool
+	versions map[string]int // Bumped on every write, for WATCH
+}
+
+func startFakeRedis(t *testing.T) string {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	server := &fakeRedis{
+		strings:  make(map[string]string),
+		sets:     make(map[string]map[string]bool),
+		versions: make(map[string]int),
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn)
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func (r *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	var watched map[string]int
+	var queued [][]string
+	inMulti := false
+
+	for {
+		args, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+
+		var reply string
+		switch name := strings.ToUpper(args[0]); {
+		case name == "WATCH":
+			r.mutex.Lock()
+			watched = map[string]int{}
+			for _, key := range args[1:] {
+				watched[key] = r.versions[key]
+			}
+			r.mutex.Unlock()
+			reply = "+OK\r\n"
+		case name == "UNWATCH":
+			watched = nil
+			reply = "+OK\r\n"
+		case name == "MULTI":
+			inMulti, queued = true, nil
+			reply = "+OK\r\n"
+		case name == "DISCARD":
+			inMulti, queued, watched = false, nil, nil
+			reply = "+OK\r\n"
+		case name == "EXEC":
+			r.mutex.Lock()
+			aborted := false
+			for key, version := range watched {
+				aborted = aborted || r.versions[key] != version
+			}
+			if aborted {
+				reply = "*-1\r\n"
+			} else {
+				reply = "*" + strconv.Itoa(len(queued)) + "\r\n"
+				for _, command := range queued {
+					reply += r.run(command)
+				}
+			}
+			r.mutex.Unlock()
+			inMulti, queued, watched = false, nil, nil
+		case inMulti:
+			queued = append(queued, args)
+			reply = "+QUEUED\r\n"
+		default:
+			r.mutex.Lock()
+			reply = r.run(args)
+			r.mutex.Unlock()
+		}
+
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+// run executes one command and returns its encoded reply. The caller holds
+// the mutex.
+func (r *fakeRedis) run(args []string) string {
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "GET":
+		value, exists := r.strings[args[1]]
+		if !exists {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case "SET":
+		r.strings[args[1]] = args[2]
+		r.versions[args[1]]++
+		return "+OK\r\n"
+	case "DEL":
+		_, exists := r.strings[args[1]]
+		delete(r.strings, args[1])
+		r.versions[args[1]]++
+		if exists {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	case "SADD":
+		if r.sets[args[1]] == nil {
+			r.sets[args[1]] = make(map[string]bool)
+		}
+		r.sets[args[1]][args[2]] = true
+		r.versions[args[1]]++
+		return ":1\r\n"
+	case "SREM":
+		delete(r.sets[args[1]], args[2])
+		r.versions[args[1]]++
+		return ":1\r\n"
+	case "SMEMBERS":
+		reply := "*" + strconv.Itoa(len(r.sets[args[1]])) + "\r\n"
+		for member := range r.sets[args[1]] {
+			reply += fmt.Sprintf("$%d\r\n%s\r\n", len(member), member)
+		}
+		return reply
+	default:
+		return "-ERR unknown command '" + args[0] + "'\r\n"
+	}
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, count)
+	for i := range args {
+		if line, err = reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+		if err != nil {
+			return nil, err
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, data); err != nil {
+			return nil, err
+		}
+		args[i] = string(data[:size])
+	}
+	return args, nil
+}
+
+func TestRedisSessionStoreConcurrentUpdates(t *testing.T) {
+	addr := startFakeRedis(t)
+
+	// Two instances, each with its own connection to the shared Redis
+	instanceA, _ := NewRedisSessionStore("redis://" + addr)
+	instanceB, _ := NewRedisSessionStore("redis://" + addr)
+
+	if err := instanceA.Put(&models.UploadSession{ID: "shared", Received: map[int]bool{}}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	const chunks = 40
+	var wg sync.WaitGroup
+	for chunk := 0; chunk < chunks; chunk++ {
+		store := instanceA
+		if chunk%2 == 1 {
+			store = instanceB
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := store.Update("shared", func(session *models.UploadSession) error {
+				session.Received[chunk] = true
+				return nil
+			})
+			if err != nil {
+				t.Errorf("Update for chunk %d failed: %v", chunk, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	session, err := instanceB.Get("shared")
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if len(session.Received) != chunks {
+		t.Errorf("Expected %d received chunks, got %d", chunks, len(session.Received))
+	}
+}
+
+func TestRedisSessionStoreDeleteIf(t *testing.T) {
+	store, _ := NewRedisSessionStore("redis://" + startFakeRedis(t))
+
+	if err := store.Put(&models.UploadSession{ID: "a", Status: models.StatusUploading}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	completed := func(session *models.UploadSession) bool { return session.Status == models.StatusCompleted }
+	deleted, err := store.DeleteIf("a", completed)
+	if err != nil || deleted {
+		t.Fatalf("Expected a session failing the condition to be kept, got deleted=%v err=%v", deleted, err)
+	}
+
+	uploading := func(session *models.UploadSession) bool { return session.Status == models.StatusUploading }
+	if deleted, err = store.DeleteIf("a", uploading); err != nil || !deleted {
+		t.Fatalf("Expected the session to be deleted, got deleted=%v err=%v", deleted, err)
+	}
+	if sessions, err := store.List(); err != nil || len(sessions) != 0 {
+		t.Errorf("Expected no sessions left, got %d (err=%v)", len(sessions), err)
+	}
+
+	if deleted, err = store.DeleteIf("a", uploading); err != nil || deleted {
+		t.Errorf("Expected a missing session to be reported not deleted, got deleted=%v err=%v", deleted, err)
+	}
+}
@@ -0,0 +1,87 @@
+package upload
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"syscall"
+	"time"
+)
+
+const (
+	defaultWriteRetries      = 3
+	defaultWriteRetryBackoff = 50 * time.Millisecond
+
+	// maxWriteRetryWait caps the total time one write spends backing off,
+	// however many retries are configured.
+	maxWriteRetryWait = 5 * time.Second
+)
+
+// chunkFile is the part of an open temp file that chunk writes use.
+type chunkFile interface {
+	io.WriterAt
+	io.Closer
+}
+
+func openChunkFile(path string) (chunkFile, error) {
+	return os.OpenFile(path, os.O_WRONLY, 0644)
+}
+
+// isTransient reports whether an I/O error is worth retrying: interrupted or
+// would-block calls, a busy device and timeouts, which flaky local disks and
+// network filesystems produce. A full disk, missing permissions and anything
+// unrecognized fail straight away.
+func isTransient(err error) bool {
+	for _, errno := range []syscall.Errno{syscall.EINTR, syscall.EAGAIN, syscall.EBUSY, syscall.ETIMEDOUT} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	var timeout interface{ Timeout() bool }
+	return errors.As(err, &timeout) && timeout.Timeout()
+}
+
+// retryIO runs op, retrying transient failures up to the configured number of
+// times with a doubling backoff, as measured by the manager's clock, and
+// giving up early rather than wait more than maxWriteRetryWait in all. op must
+// be safe to repeat; callers must not hold the manager's lock.
+func (m *Manager) retryIO(sessionID string, op func() error) error {
+	backoff := m.options.WriteRetryBackoff
+	var waited time.Duration
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if err == nil || !isTransient(err) || attempt >= m.options.WriteRetries || waited+backoff > maxWriteRetryWait {
+			return err
+		}
+
+		slog.Warn("Transient chunk write failure, retrying",
+			"error", err,
+			"sessionId", sessionID,
+			"attempt", attempt+1,
+			"backoff", backoff,
+		)
+		m.clock.Sleep(backoff)
+		waited += backoff
+		backoff *= 2
+	}
+}
+
+// writeChunkAt writes data at offset in the session's temp file, reopening
+// the file on each retry. Positional writes make a repeated attempt simply
+// overwrite whatever part of the chunk the failed one managed.
+func (m *Manager) writeChunkAt(sessionID, path string, offset int64, data []byte) error {
+	return m.retryIO(sessionID, func() error {
+		file, err := m.openChunkFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to open temporary file: %w", err)
+		}
+		defer file.Close()
+
+		if _, err := file.WriteAt(data, offset); err != nil {
+			return fmt.Errorf("failed to write chunk data: %w", err)
+		}
+		return nil
+	})
+}
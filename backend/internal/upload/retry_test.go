@@ -0,0 +1,130 @@
+package upload
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/Steven-harris/sortify/backend/internal/clock"
+	"github.com/Steven-harris/sortify/backend/internal/models"
+)
+
+// flakyFile fails its first writes with err before writing through.
+type flakyFile struct {
+	*os.File
+	failures *int
+	err      error
+}
+
+func (f flakyFile) WriteAt(p []byte, off int64) (int, error) {
+	if *f.failures > 0 {
+		*f.failures--
+		return 0, f.err
+	}
+	return f.File.WriteAt(p, off)
+}
+
+func TestChunkWriteRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		retries      int
+		failures     int
+		err          error
+		wantAttempts int
+		wantErr      bool
+	}{
+		{"transient error retried", 3, 2, syscall.EAGAIN, 3, false},
+		{"interrupted write retried", 3, 1, syscall.EINTR, 2, false},
+		{"retries exhausted", 2, 5, syscall.ETIMEDOUT, 3, true},
+		{"disk full fails at once", 3, 1, syscall.ENOSPC, 1, true},
+		{"retries disabled", 0, 1, syscall.EAGAIN, 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager := NewManager(t.TempDir(), 5)
+			manager.options.WriteRetries = tt.retries
+			manager.options.WriteRetryBackoff = time.Millisecond
+
+			failures, attempts := tt.failures, 0
+			manager.openChunkFile = func(path string) (chunkFile, error) {
+				attempts++
+				file, err := os.OpenFile(path, os.O_WRONLY, 0644)
+				if err != nil {
+					return nil, err
+				}
+				return flakyFile{File: file, failures: &failures, err: tt.err}, nil
+			}
+
+			content := []byte("flaky disk")
+			session, err := manager.CreateSession(&models.StartUploadRequest{
+				FileName:  "test.jpg",
+				FileSize:  int64(len(content)),
+				ChunkSize: int64(len(content)),
+			})
+			if err != nil {
+				t.Fatalf("CreateSession failed: %v", err)
+			}
+
+			err = manager.UploadChunk(session.ID, 0, content, "")
+			if attempts != tt.wantAttempts {
+				t.Errorf("Expected %d attempts, got %d", tt.wantAttempts, attempts)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, tt.err) {
+					t.Errorf("Expected %v, got %v", tt.err, err)
+				}
+				if session, _ := manager.GetSession(session.ID); session.Status != models.StatusFailed {
+					t.Errorf("Expected the session to fail, got %s", session.Status)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("UploadChunk failed: %v", err)
+			}
+			if data, err := os.ReadFile(session.TempPath); err != nil || string(data) != string(content) {
+				t.Errorf("Expected the chunk written once retried, got %q (%v)", data, err)
+			}
+		})
+	}
+}
+
+func TestChunkWriteRetryWaitIsCapped(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC))
+	start := fake.Now()
+
+	options := DefaultOptions()
+	options.Clock = fake
+	options.WriteRetries = 10
+	options.WriteRetryBackoff = time.Second
+	manager := NewManagerWithOptions(t.TempDir(), 5, options)
+
+	failures, attempts := 100, 0
+	manager.openChunkFile = func(path string) (chunkFile, error) {
+		attempts++
+		file, err := os.OpenFile(path, os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, err
+		}
+		return flakyFile{File: file, failures: &failures, err: syscall.EAGAIN}, nil
+	}
+
+	session, err := manager.CreateSession(&models.StartUploadRequest{FileName: "test.jpg", FileSize: 4, ChunkSize: 4})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if err := manager.UploadChunk(session.ID, 0, []byte("data"), ""); !errors.Is(err, syscall.EAGAIN) {
+		t.Fatalf("Expected %v, got %v", syscall.EAGAIN, err)
+	}
+
+	// Waiting 1s and 2s leaves no room for 4s more under the 5s cap
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+	if waited := fake.Now().Sub(start); waited != 3*time.Second {
+		t.Errorf("Expected 3s of backoff, got %v", waited)
+	}
+}
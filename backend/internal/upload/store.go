@@ -0,0 +1,145 @@
+package upload
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/Steven-harris/sortify/backend/internal/models"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionStore persists upload sessions. Implementations must hand out copies:
+// a session returned by Get is only changed in the store by a later Put.
+//
+// The default store keeps sessions in process memory. A shared store such as
+// RedisSessionStore lets several instances behind a load balancer serve chunks
+// for the same upload, provided the temp directory is on shared storage too.
+type SessionStore interface {
+	Get(id string) (*models.UploadSession, error)
+	Put(session *models.UploadSession) error
+	Delete(id string) error
+	List() ([]*models.UploadSession, error)
+}
+
+// SessionUpdater is implemented by stores that can apply a read-modify-write
+// atomically, so concurrent chunk uploads handled by different instances don't
+// overwrite each other's progress.
+type SessionUpdater interface {
+	Update(id string, fn func(session *models.UploadSession) error) error
+}
+
+// SessionRemover is implemented by stores that can delete a session only if
+// it still satisfies cond, so a sweep can't delete a session another instance
+// just received a chunk for. It reports whether the session was deleted; a
+// missing session is not an error.
+type SessionRemover interface {
+	DeleteIf(id string, cond func(session *models.UploadSession) bool) (bool, error)
+}
+
+type MemorySessionStore struct {
+	sessions map[string]*models.UploadSession
+	mutex    sync.RWMutex
+}
+
+func NewMemorySessionStore() *MemorySessionStore {
+	return &MemorySessionStore{sessions: make(map[string]*models.UploadSession)}
+}
+
+func (s *MemorySessionStore) Get(id string) (*models.UploadSession, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	session, exists := s.sessions[id]
+	if !exists {
+		return nil, ErrSessionNotFound
+	}
+	return copySession(session), nil
+}
+
+func (s *MemorySessionStore) Put(session *models.UploadSession) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.sessions[session.ID] = copySession(session)
+	return nil
+}
+
+func (s *MemorySessionStore) Delete(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.sessions, id)
+	return nil
+}
+
+func (s *MemorySessionStore) List() ([]*models.UploadSession, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	sessions := make([]*models.UploadSession, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		sessions = append(sessions, copySession(session))
+	}
+	return sessions, nil
+}
+
+func (s *MemorySessionStore) Update(id string, fn func(session *models.UploadSession) error) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, exists := s.sessions[id]
+	if !exists {
+		return ErrSessionNotFound
+	}
+
+	updated := copySession(session)
+	if err := fn(updated); err != nil {
+		return err
+	}
+	s.sessions[id] = updated
+	return nil
+}
+
+func (s *MemorySessionStore) DeleteIf(id string, cond func(session *models.UploadSession) bool) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	session, exists := s.sessions[id]
+	if !exists || !cond(copySession(session)) {
+		return false, nil
+	}
+	delete(s.sessions, id)
+	return true, nil
+}
+
+func copySession(session *models.UploadSession) *models.UploadSession {
+	clone := *session
+
+	if session.Metadata != nil {
+		clone.Metadata = make(map[string]string, len(session.Metadata))
+		for key, value := range session.Metadata {
+			clone.Metadata[key] = value
+		}
+	}
+
+	clone.Received = make(map[int]bool, len(session.Received))
+	for chunk := range session.Received {
+		clone.Received[chunk] = true
+	}
+	if session.Verified != nil {
+		clone.Verified = make(map[int]bool, len(session.Verified))
+		for chunk, verified := range session.Verified {
+			clone.Verified[chunk] = verified
+		}
+	}
+	if session.ChunkHashes != nil {
+		clone.ChunkHashes = make(map[int]string, len(session.ChunkHashes))
+		for chunk, hash := range session.ChunkHashes {
+			clone.ChunkHashes[chunk] = hash
+		}
+	}
+	clone.Ranges = append([]models.ByteRange(nil), session.Ranges...)
+
+	return &clone
+}
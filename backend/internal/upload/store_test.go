@@ -0,0 +1,364 @@
+package upload
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Steven-harris/sortify/backend/internal/models"
+)
+
+// jsonStore is a fake shared store that round-trips sessions through JSON, as a
+// networked store would, so any change the manager forgets to Put is lost.
+type jsonStore struct {
+	mutex    sync.Mutex
+	sessions map[string][]byte
+	puts     int
+}
+
+func newJSONStore() *jsonStore {
+	return &jsonStore{sessions: make(map[string][]byte)}
+}
+
+func (s *jsonStore) Get(id string) (*models.UploadSession, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	data, ok := s.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	var session models.UploadSession
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+func (s *jsonStore) Put(session *models.UploadSession) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.sessions[session.ID] = data
+	s.puts++
+	return nil
+}
+
+func (s *jsonStore) Delete(id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.sessions, id)
+	return nil
+}
+
+func (s *jsonStore) List() ([]*models.UploadSession, error) {
+	s.mutex.Lock()
+	ids := make([]string, 0, len(s.sessions))
+	for id := range s.sessions {
+		ids = append(ids, id)
+	}
+	s.mutex.Unlock()
+
+	var sessions []*models.UploadSession
+	for _, id := range ids {
+		if session, err := s.Get(id); err == nil {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
+
+func TestManagerWithSharedStore(t *testing.T) {
+	tempDir := t.TempDir() // Shared storage for both instances
+	store := newJSONStore()
+	options := DefaultOptions()
+	options.Store = store
+
+	instanceA := NewManagerWithOptions(tempDir, 5, options)
+	instanceB := NewManagerWithOptions(tempDir, 5, options)
+
+	session, err := instanceA.CreateSession(&models.StartUploadRequest{
+		FileName:  "test.jpg",
+		FileSize:  20,
+		ChunkSize: 10,
+	})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	// The load balancer sends each chunk to a different instance
+	if err := instanceA.UploadChunk(session.ID, 0, []byte("0123456789"), ""); err != nil {
+		t.Fatalf("UploadChunk 0 on instance A failed: %v", err)
+	}
+	if err := instanceB.UploadChunk(session.ID, 1, []byte("abcdefghij"), ""); err != nil {
+		t.Fatalf("UploadChunk 1 on instance B failed: %v", err)
+	}
+
+	progress, err := instanceA.GetProgress(session.ID)
+	if err != nil {
+		t.Fatalf("GetProgress failed: %v", err)
+	}
+	if progress.UploadedBytes != 20 {
+		t.Errorf("Expected 20 uploaded bytes, got %d", progress.UploadedBytes)
+	}
+
+	if err := instanceA.PauseUpload(session.ID); err != nil {
+		t.Fatalf("PauseUpload failed: %v", err)
+	}
+	if err := instanceB.ResumeUpload(session.ID); err != nil {
+		t.Fatalf("ResumeUpload on the other instance failed: %v", err)
+	}
+
+	if err := instanceB.CompleteUpload(session.ID, ""); err != nil {
+		t.Fatalf("CompleteUpload failed: %v", err)
+	}
+	tempPath, err := instanceA.GetTempFilePath(session.ID)
+	if err != nil {
+		t.Fatalf("GetTempFilePath failed: %v", err)
+	}
+	if tempPath != session.TempPath {
+		t.Errorf("Expected temp path %s, got %s", session.TempPath, tempPath)
+	}
+
+	if err := instanceA.CleanupSession(session.ID); err != nil {
+		t.Fatalf("CleanupSession failed: %v", err)
+	}
+	if _, err := instanceB.GetSession(session.ID); err != ErrSessionNotFound {
+		t.Errorf("Expected ErrSessionNotFound after cleanup, got %v", err)
+	}
+	if store.puts == 0 {
+		t.Error("Expected the manager to write through the store")
+	}
+}
+
+func TestFileSessionStoreSurvivesRestart(t *testing.T) {
+	tempDir := t.TempDir()
+	storePath := filepath.Join(tempDir, "sessions")
+
+	newManager := func() *Manager {
+		store, err := NewFileSessionStore(storePath)
+		if err != nil {
+			t.Fatalf("NewFileSessionStore failed: %v", err)
+		}
+		options := DefaultOptions()
+		options.Store = store
+		return NewManagerWithOptions(tempDir, 5, options)
+	}
+	start := func(manager *Manager, fileName string) *models.UploadSession {
+		session, err := manager.CreateSession(&models.StartUploadRequest{FileName: fileName, FileSize: 20, ChunkSize: 10})
+		if err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+		return session
+	}
+
+	before := newManager()
+	session := start(before, "test.jpg")
+	if err := before.UploadChunk(session.ID, 0, []byte("0123456789"), ""); err != nil {
+		t.Fatalf("UploadChunk failed: %v", err)
+	}
+	cancelled := start(before, "cancelled.jpg")
+	if err := before.CancelUpload(cancelled.ID); err != nil {
+		t.Fatalf("CancelUpload failed: %v", err)
+	}
+	failed := start(before, "failed.jpg")
+	if err := before.FailSession(failed.ID, "organize failed"); err != nil {
+		t.Fatalf("FailSession failed: %v", err)
+	}
+	orphaned := start(before, "orphaned.jpg")
+	os.Remove(orphaned.TempPath)
+	completed := start(before, "completed.jpg")
+	for chunk, data := range []string{"0123456789", "abcdefghij"} {
+		if err := before.UploadChunk(completed.ID, chunk, []byte(data), ""); err != nil {
+			t.Fatalf("UploadChunk failed: %v", err)
+		}
+	}
+	if err := before.CompleteUpload(completed.ID, ""); err != nil {
+		t.Fatalf("CompleteUpload failed: %v", err)
+	}
+
+	// The process restarts
+	after := newManager()
+
+	restored, err := after.GetSession(session.ID)
+	if err != nil {
+		t.Fatalf("Expected the session to survive the restart: %v", err)
+	}
+	if restored.UploadedSize != 10 || !restored.Received[0] || restored.TempPath != session.TempPath {
+		t.Errorf("Expected chunk 0 of %s recorded, got %+v", session.TempPath, restored)
+	}
+	for _, id := range []string{cancelled.ID, failed.ID, orphaned.ID, completed.ID} {
+		if _, err := after.GetSession(id); err != ErrSessionNotFound {
+			t.Errorf("Expected session %s pruned, got %v", id, err)
+		}
+	}
+	if _, err := os.Stat(failed.TempPath); !os.IsNotExist(err) {
+		t.Error("Expected the failed session's temp file removed")
+	}
+
+	// The client carries on where it left off
+	if err := after.UploadChunk(session.ID, 1, []byte("abcdefghij"), ""); err != nil {
+		t.Fatalf("UploadChunk after restart failed: %v", err)
+	}
+	if err := after.CompleteUpload(session.ID, ""); err != nil {
+		t.Fatalf("CompleteUpload failed: %v", err)
+	}
+	data, err := os.ReadFile(session.TempPath)
+	if err != nil {
+		t.Fatalf("Failed to read temp file: %v", err)
+	}
+	if string(data) != "0123456789abcdefghij" {
+		t.Errorf("Expected the bytes from before and after the restart, got %q", data)
+	}
+
+	if err := after.CleanupSession(session.ID); err != nil {
+		t.Fatalf("CleanupSession failed: %v", err)
+	}
+	if _, err := newManager().GetSession(session.ID); err != ErrSessionNotFound {
+		t.Errorf("Expected the cleaned-up session gone from the file, got %v", err)
+	}
+}
+
+func TestMemorySessionStoreReturnsCopies(t *testing.T) {
+	store := NewMemorySessionStore()
+	store.Put(&models.UploadSession{ID: "a", Received: map[int]bool{}, Verified: map[int]bool{}})
+
+	session, _ := store.Get("a")
+	session.UploadedSize = 99
+	session.Received[0] = true
+	session.Verified[0] = true
+
+	stored, _ := store.Get("a")
+	if stored.UploadedSize != 0 || len(stored.Received) != 0 || len(stored.Verified) != 0 {
+		t.Error("Expected changes to a fetched session not to leak into the store without Put")
+	}
+}
+
+func TestSessionStoresCopyChunkMaps(t *testing.T) {
+	fileStore, err := NewFileSessionStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewFileSessionStore failed: %v", err)
+	}
+	stores := map[string]interface {
+		SessionStore
+		SessionUpdater
+	}{
+		"memory": NewMemorySessionStore(),
+		"file":   fileStore,
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			store.Put(&models.UploadSession{
+				ID:          "a",
+				Received:    map[int]bool{0: true},
+				Verified:    map[int]bool{0: true},
+				ChunkHashes: map[int]string{0: "aa"},
+			})
+
+			fetched, _ := store.Get("a")
+			fetched.Verified[1] = true
+			fetched.ChunkHashes[1] = "bb"
+			listed, _ := store.List()
+			delete(listed[0].Verified, 0)
+			delete(listed[0].ChunkHashes, 0)
+
+			// A failed update must leave the stored session as it was
+			store.Update("a", func(session *models.UploadSession) error {
+				session.Verified[2] = true
+				session.ChunkHashes[2] = "cc"
+				return errors.New("abandoned")
+			})
+
+			stored, _ := store.Get("a")
+			if !reflect.DeepEqual(stored.Verified, map[int]bool{0: true}) {
+				t.Errorf("Expected the stored verified chunks unchanged, got %v", stored.Verified)
+			}
+			if !reflect.DeepEqual(stored.ChunkHashes, map[int]string{0: "aa"}) {
+				t.Errorf("Expected the stored chunk hashes unchanged, got %v", stored.ChunkHashes)
+			}
+		})
+	}
+}
+
+func TestRESPReadReply(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"Simple string", "+OK\r\n", "OK"},
+		{"Integer", ":42\r\n", "42"},
+		{"Bulk string", "$5\r\nhello\r\n", "hello"},
+		{"Nil bulk", "$-1\r\n", "<nil>"},
+		{"Array", "*2\r\n$1\r\na\r\n:1\r\n", "[a 1]"},
+		{"Nil array", "*-1\r\n", "<nil>"},
+		{"Error in array", "*1\r\n-ERR wrong type\r\n", "[redis: ERR wrong type]"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn := &respConn{reader: bufio.NewReader(strings.NewReader(test.input))}
+			reply, err := conn.readReply()
+			if err != nil {
+				t.Fatalf("readReply failed: %v", err)
+			}
+			if got := formatReply(reply); got != test.expected {
+				t.Errorf("Expected %s, got %s", test.expected, got)
+			}
+		})
+	}
+
+	conn := &respConn{reader: bufio.NewReader(strings.NewReader("-ERR unknown command\r\n"))}
+	if _, err := conn.readReply(); err == nil || err.Error() != "redis: ERR unknown command" {
+		t.Errorf("Expected redis error reply, got %v", err)
+	}
+}
+
+func formatReply(reply any) string {
+	switch value := reply.(type) {
+	case nil:
+		return "<nil>"
+	case []byte:
+		return string(value)
+	case []any:
+		parts := make([]string, len(value))
+		for i, item := range value {
+			parts[i] = formatReply(item)
+		}
+		return "[" + strings.Join(parts, " ") + "]"
+	case error:
+		return value.Error()
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+func TestNewRedisSessionStoreParsesURL(t *testing.T) {
+	store, err := NewRedisSessionStore("redis://:secret@cache.internal/2")
+	if err != nil {
+		t.Fatalf("NewRedisSessionStore failed: %v", err)
+	}
+	if store.addr != "cache.internal:6379" || store.password != "secret" || store.db != 2 {
+		t.Errorf("Unexpected connection settings: addr=%s password=%s db=%d", store.addr, store.password, store.db)
+	}
+
+	for _, invalid := range []string{"http://localhost:6379", "redis://", "redis://localhost/abc"} {
+		if _, err := NewRedisSessionStore(invalid); err == nil {
+			t.Errorf("Expected error for %s", invalid)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package upload
+
+import (
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/Steven-harris/sortify/backend/internal/models"
+)
+
+// defaultSweepInterval is how often stale sessions are looked for when
+// Options.SweepInterval is unset.
+const defaultSweepInterval = 10 * time.Minute
+
+// defaultSweepPoll is how often the sweeper checks the clock for a due sweep
+// when Options.SweepPollInterval is unset and the interval is longer.
+const defaultSweepPoll = time.Minute
+
+// startSweeper runs ExpireSessions every SweepInterval as measured by the
+// manager's clock, checking it every SweepPollInterval.
+func (m *Manager) startSweeper() {
+	interval := m.options.SweepInterval
+	if interval <= 0 {
+		interval = min(defaultSweepInterval, m.options.SessionTTL)
+	}
+	poll := m.options.SweepPollInterval
+	if poll <= 0 {
+		poll = min(defaultSweepPoll, interval)
+	}
+
+	m.stopSweep = make(chan struct{})
+	m.sweepDone = make(chan struct{})
+
+	go func() {
+		defer close(m.sweepDone)
+
+		ticker := time.NewTicker(poll)
+		defer ticker.Stop()
+
+		next := m.clock.Now().Add(interval)
+		for {
+			select {
+			case <-m.stopSweep:
+				return
+			case <-ticker.C:
+			}
+			if m.clock.Now().Before(next) {
+				continue
+			}
+
+			expired, err := m.ExpireSessions(m.options.SessionTTL, m.options.PausedSessionTTL)
+			if err != nil {
+				slog.Error("Failed to sweep stale upload sessions", "error", err)
+			} else if expired > 0 {
+				slog.Info("Swept stale upload sessions", "expired", expired, "ttl", m.options.SessionTTL)
+			}
+			next = m.clock.Now().Add(interval)
+		}
+	}()
+}
+
+// Stop ends the stale session sweep and waits for it to finish. It is safe to
+// call more than once, and on managers that never swept.
+func (m *Manager) Stop() {
+	if m.stopSweep == nil {
+		return
+	}
+	m.stopOnce.Do(func() { close(m.stopSweep) })
+	<-m.sweepDone
+}
+
+// ExpireSessions removes every session not updated for longer than ttl,
+// deleting its temp file, and reports how many went. Completed sessions go
+// too, since organizing removes them: one left that long failed to organize
+// and was abandoned. Paused sessions only expire after pausedTTL, and never
+// when it is zero, as their uploads are meant to resume.
+func (m *Manager) ExpireSessions(ttl, pausedTTL time.Duration) (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	sessions, err := m.sessions.List()
+	if err != nil {
+		return 0, err
+	}
+
+	now := m.clock.Now()
+	stale := func(session *models.UploadSession) bool {
+		limit := ttl
+		if session.Status == models.StatusPaused {
+			limit = pausedTTL
+		}
+		return limit > 0 && session.UpdatedAt.Before(now.Add(-limit))
+	}
+
+	expired := 0
+	for _, session := range sessions {
+		if !stale(session) {
+			continue
+		}
+
+		// Checked again as it is deleted, in case another instance sharing
+		// the store has just received a chunk for it
+		deleted, err := m.deleteIf(session.ID, stale)
+		if err != nil {
+			return expired, err
+		}
+		if !deleted {
+			continue
+		}
+		os.Remove(session.TempPath)
+		slog.Debug("Expired stale upload session", "sessionId", session.ID, "status", session.Status, "updatedAt", session.UpdatedAt)
+		expired++
+	}
+	return expired, nil
+}
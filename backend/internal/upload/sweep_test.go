@@ -0,0 +1,148 @@
+package upload
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Steven-harris/sortify/backend/internal/clock"
+	"github.com/Steven-harris/sortify/backend/internal/models"
+)
+
+func TestNewManagerWithTTLReapsIdleSession(t *testing.T) {
+	manager := NewManagerWithTTL(t.TempDir(), 1, 50*time.Millisecond, 10*time.Millisecond)
+	defer manager.Stop()
+
+	session, err := manager.CreateSession(&models.StartUploadRequest{FileName: "idle.jpg", FileSize: 10, ChunkSize: 10})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := manager.GetSession(session.ID); errors.Is(err, ErrSessionNotFound) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the idle session to be reaped")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if _, err := os.Stat(session.TempPath); !os.IsNotExist(err) {
+		t.Errorf("Expected the temp file to be removed, got %v", err)
+	}
+	// The reaped session's slot is free again
+	if _, err := manager.CreateSession(&models.StartUploadRequest{FileName: "next.jpg", FileSize: 10, ChunkSize: 10}); err != nil {
+		t.Errorf("Expected a new session to fit, got %v", err)
+	}
+
+	manager.Stop()
+	manager.Stop()
+}
+
+func TestExpireSessions(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC))
+	options := DefaultOptions()
+	options.Clock = fake
+	manager := NewManagerWithOptions(t.TempDir(), 5, options)
+	defer manager.Stop()
+
+	create := func(name string) *models.UploadSession {
+		session, err := manager.CreateSession(&models.StartUploadRequest{FileName: name, FileSize: 10, ChunkSize: 10})
+		if err != nil {
+			t.Fatalf("CreateSession failed: %v", err)
+		}
+		return session
+	}
+
+	idle := create("idle.jpg")
+	paused := create("paused.jpg")
+	if err := manager.PauseUpload(paused.ID); err != nil {
+		t.Fatalf("PauseUpload failed: %v", err)
+	}
+	completed := create("completed.jpg")
+	if err := manager.UploadChunk(completed.ID, 0, []byte("0123456789"), ""); err != nil {
+		t.Fatalf("UploadChunk failed: %v", err)
+	}
+	if err := manager.CompleteUpload(completed.ID, ""); err != nil {
+		t.Fatalf("CompleteUpload failed: %v", err)
+	}
+
+	fake.Advance(2 * time.Hour)
+	fresh := create("fresh.jpg")
+
+	// Paused sessions outlive the TTL unless they have one of their own
+	expired, err := manager.ExpireSessions(time.Hour, 0)
+	if err != nil {
+		t.Fatalf("ExpireSessions failed: %v", err)
+	}
+	if expired != 2 {
+		t.Errorf("Expected 2 sessions expired, got %d", expired)
+	}
+
+	// The completed session was never organized, so it is abandoned too
+	for _, session := range []*models.UploadSession{idle, completed} {
+		if _, err := manager.GetSession(session.ID); !errors.Is(err, ErrSessionNotFound) {
+			t.Errorf("Expected %s to be expired, got %v", session.FileName, err)
+		}
+		if _, err := os.Stat(session.TempPath); !os.IsNotExist(err) {
+			t.Errorf("Expected the temp file of %s to be removed, got %v", session.FileName, err)
+		}
+	}
+	for _, session := range []*models.UploadSession{paused, fresh} {
+		if _, err := manager.GetSession(session.ID); err != nil {
+			t.Errorf("Expected %s to be kept, got %v", session.FileName, err)
+		}
+	}
+
+	expired, err = manager.ExpireSessions(time.Hour, 3*time.Hour)
+	if err != nil {
+		t.Fatalf("ExpireSessions failed: %v", err)
+	}
+	if expired != 0 {
+		t.Errorf("Expected the paused session to be kept within its TTL, got %d expired", expired)
+	}
+	fake.Advance(2 * time.Hour)
+	if expired, err = manager.ExpireSessions(24*time.Hour, 3*time.Hour); err != nil || expired != 1 {
+		t.Errorf("Expected the paused session to expire after its TTL, got %d, %v", expired, err)
+	}
+	if _, err := manager.GetSession(paused.ID); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected the paused session to be expired, got %v", err)
+	}
+}
+
+func TestSweeperFollowsClock(t *testing.T) {
+	fake := clock.NewFake(time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC))
+	options := DefaultOptions()
+	options.Clock = fake
+	options.SessionTTL = time.Hour
+	options.SweepInterval = time.Hour
+	options.SweepPollInterval = time.Millisecond
+	manager := NewManagerWithOptions(t.TempDir(), 1, options)
+	defer manager.Stop()
+
+	session, err := manager.CreateSession(&models.StartUploadRequest{FileName: "idle.jpg", FileSize: 10, ChunkSize: 10})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+
+	// No sweep is due while the clock stands still
+	time.Sleep(20 * time.Millisecond)
+	if _, err := manager.GetSession(session.ID); err != nil {
+		t.Fatalf("Expected the session to be kept before the clock moves, got %v", err)
+	}
+
+	fake.Advance(2 * time.Hour)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := manager.GetSession(session.ID); errors.Is(err, ErrSessionNotFound) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the session to be swept once the clock passed the interval")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
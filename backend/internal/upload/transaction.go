@@ -0,0 +1,102 @@
+package upload
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/Steven-harris/sortify/backend/internal/models"
+)
+
+// Transactional sessions stage their chunks under StagingDir and only move
+// the file to CommittedDir, where it can be organized, once completion has
+// verified it. A failed completion rolls back by deleting everything staged,
+// so a partially written file is never left behind or organized.
+const (
+	StagingDir   = "staging"
+	CommittedDir = "committed"
+)
+
+func (m *Manager) stagingPath(sessionID string) string {
+	return filepath.Join(m.tempDir, StagingDir, sessionID+".part")
+}
+
+func (m *Manager) committedPath(sessionID string) string {
+	return filepath.Join(m.tempDir, CommittedDir, sessionID+".tmp")
+}
+
+// commit marks a session that just passed completion as completed. A
+// transactional session's file moves out of staging first; the rename is the
+// commit point. The stored session is updated rather than overwritten, so a
+// change another instance made since it was read isn't lost, and one closed
+// meanwhile isn't revived. The caller must hold the manager lock.
+func (m *Manager) commit(session *models.UploadSession) error {
+	tempPath := session.TempPath
+	if session.Transactional {
+		tempPath = m.committedPath(session.ID)
+		if err := os.MkdirAll(filepath.Dir(tempPath), 0755); err != nil {
+			return fmt.Errorf("failed to create commit directory: %w", err)
+		}
+		if err := os.Rename(session.TempPath, tempPath); err != nil {
+			return fmt.Errorf("failed to commit upload: %w", err)
+		}
+	}
+
+	err := m.update(session.ID, func(stored *models.UploadSession) error {
+		if _, err := checkCompletable(stored); err != nil {
+			return err
+		}
+		stored.Status = models.StatusCompleted
+		stored.Error = "" // A retried completion succeeded
+		stored.TempPath = tempPath
+		stored.UpdatedAt = m.clock.Now()
+		return nil
+	})
+	if err != nil && session.Transactional {
+		m.rollback(session.ID, err.Error())
+	}
+	return err
+}
+
+// reject fails a session whose file didn't verify on completion and returns
+// cause. A transactional session is rolled back, deleting its data; other
+// sessions keep theirs for inspection. Completions refused for want of data
+// aren't rejections: the client can still send what is missing. The caller
+// must hold the manager lock.
+func (m *Manager) reject(session *models.UploadSession, cause error) error {
+	if session.Transactional {
+		m.rollback(session.ID, cause.Error())
+		return cause
+	}
+	return m.fail(session.ID, cause)
+}
+
+// Rollback abandons a transactional upload, deleting its staged or committed
+// data and marking the session failed with reason. Use it when a committed
+// upload could not be organized.
+func (m *Manager) Rollback(sessionID, reason string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, err := m.sessions.Get(sessionID); err != nil {
+		return err
+	}
+	m.rollback(sessionID, reason)
+	return nil
+}
+
+// rollback deletes both places a transactional session's data may be, since
+// a failure can strike either side of the commit. The caller must hold the
+// manager lock.
+func (m *Manager) rollback(sessionID, reason string) {
+	for _, path := range []string{m.stagingPath(sessionID), m.committedPath(sessionID)} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			slog.Error("Failed to remove rolled back upload data", "error", err, "sessionId", sessionID, "path", path)
+		}
+	}
+	if err := m.markFailed(sessionID, "rolled back: "+reason); err != nil {
+		slog.Error("Failed to mark session rolled back", "error", err, "sessionId", sessionID)
+	}
+	slog.Info("Transactional upload rolled back", "sessionId", sessionID, "reason", reason)
+}
@@ -0,0 +1,228 @@
+package upload
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Steven-harris/sortify/backend/internal/models"
+)
+
+// tempDirFiles lists every file under dir, relative to it.
+func tempDirFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	var files []string
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			rel, _ := filepath.Rel(dir, path)
+			files = append(files, rel)
+		}
+		return nil
+	})
+	return files
+}
+
+func TestTransactionalUploadCommit(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(tempDir, 5)
+
+	content := []byte("all or nothing")
+	session, err := manager.CreateSession(&models.StartUploadRequest{
+		FileName:      "test.jpg",
+		FileSize:      int64(len(content)),
+		ChunkSize:     8,
+		Checksum:      fmt.Sprintf("%x", sha256.Sum256(content)),
+		Transactional: true,
+	})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if filepath.Dir(session.TempPath) != filepath.Join(tempDir, StagingDir) {
+		t.Errorf("Expected the data staged under %s, got %s", StagingDir, session.TempPath)
+	}
+
+	for chunk := 0; chunk < 2; chunk++ {
+		end := min((chunk+1)*8, len(content))
+		if err := manager.UploadChunk(session.ID, chunk, content[chunk*8:end], ""); err != nil {
+			t.Fatalf("UploadChunk %d failed: %v", chunk, err)
+		}
+	}
+
+	// Nothing is organizable before the commit
+	if _, err := manager.GetTempFilePath(session.ID); err == nil {
+		t.Error("Expected no temp file path before completion")
+	}
+
+	if err := manager.CompleteUpload(session.ID, ""); err != nil {
+		t.Fatalf("CompleteUpload failed: %v", err)
+	}
+
+	path, err := manager.GetTempFilePath(session.ID)
+	if err != nil {
+		t.Fatalf("GetTempFilePath failed: %v", err)
+	}
+	if path != manager.committedPath(session.ID) {
+		t.Errorf("Expected the committed path, got %s", path)
+	}
+	if data, err := os.ReadFile(path); err != nil || string(data) != string(content) {
+		t.Errorf("Expected the committed file to hold the upload, got %q (%v)", data, err)
+	}
+	if files := tempDirFiles(t, filepath.Join(tempDir, StagingDir)); len(files) != 0 {
+		t.Errorf("Expected nothing left in staging, got %v", files)
+	}
+
+	if err := manager.CleanupSession(session.ID); err != nil {
+		t.Fatalf("CleanupSession failed: %v", err)
+	}
+	if files := tempDirFiles(t, tempDir); len(files) != 0 {
+		t.Errorf("Expected no files after cleanup, got %v", files)
+	}
+}
+
+func TestTransactionalUploadRollback(t *testing.T) {
+	tests := []struct {
+		name     string
+		chunks   int    // Chunks of the two sent before completing
+		checksum string // Sent on completion
+		expected error
+	}{
+		{"Checksum mismatch", 2, strings.Repeat("0", 64), ErrChecksumMismatch},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			manager := NewManager(tempDir, 5)
+
+			content := []byte("all or nothing")
+			session, err := manager.CreateSession(&models.StartUploadRequest{
+				FileName:      "test.jpg",
+				FileSize:      int64(len(content)),
+				ChunkSize:     8,
+				Transactional: true,
+			})
+			if err != nil {
+				t.Fatalf("CreateSession failed: %v", err)
+			}
+			for chunk := 0; chunk < test.chunks; chunk++ {
+				end := min((chunk+1)*8, len(content))
+				if err := manager.UploadChunk(session.ID, chunk, content[chunk*8:end], ""); err != nil {
+					t.Fatalf("UploadChunk %d failed: %v", chunk, err)
+				}
+			}
+
+			if err := manager.CompleteUpload(session.ID, test.checksum); !errors.Is(err, test.expected) {
+				t.Fatalf("Expected %v, got %v", test.expected, err)
+			}
+
+			if files := tempDirFiles(t, tempDir); len(files) != 0 {
+				t.Errorf("Expected the rollback to leave no residue, got %v", files)
+			}
+			stored, err := manager.GetSession(session.ID)
+			if err != nil {
+				t.Fatalf("GetSession failed: %v", err)
+			}
+			if stored.Status != models.StatusFailed || !strings.HasPrefix(stored.Error, "rolled back") {
+				t.Errorf("Expected a rolled back failed session, got %s %q", stored.Status, stored.Error)
+			}
+			if _, err := manager.GetTempFilePath(session.ID); err == nil {
+				t.Error("Expected nothing to organize after a rollback")
+			}
+
+			// The staged data is gone, so later chunks can't resurrect it
+			if err := manager.UploadChunk(session.ID, 0, content[:8], ""); err == nil {
+				t.Error("Expected chunks for a rolled back session to be refused")
+			}
+			if files := tempDirFiles(t, tempDir); len(files) != 0 {
+				t.Errorf("Expected no residue after a late chunk, got %v", files)
+			}
+		})
+	}
+}
+
+func TestTransactionalCompletionWaitsForMissingChunks(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(tempDir, 5)
+
+	content := []byte("all or nothing")
+	session, err := manager.CreateSession(&models.StartUploadRequest{
+		FileName:      "test.jpg",
+		FileSize:      int64(len(content)),
+		ChunkSize:     8,
+		Transactional: true,
+	})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if err := manager.UploadChunk(session.ID, 0, content[:8], ""); err != nil {
+		t.Fatalf("UploadChunk failed: %v", err)
+	}
+
+	// Completing early is refused but rolls nothing back
+	if err := manager.CompleteUpload(session.ID, ""); err == nil {
+		t.Fatal("Expected completion to wait for the missing chunk")
+	}
+	if err := manager.UploadChunk(session.ID, 1, content[8:], ""); err != nil {
+		t.Fatalf("Expected the missing chunk accepted after an early completion, got %v", err)
+	}
+	if err := manager.CompleteUpload(session.ID, ""); err != nil {
+		t.Fatalf("CompleteUpload failed: %v", err)
+	}
+
+	// Repeating the completion, as a retried organize does, succeeds again
+	if err := manager.CompleteUpload(session.ID, ""); err != nil {
+		t.Errorf("Expected a repeated completion to succeed, got %v", err)
+	}
+	tempPath, err := manager.GetTempFilePath(session.ID)
+	if err != nil {
+		t.Fatalf("GetTempFilePath failed: %v", err)
+	}
+	if data, err := os.ReadFile(tempPath); err != nil || string(data) != string(content) {
+		t.Errorf("Expected the committed file intact, got %q (%v)", data, err)
+	}
+
+	// A failed session can't be completed into success
+	if err := manager.Rollback(session.ID, "organize failed"); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if err := manager.CompleteUpload(session.ID, ""); !errors.Is(err, ErrSessionClosed) {
+		t.Errorf("Expected %v, got %v", ErrSessionClosed, err)
+	}
+}
+
+func TestRollbackAfterCommit(t *testing.T) {
+	tempDir := t.TempDir()
+	manager := NewManager(tempDir, 5)
+
+	session, err := manager.CreateSession(&models.StartUploadRequest{
+		FileName:      "test.jpg",
+		FileSize:      4,
+		ChunkSize:     4,
+		Transactional: true,
+	})
+	if err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if err := manager.UploadChunk(session.ID, 0, []byte("data"), ""); err != nil {
+		t.Fatalf("UploadChunk failed: %v", err)
+	}
+	if err := manager.CompleteUpload(session.ID, ""); err != nil {
+		t.Fatalf("CompleteUpload failed: %v", err)
+	}
+
+	// Organizing the committed file failed
+	if err := manager.Rollback(session.ID, "failed to organize file"); err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if files := tempDirFiles(t, tempDir); len(files) != 0 {
+		t.Errorf("Expected the committed file removed, got %v", files)
+	}
+	if err := manager.Rollback("missing", "reason"); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound, got %v", err)
+	}
+}
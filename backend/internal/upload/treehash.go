@@ -0,0 +1,153 @@
+package upload
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/Steven-harris/sortify/backend/internal/models"
+)
+
+// TreeHash returns the Merkle root of the given leaf digests. Leaves are the
+// SHA-256 of each chunk in order; each level pairs neighbours as
+// SHA-256(left || right), and a node left over at the end of a level moves up
+// unchanged. A single leaf is its own root.
+func TreeHash(leaves [][]byte) []byte {
+	if len(leaves) == 0 {
+		sum := sha256.Sum256(nil)
+		return sum[:]
+	}
+
+	level := leaves
+	for len(level) > 1 {
+		next := make([][]byte, 0, (len(level)+1)/2)
+		for i := 0; i < len(level); i += 2 {
+			if i+1 == len(level) {
+				next = append(next, level[i])
+				continue
+			}
+			h := sha256.New()
+			h.Write(level[i])
+			h.Write(level[i+1])
+			next = append(next, h.Sum(nil))
+		}
+		level = next
+	}
+	return level[0]
+}
+
+// recordChunkHash keeps the SHA-256 of a chunk's bytes for tree-hash
+// completion, forgetting a digest of bytes the chunk no longer holds.
+func recordChunkHash(session *models.UploadSession, chunkNumber int, digest string) {
+	if digest == "" {
+		delete(session.ChunkHashes, chunkNumber)
+		return
+	}
+	if session.ChunkHashes == nil {
+		session.ChunkHashes = make(map[int]string)
+	}
+	session.ChunkHashes[chunkNumber] = digest
+}
+
+// CompleteUploadTree completes an upload by checking the tree hash of its
+// chunks against root instead of hashing the whole file. Chunks sent with a
+// SHA-256 checksum contribute the digest computed as they were written; only
+// the rest are read back from disk. As with SkipFullChecksum, bytes corrupted
+// on disk after their chunk was hashed go unnoticed.
+func (m *Manager) CompleteUploadTree(sessionID, root string) error {
+	expected, err := hex.DecodeString(root)
+	if err != nil || len(expected) != sha256.Size {
+		return fmt.Errorf("%w: tree roots are %d hex characters, got %q", ErrMalformedChecksum, 2*sha256.Size, root)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	session, err := m.sessions.Get(sessionID)
+	if err != nil {
+		return err
+	}
+	if done, err := checkCompletable(session); done || err != nil {
+		return err
+	}
+	if err := checkReceived(session); err != nil {
+		return err
+	}
+
+	leaves, reread, err := chunkLeaves(session)
+	if err != nil {
+		return m.reject(session, fmt.Errorf("failed to calculate tree hash: %w", err))
+	}
+	if actual := TreeHash(leaves); !strings.EqualFold(hex.EncodeToString(actual), root) {
+		return m.reject(session, fmt.Errorf("%w: tree root does not match the chunks", ErrChecksumMismatch))
+	}
+	slog.Info("Upload verified by tree hash", "sessionId", sessionID, "chunksReread", reread)
+
+	return m.commit(session)
+}
+
+// verifyChunkHashes reads back every chunk with a recorded SHA-256 and checks
+// the bytes on disk still match it, catching a chunk written to the wrong
+// offset or corrupted after it was checked.
+func verifyChunkHashes(session *models.UploadSession) error {
+	if len(session.ChunkHashes) == 0 {
+		return nil
+	}
+
+	file, err := os.Open(session.TempPath)
+	if err != nil {
+		return fmt.Errorf("failed to read back chunks: %w", err)
+	}
+	defer file.Close()
+
+	for chunkNumber, digest := range session.ChunkHashes {
+		offset := int64(chunkNumber) * session.ChunkSize
+		h := sha256.New()
+		if _, err := io.Copy(h, io.NewSectionReader(file, offset, min(session.ChunkSize, session.FileSize-offset))); err != nil {
+			return fmt.Errorf("failed to read back chunks: %w", err)
+		}
+		if !strings.EqualFold(hex.EncodeToString(h.Sum(nil)), digest) {
+			return fmt.Errorf("%w: chunk %d changed on disk", ErrChecksumMismatch, chunkNumber)
+		}
+	}
+	return nil
+}
+
+// chunkLeaves returns the SHA-256 of every chunk, reading back those without
+// a recorded digest, and how many had to be read.
+func chunkLeaves(session *models.UploadSession) ([][]byte, int, error) {
+	leaves := make([][]byte, session.TotalChunks)
+	var file *os.File
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+
+	reread := 0
+	for chunkNumber := range leaves {
+		if digest, err := hex.DecodeString(session.ChunkHashes[chunkNumber]); err == nil && len(digest) == sha256.Size {
+			leaves[chunkNumber] = digest
+			continue
+		}
+
+		if file == nil {
+			var err error
+			if file, err = os.Open(session.TempPath); err != nil {
+				return nil, 0, err
+			}
+		}
+		offset := int64(chunkNumber) * session.ChunkSize
+		h := sha256.New()
+		if _, err := io.Copy(h, io.NewSectionReader(file, offset, min(session.ChunkSize, session.FileSize-offset))); err != nil {
+			return nil, 0, err
+		}
+		leaves[chunkNumber] = h.Sum(nil)
+		reread++
+	}
+	return leaves, reread, nil
+}
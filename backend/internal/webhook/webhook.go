@@ -0,0 +1,261 @@
+// Package webhook posts library events, such as a file being organized, to a
+// configured URL so downstream automation can react to them. Delivery happens
+// in the background with retries; a slow or failing endpoint never holds up
+// the request that raised the event.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Event types.
+const (
+	EventFileOrganized = "file_organized" // A file was filed into the library by an upload, import, refresh or reorganize
+	EventUploadFailed  = "upload_failed"  // An upload could not be completed or organized
+	EventDateRequired  = "date_required"  // REQUIRE_DATE held back an upload that has no capture date
+)
+
+// Events lists every event type a notifier can send.
+var Events = []string{EventFileOrganized, EventUploadFailed, EventDateRequired}
+
+// ErrUnknownEvent reports an event type that is never sent, so filtering on
+// it would silently deliver nothing.
+var ErrUnknownEvent = errors.New("unknown webhook event")
+
+// ValidateEvents checks that every configured event type is one in Events.
+func ValidateEvents(events []string) error {
+	for _, event := range events {
+		if !slices.Contains(Events, event) {
+			return fmt.Errorf("%w %q, expected one of %v", ErrUnknownEvent, event, Events)
+		}
+	}
+	return nil
+}
+
+// Event is the JSON body of each webhook request.
+type Event struct {
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+	Data any       `json:"data"`
+}
+
+const (
+	defaultRetryBackoff = time.Second
+	defaultTimeout      = 10 * time.Second
+	defaultQueueSize    = 256
+)
+
+// Options configures a Notifier; zero durations and sizes take the defaults.
+type Options struct {
+	URL          string
+	Events       []string      // Event types to send; empty sends all of them
+	Retries      int           // Further attempts after a failed delivery; zero disables retrying
+	RetryBackoff time.Duration // Wait before the first retry, doubling after each
+	Timeout      time.Duration // Per-attempt request timeout
+	QueueSize    int           // Events waiting for delivery before new ones are dropped
+	Client       *http.Client  // Nil uses a client with Timeout
+}
+
+// Notifier delivers events to a webhook URL from a single background worker,
+// so events arrive in the order they were raised. A nil Notifier discards
+// everything, which lets callers skip checking whether webhooks are on.
+type Notifier struct {
+	url     string
+	events  map[string]bool // Nil sends every event
+	retries int
+	backoff time.Duration
+	client  *http.Client
+
+	mutex  sync.Mutex // Guards closing queue against concurrent sends
+	closed bool
+	queue  chan Event
+	done   chan struct{}
+
+	ctx    context.Context // Done once Shutdown gives up on the queue
+	cancel context.CancelFunc
+}
+
+// New starts a notifier for options.URL, or returns nil when it is empty.
+func New(options Options) *Notifier {
+	if options.URL == "" {
+		return nil
+	}
+
+	retries := max(options.Retries, 0)
+	backoff := options.RetryBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	client := options.Client
+	if client == nil {
+		timeout := options.Timeout
+		if timeout <= 0 {
+			timeout = defaultTimeout
+		}
+		client = &http.Client{Timeout: timeout}
+	}
+	queueSize := options.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+
+	var events map[string]bool
+	if len(options.Events) > 0 {
+		events = make(map[string]bool, len(options.Events))
+		for _, event := range options.Events {
+			events[event] = true
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &Notifier{
+		url:     options.URL,
+		events:  events,
+		retries: retries,
+		backoff: backoff,
+		client:  client,
+		queue:   make(chan Event, queueSize),
+		done:    make(chan struct{}),
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	go n.run()
+	return n
+}
+
+// Send queues an event of the given type without waiting for delivery.
+// Events that aren't configured are ignored, and when the queue is full the
+// event is dropped with a warning rather than blocking the caller.
+func (n *Notifier) Send(eventType string, data any) {
+	if n == nil || (n.events != nil && !n.events[eventType]) {
+		return
+	}
+
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	if n.closed {
+		return
+	}
+	select {
+	case n.queue <- Event{Type: eventType, Time: time.Now().UTC(), Data: data}:
+	default:
+		slog.Warn("Webhook queue full, dropping event", "event", eventType)
+	}
+}
+
+// Close stops accepting events and waits for the queued ones to be
+// delivered or given up on, however long their retries take.
+func (n *Notifier) Close() {
+	n.Shutdown(context.Background())
+}
+
+// Shutdown stops accepting events and waits for the queued ones until ctx is
+// done. Past that the delivery in flight is cancelled and the rest of the
+// queue is dropped, and ctx's error is returned.
+func (n *Notifier) Shutdown(ctx context.Context) error {
+	if n == nil {
+		return nil
+	}
+	n.mutex.Lock()
+	if !n.closed {
+		n.closed = true
+		close(n.queue)
+	}
+	n.mutex.Unlock()
+
+	select {
+	case <-n.done:
+		return nil
+	case <-ctx.Done():
+		n.cancel()
+		<-n.done
+		return ctx.Err()
+	}
+}
+
+func (n *Notifier) run() {
+	defer close(n.done)
+	defer n.cancel()
+
+	dropped := 0
+	for event := range n.queue {
+		if n.ctx.Err() != nil {
+			dropped++
+			continue
+		}
+		n.deliver(event)
+	}
+	if dropped > 0 {
+		slog.Warn("Webhook shutdown timed out, dropping queued events", "dropped", dropped)
+	}
+}
+
+// deliver posts event, retrying network errors, rate limiting and server
+// errors with a doubling backoff. Other client errors mean the endpoint
+// rejected the event, which a retry won't change.
+func (n *Notifier) deliver(event Event) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("Failed to encode webhook event", "error", err, "event", event.Type)
+		return
+	}
+
+	backoff := n.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := n.post(body)
+		if err == nil {
+			return
+		}
+		if !retry || attempt >= n.retries || n.ctx.Err() != nil {
+			slog.Error("Failed to deliver webhook", "error", err, "event", event.Type, "attempts", attempt+1)
+			return
+		}
+
+		slog.Warn("Webhook delivery failed, retrying",
+			"error", err,
+			"event", event.Type,
+			"attempt", attempt+1,
+			"backoff", backoff,
+		)
+		select {
+		case <-time.After(backoff):
+		case <-n.ctx.Done():
+		}
+		backoff *= 2
+	}
+}
+
+// post sends one attempt and reports whether a failure is worth retrying.
+func (n *Notifier) post(body []byte) (retry bool, err error) {
+	req, err := http.NewRequestWithContext(n.ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Sortify-Webhook")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("webhook returned %s", resp.Status)
+	default:
+		return false, fmt.Errorf("webhook returned %s", resp.Status)
+	}
+}
@@ -0,0 +1,160 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder is a stub webhook endpoint answering with statuses in turn, then
+// 200 OK once they run out.
+type recorder struct {
+	mutex    sync.Mutex
+	statuses []int
+	events   []Event
+	attempts int
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rec.mutex.Lock()
+	defer rec.mutex.Unlock()
+
+	rec.attempts++
+	var event Event
+	if err := json.NewDecoder(r.Body).Decode(&event); err == nil && r.Header.Get("Content-Type") == "application/json" {
+		rec.events = append(rec.events, event)
+	}
+	if len(rec.statuses) > 0 {
+		w.WriteHeader(rec.statuses[0])
+		rec.statuses = rec.statuses[1:]
+	}
+}
+
+func TestNotifierDelivery(t *testing.T) {
+	tests := []struct {
+		name             string
+		statuses         []int
+		retries          int
+		expectedAttempts int
+	}{
+		{"Delivered first time", nil, 3, 1},
+		{"Server errors are retried", []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}, 3, 3},
+		{"Retries run out", []int{500, 500, 500}, 2, 3},
+		{"Client errors are not retried", []int{http.StatusBadRequest}, 3, 1},
+		{"Retries disabled", []int{500}, 0, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rec := &recorder{statuses: test.statuses}
+			server := httptest.NewServer(rec)
+			defer server.Close()
+
+			notifier := New(Options{URL: server.URL, Retries: test.retries, RetryBackoff: time.Millisecond})
+			notifier.Send(EventFileOrganized, map[string]string{"fileName": "IMG_0001.jpg"})
+			notifier.Close()
+
+			if rec.attempts != test.expectedAttempts {
+				t.Errorf("Expected %d attempts, got %d", test.expectedAttempts, rec.attempts)
+			}
+			if len(rec.events) == 0 {
+				t.Fatal("Expected the event to reach the endpoint")
+			}
+			event := rec.events[0]
+			if event.Type != EventFileOrganized || event.Time.IsZero() {
+				t.Errorf("Expected a timestamped %s event, got %+v", EventFileOrganized, event)
+			}
+			if data, _ := event.Data.(map[string]any); data["fileName"] != "IMG_0001.jpg" {
+				t.Errorf("Expected the event data, got %v", event.Data)
+			}
+		})
+	}
+}
+
+func TestNotifierEventFilter(t *testing.T) {
+	rec := &recorder{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	notifier := New(Options{URL: server.URL, Events: []string{EventUploadFailed}})
+	notifier.Send(EventFileOrganized, nil)
+	notifier.Send(EventUploadFailed, nil)
+	notifier.Close()
+
+	if len(rec.events) != 1 || rec.events[0].Type != EventUploadFailed {
+		t.Errorf("Expected only the %s event, got %+v", EventUploadFailed, rec.events)
+	}
+
+	// Sending after Close is ignored rather than panicking
+	notifier.Send(EventUploadFailed, nil)
+}
+
+func TestNotifierDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	notifier := New(Options{URL: server.URL, QueueSize: 1})
+	start := time.Now()
+	for range 10 {
+		notifier.Send(EventFileOrganized, nil)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected sending to a stalled endpoint not to block, took %v", elapsed)
+	}
+}
+
+func TestNilNotifier(t *testing.T) {
+	notifier := New(Options{})
+	if notifier != nil {
+		t.Fatal("Expected no notifier without a URL")
+	}
+
+	// A disabled notifier accepts and discards events
+	notifier.Send(EventFileOrganized, nil)
+	notifier.Close()
+}
+
+func TestNotifierShutdownIsBounded(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	notifier := New(Options{URL: server.URL, Retries: 3, Timeout: time.Hour})
+	for range 5 {
+		notifier.Send(EventFileOrganized, nil)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := notifier.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected shutdown to give up on a stalled endpoint, took %v", elapsed)
+	}
+}
+
+func TestValidateEvents(t *testing.T) {
+	if err := ValidateEvents([]string{EventFileOrganized, EventUploadFailed}); err != nil {
+		t.Errorf("Expected known events to be accepted, got %v", err)
+	}
+	if err := ValidateEvents([]string{"file_organised"}); !errors.Is(err, ErrUnknownEvent) {
+		t.Errorf("Expected ErrUnknownEvent, got %v", err)
+	}
+}
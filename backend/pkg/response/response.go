@@ -7,7 +7,20 @@ import (
 )
 
 type Problem struct {
-	Error string `json:"error"`
+	Error  string            `json:"error"`
+	Errors map[string]string `json:"errors,omitempty"` // Per-field validation failures
+}
+
+// ValidationErrors collects every invalid field of a request, keyed by the
+// field's JSON name, so clients can fix them all in one round trip.
+type ValidationErrors map[string]string
+
+func (v ValidationErrors) Add(field, message string) {
+	v[field] = message
+}
+
+func (v ValidationErrors) HasErrors() bool {
+	return len(v) > 0
 }
 
 func JSON(w http.ResponseWriter, statusCode int, data any) {
@@ -54,3 +67,12 @@ func NotFound(w http.ResponseWriter, error string) {
 func Unauthorized(w http.ResponseWriter, error string) {
 	Error(w, http.StatusUnauthorized, error)
 }
+
+func ValidationFailed(w http.ResponseWriter, errors ValidationErrors) {
+	slog.Warn("A bad request was made", "error", "validation failed", "fields", errors)
+
+	JSON(w, http.StatusBadRequest, Problem{
+		Error:  "Validation failed",
+		Errors: errors,
+	})
+}
@@ -731,8 +731,8 @@ export class SortifyUpload extends LitElement {
         item.status = 'completed';
         item.processResponse = {
           id: uploadResponse.sessionId ?? uploadResponse.id ?? '',
-          originalPath: uploadResponse.filename,
-          organizedPath: uploadResponse.filename,
+          originalPath: uploadResponse.fileName,
+          organizedPath: uploadResponse.fileName,
           metadata: uploadResponse.mediaInfo || {},
           status: 'completed'
         };
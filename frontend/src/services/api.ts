@@ -12,7 +12,7 @@ export interface UploadOptions {
 export interface UploadResponse {
   sessionId?: string;
   id?: string;
-  filename: string;
+  fileName: string;
   mediaInfo?: any;
   organized?: boolean;
   size?: number;